@@ -4,14 +4,52 @@ import (
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/postgresql"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/server"
+	"github.com/Knoblauchpilze/user-service/internal/clientip"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
 	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn"
+)
+
+type Environment string
+
+const (
+	Production  Environment = "production"
+	Development Environment = "development"
 )
 
 type Configuration struct {
-	Server   server.Config
-	Database postgresql.Config
-	ApiKey   service.ApiKeyConfig
+	// Environment relaxes some checks of the configuration, such as the
+	// transport of the messages, when set to development.
+	Environment   Environment
+	Server        server.Config
+	ClientIp      clientip.Config
+	Database      postgresql.Config
+	ApiKey        service.ApiKeyConfig
+	Jwt           jwt.Config
+	Keyring       keyring.Config
+	Admin         service.AdminConfig
+	OAuth         service.OAuthConfig
+	Oidc          service.OidcConfig
+	Federation    federation.Config
+	Saml          saml.Config
+	Totp          totp.Config
+	WebAuthn      webauthn.Config
+	LoginThrottle service.LoginThrottleConfig
+	Verification  service.VerificationConfig
+	PasswordReset service.PasswordResetConfig
+	EmailLogin    service.EmailLoginConfig
+	Email         email.Config
+	Mail          mail.Config
+	Password      password.Config
 }
 
 func DefaultConfig() Configuration {
@@ -19,6 +57,7 @@ func DefaultConfig() Configuration {
 	const defaultDatabaseUser = "user_service_manager"
 
 	return Configuration{
+		Environment: Production,
 		Server: server.Config{
 			BasePath:        "/v1/users",
 			Port:            uint16(80),
@@ -30,7 +69,100 @@ func DefaultConfig() Configuration {
 			"comes-from-the-environment",
 		),
 		ApiKey: service.ApiKeyConfig{
-			Validity: time.Duration(3 * time.Hour),
+			Validity:             time.Duration(3 * time.Hour),
+			MaxLifetime:          time.Duration(7 * 24 * time.Hour),
+			RefreshValidity:      time.Duration(30 * 24 * time.Hour),
+			MaxPerUser:           10,
+			Eviction:             service.EvictOldest,
+			LegacyFormatDeadline: "2027-01-01T00:00:00Z",
+		},
+		Jwt: jwt.Config{
+			Enabled: false,
+			Issuer:  "user-service",
+		},
+		Keyring: keyring.Config{
+			RotationInterval: 90 * 24 * time.Hour,
+			ActivationDelay:  1 * time.Hour,
+			GracePeriod:      30 * 24 * time.Hour,
+			SigningAlgorithm: keyring.EdDSA,
+		},
+		Oidc: service.OidcConfig{
+			Enabled:      false,
+			CodeValidity: 1 * time.Minute,
+		},
+		Federation: federation.Config{
+			LoginValidity: 10 * time.Minute,
+		},
+		Saml: saml.Config{
+			RequestValidity: 10 * time.Minute,
+		},
+		Totp: totp.Config{
+			Issuer: "user-service",
+		},
+		WebAuthn: webauthn.Config{
+			RpName:           "user-service",
+			CeremonyValidity: 5 * time.Minute,
+		},
+		LoginThrottle: service.LoginThrottleConfig{
+			FreeAttempts:     3,
+			BaseDelay:        1 * time.Second,
+			MaxDelay:         5 * time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  30 * time.Minute,
+			ResetAfter:       24 * time.Hour,
+		},
+		Verification: service.VerificationConfig{
+			TokenValidity: 24 * time.Hour,
+			Required:      false,
+		},
+		PasswordReset: service.PasswordResetConfig{
+			TokenValidity: 30 * time.Minute,
+			MaxRequests:   5,
+			RequestWindow: 1 * time.Hour,
+		},
+		EmailLogin: service.EmailLoginConfig{
+			Enabled:       false,
+			TokenValidity: 15 * time.Minute,
+			MaxAttempts:   5,
+			MaxRequests:   5,
+			RequestWindow: 1 * time.Hour,
+		},
+		Email: email.Config{
+			LowercaseLocalPart: false,
+		},
+		Mail: mail.Config{
+			Transport: mail.DisabledTransport,
+			From:      "user-service@localhost",
+		},
+		Password: password.Config{
+			Algorithm: password.Argon2id,
+			Argon2: password.Argon2Config{
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+			Bcrypt: password.BcryptConfig{
+				Cost: 12,
+			},
+			Policy: password.PolicyConfig{
+				MinLength:             8,
+				MaxLength:             64,
+				BanEmailLocalPart:     true,
+				RejectCommonPasswords: true,
+				HistorySize:           5,
+			},
 		},
 	}
 }
+
+// ValidateMail refuses the transports which are only meant for development
+// outside of it: the messages hold secrets.
+func (c Configuration) ValidateMail() error {
+	if c.Mail.Transport == mail.LogTransport && c.Environment != Development {
+		return errors.NewCodeWithDetails(mail.InvalidConfiguration, "log transport is only allowed in development")
+	}
+
+	return c.Mail.Validate()
+}
@@ -2,7 +2,12 @@ package internal
 
 import (
 	"testing"
+	"time"
 
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,6 +18,12 @@ func TestUnit_DefaultConfig_DefinesCorrectRestConfiguration(t *testing.T) {
 	assert.Equal(t, uint16(80), config.Server.Port)
 }
 
+func TestUnit_DefaultConfig_DoesNotTrustAnyProxy(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Empty(t, config.ClientIp.TrustedProxies)
+}
+
 func TestUnit_DefaultConfig_SetsExpectedDbConnection(t *testing.T) {
 	config := DefaultConfig()
 
@@ -26,3 +37,139 @@ func TestUnit_DefaultConfig_DoesNotSetDbPassword(t *testing.T) {
 
 	assert.Equal(t, "comes-from-the-environment", config.Database.Password)
 }
+
+func TestUnit_DefaultConfig_HashesPasswordsWithArgon2id(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Equal(t, password.Argon2id, config.Password.Algorithm)
+	assert.Equal(t, uint32(64*1024), config.Password.Argon2.Memory)
+}
+
+func TestUnit_DefaultConfig_DefinesPasswordPolicy(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Equal(t, 8, config.Password.Policy.MinLength)
+	assert.Equal(t, 64, config.Password.Policy.MaxLength)
+	assert.True(t, config.Password.Policy.BanEmailLocalPart)
+	assert.True(t, config.Password.Policy.RejectCommonPasswords)
+	assert.Equal(t, 5, config.Password.Policy.HistorySize)
+}
+
+func TestUnit_DefaultConfig_KeepsEmailLocalPartCase(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Email.LowercaseLocalPart)
+}
+
+func TestUnit_DefaultConfig_LetsUnverifiedUsersLogIn(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Verification.Required)
+	assert.Equal(t, 24*time.Hour, config.Verification.TokenValidity)
+	assert.Nil(t, config.Verification.Validate())
+}
+
+func TestUnit_DefaultConfig_ExpiresPasswordResetLinksQuickly(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Equal(t, 30*time.Minute, config.PasswordReset.TokenValidity)
+	assert.Nil(t, config.PasswordReset.Validate())
+}
+
+func TestUnit_DefaultConfig_DisablesEmailLogin(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.EmailLogin.Enabled)
+	assert.Equal(t, 15*time.Minute, config.EmailLogin.TokenValidity)
+	assert.Equal(t, 5, config.EmailLogin.MaxAttempts)
+
+	config.EmailLogin.Enabled = true
+	assert.Nil(t, config.EmailLogin.Validate())
+}
+
+func TestUnit_DefaultConfig_DoesNotSendMessages(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Equal(t, Production, config.Environment)
+	assert.Equal(t, mail.DisabledTransport, config.Mail.Transport)
+	assert.Nil(t, config.ValidateMail())
+}
+
+func TestUnit_Configuration_ValidateMail_WhenLogTransportInProduction_ExpectError(t *testing.T) {
+	config := DefaultConfig()
+	config.Mail.Transport = mail.LogTransport
+
+	err := config.ValidateMail()
+
+	assert.True(t, errors.IsErrorWithCode(err, mail.InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Configuration_ValidateMail_WhenLogTransportInDevelopment_ExpectSuccess(t *testing.T) {
+	config := DefaultConfig()
+	config.Environment = Development
+	config.Mail.Transport = mail.LogTransport
+
+	err := config.ValidateMail()
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_DefaultConfig_ThrottlesLoginAttempts(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Equal(t, 3, config.LoginThrottle.FreeAttempts)
+	assert.Equal(t, 1*time.Second, config.LoginThrottle.BaseDelay)
+	assert.Equal(t, 5*time.Minute, config.LoginThrottle.MaxDelay)
+	assert.Equal(t, 10, config.LoginThrottle.LockoutThreshold)
+	assert.Equal(t, 30*time.Minute, config.LoginThrottle.LockoutDuration)
+	assert.Equal(t, 24*time.Hour, config.LoginThrottle.ResetAfter)
+}
+
+func TestUnit_DefaultConfig_DoesNotIssueSignedTokens(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Jwt.Enabled)
+}
+
+func TestUnit_DefaultConfig_DoesNotEnableKeyring(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Keyring.Enabled())
+	assert.Equal(t, keyring.EdDSA, config.Keyring.SigningAlgorithm)
+}
+
+func TestUnit_DefaultConfig_DisablesOpenIdConnect(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Oidc.Enabled)
+	assert.Equal(t, 1*time.Minute, config.Oidc.CodeValidity)
+}
+
+func TestUnit_DefaultConfig_HasNoIdentityProviders(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Empty(t, config.Federation.Providers)
+	assert.Equal(t, 10*time.Minute, config.Federation.LoginValidity)
+}
+
+func TestUnit_DefaultConfig_HasNoSamlTenants(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.Empty(t, config.Saml.Tenants)
+	assert.Equal(t, 10*time.Minute, config.Saml.RequestValidity)
+}
+
+func TestUnit_DefaultConfig_DisablesTwoFactorAuthentication(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.Totp.Enabled())
+	assert.Equal(t, "user-service", config.Totp.Issuer)
+}
+
+func TestUnit_DefaultConfig_DisablesPasskeys(t *testing.T) {
+	config := DefaultConfig()
+
+	assert.False(t, config.WebAuthn.Enabled())
+	assert.Equal(t, "user-service", config.WebAuthn.RpName)
+	assert.Equal(t, 5*time.Minute, config.WebAuthn.CeremonyValidity)
+}
@@ -24,29 +24,123 @@ import (
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/server"
 	_ "github.com/Knoblauchpilze/user-service/api"
 	"github.com/Knoblauchpilze/user-service/cmd/users/internal"
+	"github.com/Knoblauchpilze/user-service/internal/clientip"
 	"github.com/Knoblauchpilze/user-service/internal/controller"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
 	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	echoSwagger "github.com/swaggo/echo-swagger/v2"
 )
 
-func determineConfigName() string {
-	if len(os.Args) < 2 {
+// rotateKeysCommand replaces the keys of a purpose right away, for example
+// when they were compromised: ./users rotate-keys <purpose> [config].
+const rotateKeysCommand = "rotate-keys"
+
+// normalizeEmailsCommand rewrites the stored emails with the configured
+// normalization, after an upgrade or a change of the email options:
+// ./users normalize-emails [config].
+const normalizeEmailsCommand = "normalize-emails"
+
+func determineConfigName(args []string) string {
+	if len(args) < 1 {
 		return "users-prod.yml"
 	}
 
-	return os.Args[1]
+	return args[0]
 }
 
 func main() {
 	log := logger.New(os.Stdout)
 
-	conf, err := config.Load(determineConfigName(), internal.DefaultConfig())
+	if len(os.Args) > 1 && os.Args[1] == rotateKeysCommand {
+		rotateKeys(log, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == normalizeEmailsCommand {
+		normalizeEmails(log, os.Args[2:])
+		return
+	}
+
+	conf, err := config.Load(determineConfigName(os.Args[1:]), internal.DefaultConfig())
 	if err != nil {
 		log.Error("Failed to load configuration", slog.Any("error", err))
 		os.Exit(1)
 	}
 
+	if err := conf.ApiKey.Validate(); err != nil {
+		log.Error("Invalid API key configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := conf.OAuth.Validate(); err != nil {
+		log.Error("Invalid OAuth configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := conf.Verification.Validate(); err != nil {
+		log.Error("Invalid email verification configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := conf.PasswordReset.Validate(); err != nil {
+		log.Error("Invalid password reset configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := conf.EmailLogin.Validate(); err != nil {
+		log.Error("Invalid email login configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	if err := conf.ValidateMail(); err != nil {
+		log.Error("Invalid mail configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	mailer, err := mail.New(conf.Mail, log)
+	if err != nil {
+		log.Error("Invalid mail configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	providers, err := federation.New(conf.Federation)
+	if err != nil {
+		log.Error("Invalid identity providers configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	sp, err := saml.New(conf.Saml)
+	if err != nil {
+		log.Error("Invalid SAML configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	var authenticator *totp.Authenticator
+	if conf.Totp.Enabled() {
+		authenticator, err = totp.New(conf.Totp)
+		if err != nil {
+			log.Error("Invalid two-factor authentication configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	var rp *webauthn.RelyingParty
+	if conf.WebAuthn.Enabled() {
+		rp, err = webauthn.New(conf.WebAuthn)
+		if err != nil {
+			log.Error("Invalid passkeys configuration", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
 	conn, err := db.New(context.Background(), conf.Database)
 	if err != nil {
 		log.Error("Failed to create db connection", slog.Any("error", err))
@@ -55,12 +149,82 @@ func main() {
 	defer conn.Close(context.Background())
 
 	repos := repositories.Repositories{
-		User:   repositories.NewUserRepository(conn),
-		ApiKey: repositories.NewApiKeyRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		RevokedToken:       repositories.NewRevokedTokenRepository(conn),
+		Keyring:            repositories.NewKeyringRepository(conn),
+		AuthorizationCode:  repositories.NewAuthorizationCodeRepository(conn),
+		Identity:           repositories.NewIdentityRepository(conn),
+		FederatedLogin:     repositories.NewFederatedLoginRepository(conn),
+		SamlRequest:        repositories.NewSamlRequestRepository(conn),
+		SamlAssertion:      repositories.NewSamlAssertionRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		WebAuthnCeremony:   repositories.NewWebAuthnCeremonyRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+		PasswordReset:      repositories.NewPasswordResetRepository(conn),
+		EmailLogin:         repositories.NewEmailLoginRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		Permission:         repositories.NewPermissionRepository(conn),
+		Role:               repositories.NewRoleRepository(conn),
+		RoleAssignment:     repositories.NewRoleAssignmentRepository(conn),
 	}
 
-	userService := service.NewUserService(conf.ApiKey, conn, repos)
-	authService := service.NewAuthService(repos)
+	var ring keyring.Keyring
+	if conf.Keyring.Enabled() {
+		ring, err = keyring.New(context.Background(), conf.Keyring, conn, repos.Keyring)
+		if err != nil {
+			log.Error("Failed to create keyring", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	hasher, err := password.NewHasher(conf.Password)
+	if err != nil {
+		log.Error("Failed to create password hasher", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	policy, err := password.NewPolicy(conf.Password.Policy)
+	if err != nil {
+		log.Error("Failed to create password policy", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	normalizer := email.NewNormalizer(conf.Email)
+
+	var signer jwt.Signer
+	if conf.Jwt.Enabled {
+		if ring == nil {
+			log.Error("Signed tokens require a keyring: set a master key")
+			os.Exit(1)
+		}
+
+		signer = jwt.NewSigner(conf.Jwt, ring)
+	}
+
+	if conf.Oidc.Enabled && signer == nil {
+		log.Error("OpenID Connect requires signed tokens: enable them")
+		os.Exit(1)
+	}
+	if err := conf.Oidc.Validate(conf.Jwt.Issuer); err != nil {
+		log.Error("Invalid OpenID Connect configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	userService := service.NewUserService(conf.ApiKey, conf.Admin, conf.LoginThrottle, conf.Verification, signer, ring, mailer, normalizer, hasher, policy, conn, repos)
+	authService := service.NewAuthService(conf.ApiKey, conf.Admin, conf.OAuth, signer, ring, repos)
+
+	extractor, err := clientip.NewExtractor(conf.ClientIp)
+	if err != nil {
+		log.Error("Invalid client IP configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+	controller.UseClientIpExtractor(extractor)
 
 	s := server.NewWithLogger(conf.Server, log)
 
@@ -85,6 +249,117 @@ func main() {
 		}
 	}
 
+	if signer != nil {
+		for _, route := range controller.JwksEndpoints(signer) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	recoveryCodeService := service.NewRecoveryCodeService(userService)
+
+	for _, route := range controller.RecoveryCodeEndpoints(recoveryCodeService) {
+		if err := s.AddRoute(route); err != nil {
+			log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	verificationService := service.NewVerificationService(userService)
+
+	for _, route := range controller.VerificationEndpoints(verificationService) {
+		if err := s.AddRoute(route); err != nil {
+			log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	passwordResetService := service.NewPasswordResetService(conf.PasswordReset, repos, userService, log)
+
+	for _, route := range controller.PasswordResetEndpoints(passwordResetService) {
+		if err := s.AddRoute(route); err != nil {
+			log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	roleService := service.NewRoleService(conn, repos, userService)
+
+	for _, route := range controller.RoleEndpoints(roleService) {
+		if err := s.AddRoute(route); err != nil {
+			log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+			os.Exit(1)
+		}
+	}
+
+	if conf.EmailLogin.Enabled {
+		emailLoginService := service.NewEmailLoginService(conf.EmailLogin, repos, userService)
+
+		for _, route := range controller.EmailLoginEndpoints(emailLoginService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if conf.Oidc.Enabled {
+		oidcService := service.NewOidcService(conf.Oidc, conf.OAuth, authenticator, repos, userService)
+
+		for _, route := range controller.OidcEndpoints(oidcService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if len(providers) > 0 {
+		federationService := service.NewFederationService(conf.Federation, providers, repos, userService)
+
+		for _, route := range controller.FederationEndpoints(federationService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if sp.Enabled() {
+		samlService := service.NewSamlService(conf.Saml, sp, repos, userService)
+
+		for _, route := range controller.SamlEndpoints(samlService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if authenticator != nil {
+		mfaService := service.NewMfaService(authenticator, userService)
+
+		for _, route := range controller.MfaEndpoints(mfaService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
+	if rp != nil {
+		webAuthnService := service.NewWebAuthnService(conf.WebAuthn, rp, repos, userService)
+
+		for _, route := range controller.WebAuthnEndpoints(webAuthnService) {
+			if err := s.AddRoute(route); err != nil {
+				log.Error("Failed to register route", slog.String("route", route.Path()), slog.Any("error", err))
+				os.Exit(1)
+			}
+		}
+	}
+
 	swaggerUi := rest.NewRawRoute(http.MethodGet, "/swagger/*", echoSwagger.WrapHandlerV3)
 	if err := s.AddRoute(swaggerUi); err != nil {
 		log.Error("Failed to register route", slog.String("route", swaggerUi.Path()), slog.Any("error", err))
@@ -103,3 +378,70 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func rotateKeys(log *slog.Logger, args []string) {
+	if len(args) < 1 {
+		log.Error("Usage: users rotate-keys <purpose> [config]")
+		os.Exit(1)
+	}
+
+	purpose, err := keyring.ParsePurpose(args[0])
+	if err != nil {
+		log.Error("Invalid key purpose", slog.String("purpose", args[0]), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	conf, err := config.Load(determineConfigName(args[1:]), internal.DefaultConfig())
+	if err != nil {
+		log.Error("Failed to load configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	conn, err := db.New(context.Background(), conf.Database)
+	if err != nil {
+		log.Error("Failed to create db connection", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer conn.Close(context.Background())
+
+	ring, err := keyring.New(context.Background(), conf.Keyring, conn, repositories.NewKeyringRepository(conn))
+	if err != nil {
+		log.Error("Failed to create keyring", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	key, err := ring.EmergencyRotate(context.Background(), purpose)
+	if err != nil {
+		log.Error("Failed to rotate keys", slog.String("purpose", string(purpose)), slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	log.Info("Rotated keys", slog.String("purpose", string(purpose)), slog.String("key", key.Id))
+}
+
+func normalizeEmails(log *slog.Logger, args []string) {
+	conf, err := config.Load(determineConfigName(args), internal.DefaultConfig())
+	if err != nil {
+		log.Error("Failed to load configuration", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	conn, err := db.New(context.Background(), conf.Database)
+	if err != nil {
+		log.Error("Failed to create db connection", slog.Any("error", err))
+		os.Exit(1)
+	}
+	defer conn.Close(context.Background())
+
+	normalizer := email.NewNormalizer(conf.Email)
+	count, conflicts, err := service.NormalizeEmails(context.Background(), normalizer, repositories.NewUserRepository(conn))
+	for _, id := range conflicts {
+		log.Error("Normalized email is already used", slog.String("user", id.String()))
+	}
+	if err != nil {
+		log.Error("Failed to normalize emails", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	log.Info("Normalized emails", slog.Int("count", count), slog.Int("conflicts", len(conflicts)))
+}
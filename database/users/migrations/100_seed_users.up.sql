@@ -7,10 +7,12 @@ INSERT INTO user_service_schema.api_user ("id", "email", "password")
   );
 
 -- https://www.postgresql.org/docs/current/functions-datetime.html#FUNCTIONS-DATETIME-CURRENT
-INSERT INTO user_service_schema.api_key ("id", "key", "api_user", "valid_until")
+-- Only the digest of the keys is stored: the keys to use in the requests
+-- are the ones passed to 'sha256'.
+INSERT INTO user_service_schema.api_key ("id", "key_hash", "api_user", "valid_until")
   VALUES (
     'a5eff7a9-9bd6-4f51-9b42-a7ca5ffd3f5e',
-    '3e8d49a3-9220-4ea0-88eb-299520c6ab85',
+    encode(sha256(convert_to('usk_live_pqGnzVY6kJAMGr3N18kdtROIF4ax8swbiRVdTV7J4Po47UUh5', 'UTF8')), 'hex'),
     '0463ed3d-bfc9-4c10-b6ee-c223bbca0fab',
      current_timestamp + make_interval(hours => 6)
   );
@@ -23,10 +25,10 @@ INSERT INTO user_service_schema.api_user ("id", "email", "password")
     'super-strong-password'
   );
 
-INSERT INTO user_service_schema.api_key ("id", "key", "api_user", "valid_until")
+INSERT INTO user_service_schema.api_key ("id", "key_hash", "api_user", "valid_until")
   VALUES (
     'fd8136c4-c584-4bbf-a390-53d5c2548fb8',
-    '2da3e9ec-7299-473a-be0f-d722d870f51a',
+    encode(sha256(convert_to('usk_live_Jq6rzcUNgzsxwCE7f2Hk8vEEa9SqZI92UPi61N0HsJ947yYt7', 'UTF8')), 'hex'),
     '4f26321f-d0ea-46a3-83dd-6aa1c6053aaf',
      current_timestamp + make_interval(hours => 6)
   );
@@ -39,10 +41,10 @@ INSERT INTO user_service_schema.api_user ("id", "email", "password")
     'weakpassword'
   );
 
-INSERT INTO user_service_schema.api_key ("id", "key", "api_user", "valid_until")
+INSERT INTO user_service_schema.api_key ("id", "key_hash", "api_user", "valid_until")
   VALUES (
     '42698272-5b8f-42db-a43c-8108eaad66e1',
-    'e9c3ce0d-d6d6-45cb-ad93-c407d429469f',
+    encode(sha256(convert_to('usk_live_RvjBIkEmOXpDtLuY7gC4O5BhGRGAciPI91ISLQEkyZS4p4ruL', 'UTF8')), 'hex'),
     '00b265e6-6638-4b1b-aeac-5898c7307eb8',
      current_timestamp + make_interval(hours => 6)
   );
@@ -56,10 +58,10 @@ INSERT INTO user_service_schema.api_user ("id", "email", "password")
     'mycatismypassword'
   );
 
-INSERT INTO user_service_schema.api_key ("id", "key", "api_user", "valid_until")
+INSERT INTO user_service_schema.api_key ("id", "key_hash", "api_user", "valid_until")
   VALUES (
     'a610adcb-d966-4617-9f15-caf6e48b6325',
-    'c64f4da4-8bc5-4e19-a038-cd8755bd07d5',
+    encode(sha256(convert_to('usk_live_bjbZXSJ75G5CnpXpLUynFa00YG3onAe7oO9Diea2wne45exXH', 'UTF8')), 'hex'),
     'beb2a2dc-2a9f-48d6-b2ca-fd3b5ca3249f',
      current_timestamp + make_interval(hours => 6)
   );
@@ -0,0 +1,7 @@
+
+DROP TRIGGER trigger_api_key_revoke_token ON api_key;
+DROP FUNCTION revoke_api_key_token;
+
+DROP TABLE revoked_token;
+
+ALTER TABLE api_key DROP COLUMN token_id;
@@ -0,0 +1,35 @@
+
+-- Identifier of the signed token issued for the session, if any.
+ALTER TABLE api_key ADD COLUMN token_id UUID;
+
+-- Signed tokens can be verified without the database: the ones belonging to
+-- sessions which were revoked or rotated are listed here until they expire.
+CREATE TABLE revoked_token (
+  id UUID NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE FUNCTION revoke_api_key_token()
+RETURNS TRIGGER AS $$
+BEGIN
+  IF OLD.token_id IS NULL OR OLD.valid_until < current_timestamp THEN
+    RETURN NULL;
+  END IF;
+  IF TG_OP = 'UPDATE' AND OLD.token_id = NEW.token_id THEN
+    RETURN NULL;
+  END IF;
+
+  DELETE FROM revoked_token WHERE expires_at < current_timestamp;
+  INSERT INTO revoked_token (id, expires_at)
+    VALUES (OLD.token_id, OLD.valid_until)
+    ON CONFLICT (id) DO NOTHING;
+
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_api_key_revoke_token
+  AFTER UPDATE OR DELETE ON api_key
+  FOR EACH ROW
+  EXECUTE FUNCTION revoke_api_key_token();
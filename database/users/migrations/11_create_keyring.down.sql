@@ -0,0 +1,2 @@
+
+DROP TABLE keyring_key;
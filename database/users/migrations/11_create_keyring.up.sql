@@ -0,0 +1,18 @@
+
+CREATE TABLE keyring_key (
+  id TEXT NOT NULL,
+  purpose TEXT NOT NULL,
+  algorithm TEXT NOT NULL,
+  -- Encrypted with the master key which is never stored in the database.
+  material BYTEA NOT NULL,
+  state TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  activated_at TIMESTAMP WITH TIME ZONE,
+  retired_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (id),
+  CHECK (state IN ('pending', 'active', 'retired'))
+);
+
+CREATE INDEX keyring_key_purpose_index ON keyring_key (purpose);
+-- There can only be one key in use for each purpose.
+CREATE UNIQUE INDEX keyring_key_active_index ON keyring_key (purpose) WHERE state = 'active';
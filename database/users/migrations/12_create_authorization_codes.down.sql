@@ -0,0 +1,5 @@
+
+DROP TABLE authorization_code;
+
+ALTER TABLE api_key DROP COLUMN scope;
+ALTER TABLE api_key DROP COLUMN client_id;
@@ -0,0 +1,21 @@
+
+-- Sessions opened by OAuth clients: first-party sessions leave these empty.
+ALTER TABLE api_key ADD COLUMN client_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_key ADD COLUMN scope TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE authorization_code (
+  id UUID NOT NULL,
+  code_hash TEXT NOT NULL,
+  client_id TEXT NOT NULL,
+  api_user UUID NOT NULL,
+  redirect_uri TEXT NOT NULL,
+  scope TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  code_challenge TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX authorization_code_code_hash_index ON authorization_code (code_hash);
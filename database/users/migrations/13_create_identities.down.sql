@@ -0,0 +1,3 @@
+
+DROP TABLE federated_login;
+DROP TABLE identity;
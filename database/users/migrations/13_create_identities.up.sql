@@ -0,0 +1,31 @@
+
+-- Accounts of the users at external identity providers.
+CREATE TABLE identity (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  last_login_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX identity_provider_subject_index ON identity (provider, subject);
+CREATE INDEX identity_api_user_index ON identity (api_user);
+
+-- Logins started at an external identity provider and waiting for the
+-- user to come back.
+CREATE TABLE federated_login (
+  id UUID NOT NULL,
+  state_hash TEXT NOT NULL,
+  provider TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX federated_login_state_hash_index ON federated_login (state_hash);
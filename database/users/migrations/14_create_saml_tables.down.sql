@@ -0,0 +1,3 @@
+
+DROP TABLE saml_assertion;
+DROP TABLE saml_request;
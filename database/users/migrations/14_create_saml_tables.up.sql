@@ -0,0 +1,26 @@
+
+-- Authentication requests sent to the SAML identity providers of tenants
+-- and waiting for the user to come back.
+CREATE TABLE saml_request (
+  id UUID NOT NULL,
+  request_id TEXT NOT NULL,
+  relay_state_hash TEXT NOT NULL,
+  tenant TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX saml_request_request_id_index ON saml_request (request_id);
+
+-- Assertions already used to sign in: they are kept until they expire so
+-- that they can't be replayed.
+CREATE TABLE saml_assertion (
+  id UUID NOT NULL,
+  issuer TEXT NOT NULL,
+  assertion_id TEXT NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX saml_assertion_issuer_assertion_id_index ON saml_assertion (issuer, assertion_id);
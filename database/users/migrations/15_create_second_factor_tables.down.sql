@@ -0,0 +1,3 @@
+
+DROP TABLE mfa_challenge;
+DROP TABLE totp_secret;
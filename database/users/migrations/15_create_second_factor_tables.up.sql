@@ -0,0 +1,26 @@
+
+-- Secrets shared with the authenticator apps of the users. The secret is
+-- encrypted and only used once confirmed with a first code.
+CREATE TABLE totp_secret (
+  api_user UUID NOT NULL,
+  secret BYTEA NOT NULL,
+  last_counter BIGINT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  confirmed_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (api_user),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+-- Logins waiting for the second factor of the user.
+CREATE TABLE mfa_challenge (
+  id UUID NOT NULL,
+  token_hash TEXT NOT NULL,
+  api_user UUID NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX mfa_challenge_token_hash_index ON mfa_challenge (token_hash);
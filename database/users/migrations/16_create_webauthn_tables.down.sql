@@ -0,0 +1,3 @@
+
+DROP TABLE webauthn_ceremony;
+DROP TABLE webauthn_credential;
@@ -0,0 +1,36 @@
+
+-- Public key credentials created by the authenticators of the users. The
+-- credential id is chosen by the authenticator and unique across users.
+CREATE TABLE webauthn_credential (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  credential_id BYTEA NOT NULL,
+  public_key BYTEA NOT NULL,
+  sign_count BIGINT NOT NULL,
+  transports TEXT[] NOT NULL,
+  aaguid UUID NOT NULL,
+  name TEXT NOT NULL,
+  backup_eligible BOOLEAN NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  last_used_at TIMESTAMP WITH TIME ZONE,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX webauthn_credential_credential_id_index ON webauthn_credential (credential_id);
+CREATE INDEX webauthn_credential_api_user_index ON webauthn_credential (api_user);
+
+-- Ceremonies waiting for the answer of the browser. The user is not known
+-- when logging in without a password.
+CREATE TABLE webauthn_ceremony (
+  id UUID NOT NULL,
+  challenge_hash TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  api_user UUID,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX webauthn_ceremony_challenge_hash_index ON webauthn_ceremony (challenge_hash);
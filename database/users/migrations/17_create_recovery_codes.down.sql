@@ -0,0 +1,4 @@
+
+ALTER TABLE api_key DROP COLUMN password_change_required;
+ALTER TABLE api_user DROP COLUMN password_change_required;
+DROP TABLE recovery_code;
@@ -0,0 +1,26 @@
+
+-- Single-use codes replacing the password of a user who lost it. Used codes
+-- are kept to record when and from where they were used. The codes are
+-- stored as a digest without pepper: they stay valid whatever the keys
+-- rotated since, and logins find them by digest.
+CREATE TABLE recovery_code (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  code_hash TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  user_agent TEXT,
+  ip_address TEXT,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE INDEX recovery_code_api_user_index ON recovery_code (api_user, code_hash);
+
+-- Set when the user logged in with a recovery code, until they choose a new
+-- password.
+ALTER TABLE api_user ADD COLUMN password_change_required BOOLEAN NOT NULL DEFAULT false;
+
+-- Set for the sessions opened while the user has to change their password:
+-- they can't be used for anything else.
+ALTER TABLE api_key ADD COLUMN password_change_required BOOLEAN NOT NULL DEFAULT false;
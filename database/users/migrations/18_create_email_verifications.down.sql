@@ -0,0 +1,3 @@
+
+DROP TABLE email_verification;
+ALTER TABLE api_user DROP COLUMN email_verified_at;
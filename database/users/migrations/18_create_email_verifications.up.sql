@@ -0,0 +1,20 @@
+
+-- Set once the user proved that they own their email, and cleared when
+-- they change it.
+ALTER TABLE api_user ADD COLUMN email_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Tokens sent to the users to verify their email. The email is kept to make
+-- sure that the token is not used for another address.
+CREATE TABLE email_verification (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  email TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX email_verification_token_hash_index ON email_verification (token_hash);
+CREATE INDEX email_verification_api_user_index ON email_verification (api_user);
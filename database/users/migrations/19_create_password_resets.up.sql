@@ -0,0 +1,16 @@
+
+-- Tokens sent to the users who forgot their password. Like the email
+-- verifications, they are bound to the address they were sent to.
+CREATE TABLE password_reset (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  email TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX password_reset_token_hash_index ON password_reset (token_hash);
+CREATE INDEX password_reset_api_user_index ON password_reset (api_user);
@@ -0,0 +1,19 @@
+
+-- Links and codes sent to the users logging in without their password.
+-- Like the password resets, they are bound to the address they were sent
+-- to.
+CREATE TABLE email_login (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  email TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  code_hash TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX email_login_token_hash_index ON email_login (token_hash);
+CREATE INDEX email_login_api_user_index ON email_login (api_user);
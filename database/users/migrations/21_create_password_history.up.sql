@@ -0,0 +1,13 @@
+
+-- Hashes of the previous passwords of the users, so that they are not
+-- reused when changing the password.
+CREATE TABLE password_history (
+  id UUID NOT NULL,
+  api_user UUID NOT NULL,
+  password TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE
+);
+
+CREATE INDEX password_history_api_user_index ON password_history (api_user, created_at);
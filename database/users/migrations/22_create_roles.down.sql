@@ -0,0 +1,5 @@
+
+DROP TABLE role_assignment;
+DROP TABLE role_permission;
+DROP TABLE role;
+DROP TABLE permission;
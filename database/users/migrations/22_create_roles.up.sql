@@ -0,0 +1,49 @@
+
+-- Permissions are opaque to this service: their names are returned by the
+-- authentication endpoint for the other services to check.
+CREATE TABLE permission (
+  id UUID NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE (name)
+);
+
+-- A role inherits the permissions of its parent, and so on up the chain.
+CREATE TABLE role (
+  id UUID NOT NULL,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL,
+  parent UUID,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  version INTEGER DEFAULT 0,
+  PRIMARY KEY (id),
+  UNIQUE (name),
+  FOREIGN KEY (parent) REFERENCES role(id) ON DELETE SET NULL
+);
+
+CREATE TRIGGER trigger_role_updated_at
+  BEFORE UPDATE OR INSERT ON role
+  FOR EACH ROW
+  EXECUTE FUNCTION update_updated_at();
+
+CREATE TABLE role_permission (
+  role UUID NOT NULL,
+  permission UUID NOT NULL,
+  PRIMARY KEY (role, permission),
+  FOREIGN KEY (role) REFERENCES role(id) ON DELETE CASCADE,
+  FOREIGN KEY (permission) REFERENCES permission(id) ON DELETE CASCADE
+);
+
+CREATE TABLE role_assignment (
+  api_user UUID NOT NULL,
+  role UUID NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (api_user, role),
+  FOREIGN KEY (api_user) REFERENCES api_user(id) ON DELETE CASCADE,
+  FOREIGN KEY (role) REFERENCES role(id) ON DELETE CASCADE
+);
+
+CREATE INDEX role_assignment_role_index ON role_assignment (role);
@@ -0,0 +1,2 @@
+-- The emails are kept in their normalized form: the original ones are not
+-- known anymore.
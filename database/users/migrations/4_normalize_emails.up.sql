@@ -0,0 +1,50 @@
+-- Emails are now normalized by the service before being stored. Existing
+-- rows are brought to the same form as far as SQL allows: surrounding
+-- spaces are removed and the domain is lowercased. The local part is kept
+-- as is, and so are the internationalized domains: running the service
+-- with the 'normalize-emails' command takes care of them.
+-- Existing accounts which would collide once normalized are listed and
+-- have to be merged or renamed manually before this migration can be
+-- applied.
+CREATE FUNCTION normalize_email(email TEXT) RETURNS TEXT AS $$
+  DECLARE
+    trimmed TEXT := btrim(email);
+    at INTEGER := length(trimmed) - strpos(reverse(trimmed), '@') + 1;
+  BEGIN
+    IF strpos(trimmed, '@') = 0 THEN
+      RETURN trimmed;
+    END IF;
+
+    RETURN left(trimmed, at) || lower(substr(trimmed, at + 1));
+  END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+DO $$
+  DECLARE
+    collision RECORD;
+    collisions INTEGER := 0;
+  BEGIN
+    FOR collision IN
+      SELECT
+        normalize_email(email) AS email,
+        string_agg(id::TEXT, ', ' ORDER BY created_at) AS users
+      FROM
+        api_user
+      GROUP BY
+        normalize_email(email)
+      HAVING
+        count(*) > 1
+    LOOP
+      RAISE WARNING 'email % is used by users %', collision.email, collision.users;
+      collisions := collisions + 1;
+    END LOOP;
+
+    IF collisions > 0 THEN
+      RAISE EXCEPTION '% email(s) are used by more than one user once normalized', collisions;
+    END IF;
+  END;
+$$;
+
+UPDATE api_user SET email = normalize_email(email) WHERE email <> normalize_email(email);
+
+DROP FUNCTION normalize_email(TEXT);
@@ -0,0 +1,12 @@
+
+CREATE TABLE login_throttle (
+  kind TEXT NOT NULL,
+  key TEXT NOT NULL,
+  failures INTEGER NOT NULL,
+  last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  blocked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  locked BOOLEAN NOT NULL DEFAULT false,
+  PRIMARY KEY (kind, key)
+);
+
+CREATE INDEX login_throttle_locked_index ON login_throttle (locked) WHERE locked;
@@ -0,0 +1,10 @@
+
+-- Digests can't be turned back into keys: the sessions are dropped.
+DELETE FROM api_key;
+
+DROP INDEX api_key_key_hash_index;
+
+ALTER TABLE api_key ALTER COLUMN key_hash TYPE UUID USING key_hash::UUID;
+ALTER TABLE api_key RENAME COLUMN key_hash TO key;
+
+CREATE INDEX api_key_key_index ON api_key (key);
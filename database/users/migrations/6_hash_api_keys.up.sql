@@ -0,0 +1,9 @@
+
+ALTER TABLE api_key RENAME COLUMN key TO key_hash;
+
+-- Existing keys are replaced by their SHA-256 digest. They won't match
+-- anymore if a pepper is configured: the users will have to log in again.
+ALTER TABLE api_key ALTER COLUMN key_hash TYPE TEXT USING encode(sha256(convert_to(key_hash::TEXT, 'UTF8')), 'hex');
+
+DROP INDEX api_key_key_index;
+CREATE UNIQUE INDEX api_key_key_hash_index ON api_key (key_hash);
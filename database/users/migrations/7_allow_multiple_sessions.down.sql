@@ -0,0 +1,11 @@
+ALTER TABLE api_key ALTER COLUMN created_at DROP NOT NULL;
+
+-- Only the most recent session of each user is kept.
+DELETE FROM api_key
+WHERE id NOT IN (
+  SELECT DISTINCT ON (api_user) id
+  FROM api_key
+  ORDER BY api_user, created_at DESC
+);
+
+ALTER TABLE api_key ADD CONSTRAINT api_key_api_user_key UNIQUE (api_user);
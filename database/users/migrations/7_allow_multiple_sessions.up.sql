@@ -0,0 +1,5 @@
+ALTER TABLE api_key DROP CONSTRAINT api_key_api_user_key;
+
+-- Sessions are evicted from the oldest one: the creation date is needed.
+UPDATE api_key SET created_at = current_timestamp WHERE created_at IS NULL;
+ALTER TABLE api_key ALTER COLUMN created_at SET NOT NULL;
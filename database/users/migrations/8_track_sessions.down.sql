@@ -0,0 +1,3 @@
+ALTER TABLE api_key DROP COLUMN last_used_at;
+ALTER TABLE api_key DROP COLUMN ip_address;
+ALTER TABLE api_key DROP COLUMN user_agent;
@@ -0,0 +1,3 @@
+ALTER TABLE api_key ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_key ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_key ADD COLUMN last_used_at TIMESTAMP WITH TIME ZONE;
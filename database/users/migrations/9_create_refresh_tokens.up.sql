@@ -0,0 +1,15 @@
+
+CREATE TABLE refresh_token (
+  id UUID NOT NULL,
+  token_hash TEXT NOT NULL,
+  api_key UUID NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (id),
+  -- Revoking a session revokes all the refresh tokens issued for it.
+  FOREIGN KEY (api_key) REFERENCES api_key(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX refresh_token_token_hash_index ON refresh_token (token_hash);
+CREATE INDEX refresh_token_api_key_index ON refresh_token (api_key);
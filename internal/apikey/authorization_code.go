@@ -0,0 +1,44 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// CodePrefix identifies the authorization codes returned to OAuth clients.
+const CodePrefix = "usc_live_"
+
+// AuthorizationCode is an authorization code which is known to be
+// well-formed. It uses the same format as the keys, with a different prefix.
+type AuthorizationCode struct {
+	value string
+}
+
+func GenerateAuthorizationCode() AuthorizationCode {
+	return AuthorizationCode{
+		value: generate(CodePrefix),
+	}
+}
+
+// ParseAuthorizationCode verifies that the input looks like an authorization
+// code, without checking whether it actually exists.
+func ParseAuthorizationCode(raw string) (AuthorizationCode, error) {
+	body, ok := strings.CutPrefix(raw, CodePrefix)
+	if !ok {
+		return AuthorizationCode{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(CodePrefix, body)
+	if err != nil {
+		return AuthorizationCode{}, err
+	}
+
+	return AuthorizationCode{
+		value: raw,
+	}, nil
+}
+
+func (c AuthorizationCode) String() string {
+	return c.value
+}
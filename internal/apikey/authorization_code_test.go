@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GenerateAuthorizationCode_ExpectCodeCanBeParsed(t *testing.T) {
+	code := GenerateAuthorizationCode()
+
+	actual, err := ParseAuthorizationCode(code.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, code, actual)
+	assert.True(t, strings.HasPrefix(code.String(), CodePrefix), "Actual code: %s", code)
+}
+
+func TestUnit_ParseAuthorizationCode_WhenCodeIsARefreshToken_ExpectError(t *testing.T) {
+	_, err := ParseAuthorizationCode(GenerateRefreshToken().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParseAuthorizationCode_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	swapped := CodePrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParseAuthorizationCode(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
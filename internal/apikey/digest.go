@@ -0,0 +1,21 @@
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Digest returns the value persisted in place of the key. When a pepper is
+// provided the digest is an HMAC keyed with it: this way a dump of the
+// database is not enough to check guesses offline.
+func Digest(key string, pepper string) string {
+	if pepper == "" {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+
+	mac := hmac.New(sha256.New, []byte(pepper))
+	mac.Write([]byte(key))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,28 @@
+package apikey
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Digest_WhenNoPepper_ExpectSha256(t *testing.T) {
+	actual := Digest("2da3e9ec-7299-473a-be0f-d722d870f51a", "")
+
+	// echo -n '2da3e9ec-7299-473a-be0f-d722d870f51a' | sha256sum
+	assert.Equal(t, "f621194eb67b14c357e69cdb8e894785eed50625d4cb36ab761e3955935d6f0e", actual)
+}
+
+func TestUnit_Digest_WhenPepperIsProvided_ExpectHmac(t *testing.T) {
+	actual := Digest("2da3e9ec-7299-473a-be0f-d722d870f51a", "my-pepper")
+
+	// echo -n '2da3e9ec-7299-473a-be0f-d722d870f51a' | openssl dgst -sha256 -hmac 'my-pepper'
+	assert.Equal(t, "93bb3f1f125236fe2b1137efb6248f4796d9fc6d9dce5c72a785529bf5f54db4", actual)
+}
+
+func TestUnit_Digest_WhenPepperChanges_ExpectDifferentDigest(t *testing.T) {
+	first := Digest("2da3e9ec-7299-473a-be0f-d722d870f51a", "my-pepper")
+	second := Digest("2da3e9ec-7299-473a-be0f-d722d870f51a", "my-other-pepper")
+
+	assert.NotEqual(t, first, second)
+}
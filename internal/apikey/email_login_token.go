@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// EmailLoginPrefix identifies the tokens of the links sent to log in without
+// a password.
+const EmailLoginPrefix = "usl_live_"
+
+// EmailLoginToken is a token which is known to be well-formed. It is sent by
+// email to let the user log in without their password and uses the same
+// format as the keys, with a different prefix.
+type EmailLoginToken struct {
+	value string
+}
+
+func GenerateEmailLoginToken() EmailLoginToken {
+	return EmailLoginToken{
+		value: generate(EmailLoginPrefix),
+	}
+}
+
+// ParseEmailLoginToken verifies that the input looks like an email login
+// token, without checking whether it actually exists.
+func ParseEmailLoginToken(raw string) (EmailLoginToken, error) {
+	body, ok := strings.CutPrefix(raw, EmailLoginPrefix)
+	if !ok {
+		return EmailLoginToken{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(EmailLoginPrefix, body)
+	if err != nil {
+		return EmailLoginToken{}, err
+	}
+
+	return EmailLoginToken{
+		value: raw,
+	}, nil
+}
+
+func (t EmailLoginToken) String() string {
+	return t.value
+}
@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GenerateEmailLoginToken_ExpectTokenCanBeParsed(t *testing.T) {
+	token := GenerateEmailLoginToken()
+
+	actual, err := ParseEmailLoginToken(token.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, token, actual)
+	assert.True(t, strings.HasPrefix(token.String(), EmailLoginPrefix), "Actual token: %s", token)
+}
+
+func TestUnit_ParseEmailLoginToken_WhenTokenIsAPasswordResetToken_ExpectError(t *testing.T) {
+	_, err := ParseEmailLoginToken(GeneratePasswordResetToken().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParseEmailLoginToken_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	swapped := EmailLoginPrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParseEmailLoginToken(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
@@ -0,0 +1,10 @@
+package apikey
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidFormat   errors.ErrorCode = 1300
+	InvalidChecksum errors.ErrorCode = 1301
+)
@@ -0,0 +1,176 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"hash/crc32"
+	"math/big"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Prefix makes the keys easy to recognize, both for humans and for secret
+// scanners.
+const Prefix = "usk_live_"
+
+// 32 bytes of randomness need at most 43 characters in base 62 and the CRC32
+// checksum at most 6.
+const (
+	entropyLength  = 32
+	payloadLength  = 43
+	checksumLength = 6
+)
+
+// Matches the digits used by big.Int.Text.
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Signed tokens are JSON objects encoded in base64: their header always
+// starts with the same characters.
+const (
+	tokenHeaderPrefix = "eyJ"
+	tokenSegments     = 3
+	maxTokenLength    = 4096
+)
+
+// Key is an API key which is known to be well-formed. Keys can either use the
+// current format (see Generate) or be plain UUIDs as issued by previous
+// versions of the service: the latter are flagged as legacy. Signed tokens
+// are accepted as well and flagged as such: their signature is not verified.
+type Key struct {
+	value  string
+	legacy bool
+	token  bool
+}
+
+// Generate creates a new key in the current format: the prefix followed by
+// the random payload and a checksum of both, all encoded in base 62 so that
+// the key can be selected with a double click.
+func Generate() Key {
+	return Key{
+		value: generate(Prefix),
+	}
+}
+
+// Parse verifies that the input looks like a key, without checking whether
+// it actually exists.
+func Parse(raw string) (Key, error) {
+	if body, ok := strings.CutPrefix(raw, Prefix); ok {
+		return parseCurrentFormat(raw, body)
+	}
+	if looksLikeToken(raw) {
+		return Key{
+			value: raw,
+			token: true,
+		}, nil
+	}
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return Key{}, errors.WrapCode(err, InvalidFormat)
+	}
+
+	return Key{
+		value:  id.String(),
+		legacy: true,
+	}, nil
+}
+
+func (k Key) String() string {
+	return k.value
+}
+
+func (k Key) Legacy() bool {
+	return k.legacy
+}
+
+func (k Key) Token() bool {
+	return k.token
+}
+
+func parseCurrentFormat(raw string, body string) (Key, error) {
+	err := verify(Prefix, body)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		value: raw,
+	}, nil
+}
+
+func looksLikeToken(raw string) bool {
+	if len(raw) > maxTokenLength || !strings.HasPrefix(raw, tokenHeaderPrefix) {
+		return false
+	}
+
+	segments := strings.Split(raw, ".")
+	if len(segments) != tokenSegments {
+		return false
+	}
+
+	for _, segment := range segments {
+		if segment == "" || !isBase64Url(segment) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func generate(prefix string) string {
+	entropy := make([]byte, entropyLength)
+	// https://pkg.go.dev/crypto/rand#Read
+	rand.Read(entropy)
+
+	payload := encodeBase62(new(big.Int).SetBytes(entropy), payloadLength)
+	return prefix + payload + checksum(prefix, payload)
+}
+
+// verify checks the part of a token following its prefix.
+func verify(prefix string, body string) error {
+	if len(body) != payloadLength+checksumLength || !isBase62(body) {
+		return errors.NewCode(InvalidFormat)
+	}
+
+	payload, sum := body[:payloadLength], body[payloadLength:]
+	if checksum(prefix, payload) != sum {
+		return errors.NewCode(InvalidChecksum)
+	}
+
+	return nil
+}
+
+func checksum(prefix string, payload string) string {
+	sum := crc32.ChecksumIEEE([]byte(prefix + payload))
+	return encodeBase62(new(big.Int).SetUint64(uint64(sum)), checksumLength)
+}
+
+func encodeBase62(value *big.Int, length int) string {
+	out := []byte(value.Text(62))
+	if len(out) >= length {
+		return string(out)
+	}
+
+	return strings.Repeat(string(base62Alphabet[0]), length-len(out)) + string(out)
+}
+
+func isBase62(value string) bool {
+	for _, c := range value {
+		if !strings.ContainsRune(base62Alphabet, c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isBase64Url(value string) bool {
+	for _, c := range value {
+		if !strings.ContainsRune(base62Alphabet, c) && c != '-' && c != '_' {
+			return false
+		}
+	}
+
+	return true
+}
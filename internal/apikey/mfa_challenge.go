@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// ChallengePrefix identifies the tokens of the logins waiting for a second
+// factor.
+const ChallengePrefix = "usm_live_"
+
+// MfaChallenge is a challenge token which is known to be well-formed. It is
+// returned when a login waits for a second factor and uses the same format
+// as the keys, with a different prefix.
+type MfaChallenge struct {
+	value string
+}
+
+func GenerateMfaChallenge() MfaChallenge {
+	return MfaChallenge{
+		value: generate(ChallengePrefix),
+	}
+}
+
+// ParseMfaChallenge verifies that the input looks like a challenge token,
+// without checking whether it actually exists.
+func ParseMfaChallenge(raw string) (MfaChallenge, error) {
+	body, ok := strings.CutPrefix(raw, ChallengePrefix)
+	if !ok {
+		return MfaChallenge{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(ChallengePrefix, body)
+	if err != nil {
+		return MfaChallenge{}, err
+	}
+
+	return MfaChallenge{
+		value: raw,
+	}, nil
+}
+
+func (c MfaChallenge) String() string {
+	return c.value
+}
@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GenerateMfaChallenge_ExpectChallengeCanBeParsed(t *testing.T) {
+	challenge := GenerateMfaChallenge()
+
+	actual, err := ParseMfaChallenge(challenge.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, challenge, actual)
+	assert.True(t, strings.HasPrefix(challenge.String(), ChallengePrefix), "Actual challenge: %s", challenge)
+}
+
+func TestUnit_ParseMfaChallenge_WhenChallengeIsAnAuthorizationCode_ExpectError(t *testing.T) {
+	_, err := ParseMfaChallenge(GenerateAuthorizationCode().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParseMfaChallenge_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	swapped := ChallengePrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParseMfaChallenge(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
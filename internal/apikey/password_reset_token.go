@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// PasswordResetPrefix identifies the tokens sent to the users who forgot
+// their password.
+const PasswordResetPrefix = "usp_live_"
+
+// PasswordResetToken is a token which is known to be well-formed. It is sent
+// by email to let the user choose a new password and uses the same format as
+// the keys, with a different prefix.
+type PasswordResetToken struct {
+	value string
+}
+
+func GeneratePasswordResetToken() PasswordResetToken {
+	return PasswordResetToken{
+		value: generate(PasswordResetPrefix),
+	}
+}
+
+// ParsePasswordResetToken verifies that the input looks like a password
+// reset token, without checking whether it actually exists.
+func ParsePasswordResetToken(raw string) (PasswordResetToken, error) {
+	body, ok := strings.CutPrefix(raw, PasswordResetPrefix)
+	if !ok {
+		return PasswordResetToken{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(PasswordResetPrefix, body)
+	if err != nil {
+		return PasswordResetToken{}, err
+	}
+
+	return PasswordResetToken{
+		value: raw,
+	}, nil
+}
+
+func (t PasswordResetToken) String() string {
+	return t.value
+}
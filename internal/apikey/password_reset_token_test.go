@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GeneratePasswordResetToken_ExpectTokenCanBeParsed(t *testing.T) {
+	token := GeneratePasswordResetToken()
+
+	actual, err := ParsePasswordResetToken(token.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, token, actual)
+	assert.True(t, strings.HasPrefix(token.String(), PasswordResetPrefix), "Actual token: %s", token)
+}
+
+func TestUnit_ParsePasswordResetToken_WhenTokenIsAVerificationToken_ExpectError(t *testing.T) {
+	_, err := ParsePasswordResetToken(GenerateVerificationToken().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParsePasswordResetToken_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	swapped := PasswordResetPrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParsePasswordResetToken(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
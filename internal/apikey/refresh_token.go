@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// RefreshPrefix differs from the prefix of the keys so that a refresh token
+// can't be used in place of a key and vice versa.
+const RefreshPrefix = "usr_live_"
+
+// RefreshToken is a refresh token which is known to be well-formed. It uses
+// the same format as the keys, with a different prefix.
+type RefreshToken struct {
+	value string
+}
+
+func GenerateRefreshToken() RefreshToken {
+	return RefreshToken{
+		value: generate(RefreshPrefix),
+	}
+}
+
+// ParseRefreshToken verifies that the input looks like a refresh token,
+// without checking whether it actually exists.
+func ParseRefreshToken(raw string) (RefreshToken, error) {
+	body, ok := strings.CutPrefix(raw, RefreshPrefix)
+	if !ok {
+		return RefreshToken{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(RefreshPrefix, body)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	return RefreshToken{
+		value: raw,
+	}, nil
+}
+
+func (t RefreshToken) String() string {
+	return t.value
+}
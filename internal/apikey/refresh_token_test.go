@@ -0,0 +1,48 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GenerateRefreshToken_ExpectPrefixedToken(t *testing.T) {
+	token := GenerateRefreshToken()
+
+	assert.True(t, strings.HasPrefix(token.String(), RefreshPrefix), "Actual token: %s", token)
+	assert.Equal(t, len(RefreshPrefix)+payloadLength+checksumLength, len(token.String()))
+}
+
+func TestUnit_GenerateRefreshToken_ExpectTokenCanBeParsed(t *testing.T) {
+	token := GenerateRefreshToken()
+
+	actual, err := ParseRefreshToken(token.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, token, actual)
+}
+
+func TestUnit_ParseRefreshToken_WhenTokenIsAKey_ExpectError(t *testing.T) {
+	_, err := ParseRefreshToken(sampleKey)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParseRefreshToken_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	// The checksum covers the prefix: a key can't be turned into a token.
+	swapped := RefreshPrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParseRefreshToken(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
+
+func TestUnit_Parse_WhenKeyIsARefreshToken_ExpectError(t *testing.T) {
+	token := GenerateRefreshToken()
+
+	_, err := Parse(token.String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
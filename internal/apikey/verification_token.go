@@ -0,0 +1,46 @@
+package apikey
+
+import (
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// VerificationPrefix identifies the tokens sent to the users to verify
+// their email.
+const VerificationPrefix = "usv_live_"
+
+// VerificationToken is a token which is known to be well-formed. It is sent
+// by email to prove that the user owns the address and uses the same
+// format as the keys, with a different prefix.
+type VerificationToken struct {
+	value string
+}
+
+func GenerateVerificationToken() VerificationToken {
+	return VerificationToken{
+		value: generate(VerificationPrefix),
+	}
+}
+
+// ParseVerificationToken verifies that the input looks like a verification
+// token, without checking whether it actually exists.
+func ParseVerificationToken(raw string) (VerificationToken, error) {
+	body, ok := strings.CutPrefix(raw, VerificationPrefix)
+	if !ok {
+		return VerificationToken{}, errors.NewCode(InvalidFormat)
+	}
+
+	err := verify(VerificationPrefix, body)
+	if err != nil {
+		return VerificationToken{}, err
+	}
+
+	return VerificationToken{
+		value: raw,
+	}, nil
+}
+
+func (t VerificationToken) String() string {
+	return t.value
+}
@@ -0,0 +1,33 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_GenerateVerificationToken_ExpectTokenCanBeParsed(t *testing.T) {
+	token := GenerateVerificationToken()
+
+	actual, err := ParseVerificationToken(token.String())
+
+	assert.Nil(t, err)
+	assert.Equal(t, token, actual)
+	assert.True(t, strings.HasPrefix(token.String(), VerificationPrefix), "Actual token: %s", token)
+}
+
+func TestUnit_ParseVerificationToken_WhenTokenIsAChallenge_ExpectError(t *testing.T) {
+	_, err := ParseVerificationToken(GenerateMfaChallenge().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFormat), "Actual err: %v", err)
+}
+
+func TestUnit_ParseVerificationToken_WhenPrefixIsSwapped_ExpectError(t *testing.T) {
+	swapped := VerificationPrefix + strings.TrimPrefix(sampleKey, Prefix)
+
+	_, err := ParseVerificationToken(swapped)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidChecksum), "Actual err: %v", err)
+}
@@ -0,0 +1,34 @@
+package clientip
+
+import (
+	"net"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// Config lists the reverse proxies trusted to report the address of the
+// clients in the X-Forwarded-For header. Without any, the client IP is the
+// address of the connection: the header could otherwise be set by anyone.
+type Config struct {
+	// TrustedProxies are the networks of the proxies in CIDR notation, such
+	// as 10.0.0.0/8.
+	TrustedProxies []string
+}
+
+func (c Config) Validate() error {
+	_, err := c.trustedRanges()
+	return err
+}
+
+func (c Config) trustedRanges() ([]*net.IPNet, error) {
+	var out []*net.IPNet
+	for _, proxy := range c.TrustedProxies {
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.NewCodeWithDetails(InvalidConfiguration, "invalid trusted proxy: "+proxy)
+		}
+		out = append(out, network)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package clientip
+
+import (
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Config_Validate(t *testing.T) {
+	config := Config{
+		TrustedProxies: []string{"10.0.0.0/8", "2001:db8::/32"},
+	}
+
+	err := config.Validate()
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Config_Validate_WhenProxyIsNotANetwork_ExpectError(t *testing.T) {
+	config := Config{
+		TrustedProxies: []string{"10.0.0.1"},
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
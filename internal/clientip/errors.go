@@ -0,0 +1,9 @@
+package clientip
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration errors.ErrorCode = 2100
+)
@@ -0,0 +1,30 @@
+package clientip
+
+import (
+	"github.com/labstack/echo/v5"
+)
+
+// NewExtractor returns how to find the address of the clients. Only the
+// configured proxies are trusted, not even the loopback or the private
+// networks: the client IP is the nearest address of the X-Forwarded-For
+// header which was not added by one of them.
+func NewExtractor(config Config) (echo.IPExtractor, error) {
+	ranges, err := config.trustedRanges()
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, network := range ranges {
+		options = append(options, echo.TrustIPRange(network))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
@@ -0,0 +1,61 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_NewExtractor_WhenNoProxyIsTrusted_ExpectConnectionAddress(t *testing.T) {
+	extractor, err := NewExtractor(Config{})
+	require.Nil(t, err)
+
+	actual := extractor(newTestRequest("10.0.0.2:41234", "203.0.113.7"))
+
+	assert.Equal(t, "10.0.0.2", actual)
+}
+
+func TestUnit_NewExtractor_WhenProxyIsTrusted_ExpectForwardedAddress(t *testing.T) {
+	extractor, err := NewExtractor(Config{TrustedProxies: []string{"172.16.0.0/12"}})
+	require.Nil(t, err)
+
+	actual := extractor(newTestRequest("172.18.0.3:41234", "203.0.113.7, 172.18.0.4"))
+
+	assert.Equal(t, "203.0.113.7", actual)
+}
+
+func TestUnit_NewExtractor_WhenProxyIsNotTrusted_ExpectConnectionAddress(t *testing.T) {
+	extractor, err := NewExtractor(Config{TrustedProxies: []string{"172.16.0.0/12"}})
+	require.Nil(t, err)
+
+	actual := extractor(newTestRequest("198.51.100.4:41234", "203.0.113.7"))
+
+	assert.Equal(t, "198.51.100.4", actual)
+}
+
+func TestUnit_NewExtractor_WhenForwardedAddressIsPrivate_ExpectItIsNotTrusted(t *testing.T) {
+	extractor, err := NewExtractor(Config{TrustedProxies: []string{"172.16.0.0/12"}})
+	require.Nil(t, err)
+
+	actual := extractor(newTestRequest("172.18.0.3:41234", "203.0.113.7, 10.0.0.2"))
+
+	assert.Equal(t, "10.0.0.2", actual)
+}
+
+func TestUnit_NewExtractor_WhenConfigIsInvalid_ExpectError(t *testing.T) {
+	_, err := NewExtractor(Config{TrustedProxies: []string{"not-a-network"}})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func newTestRequest(remoteAddr string, forwardedFor string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+	return req
+}
@@ -2,16 +2,26 @@ package controller
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
 	"github.com/Knoblauchpilze/user-service/internal/service"
-	"github.com/google/uuid"
 	"github.com/labstack/echo/v5"
 )
 
 const apiKeyHeaderKey = "X-Api-Key"
 
+// The authorization is also returned as headers: reverse proxies such as
+// traefik can copy them to the request forwarded to the services.
+const (
+	userIdHeaderKey          = "X-User-Id"
+	userRolesHeaderKey       = "X-User-Roles"
+	userPermissionsHeaderKey = "X-User-Permissions"
+	authorizationListSep     = ","
+)
+
 func AuthEndpoints(service service.AuthService) rest.Routes {
 	var out rest.Routes
 
@@ -19,19 +29,27 @@ func AuthEndpoints(service service.AuthService) rest.Routes {
 	auth := rest.NewRoute(http.MethodGet, "/auth", authHandler)
 	out = append(out, auth)
 
+	// The response format is imposed by RFC 7662: it is not wrapped.
+	introspectHandler := createServiceAwareHttpHandler(introspectKey, service)
+	introspect := rest.NewRawRoute(http.MethodPost, "/oauth/introspect", introspectHandler)
+	out = append(out, introspect)
+
 	return out
 }
 
 // authUser godoc
 //
 // @Summary Authenticate API key
-// @Description Validates the API key provided in the request header.
+// @Description Validates the API key or the signed token provided in the request header and returns the roles and permissions of the user. They include the roles inherited through the role hierarchy. The same information is returned in the X-User-Id, X-User-Roles and X-User-Permissions headers (as comma-separated lists) so that a reverse proxy can forward it.
 // @Tags auth
 // @Produce json
 // @Param X-Api-Key header string true "API key"
-// @Success 204
+// @Success 200 {object} rest.ResponseEnvelope[communication.AuthorizationDtoResponse]
+// @Header 200 {string} X-User-Id "User ID"
+// @Header 200 {string} X-User-Roles "Comma-separated roles of the user"
+// @Header 200 {string} X-User-Permissions "Comma-separated permissions of the user"
 // @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid API key"
-// @Failure 403 {object} rest.ResponseEnvelope[string] "User is not authenticated"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "User is not authenticated, or has to change their password first"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
 // @Router /users/auth [get]
 func authUser(c *echo.Context, s service.AuthService) error {
@@ -40,7 +58,7 @@ func authUser(c *echo.Context, s service.AuthService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid API key")
 	}
 
-	_, err := s.Authenticate(c.Request().Context(), apiKey)
+	out, err := s.Authenticate(c.Request().Context(), apiKey)
 	if err != nil {
 		if isUserNotAuthenticated(err) {
 			return c.JSON(http.StatusForbidden, err)
@@ -49,26 +67,71 @@ func authUser(c *echo.Context, s service.AuthService) error {
 		return c.JSON(http.StatusInternalServerError, err)
 	}
 
-	return c.NoContent(http.StatusNoContent)
+	header := c.Response().Header()
+	header.Set(userIdHeaderKey, out.User.String())
+	header.Set(userRolesHeaderKey, strings.Join(out.Roles, authorizationListSep))
+	header.Set(userPermissionsHeaderKey, strings.Join(out.Permissions, authorizationListSep))
+	return c.JSON(http.StatusOK, out)
+}
+
+// introspectKey godoc
+//
+// @Summary Introspect API key
+// @Description Describes an API key or a signed token issued by the service, as defined by RFC 7662. Callers authenticate with their client credentials, either with HTTP Basic authentication or in the form.
+// @Tags auth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param token formData string true "API key or signed token"
+// @Param token_type_hint formData string false "Ignored: all the keys are looked up the same way"
+// @Param client_id formData string false "Client identifier, when not using HTTP Basic authentication"
+// @Param client_secret formData string false "Client secret, when not using HTTP Basic authentication"
+// @Success 200 {object} communication.IntrospectionDtoResponse
+// @Failure 400 {object} controller.oauthError "Missing token"
+// @Failure 401 {object} controller.oauthError "Client is not authenticated"
+// @Failure 500
+// @Router /users/oauth/introspect [post]
+func introspectKey(c *echo.Context, s service.AuthService) error {
+	key := c.FormValue("token")
+	if key == "" {
+		return c.JSON(http.StatusBadRequest, oauthError{Error: invalidRequest})
+	}
+
+	out, err := s.Introspect(c.Request().Context(), clientCredentials(c), key)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.ClientNotAuthenticated) {
+			c.Response().Header().Set("WWW-Authenticate", `Basic realm="user-service"`)
+			return c.JSON(http.StatusUnauthorized, oauthError{Error: invalidClient})
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc7662#section-4
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, out)
 }
 
-func tryGetApiKeyHeader(req *http.Request) (uuid.UUID, bool) {
+func tryGetApiKeyHeader(req *http.Request) (apikey.Key, bool) {
 	apiKeys, ok := req.Header[apiKeyHeaderKey]
 	if !ok {
-		return uuid.UUID{}, false
+		return apikey.Key{}, false
 	}
 	if len(apiKeys) != 1 {
-		return uuid.UUID{}, false
+		return apikey.Key{}, false
 	}
 
-	apiKey, err := uuid.Parse(apiKeys[0])
+	// Malformed keys can't exist: no need to hit the database for them.
+	apiKey, err := apikey.Parse(apiKeys[0])
 	if err != nil {
-		return uuid.UUID{}, false
+		return apikey.Key{}, false
 	}
 
 	return apiKey, true
 }
 
 func isUserNotAuthenticated(err error) bool {
-	return errors.IsErrorWithCode(err, service.UserNotAuthenticated) || errors.IsErrorWithCode(err, service.AuthenticationExpired)
+	return errors.IsErrorWithCode(err, service.UserNotAuthenticated) ||
+		errors.IsErrorWithCode(err, service.AuthenticationExpired) ||
+		errors.IsErrorWithCode(err, service.LegacyApiKeyRejected) ||
+		errors.IsErrorWithCode(err, service.PasswordChangeRequired)
 }
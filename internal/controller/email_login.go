@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/labstack/echo/v5"
+)
+
+func EmailLoginEndpoints(service service.EmailLoginService) rest.Routes {
+	var out rest.Routes
+
+	requestHandler := createServiceAwareHttpHandler(requestEmailLogin, service)
+	request := rest.NewRoute(http.MethodPost, "/sessions/email/request", requestHandler)
+	out = append(out, request)
+
+	loginHandler := createServiceAwareHttpHandler(loginByEmailCode, service)
+	login := rest.NewRoute(http.MethodPost, "/sessions/email", loginHandler)
+	out = append(out, login)
+
+	return out
+}
+
+// requestEmailLogin godoc
+//
+// @Summary Request a login link or code
+// @Description Sends a link and a one-time code to log in without a password to the user owning the email. The answer is the same whether such a user exists or not. The number of requests for the same email is limited. Only available when email login is enabled.
+// @Tags sessions
+// @Accept json
+// @Param request body communication.EmailLoginDtoRequest true "Email of the user"
+// @Success 202
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many requests for the email"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/email/request [post]
+func requestEmailLogin(c *echo.Context, s service.EmailLoginService) error {
+	var emailLoginDtoRequest communication.EmailLoginDtoRequest
+	err := c.Bind(&emailLoginDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	err = s.Request(c.Request().Context(), emailLoginDtoRequest.Email)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.TooManyRequests) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many requests")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// loginByEmailCode godoc
+//
+// @Summary Create session from a login link or code
+// @Description Exchanges the token of a login link, or the email and the one-time code sent to it, for an API key. Both can only be used once and expire quickly; the code can't be used anymore after too many wrong attempts. Following the link or typing the code in verifies the email. Users who enabled two-factor authentication get a challenge instead, to exchange for the API key at /sessions/mfa. Failed attempts are throttled like the ones with a password.
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param credentials body communication.EmailLoginCredentialsDtoRequest true "Token, or email and code"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Success 202 {object} rest.ResponseEnvelope[communication.MfaChallengeDtoResponse] "A second factor is required"
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid, expired or already used link or code"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many login attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/email [post]
+func loginByEmailCode(c *echo.Context, s service.EmailLoginService) error {
+	var credentials communication.EmailLoginCredentialsDtoRequest
+	err := c.Bind(&credentials)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	var out communication.LoginDtoResponse
+	switch {
+	case credentials.Token != "":
+		token, parseErr := apikey.ParseEmailLoginToken(credentials.Token)
+		if parseErr != nil {
+			return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+		}
+		out, err = s.LoginWithToken(c.Request().Context(), token, client)
+	case credentials.Email != "" && credentials.Code != "":
+		out, err = s.LoginWithCode(c.Request().Context(), credentials.Email, credentials.Code, client)
+	default:
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidCredentials) {
+			return c.JSON(http.StatusUnauthorized, "Invalid credentials")
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many login attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusConflict, "Too many sessions")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	if out.Challenge != nil {
+		return c.JSON(http.StatusAccepted, out.Challenge)
+	}
+	return c.JSON(http.StatusCreated, out.Session)
+}
@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockEmailLoginService struct {
+	out communication.LoginDtoResponse
+	err error
+
+	email string
+	token apikey.EmailLoginToken
+	code  string
+}
+
+func TestUnit_EmailLoginController_RequestEmailLogin(t *testing.T) {
+	req := newTestEmailLoginRequest(t, communication.EmailLoginDtoRequest{Email: "user@example.com"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockEmailLoginService{}
+
+	err := requestEmailLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, "user@example.com", m.email)
+}
+
+func TestUnit_EmailLoginController_RequestEmailLogin_WhenBodyIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not-an-email-login-request"))
+	req.Header.Set("Content-Type", "application/json")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockEmailLoginService{}
+
+	err := requestEmailLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid request syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_EmailLoginController_RequestEmailLogin_WhenTooManyRequests_ExpectTooManyRequests(t *testing.T) {
+	req := newTestEmailLoginRequest(t, communication.EmailLoginDtoRequest{Email: "user@example.com"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockEmailLoginService{
+		err: errors.WrapCode(service.NewRetryAfterError(1*time.Hour), service.TooManyRequests),
+	}
+
+	err := requestEmailLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "3600", rw.Header().Get("Retry-After"))
+	assert.Equal(t, "\"Too many requests\"\n", rw.Body.String())
+}
+
+func TestUnit_EmailLoginController_LoginByEmailCode_WithToken(t *testing.T) {
+	token := apikey.GenerateEmailLoginToken()
+	req := newTestEmailLoginRequest(t, communication.EmailLoginCredentialsDtoRequest{Token: token.String()})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockEmailLoginService{
+		out: communication.LoginDtoResponse{
+			Session: &communication.ApiKeyDtoResponse{
+				User:       uuid.New(),
+				Key:        apikey.Generate().String(),
+				ValidUntil: time.Now().Add(time.Hour),
+			},
+		},
+	}
+
+	err := loginByEmailCode(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, token, m.token)
+	var actual communication.ApiKeyDtoResponse
+	err = json.Unmarshal(rw.Body.Bytes(), &actual)
+	require.Nil(t, err)
+	assert.Equal(t, m.out.Session.Key, actual.Key)
+}
+
+func TestUnit_EmailLoginController_LoginByEmailCode_WithCode(t *testing.T) {
+	req := newTestEmailLoginRequest(t, communication.EmailLoginCredentialsDtoRequest{Email: "user@example.com", Code: "492817"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockEmailLoginService{
+		out: communication.LoginDtoResponse{
+			Challenge: &communication.MfaChallengeDtoResponse{
+				Challenge: "my-challenge",
+				Methods:   []string{service.TotpMethod},
+			},
+		},
+	}
+
+	err := loginByEmailCode(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, "user@example.com", m.email)
+	assert.Equal(t, "492817", m.code)
+}
+
+func TestUnit_EmailLoginController_LoginByEmailCode_WhenRequestIsInvalid_ExpectBadRequest(t *testing.T) {
+	type testCase struct {
+		credentials communication.EmailLoginCredentialsDtoRequest
+	}
+
+	testCases := map[string]testCase{
+		"empty": {
+			credentials: communication.EmailLoginCredentialsDtoRequest{},
+		},
+		"malformedToken": {
+			credentials: communication.EmailLoginCredentialsDtoRequest{Token: apikey.GeneratePasswordResetToken().String()},
+		},
+		"missingCode": {
+			credentials: communication.EmailLoginCredentialsDtoRequest{Email: "user@example.com"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestEmailLoginRequest(t, tc.credentials)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockEmailLoginService{}
+
+			err := loginByEmailCode(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, http.StatusBadRequest, rw.Code)
+			assert.Equal(t, "\"Invalid request syntax\"\n", rw.Body.String())
+		})
+	}
+}
+
+func TestUnit_EmailLoginController_LoginByEmailCode_WhenLoginFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidCredentials": {
+			err:            errors.NewCode(service.InvalidCredentials),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"accountLocked": {
+			err:            errors.NewCode(service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestEmailLoginRequest(t, communication.EmailLoginCredentialsDtoRequest{Email: "user@example.com", Code: "492817"})
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockEmailLoginService{
+				err: tc.err,
+			}
+
+			err := loginByEmailCode(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func newTestEmailLoginRequest(t *testing.T, requestDto any) *http.Request {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (m *mockEmailLoginService) Request(ctx context.Context, rawEmail string) error {
+	m.email = rawEmail
+	return m.err
+}
+
+func (m *mockEmailLoginService) LoginWithToken(ctx context.Context, token apikey.EmailLoginToken, client service.ClientInfo) (communication.LoginDtoResponse, error) {
+	m.token = token
+	return m.out, m.err
+}
+
+func (m *mockEmailLoginService) LoginWithCode(ctx context.Context, rawEmail string, code string, client service.ClientInfo) (communication.LoginDtoResponse, error) {
+	m.email = rawEmail
+	m.code = code
+	return m.out, m.err
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/labstack/echo/v5"
+)
+
+// federatedLoginCookie keeps the state of the login in the browser of the
+// user while they sign in at the provider.
+const federatedLoginCookie = "federated_login_state"
+
+func FederationEndpoints(service service.FederationService) rest.Routes {
+	var out rest.Routes
+
+	// The redirection can't be wrapped in an envelope.
+	startHandler := createServiceAwareHttpHandler(startFederatedLogin, service)
+	start := rest.NewRawRoute(http.MethodGet, "/login/:provider", startHandler)
+	out = append(out, start)
+
+	completeHandler := createServiceAwareHttpHandler(completeFederatedLogin, service)
+	complete := rest.NewRoute(http.MethodGet, "/login/:provider/callback", completeHandler)
+	out = append(out, complete)
+
+	return out
+}
+
+// startFederatedLogin godoc
+//
+// @Summary Sign in with an identity provider
+// @Description Redirects the user to the identity provider to sign in. The provider sends them back to the callback, which must be reached with the cookie set by this endpoint.
+// @Tags sessions
+// @Param provider path string true "Name of the identity provider"
+// @Success 303 "Redirection to the identity provider"
+// @Failure 404 "No such identity provider"
+// @Failure 502 "Identity provider unavailable"
+// @Failure 500 "Internal server error"
+// @Router /users/login/{provider} [get]
+func startFederatedLogin(c *echo.Context, s service.FederationService) error {
+	redirect, state, err := s.Start(c.Request().Context(), c.Param("provider"))
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.UnknownIdentityProvider) {
+			return c.JSON(http.StatusNotFound, "No such identity provider")
+		}
+		if isIdentityProviderError(err) {
+			return c.JSON(http.StatusBadGateway, "Identity provider unavailable")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// The provider redirects the user to the callback with a top-level GET:
+	// the cookie has to be sent along, hence the lax policy.
+	c.SetCookie(&http.Cookie{
+		Name:     federatedLoginCookie,
+		Value:    state,
+		Path:     c.Request().URL.Path,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// completeFederatedLogin godoc
+//
+// @Summary Complete sign in with an identity provider
+// @Description Called by the identity provider when the user signed in. Opens a session for the user linked to their identity at the provider, or returns a challenge to answer at /sessions/mfa when they enabled two-factor authentication. Users seen for the first time are linked to the account with the same verified email or get a new account if the provider allows it.
+// @Tags sessions
+// @Produce json
+// @Param provider path string true "Name of the identity provider"
+// @Param code query string false "Authorization code issued by the provider"
+// @Param state query string true "State of the login"
+// @Param error query string false "Set by the provider when the user did not sign in"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Success 202 {object} rest.ResponseEnvelope[communication.MfaChallengeDtoResponse] "A second factor is required"
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid or expired login"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Email not verified or no account for this identity"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such identity provider"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 502 {object} rest.ResponseEnvelope[string] "Identity provider error"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/login/{provider}/callback [get]
+func completeFederatedLogin(c *echo.Context, s service.FederationService) error {
+	var callback communication.FederatedCallbackDtoRequest
+	err := c.Bind(&callback)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid callback syntax")
+	}
+
+	var browserState string
+	if cookie, err := c.Cookie(federatedLoginCookie); err == nil {
+		browserState = cookie.Value
+	}
+	// The login can only be completed once, whatever the outcome.
+	c.SetCookie(&http.Cookie{
+		Name:     federatedLoginCookie,
+		Path:     strings.TrimSuffix(c.Request().URL.Path, "/callback"),
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.Complete(c.Request().Context(), c.Param("provider"), callback, browserState, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.UnknownIdentityProvider) {
+			return c.JSON(http.StatusNotFound, "No such identity provider")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidFederatedLogin) {
+			return c.JSON(http.StatusBadRequest, "Invalid or expired login")
+		}
+		if errors.IsErrorWithCode(err, service.UnverifiedFederatedEmail) {
+			return c.JSON(http.StatusForbidden, "Email not verified by the identity provider")
+		}
+		if errors.IsErrorWithCode(err, service.FederatedSignUpDisabled) {
+			return c.JSON(http.StatusForbidden, "No account for this identity")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusConflict, "Too many sessions")
+		}
+		if isIdentityProviderError(err) || errors.IsErrorWithCode(err, service.InvalidEmail) {
+			return c.JSON(http.StatusBadGateway, "Identity provider error")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	if out.Challenge != nil {
+		return c.JSON(http.StatusAccepted, out.Challenge)
+	}
+	return c.JSON(http.StatusCreated, out.Session)
+}
+
+func isIdentityProviderError(err error) bool {
+	return errors.IsErrorWithCode(err, federation.ProviderUnavailable) ||
+		errors.IsErrorWithCode(err, federation.CodeExchangeFailed) ||
+		errors.IsErrorWithCode(err, federation.InvalidIdToken) ||
+		errors.IsErrorWithCode(err, federation.MissingSubject)
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockFederationService struct {
+	err error
+
+	provider     string
+	redirect     string
+	state        string
+	callback     communication.FederatedCallbackDtoRequest
+	browserState string
+	login        communication.LoginDtoResponse
+}
+
+func TestUnit_FederationController_StartFederatedLogin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "provider", Value: "google"}})
+	m := &mockFederationService{
+		redirect: "https://accounts.example.com/authorize?state=my-state",
+		state:    "my-state",
+	}
+
+	err := startFederatedLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusSeeOther, rw.Code)
+	assert.Equal(t, m.redirect, rw.Header().Get("Location"))
+	assert.Equal(t, "google", m.provider)
+	cookies := rw.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, federatedLoginCookie, cookies[0].Name)
+	assert.Equal(t, "my-state", cookies[0].Value)
+	assert.Equal(t, "/v1/users/login/google", cookies[0].Path)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+}
+
+func TestUnit_FederationController_StartFederatedLogin_WhenStartFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"unknownProvider": {
+			err:            errors.NewCode(service.UnknownIdentityProvider),
+			expectedStatus: http.StatusNotFound,
+		},
+		"providerUnavailable": {
+			err:            errors.NewCode(federation.ProviderUnavailable),
+			expectedStatus: http.StatusBadGateway,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google", nil)
+			m := &mockFederationService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.FederationService](t, req, m, startFederatedLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func TestUnit_FederationController_CompleteFederatedLogin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google/callback?code=my-code&state=my-state", nil)
+	req.AddCookie(&http.Cookie{Name: federatedLoginCookie, Value: "my-state"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "provider", Value: "google"}})
+	m := &mockFederationService{
+		login: communication.LoginDtoResponse{
+			Session: &communication.ApiKeyDtoResponse{
+				User: uuid.New(),
+				Key:  "usk_live_key",
+			},
+		},
+	}
+
+	err := completeFederatedLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "google", m.provider)
+	assert.Equal(t, "my-code", m.callback.Code)
+	assert.Equal(t, "my-state", m.callback.State)
+	assert.Equal(t, "my-state", m.browserState)
+	cookies := rw.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/v1/users/login/google", cookies[0].Path)
+	assert.Less(t, cookies[0].MaxAge, 0)
+}
+
+func TestUnit_FederationController_CompleteFederatedLogin_WhenSecondFactorIsRequired_ExpectChallenge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google/callback?code=my-code&state=my-state", nil)
+	req.AddCookie(&http.Cookie{Name: federatedLoginCookie, Value: "my-state"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "provider", Value: "google"}})
+	m := &mockFederationService{
+		login: communication.LoginDtoResponse{
+			Challenge: &communication.MfaChallengeDtoResponse{
+				Challenge: "usm_live_challenge",
+				Methods:   []string{service.TotpMethod},
+			},
+		},
+	}
+
+	err := completeFederatedLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+}
+
+func TestUnit_FederationController_CompleteFederatedLogin_WhenProviderReportsError_ExpectItIsForwarded(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google/callback?error=access_denied&state=my-state", nil)
+	ctx, _ := generateTestEchoContextFromRequest(req)
+	m := &mockFederationService{
+		err: errors.NewCode(service.InvalidFederatedLogin),
+	}
+
+	err := completeFederatedLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, "access_denied", m.callback.Error)
+	assert.Empty(t, m.browserState)
+}
+
+func TestUnit_FederationController_CompleteFederatedLogin_WhenCompleteFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"unknownProvider": {
+			err:            errors.NewCode(service.UnknownIdentityProvider),
+			expectedStatus: http.StatusNotFound,
+		},
+		"invalidLogin": {
+			err:            errors.NewCode(service.InvalidFederatedLogin),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"unverifiedEmail": {
+			err:            errors.NewCode(service.UnverifiedFederatedEmail),
+			expectedStatus: http.StatusForbidden,
+		},
+		"signUpDisabled": {
+			err:            errors.NewCode(service.FederatedSignUpDisabled),
+			expectedStatus: http.StatusForbidden,
+		},
+		"accountLocked": {
+			err:            errors.NewCode(service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusConflict,
+		},
+		"invalidIdToken": {
+			err:            errors.NewCode(federation.InvalidIdToken),
+			expectedStatus: http.StatusBadGateway,
+		},
+		"codeExchangeFailed": {
+			err:            errors.NewCode(federation.CodeExchangeFailed),
+			expectedStatus: http.StatusBadGateway,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/login/google/callback?code=my-code&state=my-state", nil)
+			m := &mockFederationService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.FederationService](t, req, m, completeFederatedLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func (m *mockFederationService) Start(ctx context.Context, provider string) (string, string, error) {
+	m.provider = provider
+	return m.redirect, m.state, m.err
+}
+
+func (m *mockFederationService) Complete(ctx context.Context, provider string, callback communication.FederatedCallbackDtoRequest, browserState string, client service.ClientInfo) (communication.LoginDtoResponse, error) {
+	m.provider = provider
+	m.callback = callback
+	m.browserState = browserState
+	return m.login, m.err
+}
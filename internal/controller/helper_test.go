@@ -10,6 +10,9 @@ import (
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/postgresql"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/clientip"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	"github.com/google/uuid"
@@ -19,6 +22,23 @@ import (
 
 var dbTestConfig = postgresql.NewConfigForLocalhost("db_user_service", "user_service_manager", "manager_password")
 
+var passwordTestConfig = password.Config{
+	Algorithm: password.Argon2id,
+	Argon2: password.Argon2Config{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	Policy: password.PolicyConfig{
+		MinLength:             8,
+		MaxLength:             64,
+		BanEmailLocalPart:     true,
+		RejectCommonPasswords: true,
+	},
+}
+
 func newTestConnection(t *testing.T) db.Connection {
 	conn, err := db.New(context.Background(), dbTestConfig)
 	require.Nil(t, err)
@@ -33,6 +53,19 @@ func generateTestEchoContextFromRequest(req *http.Request) (*echo.Context, *http
 	return ctx, rw
 }
 
+// trustTestProxies makes the handlers trust the proxies of the networks
+// until the end of the test.
+func trustTestProxies(t *testing.T, networks ...string) {
+	extractor, err := clientip.NewExtractor(clientip.Config{TrustedProxies: networks})
+	require.Nil(t, err)
+
+	previous := extractClientIp
+	UseClientIpExtractor(extractor)
+	t.Cleanup(func() {
+		UseClientIpExtractor(previous)
+	})
+}
+
 type controllerFunc[Service any] func(*echo.Context, Service) error
 
 func assertStatusCode[Service any](t *testing.T, req *http.Request, service Service, callable controllerFunc[Service], expectedStatusCode int) {
@@ -70,7 +103,7 @@ func insertTestUser(t *testing.T, conn db.Connection) persistence.User {
 	id := uuid.New()
 	user := persistence.User{
 		Id:        id,
-		Email:     fmt.Sprintf("my-user-%s", id),
+		Email:     fmt.Sprintf("my-user-%s@example.com", id),
 		Password:  "my-password",
 		CreatedAt: time.Now(),
 	}
@@ -100,22 +133,48 @@ func assertEmailForUser(t *testing.T, conn db.Connection, user uuid.UUID, expect
 	require.Equal(t, expectedEmail, value)
 }
 
-func insertApiKeyForUser(t *testing.T, conn db.Connection, userId uuid.UUID) persistence.ApiKey {
+func lockTestUser(t *testing.T, conn db.Connection, user persistence.User) {
+	sqlQuery := `
+INSERT INTO login_throttle (kind, key, failures, last_failure_at, blocked_until, locked)
+	VALUES ('email', lower($1), 10, $2, $3, true)`
+	_, err := conn.Exec(context.Background(), sqlQuery, user.Email, time.Now(), time.Now().Add(time.Hour))
+	require.Nil(t, err)
+}
+
+// testApiKey keeps track of the key in clear as only its digest is stored.
+type testApiKey struct {
+	persistence.ApiKey
+	Key apikey.Key
+}
+
+func insertApiKeyForUser(t *testing.T, conn db.Connection, userId uuid.UUID) testApiKey {
+	return insertApiKeyForUserWithValidity(t, conn, userId, time.Date(2024, 11, 22, 17, 00, 10, 0, time.UTC))
+}
+
+func insertApiKeyForUserWithValidity(t *testing.T, conn db.Connection, userId uuid.UUID, validity time.Time) testApiKey {
 	repo := repositories.NewApiKeyRepository(conn)
 
+	key := apikey.Generate()
 	apiKey := persistence.ApiKey{
 		Id:         uuid.New(),
-		Key:        uuid.New(),
+		KeyHash:    apikey.Digest(key.String(), ""),
 		ApiUser:    userId,
-		ValidUntil: time.Date(2024, 11, 22, 17, 00, 10, 0, time.UTC),
+		CreatedAt:  time.Now(),
+		ValidUntil: validity,
 	}
 
-	out, err := repo.Create(context.Background(), apiKey)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	out, err := repo.Create(context.Background(), tx, apiKey)
+	tx.Close(context.Background())
 	require.Nil(t, err)
 
 	assertApiKeyExists(t, conn, out.Id)
 
-	return out
+	return testApiKey{
+		ApiKey: out,
+		Key:    key,
+	}
 }
 
 func assertApiKeyExists(t *testing.T, conn db.Connection, id uuid.UUID) {
@@ -124,10 +183,10 @@ func assertApiKeyExists(t *testing.T, conn db.Connection, id uuid.UUID) {
 	require.Equal(t, id, value)
 }
 
-func assertApiKeyExistsByKey(t *testing.T, conn db.Connection, key uuid.UUID) {
-	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT key FROM api_key WHERE key = $1", key)
+func assertApiKeyExistsByKey(t *testing.T, conn db.Connection, key string) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM api_key WHERE key_hash = $1", apikey.Digest(key, ""))
 	require.Nil(t, err)
-	require.Equal(t, key, value)
+	require.Equal(t, 1, value)
 }
 
 func assertApiKeyDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/labstack/echo/v5"
+)
+
+// JwksEndpoints publishes the keys verifying the signed tokens. The response
+// is not wrapped in an envelope: clients expect the standard format.
+func JwksEndpoints(signer jwt.Signer) rest.Routes {
+	var out rest.Routes
+
+	jwksHandler := createServiceAwareHttpHandler(getJwks, signer)
+	jwks := rest.NewRawRoute(http.MethodGet, "/.well-known/jwks.json", jwksHandler)
+	out = append(out, jwks)
+
+	return out
+}
+
+// getJwks godoc
+//
+// @Summary Get token signing keys
+// @Description Returns the public keys verifying the tokens issued by the service as a JSON Web Key Set.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwt.Jwks
+// @Failure 500
+// @Router /users/.well-known/jwks.json [get]
+func getJwks(c *echo.Context, signer jwt.Signer) error {
+	keys, err := signer.Keys(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSigner struct {
+	jwt.Signer
+
+	keys jwt.Jwks
+	err  error
+}
+
+func TestUnit_JwksController_ExpectKeysWithoutEnvelope(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	m := &mockSigner{
+		keys: jwt.Jwks{
+			Keys: []jwt.Jwk{
+				{
+					KeyType:   "OKP",
+					Curve:     "Ed25519",
+					X:         "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+					KeyId:     "my-key",
+					Algorithm: "EdDSA",
+					Use:       "sig",
+				},
+			},
+		},
+	}
+	expectedBody := `{
+		"keys": [
+			{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+				"kid": "my-key",
+				"alg": "EdDSA",
+				"use": "sig"
+			}
+		]
+	}`
+
+	assertStatusCodeAndJsonBody[jwt.Signer](t, req, m, getJwks, http.StatusOK, expectedBody)
+}
+
+func TestUnit_JwksController_WhenKeysCannotBeLoaded_ExpectInternalServerError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockSigner{
+		err: errors.NewCode(keyring.DecryptionFailed),
+	}
+
+	err := getJwks(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func (m *mockSigner) Keys(_ context.Context) (jwt.Jwks, error) {
+	return m.keys, m.err
+}
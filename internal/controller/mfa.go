@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+)
+
+func MfaEndpoints(service service.MfaService) rest.Routes {
+	var out rest.Routes
+
+	completeLoginHandler := createServiceAwareHttpHandler(completeMfaLogin, service)
+	completeLogin := rest.NewRoute(http.MethodPost, "/sessions/mfa", completeLoginHandler)
+	out = append(out, completeLogin)
+
+	enrollHandler := createServiceAwareHttpHandler(enrollTotp, service)
+	enroll := rest.NewRoute(http.MethodPost, "/:id/totp", enrollHandler)
+	out = append(out, enroll)
+
+	confirmHandler := createServiceAwareHttpHandler(confirmTotp, service)
+	confirm := rest.NewRoute(http.MethodPost, "/:id/totp/confirmation", confirmHandler)
+	out = append(out, confirm)
+
+	disableHandler := createServiceAwareHttpHandler(disableTotp, service)
+	disable := rest.NewRoute(http.MethodDelete, "/:id/totp", disableHandler)
+	out = append(out, disable)
+
+	return out
+}
+
+// completeMfaLogin godoc
+//
+// @Summary Complete login with a second factor
+// @Description Exchanges the challenge returned by the login of a user who enabled two-factor authentication and a code of their authenticator app for an API key. Wrong codes count as failed login attempts, and the challenge is dropped after a few of them.
+// @Tags sessions
+// @Produce json
+// @Param request body communication.MfaDtoRequest true "Challenge and code"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired challenge, or invalid code"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many login attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/mfa [post]
+func completeMfaLogin(c *echo.Context, s service.MfaService) error {
+	var request communication.MfaDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.CompleteLogin(c.Request().Context(), request, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidMfaChallenge) {
+			return c.JSON(http.StatusUnauthorized, "Invalid challenge")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidMfaCode) {
+			return c.JSON(http.StatusUnauthorized, "Invalid code")
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many login attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusConflict, "Too many sessions")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// enrollTotp godoc
+//
+// @Summary Enroll an authenticator app
+// @Description Generates the secret to add to an authenticator app. Only available to the user themselves. The secret is only asked for at login once confirmed with a first code; enrolling again replaces a secret which was not confirmed yet.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 201 {object} rest.ResponseEnvelope[communication.TotpEnrollmentDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Two-factor authentication is already enabled"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/totp [post]
+func enrollTotp(c *echo.Context, s service.MfaService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.EnrollTotp(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.TotpAlreadyEnabled) {
+			return c.JSON(http.StatusConflict, "Two-factor authentication is already enabled")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// The secret should not stay in a cache.
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusCreated, out)
+}
+
+// confirmTotp godoc
+//
+// @Summary Confirm an authenticator app
+// @Description Enables two-factor authentication with a first code computed from the secret returned at enrollment. Only available to the user themselves.
+// @Tags users
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param code body communication.TotpCodeDtoRequest true "Code of the authenticator app"
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax, API key or code"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No authenticator app enrolled"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Two-factor authentication is already enabled"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/totp/confirmation [post]
+func confirmTotp(c *echo.Context, s service.MfaService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	var request communication.TotpCodeDtoRequest
+	err = c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid code syntax")
+	}
+
+	err = s.ConfirmTotp(c.Request().Context(), apiKey, id, request.Code)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidMfaCode) {
+			return c.JSON(http.StatusBadRequest, "Invalid code")
+		}
+		if errors.IsErrorWithCode(err, service.TotpNotEnrolled) {
+			return c.JSON(http.StatusNotFound, "No authenticator app enrolled")
+		}
+		if errors.IsErrorWithCode(err, service.TotpAlreadyEnabled) {
+			return c.JSON(http.StatusConflict, "Two-factor authentication is already enabled")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// disableTotp godoc
+//
+// @Summary Disable two-factor authentication
+// @Description Removes the authenticator app of the user. Only available to the user themselves, with a code which was not used before: a stolen session is not enough. Wrong codes count as failed login attempts.
+// @Tags users
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param code body communication.TotpCodeDtoRequest true "Code of the authenticator app"
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax, API key or code"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "Two-factor authentication is not enabled"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/totp [delete]
+func disableTotp(c *echo.Context, s service.MfaService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	var request communication.TotpCodeDtoRequest
+	err = c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid code syntax")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	err = s.DisableTotp(c.Request().Context(), apiKey, id, request.Code, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidMfaCode) {
+			return c.JSON(http.StatusBadRequest, "Invalid code")
+		}
+		if errors.IsErrorWithCode(err, service.TotpNotEnrolled) || errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "Two-factor authentication is not enabled")
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,372 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockMfaService struct {
+	err error
+
+	request    communication.MfaDtoRequest
+	id         uuid.UUID
+	code       string
+	client     service.ClientInfo
+	apiKey     communication.ApiKeyDtoResponse
+	enrollment communication.TotpEnrollmentDtoResponse
+}
+
+var defaultMfaUserId = uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+
+func TestUnit_MfaController_CompleteMfaLogin(t *testing.T) {
+	req := newTestMfaRequest(t, communication.MfaDtoRequest{
+		Challenge: "usm_live_challenge",
+		Code:      "123456",
+	})
+	req.RemoteAddr = "198.51.100.4:41234"
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockMfaService{
+		apiKey: communication.ApiKeyDtoResponse{
+			User: defaultMfaUserId,
+			Key:  "usk_live_key",
+		},
+	}
+
+	err := completeMfaLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "usm_live_challenge", m.request.Challenge)
+	assert.Equal(t, "123456", m.request.Code)
+	assert.Equal(t, "198.51.100.4", m.client.Ip)
+}
+
+func TestUnit_MfaController_CompleteMfaLogin_WhenBodyIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not-a-json"))
+	req.Header.Set("Content-Type", "application/json")
+	m := &mockMfaService{}
+	expectedBody := []byte("\"Invalid request syntax\"\n")
+
+	assertStatusCodeAndBody[service.MfaService](t, req, m, completeMfaLogin, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_MfaController_CompleteMfaLogin_WhenCompleteFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidChallenge": {
+			err:            errors.NewCode(service.InvalidMfaChallenge),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalidCode": {
+			err:            errors.NewCode(service.InvalidMfaCode),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"tooManyAttempts": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Second), service.TooManyLoginAttempts),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"accountLocked": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Minute), service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestMfaRequest(t, communication.MfaDtoRequest{})
+			m := &mockMfaService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.MfaService](t, req, m, completeMfaLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func TestUnit_MfaController_EnrollTotp(t *testing.T) {
+	req := newTestTotpRequest(t, http.MethodPost, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockMfaService{
+		enrollment: communication.TotpEnrollmentDtoResponse{
+			Secret: "JBSWY3DPEHPK3PXP",
+			Uri:    "otpauth://totp/user-service:some@e.mail?secret=JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	err := enrollTotp(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Contains(t, rw.Body.String(), "JBSWY3DPEHPK3PXP")
+}
+
+func TestUnit_MfaController_EnrollTotp_WhenIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestTotpRequest(t, http.MethodPost, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+	m := &mockMfaService{}
+
+	err := enrollTotp(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_MfaController_EnrollTotp_WhenApiKeyIsMissing_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockMfaService{}
+
+	err := enrollTotp(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_MfaController_EnrollTotp_WhenEnrollFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"alreadyEnabled": {
+			err:            errors.NewCode(service.TotpAlreadyEnabled),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestTotpRequest(t, http.MethodPost, nil)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+			m := &mockMfaService{
+				err: tc.err,
+			}
+
+			err := enrollTotp(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Empty(t, rw.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestUnit_MfaController_ConfirmTotp(t *testing.T) {
+	req := newTestTotpRequest(t, http.MethodPost, &communication.TotpCodeDtoRequest{Code: "123456"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockMfaService{}
+
+	err := confirmTotp(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Equal(t, "123456", m.code)
+}
+
+func TestUnit_MfaController_ConfirmTotp_WhenConfirmFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"invalidCode": {
+			err:            errors.NewCode(service.InvalidMfaCode),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"notEnrolled": {
+			err:            errors.NewCode(service.TotpNotEnrolled),
+			expectedStatus: http.StatusNotFound,
+		},
+		"alreadyEnabled": {
+			err:            errors.NewCode(service.TotpAlreadyEnabled),
+			expectedStatus: http.StatusConflict,
+		},
+		"unavailable": {
+			err:            errors.NewCode(service.SecondFactorUnavailable),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestTotpRequest(t, http.MethodPost, &communication.TotpCodeDtoRequest{Code: "123456"})
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+			m := &mockMfaService{
+				err: tc.err,
+			}
+
+			err := confirmTotp(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_MfaController_DisableTotp(t *testing.T) {
+	req := newTestTotpRequest(t, http.MethodDelete, &communication.TotpCodeDtoRequest{Code: "123456"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockMfaService{}
+
+	err := disableTotp(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Equal(t, "123456", m.code)
+}
+
+func TestUnit_MfaController_DisableTotp_WhenDisableFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"invalidCode": {
+			err:            errors.NewCode(service.InvalidMfaCode),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"notEnrolled": {
+			err:            errors.NewCode(service.TotpNotEnrolled),
+			expectedStatus: http.StatusNotFound,
+		},
+		"noSuchUser": {
+			err:            errors.NewCode(db.NoMatchingRows),
+			expectedStatus: http.StatusNotFound,
+		},
+		"tooManyAttempts": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Second), service.TooManyLoginAttempts),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"accountLocked": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Minute), service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestTotpRequest(t, http.MethodDelete, &communication.TotpCodeDtoRequest{Code: "123456"})
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+			m := &mockMfaService{
+				err: tc.err,
+			}
+
+			err := disableTotp(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func newTestMfaRequest(t *testing.T, requestDto communication.MfaDtoRequest) *http.Request {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func newTestTotpRequest(t *testing.T, method string, requestDto *communication.TotpCodeDtoRequest) *http.Request {
+	var body bytes.Buffer
+	if requestDto != nil {
+		err := json.NewEncoder(&body).Encode(requestDto)
+		require.Nil(t, err)
+	}
+
+	req := httptest.NewRequest(method, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	return req
+}
+
+func (m *mockMfaService) CompleteLogin(ctx context.Context, request communication.MfaDtoRequest, client service.ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	m.request = request
+	m.client = client
+	return m.apiKey, m.err
+}
+
+func (m *mockMfaService) EnrollTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.TotpEnrollmentDtoResponse, error) {
+	m.id = id
+	return m.enrollment, m.err
+}
+
+func (m *mockMfaService) ConfirmTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string) error {
+	m.id = id
+	m.code = code
+	return m.err
+}
+
+func (m *mockMfaService) DisableTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string, client service.ClientInfo) error {
+	m.id = id
+	m.code = code
+	m.client = client
+	return m.err
+}
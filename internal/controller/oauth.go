@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/url"
+
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/labstack/echo/v5"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+const (
+	invalidRequest       = "invalid_request"
+	invalidClient        = "invalid_client"
+	invalidGrant         = "invalid_grant"
+	unsupportedGrantType = "unsupported_grant_type"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+const (
+	unsupportedResponseType = "unsupported_response_type"
+	invalidScope            = "invalid_scope"
+	serverError             = "server_error"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+const (
+	invalidToken      = "invalid_token"
+	insufficientScope = "insufficient_scope"
+)
+
+type oauthError struct {
+	Error string `json:"error" example:"invalid_client"`
+}
+
+// clientCredentials reads the credentials from the Authorization header,
+// falling back to the form when it is not set.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+func clientCredentials(c *echo.Context) service.ClientCredentials {
+	id, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return service.ClientCredentials{
+			Id:     c.FormValue("client_id"),
+			Secret: c.FormValue("client_secret"),
+		}
+	}
+
+	// The credentials are form-encoded before being put in the header.
+	if unescaped, err := url.QueryUnescape(id); err == nil {
+		id = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(secret); err == nil {
+		secret = unescaped
+	}
+
+	return service.ClientCredentials{
+		Id:     id,
+		Secret: secret,
+	}
+}
@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/labstack/echo/v5"
+)
+
+//go:embed templates/authorize.html
+var authorizeTemplateContent string
+
+var authorizeTemplate = template.Must(template.New("authorize").Parse(authorizeTemplateContent))
+
+type authorizeTemplateData struct {
+	Request communication.AuthorizationDtoRequest
+	Error   string
+}
+
+// OidcEndpoints lets the OAuth clients sign users in. The formats are imposed
+// by the specifications: none of the responses are wrapped.
+func OidcEndpoints(service service.OidcService) rest.Routes {
+	var out rest.Routes
+
+	configurationHandler := createServiceAwareHttpHandler(getOidcConfiguration, service)
+	configuration := rest.NewRawRoute(http.MethodGet, "/.well-known/openid-configuration", configurationHandler)
+	out = append(out, configuration)
+
+	showFormHandler := createServiceAwareHttpHandler(showAuthorizationForm, service)
+	showForm := rest.NewRawRoute(http.MethodGet, "/oauth/authorize", showFormHandler)
+	out = append(out, showForm)
+
+	authorizeHandler := createServiceAwareHttpHandler(authorize, service)
+	authorize := rest.NewRawRoute(http.MethodPost, "/oauth/authorize", authorizeHandler)
+	out = append(out, authorize)
+
+	tokenHandler := createServiceAwareHttpHandler(exchangeCode, service)
+	token := rest.NewRawRoute(http.MethodPost, "/oauth/token", tokenHandler)
+	out = append(out, token)
+
+	// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoRequest
+	userInfoHandler := createServiceAwareHttpHandler(getUserInfo, service)
+	getInfo := rest.NewRawRoute(http.MethodGet, "/oauth/userinfo", userInfoHandler)
+	out = append(out, getInfo)
+	postInfo := rest.NewRawRoute(http.MethodPost, "/oauth/userinfo", userInfoHandler)
+	out = append(out, postInfo)
+
+	return out
+}
+
+// getOidcConfiguration godoc
+//
+// @Summary Get OpenID Connect configuration
+// @Description Describes the OpenID Connect provider as defined by the discovery specification.
+// @Tags oidc
+// @Produce json
+// @Success 200 {object} communication.OidcConfigurationDtoResponse
+// @Failure 500
+// @Router /users/.well-known/openid-configuration [get]
+func getOidcConfiguration(c *echo.Context, s service.OidcService) error {
+	out, err := s.Configuration(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// showAuthorizationForm godoc
+//
+// @Summary Show login form
+// @Description Starts the authorization code flow: users log in with their email and password and are sent back to the client with a code. A S256 PKCE challenge is required.
+// @Tags oidc
+// @Produce html
+// @Param response_type query string true "Must be code"
+// @Param client_id query string true "Client identifier"
+// @Param redirect_uri query string true "One of the redirect URIs registered for the client"
+// @Param scope query string true "Space-separated scopes, must include openid"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Opaque value copied in the ID token"
+// @Param code_challenge query string true "PKCE challenge"
+// @Param code_challenge_method query string true "Must be S256"
+// @Success 200 "Login form"
+// @Failure 303 "Redirection to the client with an error"
+// @Failure 400 "Unknown client or redirect URI"
+// @Router /users/oauth/authorize [get]
+func showAuthorizationForm(c *echo.Context, s service.OidcService) error {
+	var request communication.AuthorizationDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return renderAuthorizationError(c, http.StatusBadRequest, "Invalid authorization request")
+	}
+
+	err = s.ValidateAuthorization(c.Request().Context(), request)
+	if err != nil {
+		return handleAuthorizationError(c, request, err)
+	}
+
+	return renderAuthorizationForm(c, http.StatusOK, request, "")
+}
+
+// authorize godoc
+//
+// @Summary Log in
+// @Description Submits the login form: users are sent back to the client with a code once they logged in.
+// @Tags oidc
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param response_type formData string true "Must be code"
+// @Param client_id formData string true "Client identifier"
+// @Param redirect_uri formData string true "One of the redirect URIs registered for the client"
+// @Param scope formData string true "Space-separated scopes, must include openid"
+// @Param state formData string false "Opaque value returned to the client"
+// @Param nonce formData string false "Opaque value copied in the ID token"
+// @Param code_challenge formData string true "PKCE challenge"
+// @Param code_challenge_method formData string true "Must be S256"
+// @Param email formData string true "Email of the user"
+// @Param password formData string true "Password of the user"
+// @Param otp formData string false "One-time code of the authenticator app, for the users who enabled two-factor authentication"
+// @Success 303 "Redirection to the client with a code"
+// @Failure 400 "Unknown client or redirect URI"
+// @Failure 401 "Login form with an error"
+// @Failure 403 "Login form with an error"
+// @Failure 429 "Login form with an error"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Router /users/oauth/authorize [post]
+func authorize(c *echo.Context, s service.OidcService) error {
+	var request communication.AuthorizationDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return renderAuthorizationError(c, http.StatusBadRequest, "Invalid authorization request")
+	}
+
+	user := communication.UserDtoRequest{
+		Email:    c.FormValue("email"),
+		Password: c.FormValue("password"),
+	}
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	code, err := s.Authorize(c.Request().Context(), request, user, c.FormValue("otp"), client)
+	if err != nil {
+		// Users should not be told whether the account exists.
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) || errors.IsErrorWithCode(err, service.InvalidCredentials) {
+			return renderAuthorizationForm(c, http.StatusUnauthorized, request, "Invalid credentials")
+		}
+		if errors.IsErrorWithCode(err, service.EmailNotVerified) {
+			return renderAuthorizationForm(c, http.StatusForbidden, request, "Verify your email address first: a new link was sent to you")
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return renderAuthorizationForm(c, http.StatusTooManyRequests, request, "Too many login attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return renderAuthorizationForm(c, http.StatusTooManyRequests, request, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.SecondFactorRequired) {
+			return renderAuthorizationForm(c, http.StatusUnauthorized, request, "Enter the one-time code of your authenticator app")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidMfaCode) {
+			return renderAuthorizationForm(c, http.StatusUnauthorized, request, "Invalid one-time code")
+		}
+
+		return handleAuthorizationError(c, request, err)
+	}
+
+	params := url.Values{}
+	params.Set("code", code.String())
+	return redirectToClient(c, request, params)
+}
+
+// exchangeCode godoc
+//
+// @Summary Exchange authorization code
+// @Description Exchanges an authorization code for an access token and an ID token. Confidential clients authenticate with their credentials, either with HTTP Basic authentication or in the form. Public clients only provide their id.
+// @Tags oidc
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "Must be authorization_code"
+// @Param code formData string true "Authorization code"
+// @Param redirect_uri formData string true "Redirect URI used to obtain the code"
+// @Param code_verifier formData string true "PKCE verifier"
+// @Param client_id formData string false "Client identifier, when not using HTTP Basic authentication"
+// @Param client_secret formData string false "Client secret, when not using HTTP Basic authentication"
+// @Success 200 {object} communication.TokenDtoResponse
+// @Failure 400 {object} controller.oauthError "Invalid request or code"
+// @Failure 401 {object} controller.oauthError "Client is not authenticated"
+// @Failure 500
+// @Router /users/oauth/token [post]
+func exchangeCode(c *echo.Context, s service.OidcService) error {
+	var request communication.TokenDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, oauthError{Error: invalidRequest})
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	out, err := s.Exchange(c.Request().Context(), clientCredentials(c), request, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.ClientNotAuthenticated) {
+			c.Response().Header().Set("WWW-Authenticate", `Basic realm="user-service"`)
+			return c.JSON(http.StatusUnauthorized, oauthError{Error: invalidClient})
+		}
+		if errors.IsErrorWithCode(err, service.UnsupportedGrantType) {
+			return c.JSON(http.StatusBadRequest, oauthError{Error: unsupportedGrantType})
+		}
+		// The user can't open more sessions: the client has to start over.
+		if errors.IsErrorWithCode(err, service.InvalidGrant) || errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusBadRequest, oauthError{Error: invalidGrant})
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// getUserInfo godoc
+//
+// @Summary Get user info
+// @Description Returns the claims about the user which the access token gives access to. Only the tokens issued to OpenID Connect clients are accepted.
+// @Tags oidc
+// @Produce json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} communication.UserInfoDtoResponse
+// @Failure 401 "Missing or invalid access token"
+// @Failure 403 "Access token was not issued with the openid scope"
+// @Failure 500
+// @Router /users/oauth/userinfo [get]
+func getUserInfo(c *echo.Context, s service.OidcService) error {
+	apiKey, ok := tryGetBearerToken(c.Request())
+	if !ok {
+		c.Response().Header().Set("WWW-Authenticate", `Bearer realm="user-service"`)
+		return c.NoContent(http.StatusUnauthorized)
+	}
+
+	out, err := s.UserInfo(c.Request().Context(), apiKey)
+	if err != nil {
+		if isUserNotAuthenticated(err) {
+			c.Response().Header().Set("WWW-Authenticate", `Bearer realm="user-service", error="`+invalidToken+`"`)
+			return c.NoContent(http.StatusUnauthorized)
+		}
+		if errors.IsErrorWithCode(err, service.InsufficientScope) {
+			c.Response().Header().Set("WWW-Authenticate", `Bearer realm="user-service", error="`+insufficientScope+`", scope="`+service.OpenIdScope+`"`)
+			return c.NoContent(http.StatusForbidden)
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, out)
+}
+
+// handleAuthorizationError sends the user back to the client with the error,
+// unless the client or the redirect URI can't be trusted.
+func handleAuthorizationError(c *echo.Context, request communication.AuthorizationDtoRequest, err error) error {
+	if errors.IsErrorWithCode(err, service.InvalidRedirectUri) {
+		return renderAuthorizationError(c, http.StatusBadRequest, "Unknown client or redirect URI")
+	}
+
+	code := serverError
+	if errors.IsErrorWithCode(err, service.UnsupportedResponseType) {
+		code = unsupportedResponseType
+	}
+	if errors.IsErrorWithCode(err, service.InvalidScope) {
+		code = invalidScope
+	}
+	if errors.IsErrorWithCode(err, service.InvalidAuthorizationRequest) {
+		code = invalidRequest
+	}
+
+	params := url.Values{}
+	params.Set("error", code)
+	return redirectToClient(c, request, params)
+}
+
+// redirectToClient adds the parameters and the state to the redirect URI of
+// the request, which is expected to be valid.
+func redirectToClient(c *echo.Context, request communication.AuthorizationDtoRequest, params url.Values) error {
+	target, err := url.Parse(request.RedirectUri)
+	if err != nil {
+		return renderAuthorizationError(c, http.StatusBadRequest, "Unknown client or redirect URI")
+	}
+
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	// A 303 makes sure that the browser does not post the form again.
+	return c.Redirect(http.StatusSeeOther, target.String())
+}
+
+func renderAuthorizationForm(c *echo.Context, status int, request communication.AuthorizationDtoRequest, message string) error {
+	data := authorizeTemplateData{
+		Request: request,
+		Error:   message,
+	}
+
+	var out bytes.Buffer
+	err := authorizeTemplate.Execute(&out, data)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// The form should not be embedded by another site to trick users into
+	// logging in.
+	c.Response().Header().Set("X-Frame-Options", "DENY")
+	c.Response().Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.HTML(status, out.String())
+}
+
+func renderAuthorizationError(c *echo.Context, status int, message string) error {
+	return c.HTML(status, "<!DOCTYPE html><title>Sign in</title><p>"+template.HTMLEscapeString(message)+"</p>")
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6750#section-2.1
+func tryGetBearerToken(req *http.Request) (apikey.Key, bool) {
+	header := req.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return apikey.Key{}, false
+	}
+
+	apiKey, err := apikey.Parse(strings.TrimSpace(token))
+	if err != nil {
+		return apikey.Key{}, false
+	}
+
+	return apiKey, true
+}
@@ -0,0 +1,614 @@
+package controller
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockOidcService struct {
+	service.OidcService
+
+	err error
+
+	request     communication.AuthorizationDtoRequest
+	user        communication.UserDtoRequest
+	oneTimeCode string
+	code        apikey.AuthorizationCode
+	client      service.ClientCredentials
+	token       communication.TokenDtoRequest
+	tokens      communication.TokenDtoResponse
+	apiKey      apikey.Key
+	userInfo    communication.UserInfoDtoResponse
+}
+
+var authorizationTestQuery = url.Values{
+	"response_type":         {"code"},
+	"client_id":             {"my-client"},
+	"redirect_uri":          {"https://my-client.example.com/callback?tenant=1"},
+	"scope":                 {"openid email"},
+	"state":                 {"my-state"},
+	"nonce":                 {"my-nonce"},
+	"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+	"code_challenge_method": {"S256"},
+}
+
+func TestUnit_OidcController_ShowAuthorizationForm(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?"+authorizationTestQuery.Encode(), nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockOidcService{}
+
+	err := showAuthorizationForm(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "DENY", rw.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "my-client", m.request.ClientId)
+	assert.Equal(t, "https://my-client.example.com/callback?tenant=1", m.request.RedirectUri)
+	assert.Equal(t, "S256", m.request.CodeChallengeMethod)
+	assert.Contains(t, rw.Body.String(), `name="state" value="my-state"`)
+}
+
+func TestUnit_OidcController_ShowAuthorizationForm_WhenRedirectUriIsInvalid_ExpectNoRedirection(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?"+authorizationTestQuery.Encode(), nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockOidcService{
+		err: errors.NewCode(service.InvalidRedirectUri),
+	}
+
+	err := showAuthorizationForm(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Empty(t, rw.Header().Get("Location"))
+}
+
+func TestUnit_OidcController_ShowAuthorizationForm_WhenRequestIsInvalid_ExpectErrorIsSentToClient(t *testing.T) {
+	type testCase struct {
+		err           error
+		expectedError string
+	}
+
+	testCases := map[string]testCase{
+		"unsupportedResponseType": {
+			err:           errors.NewCode(service.UnsupportedResponseType),
+			expectedError: "unsupported_response_type",
+		},
+		"invalidScope": {
+			err:           errors.NewCode(service.InvalidScope),
+			expectedError: "invalid_scope",
+		},
+		"invalidRequest": {
+			err:           errors.NewCode(service.InvalidAuthorizationRequest),
+			expectedError: "invalid_request",
+		},
+		"serverError": {
+			err:           errors.New("failure"),
+			expectedError: "server_error",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?"+authorizationTestQuery.Encode(), nil)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockOidcService{
+				err: tc.err,
+			}
+
+			err := showAuthorizationForm(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, http.StatusSeeOther, rw.Code)
+			expected := "https://my-client.example.com/callback?error=" + tc.expectedError + "&state=my-state&tenant=1"
+			assert.Equal(t, expected, rw.Header().Get("Location"))
+		})
+	}
+}
+
+func TestUnit_OidcController_Authorize_ExpectRedirectionWithCode(t *testing.T) {
+	form := cloneValues(authorizationTestQuery)
+	form.Set("email", "user@example.com")
+	form.Set("password", "my-password")
+	req := newTestFormRequest(form)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockOidcService{
+		code: apikey.GenerateAuthorizationCode(),
+	}
+
+	err := authorize(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusSeeOther, rw.Code)
+	expected := "https://my-client.example.com/callback?code=" + m.code.String() + "&state=my-state&tenant=1"
+	assert.Equal(t, expected, rw.Header().Get("Location"))
+	assert.Equal(t, "my-client", m.request.ClientId)
+	assert.Equal(t, "user@example.com", m.user.Email)
+	assert.Equal(t, "my-password", m.user.Password)
+	assert.Empty(t, m.oneTimeCode)
+}
+
+func TestUnit_OidcController_Authorize_ExpectOneTimeCodeIsForwarded(t *testing.T) {
+	form := cloneValues(authorizationTestQuery)
+	form.Set("email", "user@example.com")
+	form.Set("password", "my-password")
+	form.Set("otp", "492039")
+	req := newTestFormRequest(form)
+	ctx, _ := generateTestEchoContextFromRequest(req)
+	m := &mockOidcService{
+		code: apikey.GenerateAuthorizationCode(),
+	}
+
+	err := authorize(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, "492039", m.oneTimeCode)
+}
+
+func TestUnit_OidcController_Authorize_WhenLoginFails_ExpectFormIsDisplayedAgain(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+		expectedError  string
+	}
+
+	testCases := map[string]testCase{
+		"invalidCredentials": {
+			err:            errors.NewCode(service.InvalidCredentials),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Invalid credentials",
+		},
+		"emailNotVerified": {
+			err:            errors.NewCode(service.EmailNotVerified),
+			expectedStatus: http.StatusForbidden,
+			expectedError:  "Verify your email address first: a new link was sent to you",
+		},
+		"tooManyAttempts": {
+			err:            errors.NewCode(service.TooManyLoginAttempts),
+			expectedStatus: http.StatusTooManyRequests,
+			expectedError:  "Too many login attempts",
+		},
+		"accountLocked": {
+			err:            errors.NewCode(service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+			expectedError:  "Account locked",
+		},
+		"secondFactorRequired": {
+			err:            errors.NewCode(service.SecondFactorRequired),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Enter the one-time code of your authenticator app",
+		},
+		"invalidOneTimeCode": {
+			err:            errors.NewCode(service.InvalidMfaCode),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Invalid one-time code",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestFormRequest(authorizationTestQuery)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockOidcService{
+				err: tc.err,
+			}
+
+			err := authorize(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Empty(t, rw.Header().Get("Location"))
+			assert.Contains(t, rw.Body.String(), tc.expectedError)
+		})
+	}
+}
+
+func TestUnit_OidcController_ExchangeCode(t *testing.T) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {"usc_live_code"},
+		"redirect_uri":  {"https://my-client.example.com/callback"},
+		"code_verifier": {"my-verifier"},
+	}
+	req := newTestFormRequest(form)
+	req.SetBasicAuth(oauthTestClient.Id, oauthTestClient.Secret)
+	m := &mockOidcService{
+		tokens: communication.TokenDtoResponse{
+			AccessToken: sampleApiKey,
+			TokenType:   "Bearer",
+			ExpiresIn:   3600,
+			IdToken:     "header.payload.signature",
+			Scope:       "openid",
+		},
+	}
+	expectedBody := `
+	{
+		"access_token": "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
+		"token_type": "Bearer",
+		"expires_in": 3600,
+		"id_token": "header.payload.signature",
+		"scope": "openid"
+	}`
+
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	err := exchangeCode(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, expectedBody, rw.Body.String())
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, oauthTestClient, m.client)
+	expectedRequest := communication.TokenDtoRequest{
+		GrantType:    "authorization_code",
+		Code:         "usc_live_code",
+		RedirectUri:  "https://my-client.example.com/callback",
+		CodeVerifier: "my-verifier",
+	}
+	assert.Equal(t, expectedRequest, m.token)
+}
+
+func TestUnit_OidcController_ExchangeCode_WhenExchangeFails_ExpectOAuthError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+		expectedError  string
+	}
+
+	testCases := map[string]testCase{
+		"clientNotAuthenticated": {
+			err:            errors.NewCode(service.ClientNotAuthenticated),
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "invalid_client",
+		},
+		"unsupportedGrantType": {
+			err:            errors.NewCode(service.UnsupportedGrantType),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "unsupported_grant_type",
+		},
+		"invalidGrant": {
+			err:            errors.NewCode(service.InvalidGrant),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_grant",
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "invalid_grant",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestFormRequest(url.Values{"grant_type": {"authorization_code"}})
+			m := &mockOidcService{
+				err: tc.err,
+			}
+
+			assertStatusCodeAndJsonBody[service.OidcService](t, req, m, exchangeCode, tc.expectedStatus, `{"error":"`+tc.expectedError+`"}`)
+		})
+	}
+}
+
+func TestUnit_OidcController_GetUserInfo(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sampleApiKey)
+	m := &mockOidcService{
+		userInfo: communication.UserInfoDtoResponse{
+			Subject: "0463ed3d-bfc9-4c10-b6ee-c223bbca0fab",
+			Email:   "user@example.com",
+		},
+	}
+	expectedBody := `
+	{
+		"sub": "0463ed3d-bfc9-4c10-b6ee-c223bbca0fab",
+		"email": "user@example.com"
+	}`
+
+	assertStatusCodeAndJsonBody[service.OidcService](t, req, m, getUserInfo, http.StatusOK, expectedBody)
+	assert.Equal(t, sampleApiKey, m.apiKey.String())
+}
+
+func TestUnit_OidcController_GetUserInfo_WhenTokenIsMissingOrInvalid_ExpectUnauthorized(t *testing.T) {
+	type testCase struct {
+		header string
+		err    error
+	}
+
+	testCases := map[string]testCase{
+		"noHeader": {},
+		"notBearer": {
+			header: "Basic " + sampleApiKey,
+		},
+		"malformedToken": {
+			header: "Bearer not-a-key",
+		},
+		"notAuthenticated": {
+			header: "Bearer " + sampleApiKey,
+			err:    errors.NewCode(service.UserNotAuthenticated),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockOidcService{
+				err: tc.err,
+			}
+
+			err := getUserInfo(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, http.StatusUnauthorized, rw.Code)
+			assert.Contains(t, rw.Header().Get("WWW-Authenticate"), "Bearer")
+		})
+	}
+}
+
+func TestUnit_OidcController_GetUserInfo_WhenScopeIsInsufficient_ExpectForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sampleApiKey)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockOidcService{
+		err: errors.NewCode(service.InsufficientScope),
+	}
+
+	err := getUserInfo(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Contains(t, rw.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+}
+
+// TestIT_OidcController_AuthorizationCodeFlow plays the part of a relying
+// party signing a user in through the HTTP endpoints.
+func TestIT_OidcController_AuthorizationCodeFlow(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	signer := newTestOidcSigner(t)
+
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		AuthorizationCode:  repositories.NewAuthorizationCodeRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Role:               repositories.NewRoleRepository(conn),
+		RoleAssignment:     repositories.NewRoleAssignmentRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+	hasher, err := password.NewHasher(passwordTestConfig)
+	require.Nil(t, err)
+	policy, err := password.NewPolicy(passwordTestConfig.Policy)
+	require.Nil(t, err)
+	config := service.OidcConfig{
+		Enabled:      true,
+		CodeValidity: time.Minute,
+	}
+	oauthConfig := service.OAuthConfig{
+		Clients: []service.OAuthClient{
+			{
+				Id:           "my-client",
+				SecretDigest: apikey.Digest("my-secret", ""),
+				RedirectUris: []string{"https://my-client.example.com/callback"},
+			},
+		},
+	}
+	apiKeyConfig := service.ApiKeyConfig{
+		Validity: time.Hour,
+	}
+	users := service.NewUserService(apiKeyConfig, service.AdminConfig{}, service.LoginThrottleConfig{}, service.VerificationConfig{}, signer, nil, nil, email.NewNormalizer(email.Config{}), hasher, policy, conn, repos)
+	oidcService := service.NewOidcService(config, oauthConfig, nil, repos, users)
+
+	e := echo.New()
+	routes := append(OidcEndpoints(oidcService), JwksEndpoints(signer)...)
+	for _, route := range routes {
+		e.Add(route.Method(), route.Path(), route.Handler())
+	}
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	// The relying party handles the redirections itself.
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"my-client"},
+		"redirect_uri":          {"https://my-client.example.com/callback"},
+		"scope":                 {"openid email"},
+		"state":                 {"my-state"},
+		"nonce":                 {"my-nonce"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	resp, err := client.Get(server.URL + "/oauth/authorize?" + query.Encode())
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	form := cloneValues(query)
+	form.Set("email", user.Email)
+	form.Set("password", user.Password)
+	resp, err = client.PostForm(server.URL+"/oauth/authorize", form)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.Nil(t, err)
+	assert.Equal(t, "my-client.example.com", location.Host)
+	assert.Equal(t, "my-state", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	tokenForm := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {"https://my-client.example.com/callback"},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/oauth/token", strings.NewReader(tokenForm.Encode()))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("my-client", "my-secret")
+	resp, err = client.Do(req)
+	require.Nil(t, err)
+	var tokens communication.TokenDtoResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&tokens))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = client.Get(server.URL + "/.well-known/jwks.json")
+	require.Nil(t, err)
+	var jwks jwt.Jwks
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	resp.Body.Close()
+	claims := verifyTestIdToken(t, tokens.IdToken, jwks)
+	assert.Equal(t, user.Id.String(), claims.Subject)
+	assert.Equal(t, "my-client", claims.Audience)
+	assert.Equal(t, "my-nonce", claims.Nonce)
+	assert.Equal(t, user.Email, claims.Email)
+
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/oauth/userinfo", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	resp, err = client.Do(req)
+	require.Nil(t, err)
+	var info communication.UserInfoDtoResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&info))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, user.Id.String(), info.Subject)
+	assert.Equal(t, user.Email, info.Email)
+}
+
+func (m *mockOidcService) ValidateAuthorization(ctx context.Context, request communication.AuthorizationDtoRequest) error {
+	m.request = request
+	return m.err
+}
+
+func (m *mockOidcService) Authorize(ctx context.Context, request communication.AuthorizationDtoRequest, user communication.UserDtoRequest, oneTimeCode string, client service.ClientInfo) (apikey.AuthorizationCode, error) {
+	m.request = request
+	m.user = user
+	m.oneTimeCode = oneTimeCode
+	return m.code, m.err
+}
+
+func (m *mockOidcService) Exchange(ctx context.Context, credentials service.ClientCredentials, request communication.TokenDtoRequest, client service.ClientInfo) (communication.TokenDtoResponse, error) {
+	m.client = credentials
+	m.token = request
+	return m.tokens, m.err
+}
+
+func (m *mockOidcService) UserInfo(ctx context.Context, apiKey apikey.Key) (communication.UserInfoDtoResponse, error) {
+	m.apiKey = apiKey
+	return m.userInfo, m.err
+}
+
+func newTestFormRequest(form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func cloneValues(values url.Values) url.Values {
+	out := url.Values{}
+	for key, value := range values {
+		out[key] = append([]string(nil), value...)
+	}
+	return out
+}
+
+// staticKeyring always signs with the same key.
+type staticKeyring struct {
+	keyring.Keyring
+
+	key keyring.Key
+}
+
+func (k *staticKeyring) Active(ctx context.Context, purpose keyring.Purpose) (keyring.Key, error) {
+	return k.key, nil
+}
+
+func (k *staticKeyring) Accepted(ctx context.Context, purpose keyring.Purpose) ([]keyring.Key, error) {
+	return []keyring.Key{k.key}, nil
+}
+
+func newTestOidcSigner(t *testing.T) jwt.Signer {
+	key, err := keyring.GenerateKey(keyring.TokenSigning, keyring.EdDSA, time.Now())
+	require.Nil(t, err)
+
+	config := jwt.Config{
+		Enabled: true,
+		Issuer:  "https://example.com/v1/users",
+	}
+	return jwt.NewSigner(config, &staticKeyring{key: key})
+}
+
+// verifyTestIdToken checks the signature of the token with the published
+// keys, as a relying party would.
+func verifyTestIdToken(t *testing.T, token string, jwks jwt.Jwks) jwt.IdClaims {
+	segments := strings.Split(token, ".")
+	require.Len(t, segments, 3)
+
+	var header struct {
+		KeyId string `json:"kid"`
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(segments[0])
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(decoded, &header))
+
+	var publicKey ed25519.PublicKey
+	for _, key := range jwks.Keys {
+		if key.KeyId == header.KeyId {
+			publicKey, err = base64.RawURLEncoding.DecodeString(key.X)
+			require.Nil(t, err)
+		}
+	}
+	require.NotNil(t, publicKey, "No key %s in %v", header.KeyId, jwks)
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	require.Nil(t, err)
+	require.True(t, ed25519.Verify(publicKey, []byte(segments[0]+"."+segments[1]), signature))
+
+	var claims jwt.IdClaims
+	decoded, err = base64.RawURLEncoding.DecodeString(segments[1])
+	require.Nil(t, err)
+	require.Nil(t, json.Unmarshal(decoded, &claims))
+	return claims
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/labstack/echo/v5"
+)
+
+func PasswordResetEndpoints(service service.PasswordResetService) rest.Routes {
+	var out rest.Routes
+
+	requestHandler := createServiceAwareHttpHandler(requestPasswordReset, service)
+	request := rest.NewRoute(http.MethodPost, "/password-resets", requestHandler)
+	out = append(out, request)
+
+	resetHandler := createServiceAwareHttpHandler(resetPassword, service)
+	reset := rest.NewRoute(http.MethodPost, "/password-resets/:token", resetHandler)
+	out = append(out, reset)
+
+	return out
+}
+
+// requestPasswordReset godoc
+//
+// @Summary Request a password reset
+// @Description Sends a link to choose a new password to the user owning the email. The answer is the same whether such a user exists or not. The number of requests for the same email is limited.
+// @Tags users
+// @Accept json
+// @Param request body communication.PasswordResetDtoRequest true "Email of the user"
+// @Success 202
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many requests for the email"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/password-resets [post]
+func requestPasswordReset(c *echo.Context, s service.PasswordResetService) error {
+	var passwordResetDtoRequest communication.PasswordResetDtoRequest
+	err := c.Bind(&passwordResetDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	err = s.Request(c.Request().Context(), passwordResetDtoRequest.Email)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.TooManyRequests) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many requests")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// resetPassword godoc
+//
+// @Summary Reset password
+// @Description Replaces the password of the user the token was sent to and revokes all their sessions. Each token can only be used once, but it is kept when the password is rejected by the policy.
+// @Tags users
+// @Accept json
+// @Param token path string true "Password reset token"
+// @Param password body communication.NewPasswordDtoRequest true "New password"
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[communication.PasswordViolationsDtoResponse] "Invalid token or password syntax (as a string), or list of password policy rules which are not respected"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired token"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Password was changed concurrently"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/password-resets/{token} [post]
+func resetPassword(c *echo.Context, s service.PasswordResetService) error {
+	token, err := apikey.ParsePasswordResetToken(c.Param("token"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid token syntax")
+	}
+
+	var newPasswordDtoRequest communication.NewPasswordDtoRequest
+	err = c.Bind(&newPasswordDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid password syntax")
+	}
+
+	err = s.Reset(c.Request().Context(), token, newPasswordDtoRequest.Password)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidPasswordResetToken) {
+			return c.JSON(http.StatusUnauthorized, "Invalid password reset token")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidPassword) {
+			return c.JSON(http.StatusBadRequest, toPasswordViolationsDtoResponse(err))
+		}
+		if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+			return c.JSON(http.StatusConflict, "Password was changed concurrently")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockPasswordResetService struct {
+	err error
+
+	email    string
+	token    apikey.PasswordResetToken
+	password string
+}
+
+func TestUnit_PasswordResetController_RequestPasswordReset(t *testing.T) {
+	req := newTestPasswordResetRequest(t, communication.PasswordResetDtoRequest{Email: "user@example.com"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockPasswordResetService{}
+
+	err := requestPasswordReset(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, "user@example.com", m.email)
+}
+
+func TestUnit_PasswordResetController_RequestPasswordReset_WhenBodyIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("not-json"))
+	req.Header.Set("Content-Type", "application/json")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockPasswordResetService{}
+
+	err := requestPasswordReset(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid request syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_PasswordResetController_RequestPasswordReset_WhenRequestFails_ExpectInternalServerError(t *testing.T) {
+	req := newTestPasswordResetRequest(t, communication.PasswordResetDtoRequest{Email: "user@example.com"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockPasswordResetService{
+		err: errors.New("failure"),
+	}
+
+	err := requestPasswordReset(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestUnit_PasswordResetController_RequestPasswordReset_WhenTooManyRequests_ExpectTooManyRequests(t *testing.T) {
+	req := newTestPasswordResetRequest(t, communication.PasswordResetDtoRequest{Email: "user@example.com"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockPasswordResetService{
+		err: errors.WrapCode(service.NewRetryAfterError(1*time.Hour), service.TooManyRequests),
+	}
+
+	err := requestPasswordReset(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "3600", rw.Header().Get("Retry-After"))
+	assert.Equal(t, "\"Too many requests\"\n", rw.Body.String())
+}
+
+func TestUnit_PasswordResetController_ResetPassword(t *testing.T) {
+	token := apikey.GeneratePasswordResetToken()
+	req := newTestPasswordResetRequest(t, communication.NewPasswordDtoRequest{Password: "my-new-password"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "token", Value: token.String()}})
+	m := &mockPasswordResetService{}
+
+	err := resetPassword(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, token, m.token)
+	assert.Equal(t, "my-new-password", m.password)
+}
+
+func TestUnit_PasswordResetController_ResetPassword_WhenTokenIsMalformed_ExpectBadRequest(t *testing.T) {
+	req := newTestPasswordResetRequest(t, communication.NewPasswordDtoRequest{Password: "my-new-password"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "token", Value: apikey.GenerateVerificationToken().String()}})
+	m := &mockPasswordResetService{}
+
+	err := resetPassword(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid token syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_PasswordResetController_ResetPassword_WhenPasswordIsRejected_ExpectViolations(t *testing.T) {
+	req := newTestPasswordResetRequest(t, communication.NewPasswordDtoRequest{Password: "short"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "token", Value: apikey.GeneratePasswordResetToken().String()}})
+	violations := []password.Rule{password.TooShort}
+	m := &mockPasswordResetService{
+		err: errors.WrapCode(password.NewPolicyViolationError(violations), service.InvalidPassword),
+	}
+
+	err := resetPassword(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.JSONEq(t, `{"violations": ["too_short"]}`, rw.Body.String())
+}
+
+func TestUnit_PasswordResetController_ResetPassword_WhenResetFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidToken": {
+			err:            errors.NewCode(service.InvalidPasswordResetToken),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"optimisticLock": {
+			err:            errors.NewCode(repositories.OptimisticLockException),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestPasswordResetRequest(t, communication.NewPasswordDtoRequest{Password: "my-new-password"})
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "token", Value: apikey.GeneratePasswordResetToken().String()}})
+			m := &mockPasswordResetService{
+				err: tc.err,
+			}
+
+			err := resetPassword(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func newTestPasswordResetRequest(t *testing.T, dto any) *http.Request {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(dto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (m *mockPasswordResetService) Request(ctx context.Context, rawEmail string) error {
+	m.email = rawEmail
+	return m.err
+}
+
+func (m *mockPasswordResetService) Reset(ctx context.Context, token apikey.PasswordResetToken, plaintext string) error {
+	m.token = token
+	m.password = plaintext
+	return m.err
+}
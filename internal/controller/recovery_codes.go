@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+)
+
+func RecoveryCodeEndpoints(service service.RecoveryCodeService) rest.Routes {
+	var out rest.Routes
+
+	generateHandler := createServiceAwareHttpHandler(generateRecoveryCodes, service)
+	generate := rest.NewRoute(http.MethodPost, "/:id/recovery-codes", generateHandler)
+	out = append(out, generate)
+
+	return out
+}
+
+// generateRecoveryCodes godoc
+//
+// @Summary Generate recovery codes
+// @Description Generates single-use codes which can replace the password at login when it is lost. Only available to the user themselves. The codes are only returned once: generating them again replaces the ones which were not used yet. Logging in with a recovery code requires the user to choose a new password.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 201 {object} rest.ResponseEnvelope[communication.RecoveryCodesDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/recovery-codes [post]
+func generateRecoveryCodes(c *echo.Context, s service.RecoveryCodeService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.Generate(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// The codes should not stay in a cache.
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusCreated, out)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRecoveryCodeService struct {
+	err error
+
+	id    uuid.UUID
+	codes communication.RecoveryCodesDtoResponse
+}
+
+var defaultRecoveryCodeUserId = uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+
+func TestUnit_RecoveryCodeController_GenerateRecoveryCodes(t *testing.T) {
+	req := newTestRecoveryCodesRequest()
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRecoveryCodeUserId.String()}})
+	m := &mockRecoveryCodeService{
+		codes: communication.RecoveryCodesDtoResponse{
+			Codes: []string{"k7qm-2xvd-9pfh-3nwa"},
+		},
+	}
+
+	err := generateRecoveryCodes(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.Equal(t, defaultRecoveryCodeUserId, m.id)
+	assert.Contains(t, rw.Body.String(), "k7qm-2xvd-9pfh-3nwa")
+}
+
+func TestUnit_RecoveryCodeController_GenerateRecoveryCodes_WhenIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestRecoveryCodesRequest()
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+	m := &mockRecoveryCodeService{}
+
+	err := generateRecoveryCodes(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_RecoveryCodeController_GenerateRecoveryCodes_WhenApiKeyIsMissing_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRecoveryCodeUserId.String()}})
+	m := &mockRecoveryCodeService{}
+
+	err := generateRecoveryCodes(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_RecoveryCodeController_GenerateRecoveryCodes_WhenGenerateFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRecoveryCodesRequest()
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRecoveryCodeUserId.String()}})
+			m := &mockRecoveryCodeService{
+				err: tc.err,
+			}
+
+			err := generateRecoveryCodes(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Empty(t, rw.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func newTestRecoveryCodesRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	return req
+}
+
+func (m *mockRecoveryCodeService) Generate(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.RecoveryCodesDtoResponse, error) {
+	m.id = id
+	return m.codes, m.err
+}
@@ -0,0 +1,644 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+)
+
+func RoleEndpoints(service service.RoleService) rest.Routes {
+	var out rest.Routes
+
+	createPermissionHandler := createServiceAwareHttpHandler(createPermission, service)
+	createPermission := rest.NewRoute(http.MethodPost, "/permissions", createPermissionHandler)
+	out = append(out, createPermission)
+
+	getPermissionHandler := createServiceAwareHttpHandler(getPermission, service)
+	getPermission := rest.NewRoute(http.MethodGet, "/permissions/:id", getPermissionHandler)
+	out = append(out, getPermission)
+
+	listPermissionsHandler := createServiceAwareHttpHandler(listPermissions, service)
+	listPermissions := rest.NewRoute(http.MethodGet, "/permissions", listPermissionsHandler)
+	out = append(out, listPermissions)
+
+	updatePermissionHandler := createServiceAwareHttpHandler(updatePermission, service)
+	updatePermission := rest.NewRoute(http.MethodPatch, "/permissions/:id", updatePermissionHandler)
+	out = append(out, updatePermission)
+
+	deletePermissionHandler := createServiceAwareHttpHandler(deletePermission, service)
+	deletePermission := rest.NewRoute(http.MethodDelete, "/permissions/:id", deletePermissionHandler)
+	out = append(out, deletePermission)
+
+	createRoleHandler := createServiceAwareHttpHandler(createRole, service)
+	createRole := rest.NewRoute(http.MethodPost, "/roles", createRoleHandler)
+	out = append(out, createRole)
+
+	getRoleHandler := createServiceAwareHttpHandler(getRole, service)
+	getRole := rest.NewRoute(http.MethodGet, "/roles/:id", getRoleHandler)
+	out = append(out, getRole)
+
+	listRolesHandler := createServiceAwareHttpHandler(listRoles, service)
+	listRoles := rest.NewRoute(http.MethodGet, "/roles", listRolesHandler)
+	out = append(out, listRoles)
+
+	updateRoleHandler := createServiceAwareHttpHandler(updateRole, service)
+	updateRole := rest.NewRoute(http.MethodPatch, "/roles/:id", updateRoleHandler)
+	out = append(out, updateRole)
+
+	deleteRoleHandler := createServiceAwareHttpHandler(deleteRole, service)
+	deleteRole := rest.NewRoute(http.MethodDelete, "/roles/:id", deleteRoleHandler)
+	out = append(out, deleteRole)
+
+	listAssignedRolesHandler := createServiceAwareHttpHandler(listAssignedRoles, service)
+	listAssignedRoles := rest.NewRoute(http.MethodGet, "/:id/roles", listAssignedRolesHandler)
+	out = append(out, listAssignedRoles)
+
+	assignRoleHandler := createServiceAwareHttpHandler(assignRole, service)
+	assignRole := rest.NewRoute(http.MethodPut, "/:id/roles/:role", assignRoleHandler)
+	out = append(out, assignRole)
+
+	unassignRoleHandler := createServiceAwareHttpHandler(unassignRole, service)
+	unassignRole := rest.NewRoute(http.MethodDelete, "/:id/roles/:role", unassignRoleHandler)
+	out = append(out, unassignRole)
+
+	return out
+}
+
+// createPermission godoc
+//
+// @Summary Create permission
+// @Description Creates a permission which can then be granted to roles. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param permission body communication.PermissionDtoRequest true "Permission payload"
+// @Success 201 {object} rest.ResponseEnvelope[communication.PermissionDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid permission syntax, name or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Permission already exists"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/permissions [post]
+func createPermission(c *echo.Context, s service.RoleService) error {
+	var permissionDtoRequest communication.PermissionDtoRequest
+	err := c.Bind(&permissionDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid permission syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.CreatePermission(c.Request().Context(), apiKey, permissionDtoRequest)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidPermissionName) {
+			return c.JSON(http.StatusBadRequest, "Invalid permission name")
+		}
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return c.JSON(http.StatusConflict, "Permission already exists")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// getPermission godoc
+//
+// @Summary Get permission
+// @Description Returns a permission by its identifier. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Permission ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[communication.PermissionDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such permission"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/permissions/{id} [get]
+func getPermission(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.GetPermission(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such permission")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// listPermissions godoc
+//
+// @Summary List permissions
+// @Description Returns all the permissions, sorted by name. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.PermissionDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/permissions [get]
+func listPermissions(c *echo.Context, s service.RoleService) error {
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListPermissions(c.Request().Context(), apiKey)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// updatePermission godoc
+//
+// @Summary Update permission
+// @Description Renames a permission or changes its description. The roles granting it are updated as well. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Permission ID" Format(uuid)
+// @Param permission body communication.PermissionDtoRequest true "Permission payload"
+// @Success 200 {object} rest.ResponseEnvelope[communication.PermissionDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id, permission syntax, name or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such permission"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Permission already exists"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/permissions/{id} [patch]
+func updatePermission(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	var permissionDtoRequest communication.PermissionDtoRequest
+	err = c.Bind(&permissionDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid permission syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.UpdatePermission(c.Request().Context(), apiKey, id, permissionDtoRequest)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidPermissionName) {
+			return c.JSON(http.StatusBadRequest, "Invalid permission name")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such permission")
+		}
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return c.JSON(http.StatusConflict, "Permission already exists")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// deletePermission godoc
+//
+// @Summary Delete permission
+// @Description Deletes a permission: it is revoked from all the roles granting it. Only available to administrators.
+// @Tags roles
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Permission ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such permission"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/permissions/{id} [delete]
+func deletePermission(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.DeletePermission(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such permission")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// createRole godoc
+//
+// @Summary Create role
+// @Description Creates a role granting the listed permissions. A role can inherit the permissions of a parent role. The names of the built-in roles (user and admin) are reserved. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param role body communication.RoleDtoRequest true "Role payload"
+// @Success 201 {object} rest.ResponseEnvelope[communication.RoleDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid role syntax, name, parent, permission or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Role already exists"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/roles [post]
+func createRole(c *echo.Context, s service.RoleService) error {
+	var roleDtoRequest communication.RoleDtoRequest
+	err := c.Bind(&roleDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid role syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.CreateRole(c.Request().Context(), apiKey, roleDtoRequest)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if message, invalid := tryGetInvalidRoleMessage(err); invalid {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return c.JSON(http.StatusConflict, "Role already exists")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// getRole godoc
+//
+// @Summary Get role
+// @Description Returns a role by its identifier, with the permissions it grants directly. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Role ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[communication.RoleDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such role"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/roles/{id} [get]
+func getRole(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.GetRole(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such role")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// listRoles godoc
+//
+// @Summary List roles
+// @Description Returns all the roles, sorted by name. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.RoleDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/roles [get]
+func listRoles(c *echo.Context, s service.RoleService) error {
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListRoles(c.Request().Context(), apiKey)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// updateRole godoc
+//
+// @Summary Update role
+// @Description Replaces the name, description, parent and permissions of a role. The parent can't inherit from the role. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Role ID" Format(uuid)
+// @Param role body communication.RoleDtoRequest true "Role payload"
+// @Success 200 {object} rest.ResponseEnvelope[communication.RoleDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id, role syntax, name, parent, permission or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such role"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Role already exists or is not up to date"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/roles/{id} [patch]
+func updateRole(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	var roleDtoRequest communication.RoleDtoRequest
+	err = c.Bind(&roleDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid role syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.UpdateRole(c.Request().Context(), apiKey, id, roleDtoRequest)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if message, invalid := tryGetInvalidRoleMessage(err); invalid {
+			return c.JSON(http.StatusBadRequest, message)
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such role")
+		}
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return c.JSON(http.StatusConflict, "Role already exists")
+		}
+		if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+			return c.JSON(http.StatusConflict, "Role is not up to date")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// deleteRole godoc
+//
+// @Summary Delete role
+// @Description Deletes a role: it is unassigned from all users and the roles inheriting from it lose their parent. Only available to administrators.
+// @Tags roles
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "Role ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such role"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/roles/{id} [delete]
+func deleteRole(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.DeleteRole(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such role")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listAssignedRoles godoc
+//
+// @Summary List assigned roles
+// @Description Returns the roles assigned to a user, without the ones they inherit from. Only available to administrators.
+// @Tags roles
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.RoleDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/roles [get]
+func listAssignedRoles(c *echo.Context, s service.RoleService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListAssignedRoles(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such user")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// assignRole godoc
+//
+// @Summary Assign role
+// @Description Assigns a role to a user. Assigning a role twice has no effect. Only available to administrators.
+// @Tags roles
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param role path string true "Role ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user or role"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/roles/{role} [put]
+func assignRole(c *echo.Context, s service.RoleService) error {
+	id, role, err := parseUserAndRoleIds(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.Assign(c.Request().Context(), apiKey, id, role)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such user or role")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// unassignRole godoc
+//
+// @Summary Unassign role
+// @Description Removes a role from the ones assigned to a user. Only available to administrators.
+// @Tags roles
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param role path string true "Role ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "Role is not assigned to the user"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/roles/{role} [delete]
+func unassignRole(c *echo.Context, s service.RoleService) error {
+	id, role, err := parseUserAndRoleIds(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.Unassign(c.Request().Context(), apiKey, id, role)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "Role is not assigned to the user")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func parseUserAndRoleIds(c *echo.Context) (uuid.UUID, uuid.UUID, error) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
+
+	role, err := uuid.Parse(c.Param("role"))
+	if err != nil {
+		return uuid.UUID{}, uuid.UUID{}, err
+	}
+
+	return id, role, nil
+}
+
+func tryGetInvalidRoleMessage(err error) (string, bool) {
+	switch {
+	case errors.IsErrorWithCode(err, service.InvalidRoleName):
+		return "Invalid role name", true
+	case errors.IsErrorWithCode(err, service.InvalidRoleParent):
+		return "Invalid role parent", true
+	case errors.IsErrorWithCode(err, service.UnknownPermission):
+		return "Unknown permission", true
+	default:
+		return "", false
+	}
+}
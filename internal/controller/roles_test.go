@@ -0,0 +1,376 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRoleService struct {
+	service.RoleService
+
+	err error
+
+	id         uuid.UUID
+	user       uuid.UUID
+	role       uuid.UUID
+	permission communication.PermissionDtoRequest
+	roleDto    communication.RoleDtoRequest
+}
+
+var (
+	defaultRoleId     = uuid.MustParse("5f3f7d0e-6a1e-4a4b-9a43-0b0f5bb6d0a2")
+	defaultRoleUserId = uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+)
+
+func TestUnit_RoleController_CreatePermission(t *testing.T) {
+	req := newTestRoleRequest(http.MethodPost, `{"name":"articles:write","description":"Publish articles"}`)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockRoleService{}
+
+	err := createPermission(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "articles:write", m.permission.Name)
+	assert.Equal(t, "Publish articles", m.permission.Description)
+}
+
+func TestUnit_RoleController_CreatePermission_WhenApiKeyIsMissing_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"articles:write"}`))
+	req.Header.Set("Content-Type", "application/json")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockRoleService{}
+
+	err := createPermission(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_RoleController_CreatePermission_WhenCreateFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"notAnAdministrator": {
+			err:            errors.NewCode(service.NotAnAdministrator),
+			expectedStatus: http.StatusForbidden,
+		},
+		"invalidName": {
+			err:            errors.NewCode(service.InvalidPermissionName),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"duplicatedName": {
+			err:            errors.NewCode(pgx.UniqueConstraintViolation),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRoleRequest(http.MethodPost, `{"name":"articles:write"}`)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockRoleService{
+				err: tc.err,
+			}
+
+			err := createPermission(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_RoleController_DeletePermission_WhenPermissionDoesNotExist_ExpectNotFound(t *testing.T) {
+	req := newTestRoleRequest(http.MethodDelete, "")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRoleId.String()}})
+	m := &mockRoleService{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	err := deletePermission(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, defaultRoleId, m.id)
+}
+
+func TestUnit_RoleController_CreateRole(t *testing.T) {
+	body := `{"name":"editor","parent":"` + defaultRoleId.String() + `","permissions":["articles:write"]}`
+	req := newTestRoleRequest(http.MethodPost, body)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockRoleService{}
+
+	err := createRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "editor", m.roleDto.Name)
+	assert.Equal(t, &defaultRoleId, m.roleDto.Parent)
+	assert.Equal(t, []string{"articles:write"}, m.roleDto.Permissions)
+}
+
+func TestUnit_RoleController_CreateRole_WhenBodyIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestRoleRequest(http.MethodPost, `{"name":`)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockRoleService{}
+
+	err := createRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid role syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_RoleController_UpdateRole_WhenIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestRoleRequest(http.MethodPatch, `{"name":"editor"}`)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+	m := &mockRoleService{}
+
+	err := updateRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_RoleController_UpdateRole_WhenUpdateFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err             error
+		expectedStatus  int
+		expectedMessage string
+	}
+
+	testCases := map[string]testCase{
+		"notAnAdministrator": {
+			err:             errors.NewCode(service.NotAnAdministrator),
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "\"Not an administrator\"\n",
+		},
+		"invalidName": {
+			err:             errors.NewCode(service.InvalidRoleName),
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "\"Invalid role name\"\n",
+		},
+		"invalidParent": {
+			err:             errors.NewCode(service.InvalidRoleParent),
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "\"Invalid role parent\"\n",
+		},
+		"unknownPermission": {
+			err:             errors.NewCodeWithDetails(service.UnknownPermission, "articles:write"),
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "\"Unknown permission\"\n",
+		},
+		"noSuchRole": {
+			err:             errors.NewCode(db.NoMatchingRows),
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "\"No such role\"\n",
+		},
+		"duplicatedName": {
+			err:             errors.NewCode(pgx.UniqueConstraintViolation),
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "\"Role already exists\"\n",
+		},
+		"optimisticLock": {
+			err:             errors.NewCode(repositories.OptimisticLockException),
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "\"Role is not up to date\"\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRoleRequest(http.MethodPatch, `{"name":"editor"}`)
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRoleId.String()}})
+			m := &mockRoleService{
+				err: tc.err,
+			}
+
+			err := updateRole(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Equal(t, tc.expectedMessage, rw.Body.String())
+		})
+	}
+}
+
+func TestUnit_RoleController_AssignRole(t *testing.T) {
+	req := newTestRoleRequest(http.MethodPut, "")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultRoleUserId.String()},
+		{Name: "role", Value: defaultRoleId.String()},
+	})
+	m := &mockRoleService{}
+
+	err := assignRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, defaultRoleUserId, m.user)
+	assert.Equal(t, defaultRoleId, m.role)
+}
+
+func TestUnit_RoleController_AssignRole_WhenRoleIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestRoleRequest(http.MethodPut, "")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultRoleUserId.String()},
+		{Name: "role", Value: "not-a-uuid"},
+	})
+	m := &mockRoleService{}
+
+	err := assignRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_RoleController_AssignRole_WhenAssignFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"notAnAdministrator": {
+			err:            errors.NewCode(service.NotAnAdministrator),
+			expectedStatus: http.StatusForbidden,
+		},
+		"noSuchUserOrRole": {
+			err:            errors.NewCode(db.NoMatchingRows),
+			expectedStatus: http.StatusNotFound,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestRoleRequest(http.MethodPut, "")
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{
+				{Name: "id", Value: defaultRoleUserId.String()},
+				{Name: "role", Value: defaultRoleId.String()},
+			})
+			m := &mockRoleService{
+				err: tc.err,
+			}
+
+			err := assignRole(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_RoleController_UnassignRole_WhenRoleIsNotAssigned_ExpectNotFound(t *testing.T) {
+	req := newTestRoleRequest(http.MethodDelete, "")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultRoleUserId.String()},
+		{Name: "role", Value: defaultRoleId.String()},
+	})
+	m := &mockRoleService{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	err := unassignRole(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, defaultRoleUserId, m.user)
+	assert.Equal(t, defaultRoleId, m.role)
+}
+
+func TestUnit_RoleController_ListAssignedRoles_WhenUserDoesNotExist_ExpectNotFound(t *testing.T) {
+	req := newTestRoleRequest(http.MethodGet, "")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultRoleUserId.String()}})
+	m := &mockRoleService{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	err := listAssignedRoles(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "\"No such user\"\n", rw.Body.String())
+}
+
+func newTestRoleRequest(method string, body string) *http.Request {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	return req
+}
+
+func (m *mockRoleService) CreatePermission(ctx context.Context, apiKey apikey.Key, permissionDto communication.PermissionDtoRequest) (communication.PermissionDtoResponse, error) {
+	m.permission = permissionDto
+	return communication.PermissionDtoResponse{}, m.err
+}
+
+func (m *mockRoleService) DeletePermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	m.id = id
+	return m.err
+}
+
+func (m *mockRoleService) CreateRole(ctx context.Context, apiKey apikey.Key, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error) {
+	m.roleDto = roleDto
+	return communication.RoleDtoResponse{}, m.err
+}
+
+func (m *mockRoleService) UpdateRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error) {
+	m.id = id
+	m.roleDto = roleDto
+	return communication.RoleDtoResponse{}, m.err
+}
+
+func (m *mockRoleService) ListAssignedRoles(ctx context.Context, apiKey apikey.Key, user uuid.UUID) ([]communication.RoleDtoResponse, error) {
+	m.user = user
+	return nil, m.err
+}
+
+func (m *mockRoleService) Assign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error {
+	m.user = user
+	m.role = role
+	return m.err
+}
+
+func (m *mockRoleService) Unassign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error {
+	m.user = user
+	m.role = role
+	return m.err
+}
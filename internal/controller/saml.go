@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/labstack/echo/v5"
+)
+
+// samlLoginCookie keeps the relay state in the browser of the user while
+// they sign in at the identity provider of their tenant.
+const samlLoginCookie = "saml_relay_state"
+
+// https://docs.oasis-open.org/security/saml/v2.0/saml-metadata-2.0-os.pdf#page=46
+const samlMetadataContentType = "application/samlmetadata+xml"
+
+func SamlEndpoints(service service.SamlService) rest.Routes {
+	var out rest.Routes
+
+	// Neither the metadata nor the redirection can be wrapped in an envelope.
+	metadataHandler := createServiceAwareHttpHandler(getSamlMetadata, service)
+	metadata := rest.NewRawRoute(http.MethodGet, "/saml/:tenant/metadata", metadataHandler)
+	out = append(out, metadata)
+
+	startHandler := createServiceAwareHttpHandler(startSamlLogin, service)
+	start := rest.NewRawRoute(http.MethodGet, "/saml/:tenant/login", startHandler)
+	out = append(out, start)
+
+	completeHandler := createServiceAwareHttpHandler(completeSamlLogin, service)
+	complete := rest.NewRoute(http.MethodPost, "/saml/:tenant/acs", completeHandler)
+	out = append(out, complete)
+
+	return out
+}
+
+// getSamlMetadata godoc
+//
+// @Summary Get the SAML metadata of a tenant
+// @Description Describes the service provider to the identity provider of the tenant: entity identifier, assertion consumer service and signing certificate.
+// @Tags sessions
+// @Produce xml
+// @Param tenant path string true "Name of the tenant"
+// @Success 200 {string} string "SAML metadata"
+// @Failure 404 "No such tenant"
+// @Failure 500 "Internal server error"
+// @Router /users/saml/{tenant}/metadata [get]
+func getSamlMetadata(c *echo.Context, s service.SamlService) error {
+	out, err := s.Metadata(c.Request().Context(), c.Param("tenant"))
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.UnknownSamlTenant) {
+			return c.JSON(http.StatusNotFound, "No such tenant")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.Blob(http.StatusOK, samlMetadataContentType, out)
+}
+
+// startSamlLogin godoc
+//
+// @Summary Sign in with the identity provider of a tenant
+// @Description Redirects the user to the SAML identity provider of the tenant with a signed authentication request. The provider posts its answer to the assertion consumer service, which must be reached with the cookie set by this endpoint.
+// @Tags sessions
+// @Param tenant path string true "Name of the tenant"
+// @Success 303 "Redirection to the identity provider"
+// @Failure 404 "No such tenant"
+// @Failure 500 "Internal server error"
+// @Router /users/saml/{tenant}/login [get]
+func startSamlLogin(c *echo.Context, s service.SamlService) error {
+	redirect, relayState, err := s.Start(c.Request().Context(), c.Param("tenant"))
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.UnknownSamlTenant) {
+			return c.JSON(http.StatusNotFound, "No such tenant")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	// The provider posts the answer from its own site: a lax policy would
+	// keep the cookie from being sent along.
+	c.SetCookie(&http.Cookie{
+		Name:     samlLoginCookie,
+		Value:    relayState,
+		Path:     strings.TrimSuffix(c.Request().URL.Path, "/login"),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+	c.Response().Header().Set("Cache-Control", "no-store")
+
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// completeSamlLogin godoc
+//
+// @Summary Complete sign in with the identity provider of a tenant
+// @Description Assertion consumer service receiving the answer of the identity provider with the HTTP-POST binding. Opens a session for the user linked to the asserted identity, or returns a challenge to answer at /sessions/mfa when they enabled two-factor authentication. Users seen for the first time are linked to the account with the same email, which must belong to one of the domains of the tenant, or get a new account if the tenant allows it.
+// @Tags sessions
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param tenant path string true "Name of the tenant"
+// @Param SAMLResponse formData string true "Base64 encoded SAML response"
+// @Param RelayState formData string true "Relay state of the login"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Success 202 {object} rest.ResponseEnvelope[communication.MfaChallengeDtoResponse] "A second factor is required"
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid or expired login"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid assertion"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Email outside of the tenant or no account for this identity"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such tenant"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/saml/{tenant}/acs [post]
+func completeSamlLogin(c *echo.Context, s service.SamlService) error {
+	var response communication.SamlResponseDtoRequest
+	err := c.Bind(&response)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid response syntax")
+	}
+
+	var browserState string
+	if cookie, err := c.Cookie(samlLoginCookie); err == nil {
+		browserState = cookie.Value
+	}
+	// The login can only be completed once, whatever the outcome.
+	c.SetCookie(&http.Cookie{
+		Name:     samlLoginCookie,
+		Path:     strings.TrimSuffix(c.Request().URL.Path, "/acs"),
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.Complete(c.Request().Context(), c.Param("tenant"), response, browserState, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.UnknownSamlTenant) {
+			return c.JSON(http.StatusNotFound, "No such tenant")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidSamlLogin) || errors.IsErrorWithCode(err, saml.MalformedResponse) {
+			return c.JSON(http.StatusBadRequest, "Invalid or expired login")
+		}
+		if errors.IsErrorWithCode(err, saml.UnsuccessfulResponse) {
+			return c.JSON(http.StatusUnauthorized, "Sign in failed at the identity provider")
+		}
+		if isInvalidSamlAssertion(err) {
+			return c.JSON(http.StatusUnauthorized, "Invalid assertion")
+		}
+		if errors.IsErrorWithCode(err, service.UnverifiedFederatedEmail) {
+			return c.JSON(http.StatusForbidden, "Email outside of the domains of the tenant")
+		}
+		if errors.IsErrorWithCode(err, service.FederatedSignUpDisabled) {
+			return c.JSON(http.StatusForbidden, "No account for this identity")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusConflict, "Too many sessions")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	if out.Challenge != nil {
+		return c.JSON(http.StatusAccepted, out.Challenge)
+	}
+	return c.JSON(http.StatusCreated, out.Session)
+}
+
+func isInvalidSamlAssertion(err error) bool {
+	return errors.IsErrorWithCode(err, saml.InvalidSignature) ||
+		errors.IsErrorWithCode(err, saml.InvalidAssertion) ||
+		errors.IsErrorWithCode(err, saml.UnsupportedAssertion) ||
+		errors.IsErrorWithCode(err, service.SamlAssertionReplayed) ||
+		errors.IsErrorWithCode(err, service.InvalidEmail)
+}
@@ -0,0 +1,255 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSamlService struct {
+	err error
+
+	tenant       string
+	metadata     []byte
+	redirect     string
+	relayState   string
+	response     communication.SamlResponseDtoRequest
+	browserState string
+	login        communication.LoginDtoResponse
+}
+
+func TestUnit_SamlController_GetSamlMetadata(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/saml/acme/metadata", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "tenant", Value: "acme"}})
+	m := &mockSamlService{
+		metadata: []byte("<EntityDescriptor/>"),
+	}
+
+	err := getSamlMetadata(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "acme", m.tenant)
+	assert.Equal(t, samlMetadataContentType, rw.Header().Get("Content-Type"))
+	assert.Equal(t, "<EntityDescriptor/>", rw.Body.String())
+}
+
+func TestUnit_SamlController_GetSamlMetadata_WhenTenantIsUnknown_ExpectNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/saml/acme/metadata", nil)
+	m := &mockSamlService{
+		err: errors.NewCode(service.UnknownSamlTenant),
+	}
+
+	assertStatusCode[service.SamlService](t, req, m, getSamlMetadata, http.StatusNotFound)
+}
+
+func TestUnit_SamlController_StartSamlLogin(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/saml/acme/login", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "tenant", Value: "acme"}})
+	m := &mockSamlService{
+		redirect:   "https://idp.example.com/sso?SAMLRequest=my-request",
+		relayState: "my-relay-state",
+	}
+
+	err := startSamlLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusSeeOther, rw.Code)
+	assert.Equal(t, m.redirect, rw.Header().Get("Location"))
+	assert.Equal(t, "acme", m.tenant)
+	cookies := rw.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, samlLoginCookie, cookies[0].Name)
+	assert.Equal(t, "my-relay-state", cookies[0].Value)
+	assert.Equal(t, "/v1/users/saml/acme", cookies[0].Path)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteNoneMode, cookies[0].SameSite)
+}
+
+func TestUnit_SamlController_StartSamlLogin_WhenStartFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"unknownTenant": {
+			err:            errors.NewCode(service.UnknownSamlTenant),
+			expectedStatus: http.StatusNotFound,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/users/saml/acme/login", nil)
+			m := &mockSamlService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.SamlService](t, req, m, startSamlLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func TestUnit_SamlController_CompleteSamlLogin(t *testing.T) {
+	req := newTestSamlResponseRequest("my-response", "my-relay-state")
+	req.AddCookie(&http.Cookie{Name: samlLoginCookie, Value: "my-relay-state"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "tenant", Value: "acme"}})
+	m := &mockSamlService{
+		login: communication.LoginDtoResponse{
+			Session: &communication.ApiKeyDtoResponse{
+				User: uuid.New(),
+				Key:  "usk_live_key",
+			},
+		},
+	}
+
+	err := completeSamlLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "acme", m.tenant)
+	assert.Equal(t, "my-response", m.response.SAMLResponse)
+	assert.Equal(t, "my-relay-state", m.response.RelayState)
+	assert.Equal(t, "my-relay-state", m.browserState)
+	cookies := rw.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/v1/users/saml/acme", cookies[0].Path)
+	assert.Less(t, cookies[0].MaxAge, 0)
+}
+
+func TestUnit_SamlController_CompleteSamlLogin_WhenSecondFactorIsRequired_ExpectChallenge(t *testing.T) {
+	req := newTestSamlResponseRequest("my-response", "my-relay-state")
+	req.AddCookie(&http.Cookie{Name: samlLoginCookie, Value: "my-relay-state"})
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "tenant", Value: "acme"}})
+	m := &mockSamlService{
+		login: communication.LoginDtoResponse{
+			Challenge: &communication.MfaChallengeDtoResponse{
+				Challenge: "usm_live_challenge",
+				Methods:   []string{service.TotpMethod},
+			},
+		},
+	}
+
+	err := completeSamlLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+}
+
+func TestUnit_SamlController_CompleteSamlLogin_WhenCompleteFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"unknownTenant": {
+			err:            errors.NewCode(service.UnknownSamlTenant),
+			expectedStatus: http.StatusNotFound,
+		},
+		"invalidLogin": {
+			err:            errors.NewCode(service.InvalidSamlLogin),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"malformedResponse": {
+			err:            errors.NewCode(saml.MalformedResponse),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"unsuccessfulResponse": {
+			err:            errors.NewCode(saml.UnsuccessfulResponse),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalidSignature": {
+			err:            errors.NewCode(saml.InvalidSignature),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalidAssertion": {
+			err:            errors.NewCode(saml.InvalidAssertion),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"replayedAssertion": {
+			err:            errors.NewCode(service.SamlAssertionReplayed),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unverifiedEmail": {
+			err:            errors.NewCode(service.UnverifiedFederatedEmail),
+			expectedStatus: http.StatusForbidden,
+		},
+		"signUpDisabled": {
+			err:            errors.NewCode(service.FederatedSignUpDisabled),
+			expectedStatus: http.StatusForbidden,
+		},
+		"accountLocked": {
+			err:            errors.NewCode(service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestSamlResponseRequest("my-response", "my-relay-state")
+			m := &mockSamlService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.SamlService](t, req, m, completeSamlLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func newTestSamlResponseRequest(response string, relayState string) *http.Request {
+	form := url.Values{}
+	form.Set("SAMLResponse", response)
+	form.Set("RelayState", relayState)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/users/saml/acme/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func (m *mockSamlService) Metadata(ctx context.Context, tenant string) ([]byte, error) {
+	m.tenant = tenant
+	return m.metadata, m.err
+}
+
+func (m *mockSamlService) Start(ctx context.Context, tenant string) (string, string, error) {
+	m.tenant = tenant
+	return m.redirect, m.relayState, m.err
+}
+
+func (m *mockSamlService) Complete(ctx context.Context, tenant string, response communication.SamlResponseDtoRequest, browserState string, client service.ClientInfo) (communication.LoginDtoResponse, error) {
+	m.tenant = tenant
+	m.response = response
+	m.browserState = browserState
+	return m.login, m.err
+}
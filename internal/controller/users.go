@@ -1,12 +1,16 @@
 package controller
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/Knoblauchpilze/user-service/internal/service"
 	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
@@ -14,6 +18,8 @@ import (
 	"github.com/labstack/echo/v5"
 )
 
+const exceptCurrentSession = "current"
+
 func UserEndpoints(service service.UserService) rest.Routes {
 	var out rest.Routes
 
@@ -41,22 +47,62 @@ func UserEndpoints(service service.UserService) rest.Routes {
 	loginByEmail := rest.NewRoute(http.MethodPost, "/sessions", loginByEmailHandler)
 	out = append(out, loginByEmail)
 
+	refreshHandler := createServiceAwareHttpHandler(refreshSession, service)
+	refresh := rest.NewRoute(http.MethodPost, "/sessions/refresh", refreshHandler)
+	out = append(out, refresh)
+
 	logoutHandler := createServiceAwareHttpHandler(logoutUser, service)
 	logout := rest.NewRoute(http.MethodDelete, "/sessions/:id", logoutHandler)
 	out = append(out, logout)
 
+	listLockoutsHandler := createServiceAwareHttpHandler(listLockouts, service)
+	listLockouts := rest.NewRoute(http.MethodGet, "/lockouts", listLockoutsHandler)
+	out = append(out, listLockouts)
+
+	unlockHandler := createServiceAwareHttpHandler(unlockUser, service)
+	unlock := rest.NewRoute(http.MethodDelete, "/:id/lockout", unlockHandler)
+	out = append(out, unlock)
+
+	changePasswordHandler := createServiceAwareHttpHandler(changePassword, service)
+	changePassword := rest.NewRoute(http.MethodPost, "/:id/password", changePasswordHandler)
+	out = append(out, changePassword)
+
+	listSessionsHandler := createServiceAwareHttpHandler(listSessions, service)
+	listSessions := rest.NewRoute(http.MethodGet, "/:id/sessions", listSessionsHandler)
+	out = append(out, listSessions)
+
+	revokeSessionHandler := createServiceAwareHttpHandler(revokeSession, service)
+	revokeSession := rest.NewRoute(http.MethodDelete, "/:id/sessions/:session", revokeSessionHandler)
+	out = append(out, revokeSession)
+
+	revokeSessionsHandler := createServiceAwareHttpHandler(revokeSessions, service)
+	revokeSessions := rest.NewRoute(http.MethodDelete, "/:id/sessions", revokeSessionsHandler)
+	out = append(out, revokeSessions)
+
 	return out
 }
 
+// extractClientIp finds the address of the clients. It is the one of the
+// connection until UseClientIpExtractor trusts the reverse proxies in front
+// of the service.
+var extractClientIp = echo.ExtractIPDirect()
+
+// UseClientIpExtractor replaces how the handlers find the address of the
+// clients. It has to be called before the server starts.
+func UseClientIpExtractor(extractor echo.IPExtractor) {
+	extractClientIp = extractor
+}
+
 // createUser godoc
 //
 // @Summary Create user
-// @Description Creates a user from the provided credentials.
+// @Description Creates a user from the provided credentials. The response is the self view of the user, or the admin view when the caller is an administrator.
 // @Tags users
 // @Produce json
+// @Param X-Api-Key header string false "API key"
 // @Param user body communication.UserDtoRequest true "User payload"
-// @Success 201 {object} rest.ResponseEnvelope[communication.UserDtoResponse]
-// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid user syntax, email, or password"
+// @Success 201 {object} rest.ResponseEnvelope[communication.UserSelfDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[communication.PasswordViolationsDtoResponse] "Invalid user syntax or email (as a string), or list of password policy rules which are not respected"
 // @Failure 409 {object} rest.ResponseEnvelope[string] "Email already in use"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
 // @Router /users [post]
@@ -68,13 +114,22 @@ func createUser(c *echo.Context, s service.UserService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid user syntax")
 	}
 
-	out, err := s.Create(c.Request().Context(), userDtoRequest)
+	view, err := determineUserView(c, s, uuid.Nil)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+	// Whoever creates an account owns it, unless they are an administrator.
+	if view == communication.PublicView {
+		view = communication.SelfView
+	}
+
+	out, err := s.Create(c.Request().Context(), userDtoRequest, view)
 	if err != nil {
 		if errors.IsErrorWithCode(err, service.InvalidEmail) {
 			return c.JSON(http.StatusBadRequest, "Invalid email")
 		}
 		if errors.IsErrorWithCode(err, service.InvalidPassword) {
-			return c.JSON(http.StatusBadRequest, "Invalid password")
+			return c.JSON(http.StatusBadRequest, toPasswordViolationsDtoResponse(err))
 		}
 		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
 			return c.JSON(http.StatusConflict, "Email already in use")
@@ -89,11 +144,12 @@ func createUser(c *echo.Context, s service.UserService) error {
 // getUser godoc
 //
 // @Summary Get user
-// @Description Returns a user by its identifier.
+// @Description Returns a user by its identifier. Callers get the public view of the user, except for the user themselves who get the self view and administrators who get the admin view.
 // @Tags users
 // @Produce json
+// @Param X-Api-Key header string false "API key"
 // @Param id path string true "User ID" Format(uuid)
-// @Success 200 {object} rest.ResponseEnvelope[communication.UserDtoResponse]
+// @Success 200 {object} rest.ResponseEnvelope[communication.UserSelfDtoResponse]
 // @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax"
 // @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
@@ -105,7 +161,12 @@ func getUser(c *echo.Context, s service.UserService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
 	}
 
-	out, err := s.Get(c.Request().Context(), id)
+	view, err := determineUserView(c, s, id)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	out, err := s.Get(c.Request().Context(), id, view)
 	if err != nil {
 		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
 			return c.JSON(http.StatusNotFound, "No such user")
@@ -138,13 +199,15 @@ func listUsers(c *echo.Context, s service.UserService) error {
 // updateUser godoc
 //
 // @Summary Update user
-// @Description Updates a user identified by its identifier.
+// @Description Changes the email of a user identified by its identifier. Only available to the user themselves and to administrators. The password can't be changed here: use the change password endpoint instead. The response follows the same projection rules as the get user endpoint.
 // @Tags users
 // @Produce json
+// @Param X-Api-Key header string true "API key"
 // @Param id path string true "User ID" Format(uuid)
-// @Param user body communication.UserDtoRequest true "User payload"
-// @Success 200 {object} rest.ResponseEnvelope[communication.UserDtoResponse]
-// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id or user syntax"
+// @Param user body communication.UserDtoRequest true "User payload, without the password"
+// @Success 200 {object} rest.ResponseEnvelope[communication.UserSelfDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id, user syntax or email, or password provided"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
 // @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
 // @Failure 409 {object} rest.ResponseEnvelope[string] "User is not up to date"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
@@ -162,11 +225,25 @@ func updateUser(c *echo.Context, s service.UserService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid user syntax")
 	}
 
-	out, err := s.Update(c.Request().Context(), id, userDtoRequest)
+	view, err := determineUserView(c, s, id)
 	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	out, err := s.Update(c.Request().Context(), id, userDtoRequest, view)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
 		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
 			return c.JSON(http.StatusNotFound, "No such user")
 		}
+		if errors.IsErrorWithCode(err, service.InvalidEmail) {
+			return c.JSON(http.StatusBadRequest, "Invalid email")
+		}
+		if errors.IsErrorWithCode(err, service.PasswordNotUpdatable) {
+			return c.JSON(http.StatusBadRequest, "Password can only be changed with the password endpoint")
+		}
 
 		if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
 			return c.JSON(http.StatusConflict, "User is not up to date")
@@ -206,14 +283,18 @@ func deleteUser(c *echo.Context, s service.UserService) error {
 // loginUserByEmail godoc
 //
 // @Summary Create session
-// @Description Authenticates a user with email and password and returns an API key.
+// @Description Authenticates a user with email and password and returns an API key. A recovery code can be given instead of the password: the code can't be used again and the session is marked as requiring a password change. Users who enabled two-factor authentication get a challenge instead, to exchange for the API key with a code at /sessions/mfa. Failed attempts are tracked per email and per client IP: they are delayed with an exponential back-off and the account is locked after too many of them.
 // @Tags sessions
 // @Produce json
 // @Param user body communication.UserDtoRequest true "User credentials"
 // @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Success 202 {object} rest.ResponseEnvelope[communication.MfaChallengeDtoResponse] "A second factor is required"
 // @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid user syntax"
-// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid credentials"
-// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid credentials, including unknown emails"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Email not verified, a new link was sent"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many login attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
 // @Router /users/sessions [post]
 func loginUserByEmail(c *echo.Context, s service.UserService) error {
@@ -223,30 +304,89 @@ func loginUserByEmail(c *echo.Context, s service.UserService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid user syntax")
 	}
 
-	out, err := s.Login(c.Request().Context(), userDtoRequest)
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.Login(c.Request().Context(), userDtoRequest, client)
 	if err != nil {
-		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
-			return c.JSON(http.StatusNotFound, "No such user")
-		}
 		if errors.IsErrorWithCode(err, service.InvalidCredentials) {
 			return c.JSON(http.StatusUnauthorized, "Invalid credentials")
 		}
+		if errors.IsErrorWithCode(err, service.EmailNotVerified) {
+			return c.JSON(http.StatusForbidden, "Email not verified")
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many login attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, service.TooManySessions) {
+			return c.JSON(http.StatusConflict, "Too many sessions")
+		}
 
 		return c.JSON(http.StatusInternalServerError, err)
 	}
 
-	return c.JSON(http.StatusCreated, out)
+	if out.Challenge != nil {
+		return c.JSON(http.StatusAccepted, out.Challenge)
+	}
+	return c.JSON(http.StatusCreated, out.Session)
+}
+
+// refreshSession godoc
+//
+// @Summary Refresh session
+// @Description Exchanges a refresh token for a new API key and a new refresh token. Each refresh token can only be used once: presenting it again revokes the session it belongs to, as it means that it was stolen.
+// @Tags sessions
+// @Produce json
+// @Param token body communication.RefreshTokenDtoRequest true "Refresh token"
+// @Success 200 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid refresh token syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or reused refresh token"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/refresh [post]
+func refreshSession(c *echo.Context, s service.UserService) error {
+	var refreshTokenDtoRequest communication.RefreshTokenDtoRequest
+	err := c.Bind(&refreshTokenDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid refresh token syntax")
+	}
+
+	token, err := apikey.ParseRefreshToken(refreshTokenDtoRequest.RefreshToken)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid refresh token syntax")
+	}
+
+	out, err := s.Refresh(c.Request().Context(), token)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidRefreshToken) {
+			return c.JSON(http.StatusUnauthorized, "Invalid refresh token")
+		}
+		if errors.IsErrorWithCode(err, service.RefreshTokenReused) {
+			return c.JSON(http.StatusUnauthorized, "Refresh token reused")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
 }
 
 // logoutUser godoc
 //
 // @Summary Delete session
-// @Description Revokes the active session for the specified user.
+// @Description Revokes the session identified by the API key attached to the request. When no API key is provided, all the sessions of the user are revoked.
 // @Tags sessions
+// @Param X-Api-Key header string false "API key"
 // @Param id path string true "User ID" Format(uuid)
 // @Success 204
-// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax"
-// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user or session"
 // @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
 // @Router /users/sessions/{id} [delete]
 func logoutUser(c *echo.Context, s service.UserService) error {
@@ -256,8 +396,217 @@ func logoutUser(c *echo.Context, s service.UserService) error {
 		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
 	}
 
-	err = s.Logout(c.Request().Context(), id)
+	if _, ok := c.Request().Header[apiKeyHeaderKey]; ok {
+		// A malformed key should not revoke all the sessions by accident.
+		apiKey, exists := tryGetApiKeyHeader(c.Request())
+		if !exists {
+			return c.JSON(http.StatusBadRequest, "Invalid API key")
+		}
+
+		err = s.LogoutSession(c.Request().Context(), id, apiKey)
+	} else {
+		err = s.Logout(c.Request().Context(), id)
+	}
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such user")
+		}
+		if errors.IsErrorWithCode(err, service.SessionNotFound) {
+			return c.JSON(http.StatusNotFound, "No such session")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listSessions godoc
+//
+// @Summary List sessions
+// @Description Returns the active sessions of a user. Only available to the user themselves and to administrators. The keys of the sessions are never returned.
+// @Tags sessions
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.SessionDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/sessions [get]
+func listSessions(c *echo.Context, s service.UserService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListSessions(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// revokeSession godoc
+//
+// @Summary Revoke session
+// @Description Revokes a single session of a user. Only available to the user themselves and to administrators.
+// @Tags sessions
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param session path string true "Session ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such session"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/sessions/{session} [delete]
+func revokeSession(c *echo.Context, s service.UserService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	maybeSession := c.Param("session")
+	session, err := uuid.Parse(maybeSession)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid session id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.RevokeSession(c.Request().Context(), apiKey, id, session)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.SessionNotFound) {
+			return c.JSON(http.StatusNotFound, "No such session")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// revokeSessions godoc
+//
+// @Summary Revoke sessions
+// @Description Revokes all the sessions of a user. With `except=current`, the session attached to the API key of the request is kept: this allows to log out the other devices. Only available to the user themselves and to administrators.
+// @Tags sessions
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param except query string false "Session to keep" Enums(current)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax, API key or except value"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/sessions [delete]
+func revokeSessions(c *echo.Context, s service.UserService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	except := c.QueryParam("except")
+	if except != "" && except != exceptCurrentSession {
+		return c.JSON(http.StatusBadRequest, "Invalid except value")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.RevokeSessions(c.Request().Context(), apiKey, id, except == exceptCurrentSession)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// listLockouts godoc
+//
+// @Summary List locked accounts
+// @Description Returns the accounts which are currently locked because of too many failed login attempts. Only available to administrators.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.AccountLockoutDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/lockouts [get]
+func listLockouts(c *echo.Context, s service.UserService) error {
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListLockouts(c.Request().Context(), apiKey)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// unlockUser godoc
+//
+// @Summary Unlock user
+// @Description Unlocks the account of a user and forgets its failed login attempts. Only available to administrators.
+// @Tags users
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Not an administrator"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/lockout [delete]
+func unlockUser(c *echo.Context, s service.UserService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.Unlock(c.Request().Context(), apiKey, id)
 	if err != nil {
+		if errors.IsErrorWithCode(err, service.NotAnAdministrator) {
+			return c.JSON(http.StatusForbidden, "Not an administrator")
+		}
 		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
 			return c.JSON(http.StatusNotFound, "No such user")
 		}
@@ -267,3 +616,106 @@ func logoutUser(c *echo.Context, s service.UserService) error {
 
 	return c.NoContent(http.StatusNoContent)
 }
+
+// changePassword godoc
+//
+// @Summary Change password
+// @Description Replaces the password of a user after verifying the current one, which is not needed from the sessions opened with a recovery code. The new password must respect the password policy and can't be one of the most recent passwords of the user. Every other session of the user is revoked: the session attached to the API key of the request is kept. Wrong current passwords are throttled as failed logins. Only available to the user themselves.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param password body communication.PasswordChangeDtoRequest true "Current and new passwords"
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[communication.PasswordViolationsDtoResponse] "Invalid id syntax, API key or password syntax (as a string), or list of password policy rules which are not respected"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid credentials"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such user"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Password was changed concurrently"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/password [post]
+func changePassword(c *echo.Context, s service.UserService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	var passwordDtoRequest communication.PasswordChangeDtoRequest
+	err = c.Bind(&passwordDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid password syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	err = s.ChangePassword(c.Request().Context(), apiKey, id, passwordDtoRequest, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return c.JSON(http.StatusNotFound, "No such user")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidCredentials) {
+			return c.JSON(http.StatusUnauthorized, "Invalid credentials")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidPassword) {
+			return c.JSON(http.StatusBadRequest, toPasswordViolationsDtoResponse(err))
+		}
+		if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Too many attempts")
+		}
+		if errors.IsErrorWithCode(err, service.AccountLocked) {
+			setRetryAfterHeader(c, err)
+			return c.JSON(http.StatusTooManyRequests, "Account locked")
+		}
+		if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+			return c.JSON(http.StatusConflict, "Password was changed concurrently")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func setRetryAfterHeader(c *echo.Context, err error) {
+	delay, ok := service.RetryAfter(err)
+	if !ok {
+		return
+	}
+
+	// https://www.rfc-editor.org/rfc/rfc9110#field.retry-after
+	seconds := int(math.Ceil(delay.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(max(1, seconds)))
+}
+
+func determineUserView(c *echo.Context, s service.UserService, user uuid.UUID) (communication.UserView, error) {
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return communication.PublicView, nil
+	}
+
+	return s.ViewOf(c.Request().Context(), apiKey, user)
+}
+
+func toPasswordViolationsDtoResponse(err error) communication.PasswordViolationsDtoResponse {
+	var rules []string
+	for _, rule := range password.Violations(err) {
+		rules = append(rules, string(rule))
+	}
+
+	return communication.ToPasswordViolationsDtoResponse(rules)
+}
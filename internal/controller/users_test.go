@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,7 +12,11 @@ import (
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
 	eassert "github.com/Knoblauchpilze/easy-assert/assert"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/Knoblauchpilze/user-service/internal/service"
 	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
@@ -23,6 +28,17 @@ import (
 
 type mockUserService struct {
 	service.UserService
+
+	view    communication.UserView
+	viewErr error
+	err     error
+
+	requestedView communication.UserView
+	client        service.ClientInfo
+	keepCurrent   bool
+	challenge     *communication.MfaChallengeDtoResponse
+
+	passwordChange communication.PasswordChangeDtoRequest
 }
 
 func TestUnit_UserController_CreateUser_WhenUserHasWrongSyntax_ExpectBadRequest(t *testing.T) {
@@ -36,7 +52,7 @@ func TestUnit_UserController_CreateUser_WhenUserHasWrongSyntax_ExpectBadRequest(
 
 func TestIT_UserController_Create(t *testing.T) {
 	requestDto := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("my-email-%s", uuid.NewString()),
+		Email:    fmt.Sprintf("my-email-%s@example.com", uuid.NewString()),
 		Password: "my-password",
 	}
 
@@ -53,13 +69,13 @@ func TestIT_UserController_Create(t *testing.T) {
 	err = createUser(ctx, service)
 	assert.Nil(t, err)
 
-	var responseDto communication.UserDtoResponse
+	var responseDto communication.UserSelfDtoResponse
 	err = json.Unmarshal(rw.Body.Bytes(), &responseDto)
 	require.Nil(t, err)
 
 	assert.Equal(t, http.StatusCreated, rw.Code)
 	assert.Equal(t, requestDto.Email, responseDto.Email)
-	assert.Equal(t, requestDto.Password, responseDto.Password)
+	assert.NotContains(t, rw.Body.String(), "password")
 	assertUserExists(t, conn, responseDto.Id)
 }
 
@@ -88,7 +104,7 @@ func TestIT_UserController_Create_WhenEmailIsEmpty_ExpectFailure(t *testing.T) {
 
 func TestIT_UserController_Create_WhenPasswordIsEmpty_ExpectFailure(t *testing.T) {
 	requestDto := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("my-email-%s", uuid.NewString()),
+		Email:    fmt.Sprintf("my-email-%s@example.com", uuid.NewString()),
 		Password: "",
 	}
 
@@ -106,7 +122,7 @@ func TestIT_UserController_Create_WhenPasswordIsEmpty_ExpectFailure(t *testing.T
 	assert.Nil(t, err)
 
 	assert.Equal(t, http.StatusBadRequest, rw.Code)
-	assert.Equal(t, "\"Invalid password\"\n", rw.Body.String())
+	assert.JSONEq(t, `{"violations": ["too_short"]}`, rw.Body.String())
 }
 
 func TestIT_UserController_Create_WhenEmailAlreadyExists_ExpectFailure(t *testing.T) {
@@ -144,11 +160,155 @@ func TestUnit_UserController_GetUser_WhenIdHasWrongSyntax_ExpectBadRequest(t *te
 	assertStatusCodeAndBody[service.UserService](t, req, m, getUser, http.StatusBadRequest, expectedBody)
 }
 
+func TestUnit_UserController_GetUser_WhenNoApiKey_ExpectPublicView(t *testing.T) {
+	id := uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: id.String()}})
+
+	m := &mockUserService{
+		view: communication.SelfView,
+	}
+
+	err := getUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, communication.PublicView, m.requestedView)
+}
+
+func TestUnit_UserController_GetUser_WhenApiKeyProvided_ExpectViewFromService(t *testing.T) {
+	id := uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Api-Key", "e6349328-543b-4b4e-8a3c-4caf7b413589")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: id.String()}})
+
+	m := &mockUserService{
+		view: communication.AdminView,
+	}
+
+	err := getUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, communication.AdminView, m.requestedView)
+}
+
+func TestUnit_UserController_GetUser_WhenViewCannotBeDetermined_ExpectInternalServerError(t *testing.T) {
+	id := uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Api-Key", "e6349328-543b-4b4e-8a3c-4caf7b413589")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: id.String()}})
+
+	m := &mockUserService{
+		viewErr: errors.NewCode(db.NotConnected),
+	}
+
+	err := getUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestUnit_UserController_CreateUser_WhenNoApiKey_ExpectSelfView(t *testing.T) {
+	requestDto := communication.UserDtoRequest{
+		Email:    "some@e.mail",
+		Password: "my-password",
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{}
+
+	err = createUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, communication.SelfView, m.requestedView)
+}
+
+func TestUnit_UserController_CreateUser_WhenPasswordBreaksPolicy_ExpectViolations(t *testing.T) {
+	requestDto := communication.UserDtoRequest{
+		Email:    "some@e.mail",
+		Password: "password",
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+
+	violations := []password.Rule{password.TooShort, password.CommonPassword}
+	m := &mockUserService{
+		err: errors.WrapCode(password.NewPolicyViolationError(violations), service.InvalidPassword),
+	}
+	expectedBody := `
+	{
+		"violations": ["too_short", "common_password"]
+	}`
+
+	assertStatusCodeAndJsonBody[service.UserService](t, req, m, createUser, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_UserController_UpdateUser_WhenUpdateFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err             error
+		expectedStatus  int
+		expectedMessage string
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:             errors.NewCode(service.PermissionDenied),
+			expectedStatus:  http.StatusForbidden,
+			expectedMessage: "\"Permission denied\"\n",
+		},
+		"invalidEmail": {
+			err:             errors.NewCode(service.InvalidEmail),
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "\"Invalid email\"\n",
+		},
+		"passwordNotUpdatable": {
+			err:             errors.NewCode(service.PasswordNotUpdatable),
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "\"Password can only be changed with the password endpoint\"\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"email":"some@e.mail"}`))
+			req.Header.Set("Content-Type", "application/json")
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+			m := &mockUserService{
+				err: tc.err,
+			}
+
+			err := updateUser(ctx, m)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+			assert.Equal(t, tc.expectedMessage, rw.Body.String())
+		})
+	}
+}
+
 func TestIT_UserController_GetUser(t *testing.T) {
 	conn := newTestConnection(t)
 	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(1*time.Hour))
 
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("X-Api-Key", apiKey.Key.String())
 	ctx, rw := generateTestEchoContextFromRequest(req)
 	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
 
@@ -157,14 +317,14 @@ func TestIT_UserController_GetUser(t *testing.T) {
 	err := getUser(ctx, service)
 	assert.Nil(t, err)
 
-	var responseDto communication.UserDtoResponse
+	var responseDto communication.UserSelfDtoResponse
 	err = json.Unmarshal(rw.Body.Bytes(), &responseDto)
 	require.Nil(t, err)
 
 	assert.Equal(t, http.StatusOK, rw.Code)
 	assert.Equal(t, user.Id, responseDto.Id)
 	assert.Equal(t, user.Email, responseDto.Email)
-	assert.Equal(t, user.Password, responseDto.Password)
+	assert.NotContains(t, rw.Body.String(), "password")
 	safetyMargin := 1 * time.Second
 	assert.True(t, eassert.AreTimeCloserThan(user.CreatedAt, responseDto.CreatedAt, safetyMargin))
 }
@@ -236,10 +396,10 @@ func TestUnit_UserController_UpdateUser_WhenUserHasWrongSyntax_ExpectBadRequest(
 func TestIT_UserController_UpdateUser(t *testing.T) {
 	conn := newTestConnection(t)
 	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
 
 	requestDto := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("my-other-email-%s", uuid.NewString()),
-		Password: "my-other-password",
+		Email: fmt.Sprintf("my-other-email-%s@example.com", uuid.NewString()),
 	}
 
 	var body bytes.Buffer
@@ -248,6 +408,7 @@ func TestIT_UserController_UpdateUser(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodPost, "/", &body)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apiKey.Key.String())
 	ctx, rw := generateTestEchoContextFromRequest(req)
 	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
 
@@ -256,24 +417,51 @@ func TestIT_UserController_UpdateUser(t *testing.T) {
 	err = updateUser(ctx, service)
 	assert.Nil(t, err)
 
-	var responseDto communication.UserDtoResponse
+	var responseDto communication.UserPublicDtoResponse
 	err = json.Unmarshal(rw.Body.Bytes(), &responseDto)
 	require.Nil(t, err)
 
 	assert.Equal(t, http.StatusOK, rw.Code)
 	assertEmailForUser(t, conn, user.Id, requestDto.Email)
-	assert.Equal(t, requestDto.Email, responseDto.Email)
-	assert.Equal(t, requestDto.Password, responseDto.Password)
+	assert.Equal(t, user.Id, responseDto.Id)
+	assert.NotContains(t, rw.Body.String(), "password")
+}
+
+func TestIT_UserController_UpdateUser_WhenNoApiKey_ExpectForbidden(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+
+	requestDto := communication.UserDtoRequest{
+		Email: fmt.Sprintf("attacker-%s@example.com", uuid.NewString()),
+	}
+
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
+
+	service, _ := createTestUserService(t)
+
+	err = updateUser(ctx, service)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assertEmailForUser(t, conn, user.Id, user.Email)
 }
 
 func TestIT_UserController_UpdateUser_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
 	// Non-existent id
 	id := uuid.MustParse("00000000-1111-2222-1111-000000000000")
 	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, admin.Id)
 
 	requestDto := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("my-email-%s", uuid.NewString()),
-		Password: "my-new-password",
+		Email: fmt.Sprintf("my-email-%s@example.com", uuid.NewString()),
 	}
 
 	var body bytes.Buffer
@@ -282,10 +470,11 @@ func TestIT_UserController_UpdateUser_WhenUserDoesNotExist_ExpectFailure(t *test
 
 	req := httptest.NewRequest(http.MethodPost, "/", &body)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apiKey.Key.String())
 	ctx, rw := generateTestEchoContextFromRequest(req)
 	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: id.String()}})
 
-	service, _ := createTestUserService(t)
+	service, _ := createTestUserServiceWithAdministrators(t, admin.Id)
 
 	err = updateUser(ctx, service)
 	assert.Nil(t, err)
@@ -401,7 +590,7 @@ func TestIT_UserController_LoginUserByEmail(t *testing.T) {
 
 func TestIT_UserController_LoginUserByEmail_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
 	requestDto := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("some-email-%s", uuid.NewString()),
+		Email:    fmt.Sprintf("some-email-%s@example.com", uuid.NewString()),
 		Password: "my-password",
 	}
 
@@ -418,8 +607,8 @@ func TestIT_UserController_LoginUserByEmail_WhenUserDoesNotExist_ExpectFailure(t
 	err = loginUserByEmail(ctx, service)
 	assert.Nil(t, err)
 
-	assert.Equal(t, http.StatusNotFound, rw.Code)
-	assert.Equal(t, "\"No such user\"\n", rw.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Equal(t, "\"Invalid credentials\"\n", rw.Body.String())
 }
 
 func TestIT_UserController_LoginUserByEmail_WhenPasswordDoesNotMatch_ExpectFailure(t *testing.T) {
@@ -448,6 +637,195 @@ func TestIT_UserController_LoginUserByEmail_WhenPasswordDoesNotMatch_ExpectFailu
 	assert.Equal(t, "\"Invalid credentials\"\n", rw.Body.String())
 }
 
+func TestUnit_UserController_LoginUserByEmail_WhenBehindTrustedProxy_ExpectForwardedClientIp(t *testing.T) {
+	trustTestProxies(t, "172.16.0.0/12")
+	req := newTestLoginRequest(t)
+	req.RemoteAddr = "172.18.0.3:41234"
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7, 172.18.0.4")
+	ctx, _ := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.7", m.client.Ip)
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenForwardedHeaderIsNotTrusted_ExpectRemoteAddress(t *testing.T) {
+	trustTestProxies(t, "172.16.0.0/12")
+	req := newTestLoginRequest(t)
+	req.RemoteAddr = "198.51.100.4:41234"
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+	ctx, _ := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.4", m.client.Ip)
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenNoProxyIsTrusted_ExpectRemoteAddress(t *testing.T) {
+	req := newTestLoginRequest(t)
+	req.RemoteAddr = "172.18.0.3:41234"
+	req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+	ctx, _ := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "172.18.0.3", m.client.Ip)
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenEmailIsNotVerified_ExpectForbidden(t *testing.T) {
+	req := newTestLoginRequest(t)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{
+		err: errors.NewCode(service.EmailNotVerified),
+	}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, "\"Email not verified\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenTooManyAttempts_ExpectTooManyRequests(t *testing.T) {
+	req := newTestLoginRequest(t)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{
+		err: errors.WrapCode(service.NewRetryAfterError(2500*time.Millisecond), service.TooManyLoginAttempts),
+	}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "3", rw.Header().Get("Retry-After"))
+	assert.Equal(t, "\"Too many login attempts\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenAccountIsLocked_ExpectTooManyRequests(t *testing.T) {
+	req := newTestLoginRequest(t)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{
+		err: errors.WrapCode(service.NewRetryAfterError(30*time.Minute), service.AccountLocked),
+	}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1800", rw.Header().Get("Retry-After"))
+	assert.Equal(t, "\"Account locked\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenSecondFactorIsRequired_ExpectAccepted(t *testing.T) {
+	req := newTestLoginRequest(t)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	m := &mockUserService{
+		challenge: &communication.MfaChallengeDtoResponse{
+			Challenge: "usm_live_challenge",
+			Methods:   []string{service.TotpMethod},
+		},
+	}
+
+	err := loginUserByEmail(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Contains(t, rw.Body.String(), "usm_live_challenge")
+}
+
+func TestUnit_UserController_LoginUserByEmail_WhenTooManySessions_ExpectConflict(t *testing.T) {
+	req := newTestLoginRequest(t)
+
+	m := &mockUserService{
+		err: errors.NewCode(service.TooManySessions),
+	}
+	expectedBody := []byte("\"Too many sessions\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, loginUserByEmail, http.StatusConflict, expectedBody)
+}
+
+func TestUnit_UserController_RefreshSession_WhenTokenHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := newTestRefreshRequest(t, "not-a-refresh-token")
+
+	m := &mockUserService{}
+	expectedBody := []byte("\"Invalid refresh token syntax\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, refreshSession, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_UserController_RefreshSession_WhenTokenIsAnApiKey_ExpectBadRequest(t *testing.T) {
+	req := newTestRefreshRequest(t, apikey.Generate().String())
+
+	m := &mockUserService{}
+	expectedBody := []byte("\"Invalid refresh token syntax\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, refreshSession, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_UserController_RefreshSession_WhenTokenIsInvalid_ExpectUnauthorized(t *testing.T) {
+	req := newTestRefreshRequest(t, apikey.GenerateRefreshToken().String())
+
+	m := &mockUserService{
+		err: errors.NewCode(service.InvalidRefreshToken),
+	}
+	expectedBody := []byte("\"Invalid refresh token\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, refreshSession, http.StatusUnauthorized, expectedBody)
+}
+
+func TestUnit_UserController_RefreshSession_WhenTokenIsReused_ExpectUnauthorized(t *testing.T) {
+	req := newTestRefreshRequest(t, apikey.GenerateRefreshToken().String())
+
+	m := &mockUserService{
+		err: errors.NewCode(service.RefreshTokenReused),
+	}
+	expectedBody := []byte("\"Refresh token reused\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, refreshSession, http.StatusUnauthorized, expectedBody)
+}
+
+func TestIT_UserController_RefreshSession(t *testing.T) {
+	client := service.ClientInfo{}
+	service, conn := createTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+	login, err := service.Login(context.Background(), userDtoRequest, client)
+	require.Nil(t, err)
+	require.NotNil(t, login.Session)
+
+	req := newTestRefreshRequest(t, login.Session.RefreshToken)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	err = refreshSession(ctx, service)
+	assert.Nil(t, err)
+
+	var responseDto communication.ApiKeyDtoResponse
+	err = json.Unmarshal(rw.Body.Bytes(), &responseDto)
+	require.Nil(t, err)
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, user.Id, responseDto.User)
+	assert.NotEqual(t, login.Session.Key, responseDto.Key)
+	assert.NotEqual(t, login.Session.RefreshToken, responseDto.RefreshToken)
+	assertApiKeyExistsByKey(t, conn, responseDto.Key)
+}
+
 func TestUnit_UserController_LogoutUser_WhenIdHasWrongSyntax_ExpectBadRequest(t *testing.T) {
 	req := httptest.NewRequest(http.MethodDelete, "/not-a-uuid", nil)
 
@@ -476,6 +854,59 @@ func TestIT_UserController_LogoutUser(t *testing.T) {
 	assertUserExists(t, conn, user.Id)
 }
 
+func TestUnit_UserController_LogoutUser_WhenApiKeyHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/sessions", nil)
+	req.Header.Set(apiKeyHeaderKey, "not-a-key")
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{}
+
+	err := logoutUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_LogoutUser_WhenSessionDoesNotExist_ExpectNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/sessions", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{
+		err: errors.NewCode(service.SessionNotFound),
+	}
+
+	err := logoutUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "\"No such session\"\n", rw.Body.String())
+}
+
+func TestIT_UserController_LogoutUser_WhenApiKeyIsProvided_ExpectOtherSessionsAreKept(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	req := httptest.NewRequest(http.MethodDelete, "/sessions", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey1.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
+
+	service, _ := createTestUserService(t)
+
+	err := logoutUser(ctx, service)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+}
+
 func TestIT_UserController_LogoutUser_WhenNotLoggedIn_ExpectSuccess(t *testing.T) {
 	conn := newTestConnection(t)
 	user := insertTestUser(t, conn)
@@ -510,17 +941,573 @@ func TestIT_UserController_LogoutUser_WhenUserDoesNotExist_ExpectFailure(t *test
 	assert.Equal(t, "\"No such user\"\n", rw.Body.String())
 }
 
-func createTestUserService(t *testing.T) (service.UserService, db.Connection) {
+func TestUnit_UserController_ListSessions_WhenIdHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/not-a-uuid/sessions", nil)
+
+	m := &mockUserService{}
+	expectedBody := []byte("\"Invalid id syntax\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, listSessions, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_UserController_ListSessions_WhenNoApiKey_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	err := listSessions(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_ListSessions_WhenPermissionDenied_ExpectForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{
+		err: errors.NewCode(service.PermissionDenied),
+	}
+
+	err := listSessions(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, "\"Permission denied\"\n", rw.Body.String())
+}
+
+func TestIT_UserController_ListSessions(t *testing.T) {
 	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
+	apiKey2 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
 
-	repos := repositories.Repositories{
-		ApiKey: repositories.NewApiKeyRepository(conn),
-		User:   repositories.NewUserRepository(conn),
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey1.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
+
+	service, _ := createTestUserService(t)
+	err := listSessions(ctx, service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotContains(t, rw.Body.String(), apiKey1.Key.String())
+	assert.NotContains(t, rw.Body.String(), apiKey1.KeyHash)
+	var out []communication.SessionDtoResponse
+	err = json.Unmarshal(rw.Body.Bytes(), &out)
+	require.Nil(t, err)
+	require.Len(t, out, 2)
+	assert.Equal(t, apiKey1.Id, out[0].Id)
+	assert.True(t, out[0].Current)
+	assert.Equal(t, apiKey2.Id, out[1].Id)
+	assert.False(t, out[1].Current)
+}
+
+func TestUnit_UserController_RevokeSession_WhenSessionHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: uuid.NewString()},
+		{Name: "session", Value: "not-a-uuid"},
+	})
+
+	err := revokeSession(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid session id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_RevokeSession_WhenSessionDoesNotExist_ExpectNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: uuid.NewString()},
+		{Name: "session", Value: uuid.NewString()},
+	})
+
+	m := &mockUserService{
+		err: errors.NewCode(service.SessionNotFound),
+	}
+
+	err := revokeSession(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Equal(t, "\"No such session\"\n", rw.Body.String())
+}
+
+func TestIT_UserController_RevokeSession(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
+	apiKey2 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey1.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: user.Id.String()},
+		{Name: "session", Value: apiKey2.Id.String()},
+	})
+
+	service, _ := createTestUserService(t)
+	err := revokeSession(ctx, service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assertApiKeyExists(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestUnit_UserController_RevokeSessions_WhenExceptHasWrongValue_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/?except=all", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	err := revokeSessions(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid except value\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_RevokeSessions_WhenExceptIsCurrent_ExpectCurrentSessionKept(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/?except=current", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{}
+	err := revokeSessions(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.True(t, m.keepCurrent)
+}
+
+func TestUnit_UserController_RevokeSessions_WhenPermissionDenied_ExpectForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{
+		err: errors.NewCode(service.PermissionDenied),
 	}
 
+	err := revokeSessions(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, "\"Permission denied\"\n", rw.Body.String())
+	assert.False(t, m.keepCurrent)
+}
+
+func TestIT_UserController_RevokeSessions_WhenExceptIsCurrent_ExpectOtherSessionsDeleted(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
+	apiKey2 := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Hour))
+
+	req := httptest.NewRequest(http.MethodDelete, "/?except=current", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey1.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: user.Id.String()}})
+
+	service, _ := createTestUserService(t)
+	err := revokeSessions(ctx, service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assertApiKeyExists(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestUnit_UserController_ListLockouts_WhenNoApiKey_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	m := &mockUserService{}
+	expectedBody := []byte("\"Invalid API key\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, listLockouts, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_UserController_ListLockouts_WhenNotAnAdministrator_ExpectForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+
+	m := &mockUserService{
+		err: errors.NewCode(service.NotAnAdministrator),
+	}
+	expectedBody := []byte("\"Not an administrator\"\n")
+
+	assertStatusCodeAndBody[service.UserService](t, req, m, listLockouts, http.StatusForbidden, expectedBody)
+}
+
+func TestIT_UserController_ListLockouts(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUserWithValidity(t, conn, admin.Id, time.Now().Add(time.Hour))
+	locked := insertTestUser(t, conn)
+	lockTestUser(t, conn, locked)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+
+	service, _ := createTestUserServiceWithAdministrators(t, admin.Id)
+	err := listLockouts(ctx, service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	var out []communication.AccountLockoutDtoResponse
+	err = json.Unmarshal(rw.Body.Bytes(), &out)
+	require.Nil(t, err)
+	var users []uuid.UUID
+	for _, lockout := range out {
+		users = append(users, lockout.User)
+	}
+	assert.Contains(t, users, locked.Id)
+}
+
+func TestUnit_UserController_UnlockUser_WhenIdHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+
+	err := unlockUser(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_UnlockUser_WhenNotAnAdministrator_ExpectForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{
+		err: errors.NewCode(service.NotAnAdministrator),
+	}
+
+	err := unlockUser(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+	assert.Equal(t, "\"Not an administrator\"\n", rw.Body.String())
+}
+
+func TestIT_UserController_UnlockUser(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUserWithValidity(t, conn, admin.Id, time.Now().Add(time.Hour))
+	locked := insertTestUser(t, conn)
+	lockTestUser(t, conn, locked)
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apiKey.Key.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: locked.Id.String()}})
+
+	service, _ := createTestUserServiceWithAdministrators(t, admin.Id)
+	err := unlockUser(ctx, service)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(*) FROM login_throttle WHERE kind = 'email' AND key = lower($1)", locked.Email)
+	require.Nil(t, err)
+	assert.Zero(t, value)
+}
+
+func TestUnit_UserController_ChangePassword_WhenIdHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := newTestPasswordChangeRequest(t)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+
+	err := changePassword(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_ChangePassword_WhenPasswordHasWrongSyntax_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not-a-password-change-dto-request"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	err := changePassword(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid password syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_ChangePassword_WhenApiKeyIsMissing_ExpectBadRequest(t *testing.T) {
+	req := newTestPasswordChangeRequest(t)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	err := changePassword(ctx, &mockUserService{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_UserController_ChangePassword_ExpectPasswordsForwardedToService(t *testing.T) {
+	req := newTestPasswordChangeRequest(t)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	m := &mockUserService{}
+	err := changePassword(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, "my-password", m.passwordChange.CurrentPassword)
+	assert.Equal(t, "my-new-password", m.passwordChange.NewPassword)
+}
+
+func TestUnit_UserController_ChangePassword_WhenServiceFails_ExpectCorrectStatus(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"noSuchUser": {
+			err:            errors.NewCode(db.NoMatchingRows),
+			expectedStatus: http.StatusNotFound,
+		},
+		"invalidCredentials": {
+			err:            errors.NewCode(service.InvalidCredentials),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"tooManyAttempts": {
+			err:            errors.NewCode(service.TooManyLoginAttempts),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"accountLocked": {
+			err:            errors.NewCode(service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"concurrentChange": {
+			err:            errors.NewCode(repositories.OptimisticLockException),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestPasswordChangeRequest(t)
+			req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+			m := &mockUserService{
+				err: testCase.err,
+			}
+			err := changePassword(ctx, m)
+
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_UserController_ChangePassword_WhenPasswordIsReused_ExpectViolationReturned(t *testing.T) {
+	req := newTestPasswordChangeRequest(t)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: uuid.NewString()}})
+
+	violations := []password.Rule{password.ReusedPassword}
+	m := &mockUserService{
+		err: errors.WrapCode(password.NewPolicyViolationError(violations), service.InvalidPassword),
+	}
+	err := changePassword(ctx, m)
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	var out communication.PasswordViolationsDtoResponse
+	err = json.Unmarshal(rw.Body.Bytes(), &out)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"reused_password"}, out.Violations)
+}
+
+func newTestLoginRequest(t *testing.T) *http.Request {
+	requestDto := communication.UserDtoRequest{
+		Email:    "some@e.mail",
+		Password: "my-password",
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func newTestRefreshRequest(t *testing.T, token string) *http.Request {
+	requestDto := communication.RefreshTokenDtoRequest{
+		RefreshToken: token,
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func newTestPasswordChangeRequest(t *testing.T) *http.Request {
+	requestDto := communication.PasswordChangeDtoRequest{
+		CurrentPassword: "my-password",
+		NewPassword:     "my-new-password",
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func createTestUserService(t *testing.T) (service.UserService, db.Connection) {
+	return createTestUserServiceWithAdministrators(t)
+}
+
+func createTestUserServiceWithAdministrators(t *testing.T, admins ...uuid.UUID) (service.UserService, db.Connection) {
 	config := service.ApiKeyConfig{
 		Validity: 1 * time.Hour,
 	}
 
-	return service.NewUserService(config, conn, repos), conn
+	return createTestUserServiceWithConfig(t, config, admins...)
+}
+
+func createTestUserServiceWithRefreshTokens(t *testing.T) (service.UserService, db.Connection) {
+	config := service.ApiKeyConfig{
+		Validity:        1 * time.Hour,
+		RefreshValidity: 24 * time.Hour,
+	}
+
+	return createTestUserServiceWithConfig(t, config)
+}
+
+func createTestUserServiceWithConfig(t *testing.T, config service.ApiKeyConfig, admins ...uuid.UUID) (service.UserService, db.Connection) {
+	conn := newTestConnection(t)
+
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	hasher, err := password.NewHasher(passwordTestConfig)
+	require.Nil(t, err)
+	policy, err := password.NewPolicy(passwordTestConfig.Policy)
+	require.Nil(t, err)
+
+	normalizer := email.NewNormalizer(email.Config{})
+
+	adminConfig := service.AdminConfig{
+		Users: admins,
+	}
+
+	return service.NewUserService(config, adminConfig, service.LoginThrottleConfig{}, service.VerificationConfig{}, nil, nil, nil, normalizer, hasher, policy, conn, repos), conn
+}
+
+func (m *mockUserService) ViewOf(ctx context.Context, apiKey apikey.Key, user uuid.UUID) (communication.UserView, error) {
+	return m.view, m.viewErr
+}
+
+func (m *mockUserService) Create(ctx context.Context, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error) {
+	m.requestedView = view
+	return communication.UserSelfDtoResponse{}, m.err
+}
+
+func (m *mockUserService) Get(ctx context.Context, id uuid.UUID, view communication.UserView) (communication.UserDtoResponse, error) {
+	m.requestedView = view
+	return communication.UserPublicDtoResponse{Id: id}, nil
+}
+
+func (m *mockUserService) Update(ctx context.Context, id uuid.UUID, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error) {
+	m.requestedView = view
+	return communication.UserPublicDtoResponse{Id: id}, m.err
+}
+
+func (m *mockUserService) Login(ctx context.Context, userDto communication.UserDtoRequest, client service.ClientInfo) (communication.LoginDtoResponse, error) {
+	m.client = client
+	if m.challenge != nil {
+		return communication.LoginDtoResponse{Challenge: m.challenge}, m.err
+	}
+	return communication.LoginDtoResponse{Session: &communication.ApiKeyDtoResponse{}}, m.err
+}
+
+func (m *mockUserService) Refresh(ctx context.Context, token apikey.RefreshToken) (communication.ApiKeyDtoResponse, error) {
+	return communication.ApiKeyDtoResponse{}, m.err
+}
+
+func (m *mockUserService) LogoutSession(ctx context.Context, id uuid.UUID, apiKey apikey.Key) error {
+	return m.err
+}
+
+func (m *mockUserService) ListSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.SessionDtoResponse, error) {
+	return []communication.SessionDtoResponse{}, m.err
+}
+
+func (m *mockUserService) RevokeSession(ctx context.Context, apiKey apikey.Key, id uuid.UUID, session uuid.UUID) error {
+	return m.err
+}
+
+func (m *mockUserService) RevokeSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID, keepCurrent bool) error {
+	m.keepCurrent = keepCurrent
+	return m.err
+}
+
+func (m *mockUserService) ListLockouts(ctx context.Context, apiKey apikey.Key) ([]communication.AccountLockoutDtoResponse, error) {
+	return []communication.AccountLockoutDtoResponse{}, m.err
+}
+
+func (m *mockUserService) Unlock(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	return m.err
+}
+
+func (m *mockUserService) ChangePassword(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.PasswordChangeDtoRequest, client service.ClientInfo) error {
+	m.passwordChange = request
+	return m.err
 }
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+)
+
+func VerificationEndpoints(service service.VerificationService) rest.Routes {
+	var out rest.Routes
+
+	resendHandler := createServiceAwareHttpHandler(resendVerification, service)
+	resend := rest.NewRoute(http.MethodPost, "/:id/verification", resendHandler)
+	out = append(out, resend)
+
+	confirmHandler := createServiceAwareHttpHandler(confirmVerification, service)
+	confirm := rest.NewRoute(http.MethodPost, "/verification/confirm", confirmHandler)
+	out = append(out, confirm)
+
+	return out
+}
+
+// resendVerification godoc
+//
+// @Summary Resend verification link
+// @Description Sends a new link to the user to verify their email. The links sent previously stop working. Only available to the user themselves.
+// @Tags users
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 202
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Email already verified"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/verification [post]
+func resendVerification(c *echo.Context, s service.VerificationService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.Resend(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.EmailAlreadyVerified) {
+			return c.JSON(http.StatusConflict, "Email already verified")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
+
+// confirmVerification godoc
+//
+// @Summary Confirm email
+// @Description Marks the email of the user as verified with the token they received. Each token can only be used once and only for the email it was sent to.
+// @Tags users
+// @Accept json
+// @Param token body communication.VerificationDtoRequest true "Verification token"
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid token syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired token"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/verification/confirm [post]
+func confirmVerification(c *echo.Context, s service.VerificationService) error {
+	var verificationDtoRequest communication.VerificationDtoRequest
+	err := c.Bind(&verificationDtoRequest)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid token syntax")
+	}
+
+	token, err := apikey.ParseVerificationToken(verificationDtoRequest.Token)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid token syntax")
+	}
+
+	err = s.Confirm(c.Request().Context(), token)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidVerificationToken) {
+			return c.JSON(http.StatusUnauthorized, "Invalid verification token")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
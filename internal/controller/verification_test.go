@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockVerificationService struct {
+	err error
+
+	id    uuid.UUID
+	token apikey.VerificationToken
+}
+
+var defaultVerificationUserId = uuid.MustParse("5c7b1d2e-3f4a-4b6c-9d8e-0f1a2b3c4d5e")
+
+func TestUnit_VerificationController_ResendVerification(t *testing.T) {
+	req := newTestResendVerificationRequest()
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultVerificationUserId.String()}})
+	m := &mockVerificationService{}
+
+	err := resendVerification(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, rw.Code)
+	assert.Equal(t, defaultVerificationUserId, m.id)
+}
+
+func TestUnit_VerificationController_ResendVerification_WhenIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestResendVerificationRequest()
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+	m := &mockVerificationService{}
+
+	err := resendVerification(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid id syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_VerificationController_ResendVerification_WhenApiKeyIsMissing_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultVerificationUserId.String()}})
+	m := &mockVerificationService{}
+
+	err := resendVerification(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid API key\"\n", rw.Body.String())
+}
+
+func TestUnit_VerificationController_ResendVerification_WhenResendFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"alreadyVerified": {
+			err:            errors.NewCode(service.EmailAlreadyVerified),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestResendVerificationRequest()
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultVerificationUserId.String()}})
+			m := &mockVerificationService{
+				err: tc.err,
+			}
+
+			err := resendVerification(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_VerificationController_ConfirmVerification(t *testing.T) {
+	token := apikey.GenerateVerificationToken()
+	req := newTestConfirmVerificationRequest(t, token.String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockVerificationService{}
+
+	err := confirmVerification(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, token, m.token)
+}
+
+func TestUnit_VerificationController_ConfirmVerification_WhenTokenIsMalformed_ExpectBadRequest(t *testing.T) {
+	req := newTestConfirmVerificationRequest(t, apikey.GenerateRefreshToken().String())
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockVerificationService{}
+
+	err := confirmVerification(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "\"Invalid token syntax\"\n", rw.Body.String())
+}
+
+func TestUnit_VerificationController_ConfirmVerification_WhenConfirmFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidToken": {
+			err:            errors.NewCode(service.InvalidVerificationToken),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestConfirmVerificationRequest(t, apikey.GenerateVerificationToken().String())
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			m := &mockVerificationService{
+				err: tc.err,
+			}
+
+			err := confirmVerification(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func newTestResendVerificationRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	return req
+}
+
+func newTestConfirmVerificationRequest(t *testing.T, token string) *http.Request {
+	requestDto := communication.VerificationDtoRequest{
+		Token: token,
+	}
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(requestDto)
+	require.Nil(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func (m *mockVerificationService) Resend(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	m.id = id
+	return m.err
+}
+
+func (m *mockVerificationService) Confirm(ctx context.Context, token apikey.VerificationToken) error {
+	m.token = token
+	return m.err
+}
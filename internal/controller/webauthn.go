@@ -0,0 +1,378 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/rest"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+)
+
+func WebAuthnEndpoints(service service.WebAuthnService) rest.Routes {
+	var out rest.Routes
+
+	startRegistrationHandler := createServiceAwareHttpHandler(startWebAuthnRegistration, service)
+	startRegistration := rest.NewRoute(http.MethodPost, "/:id/webauthn/registration", startRegistrationHandler)
+	out = append(out, startRegistration)
+
+	finishRegistrationHandler := createServiceAwareHttpHandler(finishWebAuthnRegistration, service)
+	finishRegistration := rest.NewRoute(http.MethodPost, "/:id/webauthn/credentials", finishRegistrationHandler)
+	out = append(out, finishRegistration)
+
+	listHandler := createServiceAwareHttpHandler(listWebAuthnCredentials, service)
+	list := rest.NewRoute(http.MethodGet, "/:id/webauthn/credentials", listHandler)
+	out = append(out, list)
+
+	deleteHandler := createServiceAwareHttpHandler(deleteWebAuthnCredential, service)
+	delete := rest.NewRoute(http.MethodDelete, "/:id/webauthn/credentials/:credential", deleteHandler)
+	out = append(out, delete)
+
+	startLoginHandler := createServiceAwareHttpHandler(startWebAuthnLogin, service)
+	startLogin := rest.NewRoute(http.MethodPost, "/sessions/webauthn/options", startLoginHandler)
+	out = append(out, startLogin)
+
+	finishLoginHandler := createServiceAwareHttpHandler(finishWebAuthnLogin, service)
+	finishLogin := rest.NewRoute(http.MethodPost, "/sessions/webauthn", finishLoginHandler)
+	out = append(out, finishLogin)
+
+	startSecondFactorHandler := createServiceAwareHttpHandler(startWebAuthnSecondFactor, service)
+	startSecondFactor := rest.NewRoute(http.MethodPost, "/sessions/mfa/webauthn/options", startSecondFactorHandler)
+	out = append(out, startSecondFactor)
+
+	completeSecondFactorHandler := createServiceAwareHttpHandler(completeWebAuthnSecondFactor, service)
+	completeSecondFactor := rest.NewRoute(http.MethodPost, "/sessions/mfa/webauthn", completeSecondFactorHandler)
+	out = append(out, completeSecondFactor)
+
+	return out
+}
+
+// startWebAuthnRegistration godoc
+//
+// @Summary Start registering a passkey
+// @Description Returns the options to create a passkey with in the browser. Only available to the user themselves. The options expire after a few minutes.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[communication.WebAuthnCreationOptionsDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/webauthn/registration [post]
+func startWebAuthnRegistration(c *echo.Context, s service.WebAuthnService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.StartRegistration(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// finishWebAuthnRegistration godoc
+//
+// @Summary Register a passkey
+// @Description Stores the passkey created by the browser with the options of the registration. Only available to the user themselves. The passkey can then be used to log in without a password, and is asked for after the password otherwise.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param request body communication.WebAuthnRegistrationDtoRequest true "Name and credential created by the browser"
+// @Success 201 {object} rest.ResponseEnvelope[communication.WebAuthnCredentialDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax, API key, request or credential, or expired registration"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Passkey already registered"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/webauthn/credentials [post]
+func finishWebAuthnRegistration(c *echo.Context, s service.WebAuthnService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	var request communication.WebAuthnRegistrationDtoRequest
+	err = c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	out, err := s.FinishRegistration(c.Request().Context(), apiKey, id, request)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidWebAuthnCeremony) {
+			return c.JSON(http.StatusBadRequest, "Invalid or expired registration")
+		}
+		if errors.IsErrorWithCode(err, service.InvalidWebAuthnCredential) {
+			return c.JSON(http.StatusBadRequest, "Invalid credential")
+		}
+		if errors.IsErrorWithCode(err, service.WebAuthnCredentialAlreadyRegistered) {
+			return c.JSON(http.StatusConflict, "Passkey already registered")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// listWebAuthnCredentials godoc
+//
+// @Summary List passkeys
+// @Description Returns the passkeys registered by a user. Only available to the user themselves.
+// @Tags users
+// @Produce json
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Success 200 {object} rest.ResponseEnvelope[[]communication.WebAuthnCredentialDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/webauthn/credentials [get]
+func listWebAuthnCredentials(c *echo.Context, s service.WebAuthnService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	out, err := s.ListCredentials(c.Request().Context(), apiKey, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// deleteWebAuthnCredential godoc
+//
+// @Summary Remove a passkey
+// @Description Removes a passkey of a user: it can no longer be used to log in. Only available to the user themselves.
+// @Tags users
+// @Param X-Api-Key header string true "API key"
+// @Param id path string true "User ID" Format(uuid)
+// @Param credential path string true "Passkey ID" Format(uuid)
+// @Success 204
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid id syntax or API key"
+// @Failure 403 {object} rest.ResponseEnvelope[string] "Permission denied"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No such passkey"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/{id}/webauthn/credentials/{credential} [delete]
+func deleteWebAuthnCredential(c *echo.Context, s service.WebAuthnService) error {
+	maybeId := c.Param("id")
+	id, err := uuid.Parse(maybeId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid id syntax")
+	}
+
+	maybeCredential := c.Param("credential")
+	credential, err := uuid.Parse(maybeCredential)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid passkey id syntax")
+	}
+
+	apiKey, exists := tryGetApiKeyHeader(c.Request())
+	if !exists {
+		return c.JSON(http.StatusBadRequest, "Invalid API key")
+	}
+
+	err = s.DeleteCredential(c.Request().Context(), apiKey, id, credential)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.PermissionDenied) {
+			return c.JSON(http.StatusForbidden, "Permission denied")
+		}
+		if errors.IsErrorWithCode(err, service.UnknownWebAuthnCredential) {
+			return c.JSON(http.StatusNotFound, "No such passkey")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// startWebAuthnLogin godoc
+//
+// @Summary Start logging in with a passkey
+// @Description Returns the options to log in with a passkey instead of a password. The browser offers the passkeys it holds for the service. The options expire after a few minutes.
+// @Tags sessions
+// @Produce json
+// @Success 200 {object} rest.ResponseEnvelope[communication.WebAuthnRequestOptionsDtoResponse]
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/webauthn/options [post]
+func startWebAuthnLogin(c *echo.Context, s service.WebAuthnService) error {
+	out, err := s.StartLogin(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// finishWebAuthnLogin godoc
+//
+// @Summary Log in with a passkey
+// @Description Exchanges the answer of the browser to the login options for an API key. The passkey must verify the user, for example with a fingerprint or a PIN: no second factor is asked for.
+// @Tags sessions
+// @Produce json
+// @Param request body communication.WebAuthnAssertionDtoRequest true "Credential returned by the browser"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired login, or invalid or unknown passkey"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many login attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/webauthn [post]
+func finishWebAuthnLogin(c *echo.Context, s service.WebAuthnService) error {
+	var request communication.WebAuthnAssertionDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.FinishLogin(c.Request().Context(), request, client)
+	if err != nil {
+		return webAuthnLoginError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// startWebAuthnSecondFactor godoc
+//
+// @Summary Start answering a challenge with a passkey
+// @Description Returns the options to answer the challenge returned by the login of a user who registered passkeys with one of them. The options expire after a few minutes.
+// @Tags sessions
+// @Produce json
+// @Param request body communication.MfaChallengeDtoRequest true "Challenge"
+// @Success 200 {object} rest.ResponseEnvelope[communication.WebAuthnRequestOptionsDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired challenge"
+// @Failure 404 {object} rest.ResponseEnvelope[string] "No passkey registered"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/mfa/webauthn/options [post]
+func startWebAuthnSecondFactor(c *echo.Context, s service.WebAuthnService) error {
+	var request communication.MfaChallengeDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	out, err := s.StartSecondFactor(c.Request().Context(), request)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidMfaChallenge) {
+			return c.JSON(http.StatusUnauthorized, "Invalid challenge")
+		}
+		if errors.IsErrorWithCode(err, service.UnknownWebAuthnCredential) {
+			return c.JSON(http.StatusNotFound, "No passkey registered")
+		}
+
+		return c.JSON(http.StatusInternalServerError, err)
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
+// completeWebAuthnSecondFactor godoc
+//
+// @Summary Complete login with a passkey
+// @Description Exchanges the challenge returned by the login of a user who registered passkeys and the answer of the browser to the options of the challenge for an API key. Invalid answers count as failed login attempts, and the challenge is dropped after a few of them.
+// @Tags sessions
+// @Produce json
+// @Param request body communication.WebAuthnMfaDtoRequest true "Challenge and credential returned by the browser"
+// @Success 201 {object} rest.ResponseEnvelope[communication.ApiKeyDtoResponse]
+// @Failure 400 {object} rest.ResponseEnvelope[string] "Invalid request syntax"
+// @Failure 401 {object} rest.ResponseEnvelope[string] "Invalid or expired challenge, or invalid or unknown passkey"
+// @Failure 409 {object} rest.ResponseEnvelope[string] "Too many sessions"
+// @Failure 429 {object} rest.ResponseEnvelope[string] "Too many login attempts or account locked"
+// @Header 429 {integer} Retry-After "Number of seconds to wait before trying again"
+// @Failure 500 {object} rest.ResponseEnvelope[string] "Internal server error"
+// @Router /users/sessions/mfa/webauthn [post]
+func completeWebAuthnSecondFactor(c *echo.Context, s service.WebAuthnService) error {
+	var request communication.WebAuthnMfaDtoRequest
+	err := c.Bind(&request)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "Invalid request syntax")
+	}
+
+	client := service.ClientInfo{
+		Ip:        extractClientIp(c.Request()),
+		UserAgent: c.Request().UserAgent(),
+	}
+
+	out, err := s.CompleteSecondFactor(c.Request().Context(), request, client)
+	if err != nil {
+		if errors.IsErrorWithCode(err, service.InvalidMfaChallenge) {
+			return c.JSON(http.StatusUnauthorized, "Invalid challenge")
+		}
+		return webAuthnLoginError(c, err)
+	}
+
+	return c.JSON(http.StatusCreated, out)
+}
+
+// webAuthnLoginError answers the failures shared by the logins with a
+// passkey.
+func webAuthnLoginError(c *echo.Context, err error) error {
+	if errors.IsErrorWithCode(err, service.InvalidWebAuthnCeremony) {
+		return c.JSON(http.StatusUnauthorized, "Invalid or expired login")
+	}
+	if errors.IsErrorWithCode(err, service.InvalidWebAuthnCredential) {
+		return c.JSON(http.StatusUnauthorized, "Invalid passkey")
+	}
+	if errors.IsErrorWithCode(err, service.UnknownWebAuthnCredential) {
+		return c.JSON(http.StatusUnauthorized, "Unknown passkey")
+	}
+	if errors.IsErrorWithCode(err, service.TooManyLoginAttempts) {
+		setRetryAfterHeader(c, err)
+		return c.JSON(http.StatusTooManyRequests, "Too many login attempts")
+	}
+	if errors.IsErrorWithCode(err, service.AccountLocked) {
+		setRetryAfterHeader(c, err)
+		return c.JSON(http.StatusTooManyRequests, "Account locked")
+	}
+	if errors.IsErrorWithCode(err, service.TooManySessions) {
+		return c.JSON(http.StatusConflict, "Too many sessions")
+	}
+
+	return c.JSON(http.StatusInternalServerError, err)
+}
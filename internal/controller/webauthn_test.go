@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/service"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWebAuthnService struct {
+	err error
+
+	id           uuid.UUID
+	credentialId uuid.UUID
+	registration communication.WebAuthnRegistrationDtoRequest
+	assertion    communication.WebAuthnAssertionDtoRequest
+	mfa          communication.WebAuthnMfaDtoRequest
+	client       service.ClientInfo
+
+	creationOptions communication.WebAuthnCreationOptionsDtoResponse
+	requestOptions  communication.WebAuthnRequestOptionsDtoResponse
+	credential      communication.WebAuthnCredentialDtoResponse
+	credentials     []communication.WebAuthnCredentialDtoResponse
+	apiKey          communication.ApiKeyDtoResponse
+}
+
+var defaultWebAuthnCredentialId = uuid.MustParse("1d9c3f8e-5a3b-4c52-9f0e-0b7e4f6f2c11")
+
+func TestUnit_WebAuthnController_StartWebAuthnRegistration(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodPost, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockWebAuthnService{
+		creationOptions: communication.WebAuthnCreationOptionsDtoResponse{
+			Challenge: "my-challenge",
+		},
+	}
+
+	err := startWebAuthnRegistration(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Contains(t, rw.Body.String(), "my-challenge")
+}
+
+func TestUnit_WebAuthnController_StartWebAuthnRegistration_WhenIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodPost, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: "not-a-uuid"}})
+	m := &mockWebAuthnService{}
+
+	err := startWebAuthnRegistration(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestUnit_WebAuthnController_FinishWebAuthnRegistration(t *testing.T) {
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Name: "my-passkey",
+		Credential: communication.WebAuthnAttestationDto{
+			Id:   "AQID",
+			Type: "public-key",
+		},
+	}
+	req := newTestWebAuthnRequest(t, http.MethodPost, request)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockWebAuthnService{
+		credential: communication.WebAuthnCredentialDtoResponse{
+			Id:   defaultWebAuthnCredentialId,
+			Name: "my-passkey",
+		},
+	}
+
+	err := finishWebAuthnRegistration(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Equal(t, request, m.registration)
+	assert.Contains(t, rw.Body.String(), defaultWebAuthnCredentialId.String())
+}
+
+func TestUnit_WebAuthnController_FinishWebAuthnRegistration_WhenFinishFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"permissionDenied": {
+			err:            errors.NewCode(service.PermissionDenied),
+			expectedStatus: http.StatusForbidden,
+		},
+		"invalidCeremony": {
+			err:            errors.NewCode(service.InvalidWebAuthnCeremony),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalidCredential": {
+			err:            errors.NewCode(service.InvalidWebAuthnCredential),
+			expectedStatus: http.StatusBadRequest,
+		},
+		"alreadyRegistered": {
+			err:            errors.NewCode(service.WebAuthnCredentialAlreadyRegistered),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestWebAuthnRequest(t, http.MethodPost, communication.WebAuthnRegistrationDtoRequest{})
+			ctx, rw := generateTestEchoContextFromRequest(req)
+			ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+			m := &mockWebAuthnService{
+				err: tc.err,
+			}
+
+			err := finishWebAuthnRegistration(ctx, m)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expectedStatus, rw.Code)
+		})
+	}
+}
+
+func TestUnit_WebAuthnController_ListWebAuthnCredentials(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodGet, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{{Name: "id", Value: defaultMfaUserId.String()}})
+	m := &mockWebAuthnService{
+		credentials: []communication.WebAuthnCredentialDtoResponse{
+			{
+				Id:   defaultWebAuthnCredentialId,
+				Name: "my-passkey",
+			},
+		},
+	}
+
+	err := listWebAuthnCredentials(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Contains(t, rw.Body.String(), "my-passkey")
+}
+
+func TestUnit_WebAuthnController_DeleteWebAuthnCredential(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodDelete, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultMfaUserId.String()},
+		{Name: "credential", Value: defaultWebAuthnCredentialId.String()},
+	})
+	m := &mockWebAuthnService{}
+
+	err := deleteWebAuthnCredential(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Equal(t, defaultMfaUserId, m.id)
+	assert.Equal(t, defaultWebAuthnCredentialId, m.credentialId)
+}
+
+func TestUnit_WebAuthnController_DeleteWebAuthnCredential_WhenCredentialIdIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodDelete, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultMfaUserId.String()},
+		{Name: "credential", Value: "not-a-uuid"},
+	})
+	m := &mockWebAuthnService{}
+
+	err := deleteWebAuthnCredential(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+}
+
+func TestUnit_WebAuthnController_DeleteWebAuthnCredential_WhenCredentialDoesNotExist_ExpectNotFound(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodDelete, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	ctx.SetPathValues([]echo.PathValue{
+		{Name: "id", Value: defaultMfaUserId.String()},
+		{Name: "credential", Value: defaultWebAuthnCredentialId.String()},
+	})
+	m := &mockWebAuthnService{
+		err: errors.NewCode(service.UnknownWebAuthnCredential),
+	}
+
+	err := deleteWebAuthnCredential(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+func TestUnit_WebAuthnController_StartWebAuthnLogin(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodPost, nil)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockWebAuthnService{
+		requestOptions: communication.WebAuthnRequestOptionsDtoResponse{
+			Challenge: "my-challenge",
+		},
+	}
+
+	err := startWebAuthnLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "my-challenge")
+}
+
+func TestUnit_WebAuthnController_FinishWebAuthnLogin(t *testing.T) {
+	request := communication.WebAuthnAssertionDtoRequest{
+		Id:   "AQID",
+		Type: "public-key",
+	}
+	req := newTestWebAuthnRequest(t, http.MethodPost, request)
+	req.RemoteAddr = "198.51.100.4:41234"
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockWebAuthnService{
+		apiKey: communication.ApiKeyDtoResponse{
+			User: defaultMfaUserId,
+			Key:  "usk_live_key",
+		},
+	}
+
+	err := finishWebAuthnLogin(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, request, m.assertion)
+	assert.Equal(t, "198.51.100.4", m.client.Ip)
+}
+
+func TestUnit_WebAuthnController_FinishWebAuthnLogin_WhenBodyIsInvalid_ExpectBadRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not-a-json"))
+	req.Header.Set("Content-Type", "application/json")
+	m := &mockWebAuthnService{}
+	expectedBody := []byte("\"Invalid request syntax\"\n")
+
+	assertStatusCodeAndBody[service.WebAuthnService](t, req, m, finishWebAuthnLogin, http.StatusBadRequest, expectedBody)
+}
+
+func TestUnit_WebAuthnController_FinishWebAuthnLogin_WhenFinishFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidCeremony": {
+			err:            errors.NewCode(service.InvalidWebAuthnCeremony),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"invalidCredential": {
+			err:            errors.NewCode(service.InvalidWebAuthnCredential),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"unknownCredential": {
+			err:            errors.NewCode(service.UnknownWebAuthnCredential),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"tooManyAttempts": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Second), service.TooManyLoginAttempts),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"accountLocked": {
+			err:            errors.WrapCode(service.NewRetryAfterError(time.Minute), service.AccountLocked),
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		"tooManySessions": {
+			err:            errors.NewCode(service.TooManySessions),
+			expectedStatus: http.StatusConflict,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestWebAuthnRequest(t, http.MethodPost, communication.WebAuthnAssertionDtoRequest{})
+			m := &mockWebAuthnService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.WebAuthnService](t, req, m, finishWebAuthnLogin, tc.expectedStatus)
+		})
+	}
+}
+
+func TestUnit_WebAuthnController_StartWebAuthnSecondFactor_WhenStartFails_ExpectError(t *testing.T) {
+	type testCase struct {
+		err            error
+		expectedStatus int
+	}
+
+	testCases := map[string]testCase{
+		"invalidChallenge": {
+			err:            errors.NewCode(service.InvalidMfaChallenge),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"noPasskey": {
+			err:            errors.NewCode(service.UnknownWebAuthnCredential),
+			expectedStatus: http.StatusNotFound,
+		},
+		"internalError": {
+			err:            errors.New("failure"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req := newTestWebAuthnRequest(t, http.MethodPost, communication.MfaChallengeDtoRequest{Challenge: "usm_live_challenge"})
+			m := &mockWebAuthnService{
+				err: tc.err,
+			}
+
+			assertStatusCode[service.WebAuthnService](t, req, m, startWebAuthnSecondFactor, tc.expectedStatus)
+		})
+	}
+}
+
+func TestUnit_WebAuthnController_CompleteWebAuthnSecondFactor(t *testing.T) {
+	request := communication.WebAuthnMfaDtoRequest{
+		Challenge: "usm_live_challenge",
+		Credential: communication.WebAuthnAssertionDtoRequest{
+			Id:   "AQID",
+			Type: "public-key",
+		},
+	}
+	req := newTestWebAuthnRequest(t, http.MethodPost, request)
+	ctx, rw := generateTestEchoContextFromRequest(req)
+	m := &mockWebAuthnService{}
+
+	err := completeWebAuthnSecondFactor(ctx, m)
+
+	require.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, request, m.mfa)
+}
+
+func TestUnit_WebAuthnController_CompleteWebAuthnSecondFactor_WhenChallengeIsInvalid_ExpectUnauthorized(t *testing.T) {
+	req := newTestWebAuthnRequest(t, http.MethodPost, communication.WebAuthnMfaDtoRequest{})
+	m := &mockWebAuthnService{
+		err: errors.NewCode(service.InvalidMfaChallenge),
+	}
+
+	assertStatusCode[service.WebAuthnService](t, req, m, completeWebAuthnSecondFactor, http.StatusUnauthorized)
+}
+
+func newTestWebAuthnRequest(t *testing.T, method string, requestDto any) *http.Request {
+	var body bytes.Buffer
+	if requestDto != nil {
+		err := json.NewEncoder(&body).Encode(requestDto)
+		require.Nil(t, err)
+	}
+
+	req := httptest.NewRequest(method, "/", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(apiKeyHeaderKey, apikey.Generate().String())
+	return req
+}
+
+func (m *mockWebAuthnService) StartRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.WebAuthnCreationOptionsDtoResponse, error) {
+	m.id = id
+	return m.creationOptions, m.err
+}
+
+func (m *mockWebAuthnService) FinishRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.WebAuthnRegistrationDtoRequest) (communication.WebAuthnCredentialDtoResponse, error) {
+	m.id = id
+	m.registration = request
+	return m.credential, m.err
+}
+
+func (m *mockWebAuthnService) ListCredentials(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.WebAuthnCredentialDtoResponse, error) {
+	m.id = id
+	return m.credentials, m.err
+}
+
+func (m *mockWebAuthnService) DeleteCredential(ctx context.Context, apiKey apikey.Key, id uuid.UUID, credential uuid.UUID) error {
+	m.id = id
+	m.credentialId = credential
+	return m.err
+}
+
+func (m *mockWebAuthnService) StartLogin(ctx context.Context) (communication.WebAuthnRequestOptionsDtoResponse, error) {
+	return m.requestOptions, m.err
+}
+
+func (m *mockWebAuthnService) FinishLogin(ctx context.Context, assertion communication.WebAuthnAssertionDtoRequest, client service.ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	m.assertion = assertion
+	m.client = client
+	return m.apiKey, m.err
+}
+
+func (m *mockWebAuthnService) StartSecondFactor(ctx context.Context, request communication.MfaChallengeDtoRequest) (communication.WebAuthnRequestOptionsDtoResponse, error) {
+	return m.requestOptions, m.err
+}
+
+func (m *mockWebAuthnService) CompleteSecondFactor(ctx context.Context, request communication.WebAuthnMfaDtoRequest, client service.ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	m.mfa = request
+	m.client = client
+	return m.apiKey, m.err
+}
@@ -0,0 +1,8 @@
+package email
+
+type Config struct {
+	// LowercaseLocalPart controls whether the local part of the addresses
+	// is lowercased. RFC 5321 says it is case-sensitive but virtually no
+	// mail provider treats it this way.
+	LowercaseLocalPart bool
+}
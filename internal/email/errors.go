@@ -0,0 +1,11 @@
+package email
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidSyntax  errors.ErrorCode = 1200
+	InvalidDomain  errors.ErrorCode = 1201
+	AddressTooLong errors.ErrorCode = 1202
+)
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc5321#section-4.5.3.1
+const (
+	maxLocalPartLength = 64
+	maxAddressLength   = 254
+)
+
+// https://www.unicode.org/reports/tr46/#Processing
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+	idna.StrictDomainName(true),
+)
+
+type Normalizer interface {
+	// Normalize validates the address and returns its canonical form:
+	// surrounding spaces, angle brackets and comments are removed and
+	// the domain is converted to its lowercase ASCII (punycode) form.
+	Normalize(address string) (string, error)
+}
+
+type normalizerImpl struct {
+	lowercaseLocalPart bool
+}
+
+func NewNormalizer(config Config) Normalizer {
+	return &normalizerImpl{
+		lowercaseLocalPart: config.LowercaseLocalPart,
+	}
+}
+
+func (n *normalizerImpl) Normalize(address string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil {
+		return "", errors.WrapCode(err, InvalidSyntax)
+	}
+	if parsed.Name != "" {
+		return "", errors.NewCodeWithDetails(InvalidSyntax, "display names are not allowed")
+	}
+
+	// The string representation quotes the local part when needed.
+	addrSpec := strings.TrimSuffix(strings.TrimPrefix(parsed.String(), "<"), ">")
+	at := strings.LastIndex(addrSpec, "@")
+	localPart, domain := addrSpec[:at], addrSpec[at+1:]
+
+	if len(localPart) > maxLocalPartLength {
+		return "", errors.NewCode(AddressTooLong)
+	}
+	if n.lowercaseLocalPart {
+		localPart = strings.ToLower(localPart)
+	}
+
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return "", err
+	}
+
+	out := localPart + "@" + domain
+	if len(out) > maxAddressLength {
+		return "", errors.NewCode(AddressTooLong)
+	}
+
+	return out, nil
+}
+
+func normalizeDomain(domain string) (string, error) {
+	// Address literals (e.g. 'user@[192.168.0.1]') are valid but can't be
+	// used to reach a real mailbox.
+	if strings.HasPrefix(domain, "[") {
+		return "", errors.NewCodeWithDetails(InvalidDomain, domain)
+	}
+
+	ascii, err := domainProfile.ToASCII(domain)
+	if err != nil {
+		return "", errors.WrapCode(err, InvalidDomain)
+	}
+	// Single label domains (e.g. 'localhost') are not routable.
+	if !strings.Contains(ascii, ".") {
+		return "", errors.NewCodeWithDetails(InvalidDomain, domain)
+	}
+
+	return strings.ToLower(ascii), nil
+}
@@ -0,0 +1,118 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Normalizer_WhenAddressIsValid_ExpectReturnedAsIs(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	actual, err := normalizer.Normalize("john.doe+test@example.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "john.doe+test@example.com", actual)
+}
+
+func TestUnit_Normalizer_TrimsSpacesAndLowercasesDomain(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	actual, err := normalizer.Normalize("  John.Doe@Example.COM \t")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "John.Doe@example.com", actual)
+}
+
+func TestUnit_Normalizer_WhenConfigured_ExpectLocalPartLowercased(t *testing.T) {
+	config := Config{
+		LowercaseLocalPart: true,
+	}
+	normalizer := NewNormalizer(config)
+
+	actual, err := normalizer.Normalize("John.Doe@Example.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "john.doe@example.com", actual)
+}
+
+func TestUnit_Normalizer_WhenDomainIsInternationalized_ExpectPunycode(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	actual, err := normalizer.Normalize("user@Bücher.Example")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user@xn--bcher-kva.example", actual)
+}
+
+func TestUnit_Normalizer_WhenAddressHasAngleBrackets_ExpectRemoved(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	actual, err := normalizer.Normalize("<user@example.com>")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "user@example.com", actual)
+}
+
+func TestUnit_Normalizer_WhenLocalPartIsQuoted_ExpectQuotesKept(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	actual, err := normalizer.Normalize(`"john doe"@example.com`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `"john doe"@example.com`, actual)
+}
+
+func TestUnit_Normalizer_WhenSyntaxIsInvalid_ExpectFailure(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	for _, address := range []string{
+		"",
+		"user1",
+		"user@",
+		"@example.com",
+		"john..doe@example.com",
+		"john doe@example.com",
+		"John Doe <john.doe@example.com>",
+	} {
+		_, err := normalizer.Normalize(address)
+
+		assert.True(t, errors.IsErrorWithCode(err, InvalidSyntax), "Address: %q, actual err: %v", address, err)
+	}
+}
+
+func TestUnit_Normalizer_WhenDomainIsInvalid_ExpectFailure(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	for _, address := range []string{
+		"user@localhost",
+		"user@[192.168.0.1]",
+		"user@-example.com",
+		"user@example..com",
+		"user@" + strings.Repeat("a", 64) + ".com",
+	} {
+		_, err := normalizer.Normalize(address)
+
+		assert.True(t, errors.IsErrorWithCode(err, InvalidDomain) || errors.IsErrorWithCode(err, InvalidSyntax), "Address: %q, actual err: %v", address, err)
+	}
+}
+
+func TestUnit_Normalizer_WhenLocalPartIsTooLong_ExpectFailure(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+
+	_, err := normalizer.Normalize(strings.Repeat("a", 65) + "@example.com")
+
+	assert.True(t, errors.IsErrorWithCode(err, AddressTooLong), "Actual err: %v", err)
+}
+
+func TestUnit_Normalizer_WhenAddressIsTooLong_ExpectFailure(t *testing.T) {
+	normalizer := NewNormalizer(Config{})
+	label := strings.Repeat("a", 63)
+	domain := strings.Join([]string{label, label, label}, ".") + ".com"
+
+	_, err := normalizer.Normalize(strings.Repeat("a", 64) + "@" + domain)
+
+	assert.True(t, errors.IsErrorWithCode(err, AddressTooLong), "Actual err: %v", err)
+}
@@ -0,0 +1,151 @@
+package federation
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	// OidcType is for the providers implementing OpenID Connect discovery,
+	// such as Google.
+	OidcType = "oidc"
+	// OAuth2Type is for the providers which only expose an endpoint
+	// describing the user, such as GitHub or Discord.
+	OAuth2Type = "oauth2"
+)
+
+type ProviderConfig struct {
+	// Name identifies the provider in the login URL: /v1/users/login/{name}.
+	Name string
+	// Type is either oidc or oauth2.
+	Type string
+
+	ClientId string
+	// ClientSecret is the secret obtained when registering the service at
+	// the provider. ClientSecretFile is the path to a file holding it and
+	// takes precedence.
+	ClientSecret     string
+	ClientSecretFile string
+	// RedirectUri is the callback registered at the provider. It points to
+	// /v1/users/login/{name}/callback on the public URL of the service.
+	RedirectUri string
+	// Scopes are requested in addition to openid for oidc providers.
+	Scopes []string
+
+	// Issuer is where the OpenID configuration is discovered. It is only
+	// used by oidc providers.
+	Issuer string
+
+	// The endpoints of oauth2 providers. The user info endpoint is called
+	// with the access token and should return a JSON object.
+	AuthorizationEndpoint string
+	TokenEndpoint         string
+	UserInfoEndpoint      string
+	// SubjectField, EmailField and EmailVerifiedField are the fields of the
+	// user info holding the identifier of the user, their email and whether
+	// it was verified. They default to id and email; emails are considered
+	// unverified when EmailVerifiedField is not set.
+	SubjectField       string
+	EmailField         string
+	EmailVerifiedField string
+
+	// TrustEmails considers all the emails returned by the provider as
+	// verified, for providers which only return verified emails but don't
+	// say so.
+	TrustEmails bool
+	// CreateUsers creates an account for the users signing in for the first
+	// time. Otherwise only the users who already have an account with the
+	// same verified email can sign in with the provider.
+	CreateUsers bool
+}
+
+type Config struct {
+	// LoginValidity is how long users have to sign in at the provider once
+	// they were redirected to it.
+	LoginValidity time.Duration
+	Providers     []ProviderConfig
+}
+
+func (c Config) Validate() error {
+	if len(c.Providers) > 0 && c.LoginValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "login validity must be positive")
+	}
+
+	names := make(map[string]bool)
+	for _, provider := range c.Providers {
+		if provider.Name == "" || names[provider.Name] || strings.ContainsAny(provider.Name, "/?#") {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "invalid provider name: "+provider.Name)
+		}
+		names[provider.Name] = true
+
+		if err := provider.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Provider returns the configuration of the provider with this name.
+func (c Config) Provider(name string) (ProviderConfig, bool) {
+	for _, provider := range c.Providers {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+
+	return ProviderConfig{}, false
+}
+
+func (c ProviderConfig) validate() error {
+	if c.ClientId == "" {
+		return errors.NewCodeWithDetails(InvalidConfiguration, c.Name+": client id is required")
+	}
+	if !absoluteUrl(c.RedirectUri) {
+		return errors.NewCodeWithDetails(InvalidConfiguration, c.Name+": redirect URI must be an absolute URL")
+	}
+
+	switch c.Type {
+	case OidcType:
+		if !absoluteUrl(c.Issuer) {
+			return errors.NewCodeWithDetails(InvalidConfiguration, c.Name+": issuer must be an absolute URL")
+		}
+	case OAuth2Type:
+		for _, endpoint := range []string{c.AuthorizationEndpoint, c.TokenEndpoint, c.UserInfoEndpoint} {
+			if !absoluteUrl(endpoint) {
+				return errors.NewCodeWithDetails(InvalidConfiguration, c.Name+": endpoints must be absolute URLs")
+			}
+		}
+	default:
+		return errors.NewCodeWithDetails(UnsupportedProviderType, c.Type)
+	}
+
+	_, err := c.clientSecret()
+	return err
+}
+
+func (c ProviderConfig) clientSecret() (string, error) {
+	if c.ClientSecretFile == "" {
+		return c.ClientSecret, nil
+	}
+
+	data, err := os.ReadFile(c.ClientSecretFile)
+	if err != nil {
+		return "", errors.WrapCode(err, InvalidConfiguration)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+func absoluteUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+
+	return (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
@@ -0,0 +1,116 @@
+package federation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var validOidcConfig = ProviderConfig{
+	Name:        "google",
+	Type:        OidcType,
+	ClientId:    "client-id",
+	RedirectUri: "https://users.example.com/v1/users/login/google/callback",
+	Issuer:      "https://accounts.google.com",
+}
+
+var validOAuth2Config = ProviderConfig{
+	Name:                  "github",
+	Type:                  OAuth2Type,
+	ClientId:              "client-id",
+	RedirectUri:           "https://users.example.com/v1/users/login/github/callback",
+	AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+	TokenEndpoint:         "https://github.com/login/oauth/access_token",
+	UserInfoEndpoint:      "https://api.github.com/user",
+}
+
+func TestUnit_Config_Validate(t *testing.T) {
+	config := Config{
+		LoginValidity: 10 * time.Minute,
+		Providers:     []ProviderConfig{validOidcConfig, validOAuth2Config},
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_Config_Validate_WhenNoProviders_ExpectValid(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+}
+
+func TestUnit_Config_Validate_WhenInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		alter        func(config *Config)
+		expectedCode errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"noLoginValidity": {
+			alter:        func(config *Config) { config.LoginValidity = 0 },
+			expectedCode: InvalidConfiguration,
+		},
+		"duplicateName": {
+			alter:        func(config *Config) { config.Providers[1].Name = config.Providers[0].Name },
+			expectedCode: InvalidConfiguration,
+		},
+		"nameWithSlash": {
+			alter:        func(config *Config) { config.Providers[0].Name = "google/oidc" },
+			expectedCode: InvalidConfiguration,
+		},
+		"noClientId": {
+			alter:        func(config *Config) { config.Providers[0].ClientId = "" },
+			expectedCode: InvalidConfiguration,
+		},
+		"relativeRedirectUri": {
+			alter:        func(config *Config) { config.Providers[0].RedirectUri = "/callback" },
+			expectedCode: InvalidConfiguration,
+		},
+		"noIssuer": {
+			alter:        func(config *Config) { config.Providers[0].Issuer = "" },
+			expectedCode: InvalidConfiguration,
+		},
+		"noUserInfoEndpoint": {
+			alter:        func(config *Config) { config.Providers[1].UserInfoEndpoint = "" },
+			expectedCode: InvalidConfiguration,
+		},
+		"unknownType": {
+			alter:        func(config *Config) { config.Providers[0].Type = "saml" },
+			expectedCode: UnsupportedProviderType,
+		},
+		"missingSecretFile": {
+			alter:        func(config *Config) { config.Providers[0].ClientSecretFile = "/does/not/exist" },
+			expectedCode: InvalidConfiguration,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				LoginValidity: 10 * time.Minute,
+				Providers:     []ProviderConfig{validOidcConfig, validOAuth2Config},
+			}
+			testCase.alter(&config)
+
+			err := config.Validate()
+
+			assert.True(t, errors.IsErrorWithCode(err, testCase.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_ProviderConfig_ClientSecret_WhenFileIsSet_ExpectSecretIsRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	require.Nil(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+	config := validOidcConfig
+	config.ClientSecret = "inline"
+	config.ClientSecretFile = path
+
+	actual, err := config.clientSecret()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "from-file", actual)
+}
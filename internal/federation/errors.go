@@ -0,0 +1,15 @@
+package federation
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration    errors.ErrorCode = 1600
+	UnsupportedProviderType errors.ErrorCode = 1601
+
+	ProviderUnavailable errors.ErrorCode = 1610
+	CodeExchangeFailed  errors.ErrorCode = 1611
+	InvalidIdToken      errors.ErrorCode = 1612
+	MissingSubject      errors.ErrorCode = 1613
+)
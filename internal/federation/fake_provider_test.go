@@ -0,0 +1,226 @@
+package federation
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeClientId     = "user-service"
+	fakeClientSecret = "fake-secret"
+	fakeRedirectUri  = "https://users.example.com/v1/users/login/fake/callback"
+	fakeAccessToken  = "fake-access-token"
+)
+
+type fakeAuthorization struct {
+	nonce         string
+	codeChallenge string
+	redirectUri   string
+}
+
+// fakeProvider is an in-process OpenID provider. Users are signed in by
+// calling authorize with the query of the authorization URL. The claims
+// of the ID tokens can be altered to simulate misbehaving providers.
+type fakeProvider struct {
+	server *httptest.Server
+
+	lock           sync.Mutex
+	key            ed25519.PrivateKey
+	keyId          string
+	codes          map[string]fakeAuthorization
+	userInfo       map[string]any
+	alterIdClaims  func(claims map[string]any)
+	issuerOverride string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{
+		codes: make(map[string]fakeAuthorization),
+		userInfo: map[string]any{
+			"sub":            "fake-subject",
+			"email":          "player@example.com",
+			"email_verified": true,
+		},
+	}
+	p.rotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /userinfo", p.userInfoEndpoint)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *fakeProvider) oidcConfig() ProviderConfig {
+	return ProviderConfig{
+		Name:         "fake",
+		Type:         OidcType,
+		ClientId:     fakeClientId,
+		ClientSecret: fakeClientSecret,
+		RedirectUri:  fakeRedirectUri,
+		Scopes:       []string{"email"},
+		Issuer:       p.server.URL,
+	}
+}
+
+func (p *fakeProvider) oauth2Config() ProviderConfig {
+	return ProviderConfig{
+		Name:                  "fake",
+		Type:                  OAuth2Type,
+		ClientId:              fakeClientId,
+		ClientSecret:          fakeClientSecret,
+		RedirectUri:           fakeRedirectUri,
+		AuthorizationEndpoint: p.server.URL + "/authorize",
+		TokenEndpoint:         p.server.URL + "/token",
+		UserInfoEndpoint:      p.server.URL + "/userinfo",
+	}
+}
+
+func (p *fakeProvider) rotateKey() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	p.key = key
+	p.keyId = randomValue()
+}
+
+// authorize signs the user in and returns the code sent to the callback.
+func (p *fakeProvider) authorize(t *testing.T, authorizationUrl string) string {
+	parsed, err := url.Parse(authorizationUrl)
+	require.Nil(t, err)
+	query := parsed.Query()
+	require.Equal(t, fakeClientId, query.Get("client_id"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	code := randomValue()
+	p.codes[code] = fakeAuthorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectUri:   query.Get("redirect_uri"),
+	}
+
+	return code
+}
+
+func (p *fakeProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.server.URL
+	if p.issuerOverride != "" {
+		issuer = p.issuerOverride
+	}
+
+	writeJson(w, map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": p.server.URL + "/authorize",
+		"token_endpoint":         p.server.URL + "/token",
+		"userinfo_endpoint":      p.server.URL + "/userinfo",
+		"jwks_uri":               p.server.URL + "/jwks",
+	})
+}
+
+func (p *fakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	writeJson(w, jwt.Jwks{
+		Keys: []jwt.Jwk{
+			{
+				KeyType:   "OKP",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(p.key.Public().(ed25519.PublicKey)),
+				KeyId:     p.keyId,
+				Algorithm: "EdDSA",
+				Use:       "sig",
+			},
+		},
+	})
+}
+
+func (p *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	code := r.PostFormValue("code")
+	authorization, ok := p.codes[code]
+	delete(p.codes, code)
+
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	valid := ok &&
+		r.PostFormValue("grant_type") == "authorization_code" &&
+		r.PostFormValue("client_id") == fakeClientId &&
+		r.PostFormValue("client_secret") == fakeClientSecret &&
+		r.PostFormValue("redirect_uri") == authorization.redirectUri &&
+		base64.RawURLEncoding.EncodeToString(challenge[:]) == authorization.codeChallenge
+	if !valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   p.server.URL,
+		"sub":   p.userInfo["sub"],
+		"aud":   fakeClientId,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": authorization.nonce,
+	}
+	if email, ok := p.userInfo["email"]; ok {
+		claims["email"] = email
+		claims["email_verified"] = p.userInfo["email_verified"]
+	}
+	if p.alterIdClaims != nil {
+		p.alterIdClaims(claims)
+	}
+
+	writeJson(w, map[string]string{
+		"access_token": fakeAccessToken,
+		"token_type":   "Bearer",
+		"id_token":     p.sign(claims),
+	})
+}
+
+func (p *fakeProvider) userInfoEndpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+fakeAccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	writeJson(w, p.userInfo)
+}
+
+func (p *fakeProvider) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "EdDSA", "typ": "JWT", "kid": p.keyId})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(p.key, []byte(signingInput))
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJson(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,57 @@
+package federation
+
+import (
+	"context"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	defaultSubjectField = "id"
+	defaultEmailField   = "email"
+)
+
+// oauth2Provider identifies the user with the user info endpoint. Without
+// ID tokens, the access token received from the token endpoint is the only
+// proof that the user signed in.
+type oauth2Provider struct {
+	baseProvider
+}
+
+func (p *oauth2Provider) AuthorizationUrl(ctx context.Context, request LoginRequest) (string, error) {
+	return p.authorizationUrl(p.config.AuthorizationEndpoint, p.config.Scopes, request, false)
+}
+
+func (p *oauth2Provider) Identify(ctx context.Context, code string, request LoginRequest) (Identity, error) {
+	tokens, err := p.exchange(ctx, p.config.TokenEndpoint, code, request)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	info, err := p.userInfo(ctx, p.config.UserInfoEndpoint, tokens.AccessToken)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	out := Identity{
+		Subject: stringClaim(info, fieldOrDefault(p.config.SubjectField, defaultSubjectField)),
+		Email:   stringClaim(info, fieldOrDefault(p.config.EmailField, defaultEmailField)),
+	}
+	if out.Subject == "" {
+		return Identity{}, errors.NewCode(MissingSubject)
+	}
+
+	out.EmailVerified = p.config.TrustEmails
+	if p.config.EmailVerifiedField != "" {
+		out.EmailVerified = out.EmailVerified || boolClaim(info, p.config.EmailVerifiedField)
+	}
+
+	return out, nil
+}
+
+func fieldOrDefault(field string, fallback string) string {
+	if field == "" {
+		return fallback
+	}
+	return field
+}
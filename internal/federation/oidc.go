@@ -0,0 +1,218 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+)
+
+// Tolerated difference between the clock of the provider and ours.
+const clockSkew = time.Minute
+
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type idClaims struct {
+	Issuer        string          `json:"iss"`
+	Subject       string          `json:"sub"`
+	Audience      json.RawMessage `json:"aud"`
+	ExpiresAt     int64           `json:"exp"`
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified any             `json:"email_verified"`
+}
+
+// oidcProvider discovers its endpoints and keys from the issuer. They are
+// kept in memory; the keys are fetched again when a token is signed with
+// an unknown one as providers rotate them.
+type oidcProvider struct {
+	baseProvider
+
+	lock      sync.Mutex
+	discovery *discovery
+	keys      *jwt.Jwks
+}
+
+func (p *oidcProvider) AuthorizationUrl(ctx context.Context, request LoginRequest) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := []string{"openid"}
+	for _, scope := range p.config.Scopes {
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return p.authorizationUrl(metadata.AuthorizationEndpoint, scopes, request, true)
+}
+
+func (p *oidcProvider) Identify(ctx context.Context, code string, request LoginRequest) (Identity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	tokens, err := p.exchange(ctx, metadata.TokenEndpoint, code, request)
+	if err != nil {
+		return Identity{}, err
+	}
+	if tokens.IdToken == "" {
+		return Identity{}, errors.NewCodeWithDetails(InvalidIdToken, "no ID token")
+	}
+
+	claims, err := p.verify(ctx, metadata, tokens.IdToken, request.Nonce)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	out := Identity{
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: p.config.TrustEmails || isTrue(claims.EmailVerified),
+	}
+
+	// Some providers only describe the user in the user info.
+	if out.Email == "" && metadata.UserInfoEndpoint != "" {
+		info, err := p.userInfo(ctx, metadata.UserInfoEndpoint, tokens.AccessToken)
+		if err != nil {
+			return Identity{}, err
+		}
+
+		// The user info might come from another user if the response was
+		// tampered with.
+		// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+		if stringClaim(info, "sub") == out.Subject {
+			out.Email = stringClaim(info, "email")
+			out.EmailVerified = p.config.TrustEmails || boolClaim(info, "email_verified")
+		}
+	}
+
+	return out, nil
+}
+
+// verify checks the ID token as described in the specification.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (p *oidcProvider) verify(ctx context.Context, metadata discovery, token string, nonce string) (idClaims, error) {
+	keys, err := p.jwks(ctx, metadata, false)
+	if err != nil {
+		return idClaims{}, err
+	}
+
+	var claims idClaims
+	err = jwt.VerifyExternal(token, keys, &claims)
+	if errors.IsErrorWithCode(err, jwt.UnknownKey) {
+		keys, err = p.jwks(ctx, metadata, true)
+		if err != nil {
+			return idClaims{}, err
+		}
+		err = jwt.VerifyExternal(token, keys, &claims)
+	}
+	if err != nil {
+		return idClaims{}, errors.WrapCode(err, InvalidIdToken)
+	}
+
+	if claims.Issuer != metadata.Issuer {
+		return idClaims{}, errors.NewCodeWithDetails(InvalidIdToken, "unexpected issuer")
+	}
+	if !audienceContains(claims.Audience, p.config.ClientId) {
+		return idClaims{}, errors.NewCodeWithDetails(InvalidIdToken, "unexpected audience")
+	}
+	if time.Unix(claims.ExpiresAt, 0).Add(clockSkew).Before(time.Now()) {
+		return idClaims{}, errors.NewCodeWithDetails(InvalidIdToken, "token expired")
+	}
+	if claims.Nonce != nonce {
+		return idClaims{}, errors.NewCodeWithDetails(InvalidIdToken, "unexpected nonce")
+	}
+	if claims.Subject == "" {
+		return idClaims{}, errors.NewCode(MissingSubject)
+	}
+
+	return claims, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (discovery, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.discovery != nil {
+		return *p.discovery, nil
+	}
+
+	endpoint := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return discovery{}, errors.WrapCode(err, ProviderUnavailable)
+	}
+
+	var out discovery
+	if err := p.do(req, ProviderUnavailable, &out); err != nil {
+		return discovery{}, err
+	}
+
+	// Otherwise the provider could claim to be another one.
+	// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if strings.TrimSuffix(out.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return discovery{}, errors.NewCodeWithDetails(ProviderUnavailable, "unexpected issuer: "+out.Issuer)
+	}
+	if !absoluteUrl(out.AuthorizationEndpoint) || !absoluteUrl(out.TokenEndpoint) || !absoluteUrl(out.JwksUri) {
+		return discovery{}, errors.NewCodeWithDetails(ProviderUnavailable, "incomplete configuration")
+	}
+
+	p.discovery = &out
+	return out, nil
+}
+
+func (p *oidcProvider) jwks(ctx context.Context, metadata discovery, refresh bool) (jwt.Jwks, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.keys != nil && !refresh {
+		return *p.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JwksUri, nil)
+	if err != nil {
+		return jwt.Jwks{}, errors.WrapCode(err, ProviderUnavailable)
+	}
+
+	var out jwt.Jwks
+	if err := p.do(req, ProviderUnavailable, &out); err != nil {
+		return jwt.Jwks{}, err
+	}
+
+	p.keys = &out
+	return out, nil
+}
+
+// audienceContains accepts a single audience or a list of them.
+// https://datatracker.ietf.org/doc/html/rfc7519#section-4.1.3
+func audienceContains(raw json.RawMessage, clientId string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == clientId
+	}
+
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return slices.Contains(list, clientId)
+	}
+
+	return false
+}
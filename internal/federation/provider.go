@@ -0,0 +1,260 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// The providers are called while the user waits for the callback to
+// complete: they should answer quickly.
+const requestTimeout = 10 * time.Second
+
+// Responses of the providers are small JSON documents.
+const maxResponseSize = 1 << 20
+
+// LoginRequest holds the values generated when redirecting the user to the
+// provider and which are needed to verify the answer.
+type LoginRequest struct {
+	// State is echoed back by the provider and ties the callback to the
+	// browser which started the login.
+	State string
+	// Nonce is included in the ID token by oidc providers.
+	Nonce string
+	// CodeVerifier proves that this service requested the code.
+	// https://datatracker.ietf.org/doc/html/rfc7636
+	CodeVerifier string
+}
+
+// Identity is what the provider knows about the user.
+type Identity struct {
+	// Subject identifies the user at the provider. Unlike the email, it
+	// never changes.
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type Provider interface {
+	Name() string
+	// AuthorizationUrl is where the user is redirected to sign in.
+	AuthorizationUrl(ctx context.Context, request LoginRequest) (string, error)
+	// Identify exchanges the code received in the callback and returns the
+	// identity of the user who signed in.
+	Identify(ctx context.Context, code string, request LoginRequest) (Identity, error)
+}
+
+// Providers are indexed by name.
+type Providers map[string]Provider
+
+// New creates the providers described by the configuration. It does not
+// contact them: oidc providers are discovered on first use.
+func New(config Config) (Providers, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+
+	out := make(Providers)
+	for _, providerConfig := range config.Providers {
+		secret, err := providerConfig.clientSecret()
+		if err != nil {
+			return nil, err
+		}
+
+		base := baseProvider{
+			config: providerConfig,
+			secret: secret,
+			client: client,
+		}
+
+		switch providerConfig.Type {
+		case OidcType:
+			out[providerConfig.Name] = &oidcProvider{baseProvider: base}
+		case OAuth2Type:
+			out[providerConfig.Name] = &oauth2Provider{baseProvider: base}
+		}
+	}
+
+	return out, nil
+}
+
+func NewLoginRequest() LoginRequest {
+	return LoginRequest{
+		State:        randomValue(),
+		Nonce:        randomValue(),
+		CodeVerifier: randomValue(),
+	}
+}
+
+func randomValue() string {
+	value := make([]byte, 32)
+	// Never returns an error.
+	rand.Read(value)
+
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// baseProvider implements the parts of the authorization code flow which
+// are common to all providers.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1
+type baseProvider struct {
+	config ProviderConfig
+	secret string
+	client *http.Client
+}
+
+func (p *baseProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *baseProvider) authorizationUrl(endpoint string, scopes []string, request LoginRequest, nonce bool) (string, error) {
+	out, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.WrapCode(err, ProviderUnavailable)
+	}
+
+	challenge := sha256.Sum256([]byte(request.CodeVerifier))
+
+	query := out.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientId)
+	query.Set("redirect_uri", p.config.RedirectUri)
+	query.Set("state", request.State)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(scopes) > 0 {
+		query.Set("scope", strings.Join(scopes, " "))
+	}
+	if nonce {
+		query.Set("nonce", request.Nonce)
+	}
+	out.RawQuery = query.Encode()
+
+	return out.String(), nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IdToken     string `json:"id_token"`
+}
+
+// exchange sends the credentials in the body: it is supported by all the
+// common providers, unlike basic authentication.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.3
+func (p *baseProvider) exchange(ctx context.Context, endpoint string, code string, request LoginRequest) (tokenResponse, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectUri},
+		"client_id":     {p.config.ClientId},
+		"code_verifier": {request.CodeVerifier},
+	}
+	if p.secret != "" {
+		form.Set("client_secret", p.secret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, errors.WrapCode(err, CodeExchangeFailed)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var out tokenResponse
+	if err := p.do(req, CodeExchangeFailed, &out); err != nil {
+		return tokenResponse{}, err
+	}
+	if out.AccessToken == "" {
+		return tokenResponse{}, errors.NewCodeWithDetails(CodeExchangeFailed, "no access token")
+	}
+
+	return out, nil
+}
+
+// userInfo returns the description of the user authenticated by the token.
+func (p *baseProvider) userInfo(ctx context.Context, endpoint string, accessToken string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.WrapCode(err, ProviderUnavailable)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var out map[string]any
+	if err := p.do(req, ProviderUnavailable, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// do sends the request and decodes the JSON response in out. Failures are
+// reported with the code.
+func (p *baseProvider) do(req *http.Request, code errors.ErrorCode, out any) error {
+	// GitHub answers with a form unless asked otherwise.
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return errors.WrapCode(err, code)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return errors.WrapCode(err, code)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.NewCodeWithDetails(code, fmt.Sprintf("%s answered %d", p.config.Name, resp.StatusCode))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Numeric identifiers such as the ones of GitHub don't fit in a float.
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return errors.WrapCode(err, code)
+	}
+
+	return nil
+}
+
+// stringClaim accepts strings and numbers.
+func stringClaim(claims map[string]any, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
+
+func boolClaim(claims map[string]any, name string) bool {
+	return isTrue(claims[name])
+}
+
+// isTrue accepts booleans and their string representation: some providers
+// send the latter.
+func isTrue(claim any) bool {
+	switch value := claim.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
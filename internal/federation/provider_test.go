@@ -0,0 +1,255 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_OidcProvider_AuthorizationUrl(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake.oidcConfig())
+	request := NewLoginRequest()
+
+	actual, err := provider.AuthorizationUrl(context.Background(), request)
+
+	require.Nil(t, err)
+	parsed, err := url.Parse(actual)
+	require.Nil(t, err)
+	assert.Equal(t, fake.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	query := parsed.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, fakeRedirectUri, query.Get("redirect_uri"))
+	assert.Equal(t, "openid email", query.Get("scope"))
+	assert.Equal(t, request.State, query.Get("state"))
+	assert.Equal(t, request.Nonce, query.Get("nonce"))
+	assert.NotEqual(t, request.CodeVerifier, query.Get("code_challenge"))
+}
+
+func TestUnit_OidcProvider_Identify(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake.oidcConfig())
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	expected := Identity{
+		Subject:       "fake-subject",
+		Email:         "player@example.com",
+		EmailVerified: true,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_OidcProvider_Identify_WhenEmailIsNotInIdToken_ExpectUserInfoIsUsed(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.alterIdClaims = func(claims map[string]any) {
+		delete(claims, "email")
+		delete(claims, "email_verified")
+	}
+	provider := newTestProvider(t, fake.oidcConfig())
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "player@example.com", actual.Email)
+	assert.True(t, actual.EmailVerified)
+}
+
+func TestUnit_OidcProvider_Identify_WhenKeysAreRotated_ExpectNewKeysAreFetched(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake.oidcConfig())
+	request, code := signInAtFakeProvider(t, fake, provider)
+	_, err := provider.Identify(context.Background(), code, request)
+	require.Nil(t, err)
+
+	fake.rotateKey()
+	request, code = signInAtFakeProvider(t, fake, provider)
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "fake-subject", actual.Subject)
+}
+
+func TestUnit_OidcProvider_Identify_WhenIdTokenIsInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		alter        func(claims map[string]any)
+		expectedCode errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"issuer": {
+			alter:        func(claims map[string]any) { claims["iss"] = "https://attacker.example.com" },
+			expectedCode: InvalidIdToken,
+		},
+		"audience": {
+			alter:        func(claims map[string]any) { claims["aud"] = "another-client" },
+			expectedCode: InvalidIdToken,
+		},
+		"expired": {
+			alter:        func(claims map[string]any) { claims["exp"] = 1 },
+			expectedCode: InvalidIdToken,
+		},
+		"nonce": {
+			alter:        func(claims map[string]any) { claims["nonce"] = "replayed" },
+			expectedCode: InvalidIdToken,
+		},
+		"subject": {
+			alter:        func(claims map[string]any) { delete(claims, "sub") },
+			expectedCode: MissingSubject,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeProvider(t)
+			fake.alterIdClaims = testCase.alter
+			provider := newTestProvider(t, fake.oidcConfig())
+			request, code := signInAtFakeProvider(t, fake, provider)
+
+			_, err := provider.Identify(context.Background(), code, request)
+
+			assert.True(t, errors.IsErrorWithCode(err, testCase.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_OidcProvider_Identify_WhenAudienceIsAList_ExpectSuccess(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.alterIdClaims = func(claims map[string]any) {
+		claims["aud"] = []string{"another-client", fakeClientId}
+	}
+	provider := newTestProvider(t, fake.oidcConfig())
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	_, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_OidcProvider_Identify_WhenCodeVerifierIsWrong_ExpectError(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake.oidcConfig())
+	request, code := signInAtFakeProvider(t, fake, provider)
+	request.CodeVerifier = NewLoginRequest().CodeVerifier
+
+	_, err := provider.Identify(context.Background(), code, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, CodeExchangeFailed), "Actual err: %v", err)
+}
+
+func TestUnit_OidcProvider_WhenDiscoveredIssuerDoesNotMatch_ExpectError(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.issuerOverride = "https://attacker.example.com"
+	provider := newTestProvider(t, fake.oidcConfig())
+
+	_, err := provider.AuthorizationUrl(context.Background(), NewLoginRequest())
+
+	assert.True(t, errors.IsErrorWithCode(err, ProviderUnavailable), "Actual err: %v", err)
+}
+
+func TestUnit_OAuth2Provider_AuthorizationUrl_ExpectNoNonce(t *testing.T) {
+	fake := newFakeProvider(t)
+	provider := newTestProvider(t, fake.oauth2Config())
+
+	actual, err := provider.AuthorizationUrl(context.Background(), NewLoginRequest())
+
+	require.Nil(t, err)
+	parsed, err := url.Parse(actual)
+	require.Nil(t, err)
+	assert.False(t, parsed.Query().Has("nonce"))
+	assert.False(t, parsed.Query().Has("scope"))
+}
+
+func TestUnit_OAuth2Provider_Identify(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.userInfo = map[string]any{
+		"id":       json.Number("583231"),
+		"email":    "player@example.com",
+		"verified": true,
+	}
+	config := fake.oauth2Config()
+	config.EmailVerifiedField = "verified"
+	provider := newTestProvider(t, config)
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	expected := Identity{
+		Subject:       "583231",
+		Email:         "player@example.com",
+		EmailVerified: true,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_OAuth2Provider_Identify_ExpectEmailsAreNotVerifiedByDefault(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.userInfo = map[string]any{
+		"id":    "583231",
+		"email": "player@example.com",
+	}
+	provider := newTestProvider(t, fake.oauth2Config())
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	assert.False(t, actual.EmailVerified)
+}
+
+func TestUnit_OAuth2Provider_Identify_WhenEmailsAreTrusted_ExpectVerified(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.userInfo = map[string]any{
+		"id":    "583231",
+		"email": "player@example.com",
+	}
+	config := fake.oauth2Config()
+	config.TrustEmails = true
+	provider := newTestProvider(t, config)
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	actual, err := provider.Identify(context.Background(), code, request)
+
+	assert.Nil(t, err)
+	assert.True(t, actual.EmailVerified)
+}
+
+func TestUnit_OAuth2Provider_Identify_WhenSubjectIsMissing_ExpectError(t *testing.T) {
+	fake := newFakeProvider(t)
+	fake.userInfo = map[string]any{
+		"email": "player@example.com",
+	}
+	provider := newTestProvider(t, fake.oauth2Config())
+	request, code := signInAtFakeProvider(t, fake, provider)
+
+	_, err := provider.Identify(context.Background(), code, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, MissingSubject), "Actual err: %v", err)
+}
+
+func newTestProvider(t *testing.T, config ProviderConfig) Provider {
+	providers, err := New(Config{
+		LoginValidity: 1,
+		Providers:     []ProviderConfig{config},
+	})
+	require.Nil(t, err)
+
+	return providers[config.Name]
+}
+
+func signInAtFakeProvider(t *testing.T, fake *fakeProvider, provider Provider) (LoginRequest, string) {
+	request := NewLoginRequest()
+	authorizationUrl, err := provider.AuthorizationUrl(context.Background(), request)
+	require.Nil(t, err)
+
+	return request, fake.authorize(t, authorizationUrl)
+}
@@ -0,0 +1,53 @@
+package jwt
+
+import (
+	"slices"
+	"time"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc7519#section-4
+type Claims struct {
+	Id       string   `json:"jti"`
+	Issuer   string   `json:"iss,omitempty"`
+	Subject  string   `json:"sub"`
+	Audience []string `json:"aud,omitempty"`
+	// Session is the identifier of the session the token was issued for,
+	// which allows to revoke it.
+	Session string `json:"sid"`
+	// Roles and Permissions are the ones of the user when the token was
+	// issued, including the inherited roles.
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	// ClientId is set when the token was obtained by an OAuth client.
+	// https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+	ClientId string `json:"client_id,omitempty"`
+
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+func (c Claims) Expiration() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+func (c Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// IdClaims are the claims of the ID tokens returned to the OpenID Connect
+// clients: they describe the user to the client.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type IdClaims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Audience string `json:"aud"`
+	Nonce    string `json:"nonce,omitempty"`
+
+	AuthTime  int64 `json:"auth_time"`
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+
+	// https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+	Email     string `json:"email,omitempty"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
@@ -0,0 +1,12 @@
+package jwt
+
+type Config struct {
+	// Enabled makes the service issue signed tokens instead of opaque keys.
+	// The tokens are signed with the keys of the keyring.
+	Enabled bool
+	// Issuer and Audience are copied in the iss and aud claims of the
+	// tokens. Tokens from another issuer or which are not intended for
+	// one of the audiences are rejected.
+	Issuer   string
+	Audience []string
+}
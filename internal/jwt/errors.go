@@ -0,0 +1,17 @@
+package jwt
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidPrivateKey  errors.ErrorCode = 1400
+	UnsupportedKeyType errors.ErrorCode = 1401
+
+	MalformedToken   errors.ErrorCode = 1410
+	InvalidSignature errors.ErrorCode = 1411
+	TokenExpired     errors.ErrorCode = 1412
+	InvalidIssuer    errors.ErrorCode = 1413
+	UnknownKey       errors.ErrorCode = 1414
+	InvalidAudience  errors.ErrorCode = 1415
+)
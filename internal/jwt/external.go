@@ -0,0 +1,186 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// Shorter RSA keys are not considered safe anymore.
+// https://datatracker.ietf.org/doc/html/rfc7518#section-3.3
+const minRsaKeyBits = 2048
+
+// publicKey verifies the tokens issued by other services.
+type publicKey interface {
+	algorithm() string
+	verify(data []byte, signature []byte) bool
+}
+
+// VerifyExternal checks the signature of a token issued by another service
+// with the keys it publishes and decodes its claims in out. The claims are
+// not validated: what they hold depends on the issuer.
+func VerifyExternal(token string, keys Jwks, out any) error {
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return errors.NewCode(MalformedToken)
+	}
+
+	var h header
+	if err := decodeSegment(segments[0], &h); err != nil {
+		return err
+	}
+
+	jwk, err := findKey(keys, h.KeyId)
+	if err != nil {
+		return err
+	}
+	key, err := parsePublicKey(jwk)
+	if err != nil {
+		return err
+	}
+	// As for the tokens issued by this service, the algorithm is imposed
+	// by the key and not by the header.
+	if h.Algorithm != key.algorithm() {
+		return errors.NewCode(InvalidSignature)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return errors.WrapCode(err, MalformedToken)
+	}
+	if !key.verify([]byte(segments[0]+"."+segments[1]), signature) {
+		return errors.NewCode(InvalidSignature)
+	}
+
+	return decodeSegment(segments[1], out)
+}
+
+// findKey accepts tokens without key id when the set holds a single key.
+func findKey(keys Jwks, keyId string) (Jwk, error) {
+	if keyId == "" && len(keys.Keys) == 1 {
+		return keys.Keys[0], nil
+	}
+
+	for _, key := range keys.Keys {
+		if key.KeyId == keyId {
+			return key, nil
+		}
+	}
+
+	return Jwk{}, errors.NewCode(UnknownKey)
+}
+
+func parsePublicKey(jwk Jwk) (publicKey, error) {
+	var key publicKey
+	var err error
+
+	switch {
+	case jwk.KeyType == "OKP" && jwk.Curve == "Ed25519":
+		key, err = parseEd25519PublicKey(jwk)
+	case jwk.KeyType == "EC" && jwk.Curve == "P-256":
+		key, err = parseEcdsaPublicKey(jwk)
+	case jwk.KeyType == "RSA":
+		key, err = parseRsaPublicKey(jwk)
+	default:
+		return nil, errors.NewCode(UnsupportedKeyType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Algorithm != "" && jwk.Algorithm != key.algorithm() {
+		return nil, errors.NewCodeWithDetails(UnsupportedKeyType, jwk.Algorithm)
+	}
+
+	return key, nil
+}
+
+type ed25519PublicKey struct {
+	key ed25519.PublicKey
+}
+
+func parseEd25519PublicKey(jwk Jwk) (publicKey, error) {
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, errors.NewCode(UnsupportedKeyType)
+	}
+
+	return &ed25519PublicKey{key: x}, nil
+}
+
+func (k *ed25519PublicKey) algorithm() string {
+	return "EdDSA"
+}
+
+func (k *ed25519PublicKey) verify(data []byte, signature []byte) bool {
+	return ed25519.Verify(k.key, data, signature)
+}
+
+type ecdsaPublicKey struct {
+	key *ecdsa.PublicKey
+}
+
+func parseEcdsaPublicKey(jwk Jwk) (publicKey, error) {
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if errX != nil || errY != nil || len(x) != es256CoordinateLength || len(y) != es256CoordinateLength {
+		return nil, errors.NewCode(UnsupportedKeyType)
+	}
+
+	// Parsing the uncompressed form verifies that the point is on the curve.
+	point := append([]byte{4}, append(x, y...)...)
+	key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+	if err != nil {
+		return nil, errors.WrapCode(err, UnsupportedKeyType)
+	}
+
+	return &ecdsaPublicKey{key: key}, nil
+}
+
+func (k *ecdsaPublicKey) algorithm() string {
+	return "ES256"
+}
+
+func (k *ecdsaPublicKey) verify(data []byte, signature []byte) bool {
+	return verifyEs256(k.key, data, signature)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7518#section-3.3
+type rsaPublicKey struct {
+	key *rsa.PublicKey
+}
+
+func parseRsaPublicKey(jwk Jwk) (publicKey, error) {
+	n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+	if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.NewCode(UnsupportedKeyType)
+	}
+
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}
+	if key.N.BitLen() < minRsaKeyBits {
+		return nil, errors.NewCodeWithDetails(UnsupportedKeyType, "RSA key is too short")
+	}
+
+	return &rsaPublicKey{key: key}, nil
+}
+
+func (k *rsaPublicKey) algorithm() string {
+	return "RS256"
+}
+
+func (k *rsaPublicKey) verify(data []byte, signature []byte) bool {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(k.key, crypto.SHA256, digest[:], signature) == nil
+}
@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_VerifyExternal_ExpectClaimsAreDecoded(t *testing.T) {
+	for _, algorithm := range []string{keyring.EdDSA, keyring.ES256} {
+		t.Run(algorithm, func(t *testing.T) {
+			signer := NewSigner(testConfig, newTestKeyring(t, algorithm))
+			token, err := signer.Sign(context.Background(), sampleClaims)
+			require.Nil(t, err)
+			keys, err := signer.Keys(context.Background())
+			require.Nil(t, err)
+
+			var actual Claims
+			err = VerifyExternal(token, keys, &actual)
+
+			assert.Nil(t, err)
+			assert.Equal(t, sampleClaims.Subject, actual.Subject)
+		})
+	}
+}
+
+func TestUnit_VerifyExternal_WhenSignedWithRsa_ExpectClaimsAreDecoded(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	token := signTestRsaToken(t, key, "rsa-key", sampleClaims)
+
+	var actual Claims
+	err = VerifyExternal(token, newTestRsaJwks(&key.PublicKey, "rsa-key"), &actual)
+
+	assert.Nil(t, err)
+	assert.Equal(t, sampleClaims.Subject, actual.Subject)
+}
+
+func TestUnit_VerifyExternal_WhenKeyIsUnknown_ExpectError(t *testing.T) {
+	signer := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+	otherSigner := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+	keys, err := otherSigner.Keys(context.Background())
+	require.Nil(t, err)
+
+	var actual Claims
+	err = VerifyExternal(token, keys, &actual)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownKey), "Actual err: %v", err)
+}
+
+func TestUnit_VerifyExternal_WhenSignatureIsInvalid_ExpectError(t *testing.T) {
+	signer := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+	keys, err := signer.Keys(context.Background())
+	require.Nil(t, err)
+	otherKeys, err := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA)).Keys(context.Background())
+	require.Nil(t, err)
+	keys.Keys[0].X = otherKeys.Keys[0].X
+
+	var actual Claims
+	err = VerifyExternal(token, keys, &actual)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_VerifyExternal_WhenAlgorithmDoesNotMatchKey_ExpectError(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	token := signTestRsaToken(t, key, "rsa-key", sampleClaims)
+	keys := newTestRsaJwks(&key.PublicKey, "rsa-key")
+	keys.Keys[0].Algorithm = "RS512"
+
+	var actual Claims
+	err = VerifyExternal(token, keys, &actual)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedKeyType), "Actual err: %v", err)
+}
+
+func TestUnit_VerifyExternal_WhenRsaKeyIsTooShort_ExpectError(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.Nil(t, err)
+	token := signTestRsaToken(t, key, "rsa-key", sampleClaims)
+
+	var actual Claims
+	err = VerifyExternal(token, newTestRsaJwks(&key.PublicKey, "rsa-key"), &actual)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedKeyType), "Actual err: %v", err)
+}
+
+func signTestRsaToken(t *testing.T, key *rsa.PrivateKey, keyId string, claims any) string {
+	encodedHeader, err := encodeSegment(header{Algorithm: "RS256", Type: "JWT", KeyId: keyId})
+	require.Nil(t, err)
+	encodedClaims, err := encodeSegment(claims)
+	require.Nil(t, err)
+
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.Nil(t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestRsaJwks(key *rsa.PublicKey, keyId string) Jwks {
+	return Jwks{
+		Keys: []Jwk{
+			{
+				KeyType:   "RSA",
+				N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				KeyId:     keyId,
+				Algorithm: "RS256",
+				Use:       "sig",
+			},
+		},
+	}
+}
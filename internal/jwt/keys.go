@@ -0,0 +1,143 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc7517#section-4
+type Jwk struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv,omitempty"`
+	X       string `json:"x,omitempty"`
+	Y       string `json:"y,omitempty"`
+	// N and E are only set for RSA keys, which are not issued by this
+	// service but by some identity providers.
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	KeyId     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+type signingKey interface {
+	algorithm() string
+	sign(data []byte) ([]byte, error)
+	verify(data []byte, signature []byte) bool
+	jwk() Jwk
+}
+
+// parsePrivateKey expects a PKCS #8 document as generated by the keyring.
+func parsePrivateKey(der []byte) (signingKey, error) {
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidPrivateKey)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return &ed25519Key{key: k}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.NewCode(UnsupportedKeyType)
+		}
+		return &ecdsaKey{key: k}, nil
+	default:
+		return nil, errors.NewCode(UnsupportedKeyType)
+	}
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8037
+type ed25519Key struct {
+	key ed25519.PrivateKey
+}
+
+func (k *ed25519Key) algorithm() string {
+	return "EdDSA"
+}
+
+func (k *ed25519Key) sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(k.key, data), nil
+}
+
+func (k *ed25519Key) verify(data []byte, signature []byte) bool {
+	return ed25519.Verify(k.key.Public().(ed25519.PublicKey), data, signature)
+}
+
+func (k *ed25519Key) jwk() Jwk {
+	return Jwk{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(k.key.Public().(ed25519.PublicKey)),
+		Algorithm: k.algorithm(),
+		Use:       "sig",
+	}
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7518#section-3.4
+type ecdsaKey struct {
+	key *ecdsa.PrivateKey
+}
+
+// The signature is the concatenation of R and S, each on 32 bytes.
+const es256CoordinateLength = 32
+
+func (k *ecdsaKey) algorithm() string {
+	return "ES256"
+}
+
+func (k *ecdsaKey) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, k.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2*es256CoordinateLength)
+	r.FillBytes(out[:es256CoordinateLength])
+	s.FillBytes(out[es256CoordinateLength:])
+	return out, nil
+}
+
+func (k *ecdsaKey) verify(data []byte, signature []byte) bool {
+	return verifyEs256(&k.key.PublicKey, data, signature)
+}
+
+func verifyEs256(key *ecdsa.PublicKey, data []byte, signature []byte) bool {
+	if len(signature) != 2*es256CoordinateLength {
+		return false
+	}
+
+	digest := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(signature[:es256CoordinateLength])
+	s := new(big.Int).SetBytes(signature[es256CoordinateLength:])
+	return ecdsa.Verify(key, digest[:], r, s)
+}
+
+func (k *ecdsaKey) jwk() Jwk {
+	x := make([]byte, es256CoordinateLength)
+	y := make([]byte, es256CoordinateLength)
+	k.key.X.FillBytes(x)
+	k.key.Y.FillBytes(y)
+
+	return Jwk{
+		KeyType:   "EC",
+		Curve:     "P-256",
+		X:         base64.RawURLEncoding.EncodeToString(x),
+		Y:         base64.RawURLEncoding.EncodeToString(y),
+		Algorithm: k.algorithm(),
+		Use:       "sig",
+	}
+}
@@ -0,0 +1,248 @@
+package jwt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+)
+
+// Signer issues tokens and verifies the ones it issued. Other services can
+// verify them as well using the public keys it exposes.
+type Signer interface {
+	Issuer() string
+	Sign(ctx context.Context, claims Claims) (string, error)
+	SignIdToken(ctx context.Context, claims IdClaims) (string, error)
+	Verify(ctx context.Context, token string, now time.Time) (Claims, error)
+	Keys(ctx context.Context) (Jwks, error)
+}
+
+type signerImpl struct {
+	issuer   string
+	audience []string
+	keyring  keyring.Keyring
+
+	lock sync.Mutex
+	// parsed avoids decoding the keys for each token.
+	parsed map[string]signingKey
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7515#section-4
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyId     string `json:"kid,omitempty"`
+}
+
+func NewSigner(config Config, keys keyring.Keyring) Signer {
+	return &signerImpl{
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		keyring:  keys,
+		parsed:   make(map[string]signingKey),
+	}
+}
+
+func (s *signerImpl) Issuer() string {
+	return s.issuer
+}
+
+// Sign fills the issuer and the audience of the claims before signing them
+// with the active key of the keyring.
+func (s *signerImpl) Sign(ctx context.Context, claims Claims) (string, error) {
+	claims.Issuer = s.issuer
+	claims.Audience = s.audience
+
+	return s.sign(ctx, claims)
+}
+
+// SignIdToken fills the issuer of the claims: the audience is the client the
+// token is issued to.
+func (s *signerImpl) SignIdToken(ctx context.Context, claims IdClaims) (string, error) {
+	claims.Issuer = s.issuer
+
+	return s.sign(ctx, claims)
+}
+
+func (s *signerImpl) sign(ctx context.Context, claims any) (string, error) {
+	active, err := s.keyring.Active(ctx, keyring.TokenSigning)
+	if err != nil {
+		return "", err
+	}
+	key, err := s.signingKey(active)
+	if err != nil {
+		return "", err
+	}
+
+	h := header{
+		Algorithm: key.algorithm(),
+		Type:      "JWT",
+		KeyId:     active.Id,
+	}
+
+	encodedHeader, err := encodeSegment(h)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encodedHeader + "." + encodedClaims
+	signature, err := key.sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify accepts tokens signed by any of the keys still accepted by the
+// keyring, so that tokens survive the rotation of the keys.
+func (s *signerImpl) Verify(ctx context.Context, token string, now time.Time) (Claims, error) {
+	var claims Claims
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return claims, errors.NewCode(MalformedToken)
+	}
+
+	var h header
+	if err := decodeSegment(segments[0], &h); err != nil {
+		return claims, err
+	}
+
+	key, err := s.verificationKey(ctx, h.KeyId)
+	if err != nil {
+		return claims, err
+	}
+	// The algorithm is imposed by the key: accepting the one from the
+	// header would allow to downgrade it.
+	if h.Algorithm != key.algorithm() {
+		return claims, errors.NewCode(InvalidSignature)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return claims, errors.WrapCode(err, MalformedToken)
+	}
+	if !key.verify([]byte(segments[0]+"."+segments[1]), signature) {
+		return claims, errors.NewCode(InvalidSignature)
+	}
+
+	if err := decodeSegment(segments[1], &claims); err != nil {
+		return claims, err
+	}
+	if claims.Issuer != s.issuer {
+		return claims, errors.NewCode(InvalidIssuer)
+	}
+	if !s.acceptsAudience(claims.Audience) {
+		return claims, errors.NewCode(InvalidAudience)
+	}
+	if !now.Before(claims.Expiration()) {
+		return claims, errors.NewCode(TokenExpired)
+	}
+
+	return claims, nil
+}
+
+// acceptsAudience checks that the token is intended for at least one of
+// the configured audiences. Tokens are not restricted when none is
+// configured, as they are signed without an audience in this case.
+func (s *signerImpl) acceptsAudience(audience []string) bool {
+	if len(s.audience) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(audience, func(aud string) bool {
+		return slices.Contains(s.audience, aud)
+	})
+}
+
+// Keys publishes all the keys accepted by the keyring, including the ones
+// which are not used yet: this way clients know them before they are needed.
+func (s *signerImpl) Keys(ctx context.Context) (Jwks, error) {
+	accepted, err := s.keyring.Accepted(ctx, keyring.TokenSigning)
+	if err != nil {
+		return Jwks{}, err
+	}
+
+	out := Jwks{
+		Keys: make([]Jwk, 0, len(accepted)),
+	}
+	for _, candidate := range accepted {
+		key, err := s.signingKey(candidate)
+		if err != nil {
+			return Jwks{}, err
+		}
+
+		jwk := key.jwk()
+		jwk.KeyId = candidate.Id
+		out.Keys = append(out.Keys, jwk)
+	}
+
+	return out, nil
+}
+
+func (s *signerImpl) verificationKey(ctx context.Context, keyId string) (signingKey, error) {
+	accepted, err := s.keyring.Accepted(ctx, keyring.TokenSigning)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range accepted {
+		if candidate.Id == keyId {
+			return s.signingKey(candidate)
+		}
+	}
+
+	return nil, errors.NewCode(UnknownKey)
+}
+
+func (s *signerImpl) signingKey(key keyring.Key) (signingKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if parsed, ok := s.parsed[key.Id]; ok {
+		return parsed, nil
+	}
+
+	parsed, err := parsePrivateKey(key.Material)
+	if err != nil {
+		return nil, err
+	}
+
+	s.parsed[key.Id] = parsed
+	return parsed, nil
+}
+
+func encodeSegment(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.WrapCode(err, MalformedToken)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(out); err != nil {
+		return errors.WrapCode(err, MalformedToken)
+	}
+
+	return nil
+}
@@ -0,0 +1,324 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockKeyring struct {
+	keyring.Keyring
+
+	active   keyring.Key
+	accepted []keyring.Key
+}
+
+var someTime = time.Date(2024, 11, 12, 16, 49, 35, 0, time.UTC)
+
+var sampleClaims = Claims{
+	Id:        "0f9ab1f4-6a71-4a8d-9cb7-e0d7bd5a3bb1",
+	Subject:   "0463ed3d-bfc9-4c10-b6ee-c223bbca0fab",
+	Session:   "a5eff7a9-9bd6-4f51-9b42-a7ca5ffd3f5e",
+	Roles:     []string{"user"},
+	IssuedAt:  someTime.Unix(),
+	ExpiresAt: someTime.Add(time.Hour).Unix(),
+}
+
+var testConfig = Config{
+	Enabled:  true,
+	Issuer:   "https://users.example.com",
+	Audience: []string{"my-api"},
+}
+
+func TestUnit_Signer_Sign_ExpectTokenCanBeVerified(t *testing.T) {
+	for _, algorithm := range []string{keyring.EdDSA, keyring.ES256} {
+		t.Run(algorithm, func(t *testing.T) {
+			signer := NewSigner(testConfig, newTestKeyring(t, algorithm))
+
+			token, err := signer.Sign(context.Background(), sampleClaims)
+			require.Nil(t, err)
+			actual, err := signer.Verify(context.Background(), token, someTime)
+
+			assert.Nil(t, err)
+			expected := sampleClaims
+			expected.Issuer = testConfig.Issuer
+			expected.Audience = testConfig.Audience
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestUnit_Signer_SignIdToken_ExpectIssuerIsSetAndAudienceKept(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	signer := NewSigner(testConfig, keys)
+	claims := IdClaims{
+		Subject:   sampleClaims.Subject,
+		Audience:  "my-client",
+		Nonce:     "my-nonce",
+		AuthTime:  someTime.Unix(),
+		IssuedAt:  someTime.Unix(),
+		ExpiresAt: someTime.Add(time.Hour).Unix(),
+		Email:     "user@example.com",
+	}
+
+	token, err := signer.SignIdToken(context.Background(), claims)
+	require.Nil(t, err)
+
+	segments := strings.Split(token, ".")
+	require.Len(t, segments, 3)
+	var h header
+	require.Nil(t, decodeSegment(segments[0], &h))
+	assert.Equal(t, keys.active.Id, h.KeyId)
+	var actual IdClaims
+	require.Nil(t, decodeSegment(segments[1], &actual))
+	expected := claims
+	expected.Issuer = testConfig.Issuer
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_Signer_Sign_WhenKeyIsNotSupported_ExpectError(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.Nil(t, err)
+
+	for _, key := range []any{rsaKey, ecdsaKey} {
+		material, err := x509.MarshalPKCS8PrivateKey(key)
+		require.Nil(t, err)
+		keys := &mockKeyring{
+			active: keyring.Key{Id: "my-key", Material: material},
+		}
+
+		_, err = NewSigner(testConfig, keys).Sign(context.Background(), sampleClaims)
+
+		assert.True(t, errors.IsErrorWithCode(err, UnsupportedKeyType), "Actual err: %v", err)
+	}
+}
+
+func TestUnit_Signer_Sign_WhenKeyIsInvalid_ExpectError(t *testing.T) {
+	keys := &mockKeyring{
+		active: keyring.Key{Id: "my-key", Material: []byte("not-a-key")},
+	}
+
+	_, err := NewSigner(testConfig, keys).Sign(context.Background(), sampleClaims)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPrivateKey), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenKeyWasRotated_ExpectTokenIsStillAccepted(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	signer := NewSigner(testConfig, keys)
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	next := newTestKeyring(t, keyring.EdDSA)
+	keys.active = next.active
+	keys.accepted = append(keys.accepted, next.active)
+	_, err = signer.Verify(context.Background(), token, someTime)
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Signer_Verify_WhenKeyIsNotAcceptedAnymore_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	signer := NewSigner(testConfig, keys)
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	next := newTestKeyring(t, keyring.EdDSA)
+	keys.active = next.active
+	keys.accepted = next.accepted
+	_, err = signer.Verify(context.Background(), token, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownKey), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenTokenIsExpired_ExpectError(t *testing.T) {
+	signer := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	_, err = signer.Verify(context.Background(), token, sampleClaims.Expiration())
+
+	assert.True(t, errors.IsErrorWithCode(err, TokenExpired), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenIssuerIsDifferent_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	otherConfig := testConfig
+	otherConfig.Issuer = "https://another-issuer.example.com"
+	token, err := NewSigner(otherConfig, keys).Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	_, err = NewSigner(testConfig, keys).Verify(context.Background(), token, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidIssuer), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenAudienceIsDifferent_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	otherConfig := testConfig
+	otherConfig.Audience = []string{"another-api"}
+	token, err := NewSigner(otherConfig, keys).Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	_, err = NewSigner(testConfig, keys).Verify(context.Background(), token, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidAudience), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenAudienceIsMissing_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	otherConfig := testConfig
+	otherConfig.Audience = nil
+	token, err := NewSigner(otherConfig, keys).Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	_, err = NewSigner(testConfig, keys).Verify(context.Background(), token, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidAudience), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenOneOfTheAudiencesMatches_ExpectSuccess(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	otherConfig := testConfig
+	otherConfig.Audience = []string{"another-api", "my-api"}
+	token, err := NewSigner(otherConfig, keys).Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	_, err = NewSigner(testConfig, keys).Verify(context.Background(), token, someTime)
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Signer_Verify_WhenSignedByAnotherKeyWithSameId_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	token, err := NewSigner(testConfig, keys).Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	other := newTestKeyring(t, keyring.EdDSA)
+	other.active.Id = keys.active.Id
+	other.accepted[0].Id = keys.active.Id
+	_, err = NewSigner(testConfig, other).Verify(context.Background(), token, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenClaimsAreTamperedWith_ExpectError(t *testing.T) {
+	signer := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+	forged := sampleClaims
+	forged.Roles = []string{"admin"}
+	forgedToken, err := signer.Sign(context.Background(), forged)
+	require.Nil(t, err)
+
+	segments := strings.Split(token, ".")
+	forgedSegments := strings.Split(forgedToken, ".")
+	tampered := segments[0] + "." + forgedSegments[1] + "." + segments[2]
+	_, err = signer.Verify(context.Background(), tampered, someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenAlgorithmIsNone_ExpectError(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	signer := NewSigner(testConfig, keys)
+	token, err := signer.Sign(context.Background(), sampleClaims)
+	require.Nil(t, err)
+
+	header, err := encodeSegment(header{Algorithm: "none", Type: "JWT", KeyId: keys.active.Id})
+	require.Nil(t, err)
+	segments := strings.Split(token, ".")
+	_, err = signer.Verify(context.Background(), header+"."+segments[1]+".", someTime)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Signer_Verify_WhenTokenIsMalformed_ExpectError(t *testing.T) {
+	signer := NewSigner(testConfig, newTestKeyring(t, keyring.EdDSA))
+
+	for _, token := range []string{
+		"",
+		"not-a-token",
+		"a.b",
+		"a.b.c.d",
+		"!!!.b.c",
+	} {
+		_, err := signer.Verify(context.Background(), token, someTime)
+
+		assert.True(t, errors.IsErrorWithCode(err, MalformedToken), "Token: %q, actual err: %v", token, err)
+	}
+}
+
+func TestUnit_Signer_Keys_ExpectPublicKeyIsPublished(t *testing.T) {
+	for name, testCase := range map[string]struct {
+		algorithm string
+		keyType   string
+		curve     string
+		hasY      bool
+	}{
+		"EdDSA": {algorithm: keyring.EdDSA, keyType: "OKP", curve: "Ed25519"},
+		"ES256": {algorithm: keyring.ES256, keyType: "EC", curve: "P-256", hasY: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			keys := newTestKeyring(t, testCase.algorithm)
+
+			actual, err := NewSigner(testConfig, keys).Keys(context.Background())
+
+			assert.Nil(t, err)
+			require.Len(t, actual.Keys, 1)
+			key := actual.Keys[0]
+			assert.Equal(t, testCase.keyType, key.KeyType)
+			assert.Equal(t, testCase.curve, key.Curve)
+			assert.Equal(t, testCase.algorithm, key.Algorithm)
+			assert.Equal(t, keys.active.Id, key.KeyId)
+			assert.Equal(t, "sig", key.Use)
+			assert.NotEmpty(t, key.X)
+			assert.Equal(t, testCase.hasY, key.Y != "")
+		})
+	}
+}
+
+func TestUnit_Signer_Keys_ExpectAllAcceptedKeysArePublished(t *testing.T) {
+	keys := newTestKeyring(t, keyring.EdDSA)
+	pending := newTestKeyring(t, keyring.ES256)
+	keys.accepted = append(keys.accepted, pending.active)
+
+	actual, err := NewSigner(testConfig, keys).Keys(context.Background())
+
+	assert.Nil(t, err)
+	require.Len(t, actual.Keys, 2)
+	assert.Equal(t, keys.active.Id, actual.Keys[0].KeyId)
+	assert.Equal(t, pending.active.Id, actual.Keys[1].KeyId)
+}
+
+func newTestKeyring(t *testing.T, algorithm string) *mockKeyring {
+	key, err := keyring.GenerateKey(keyring.TokenSigning, algorithm, someTime)
+	require.Nil(t, err)
+	key.State = keyring.Active
+
+	return &mockKeyring{
+		active:   key,
+		accepted: []keyring.Key{key},
+	}
+}
+
+func (m *mockKeyring) Active(ctx context.Context, purpose keyring.Purpose) (keyring.Key, error) {
+	return m.active, nil
+}
+
+func (m *mockKeyring) Accepted(ctx context.Context, purpose keyring.Purpose) ([]keyring.Key, error) {
+	return m.accepted, nil
+}
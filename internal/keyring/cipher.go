@@ -0,0 +1,56 @@
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// keyCipher encrypts the keys with AES-GCM under the master key. The id and
+// the purpose of the key are authenticated as well so that the encrypted
+// material can't be moved to another row.
+type keyCipher struct {
+	aead cipher.AEAD
+}
+
+func newKeyCipher(masterKey []byte) (keyCipher, error) {
+	block, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return keyCipher{}, errors.WrapCode(err, InvalidMasterKey)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return keyCipher{}, errors.WrapCode(err, InvalidMasterKey)
+	}
+
+	return keyCipher{aead: aead}, nil
+}
+
+// encrypt returns the nonce followed by the encrypted material.
+func (c keyCipher) encrypt(key Key) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	rand.Read(nonce)
+
+	return c.aead.Seal(nonce, nonce, key.Material, associatedData(key))
+}
+
+func (c keyCipher) decrypt(key Key, encrypted []byte) ([]byte, error) {
+	if len(encrypted) < c.aead.NonceSize() {
+		return nil, errors.NewCode(DecryptionFailed)
+	}
+
+	nonce, ciphertext := encrypted[:c.aead.NonceSize()], encrypted[c.aead.NonceSize():]
+	material, err := c.aead.Open(nil, nonce, ciphertext, associatedData(key))
+	if err != nil {
+		return nil, errors.WrapCode(err, DecryptionFailed)
+	}
+
+	return material, nil
+}
+
+func associatedData(key Key) []byte {
+	return []byte(string(key.Purpose) + "/" + key.Id)
+}
@@ -0,0 +1,64 @@
+package keyring
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_KeyCipher_ExpectKeyCanBeDecrypted(t *testing.T) {
+	cipher := newTestCipher(t, "k")
+	key := newTestKey(t)
+
+	encrypted := cipher.encrypt(key)
+	actual, err := cipher.decrypt(key, encrypted)
+
+	assert.Nil(t, err)
+	assert.Equal(t, key.Material, actual)
+	assert.NotContains(t, string(encrypted), string(key.Material))
+}
+
+func TestUnit_KeyCipher_WhenMasterKeyIsDifferent_ExpectError(t *testing.T) {
+	key := newTestKey(t)
+	encrypted := newTestCipher(t, "k").encrypt(key)
+
+	_, err := newTestCipher(t, "o").decrypt(key, encrypted)
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestUnit_KeyCipher_WhenKeyIdIsDifferent_ExpectError(t *testing.T) {
+	cipher := newTestCipher(t, "k")
+	key := newTestKey(t)
+	encrypted := cipher.encrypt(key)
+
+	other := key
+	other.Id = "another-id"
+	_, err := cipher.decrypt(other, encrypted)
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestUnit_KeyCipher_WhenDataIsTruncated_ExpectError(t *testing.T) {
+	cipher := newTestCipher(t, "k")
+
+	_, err := cipher.decrypt(newTestKey(t), []byte("short"))
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func newTestCipher(t *testing.T, fill string) keyCipher {
+	cipher, err := newKeyCipher([]byte(strings.Repeat(fill, masterKeyLength)))
+	require.Nil(t, err)
+	return cipher
+}
+
+func newTestKey(t *testing.T) Key {
+	key, err := GenerateKey(DigestPepper, HS256, time.Now())
+	require.Nil(t, err)
+	return key
+}
@@ -0,0 +1,75 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const masterKeyLength = 32
+
+type Config struct {
+	// MasterKey is the base64 encoded 32 bytes key encrypting the keys stored
+	// in the database. It is meant to come from the ENV_KEYRING_MASTERKEY
+	// environment variable rather than from the configuration file.
+	MasterKey string
+	// MasterKeyFile is the path to a file holding the master key, in the
+	// same format. It takes precedence over MasterKey. The keyring is
+	// disabled when neither is set.
+	MasterKeyFile string
+	// RotationInterval is how long a key is used before a new one is
+	// generated to replace it.
+	RotationInterval time.Duration
+	// ActivationDelay is how long a new key is published before it is used:
+	// this lets other instances and services pick it up in the meantime.
+	ActivationDelay time.Duration
+	// GracePeriod is how long a replaced key is still accepted. It should be
+	// longer than anything computed with it stays valid.
+	GracePeriod time.Duration
+	// SigningAlgorithm is the algorithm of the keys signing tokens: either
+	// EdDSA or ES256.
+	SigningAlgorithm string
+}
+
+func (c Config) Enabled() bool {
+	return c.MasterKey != "" || c.MasterKeyFile != ""
+}
+
+func (c Config) Validate() error {
+	if c.RotationInterval <= 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "rotation interval must be positive")
+	}
+	if c.ActivationDelay < 0 || c.GracePeriod < 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "durations can't be negative")
+	}
+	if c.SigningAlgorithm != EdDSA && c.SigningAlgorithm != ES256 {
+		return errors.NewCodeWithDetails(UnsupportedAlgorithm, c.SigningAlgorithm)
+	}
+
+	_, err := c.loadMasterKey()
+	return err
+}
+
+func (c Config) loadMasterKey() ([]byte, error) {
+	encoded := c.MasterKey
+	if c.MasterKeyFile != "" {
+		data, err := os.ReadFile(c.MasterKeyFile)
+		if err != nil {
+			return nil, errors.WrapCode(err, InvalidMasterKey)
+		}
+		encoded = string(data)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidMasterKey)
+	}
+	if len(key) != masterKeyLength {
+		return nil, errors.NewCode(InvalidMasterKey)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,99 @@
+package keyring
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleMasterKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", masterKeyLength)))
+
+func TestUnit_Config_Enabled(t *testing.T) {
+	assert.False(t, Config{}.Enabled())
+	assert.True(t, Config{MasterKey: sampleMasterKey}.Enabled())
+	assert.True(t, Config{MasterKeyFile: "master.key"}.Enabled())
+}
+
+func TestUnit_Config_Validate(t *testing.T) {
+	err := newTestConfig().Validate()
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Config_Validate_WhenRotationIntervalIsZero_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.RotationInterval = 0
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenAlgorithmIsNotSupported_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.SigningAlgorithm = "RS256"
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedAlgorithm), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenMasterKeyIsTooShort_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.MasterKey = base64.StdEncoding.EncodeToString([]byte("too-short"))
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMasterKey), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenMasterKeyIsNotBase64_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.MasterKey = "not-base-64"
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMasterKey), "Actual err: %v", err)
+}
+
+func TestUnit_Config_LoadMasterKey_WhenFileIsProvided_ExpectKeyFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "master.key")
+	err := os.WriteFile(path, []byte(sampleMasterKey+"\n"), 0600)
+	require.Nil(t, err)
+	config := Config{
+		MasterKey:     base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", masterKeyLength))),
+		MasterKeyFile: path,
+	}
+
+	actual, err := config.loadMasterKey()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(strings.Repeat("k", masterKeyLength)), actual)
+}
+
+func TestUnit_Config_LoadMasterKey_WhenFileDoesNotExist_ExpectError(t *testing.T) {
+	config := Config{
+		MasterKeyFile: filepath.Join(t.TempDir(), "master.key"),
+	}
+
+	_, err := config.loadMasterKey()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMasterKey), "Actual err: %v", err)
+}
+
+func newTestConfig() Config {
+	return Config{
+		MasterKey:        sampleMasterKey,
+		RotationInterval: 24 * time.Hour,
+		ActivationDelay:  time.Hour,
+		GracePeriod:      2 * time.Hour,
+		SigningAlgorithm: EdDSA,
+	}
+}
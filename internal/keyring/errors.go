@@ -0,0 +1,15 @@
+package keyring
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidMasterKey     errors.ErrorCode = 1500
+	InvalidConfiguration errors.ErrorCode = 1501
+	UnsupportedAlgorithm errors.ErrorCode = 1502
+	UnknownPurpose       errors.ErrorCode = 1503
+
+	DecryptionFailed errors.ErrorCode = 1510
+	NoActiveKey      errors.ErrorCode = 1511
+)
@@ -0,0 +1,123 @@
+package keyring
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Purpose tells what a key is used for: keys are never shared between
+// purposes and are rotated independently.
+type Purpose string
+
+const (
+	TokenSigning Purpose = "token-signing"
+	DigestPepper Purpose = "digest-pepper"
+)
+
+var purposes = []Purpose{TokenSigning, DigestPepper}
+
+func ParsePurpose(raw string) (Purpose, error) {
+	for _, purpose := range purposes {
+		if string(purpose) == raw {
+			return purpose, nil
+		}
+	}
+
+	return "", errors.NewCodeWithDetails(UnknownPurpose, raw)
+}
+
+// Signing keys are stored as PKCS #8 documents and MAC keys as raw bytes.
+const (
+	EdDSA        = "EdDSA"
+	ES256        = "ES256"
+	HS256        = "HS256"
+	macKeyLength = 32
+)
+
+type State string
+
+const (
+	// Pending keys are published but not used yet.
+	Pending State = "pending"
+	// The active key is the one used to sign or compute new values.
+	Active State = "active"
+	// Retired keys are still accepted until the grace period is over.
+	Retired State = "retired"
+)
+
+type Key struct {
+	// Id is the version of the key, as found in the kid of tokens. The
+	// identifiers of the keys of a purpose are increasing.
+	Id        string
+	Purpose   Purpose
+	Algorithm string
+	// Material is the key in clear: it is only encrypted in the database.
+	Material []byte
+	State    State
+
+	CreatedAt   time.Time
+	ActivatedAt *time.Time
+	RetiredAt   *time.Time
+}
+
+func GenerateKey(purpose Purpose, algorithm string, now time.Time) (Key, error) {
+	material, err := generateMaterial(algorithm)
+	if err != nil {
+		return Key{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Id:        id.String(),
+		Purpose:   purpose,
+		Algorithm: algorithm,
+		Material:  material,
+		State:     Pending,
+		CreatedAt: now,
+	}, nil
+}
+
+func generateMaterial(algorithm string) ([]byte, error) {
+	switch algorithm {
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS8PrivateKey(key)
+	case ES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return x509.MarshalPKCS8PrivateKey(key)
+	case HS256:
+		material := make([]byte, macKeyLength)
+		// https://pkg.go.dev/crypto/rand#Read
+		rand.Read(material)
+		return material, nil
+	default:
+		return nil, errors.NewCodeWithDetails(UnsupportedAlgorithm, algorithm)
+	}
+}
+
+// acceptedAt returns false for keys which were retired for longer than the
+// grace period.
+func (k Key) acceptedAt(now time.Time, gracePeriod time.Duration) bool {
+	if k.State != Retired || k.RetiredAt == nil {
+		return true
+	}
+
+	return now.Before(k.RetiredAt.Add(gracePeriod))
+}
@@ -0,0 +1,61 @@
+package keyring
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_ParsePurpose(t *testing.T) {
+	actual, err := ParsePurpose("token-signing")
+
+	assert.Nil(t, err)
+	assert.Equal(t, TokenSigning, actual)
+}
+
+func TestUnit_ParsePurpose_WhenUnknown_ExpectError(t *testing.T) {
+	_, err := ParsePurpose("not-a-purpose")
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownPurpose), "Actual err: %v", err)
+}
+
+func TestUnit_GenerateKey_ExpectPendingKey(t *testing.T) {
+	now := time.Now()
+
+	actual, err := GenerateKey(DigestPepper, HS256, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, DigestPepper, actual.Purpose)
+	assert.Equal(t, Pending, actual.State)
+	assert.Equal(t, now, actual.CreatedAt)
+	assert.Len(t, actual.Material, macKeyLength)
+}
+
+func TestUnit_GenerateKey_WhenSigningKey_ExpectPkcs8Material(t *testing.T) {
+	for _, algorithm := range []string{EdDSA, ES256} {
+		actual, err := GenerateKey(TokenSigning, algorithm, time.Now())
+		require.Nil(t, err)
+
+		_, err = x509.ParsePKCS8PrivateKey(actual.Material)
+		assert.Nil(t, err, "Algorithm: %s", algorithm)
+	}
+}
+
+func TestUnit_GenerateKey_ExpectIncreasingIds(t *testing.T) {
+	first, err := GenerateKey(DigestPepper, HS256, time.Now())
+	require.Nil(t, err)
+	second, err := GenerateKey(DigestPepper, HS256, time.Now())
+	require.Nil(t, err)
+
+	assert.Less(t, first.Id, second.Id)
+}
+
+func TestUnit_GenerateKey_WhenAlgorithmIsNotSupported_ExpectError(t *testing.T) {
+	_, err := GenerateKey(TokenSigning, "RS256", time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedAlgorithm), "Actual err: %v", err)
+}
@@ -0,0 +1,289 @@
+package keyring
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+)
+
+// Keys are reloaded from the database at most this often: changes made by
+// other instances are picked up within this delay.
+const reloadInterval = 10 * time.Second
+
+// Keyring holds the secrets of the service. Keys are stored encrypted in the
+// database and rotated whenever they are reloaded, so that all the instances
+// of the service share them.
+type Keyring interface {
+	// Active returns the key to use to sign or compute new values.
+	Active(ctx context.Context, purpose Purpose) (Key, error)
+	// Accepted returns the keys to verify values with: the pending and the
+	// active keys as well as the ones retired during the grace period.
+	Accepted(ctx context.Context, purpose Purpose) ([]Key, error)
+	// EmergencyRotate replaces the active key right away and drops all the
+	// other ones: values computed with them are rejected immediately.
+	EmergencyRotate(ctx context.Context, purpose Purpose) (Key, error)
+}
+
+type keyringImpl struct {
+	conn   db.Connection
+	repo   repositories.KeyringRepository
+	cipher keyCipher
+	config Config
+
+	lock  sync.Mutex
+	cache map[Purpose]cachedKeys
+}
+
+type cachedKeys struct {
+	keys     []Key
+	loadedAt time.Time
+}
+
+// New loads the keys of all the purposes, generating them if needed: this
+// verifies that the master key can decrypt them.
+func New(ctx context.Context, config Config, conn db.Connection, repo repositories.KeyringRepository) (Keyring, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	masterKey, err := config.loadMasterKey()
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := newKeyCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &keyringImpl{
+		conn:   conn,
+		repo:   repo,
+		cipher: cipher,
+		config: config,
+		cache:  make(map[Purpose]cachedKeys),
+	}
+
+	for _, purpose := range purposes {
+		if _, err := k.keys(ctx, purpose); err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+func (k *keyringImpl) Active(ctx context.Context, purpose Purpose) (Key, error) {
+	keys, err := k.keys(ctx, purpose)
+	if err != nil {
+		return Key{}, err
+	}
+
+	for _, key := range keys {
+		if key.State == Active {
+			return key, nil
+		}
+	}
+
+	return Key{}, errors.NewCode(NoActiveKey)
+}
+
+func (k *keyringImpl) Accepted(ctx context.Context, purpose Purpose) ([]Key, error) {
+	keys, err := k.keys(ctx, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var out []Key
+	for _, key := range keys {
+		if key.acceptedAt(now, k.config.GracePeriod) {
+			out = append(out, key)
+		}
+	}
+
+	return out, nil
+}
+
+func (k *keyringImpl) EmergencyRotate(ctx context.Context, purpose Purpose) (Key, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	key, err := k.replaceKeys(ctx, purpose, time.Now())
+	// Whatever happened, the keys need to be reloaded.
+	delete(k.cache, purpose)
+	return key, err
+}
+
+func (k *keyringImpl) keys(ctx context.Context, purpose Purpose) ([]Key, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	now := time.Now()
+	if cached, ok := k.cache[purpose]; ok && now.Sub(cached.loadedAt) < reloadInterval {
+		return cached.keys, nil
+	}
+
+	keys, err := k.load(ctx, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	if !planRotation(keys, now, k.config).empty() {
+		err = k.rotate(ctx, purpose, now)
+		if err != nil {
+			return nil, err
+		}
+
+		keys, err = k.load(ctx, purpose)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	k.cache[purpose] = cachedKeys{
+		keys:     keys,
+		loadedAt: now,
+	}
+	return keys, nil
+}
+
+func (k *keyringImpl) load(ctx context.Context, purpose Purpose) ([]Key, error) {
+	rows, err := k.repo.ListForPurpose(ctx, string(purpose))
+	if err != nil {
+		return nil, err
+	}
+
+	return k.decryptAll(rows)
+}
+
+func (k *keyringImpl) rotate(ctx context.Context, purpose Purpose, now time.Time) error {
+	tx, err := k.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	rows, err := k.repo.LockForPurpose(ctx, tx, string(purpose))
+	if err != nil {
+		return err
+	}
+	keys, err := k.decryptAll(rows)
+	if err != nil {
+		return err
+	}
+
+	// Another instance may have rotated the keys in the meantime.
+	plan := planRotation(keys, now, k.config)
+
+	for _, id := range plan.remove {
+		if err := k.repo.Delete(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	// Only one key can be active at a time.
+	if plan.retire != "" {
+		if err := k.repo.Retire(ctx, tx, plan.retire, now); err != nil {
+			return err
+		}
+	}
+	if plan.activate != "" {
+		if err := k.repo.Activate(ctx, tx, plan.activate, now); err != nil {
+			return err
+		}
+	}
+	if plan.bootstrap || plan.generate {
+		key, err := GenerateKey(purpose, k.algorithm(purpose), now)
+		if err != nil {
+			return err
+		}
+		if plan.bootstrap {
+			key.State = Active
+			key.ActivatedAt = &now
+		}
+
+		if _, err := k.repo.Create(ctx, tx, k.encrypt(key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *keyringImpl) replaceKeys(ctx context.Context, purpose Purpose, now time.Time) (Key, error) {
+	tx, err := k.conn.BeginTx(ctx)
+	if err != nil {
+		return Key{}, err
+	}
+	defer tx.Close(ctx)
+
+	rows, err := k.repo.LockForPurpose(ctx, tx, string(purpose))
+	if err != nil {
+		return Key{}, err
+	}
+	for _, row := range rows {
+		if err := k.repo.Delete(ctx, tx, row.Id); err != nil {
+			return Key{}, err
+		}
+	}
+
+	key, err := GenerateKey(purpose, k.algorithm(purpose), now)
+	if err != nil {
+		return Key{}, err
+	}
+	key.State = Active
+	key.ActivatedAt = &now
+
+	_, err = k.repo.Create(ctx, tx, k.encrypt(key))
+	return key, err
+}
+
+func (k *keyringImpl) algorithm(purpose Purpose) string {
+	if purpose == TokenSigning {
+		return k.config.SigningAlgorithm
+	}
+
+	return HS256
+}
+
+func (k *keyringImpl) encrypt(key Key) persistence.KeyringKey {
+	return persistence.KeyringKey{
+		Id:          key.Id,
+		Purpose:     string(key.Purpose),
+		Algorithm:   key.Algorithm,
+		Material:    k.cipher.encrypt(key),
+		State:       string(key.State),
+		CreatedAt:   key.CreatedAt,
+		ActivatedAt: key.ActivatedAt,
+		RetiredAt:   key.RetiredAt,
+	}
+}
+
+func (k *keyringImpl) decryptAll(rows []persistence.KeyringKey) ([]Key, error) {
+	out := make([]Key, 0, len(rows))
+	for _, row := range rows {
+		key := Key{
+			Id:          row.Id,
+			Purpose:     Purpose(row.Purpose),
+			Algorithm:   row.Algorithm,
+			State:       State(row.State),
+			CreatedAt:   row.CreatedAt,
+			ActivatedAt: row.ActivatedAt,
+			RetiredAt:   row.RetiredAt,
+		}
+
+		material, err := k.cipher.decrypt(key, row.Material)
+		if err != nil {
+			return nil, err
+		}
+		key.Material = material
+
+		out = append(out, key)
+	}
+
+	return out, nil
+}
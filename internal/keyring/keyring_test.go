@@ -0,0 +1,84 @@
+package keyring
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/postgresql"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dbTestConfig = postgresql.NewConfigForLocalhost("db_user_service", "user_service_manager", "manager_password")
+
+func TestIT_Keyring_New_ExpectActiveKeyForAllPurposes(t *testing.T) {
+	keyring := newTestKeyring(t)
+
+	for _, purpose := range purposes {
+		actual, err := keyring.Active(context.Background(), purpose)
+
+		assert.Nil(t, err)
+		assert.Equal(t, purpose, actual.Purpose)
+		assert.Equal(t, Active, actual.State)
+		assert.NotEmpty(t, actual.Material)
+	}
+}
+
+func TestIT_Keyring_New_WhenMasterKeyIsDifferent_ExpectError(t *testing.T) {
+	newTestKeyring(t)
+	conn := newTestConnection(t)
+	config := newTestConfig()
+	config.MasterKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", masterKeyLength)))
+
+	_, err := New(context.Background(), config, conn, repositories.NewKeyringRepository(conn))
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestIT_Keyring_Accepted_ExpectActiveKeyIsIncluded(t *testing.T) {
+	keyring := newTestKeyring(t)
+	active, err := keyring.Active(context.Background(), DigestPepper)
+	require.Nil(t, err)
+
+	actual, err := keyring.Accepted(context.Background(), DigestPepper)
+
+	assert.Nil(t, err)
+	assert.Contains(t, actual, active)
+}
+
+func TestIT_Keyring_EmergencyRotate_ExpectPreviousKeysAreDropped(t *testing.T) {
+	keyring := newTestKeyring(t)
+	previous, err := keyring.Active(context.Background(), TokenSigning)
+	require.Nil(t, err)
+
+	rotated, err := keyring.EmergencyRotate(context.Background(), TokenSigning)
+	require.Nil(t, err)
+
+	assert.NotEqual(t, previous.Id, rotated.Id)
+	active, err := keyring.Active(context.Background(), TokenSigning)
+	assert.Nil(t, err)
+	assert.Equal(t, rotated.Id, active.Id)
+	accepted, err := keyring.Accepted(context.Background(), TokenSigning)
+	assert.Nil(t, err)
+	require.Len(t, accepted, 1)
+	assert.Equal(t, rotated.Id, accepted[0].Id)
+}
+
+func newTestConnection(t *testing.T) db.Connection {
+	conn, err := db.New(context.Background(), dbTestConfig)
+	require.Nil(t, err)
+	return conn
+}
+
+func newTestKeyring(t *testing.T) Keyring {
+	conn := newTestConnection(t)
+
+	keyring, err := New(context.Background(), newTestConfig(), conn, repositories.NewKeyringRepository(conn))
+	require.Nil(t, err)
+	return keyring
+}
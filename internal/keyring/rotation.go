@@ -0,0 +1,63 @@
+package keyring
+
+import (
+	"time"
+)
+
+// rotationPlan lists the changes bringing the keys of a purpose in line with
+// the configuration.
+type rotationPlan struct {
+	// bootstrap generates a key which is used right away: this only happens
+	// when there's no key at all.
+	bootstrap bool
+	// generate creates a pending key which is activated later on.
+	generate bool
+	activate string
+	retire   string
+	remove   []string
+}
+
+func (p rotationPlan) empty() bool {
+	return !p.bootstrap && !p.generate && p.activate == "" && p.retire == "" && len(p.remove) == 0
+}
+
+// planRotation expects the keys to be sorted by creation date.
+func planRotation(keys []Key, now time.Time, config Config) rotationPlan {
+	var plan rotationPlan
+
+	var active, pending *Key
+	for i := range keys {
+		switch keys[i].State {
+		case Active:
+			active = &keys[i]
+		case Pending:
+			pending = &keys[i]
+		case Retired:
+			if !keys[i].acceptedAt(now, config.GracePeriod) {
+				plan.remove = append(plan.remove, keys[i].Id)
+			}
+		}
+	}
+
+	switch {
+	case active == nil && pending == nil:
+		plan.bootstrap = true
+	case active == nil:
+		plan.activate = pending.Id
+	case pending != nil:
+		if !now.Before(pending.CreatedAt.Add(config.ActivationDelay)) {
+			plan.retire = active.Id
+			plan.activate = pending.Id
+		}
+	default:
+		activatedAt := active.CreatedAt
+		if active.ActivatedAt != nil {
+			activatedAt = *active.ActivatedAt
+		}
+		if !now.Before(activatedAt.Add(config.RotationInterval)) {
+			plan.generate = true
+		}
+	}
+
+	return plan
+}
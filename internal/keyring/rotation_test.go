@@ -0,0 +1,91 @@
+package keyring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var someTime = time.Date(2024, 11, 12, 16, 49, 35, 0, time.UTC)
+
+func TestUnit_PlanRotation_WhenNoKeys_ExpectBootstrap(t *testing.T) {
+	actual := planRotation(nil, someTime, newTestConfig())
+
+	assert.Equal(t, rotationPlan{bootstrap: true}, actual)
+}
+
+func TestUnit_PlanRotation_WhenActiveKeyIsRecent_ExpectNothing(t *testing.T) {
+	keys := []Key{
+		newActiveTestKey("1", someTime.Add(-time.Hour)),
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.True(t, actual.empty())
+}
+
+func TestUnit_PlanRotation_WhenActiveKeyIsOld_ExpectNewPendingKey(t *testing.T) {
+	keys := []Key{
+		newActiveTestKey("1", someTime.Add(-24*time.Hour)),
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.Equal(t, rotationPlan{generate: true}, actual)
+}
+
+func TestUnit_PlanRotation_WhenPendingKeyIsRecent_ExpectNothing(t *testing.T) {
+	keys := []Key{
+		newActiveTestKey("1", someTime.Add(-25*time.Hour)),
+		{Id: "2", State: Pending, CreatedAt: someTime.Add(-30 * time.Minute)},
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.True(t, actual.empty())
+}
+
+func TestUnit_PlanRotation_WhenPendingKeyIsPublishedForLongEnough_ExpectItIsActivated(t *testing.T) {
+	keys := []Key{
+		newActiveTestKey("1", someTime.Add(-25*time.Hour)),
+		{Id: "2", State: Pending, CreatedAt: someTime.Add(-time.Hour)},
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.Equal(t, rotationPlan{activate: "2", retire: "1"}, actual)
+}
+
+func TestUnit_PlanRotation_WhenOnlyPendingKey_ExpectItIsActivated(t *testing.T) {
+	keys := []Key{
+		{Id: "2", State: Pending, CreatedAt: someTime},
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.Equal(t, rotationPlan{activate: "2"}, actual)
+}
+
+func TestUnit_PlanRotation_WhenRetiredKeyIsPastGracePeriod_ExpectItIsRemoved(t *testing.T) {
+	recentlyRetired := someTime.Add(-time.Hour)
+	longRetired := someTime.Add(-2 * time.Hour)
+	keys := []Key{
+		{Id: "1", State: Retired, RetiredAt: &longRetired},
+		{Id: "2", State: Retired, RetiredAt: &recentlyRetired},
+		newActiveTestKey("3", someTime.Add(-time.Hour)),
+	}
+
+	actual := planRotation(keys, someTime, newTestConfig())
+
+	assert.Equal(t, rotationPlan{remove: []string{"1"}}, actual)
+}
+
+func newActiveTestKey(id string, activatedAt time.Time) Key {
+	return Key{
+		Id:          id,
+		State:       Active,
+		CreatedAt:   activatedAt,
+		ActivatedAt: &activatedAt,
+	}
+}
@@ -0,0 +1,88 @@
+package mail
+
+import (
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type Transport string
+
+const (
+	// DisabledTransport does not send any message: the features relying
+	// on them (verification, password reset, login by email) are off.
+	DisabledTransport Transport = "disabled"
+	// LogTransport writes the recipient and the subject of the messages to
+	// the logs of the service instead of sending them. It is meant for
+	// development.
+	LogTransport Transport = "log"
+	// FileTransport writes each message to a file of the outbox directory.
+	FileTransport Transport = "file"
+	SmtpTransport Transport = "smtp"
+)
+
+type Config struct {
+	Transport Transport
+	// From is the address the messages are sent from.
+	From string
+	// Outbox is the directory the file transport writes the messages to.
+	Outbox string
+	Smtp   SmtpConfig
+}
+
+type SmtpConfig struct {
+	Host string
+	Port uint16
+	// Username is used to authenticate to the server when set. This is only
+	// done over TLS: the server has to support STARTTLS unless it runs on
+	// localhost.
+	Username string
+	// Password is meant to come from the ENV_MAIL_SMTP_PASSWORD environment
+	// variable rather than from the configuration file.
+	Password string
+	// PasswordFile is the path to a file holding the password. It takes
+	// precedence over Password.
+	PasswordFile string
+}
+
+func (c Config) Validate() error {
+	if c.Transport == DisabledTransport {
+		return nil
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "invalid sender address: "+c.From)
+	}
+
+	switch c.Transport {
+	case LogTransport:
+		return nil
+	case FileTransport:
+		if c.Outbox == "" {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "outbox directory must be set")
+		}
+		return nil
+	case SmtpTransport:
+		if c.Smtp.Host == "" || c.Smtp.Port == 0 {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "smtp host and port must be set")
+		}
+		_, err := c.Smtp.loadPassword()
+		return err
+	default:
+		return errors.NewCodeWithDetails(InvalidConfiguration, "unsupported transport: "+string(c.Transport))
+	}
+}
+
+func (c SmtpConfig) loadPassword() (string, error) {
+	if c.PasswordFile == "" {
+		return c.Password, nil
+	}
+
+	data, err := os.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", errors.WrapCode(err, InvalidSmtpPassword)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
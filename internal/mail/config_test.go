@@ -0,0 +1,106 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Config_Validate(t *testing.T) {
+	for _, transport := range []Transport{LogTransport, FileTransport, SmtpTransport} {
+		config := newTestConfig()
+		config.Transport = transport
+
+		err := config.Validate()
+
+		assert.Nil(t, err, "Transport: %s", transport)
+	}
+}
+
+func TestUnit_Config_Validate_WhenTransportIsDisabled_ExpectNoSenderRequired(t *testing.T) {
+	config := Config{
+		Transport: DisabledTransport,
+	}
+
+	err := config.Validate()
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Config_Validate_WhenSenderIsInvalid_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.From = "not-an-address"
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenTransportIsUnknown_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.Transport = "pigeon"
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenOutboxIsMissing_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.Transport = FileTransport
+	config.Outbox = ""
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenSmtpServerIsMissing_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.Transport = SmtpTransport
+	config.Smtp.Port = 0
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_Config_Validate_WhenSmtpPasswordFileDoesNotExist_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.Transport = SmtpTransport
+	config.Smtp.PasswordFile = filepath.Join(t.TempDir(), "missing")
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSmtpPassword), "Actual err: %v", err)
+}
+
+func TestUnit_SmtpConfig_LoadPassword_WhenFileIsSet_ExpectPasswordFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	err := os.WriteFile(path, []byte("password-from-file\n"), 0o600)
+	assert.Nil(t, err)
+	config := SmtpConfig{
+		Password:     "password-from-config",
+		PasswordFile: path,
+	}
+
+	actual, err := config.loadPassword()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "password-from-file", actual)
+}
+
+func newTestConfig() Config {
+	return Config{
+		Transport: LogTransport,
+		From:      "user-service@example.com",
+		Outbox:    "outbox",
+		Smtp: SmtpConfig{
+			Host: "localhost",
+			Port: 25,
+		},
+	}
+}
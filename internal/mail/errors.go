@@ -0,0 +1,13 @@
+package mail
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration errors.ErrorCode = 2000
+	InvalidSmtpPassword  errors.ErrorCode = 2001
+
+	InvalidMessage errors.ErrorCode = 2010
+	DeliveryFailed errors.ErrorCode = 2011
+)
@@ -0,0 +1,181 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Mailer delivers messages to the users.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New creates the mailer of the transport selected in the configuration.
+// Delivery failures are logged before being returned. No mailer is
+// returned when the transport is disabled.
+func New(config Config, log *slog.Logger) (Mailer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Transport == DisabledTransport {
+		return nil, nil
+	}
+
+	var transport Mailer
+	switch config.Transport {
+	case FileTransport:
+		transport = NewFileMailer(config.From, config.Outbox)
+	case SmtpTransport:
+		password, err := config.Smtp.loadPassword()
+		if err != nil {
+			return nil, err
+		}
+		transport = NewSmtpMailer(config.From, config.Smtp, password)
+	default:
+		transport = NewLogMailer(log)
+	}
+
+	return &loggingMailer{
+		transport: transport,
+		log:       log,
+	}, nil
+}
+
+type loggingMailer struct {
+	transport Mailer
+	log       *slog.Logger
+}
+
+func (m *loggingMailer) Send(ctx context.Context, message Message) error {
+	err := m.transport.Send(ctx, message)
+	if err != nil {
+		m.log.Warn("Failed to send message", slog.String("subject", message.Subject), slog.Any("error", err))
+	}
+	return err
+}
+
+type logMailer struct {
+	log *slog.Logger
+}
+
+// NewLogMailer creates a mailer writing the messages to the logs. Only the
+// recipient and the subject are written: the body holds secrets such as
+// verification links.
+func NewLogMailer(log *slog.Logger) Mailer {
+	return &logMailer{
+		log: log,
+	}
+}
+
+func (m *logMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	m.log.Info(
+		"Sending message",
+		slog.String("to", message.To),
+		slog.String("subject", message.Subject),
+	)
+	return nil
+}
+
+type fileMailer struct {
+	from   string
+	outbox string
+}
+
+// NewFileMailer creates a mailer writing each message to a file of the
+// outbox directory, in the format of the messages sent by SMTP. This lets
+// the messages be inspected without a mail server.
+func NewFileMailer(from string, outbox string) Mailer {
+	return &fileMailer{
+		from:   from,
+		outbox: outbox,
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	data, err := message.format(m.from, now)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.outbox, 0o750); err != nil {
+		return errors.WrapCode(err, DeliveryFailed)
+	}
+
+	// Files are named after the time they are written at so that listing
+	// the outbox shows the messages in order.
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), uuid.New())
+	if err := os.WriteFile(filepath.Join(m.outbox, name), data, 0o640); err != nil {
+		return errors.WrapCode(err, DeliveryFailed)
+	}
+
+	return nil
+}
+
+type smtpMailer struct {
+	from     string
+	config   SmtpConfig
+	password string
+}
+
+// NewSmtpMailer creates a mailer relaying the messages to an SMTP server.
+// The connection is upgraded with STARTTLS when the server supports it.
+func NewSmtpMailer(from string, config SmtpConfig, password string) Mailer {
+	return &smtpMailer{
+		from:     from,
+		config:   config,
+		password: password,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	data, err := message.format(m.from, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.password, m.config.Host)
+	}
+
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return errors.WrapCode(err, InvalidConfiguration)
+	}
+	recipient, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return errors.WrapCode(err, InvalidMessage)
+	}
+
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(int(m.config.Port)))
+	err = smtp.SendMail(address, auth, sender.Address, []string{recipient.Address}, data)
+	if err != nil {
+		return errors.WrapCode(err, DeliveryFailed)
+	}
+
+	return nil
+}
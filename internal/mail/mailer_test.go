@@ -0,0 +1,218 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleMessage = Message{
+	To:      "user@example.com",
+	Subject: "Verify your email",
+	Body:    "Open this link: https://example.com/verify?token=my-token",
+}
+
+func TestUnit_New_WhenConfigIsInvalid_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.Transport = "pigeon"
+
+	_, err := New(config, slog.New(slog.DiscardHandler))
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_New_WhenTransportIsDisabled_ExpectNoMailer(t *testing.T) {
+	config := Config{
+		Transport: DisabledTransport,
+	}
+
+	actual, err := New(config, slog.New(slog.DiscardHandler))
+
+	assert.Nil(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestUnit_New_WhenDeliveryFails_ExpectFailureIsLogged(t *testing.T) {
+	var logs bytes.Buffer
+	config := newTestConfig()
+	config.Transport = FileTransport
+	// The outbox can't be created below a regular file.
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	require.Nil(t, os.WriteFile(blocker, nil, 0o600))
+	config.Outbox = filepath.Join(blocker, "outbox")
+	mailer, err := New(config, slog.New(slog.NewTextHandler(&logs, nil)))
+	require.Nil(t, err)
+
+	err = mailer.Send(context.Background(), sampleMessage)
+
+	assert.True(t, errors.IsErrorWithCode(err, DeliveryFailed), "Actual err: %v", err)
+	assert.Contains(t, logs.String(), "Failed to send message")
+}
+
+func TestUnit_LogMailer_Send(t *testing.T) {
+	var logs bytes.Buffer
+	mailer := NewLogMailer(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	err := mailer.Send(context.Background(), sampleMessage)
+
+	assert.Nil(t, err)
+	assert.Contains(t, logs.String(), "user@example.com")
+	assert.Contains(t, logs.String(), "Verify your email")
+	assert.NotContains(t, logs.String(), "my-token")
+}
+
+func TestUnit_LogMailer_Send_WhenMessageIsInvalid_ExpectError(t *testing.T) {
+	mailer := NewLogMailer(slog.New(slog.DiscardHandler))
+
+	err := mailer.Send(context.Background(), Message{To: "not-an-address"})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMessage), "Actual err: %v", err)
+}
+
+func TestUnit_FileMailer_Send(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "outbox")
+	mailer := NewFileMailer("user-service@example.com", outbox)
+
+	err := mailer.Send(context.Background(), sampleMessage)
+
+	require.Nil(t, err)
+	files, err := os.ReadDir(outbox)
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"), "Actual name: %s", files[0].Name())
+	data, err := os.ReadFile(filepath.Join(outbox, files[0].Name()))
+	require.Nil(t, err)
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	require.Nil(t, err)
+	assert.Equal(t, "user@example.com", parsed.Header.Get("To"))
+}
+
+func TestUnit_SmtpMailer_Send(t *testing.T) {
+	server := newTestSmtpServer(t)
+	config := SmtpConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+	}
+	mailer := NewSmtpMailer("user-service@example.com", config, "")
+
+	err := mailer.Send(context.Background(), sampleMessage)
+
+	require.Nil(t, err)
+	received := <-server.received
+	assert.Equal(t, "<user-service@example.com>", received.from)
+	assert.Equal(t, []string{"<user@example.com>"}, received.to)
+	parsed, err := mail.ReadMessage(strings.NewReader(received.data))
+	require.Nil(t, err)
+	assert.Equal(t, "Verify your email", parsed.Header.Get("Subject"))
+}
+
+func TestUnit_SmtpMailer_Send_WhenServerIsUnreachable_ExpectError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+	config := SmtpConfig{
+		Host: "127.0.0.1",
+		Port: port,
+	}
+	mailer := NewSmtpMailer("user-service@example.com", config, "")
+
+	err = mailer.Send(context.Background(), sampleMessage)
+
+	assert.True(t, errors.IsErrorWithCode(err, DeliveryFailed), "Actual err: %v", err)
+}
+
+type testSmtpEnvelope struct {
+	from string
+	to   []string
+	data string
+}
+
+// testSmtpServer accepts a single message, without any extension.
+type testSmtpServer struct {
+	listener net.Listener
+	received chan testSmtpEnvelope
+}
+
+func newTestSmtpServer(t *testing.T) *testSmtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &testSmtpServer{
+		listener: listener,
+		received: make(chan testSmtpEnvelope, 1),
+	}
+	go server.serve()
+	return server
+}
+
+func (s *testSmtpServer) port() uint16 {
+	return uint16(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *testSmtpServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	var envelope testSmtpEnvelope
+	reply("220 localhost ready")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			envelope.from = strings.TrimPrefix(command, "MAIL FROM:")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			envelope.to = append(envelope.to, strings.TrimPrefix(command, "RCPT TO:"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 Go ahead")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			envelope.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			s.received <- envelope
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Message is a plain text email sent to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+func (m Message) validate() error {
+	if _, err := mail.ParseAddress(m.To); err != nil {
+		return errors.WrapCode(err, InvalidMessage)
+	}
+	// Line breaks would let the subject add headers to the message.
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return errors.NewCodeWithDetails(InvalidMessage, "subject can't contain line breaks")
+	}
+
+	return nil
+}
+
+// format returns the message as defined in RFC 5322, ready to be sent. The
+// body is encoded as quoted-printable so that it goes through the servers
+// which don't accept 8-bit data.
+func (m Message) format(from string, now time.Time) ([]byte, error) {
+	var out bytes.Buffer
+
+	domain := from[strings.LastIndex(from, "@")+1:]
+	headers := [][2]string{
+		{"From", from},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", uuid.New(), strings.Trim(domain, "<>"))},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&out, "%s: %s\r\n", header[0], header[1])
+	}
+	out.WriteString("\r\n")
+
+	body := strings.ReplaceAll(m.Body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+
+	writer := quotedprintable.NewWriter(&out)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var someTime = time.Date(2024, 11, 12, 19, 9, 36, 0, time.UTC)
+
+func TestUnit_Message_Format(t *testing.T) {
+	message := Message{
+		To:      "user@example.com",
+		Subject: "Verify your email",
+		Body:    "First line\nSecond line with a long enough content to be wrapped by the quoted-printable encoding of the body",
+	}
+
+	data, err := message.format("user-service@example.com", someTime)
+
+	require.Nil(t, err)
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	require.Nil(t, err)
+	assert.Equal(t, "user-service@example.com", parsed.Header.Get("From"))
+	assert.Equal(t, "user@example.com", parsed.Header.Get("To"))
+	date, err := parsed.Header.Date()
+	require.Nil(t, err)
+	assert.True(t, someTime.Equal(date), "Actual date: %v", date)
+	assert.Regexp(t, `^<[0-9a-f-]{36}@example\.com>$`, parsed.Header.Get("Message-ID"))
+}
+
+func TestUnit_Message_Format_ExpectSubjectAndBodyAreEncoded(t *testing.T) {
+	message := Message{
+		To:      "user@example.com",
+		Subject: "Vérifiez votre adresse",
+		Body:    "Première ligne\nSeconde ligne",
+	}
+
+	data, err := message.format("user-service@example.com", someTime)
+
+	require.Nil(t, err)
+	parsed, err := mail.ReadMessage(bytes.NewReader(data))
+	require.Nil(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.Nil(t, err)
+	assert.Equal(t, message.Subject, subject)
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.Nil(t, err)
+	assert.Equal(t, "Première ligne\r\nSeconde ligne", string(body))
+}
+
+func TestUnit_Message_Validate_WhenRecipientIsInvalid_ExpectError(t *testing.T) {
+	message := Message{
+		To:      "not-an-address",
+		Subject: "subject",
+	}
+
+	err := message.validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMessage), "Actual err: %v", err)
+}
+
+func TestUnit_Message_Validate_WhenSubjectHasLineBreaks_ExpectError(t *testing.T) {
+	message := Message{
+		To:      "user@example.com",
+		Subject: "subject\r\nBcc: someone@example.com",
+	}
+
+	err := message.validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMessage), "Actual err: %v", err)
+}
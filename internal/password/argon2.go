@@ -0,0 +1,100 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+// https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+const argon2idPrefix = "$argon2id$"
+
+type argon2idHash struct {
+	version     int
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func hashArgon2id(password string, config Argon2Config) (string, error) {
+	salt := make([]byte, config.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, config.Iterations, config.Memory, config.Parallelism, config.KeyLength)
+
+	out := fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		config.Memory,
+		config.Iterations,
+		config.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+	return out, nil
+}
+
+func verifyArgon2id(password string, encoded string) (bool, error) {
+	hash, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), hash.salt, hash.iterations, hash.memory, hash.parallelism, uint32(len(hash.key)))
+
+	return subtle.ConstantTimeCompare(key, hash.key) == 1, nil
+}
+
+func argon2idNeedsRehash(encoded string, config Argon2Config) bool {
+	hash, err := parseArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return hash.version != argon2.Version ||
+		hash.memory != config.Memory ||
+		hash.iterations != config.Iterations ||
+		hash.parallelism != config.Parallelism ||
+		uint32(len(hash.salt)) != config.SaltLength ||
+		uint32(len(hash.key)) != config.KeyLength
+}
+
+func parseArgon2id(encoded string) (argon2idHash, error) {
+	var out argon2idHash
+
+	// Expected format: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return out, errors.NewCode(MalformedHash)
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &out.version); err != nil {
+		return out, errors.WrapCode(err, MalformedHash)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &out.memory, &out.iterations, &out.parallelism); err != nil {
+		return out, errors.WrapCode(err, MalformedHash)
+	}
+
+	var err error
+	if out.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return out, errors.WrapCode(err, MalformedHash)
+	}
+	if out.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return out, errors.WrapCode(err, MalformedHash)
+	}
+	if len(out.key) == 0 {
+		return out, errors.NewCode(MalformedHash)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,39 @@
+package password
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// https://en.wikipedia.org/wiki/Bcrypt#Versioning_history
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+func hashBcrypt(password string, config BcryptConfig) (string, error) {
+	out, err := bcrypt.GenerateFromPassword([]byte(password), config.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+func verifyBcrypt(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == nil {
+		return true, nil
+	}
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+
+	return false, errors.WrapCode(err, MalformedHash)
+}
+
+func bcryptNeedsRehash(encoded string, config BcryptConfig) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost != config.Cost
+}
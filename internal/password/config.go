@@ -0,0 +1,55 @@
+package password
+
+type Algorithm string
+
+const (
+	Argon2id Algorithm = "argon2id"
+	Bcrypt   Algorithm = "bcrypt"
+)
+
+type Config struct {
+	Algorithm Algorithm
+	Argon2    Argon2Config
+	Bcrypt    BcryptConfig
+	Policy    PolicyConfig
+}
+
+// https://datatracker.ietf.org/doc/html/rfc9106#name-parameter-choice
+type Argon2Config struct {
+	// Memory is expressed in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+type BcryptConfig struct {
+	Cost int
+}
+
+// https://pages.nist.gov/800-63-4/sp800-63b.html#passwordver
+type PolicyConfig struct {
+	MinLength int
+	// MaxLength is ignored when set to 0. Passwords are also limited to
+	// 72 bytes whatever its value.
+	MaxLength int
+
+	RequireLowercase bool
+	RequireUppercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+
+	BanEmailLocalPart bool
+	BannedSubstrings  []string
+
+	RejectCommonPasswords bool
+	// CommonPasswordsFile optionally points to a file listing additional
+	// common passwords, one per line.
+	CommonPasswordsFile string
+
+	// HistorySize is the number of most recent passwords of a user,
+	// including the current one, which can't be chosen again when changing
+	// it. Passwords can always be reused when set to 0.
+	HistorySize int
+}
@@ -0,0 +1,12 @@
+package password
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	UnsupportedAlgorithm errors.ErrorCode = 1100
+	MalformedHash        errors.ErrorCode = 1101
+
+	InvalidCommonPasswordsFile errors.ErrorCode = 1110
+)
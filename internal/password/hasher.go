@@ -0,0 +1,80 @@
+package password
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) (bool, error)
+	NeedsRehash(encoded string) bool
+}
+
+type hasherImpl struct {
+	algorithm Algorithm
+	argon2    Argon2Config
+	bcrypt    BcryptConfig
+}
+
+func NewHasher(config Config) (Hasher, error) {
+	switch config.Algorithm {
+	case Argon2id, Bcrypt:
+	default:
+		return nil, errors.NewCodeWithDetails(UnsupportedAlgorithm, string(config.Algorithm))
+	}
+
+	return &hasherImpl{
+		algorithm: config.Algorithm,
+		argon2:    config.Argon2,
+		bcrypt:    config.Bcrypt,
+	}, nil
+}
+
+func (h *hasherImpl) Hash(password string) (string, error) {
+	if h.algorithm == Bcrypt {
+		return hashBcrypt(password, h.bcrypt)
+	}
+	return hashArgon2id(password, h.argon2)
+}
+
+func (h *hasherImpl) Verify(password string, encoded string) (bool, error) {
+	switch detectAlgorithm(encoded) {
+	case Argon2id:
+		return verifyArgon2id(password, encoded)
+	case Bcrypt:
+		return verifyBcrypt(password, encoded)
+	default:
+		// Rows created before passwords were hashed hold the password
+		// in clear: they are still accepted so that they can be
+		// upgraded on the next successful login.
+		match := subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1
+		return match, nil
+	}
+}
+
+func (h *hasherImpl) NeedsRehash(encoded string) bool {
+	if detectAlgorithm(encoded) != h.algorithm {
+		return true
+	}
+
+	if h.algorithm == Bcrypt {
+		return bcryptNeedsRehash(encoded, h.bcrypt)
+	}
+	return argon2idNeedsRehash(encoded, h.argon2)
+}
+
+func detectAlgorithm(encoded string) Algorithm {
+	if strings.HasPrefix(encoded, argon2idPrefix) {
+		return Argon2id
+	}
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(encoded, prefix) {
+			return Bcrypt
+		}
+	}
+
+	return ""
+}
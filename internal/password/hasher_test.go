@@ -0,0 +1,195 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var argon2TestConfig = Config{
+	Algorithm: Argon2id,
+	Argon2: Argon2Config{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+}
+
+var bcryptTestConfig = Config{
+	Algorithm: Bcrypt,
+	Bcrypt: BcryptConfig{
+		Cost: 4,
+	},
+}
+
+func TestUnit_NewHasher_WhenAlgorithmIsUnknown_ExpectFailure(t *testing.T) {
+	config := Config{
+		Algorithm: "md5",
+	}
+
+	_, err := NewHasher(config)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedAlgorithm), "Actual err: %v", err)
+}
+
+func TestUnit_Hasher_Argon2id_ProducesPhcString(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	actual, err := hasher.Hash("my-password")
+
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(actual, "$argon2id$v=19$m=1024,t=1,p=1$"), "Actual: %s", actual)
+}
+
+func TestUnit_Hasher_Argon2id_UsesRandomSalt(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	first, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+	second, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestUnit_Hasher_Argon2id_Verify(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	match, err := hasher.Verify("my-password", encoded)
+
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestUnit_Hasher_Argon2id_Verify_WhenPasswordIsWrong_ExpectNoMatch(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	match, err := hasher.Verify("not-my-password", encoded)
+
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+func TestUnit_Hasher_Argon2id_Verify_WhenHashIsMalformed_ExpectFailure(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	_, err := hasher.Verify("my-password", "$argon2id$v=19$m=1024,t=1$c2FsdA$a2V5")
+
+	assert.True(t, errors.IsErrorWithCode(err, MalformedHash), "Actual err: %v", err)
+}
+
+func TestUnit_Hasher_Bcrypt_Verify(t *testing.T) {
+	hasher := newTestHasher(t, bcryptTestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(encoded, "$2a$04$"), "Actual: %s", encoded)
+
+	match, err := hasher.Verify("my-password", encoded)
+
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestUnit_Hasher_Bcrypt_Verify_WhenPasswordIsWrong_ExpectNoMatch(t *testing.T) {
+	hasher := newTestHasher(t, bcryptTestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	match, err := hasher.Verify("not-my-password", encoded)
+
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+func TestUnit_Hasher_Verify_WhenHashedWithOtherAlgorithm_ExpectMatch(t *testing.T) {
+	bcryptHasher := newTestHasher(t, bcryptTestConfig)
+	encoded, err := bcryptHasher.Hash("my-password")
+	require.Nil(t, err)
+
+	hasher := newTestHasher(t, argon2TestConfig)
+	match, err := hasher.Verify("my-password", encoded)
+
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestUnit_Hasher_Verify_WhenLegacyPlaintext_ExpectMatch(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	match, err := hasher.Verify("pwd1", "pwd1")
+
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestUnit_Hasher_Verify_WhenLegacyPlaintextDiffers_ExpectNoMatch(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	match, err := hasher.Verify("pwd2", "pwd1")
+
+	assert.Nil(t, err)
+	assert.False(t, match)
+}
+
+func TestUnit_Hasher_NeedsRehash_WhenHashIsUpToDate_ExpectFalse(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	assert.False(t, hasher.NeedsRehash(encoded))
+}
+
+func TestUnit_Hasher_NeedsRehash_WhenLegacyPlaintext_ExpectTrue(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	assert.True(t, hasher.NeedsRehash("pwd1"))
+}
+
+func TestUnit_Hasher_NeedsRehash_WhenArgon2ParametersChanged_ExpectTrue(t *testing.T) {
+	hasher := newTestHasher(t, argon2TestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	updatedConfig := argon2TestConfig
+	updatedConfig.Argon2.Iterations = 2
+	updatedHasher := newTestHasher(t, updatedConfig)
+
+	assert.True(t, updatedHasher.NeedsRehash(encoded))
+}
+
+func TestUnit_Hasher_NeedsRehash_WhenBcryptCostChanged_ExpectTrue(t *testing.T) {
+	hasher := newTestHasher(t, bcryptTestConfig)
+	encoded, err := hasher.Hash("my-password")
+	require.Nil(t, err)
+
+	updatedConfig := bcryptTestConfig
+	updatedConfig.Bcrypt.Cost = 5
+	updatedHasher := newTestHasher(t, updatedConfig)
+
+	assert.True(t, updatedHasher.NeedsRehash(encoded))
+}
+
+func TestUnit_Hasher_NeedsRehash_WhenAlgorithmChanged_ExpectTrue(t *testing.T) {
+	bcryptHasher := newTestHasher(t, bcryptTestConfig)
+	encoded, err := bcryptHasher.Hash("my-password")
+	require.Nil(t, err)
+
+	hasher := newTestHasher(t, argon2TestConfig)
+
+	assert.True(t, hasher.NeedsRehash(encoded))
+}
+
+func newTestHasher(t *testing.T, config Config) Hasher {
+	hasher, err := NewHasher(config)
+	require.Nil(t, err)
+	return hasher
+}
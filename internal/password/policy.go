@@ -0,0 +1,204 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type Rule string
+
+const (
+	TooShort                Rule = "too_short"
+	TooLong                 Rule = "too_long"
+	MissingLowercase        Rule = "missing_lowercase"
+	MissingUppercase        Rule = "missing_uppercase"
+	MissingDigit            Rule = "missing_digit"
+	MissingSymbol           Rule = "missing_symbol"
+	ContainsEmail           Rule = "contains_email"
+	ContainsBannedSubstring Rule = "contains_banned_substring"
+	CommonPassword          Rule = "common_password"
+	// ReusedPassword is only reported when changing the password: checking
+	// it requires the history of the user.
+	ReusedPassword Rule = "reused_password"
+)
+
+// The local part of an email is only considered when it is long enough
+// to not produce false positives (e.g. 'a@b.com').
+const minEmailLocalPartLength = 3
+
+// bcrypt can't hash more than 72 bytes. The limit applies whatever the
+// algorithm so that switching to bcrypt later does not lock users out.
+const maxBytes = 72
+
+//go:embed common_passwords.txt
+var embeddedCommonPasswords []byte
+
+type Policy interface {
+	// Validate returns the rules that the password breaks, if any. The
+	// email of the user owning the password is used to detect passwords
+	// derived from it.
+	Validate(password string, email string) []Rule
+	// HistorySize returns how many of the most recent passwords of a user
+	// can't be reused.
+	HistorySize() int
+}
+
+type policyImpl struct {
+	minLength int
+	maxLength int
+
+	requireLowercase bool
+	requireUppercase bool
+	requireDigit     bool
+	requireSymbol    bool
+
+	banEmailLocalPart bool
+	bannedSubstrings  []string
+
+	commonPasswords map[string]struct{}
+
+	historySize int
+}
+
+func NewPolicy(config PolicyConfig) (Policy, error) {
+	commonPasswords := make(map[string]struct{})
+	if config.RejectCommonPasswords {
+		if err := loadCommonPasswords(bytes.NewReader(embeddedCommonPasswords), commonPasswords); err != nil {
+			return nil, err
+		}
+	}
+	if config.RejectCommonPasswords && config.CommonPasswordsFile != "" {
+		file, err := os.Open(config.CommonPasswordsFile)
+		if err != nil {
+			return nil, errors.WrapCode(err, InvalidCommonPasswordsFile)
+		}
+		defer file.Close()
+
+		if err := loadCommonPasswords(file, commonPasswords); err != nil {
+			return nil, errors.WrapCode(err, InvalidCommonPasswordsFile)
+		}
+	}
+
+	var bannedSubstrings []string
+	for _, substring := range config.BannedSubstrings {
+		if substring != "" {
+			bannedSubstrings = append(bannedSubstrings, strings.ToLower(substring))
+		}
+	}
+
+	return &policyImpl{
+		// An empty password is never acceptable, whatever the configuration.
+		minLength: max(1, config.MinLength),
+		maxLength: config.MaxLength,
+
+		requireLowercase: config.RequireLowercase,
+		requireUppercase: config.RequireUppercase,
+		requireDigit:     config.RequireDigit,
+		requireSymbol:    config.RequireSymbol,
+
+		banEmailLocalPart: config.BanEmailLocalPart,
+		bannedSubstrings:  bannedSubstrings,
+
+		commonPasswords: commonPasswords,
+
+		historySize: max(0, config.HistorySize),
+	}, nil
+}
+
+func (p *policyImpl) Validate(password string, email string) []Rule {
+	var out []Rule
+
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		out = append(out, TooShort)
+	}
+	if (p.maxLength > 0 && length > p.maxLength) || len(password) > maxBytes {
+		out = append(out, TooLong)
+	}
+
+	out = append(out, p.validateCharacterClasses(password)...)
+
+	lowercase := strings.ToLower(password)
+	if p.banEmailLocalPart && containsEmailLocalPart(lowercase, email) {
+		out = append(out, ContainsEmail)
+	}
+	for _, substring := range p.bannedSubstrings {
+		if strings.Contains(lowercase, substring) {
+			out = append(out, ContainsBannedSubstring)
+			break
+		}
+	}
+	if _, ok := p.commonPasswords[lowercase]; ok {
+		out = append(out, CommonPassword)
+	}
+
+	return out
+}
+
+func (p *policyImpl) HistorySize() int {
+	return p.historySize
+}
+
+func (p *policyImpl) validateCharacterClasses(password string) []Rule {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	var out []Rule
+	if p.requireLowercase && !lower {
+		out = append(out, MissingLowercase)
+	}
+	if p.requireUppercase && !upper {
+		out = append(out, MissingUppercase)
+	}
+	if p.requireDigit && !digit {
+		out = append(out, MissingDigit)
+	}
+	if p.requireSymbol && !symbol {
+		out = append(out, MissingSymbol)
+	}
+
+	return out
+}
+
+func containsEmailLocalPart(lowercasePassword string, email string) bool {
+	localPart, _, _ := strings.Cut(email, "@")
+	localPart = strings.ToLower(strings.TrimSpace(localPart))
+	if utf8.RuneCountInString(localPart) < minEmailLocalPartLength {
+		return false
+	}
+
+	return strings.Contains(lowercasePassword, localPart)
+}
+
+func loadCommonPasswords(source io.Reader, out map[string]struct{}) error {
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		out[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
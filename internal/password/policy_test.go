@@ -0,0 +1,241 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_Policy_WhenPasswordIsValid_ExpectNoViolation(t *testing.T) {
+	config := PolicyConfig{
+		MinLength:             8,
+		MaxLength:             64,
+		RequireLowercase:      true,
+		RequireUppercase:      true,
+		RequireDigit:          true,
+		RequireSymbol:         true,
+		BanEmailLocalPart:     true,
+		RejectCommonPasswords: true,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("Correct-Horse-7", "user@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenPasswordIsEmpty_ExpectTooShort(t *testing.T) {
+	policy := newTestPolicy(t, PolicyConfig{})
+
+	actual := policy.Validate("", "user@example.com")
+
+	assert.Equal(t, []Rule{TooShort}, actual)
+}
+
+func TestUnit_Policy_WhenPasswordIsTooShort_ExpectTooShort(t *testing.T) {
+	config := PolicyConfig{
+		MinLength: 8,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("short", "user@example.com")
+
+	assert.Equal(t, []Rule{TooShort}, actual)
+}
+
+func TestUnit_Policy_CountsCharactersAndNotBytes(t *testing.T) {
+	config := PolicyConfig{
+		MinLength: 4,
+		MaxLength: 4,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("ééée", "user@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenPasswordIsTooLong_ExpectTooLong(t *testing.T) {
+	config := PolicyConfig{
+		MaxLength: 8,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("way-too-long-password", "user@example.com")
+
+	assert.Equal(t, []Rule{TooLong}, actual)
+}
+
+func TestUnit_Policy_WhenMaxLengthIsZero_ExpectNoLimit(t *testing.T) {
+	policy := newTestPolicy(t, PolicyConfig{})
+
+	actual := policy.Validate("a-very-very-very-very-very-very-long-password", "user@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenPasswordExceedsBytesLimit_ExpectTooLong(t *testing.T) {
+	config := PolicyConfig{
+		MaxLength: 64,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate(strings.Repeat("é", 40), "user@example.com")
+
+	assert.Equal(t, []Rule{TooLong}, actual)
+}
+
+func TestUnit_Policy_WhenMaxLengthIsZero_ExpectBytesLimit(t *testing.T) {
+	policy := newTestPolicy(t, PolicyConfig{})
+
+	actual := policy.Validate(strings.Repeat("a", 73), "user@example.com")
+
+	assert.Equal(t, []Rule{TooLong}, actual)
+}
+
+func TestUnit_Policy_WhenCharacterClassesAreMissing_ExpectAllReported(t *testing.T) {
+	config := PolicyConfig{
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("ààà", "user@example.com")
+
+	expected := []Rule{MissingUppercase, MissingDigit, MissingSymbol}
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_Policy_WhenPasswordContainsEmailLocalPart_ExpectContainsEmail(t *testing.T) {
+	config := PolicyConfig{
+		BanEmailLocalPart: true,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("my-JohnDoe-password", "johndoe@example.com")
+
+	assert.Equal(t, []Rule{ContainsEmail}, actual)
+}
+
+func TestUnit_Policy_WhenEmailLocalPartIsShort_ExpectNotConsidered(t *testing.T) {
+	config := PolicyConfig{
+		BanEmailLocalPart: true,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("my-password", "my@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenEmailLocalPartIsNotBanned_ExpectNoViolation(t *testing.T) {
+	policy := newTestPolicy(t, PolicyConfig{})
+
+	actual := policy.Validate("my-johndoe-password", "johndoe@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenPasswordContainsBannedSubstring_ExpectContainsBannedSubstring(t *testing.T) {
+	config := PolicyConfig{
+		BannedSubstrings: []string{"galactic", "Sovereign"},
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("i-love-SOVEREIGN-and-galactic", "user@example.com")
+
+	assert.Equal(t, []Rule{ContainsBannedSubstring}, actual)
+}
+
+func TestUnit_Policy_WhenPasswordIsCommon_ExpectCommonPassword(t *testing.T) {
+	config := PolicyConfig{
+		RejectCommonPasswords: true,
+	}
+	policy := newTestPolicy(t, config)
+
+	actual := policy.Validate("PassWord123", "user@example.com")
+
+	assert.Equal(t, []Rule{CommonPassword}, actual)
+}
+
+func TestUnit_Policy_WhenCommonPasswordsAreNotRejected_ExpectNoViolation(t *testing.T) {
+	policy := newTestPolicy(t, PolicyConfig{})
+
+	actual := policy.Validate("password123", "user@example.com")
+
+	assert.Empty(t, actual)
+}
+
+func TestUnit_Policy_WhenCommonPasswordsFileIsProvided_ExpectEntriesRejected(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "common.txt")
+	err := os.WriteFile(file, []byte("# comment\n\nGalactic-Sovereign\n"), 0644)
+	require.Nil(t, err)
+
+	config := PolicyConfig{
+		RejectCommonPasswords: true,
+		CommonPasswordsFile:   file,
+	}
+	policy := newTestPolicy(t, config)
+
+	assert.Equal(t, []Rule{CommonPassword}, policy.Validate("galactic-sovereign", "user@example.com"))
+	assert.Equal(t, []Rule{CommonPassword}, policy.Validate("qwerty", "user@example.com"))
+}
+
+func TestUnit_NewPolicy_WhenCommonPasswordsFileDoesNotExist_ExpectFailure(t *testing.T) {
+	config := PolicyConfig{
+		RejectCommonPasswords: true,
+		CommonPasswordsFile:   filepath.Join(t.TempDir(), "not-a-file.txt"),
+	}
+
+	_, err := NewPolicy(config)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCommonPasswordsFile), "Actual err: %v", err)
+}
+
+func TestUnit_Policy_HistorySize(t *testing.T) {
+	config := PolicyConfig{
+		HistorySize: 5,
+	}
+	policy := newTestPolicy(t, config)
+
+	assert.Equal(t, 5, policy.HistorySize())
+}
+
+func TestUnit_Policy_WhenHistorySizeIsNegative_ExpectZero(t *testing.T) {
+	config := PolicyConfig{
+		HistorySize: -1,
+	}
+	policy := newTestPolicy(t, config)
+
+	assert.Zero(t, policy.HistorySize())
+}
+
+func TestUnit_Violations_WhenWrapped_ExpectRulesReturned(t *testing.T) {
+	rules := []Rule{TooShort, CommonPassword}
+	err := errors.WrapCode(NewPolicyViolationError(rules), 1051)
+
+	actual := Violations(err)
+
+	assert.Equal(t, rules, actual)
+}
+
+func TestUnit_Violations_WhenNotAPolicyViolation_ExpectNil(t *testing.T) {
+	err := errors.NewCode(1051)
+
+	actual := Violations(err)
+
+	assert.Nil(t, actual)
+}
+
+func newTestPolicy(t *testing.T, config PolicyConfig) Policy {
+	policy, err := NewPolicy(config)
+	require.Nil(t, err)
+	return policy
+}
@@ -0,0 +1,41 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type policyViolationError struct {
+	rules []Rule
+}
+
+func NewPolicyViolationError(rules []Rule) error {
+	return policyViolationError{
+		rules: rules,
+	}
+}
+
+func (e policyViolationError) Error() string {
+	var rules []string
+	for _, rule := range e.rules {
+		rules = append(rules, string(rule))
+	}
+
+	return fmt.Sprintf("password breaks the policy (%s)", strings.Join(rules, ", "))
+}
+
+// Violations returns the rules held by the policy violation error found in
+// the chain of causes of the input error, if any.
+func Violations(err error) []Rule {
+	for err != nil {
+		if violation, ok := err.(policyViolationError); ok {
+			return violation.rules
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return nil
+}
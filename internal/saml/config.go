@@ -0,0 +1,77 @@
+package saml
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type TenantConfig struct {
+	// Name identifies the tenant in the URLs: /v1/users/saml/{name}/...
+	Name string
+	// MetadataFile is the path to the metadata published by the identity
+	// provider of the tenant. It describes where to send the users and the
+	// certificates signing the assertions.
+	MetadataFile string
+	// EmailAttribute is the attribute of the assertions holding the email
+	// of the user. The name identifier is used when it is not set, which
+	// requires it to have the emailAddress format.
+	EmailAttribute string
+	// EmailDomains are the domains the identity provider is authoritative
+	// for. Emails in other domains are not trusted: otherwise the provider
+	// of a tenant could sign in as the users of another one.
+	EmailDomains []string
+	// CreateUsers creates an account for the users signing in for the first
+	// time.
+	CreateUsers bool
+}
+
+type Config struct {
+	// BaseUrl is the public URL of the service, such as
+	// https://example.com/v1/users. The endpoints given to the identity
+	// providers are relative to it.
+	BaseUrl string
+	// CertificateFile and PrivateKeyFile hold the RSA key pair signing the
+	// requests sent to the identity providers, in PEM format.
+	CertificateFile string
+	PrivateKeyFile  string
+	// RequestValidity is how long users have to sign in at the provider.
+	RequestValidity time.Duration
+	Tenants         []TenantConfig
+}
+
+func (c Config) Validate() error {
+	if len(c.Tenants) == 0 {
+		return nil
+	}
+
+	parsed, err := url.Parse(c.BaseUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "base URL must be an absolute URL")
+	}
+	if c.CertificateFile == "" || c.PrivateKeyFile == "" {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "a key pair is required to sign requests")
+	}
+	if c.RequestValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "request validity must be positive")
+	}
+
+	names := make(map[string]bool)
+	for _, tenant := range c.Tenants {
+		if tenant.Name == "" || names[tenant.Name] || strings.ContainsAny(tenant.Name, "/?#") {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "invalid tenant name: "+tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		if tenant.MetadataFile == "" {
+			return errors.NewCodeWithDetails(InvalidConfiguration, tenant.Name+": metadata is required")
+		}
+		if len(tenant.EmailDomains) == 0 {
+			return errors.NewCodeWithDetails(InvalidConfiguration, tenant.Name+": email domains are required")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Config_Validate_WhenNoTenants_ExpectValid(t *testing.T) {
+	assert.Nil(t, Config{}.Validate())
+}
+
+func TestUnit_Config_Validate_WhenInvalid_ExpectError(t *testing.T) {
+	testCases := map[string]func(config *Config){
+		"relativeBaseUrl":    func(config *Config) { config.BaseUrl = "/v1/users" },
+		"noCertificate":      func(config *Config) { config.CertificateFile = "" },
+		"noRequestValidity":  func(config *Config) { config.RequestValidity = 0 },
+		"duplicateTenant":    func(config *Config) { config.Tenants = append(config.Tenants, config.Tenants[0]) },
+		"tenantNameWithPath": func(config *Config) { config.Tenants[0].Name = "acme/corp" },
+		"noMetadata":         func(config *Config) { config.Tenants[0].MetadataFile = "" },
+		"noEmailDomains":     func(config *Config) { config.Tenants[0].EmailDomains = nil },
+	}
+
+	for name, alter := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				BaseUrl:         testBaseUrl,
+				CertificateFile: "certificate.pem",
+				PrivateKeyFile:  "key.pem",
+				RequestValidity: time.Minute,
+				Tenants: []TenantConfig{
+					{Name: "acme", MetadataFile: "metadata.xml", EmailDomains: []string{"acme.com"}},
+				},
+			}
+			alter(&config)
+
+			err := config.Validate()
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+		})
+	}
+}
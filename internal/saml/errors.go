@@ -0,0 +1,16 @@
+package saml
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration errors.ErrorCode = 1700
+	InvalidMetadata      errors.ErrorCode = 1701
+
+	MalformedResponse    errors.ErrorCode = 1710
+	InvalidSignature     errors.ErrorCode = 1711
+	UnsuccessfulResponse errors.ErrorCode = 1712
+	InvalidAssertion     errors.ErrorCode = 1713
+	UnsupportedAssertion errors.ErrorCode = 1714
+)
@@ -0,0 +1,51 @@
+package saml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/internal/saml/samltest"
+	"github.com/stretchr/testify/require"
+)
+
+const testBaseUrl = "https://users.example.com/v1/users"
+
+func newTestServiceProvider(t *testing.T, idp *samltest.IdentityProvider) *ServiceProvider {
+	dir := t.TempDir()
+	key, certificate := samltest.NewKeyPair(t)
+	certificateFile, keyFile := samltest.WriteKeyPair(t, dir, key, certificate)
+
+	config := Config{
+		BaseUrl:         testBaseUrl,
+		CertificateFile: certificateFile,
+		PrivateKeyFile:  keyFile,
+		RequestValidity: 5 * time.Minute,
+		Tenants: []TenantConfig{
+			{
+				Name:           "acme",
+				MetadataFile:   idp.WriteMetadata(t, dir),
+				EmailAttribute: "mail",
+				EmailDomains:   []string{"acme.com"},
+			},
+		},
+	}
+
+	sp, err := New(config)
+	require.Nil(t, err)
+	return sp
+}
+
+func newTestTenant(t *testing.T, idp *samltest.IdentityProvider) *Tenant {
+	tenant, ok := newTestServiceProvider(t, idp).Tenant("acme")
+	require.True(t, ok)
+	return tenant
+}
+
+// newTestResponse answers a new request of the tenant.
+func newTestResponse(t *testing.T, idp *samltest.IdentityProvider, tenant *Tenant) samltest.Response {
+	_, redirect, err := tenant.AuthnRequest("my-relay-state", time.Now())
+	require.Nil(t, err)
+	request, _ := samltest.ParseAuthnRequest(t, redirect)
+
+	return idp.NewResponse(request, "jane@acme.com")
+}
@@ -0,0 +1,98 @@
+package saml
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf
+const (
+	httpRedirectBinding = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	httpPostBinding     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// https://docs.oasis-open.org/security/saml/v2.0/saml-metadata-2.0-os.pdf
+type entityDescriptor struct {
+	XMLName  xml.Name          `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+	EntityId string            `xml:"entityID,attr"`
+	Idp      *idpSsoDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata IDPSSODescriptor"`
+}
+
+type idpSsoDescriptor struct {
+	KeyDescriptors       []keyDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+	SingleSignOnServices []endpoint      `xml:"urn:oasis:names:tc:SAML:2.0:metadata SingleSignOnService"`
+}
+
+type keyDescriptor struct {
+	Use          string   `xml:"use,attr"`
+	Certificates []string `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo>X509Data>X509Certificate"`
+}
+
+type endpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// identityProvider is what this service needs to know about the provider
+// of a tenant.
+type identityProvider struct {
+	entityId string
+	// ssoUrl is where users are redirected to sign in.
+	ssoUrl       string
+	certificates []*x509.Certificate
+}
+
+func parseMetadata(data []byte) (identityProvider, error) {
+	var descriptor entityDescriptor
+	if err := xml.Unmarshal(data, &descriptor); err != nil {
+		return identityProvider{}, errors.WrapCode(err, InvalidMetadata)
+	}
+	if descriptor.EntityId == "" || descriptor.Idp == nil {
+		return identityProvider{}, errors.NewCodeWithDetails(InvalidMetadata, "not an identity provider")
+	}
+
+	out := identityProvider{
+		entityId: descriptor.EntityId,
+	}
+
+	for _, service := range descriptor.Idp.SingleSignOnServices {
+		if service.Binding == httpRedirectBinding {
+			out.ssoUrl = service.Location
+		}
+	}
+	parsed, err := url.Parse(out.ssoUrl)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return identityProvider{}, errors.NewCodeWithDetails(InvalidMetadata, "no single sign-on service with the HTTP-Redirect binding")
+	}
+
+	for _, key := range descriptor.Idp.KeyDescriptors {
+		// Keys without use are used for both signing and encryption.
+		if key.Use != "" && key.Use != "signing" {
+			continue
+		}
+
+		for _, encoded := range key.Certificates {
+			// Certificates are usually split over several lines.
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+			if err != nil {
+				return identityProvider{}, errors.WrapCode(err, InvalidMetadata)
+			}
+			certificate, err := x509.ParseCertificate(der)
+			if err != nil {
+				return identityProvider{}, errors.WrapCode(err, InvalidMetadata)
+			}
+
+			out.certificates = append(out.certificates, certificate)
+		}
+	}
+	if len(out.certificates) == 0 {
+		return identityProvider{}, errors.NewCodeWithDetails(InvalidMetadata, "no signing certificate")
+	}
+
+	return out, nil
+}
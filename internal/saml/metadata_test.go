@@ -0,0 +1,43 @@
+package saml
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/saml/samltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_ParseMetadata(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+
+	actual, err := parseMetadata(idp.Metadata())
+
+	require.Nil(t, err)
+	assert.Equal(t, idp.EntityId, actual.entityId)
+	assert.Equal(t, idp.SsoUrl, actual.ssoUrl)
+	require.Len(t, actual.certificates, 1)
+	assert.True(t, idp.Certificate.Equal(actual.certificates[0]))
+}
+
+func TestUnit_ParseMetadata_WhenInvalid_ExpectError(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	metadata := string(idp.Metadata())
+
+	testCases := map[string]string{
+		"notXml":            "not-xml",
+		"noRedirectBinding": strings.Replace(metadata, "bindings:HTTP-Redirect", "bindings:SOAP", 1),
+		"encryptionKeyOnly": strings.Replace(metadata, `use="signing"`, `use="encryption"`, 1),
+		"serviceProvider":   strings.ReplaceAll(metadata, "IDPSSODescriptor", "SPSSODescriptor"),
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseMetadata([]byte(data))
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidMetadata), "Actual err: %v", err)
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package saml
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// Tolerated difference between the clock of the provider and ours.
+const clockSkew = time.Minute
+
+// Responses hold a single assertion: they should be small.
+const maxResponseSize = 256 * 1024
+
+const (
+	successStatus            = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerConfirmationMethod = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+)
+
+// Assertion is what the provider asserts about the user who signed in.
+type Assertion struct {
+	// Id is unique for each assertion: it is remembered until the assertion
+	// expires so that it can't be replayed.
+	Id string
+	// InResponseTo is the identifier of the request the assertion answers.
+	InResponseTo string
+	// Subject identifies the user at the provider.
+	Subject    string
+	Attributes map[string][]string
+	Email      string
+	// EmailVerified is set when the email belongs to one of the domains of
+	// the tenant.
+	EmailVerified bool
+	// ValidUntil is when the assertion can't be used anymore.
+	ValidUntil time.Time
+}
+
+// ParseResponse decodes the response posted by the provider and verifies
+// it. Only the parts covered by the signature are looked at: the rest of
+// the document could have been added by an attacker.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-profiles-2.0-os.pdf#page=19
+func (t *Tenant) ParseResponse(encoded string, now time.Time) (Assertion, error) {
+	if len(encoded) > maxResponseSize {
+		return Assertion{}, errors.NewCodeWithDetails(MalformedResponse, "response is too large")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return Assertion{}, errors.WrapCode(err, MalformedResponse)
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(data); err != nil {
+		return Assertion{}, errors.WrapCode(err, MalformedResponse)
+	}
+	response := doc.Root()
+	if !isElement(response, protocolNamespace, "Response") {
+		return Assertion{}, errors.NewCodeWithDetails(MalformedResponse, "not a response")
+	}
+
+	validation := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: t.idp.certificates,
+	})
+	validation.Clock = dsig.NewFakeClockAt(now)
+
+	responseSigned := len(children(response, signatureNamespace, "Signature")) > 0
+	if responseSigned {
+		response, err = validation.Validate(response)
+		if err != nil {
+			return Assertion{}, errors.WrapCode(err, InvalidSignature)
+		}
+	}
+
+	if err := t.verifyResponse(response); err != nil {
+		return Assertion{}, err
+	}
+
+	if len(children(response, assertionNamespace, "EncryptedAssertion")) > 0 {
+		return Assertion{}, errors.NewCodeWithDetails(UnsupportedAssertion, "encrypted assertions are not supported")
+	}
+	assertions := children(response, assertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return Assertion{}, errors.NewCodeWithDetails(MalformedResponse, "exactly one assertion is expected")
+	}
+
+	assertion := assertions[0]
+	if len(children(assertion, signatureNamespace, "Signature")) > 0 {
+		assertion, err = validation.Validate(assertion)
+		if err != nil {
+			return Assertion{}, errors.WrapCode(err, InvalidSignature)
+		}
+	} else if !responseSigned {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidSignature, "neither the response nor the assertion is signed")
+	}
+
+	out, err := t.verifyAssertion(assertion, now)
+	if err != nil {
+		return Assertion{}, err
+	}
+
+	// The response can only refer to the request the assertion answers.
+	if inResponseTo := response.SelectAttrValue("InResponseTo", ""); inResponseTo != "" && inResponseTo != out.InResponseTo {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "response and assertion answer different requests")
+	}
+
+	return out, nil
+}
+
+func (t *Tenant) verifyResponse(response *etree.Element) error {
+	if response.SelectAttrValue("Version", "") != "2.0" {
+		return errors.NewCodeWithDetails(MalformedResponse, "unsupported version")
+	}
+	if destination := response.SelectAttrValue("Destination", ""); destination != "" && destination != t.AcsUrl() {
+		return errors.NewCodeWithDetails(InvalidAssertion, "unexpected destination")
+	}
+
+	status := firstChild(firstChild(response, protocolNamespace, "Status"), protocolNamespace, "StatusCode")
+	if status == nil {
+		return errors.NewCodeWithDetails(MalformedResponse, "no status")
+	}
+	if code := status.SelectAttrValue("Value", ""); code != successStatus {
+		return errors.NewCodeWithDetails(UnsuccessfulResponse, code)
+	}
+
+	return nil
+}
+
+// verifyAssertion checks the assertion as described by the web browser
+// single sign-on profile.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-profiles-2.0-os.pdf#page=25
+func (t *Tenant) verifyAssertion(assertion *etree.Element, now time.Time) (Assertion, error) {
+	out := Assertion{
+		Id:         assertion.SelectAttrValue("ID", ""),
+		Attributes: make(map[string][]string),
+	}
+	if out.Id == "" {
+		return Assertion{}, errors.NewCodeWithDetails(MalformedResponse, "assertion without identifier")
+	}
+
+	if text(firstChild(assertion, assertionNamespace, "Issuer")) != t.idp.entityId {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "unexpected issuer")
+	}
+
+	subject := firstChild(assertion, assertionNamespace, "Subject")
+	nameId := firstChild(subject, assertionNamespace, "NameID")
+	out.Subject = text(nameId)
+	if out.Subject == "" {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "no subject")
+	}
+	// Transient identifiers change at each login: the user could not be
+	// recognized.
+	nameIdFormat := nameId.SelectAttrValue("Format", "")
+	if nameIdFormat == transientNameIdFormat {
+		return Assertion{}, errors.NewCodeWithDetails(UnsupportedAssertion, "transient name identifiers are not supported")
+	}
+
+	confirmed := false
+	for _, confirmation := range children(subject, assertionNamespace, "SubjectConfirmation") {
+		if confirmation.SelectAttrValue("Method", "") != bearerConfirmationMethod {
+			continue
+		}
+
+		data := firstChild(confirmation, assertionNamespace, "SubjectConfirmationData")
+		notOnOrAfter, err := timeAttr(data, "NotOnOrAfter")
+		if err != nil || notOnOrAfter.IsZero() {
+			continue
+		}
+		if data.SelectAttrValue("Recipient", "") != t.AcsUrl() || !now.Before(notOnOrAfter.Add(clockSkew)) {
+			continue
+		}
+
+		confirmed = true
+		out.InResponseTo = data.SelectAttrValue("InResponseTo", "")
+		out.ValidUntil = notOnOrAfter
+		break
+	}
+	if !confirmed {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "no valid bearer confirmation")
+	}
+	if out.InResponseTo == "" {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "unsolicited responses are not supported")
+	}
+
+	if err := t.verifyConditions(firstChild(assertion, assertionNamespace, "Conditions"), now, &out); err != nil {
+		return Assertion{}, err
+	}
+
+	if firstChild(assertion, assertionNamespace, "AuthnStatement") == nil {
+		return Assertion{}, errors.NewCodeWithDetails(InvalidAssertion, "no authentication statement")
+	}
+
+	for _, statement := range children(assertion, assertionNamespace, "AttributeStatement") {
+		for _, attribute := range children(statement, assertionNamespace, "Attribute") {
+			name := attribute.SelectAttrValue("Name", "")
+			for _, value := range children(attribute, assertionNamespace, "AttributeValue") {
+				out.Attributes[name] = append(out.Attributes[name], text(value))
+			}
+		}
+	}
+
+	if t.config.EmailAttribute != "" {
+		if values := out.Attributes[t.config.EmailAttribute]; len(values) > 0 {
+			out.Email = values[0]
+		}
+	} else if nameIdFormat == emailNameIdFormat {
+		out.Email = out.Subject
+	}
+	out.EmailVerified = t.inEmailDomains(out.Email)
+
+	return out, nil
+}
+
+func (t *Tenant) verifyConditions(conditions *etree.Element, now time.Time, out *Assertion) error {
+	if conditions == nil {
+		return errors.NewCodeWithDetails(InvalidAssertion, "no conditions")
+	}
+
+	notBefore, err := timeAttr(conditions, "NotBefore")
+	if err != nil {
+		return err
+	}
+	if !notBefore.IsZero() && now.Add(clockSkew).Before(notBefore) {
+		return errors.NewCodeWithDetails(InvalidAssertion, "assertion is not valid yet")
+	}
+
+	notOnOrAfter, err := timeAttr(conditions, "NotOnOrAfter")
+	if err != nil {
+		return err
+	}
+	if !notOnOrAfter.IsZero() {
+		if !now.Before(notOnOrAfter.Add(clockSkew)) {
+			return errors.NewCodeWithDetails(InvalidAssertion, "assertion expired")
+		}
+		if notOnOrAfter.After(out.ValidUntil) {
+			out.ValidUntil = notOnOrAfter
+		}
+	}
+
+	// Each restriction must include this service.
+	// https://docs.oasis-open.org/security/saml/v2.0/saml-core-2.0-os.pdf#page=23
+	restrictions := children(conditions, assertionNamespace, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return errors.NewCodeWithDetails(InvalidAssertion, "no audience restriction")
+	}
+	for _, restriction := range restrictions {
+		var audiences []string
+		for _, audience := range children(restriction, assertionNamespace, "Audience") {
+			audiences = append(audiences, text(audience))
+		}
+
+		if !slices.Contains(audiences, t.EntityId()) {
+			return errors.NewCodeWithDetails(InvalidAssertion, "unexpected audience")
+		}
+	}
+
+	return nil
+}
+
+func (t *Tenant) inEmailDomains(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := email[at+1:]
+	for _, allowed := range t.config.EmailDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The namespace of the elements is checked: an element with the right name
+// in another namespace is not the same element.
+
+func isElement(el *etree.Element, namespace string, tag string) bool {
+	return el != nil && el.Tag == tag && el.NamespaceURI() == namespace
+}
+
+func children(el *etree.Element, namespace string, tag string) []*etree.Element {
+	if el == nil {
+		return nil
+	}
+
+	var out []*etree.Element
+	for _, child := range el.ChildElements() {
+		if isElement(child, namespace, tag) {
+			out = append(out, child)
+		}
+	}
+
+	return out
+}
+
+func firstChild(el *etree.Element, namespace string, tag string) *etree.Element {
+	all := children(el, namespace, tag)
+	if len(all) == 0 {
+		return nil
+	}
+
+	return all[0]
+}
+
+func text(el *etree.Element) string {
+	if el == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(el.Text())
+}
+
+func timeAttr(el *etree.Element, name string) (time.Time, error) {
+	if el == nil {
+		return time.Time{}, nil
+	}
+
+	value := el.SelectAttrValue(name, "")
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	out, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, errors.WrapCode(err, MalformedResponse)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,226 @@
+package saml
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/saml/samltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_Tenant_ParseResponse(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+
+	actual, err := tenant.ParseResponse(idp.Encode(t, response), time.Now())
+
+	require.Nil(t, err)
+	assert.Equal(t, response.AssertionId, actual.Id)
+	assert.Equal(t, response.InResponseTo, actual.InResponseTo)
+	assert.Equal(t, "jane@acme.com", actual.Subject)
+	assert.Equal(t, "jane@acme.com", actual.Email)
+	assert.True(t, actual.EmailVerified)
+	assert.Equal(t, []string{"jane@acme.com"}, actual.Attributes["mail"])
+	assert.Equal(t, response.NotOnOrAfter.Truncate(time.Second).UTC(), actual.ValidUntil.UTC())
+}
+
+func TestUnit_Tenant_ParseResponse_WhenOnlyResponseIsSigned_ExpectSuccess(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	response.SignResponse = true
+	response.SignAssertion = false
+
+	actual, err := tenant.ParseResponse(idp.Encode(t, response), time.Now())
+
+	require.Nil(t, err)
+	assert.Equal(t, response.AssertionId, actual.Id)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenBothAreSigned_ExpectSuccess(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	response.SignResponse = true
+
+	_, err := tenant.ParseResponse(idp.Encode(t, response), time.Now())
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenEmailIsOutsideOfDomains_ExpectNotVerified(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	response.Attributes["mail"] = "jane@evil.com"
+
+	actual, err := tenant.ParseResponse(idp.Encode(t, response), time.Now())
+
+	require.Nil(t, err)
+	assert.Equal(t, "jane@evil.com", actual.Email)
+	assert.False(t, actual.EmailVerified)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		alter        func(response *samltest.Response)
+		expectedCode errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"unsigned": {
+			alter:        func(response *samltest.Response) { response.SignAssertion = false },
+			expectedCode: InvalidSignature,
+		},
+		"unsuccessful": {
+			alter:        func(response *samltest.Response) { response.Status = "urn:oasis:names:tc:SAML:2.0:status:Requester" },
+			expectedCode: UnsuccessfulResponse,
+		},
+		"wrongDestination": {
+			alter:        func(response *samltest.Response) { response.Destination = "https://evil.com/acs" },
+			expectedCode: InvalidAssertion,
+		},
+		"wrongIssuer": {
+			alter:        func(response *samltest.Response) { response.Issuer = "https://evil.com/metadata" },
+			expectedCode: InvalidAssertion,
+		},
+		"wrongRecipient": {
+			alter:        func(response *samltest.Response) { response.Recipient = "https://evil.com/acs" },
+			expectedCode: InvalidAssertion,
+		},
+		"wrongAudience": {
+			alter:        func(response *samltest.Response) { response.Audience = "https://evil.com/metadata" },
+			expectedCode: InvalidAssertion,
+		},
+		"unsolicited": {
+			alter:        func(response *samltest.Response) { response.InResponseTo = "" },
+			expectedCode: InvalidAssertion,
+		},
+		"expired": {
+			alter: func(response *samltest.Response) {
+				response.NotBefore = time.Now().Add(-time.Hour)
+				response.NotOnOrAfter = time.Now().Add(-30 * time.Minute)
+			},
+			expectedCode: InvalidAssertion,
+		},
+		"notYetValid": {
+			alter:        func(response *samltest.Response) { response.NotBefore = time.Now().Add(30 * time.Minute) },
+			expectedCode: InvalidAssertion,
+		},
+		"transientNameId": {
+			alter: func(response *samltest.Response) {
+				response.NameIdFormat = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+			},
+			expectedCode: UnsupportedAssertion,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			idp := samltest.NewIdentityProvider(t)
+			tenant := newTestTenant(t, idp)
+			response := newTestResponse(t, idp, tenant)
+			testCase.alter(&response)
+
+			_, err := tenant.ParseResponse(idp.Encode(t, response), time.Now())
+
+			assert.True(t, errors.IsErrorWithCode(err, testCase.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_Tenant_ParseResponse_WhenSignedByAnotherProvider_ExpectInvalidSignature(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	attacker := samltest.NewIdentityProvider(t)
+
+	_, err := tenant.ParseResponse(attacker.Encode(t, response), time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenAssertionIsTampered_ExpectInvalidSignature(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	encoded := idp.Encode(t, response)
+
+	tampered := alterDocument(t, encoded, func(document string) string {
+		return strings.ReplaceAll(document, "jane@acme.com", "ceo@acme.com")
+	})
+	_, err := tenant.ParseResponse(tampered, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenAssertionIsInjected_ExpectError(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	encoded := idp.Encode(t, response)
+
+	// A second assertion next to the signed one: the signature would still
+	// be valid but the forged assertion could be the one being read.
+	wrapped := alterDocument(t, encoded, func(document string) string {
+		start := strings.Index(document, "<saml:Assertion")
+		end := strings.Index(document, "</saml:Assertion>") + len("</saml:Assertion>")
+		forged := strings.ReplaceAll(document[start:end], "jane@acme.com", "ceo@acme.com")
+		return document[:start] + forged + document[start:]
+	})
+	_, err := tenant.ParseResponse(wrapped, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, MalformedResponse), "Actual err: %v", err)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenSignedAssertionIsHidden_ExpectInvalidSignature(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+	response := newTestResponse(t, idp, tenant)
+	encoded := idp.Encode(t, response)
+
+	// The signed assertion is moved in an extension and replaced by a forged
+	// one without signature.
+	wrapped := alterDocument(t, encoded, func(document string) string {
+		start := strings.Index(document, "<saml:Assertion")
+		end := strings.Index(document, "</saml:Assertion>") + len("</saml:Assertion>")
+		signed := document[start:end]
+		forged := signed[:strings.Index(signed, "<ds:Signature")] + signed[strings.Index(signed, "</ds:Signature>")+len("</ds:Signature>"):]
+		forged = strings.ReplaceAll(forged, "jane@acme.com", "ceo@acme.com")
+		return document[:start] + "<samlp:Extensions>" + signed + "</samlp:Extensions>" + forged + document[end:]
+	})
+	_, err := tenant.ParseResponse(wrapped, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_Tenant_ParseResponse_WhenMalformed_ExpectError(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+
+	testCases := map[string]string{
+		"notBase64":   "not-base64!",
+		"notXml":      base64.StdEncoding.EncodeToString([]byte("not-xml")),
+		"notResponse": base64.StdEncoding.EncodeToString([]byte(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol"/>`)),
+		"tooLarge":    strings.Repeat("a", maxResponseSize+1),
+	}
+
+	for name, encoded := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := tenant.ParseResponse(encoded, time.Now())
+
+			assert.True(t, errors.IsErrorWithCode(err, MalformedResponse), "Actual err: %v", err)
+		})
+	}
+}
+
+func alterDocument(t *testing.T, encoded string, alter func(document string) string) string {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	require.Nil(t, err)
+	return base64.StdEncoding.EncodeToString([]byte(alter(string(data))))
+}
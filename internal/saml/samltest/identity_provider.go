@@ -0,0 +1,276 @@
+// Package samltest provides an identity provider signing assertions with a
+// locally generated key pair, to test the service provider without a real
+// one.
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"io"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	EmailNameIdFormat      = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	PersistentNameIdFormat = "urn:oasis:names:tc:SAML:2.0:nameid-format:persistent"
+	SuccessStatus          = "urn:oasis:names:tc:SAML:2.0:status:Success"
+)
+
+type IdentityProvider struct {
+	EntityId    string
+	SsoUrl      string
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+}
+
+// Response describes what the provider answers. Tests alter it to simulate
+// invalid answers.
+type Response struct {
+	Id           string
+	InResponseTo string
+	Destination  string
+	Status       string
+	Issuer       string
+
+	AssertionId  string
+	NameId       string
+	NameIdFormat string
+	Recipient    string
+	Audience     string
+	NotBefore    time.Time
+	NotOnOrAfter time.Time
+	Attributes   map[string]string
+
+	SignResponse  bool
+	SignAssertion bool
+}
+
+func NewIdentityProvider(t testing.TB) *IdentityProvider {
+	key, certificate := NewKeyPair(t)
+
+	return &IdentityProvider{
+		EntityId:    "https://idp.example.com/metadata",
+		SsoUrl:      "https://idp.example.com/sso",
+		Key:         key,
+		Certificate: certificate,
+	}
+}
+
+// NewKeyPair generates a RSA key and a self-signed certificate for it.
+func NewKeyPair(t testing.TB) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "samltest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+
+	return key, certificate
+}
+
+// WriteKeyPair saves the key pair in PEM files in the directory and returns
+// their paths.
+func WriteKeyPair(t testing.TB, dir string, key *rsa.PrivateKey, certificate *x509.Certificate) (string, string) {
+	certificateFile := filepath.Join(dir, "certificate.pem")
+	certificatePem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	require.Nil(t, os.WriteFile(certificateFile, certificatePem, 0600))
+
+	keyFile := filepath.Join(dir, "key.pem")
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.Nil(t, err)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	require.Nil(t, os.WriteFile(keyFile, keyPem, 0600))
+
+	return certificateFile, keyFile
+}
+
+// WriteMetadata saves the metadata of the provider in the directory and
+// returns its path.
+func (p *IdentityProvider) WriteMetadata(t testing.TB, dir string) string {
+	path := filepath.Join(dir, "idp-metadata.xml")
+	require.Nil(t, os.WriteFile(path, p.Metadata(), 0600))
+	return path
+}
+
+func (p *IdentityProvider) Metadata() []byte {
+	return []byte(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + p.EntityId + `">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+        <ds:X509Data>
+          <ds:X509Certificate>` + base64.StdEncoding.EncodeToString(p.Certificate.Raw) + `</ds:X509Certificate>
+        </ds:X509Data>
+      </ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="` + p.SsoUrl + `/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="` + p.SsoUrl + `"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`)
+}
+
+// AuthnRequest is the part of the request the provider needs to answer.
+type AuthnRequest struct {
+	Id                          string `xml:"ID,attr"`
+	Issuer                      string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	AssertionConsumerServiceUrl string `xml:"AssertionConsumerServiceURL,attr"`
+}
+
+// ParseAuthnRequest decodes the request sent with the HTTP-Redirect
+// binding. The signature is not verified.
+func ParseAuthnRequest(t testing.TB, redirect string) (AuthnRequest, url.Values) {
+	parsed, err := url.Parse(redirect)
+	require.Nil(t, err)
+	query := parsed.Query()
+
+	deflated, err := base64.StdEncoding.DecodeString(query.Get("SAMLRequest"))
+	require.Nil(t, err)
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	require.Nil(t, err)
+
+	var out AuthnRequest
+	require.Nil(t, xml.Unmarshal(data, &out))
+
+	return out, query
+}
+
+// NewResponse answers the request with a valid response for the user.
+func (p *IdentityProvider) NewResponse(request AuthnRequest, email string) Response {
+	now := time.Now()
+
+	return Response{
+		Id:           "_response-" + randomId(),
+		InResponseTo: request.Id,
+		Destination:  request.AssertionConsumerServiceUrl,
+		Status:       SuccessStatus,
+		Issuer:       p.EntityId,
+
+		AssertionId:  "_assertion-" + randomId(),
+		NameId:       email,
+		NameIdFormat: EmailNameIdFormat,
+		Recipient:    request.AssertionConsumerServiceUrl,
+		Audience:     request.Issuer,
+		NotBefore:    now.Add(-time.Minute),
+		NotOnOrAfter: now.Add(5 * time.Minute),
+		Attributes: map[string]string{
+			"mail": email,
+		},
+
+		SignAssertion: true,
+	}
+}
+
+// Encode returns the response as posted to the service provider.
+func (p *IdentityProvider) Encode(t testing.TB, response Response) string {
+	doc := etree.NewDocument()
+
+	root := doc.CreateElement("samlp:Response")
+	root.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	root.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	root.CreateAttr("ID", response.Id)
+	root.CreateAttr("Version", "2.0")
+	root.CreateAttr("IssueInstant", formatTime(time.Now()))
+	root.CreateAttr("Destination", response.Destination)
+	root.CreateAttr("InResponseTo", response.InResponseTo)
+	root.CreateElement("saml:Issuer").SetText(response.Issuer)
+	root.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", response.Status)
+
+	assertion := p.assertion(response)
+	if response.SignAssertion {
+		assertion = p.sign(t, assertion)
+	}
+	root.AddChild(assertion)
+
+	if response.SignResponse {
+		root = p.sign(t, root)
+	}
+	doc.SetRoot(root)
+
+	data, err := doc.WriteToBytes()
+	require.Nil(t, err)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func (p *IdentityProvider) assertion(response Response) *etree.Element {
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	assertion.CreateAttr("ID", response.AssertionId)
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", formatTime(time.Now()))
+	assertion.CreateElement("saml:Issuer").SetText(response.Issuer)
+
+	subject := assertion.CreateElement("saml:Subject")
+	nameId := subject.CreateElement("saml:NameID")
+	nameId.CreateAttr("Format", response.NameIdFormat)
+	nameId.SetText(response.NameId)
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	data := confirmation.CreateElement("saml:SubjectConfirmationData")
+	data.CreateAttr("InResponseTo", response.InResponseTo)
+	data.CreateAttr("Recipient", response.Recipient)
+	data.CreateAttr("NotOnOrAfter", formatTime(response.NotOnOrAfter))
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", formatTime(response.NotBefore))
+	conditions.CreateAttr("NotOnOrAfter", formatTime(response.NotOnOrAfter))
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(response.Audience)
+
+	statement := assertion.CreateElement("saml:AuthnStatement")
+	statement.CreateAttr("AuthnInstant", formatTime(time.Now()))
+
+	if len(response.Attributes) > 0 {
+		attributes := assertion.CreateElement("saml:AttributeStatement")
+		for name, value := range response.Attributes {
+			attribute := attributes.CreateElement("saml:Attribute")
+			attribute.CreateAttr("Name", name)
+			attribute.CreateElement("saml:AttributeValue").SetText(value)
+		}
+	}
+
+	return assertion
+}
+
+func (p *IdentityProvider) sign(t testing.TB, el *etree.Element) *etree.Element {
+	ctx, err := dsig.NewSigningContext(p.Key, [][]byte{p.Certificate.Raw})
+	require.Nil(t, err)
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+
+	signed, err := ctx.SignEnveloped(el)
+	require.Nil(t, err)
+
+	return signed
+}
+
+func formatTime(value time.Time) string {
+	return value.UTC().Format(time.RFC3339)
+}
+
+func randomId() string {
+	value := make([]byte, 16)
+	rand.Read(value)
+	return base64.RawURLEncoding.EncodeToString(value)
+}
@@ -0,0 +1,326 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.w3.org/TR/xmldsig-more/#rsa-sha256
+const rsaSha256SignatureMethod = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	metadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	signatureNamespace = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+const (
+	emailNameIdFormat     = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	transientNameIdFormat = "urn:oasis:names:tc:SAML:2.0:nameid-format:transient"
+)
+
+// ServiceProvider signs users in at the identity providers of the tenants.
+type ServiceProvider struct {
+	baseUrl     string
+	key         *rsa.PrivateKey
+	certificate *x509.Certificate
+	tenants     map[string]*Tenant
+}
+
+// Tenant is an organization signing its users in with its own identity
+// provider. Each tenant sees this service as a distinct entity, so that
+// assertions issued for one tenant can't be used for another.
+type Tenant struct {
+	sp     *ServiceProvider
+	config TenantConfig
+	idp    identityProvider
+}
+
+// New loads the key pair and the metadata of the identity providers.
+func New(config Config) (*ServiceProvider, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	out := &ServiceProvider{
+		baseUrl: strings.TrimSuffix(config.BaseUrl, "/"),
+		tenants: make(map[string]*Tenant),
+	}
+	if len(config.Tenants) == 0 {
+		return out, nil
+	}
+
+	var err error
+	out.key, out.certificate, err = loadKeyPair(config.CertificateFile, config.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tenantConfig := range config.Tenants {
+		data, err := os.ReadFile(tenantConfig.MetadataFile)
+		if err != nil {
+			return nil, errors.WrapCode(err, InvalidConfiguration)
+		}
+		idp, err := parseMetadata(data)
+		if err != nil {
+			return nil, err
+		}
+
+		out.tenants[tenantConfig.Name] = &Tenant{
+			sp:     out,
+			config: tenantConfig,
+			idp:    idp,
+		}
+	}
+
+	return out, nil
+}
+
+func (sp *ServiceProvider) Tenant(name string) (*Tenant, bool) {
+	tenant, ok := sp.tenants[name]
+	return tenant, ok
+}
+
+func (sp *ServiceProvider) Enabled() bool {
+	return len(sp.tenants) > 0
+}
+
+func (t *Tenant) Name() string {
+	return t.config.Name
+}
+
+func (t *Tenant) CreateUsers() bool {
+	return t.config.CreateUsers
+}
+
+// IdentityProvider is the entity id of the provider of the tenant.
+func (t *Tenant) IdentityProvider() string {
+	return t.idp.entityId
+}
+
+// EntityId identifies this service at the provider of the tenant. It is
+// also the URL of the metadata.
+func (t *Tenant) EntityId() string {
+	return t.sp.baseUrl + "/saml/" + url.PathEscape(t.config.Name) + "/metadata"
+}
+
+// AcsUrl is the assertion consumer service: the provider posts the
+// responses there.
+func (t *Tenant) AcsUrl() string {
+	return t.sp.baseUrl + "/saml/" + url.PathEscape(t.config.Name) + "/acs"
+}
+
+// https://docs.oasis-open.org/security/saml/v2.0/saml-core-2.0-os.pdf
+type authnRequest struct {
+	XMLName                     xml.Name     `xml:"urn:oasis:names:tc:SAML:2.0:protocol AuthnRequest"`
+	Id                          string       `xml:"ID,attr"`
+	Version                     string       `xml:"Version,attr"`
+	IssueInstant                string       `xml:"IssueInstant,attr"`
+	Destination                 string       `xml:"Destination,attr"`
+	AssertionConsumerServiceUrl string       `xml:"AssertionConsumerServiceURL,attr"`
+	ProtocolBinding             string       `xml:"ProtocolBinding,attr"`
+	Issuer                      issuer       `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	NameIdPolicy                nameIdPolicy `xml:"urn:oasis:names:tc:SAML:2.0:protocol NameIDPolicy"`
+}
+
+type issuer struct {
+	Value string `xml:",chardata"`
+}
+
+type nameIdPolicy struct {
+	AllowCreate bool `xml:"AllowCreate,attr"`
+}
+
+// AuthnRequest returns the identifier of a new request and the URL where
+// the user should be sent to sign in. The request is signed as described
+// by the HTTP-Redirect binding: the provider answers with the identifier
+// and the relay state.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf#page=17
+func (t *Tenant) AuthnRequest(relayState string, now time.Time) (string, string, error) {
+	id := newRequestId()
+	request := authnRequest{
+		Id:                          id,
+		Version:                     "2.0",
+		IssueInstant:                now.UTC().Format(time.RFC3339),
+		Destination:                 t.idp.ssoUrl,
+		AssertionConsumerServiceUrl: t.AcsUrl(),
+		ProtocolBinding:             httpPostBinding,
+		Issuer:                      issuer{Value: t.EntityId()},
+		NameIdPolicy:                nameIdPolicy{AllowCreate: true},
+	}
+
+	data, err := xml.Marshal(request)
+	if err != nil {
+		return "", "", err
+	}
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return "", "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", "", err
+	}
+
+	// The signature covers the parameters in this order, as they appear in
+	// the URL.
+	query := "SAMLRequest=" + url.QueryEscape(base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	query += "&SigAlg=" + url.QueryEscape(rsaSha256SignatureMethod)
+
+	digest := sha256.Sum256([]byte(query))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, t.sp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", "", err
+	}
+	query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(signature))
+
+	separator := "?"
+	if strings.Contains(t.idp.ssoUrl, "?") {
+		separator = "&"
+	}
+
+	return id, t.idp.ssoUrl + separator + query, nil
+}
+
+// Metadata describes this service to the provider of the tenant.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-metadata-2.0-os.pdf
+func (t *Tenant) Metadata() ([]byte, error) {
+	type keyInfo struct {
+		Certificate string `xml:"http://www.w3.org/2000/09/xmldsig# X509Data>X509Certificate"`
+	}
+	type keyDescriptor struct {
+		Use     string  `xml:"use,attr"`
+		KeyInfo keyInfo `xml:"http://www.w3.org/2000/09/xmldsig# KeyInfo"`
+	}
+	type acs struct {
+		Binding  string `xml:"Binding,attr"`
+		Location string `xml:"Location,attr"`
+		Index    int    `xml:"index,attr"`
+	}
+	type spSsoDescriptor struct {
+		AuthnRequestsSigned        bool          `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned       bool          `xml:"WantAssertionsSigned,attr"`
+		ProtocolSupportEnumeration string        `xml:"protocolSupportEnumeration,attr"`
+		KeyDescriptor              keyDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata KeyDescriptor"`
+		AssertionConsumerService   acs           `xml:"urn:oasis:names:tc:SAML:2.0:metadata AssertionConsumerService"`
+	}
+	type spEntityDescriptor struct {
+		XMLName  xml.Name        `xml:"urn:oasis:names:tc:SAML:2.0:metadata EntityDescriptor"`
+		EntityId string          `xml:"entityID,attr"`
+		Sp       spSsoDescriptor `xml:"urn:oasis:names:tc:SAML:2.0:metadata SPSSODescriptor"`
+	}
+
+	descriptor := spEntityDescriptor{
+		EntityId: t.EntityId(),
+		Sp: spSsoDescriptor{
+			AuthnRequestsSigned:        true,
+			WantAssertionsSigned:       true,
+			ProtocolSupportEnumeration: protocolNamespace,
+			KeyDescriptor: keyDescriptor{
+				Use:     "signing",
+				KeyInfo: keyInfo{Certificate: base64.StdEncoding.EncodeToString(t.sp.certificate.Raw)},
+			},
+			AssertionConsumerService: acs{
+				Binding:  httpPostBinding,
+				Location: t.AcsUrl(),
+			},
+		},
+	}
+
+	data, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// newRequestId generates an identifier which is a valid xsd:ID: it can't
+// start with a digit.
+// NewRelayState returns a value to bind the response of the provider to the
+// browser which started the login. It fits in the 80 bytes allowed by the
+// binding.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf#page=15
+func NewRelayState() string {
+	value := make([]byte, 32)
+	// Never returns an error.
+	rand.Read(value)
+
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func newRequestId() string {
+	value := make([]byte, 20)
+	// Never returns an error.
+	rand.Read(value)
+
+	return "_" + hex.EncodeToString(value)
+}
+
+func loadKeyPair(certificateFile string, privateKeyFile string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	certificateData, err := os.ReadFile(certificateFile)
+	if err != nil {
+		return nil, nil, errors.WrapCode(err, InvalidConfiguration)
+	}
+	block, _ := pem.Decode(certificateData)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, errors.NewCodeWithDetails(InvalidConfiguration, "invalid certificate")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, errors.WrapCode(err, InvalidConfiguration)
+	}
+
+	keyData, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, nil, errors.WrapCode(err, InvalidConfiguration)
+	}
+	block, _ = pem.Decode(keyData)
+	if block == nil {
+		return nil, nil, errors.NewCodeWithDetails(InvalidConfiguration, "invalid private key")
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, nil, errors.WrapCode(err, InvalidConfiguration)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.NewCodeWithDetails(InvalidConfiguration, "the private key must be an RSA key")
+	}
+	if !rsaKey.PublicKey.Equal(certificate.PublicKey) {
+		return nil, nil, errors.NewCodeWithDetails(InvalidConfiguration, "the private key does not match the certificate")
+	}
+
+	return rsaKey, certificate, nil
+}
@@ -0,0 +1,109 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/saml/samltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_Tenant_Urls(t *testing.T) {
+	tenant := newTestTenant(t, samltest.NewIdentityProvider(t))
+
+	assert.Equal(t, "https://users.example.com/v1/users/saml/acme/metadata", tenant.EntityId())
+	assert.Equal(t, "https://users.example.com/v1/users/saml/acme/acs", tenant.AcsUrl())
+	assert.Equal(t, "https://idp.example.com/metadata", tenant.IdentityProvider())
+}
+
+func TestUnit_Tenant_AuthnRequest(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	tenant := newTestTenant(t, idp)
+
+	id, redirect, err := tenant.AuthnRequest("my-relay-state", time.Now())
+
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(redirect, idp.SsoUrl+"?"))
+	request, query := samltest.ParseAuthnRequest(t, redirect)
+	assert.Equal(t, id, request.Id)
+	assert.Equal(t, tenant.EntityId(), request.Issuer)
+	assert.Equal(t, tenant.AcsUrl(), request.AssertionConsumerServiceUrl)
+	assert.Equal(t, "my-relay-state", query.Get("RelayState"))
+	assert.Equal(t, rsaSha256SignatureMethod, query.Get("SigAlg"))
+}
+
+func TestUnit_Tenant_AuthnRequest_ExpectSignatureCoversQuery(t *testing.T) {
+	tenant := newTestTenant(t, samltest.NewIdentityProvider(t))
+
+	_, redirect, err := tenant.AuthnRequest("my-relay-state", time.Now())
+
+	require.Nil(t, err)
+	rawQuery := redirect[strings.Index(redirect, "?")+1:]
+	signedPart := rawQuery[:strings.Index(rawQuery, "&Signature=")]
+	query, err := url.ParseQuery(rawQuery)
+	require.Nil(t, err)
+	signature, err := base64.StdEncoding.DecodeString(query.Get("Signature"))
+	require.Nil(t, err)
+	digest := sha256.Sum256([]byte(signedPart))
+	publicKey := tenant.sp.certificate.PublicKey.(*rsa.PublicKey)
+	assert.Nil(t, rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature))
+}
+
+func TestUnit_Tenant_AuthnRequest_ExpectUniqueIdentifiers(t *testing.T) {
+	tenant := newTestTenant(t, samltest.NewIdentityProvider(t))
+
+	first, _, err := tenant.AuthnRequest("", time.Now())
+	require.Nil(t, err)
+	second, _, err := tenant.AuthnRequest("", time.Now())
+	require.Nil(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.True(t, strings.HasPrefix(first, "_"))
+}
+
+func TestUnit_Tenant_Metadata(t *testing.T) {
+	tenant := newTestTenant(t, samltest.NewIdentityProvider(t))
+
+	actual, err := tenant.Metadata()
+
+	require.Nil(t, err)
+	assert.Contains(t, string(actual), `entityID="`+tenant.EntityId()+`"`)
+	assert.Contains(t, string(actual), `Location="`+tenant.AcsUrl()+`"`)
+	assert.Contains(t, string(actual), base64.StdEncoding.EncodeToString(tenant.sp.certificate.Raw))
+}
+
+func TestUnit_New_WhenKeyDoesNotMatchCertificate_ExpectError(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	dir := t.TempDir()
+	key, _ := samltest.NewKeyPair(t)
+	_, otherCertificate := samltest.NewKeyPair(t)
+	certificateFile, keyFile := samltest.WriteKeyPair(t, dir, key, otherCertificate)
+	config := Config{
+		BaseUrl:         testBaseUrl,
+		CertificateFile: certificateFile,
+		PrivateKeyFile:  keyFile,
+		RequestValidity: time.Minute,
+		Tenants: []TenantConfig{
+			{Name: "acme", MetadataFile: idp.WriteMetadata(t, dir), EmailDomains: []string{"acme.com"}},
+		},
+	}
+
+	_, err := New(config)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_New_WhenNoTenants_ExpectDisabled(t *testing.T) {
+	sp, err := New(Config{})
+
+	require.Nil(t, err)
+	assert.False(t, sp.Enabled())
+}
@@ -0,0 +1,9 @@
+package service
+
+import (
+	"github.com/google/uuid"
+)
+
+type AdminConfig struct {
+	Users []uuid.UUID
+}
@@ -2,8 +2,99 @@ package service
 
 import (
 	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type EvictionPolicy string
+
+const (
+	EvictOldest EvictionPolicy = "oldest"
+	RejectNew   EvictionPolicy = "reject"
 )
 
 type ApiKeyConfig struct {
+	// Validity is how long a session stays valid after the user logged in
+	// or last used it.
 	Validity time.Duration
+	// MaxLifetime is the maximum duration of a session, counted from the
+	// login: using a session never extends it past this limit. Sessions
+	// are not extended on use when set to 0.
+	MaxLifetime time.Duration
+	// RefreshValidity is how long the refresh token returned on login can
+	// be used to obtain new keys. Refresh tokens are not issued when set
+	// to 0.
+	RefreshValidity time.Duration
+	// MaxPerUser is the number of sessions a user can have at the same
+	// time. It is not limited when set to 0.
+	MaxPerUser int
+	// Eviction defines what happens when a user logs in while already
+	// having the maximum number of sessions.
+	Eviction EvictionPolicy
+	// Pepper is an optional secret used to compute the digest of the keys
+	// stored in the database. Changing it invalidates all the keys. When a
+	// keyring is configured it provides the pepper instead: this one is only
+	// used to look up the keys issued before.
+	Pepper string
+	// LegacyFormatDeadline is the date (in RFC 3339 format) until which keys
+	// issued as plain UUIDs are still accepted. They are rejected as soon as
+	// it is left empty.
+	LegacyFormatDeadline string
+}
+
+func (c ApiKeyConfig) Validate() error {
+	if c.MaxPerUser > 0 && c.Eviction != EvictOldest && c.Eviction != RejectNew {
+		return errors.NewCodeWithDetails(UnsupportedEvictionPolicy, string(c.Eviction))
+	}
+
+	_, err := c.parseLegacyFormatDeadline()
+	return err
+}
+
+// legacyFormatDeadline returns the zero time when the deadline can't be
+// parsed: this rejects legacy keys, which is the safe option.
+func (c ApiKeyConfig) legacyFormatDeadline() time.Time {
+	deadline, err := c.parseLegacyFormatDeadline()
+	if err != nil {
+		return time.Time{}
+	}
+
+	return deadline
+}
+
+func (c ApiKeyConfig) parseLegacyFormatDeadline() (time.Time, error) {
+	if c.LegacyFormatDeadline == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, c.LegacyFormatDeadline)
+}
+
+// sessionValidUntil returns the expiration date of a session created at
+// createdAt when it is used at usedAt.
+func sessionValidUntil(createdAt time.Time, usedAt time.Time, validity time.Duration, maxLifetime time.Duration) time.Time {
+	if maxLifetime == 0 {
+		return createdAt.Add(validity)
+	}
+
+	validUntil := usedAt.Add(validity)
+
+	lifetimeEnd := createdAt.Add(maxLifetime)
+	if validUntil.After(lifetimeEnd) {
+		return lifetimeEnd
+	}
+
+	return validUntil
+}
+
+// extendedValidUntil returns the expiration date of the session when it is
+// used at usedAt. Signed tokens carry their own expiration date: sessions
+// they were issued for are not extended.
+func extendedValidUntil(session persistence.ApiKey, usedAt time.Time, validity time.Duration, maxLifetime time.Duration) time.Time {
+	if session.TokenId != nil {
+		return session.ValidUntil
+	}
+
+	return sessionValidUntil(session.CreatedAt, usedAt, validity, maxLifetime)
 }
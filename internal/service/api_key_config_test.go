@@ -0,0 +1,81 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_ApiKeyConfig_Validate_WhenDeadlineIsEmpty_ExpectSuccess(t *testing.T) {
+	config := ApiKeyConfig{}
+
+	assert.Nil(t, config.Validate())
+	assert.True(t, config.legacyFormatDeadline().IsZero())
+}
+
+func TestUnit_ApiKeyConfig_Validate_WhenDeadlineIsValid_ExpectSuccess(t *testing.T) {
+	config := ApiKeyConfig{
+		LegacyFormatDeadline: "2027-01-01T00:00:00Z",
+	}
+
+	assert.Nil(t, config.Validate())
+	expected := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, expected.Equal(config.legacyFormatDeadline()))
+}
+
+func TestUnit_ApiKeyConfig_Validate_WhenDeadlineIsInvalid_ExpectFailure(t *testing.T) {
+	config := ApiKeyConfig{
+		LegacyFormatDeadline: "next year",
+	}
+
+	assert.NotNil(t, config.Validate())
+	assert.True(t, config.legacyFormatDeadline().IsZero())
+}
+
+func TestUnit_ApiKeyConfig_Validate_WhenEvictionPolicyIsUnsupported_ExpectFailure(t *testing.T) {
+	config := ApiKeyConfig{
+		MaxPerUser: 2,
+		Eviction:   "newest",
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedEvictionPolicy), "Actual err: %v", err)
+}
+
+func TestUnit_ApiKeyConfig_Validate_WhenSessionsAreNotLimited_ExpectEvictionPolicyIgnored(t *testing.T) {
+	config := ApiKeyConfig{
+		MaxPerUser: 0,
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_SessionValidUntil_ExpectValidityCountedFromLastUse(t *testing.T) {
+	createdAt := time.Date(2024, 11, 12, 10, 0, 0, 0, time.UTC)
+	usedAt := createdAt.Add(5 * time.Hour)
+
+	actual := sessionValidUntil(createdAt, usedAt, time.Hour, 24*time.Hour)
+
+	assert.Equal(t, usedAt.Add(time.Hour), actual)
+}
+
+func TestUnit_SessionValidUntil_WhenMaxLifetimeIsReached_ExpectCapped(t *testing.T) {
+	createdAt := time.Date(2024, 11, 12, 10, 0, 0, 0, time.UTC)
+	usedAt := createdAt.Add(23*time.Hour + 30*time.Minute)
+
+	actual := sessionValidUntil(createdAt, usedAt, time.Hour, 24*time.Hour)
+
+	assert.Equal(t, createdAt.Add(24*time.Hour), actual)
+}
+
+func TestUnit_SessionValidUntil_WhenMaxLifetimeIsNotSet_ExpectNoExtension(t *testing.T) {
+	createdAt := time.Date(2024, 11, 12, 10, 0, 0, 0, time.UTC)
+	usedAt := createdAt.Add(2 * time.Hour)
+
+	actual := sessionValidUntil(createdAt, usedAt, 3*time.Hour, 0)
+
+	assert.Equal(t, createdAt.Add(3*time.Hour), actual)
+}
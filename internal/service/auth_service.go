@@ -2,33 +2,156 @@ package service
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
 	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	"github.com/google/uuid"
 )
 
+// Keys issued by the login endpoints belong to the service itself: this is
+// the client reported when introspecting them. Keys issued to OAuth clients
+// report the client instead.
+const sessionClientId = "user-service"
+
+// https://datatracker.ietf.org/doc/html/rfc6750#section-1
+const bearerTokenType = "Bearer"
+
 type AuthService interface {
-	Authenticate(ctx context.Context, apiKey uuid.UUID) (communication.AuthorizationDtoResponse, error)
+	// Authenticate verifies the key and returns the roles of the user behind
+	// it, including the inherited ones, along with the permissions they
+	// grant.
+	Authenticate(ctx context.Context, apiKey apikey.Key) (communication.AuthorizationDtoResponse, error)
+	// Introspect describes the key to the client when it is allowed to
+	// know about it. Keys which can't be used are reported as inactive.
+	Introspect(ctx context.Context, client ClientCredentials, key string) (communication.IntrospectionDtoResponse, error)
 }
 
 type authServiceImpl struct {
-	apiKeyRepo repositories.ApiKeyRepository
+	apiKeyRepo  repositories.ApiKeyRepository
+	signer      jwt.Signer
+	revocations *revocationList
+	admins      []uuid.UUID
+	clients     OAuthConfig
+
+	roles roleResolver
+
+	apiKeyValidity       time.Duration
+	apiKeyMaxLifetime    time.Duration
+	digester             digester
+	legacyFormatDeadline time.Time
+}
+
+// authenticatedKey describes the session a key gives access to.
+type authenticatedKey struct {
+	user  uuid.UUID
+	roles []string
+	// permissions and resolved are set for the signed tokens, which carry
+	// the roles and permissions of the user when they were issued.
+	permissions []string
+	resolved    bool
+	clientId    string
+	expiresAt   time.Time
 }
 
-func NewAuthService(repos repositories.Repositories) AuthService {
+// NewAuthService creates a service which verifies signed tokens with the
+// signer, if any, and looks up the other keys in the database. The keyring
+// is optional as well.
+func NewAuthService(config ApiKeyConfig, adminConfig AdminConfig, oauthConfig OAuthConfig, signer jwt.Signer, ring keyring.Keyring, repos repositories.Repositories) AuthService {
 	return &authServiceImpl{
-		apiKeyRepo: repos.ApiKey,
+		apiKeyRepo:  repos.ApiKey,
+		signer:      signer,
+		revocations: newRevocationList(repos.RevokedToken),
+		admins:      adminConfig.Users,
+		clients:     oauthConfig,
+
+		roles: roleResolver{
+			roleRepo:       repos.Role,
+			assignmentRepo: repos.RoleAssignment,
+		},
+
+		apiKeyValidity:    config.Validity,
+		apiKeyMaxLifetime: config.MaxLifetime,
+		digester: digester{
+			keyring:      ring,
+			legacyPepper: config.Pepper,
+		},
+		legacyFormatDeadline: config.legacyFormatDeadline(),
+	}
+}
+
+func (s *authServiceImpl) Authenticate(ctx context.Context, apiKey apikey.Key) (communication.AuthorizationDtoResponse, error) {
+	session, err := s.authenticate(ctx, apiKey)
+	if err != nil {
+		return communication.AuthorizationDtoResponse{}, err
+	}
+
+	return s.authorize(ctx, session)
+}
+
+func (s *authServiceImpl) Introspect(ctx context.Context, client ClientCredentials, key string) (communication.IntrospectionDtoResponse, error) {
+	var out communication.IntrospectionDtoResponse
+
+	if !s.clients.authenticate(client) {
+		return out, errors.NewCode(ClientNotAuthenticated)
+	}
+
+	// The client does not need to know why the key can't be used.
+	apiKey, err := apikey.Parse(key)
+	if err != nil {
+		return out, nil
+	}
+
+	session, err := s.authenticate(ctx, apiKey)
+	if err != nil {
+		if isInactiveKey(err) {
+			return out, nil
+		}
+
+		return out, err
 	}
+
+	clientId := session.clientId
+	if clientId == "" {
+		clientId = sessionClientId
+	}
+
+	out = communication.IntrospectionDtoResponse{
+		Active:    true,
+		Subject:   session.user.String(),
+		ExpiresAt: session.expiresAt.Unix(),
+		Scope:     strings.Join(session.roles, " "),
+		ClientId:  clientId,
+		TokenType: bearerTokenType,
+	}
+
+	return out, nil
 }
 
-func (s *authServiceImpl) Authenticate(ctx context.Context, apiKey uuid.UUID) (communication.AuthorizationDtoResponse, error) {
-	var out communication.AuthorizationDtoResponse
+func (s *authServiceImpl) authenticate(ctx context.Context, apiKey apikey.Key) (authenticatedKey, error) {
+	var out authenticatedKey
 
-	key, err := s.apiKeyRepo.GetForKey(ctx, apiKey)
+	if apiKey.Token() && s.signer != nil {
+		return s.authenticateToken(ctx, apiKey)
+	}
+
+	if apiKey.Legacy() && !time.Now().Before(s.legacyFormatDeadline) {
+		return out, errors.NewCode(LegacyApiKeyRejected)
+	}
+
+	keyHashes, err := s.digester.candidates(ctx, apiKey.String())
+	if err != nil {
+		return out, err
+	}
+
+	key, err := s.apiKeyRepo.GetForKey(ctx, keyHashes)
 	if err != nil {
 		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
 			return out, errors.NewCode(UserNotAuthenticated)
@@ -37,9 +160,108 @@ func (s *authServiceImpl) Authenticate(ctx context.Context, apiKey uuid.UUID) (c
 		return out, err
 	}
 
-	if key.ValidUntil.Before(time.Now()) {
+	now := time.Now()
+	if key.ValidUntil.Before(now) {
 		return out, errors.NewCode(AuthenticationExpired)
 	}
+	if key.PasswordChangeRequired {
+		return out, errors.NewCode(PasswordChangeRequired)
+	}
+
+	validUntil := extendedValidUntil(key, now, s.apiKeyValidity, s.apiKeyMaxLifetime)
+	err = s.apiKeyRepo.Touch(ctx, key.Id, now, validUntil)
+	if err != nil {
+		return out, err
+	}
+
+	out = authenticatedKey{
+		user:      key.ApiUser,
+		roles:     rolesOf(s.admins, key),
+		clientId:  key.ClientId,
+		expiresAt: validUntil,
+	}
 
 	return out, nil
 }
+
+func (s *authServiceImpl) authenticateToken(ctx context.Context, apiKey apikey.Key) (authenticatedKey, error) {
+	var out authenticatedKey
+	now := time.Now()
+
+	claims, err := s.signer.Verify(ctx, apiKey.String(), now)
+	if err != nil {
+		if errors.IsErrorWithCode(err, jwt.TokenExpired) {
+			return out, errors.WrapCode(err, AuthenticationExpired)
+		}
+
+		return out, errors.WrapCode(err, UserNotAuthenticated)
+	}
+
+	id, err := uuid.Parse(claims.Id)
+	if err != nil {
+		return out, errors.WrapCode(err, UserNotAuthenticated)
+	}
+	user, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return out, errors.WrapCode(err, UserNotAuthenticated)
+	}
+
+	revoked, err := s.revocations.contains(ctx, id, now)
+	if err != nil {
+		return out, err
+	}
+	if revoked {
+		return out, errors.NewCode(UserNotAuthenticated)
+	}
+
+	out = authenticatedKey{
+		user:        user,
+		roles:       claims.Roles,
+		permissions: claims.Permissions,
+		resolved:    true,
+		clientId:    claims.ClientId,
+		expiresAt:   claims.Expiration(),
+	}
+
+	return out, nil
+}
+
+// authorize resolves the roles of the session: the built-in ones it was
+// opened with and the ones assigned to the user, along with all the roles
+// they inherit from. Sessions opened by OAuth clients don't get the assigned
+// roles, as users only delegate their identity to them. Signed tokens carry
+// the roles resolved when they were issued: they are trusted until the token
+// expires, so that verifying it does not query the database.
+func (s *authServiceImpl) authorize(ctx context.Context, session authenticatedKey) (communication.AuthorizationDtoResponse, error) {
+	out := communication.AuthorizationDtoResponse{
+		User:        session.user,
+		Roles:       slices.Clone(session.roles),
+		Permissions: slices.Clone(session.permissions),
+	}
+	if out.Roles == nil {
+		out.Roles = []string{}
+	}
+	if out.Permissions == nil {
+		out.Permissions = []string{}
+	}
+	if session.resolved || session.clientId != "" {
+		return out, nil
+	}
+
+	roles, permissions, err := s.roles.resolve(ctx, session.user, out.Roles)
+	if err != nil {
+		return out, err
+	}
+
+	out.Roles = roles
+	out.Permissions = permissions
+
+	return out, nil
+}
+
+func isInactiveKey(err error) bool {
+	return errors.IsErrorWithCode(err, UserNotAuthenticated) ||
+		errors.IsErrorWithCode(err, AuthenticationExpired) ||
+		errors.IsErrorWithCode(err, LegacyApiKeyRejected) ||
+		errors.IsErrorWithCode(err, PasswordChangeRequired)
+}
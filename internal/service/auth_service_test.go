@@ -7,10 +7,14 @@ import (
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockApiKeyRepository struct {
@@ -18,6 +22,23 @@ type mockApiKeyRepository struct {
 
 	apiKey persistence.ApiKey
 	err    error
+
+	touched           uuid.UUID
+	touchedValidUntil time.Time
+}
+
+type mockRoleRepository struct {
+	repositories.RoleRepository
+
+	inherited   []persistence.Role
+	permissions []persistence.RolePermission
+}
+
+type mockRoleAssignmentRepository struct {
+	repositories.RoleAssignmentRepository
+
+	roles []persistence.Role
+	calls int
 }
 
 func TestUnit_AuthService_Authenticate_WhenKeyDoesNotExist_ExpectFailure(t *testing.T) {
@@ -26,7 +47,7 @@ func TestUnit_AuthService_Authenticate_WhenKeyDoesNotExist_ExpectFailure(t *test
 	}
 
 	service := newTestAuthService(repo)
-	_, err := service.Authenticate(context.Background(), uuid.New())
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
 
 	assert.True(t, errors.IsErrorWithCode(err, UserNotAuthenticated), "Actual err: %v", err)
 }
@@ -40,32 +61,580 @@ func TestUnit_AuthService_Authenticate_WhenKeyExpired_ExpectFailure(t *testing.T
 	}
 
 	service := newTestAuthService(repo)
-	_, err := service.Authenticate(context.Background(), uuid.New())
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
 
 	assert.True(t, errors.IsErrorWithCode(err, AuthenticationExpired), "Actual err: %v", err)
 }
 
+func TestUnit_AuthService_Authenticate_WhenPasswordChangeIsRequired_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ValidUntil:             time.Now().Add(time.Hour),
+			PasswordChangeRequired: true,
+		},
+	}
+
+	service := newTestAuthService(repo)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, PasswordChangeRequired), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenLegacyKeyBeforeDeadline_ExpectSuccess(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+	config := ApiKeyConfig{
+		LegacyFormatDeadline: time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+
+	service := newTestAuthServiceWithConfig(repo, config)
+	_, err := service.Authenticate(context.Background(), newTestLegacyApiKey(t))
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenLegacyKeyAfterDeadline_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+	config := ApiKeyConfig{
+		LegacyFormatDeadline: time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}
+
+	service := newTestAuthServiceWithConfig(repo, config)
+	_, err := service.Authenticate(context.Background(), newTestLegacyApiKey(t))
+
+	assert.True(t, errors.IsErrorWithCode(err, LegacyApiKeyRejected), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenLegacyKeyAndNoDeadline_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+
+	service := newTestAuthService(repo)
+	_, err := service.Authenticate(context.Background(), newTestLegacyApiKey(t))
+
+	assert.True(t, errors.IsErrorWithCode(err, LegacyApiKeyRejected), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_ExpectKeyIsTouched(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+
+	service := newTestAuthService(repo)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.Equal(t, repo.apiKey.Id, repo.touched)
+}
+
+func TestUnit_AuthService_Authenticate_WhenKeyExpired_ExpectKeyIsNotTouched(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			ValidUntil: time.Now().Add(-time.Hour),
+		},
+	}
+
+	service := newTestAuthService(repo)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, AuthenticationExpired), "Actual err: %v", err)
+	assert.Equal(t, uuid.Nil, repo.touched)
+}
+
+func TestUnit_AuthService_Authenticate_ExpectSessionIsExtended(t *testing.T) {
+	createdAt := time.Now().Add(-2 * time.Hour)
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			CreatedAt:  createdAt,
+			ValidUntil: time.Now().Add(10 * time.Minute),
+		},
+	}
+	config := ApiKeyConfig{
+		Validity:    time.Hour,
+		MaxLifetime: 24 * time.Hour,
+	}
+
+	service := newTestAuthServiceWithConfig(repo, config)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), repo.touchedValidUntil, time.Minute)
+}
+
+func TestUnit_AuthService_Authenticate_WhenCloseToMaxLifetime_ExpectSessionIsCapped(t *testing.T) {
+	createdAt := time.Now().Add(-23*time.Hour - 30*time.Minute)
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			CreatedAt:  createdAt,
+			ValidUntil: time.Now().Add(10 * time.Minute),
+		},
+	}
+	config := ApiKeyConfig{
+		Validity:    time.Hour,
+		MaxLifetime: 24 * time.Hour,
+	}
+
+	service := newTestAuthServiceWithConfig(repo, config)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.Equal(t, createdAt.Add(24*time.Hour), repo.touchedValidUntil)
+}
+
+func TestUnit_AuthService_Authenticate_WhenSessionHasToken_ExpectSessionIsNotExtended(t *testing.T) {
+	tokenId := uuid.New()
+	validUntil := time.Now().Add(time.Minute)
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			TokenId:    &tokenId,
+			CreatedAt:  time.Now(),
+			ValidUntil: validUntil,
+		},
+	}
+	config := ApiKeyConfig{
+		Validity:    time.Hour,
+		MaxLifetime: 24 * time.Hour,
+	}
+
+	service := newTestAuthServiceWithConfig(repo, config)
+	_, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.Equal(t, validUntil, repo.touchedValidUntil)
+}
+
+func TestUnit_AuthService_Authenticate_WhenTokenIsValid_ExpectDatabaseNotQueried(t *testing.T) {
+	signer := newTestSigner(t)
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+	revokedRepo := &mockRevokedTokenRepository{}
+
+	service := newTestAuthServiceWithSigner(signer, repo, revokedRepo)
+	_, err := service.Authenticate(context.Background(), newTestToken(t, signer, time.Now().Add(time.Hour)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, uuid.Nil, repo.touched)
+}
+
+func TestUnit_AuthService_Authenticate_WhenTokenIsValid_ExpectRolesAndPermissionsFromClaims(t *testing.T) {
+	signer := newTestSigner(t)
+	claims := jwt.Claims{
+		Id:          uuid.NewString(),
+		Subject:     uuid.NewString(),
+		Session:     uuid.NewString(),
+		Roles:       []string{UserRole, "editor"},
+		Permissions: []string{"articles:write"},
+		IssuedAt:    time.Now().Unix(),
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	}
+	token, err := signer.Sign(context.Background(), claims)
+	require.Nil(t, err)
+	assignmentRepo := &mockRoleAssignmentRepository{}
+	repos := repositories.Repositories{
+		ApiKey:         &mockApiKeyRepository{},
+		RevokedToken:   &mockRevokedTokenRepository{},
+		Role:           &mockRoleRepository{},
+		RoleAssignment: assignmentRepo,
+	}
+
+	service := NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, signer, nil, repos)
+	actual, err := service.Authenticate(context.Background(), newTestApiKey(t, token))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{UserRole, "editor"}, actual.Roles)
+	assert.Equal(t, []string{"articles:write"}, actual.Permissions)
+	assert.Zero(t, assignmentRepo.calls)
+}
+
+func TestUnit_AuthService_Authenticate_WhenTokenIsRevoked_ExpectFailure(t *testing.T) {
+	signer := newTestSigner(t)
+	token := newTestToken(t, signer, time.Now().Add(time.Hour))
+	claims, err := signer.Verify(context.Background(), token.String(), time.Now())
+	require.Nil(t, err)
+	revokedRepo := &mockRevokedTokenRepository{
+		revoked: []uuid.UUID{uuid.MustParse(claims.Id)},
+	}
+
+	service := newTestAuthServiceWithSigner(signer, &mockApiKeyRepository{}, revokedRepo)
+	_, err = service.Authenticate(context.Background(), token)
+
+	assert.True(t, errors.IsErrorWithCode(err, UserNotAuthenticated), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenTokenIsExpired_ExpectFailure(t *testing.T) {
+	signer := newTestSigner(t)
+
+	service := newTestAuthServiceWithSigner(signer, &mockApiKeyRepository{}, &mockRevokedTokenRepository{})
+	_, err := service.Authenticate(context.Background(), newTestToken(t, signer, time.Now().Add(-time.Hour)))
+
+	assert.True(t, errors.IsErrorWithCode(err, AuthenticationExpired), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenTokenIsSignedByAnotherKey_ExpectFailure(t *testing.T) {
+	signer := newTestSigner(t)
+
+	service := newTestAuthServiceWithSigner(signer, &mockApiKeyRepository{}, &mockRevokedTokenRepository{})
+	_, err := service.Authenticate(context.Background(), newTestToken(t, newTestSigner(t), time.Now().Add(time.Hour)))
+
+	assert.True(t, errors.IsErrorWithCode(err, UserNotAuthenticated), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Authenticate_WhenNoRoleIsAssigned_ExpectBuiltInRoles(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+
+	service := newTestAuthServiceWithClients(repo, user)
+	actual, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	expected := communication.AuthorizationDtoResponse{
+		User:        user,
+		Roles:       []string{UserRole, AdminRole},
+		Permissions: []string{},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_AuthService_Authenticate_ExpectInheritedRolesAndPermissionsResolved(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    uuid.New(),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+	editor := persistence.Role{Id: uuid.New(), Name: "editor"}
+	reviewer := persistence.Role{Id: uuid.New(), Name: "reviewer"}
+	reader := persistence.Role{Id: uuid.New(), Name: "reader"}
+	roleRepo := &mockRoleRepository{
+		inherited: []persistence.Role{editor, reader, reviewer},
+		permissions: []persistence.RolePermission{
+			{Role: reader.Id, Permission: "articles:read"},
+			{Role: editor.Id, Permission: "articles:write"},
+			{Role: reviewer.Id, Permission: "articles:read"},
+		},
+	}
+	assignmentRepo := &mockRoleAssignmentRepository{
+		roles: []persistence.Role{editor, reviewer},
+	}
+
+	service := newTestAuthServiceWithRoles(repo, roleRepo, assignmentRepo)
+	actual, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{UserRole, "editor", "reader", "reviewer"}, actual.Roles)
+	assert.Equal(t, []string{"articles:read", "articles:write"}, actual.Permissions)
+}
+
+func TestUnit_AuthService_Authenticate_WhenSessionBelongsToClient_ExpectAssignedRolesIgnored(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    uuid.New(),
+			ClientId:   "my-client",
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+	assignmentRepo := &mockRoleAssignmentRepository{
+		roles: []persistence.Role{{Id: uuid.New(), Name: "editor"}},
+	}
+
+	service := newTestAuthServiceWithRoles(repo, &mockRoleRepository{}, assignmentRepo)
+	actual, err := service.Authenticate(context.Background(), apikey.Generate())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{UserRole}, actual.Roles)
+	assert.Empty(t, actual.Permissions)
+	assert.Zero(t, assignmentRepo.calls)
+}
+
+func TestUnit_AuthService_Introspect_WhenClientIsNotAuthenticated_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{}
+
+	service := newTestAuthServiceWithClients(repo)
+	credentials := ClientCredentials{Id: "my-client", Secret: "not-my-secret"}
+	_, err := service.Introspect(context.Background(), credentials, apikey.Generate().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, ClientNotAuthenticated), "Actual err: %v", err)
+	assert.Equal(t, uuid.Nil, repo.touched)
+}
+
+func TestUnit_AuthService_Introspect_ExpectKeyIsDescribed(t *testing.T) {
+	admin := uuid.MustParse("4f26321f-d0ea-46a3-83dd-6aa1c6053aaf")
+	createdAt := time.Now().Truncate(time.Second)
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			ApiUser:    admin,
+			CreatedAt:  createdAt,
+			ValidUntil: createdAt.Add(time.Hour),
+		},
+	}
+
+	service := newTestAuthServiceWithClients(repo, admin)
+	actual, err := service.Introspect(context.Background(), oauthTestClient, apikey.Generate().String())
+
+	assert.Nil(t, err)
+	expected := communication.IntrospectionDtoResponse{
+		Active:    true,
+		Subject:   admin.String(),
+		ExpiresAt: createdAt.Add(time.Hour).Unix(),
+		Scope:     "user admin",
+		ClientId:  "user-service",
+		TokenType: "Bearer",
+	}
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, repo.apiKey.Id, repo.touched)
+}
+
+func TestUnit_AuthService_Introspect_WhenKeyCannotBeUsed_ExpectInactive(t *testing.T) {
+	type testCase struct {
+		key  string
+		repo *mockApiKeyRepository
+	}
+
+	testCases := map[string]testCase{
+		"malformed": {
+			key:  "not-a-key",
+			repo: &mockApiKeyRepository{},
+		},
+		"unknown": {
+			key:  apikey.Generate().String(),
+			repo: &mockApiKeyRepository{err: errors.NewCode(db.NoMatchingRows)},
+		},
+		"expired": {
+			key:  apikey.Generate().String(),
+			repo: &mockApiKeyRepository{apiKey: persistence.ApiKey{ValidUntil: time.Now().Add(-time.Hour)}},
+		},
+		"legacy": {
+			key:  uuid.NewString(),
+			repo: &mockApiKeyRepository{},
+		},
+		"passwordChangeRequired": {
+			key:  apikey.Generate().String(),
+			repo: &mockApiKeyRepository{apiKey: persistence.ApiKey{ValidUntil: time.Now().Add(time.Hour), PasswordChangeRequired: true}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			service := newTestAuthServiceWithClients(tc.repo)
+			actual, err := service.Introspect(context.Background(), oauthTestClient, tc.key)
+
+			assert.Nil(t, err)
+			assert.Equal(t, communication.IntrospectionDtoResponse{}, actual)
+		})
+	}
+}
+
+func TestUnit_AuthService_Introspect_WhenLookupFails_ExpectError(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NotConnected),
+	}
+
+	service := newTestAuthServiceWithClients(repo)
+	_, err := service.Introspect(context.Background(), oauthTestClient, apikey.Generate().String())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NotConnected), "Actual err: %v", err)
+}
+
+func TestUnit_AuthService_Introspect_WhenKeyIsToken_ExpectClaimsAreDescribed(t *testing.T) {
+	signer := newTestSigner(t)
+	expiresAt := time.Now().Add(time.Hour)
+	token := newTestToken(t, signer, expiresAt)
+	claims, err := signer.Verify(context.Background(), token.String(), time.Now())
+	require.Nil(t, err)
+	repos := repositories.Repositories{
+		ApiKey:         &mockApiKeyRepository{},
+		RevokedToken:   &mockRevokedTokenRepository{},
+		Role:           &mockRoleRepository{},
+		RoleAssignment: &mockRoleAssignmentRepository{},
+	}
+
+	service := NewAuthService(ApiKeyConfig{}, AdminConfig{}, oauthTestConfig, signer, nil, repos)
+	actual, err := service.Introspect(context.Background(), oauthTestClient, token.String())
+
+	assert.Nil(t, err)
+	assert.True(t, actual.Active)
+	assert.Equal(t, claims.Subject, actual.Subject)
+	assert.Equal(t, expiresAt.Unix(), actual.ExpiresAt)
+	assert.Equal(t, "user", actual.Scope)
+}
+
+func TestIT_AuthService_Authenticate_ExpectSessionIsExtendedInDatabase(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	repos := repositories.Repositories{
+		ApiKey:         repositories.NewApiKeyRepository(conn),
+		Role:           repositories.NewRoleRepository(conn),
+		RoleAssignment: repositories.NewRoleAssignmentRepository(conn),
+	}
+	apiKey := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(time.Minute))
+	config := ApiKeyConfig{
+		Validity:    time.Hour,
+		MaxLifetime: 24 * time.Hour,
+	}
+
+	service := NewAuthService(config, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
+	_, err := service.Authenticate(context.Background(), apiKey.Key)
+	assert.Nil(t, err)
+
+	actual, err := repos.ApiKey.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), actual.ValidUntil, time.Minute)
+}
+
+func TestIT_AuthService_Authenticate_ExpectRolesAndPermissionsResolved(t *testing.T) {
+	conn := newTestConnection(t)
+	user := insertTestUser(t, conn)
+	reader := insertTestRole(t, conn, nil, insertTestPermission(t, conn))
+	editor := insertTestRole(t, conn, &reader.Id, insertTestPermission(t, conn))
+	other := insertTestRole(t, conn, nil, insertTestPermission(t, conn))
+	assignTestRole(t, conn, user, editor)
+	repos := repositories.Repositories{
+		ApiKey:         repositories.NewApiKeyRepository(conn),
+		Role:           repositories.NewRoleRepository(conn),
+		RoleAssignment: repositories.NewRoleAssignmentRepository(conn),
+	}
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	service := NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
+	actual, err := service.Authenticate(context.Background(), apiKey.Key)
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, actual.User)
+	assert.ElementsMatch(t, []string{UserRole, reader.Name, editor.Name}, actual.Roles)
+	assert.ElementsMatch(t, append(reader.Permissions, editor.Permissions...), actual.Permissions)
+	assert.NotContains(t, actual.Roles, other.Name)
+}
+
 func TestIT_AuthService_Authenticate_WhenAuthenticated_ExpectSuccess(t *testing.T) {
 	conn := newTestConnection(t)
 	user := insertTestUser(t, conn)
 	repos := repositories.Repositories{
-		ApiKey: repositories.NewApiKeyRepository(conn),
+		ApiKey:         repositories.NewApiKeyRepository(conn),
+		Role:           repositories.NewRoleRepository(conn),
+		RoleAssignment: repositories.NewRoleAssignmentRepository(conn),
 	}
 	apiKey := insertApiKeyForUser(t, conn, user.Id)
 
-	service := NewAuthService(repos)
+	service := NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
 	_, err := service.Authenticate(context.Background(), apiKey.Key)
 
 	assert.Nil(t, err)
 }
 
-func (m *mockApiKeyRepository) GetForKey(ctx context.Context, apiKey uuid.UUID) (persistence.ApiKey, error) {
+func (m *mockApiKeyRepository) GetForKey(ctx context.Context, keyHashes []string) (persistence.ApiKey, error) {
 	return m.apiKey, m.err
 }
 
+func (m *mockApiKeyRepository) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time, validUntil time.Time) error {
+	m.touched = id
+	m.touchedValidUntil = validUntil
+	return nil
+}
+
+func (m *mockRoleRepository) ListInherited(ctx context.Context, ids []uuid.UUID) ([]persistence.Role, error) {
+	return m.inherited, nil
+}
+
+func (m *mockRoleRepository) ListPermissions(ctx context.Context, roles []uuid.UUID) ([]persistence.RolePermission, error) {
+	return m.permissions, nil
+}
+
+func (m *mockRoleAssignmentRepository) ListRoles(ctx context.Context, user uuid.UUID) ([]persistence.Role, error) {
+	m.calls++
+	return m.roles, nil
+}
+
 func newTestAuthService(apiKeyRepo repositories.ApiKeyRepository) AuthService {
 	repos := repositories.Repositories{
-		ApiKey: apiKeyRepo,
+		ApiKey:         apiKeyRepo,
+		Role:           &mockRoleRepository{},
+		RoleAssignment: &mockRoleAssignmentRepository{},
+	}
+	return NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
+}
+
+func newTestAuthServiceWithConfig(apiKeyRepo repositories.ApiKeyRepository, config ApiKeyConfig) AuthService {
+	repos := repositories.Repositories{
+		ApiKey:         apiKeyRepo,
+		Role:           &mockRoleRepository{},
+		RoleAssignment: &mockRoleAssignmentRepository{},
+	}
+	return NewAuthService(config, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
+}
+
+func newTestAuthServiceWithClients(apiKeyRepo repositories.ApiKeyRepository, admins ...uuid.UUID) AuthService {
+	repos := repositories.Repositories{
+		ApiKey:         apiKeyRepo,
+		Role:           &mockRoleRepository{},
+		RoleAssignment: &mockRoleAssignmentRepository{},
 	}
-	return NewAuthService(repos)
+	config := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+	return NewAuthService(config, AdminConfig{Users: admins}, oauthTestConfig, nil, nil, repos)
+}
+
+func newTestAuthServiceWithRoles(apiKeyRepo repositories.ApiKeyRepository, roleRepo repositories.RoleRepository, assignmentRepo repositories.RoleAssignmentRepository) AuthService {
+	repos := repositories.Repositories{
+		ApiKey:         apiKeyRepo,
+		Role:           roleRepo,
+		RoleAssignment: assignmentRepo,
+	}
+	return NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, nil, nil, repos)
+}
+
+func newTestAuthServiceWithSigner(signer jwt.Signer, apiKeyRepo repositories.ApiKeyRepository, revokedTokenRepo repositories.RevokedTokenRepository) AuthService {
+	repos := repositories.Repositories{
+		ApiKey:         apiKeyRepo,
+		RevokedToken:   revokedTokenRepo,
+		Role:           &mockRoleRepository{},
+		RoleAssignment: &mockRoleAssignmentRepository{},
+	}
+	return NewAuthService(ApiKeyConfig{}, AdminConfig{}, OAuthConfig{}, signer, nil, repos)
+}
+
+func newTestToken(t *testing.T, signer jwt.Signer, expiresAt time.Time) apikey.Key {
+	claims := jwt.Claims{
+		Id:        uuid.NewString(),
+		Subject:   uuid.NewString(),
+		Session:   uuid.NewString(),
+		Roles:     []string{UserRole},
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+	token, err := signer.Sign(context.Background(), claims)
+	require.Nil(t, err)
+
+	key, err := apikey.Parse(token)
+	require.Nil(t, err)
+	return key
+}
+
+func newTestLegacyApiKey(t *testing.T) apikey.Key {
+	key, err := apikey.Parse(uuid.NewString())
+	require.Nil(t, err)
+	return key
 }
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strings"
+)
+
+// Some clients send very long user agents: there is no need to keep all of
+// it to recognize a session.
+const maxUserAgentLength = 256
+
+// ClientInfo describes where a login request comes from.
+type ClientInfo struct {
+	Ip        string
+	UserAgent string
+}
+
+func (c ClientInfo) truncatedUserAgent() string {
+	if len(c.UserAgent) <= maxUserAgentLength {
+		return c.UserAgent
+	}
+
+	return strings.ToValidUTF8(c.UserAgent[:maxUserAgentLength], "")
+}
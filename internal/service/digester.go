@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+)
+
+// digester computes the digests persisted in place of the keys and tokens.
+// New digests use the active pepper of the keyring while lookups accept all
+// the peppers it still accepts: rotating the pepper does not log users out.
+type digester struct {
+	keyring keyring.Keyring
+	// legacyPepper is the pepper set in the configuration. It is used when
+	// there's no keyring, and is still accepted otherwise so that the keys
+	// issued before the keyring was set up keep working.
+	legacyPepper string
+}
+
+func (d digester) digest(ctx context.Context, value string) (string, error) {
+	if d.keyring == nil {
+		return apikey.Digest(value, d.legacyPepper), nil
+	}
+
+	pepper, err := d.keyring.Active(ctx, keyring.DigestPepper)
+	if err != nil {
+		return "", err
+	}
+
+	return apikey.Digest(value, string(pepper.Material)), nil
+}
+
+// candidates returns the digests the value may have been persisted as.
+func (d digester) candidates(ctx context.Context, value string) ([]string, error) {
+	out := []string{apikey.Digest(value, d.legacyPepper)}
+	if d.keyring == nil {
+		return out, nil
+	}
+
+	peppers, err := d.keyring.Accepted(ctx, keyring.DigestPepper)
+	if err != nil {
+		return nil, err
+	}
+	for _, pepper := range peppers {
+		out = append(out, apikey.Digest(value, string(pepper.Material)))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockKeyring struct {
+	keyring.Keyring
+
+	active   map[keyring.Purpose]keyring.Key
+	accepted map[keyring.Purpose][]keyring.Key
+	err      error
+}
+
+var pepperTestKeys = &mockKeyring{
+	active: map[keyring.Purpose]keyring.Key{
+		keyring.DigestPepper: {Id: "new-pepper", Material: []byte("new-pepper")},
+	},
+	accepted: map[keyring.Purpose][]keyring.Key{
+		keyring.DigestPepper: {
+			{Id: "new-pepper", Material: []byte("new-pepper")},
+			{Id: "old-pepper", Material: []byte("old-pepper")},
+		},
+	},
+}
+
+func TestUnit_Digester_Digest_WhenNoKeyring_ExpectLegacyPepperIsUsed(t *testing.T) {
+	d := digester{legacyPepper: "my-pepper"}
+
+	actual, err := d.digest(context.Background(), "my-key")
+
+	assert.Nil(t, err)
+	assert.Equal(t, apikey.Digest("my-key", "my-pepper"), actual)
+}
+
+func TestUnit_Digester_Digest_ExpectActivePepperIsUsed(t *testing.T) {
+	d := digester{keyring: pepperTestKeys, legacyPepper: "my-pepper"}
+
+	actual, err := d.digest(context.Background(), "my-key")
+
+	assert.Nil(t, err)
+	assert.Equal(t, apikey.Digest("my-key", "new-pepper"), actual)
+}
+
+func TestUnit_Digester_Digest_WhenKeyringFails_ExpectError(t *testing.T) {
+	d := digester{keyring: &mockKeyring{err: errors.NewCode(keyring.NoActiveKey)}}
+
+	_, err := d.digest(context.Background(), "my-key")
+
+	assert.True(t, errors.IsErrorWithCode(err, keyring.NoActiveKey), "Actual err: %v", err)
+}
+
+func TestUnit_Digester_Candidates_WhenNoKeyring_ExpectOnlyLegacyPepper(t *testing.T) {
+	d := digester{legacyPepper: "my-pepper"}
+
+	actual, err := d.candidates(context.Background(), "my-key")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{apikey.Digest("my-key", "my-pepper")}, actual)
+}
+
+func TestUnit_Digester_Candidates_ExpectAllAcceptedPeppers(t *testing.T) {
+	d := digester{keyring: pepperTestKeys, legacyPepper: "my-pepper"}
+
+	actual, err := d.candidates(context.Background(), "my-key")
+
+	assert.Nil(t, err)
+	expected := []string{
+		apikey.Digest("my-key", "my-pepper"),
+		apikey.Digest("my-key", "new-pepper"),
+		apikey.Digest("my-key", "old-pepper"),
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func (m *mockKeyring) Active(ctx context.Context, purpose keyring.Purpose) (keyring.Key, error) {
+	return m.active[purpose], m.err
+}
+
+func (m *mockKeyring) Accepted(ctx context.Context, purpose keyring.Purpose) ([]keyring.Key, error) {
+	return m.accepted[purpose], m.err
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type EmailLoginConfig struct {
+	// Enabled lets users log in with a link or a code sent by email instead
+	// of their password.
+	Enabled bool
+	// TokenValidity is how long users have to follow the link or to type
+	// the code in before having to request a new one.
+	TokenValidity time.Duration
+	// MaxAttempts is the number of wrong codes after which the code can't
+	// be used anymore. The link is discarded with it. The count carries over
+	// to the codes requested while the previous one is still valid.
+	MaxAttempts int
+	// MaxRequests is the number of messages which can be requested for
+	// the same address before the requests are rejected. They are accepted
+	// again once none was made for RequestWindow.
+	MaxRequests   int
+	RequestWindow time.Duration
+	// Url is the page of the frontend logging the user in: the token is
+	// appended to it as the token query parameter. Only the token is sent
+	// when it is empty.
+	Url string
+}
+
+func (c EmailLoginConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.TokenValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidEmailLoginConfiguration, "token validity must be positive")
+	}
+	if c.MaxAttempts <= 0 {
+		return errors.NewCodeWithDetails(InvalidEmailLoginConfiguration, "max attempts must be positive")
+	}
+	if c.MaxRequests <= 0 {
+		return errors.NewCodeWithDetails(InvalidEmailLoginConfiguration, "max requests must be positive")
+	}
+	if c.RequestWindow <= 0 {
+		return errors.NewCodeWithDetails(InvalidEmailLoginConfiguration, "request window must be positive")
+	}
+
+	return validateLinkUrl(c.Url, InvalidEmailLoginConfiguration)
+}
+
+// link returns what users should follow to log in.
+func (c EmailLoginConfig) link(token string) string {
+	return linkWithToken(c.Url, token)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_EmailLoginConfig_Validate(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:       true,
+		TokenValidity: 15 * time.Minute,
+		MaxAttempts:   5,
+		MaxRequests:   5,
+		RequestWindow: 1 * time.Hour,
+		Url:           "https://example.com/login",
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenDisabled_ExpectNoError(t *testing.T) {
+	config := EmailLoginConfig{}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenTokenValidityIsNotPositive_ExpectFailure(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:     true,
+		MaxAttempts: 5,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmailLoginConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenMaxAttemptsIsNotPositive_ExpectFailure(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:       true,
+		TokenValidity: 15 * time.Minute,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmailLoginConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenMaxRequestsIsNotPositive_ExpectFailure(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:       true,
+		TokenValidity: 15 * time.Minute,
+		MaxAttempts:   5,
+		RequestWindow: 1 * time.Hour,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmailLoginConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenRequestWindowIsNotPositive_ExpectFailure(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:       true,
+		TokenValidity: 15 * time.Minute,
+		MaxAttempts:   5,
+		MaxRequests:   5,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmailLoginConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_EmailLoginConfig_Validate_WhenUrlIsInvalid_ExpectFailure(t *testing.T) {
+	config := EmailLoginConfig{
+		Enabled:       true,
+		TokenValidity: 15 * time.Minute,
+		MaxAttempts:   5,
+		MaxRequests:   5,
+		RequestWindow: 1 * time.Hour,
+		Url:           "/login",
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmailLoginConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_EmailLoginConfig_Link(t *testing.T) {
+	config := EmailLoginConfig{
+		Url: "https://example.com/login",
+	}
+
+	actual := config.link("usl_live_my-token")
+
+	assert.Equal(t, "https://example.com/login?token=usl_live_my-token", actual)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+const emailLoginSubject = "Log in to your account"
+
+// emailLoginCodeDigits is the length of the codes: they are short enough to
+// be typed in, the attempt limit keeps them from being guessed.
+const emailLoginCodeDigits = 6
+
+type EmailLoginService interface {
+	// Request sends a link and a code to log in to the user owning the
+	// email. Nothing tells the caller whether such a user exists: the
+	// message is sent in the background and the requests are limited per
+	// address either way.
+	Request(ctx context.Context, rawEmail string) error
+	// LoginWithToken opens a session for the user the link was sent to, or
+	// returns a challenge to answer with a second factor when the user
+	// enabled one.
+	LoginWithToken(ctx context.Context, token apikey.EmailLoginToken, client ClientInfo) (communication.LoginDtoResponse, error)
+	// LoginWithCode does the same as LoginWithToken with the code sent to
+	// the email.
+	LoginWithCode(ctx context.Context, rawEmail string, code string, client ClientInfo) (communication.LoginDtoResponse, error)
+}
+
+type emailLoginServiceImpl struct {
+	config   EmailLoginConfig
+	repo     repositories.EmailLoginRepository
+	users    *userServiceImpl
+	throttle requestThrottle
+}
+
+func NewEmailLoginService(config EmailLoginConfig, repos repositories.Repositories, users *userServiceImpl) EmailLoginService {
+	return &emailLoginServiceImpl{
+		config: config,
+		repo:   repos.EmailLogin,
+		users:  users,
+		throttle: requestThrottle{
+			repo:        repos.LoginThrottle,
+			kind:        persistence.EmailLoginRequestThrottle,
+			maxRequests: config.MaxRequests,
+			window:      config.RequestWindow,
+		},
+	}
+}
+
+func (s *emailLoginServiceImpl) Request(ctx context.Context, rawEmail string) error {
+	if s.users.mailer == nil {
+		return nil
+	}
+
+	// Invalid emails can't belong to anyone: they are answered like the
+	// unknown ones.
+	address, err := s.users.normalizer.Normalize(rawEmail)
+	if err != nil {
+		return nil
+	}
+
+	err = s.throttle.record(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.userRepo.GetByEmail(ctx, address)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return nil
+		}
+
+		return err
+	}
+
+	token := apikey.GenerateEmailLoginToken()
+	tokenHash, err := s.users.digester.digest(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	code, err := generateEmailLoginCode()
+	if err != nil {
+		return err
+	}
+	codeHash, err := s.users.digester.digest(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	login := persistence.EmailLogin{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  tokenHash,
+		CodeHash:   codeHash,
+		CreatedAt:  now,
+		ValidUntil: now.Add(s.config.TokenValidity),
+	}
+
+	stored, err := s.storeLogin(ctx, login)
+	if err != nil || !stored {
+		return err
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: emailLoginSubject,
+		Body: fmt.Sprintf(
+			"Your code to log in is %s. You can also log in by following the link below:\n\n%s\n\nThey expire on %s. You can ignore this message if you did not try to log in.\n",
+			code,
+			s.config.link(token.String()),
+			login.ValidUntil.UTC().Format(time.RFC1123),
+		),
+	}
+
+	s.users.sendInBackground(ctx, message)
+
+	return nil
+}
+
+func (s *emailLoginServiceImpl) LoginWithToken(ctx context.Context, token apikey.EmailLoginToken, client ClientInfo) (communication.LoginDtoResponse, error) {
+	tokenHashes, err := s.users.digester.candidates(ctx, token.String())
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	login, err := s.repo.GetForToken(ctx, tokenHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return communication.LoginDtoResponse{}, errors.NewCode(InvalidCredentials)
+		}
+
+		return communication.LoginDtoResponse{}, err
+	}
+
+	user, secondFactor, err := s.users.authenticate(ctx, login.Email, client, func(user *persistence.User) (bool, error) {
+		return s.use(ctx, login, user)
+	})
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.users.openSessionOrChallenge(ctx, user, secondFactor, client)
+}
+
+func (s *emailLoginServiceImpl) LoginWithCode(ctx context.Context, rawEmail string, code string, client ClientInfo) (communication.LoginDtoResponse, error) {
+	user, secondFactor, err := s.users.authenticate(ctx, rawEmail, client, func(user *persistence.User) (bool, error) {
+		login, err := s.repo.GetForUser(ctx, user.Id)
+		if err != nil {
+			if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		codeHashes, err := s.users.digester.candidates(ctx, normalizeEmailLoginCode(code))
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(codeHashes, login.CodeHash) {
+			return false, s.recordFailure(ctx, login.Id)
+		}
+
+		return s.use(ctx, login, user)
+	})
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.users.openSessionOrChallenge(ctx, user, secondFactor, client)
+}
+
+// storeLogin replaces the pending login of the user. The wrong codes
+// provided for it are carried over: asking for a new code does not give more
+// attempts to guess it. Once they are exhausted, no new login is created
+// until the pending one expires.
+func (s *emailLoginServiceImpl) storeLogin(ctx context.Context, login persistence.EmailLogin) (bool, error) {
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Close(ctx)
+
+	err = s.repo.DeleteExpired(ctx, tx, login.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	previous, err := s.repo.LockForUser(ctx, tx, login.ApiUser)
+	if err != nil && !errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return false, err
+	}
+	if previous.Attempts >= s.config.MaxAttempts {
+		return false, nil
+	}
+	login.Attempts = previous.Attempts
+
+	err = s.repo.DeleteForUser(ctx, tx, login.ApiUser)
+	if err != nil {
+		return false, err
+	}
+	_, err = s.repo.Create(ctx, tx, login)
+	return err == nil, err
+}
+
+// use consumes the login if it is still valid, was not discarded after too
+// many wrong codes and was sent to the current address of the user.
+// Receiving it proves that the user owns the address: it is considered
+// verified.
+func (s *emailLoginServiceImpl) use(ctx context.Context, login persistence.EmailLogin, user *persistence.User) (bool, error) {
+	now := time.Now()
+	if login.ApiUser != user.Id || login.Email != user.Email || login.ValidUntil.Before(now) || login.Attempts >= s.config.MaxAttempts {
+		return false, nil
+	}
+
+	err := s.repo.Consume(ctx, login.Id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		err = s.users.userRepo.VerifyEmail(ctx, user.Id, user.Email, now)
+		if err != nil {
+			return false, err
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	return true, nil
+}
+
+// recordFailure counts a wrong code. The login is kept once too many of them
+// were provided so that the count is not reset by asking for a new one: it
+// can't be used anymore and is dropped when it expires.
+func (s *emailLoginServiceImpl) recordFailure(ctx context.Context, id uuid.UUID) error {
+	_, err := s.repo.RecordFailure(ctx, id)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return nil
+	}
+	return err
+}
+
+func generateEmailLoginCode() (string, error) {
+	limit := big.NewInt(1)
+	for range emailLoginCodeDigits {
+		limit.Mul(limit, big.NewInt(10))
+	}
+
+	value, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", emailLoginCodeDigits, value), nil
+}
+
+// normalizeEmailLoginCode ignores the separators users may type in the
+// middle of the code.
+func normalizeEmailLoginCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
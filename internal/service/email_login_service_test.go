@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var emailLoginTestConfig = EmailLoginConfig{
+	Enabled:       true,
+	TokenValidity: 15 * time.Minute,
+	MaxAttempts:   3,
+	MaxRequests:   5,
+	RequestWindow: 1 * time.Hour,
+	Url:           "https://example.com/login",
+}
+
+func TestUnit_GenerateEmailLoginCode(t *testing.T) {
+	code, err := generateEmailLoginCode()
+
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^\d{6}$`), code)
+}
+
+func TestUnit_NormalizeEmailLoginCode(t *testing.T) {
+	assert.Equal(t, "123456", normalizeEmailLoginCode("123 456"))
+	assert.Equal(t, "123456", normalizeEmailLoginCode("123-456"))
+}
+
+func TestIT_EmailLoginService_Request_ExpectLinkAndCodeAreSent(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+
+	err := service.Request(context.Background(), user.Email)
+
+	assert.Nil(t, err)
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, user.Email, mailer.messages[0].To)
+	assert.Equal(t, emailLoginSubject, mailer.messages[0].Subject)
+	assert.Contains(t, mailer.messages[0].Body, "https://example.com/login?token=usl_live_")
+	assert.Regexp(t, emailLoginCodeRegex, mailer.messages[0].Body)
+}
+
+func TestIT_EmailLoginService_Request_WhenEmailIsUnknown_ExpectNoError(t *testing.T) {
+	_, service, mailer, _ := newTestEmailLoginService(t, emailLoginTestConfig)
+
+	err := service.Request(context.Background(), fmt.Sprintf("not-a-user-%s@example.com", uuid.New()))
+
+	assert.Nil(t, err)
+	assert.Empty(t, mailer.messages)
+}
+
+func TestIT_EmailLoginService_Request_WhenTooManyRequests_ExpectTooManyRequests(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	for range emailLoginTestConfig.MaxRequests {
+		err := service.Request(context.Background(), user.Email)
+		require.Nil(t, err)
+	}
+
+	err := service.Request(context.Background(), user.Email)
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyRequests), "Actual err: %v", err)
+	assert.Len(t, mailer.messages, emailLoginTestConfig.MaxRequests)
+}
+
+func TestIT_EmailLoginService_LoginWithToken(t *testing.T) {
+	users, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	token, _ := requestTestEmailLogin(t, service, mailer, user)
+
+	out, err := service.LoginWithToken(context.Background(), token, ClientInfo{})
+
+	assert.Nil(t, err)
+	require.NotNil(t, out.Session)
+	assert.Nil(t, out.Challenge)
+	assert.Equal(t, user.Id, out.Session.User)
+	assertApiKeyExistsByKey(t, conn, out.Session.Key)
+	assertEmailVerified(t, users, user.Id, true)
+}
+
+func TestIT_EmailLoginService_LoginWithToken_WhenTokenIsUnknown_ExpectInvalidCredentials(t *testing.T) {
+	_, service, _, _ := newTestEmailLoginService(t, emailLoginTestConfig)
+
+	_, err := service.LoginWithToken(context.Background(), apikey.GenerateEmailLoginToken(), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithToken_WhenTokenWasAlreadyUsed_ExpectInvalidCredentials(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	token, code := requestTestEmailLogin(t, service, mailer, user)
+	_, err := service.LoginWithToken(context.Background(), token, ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.LoginWithToken(context.Background(), token, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	_, err = service.LoginWithCode(context.Background(), user.Email, code, ClientInfo{})
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithToken_WhenTokenIsExpired_ExpectInvalidCredentials(t *testing.T) {
+	config := emailLoginTestConfig
+	config.TokenValidity = -1 * time.Minute
+	_, service, mailer, conn := newTestEmailLoginService(t, config)
+	user := insertTestUser(t, conn)
+	token, _ := requestTestEmailLogin(t, service, mailer, user)
+
+	_, err := service.LoginWithToken(context.Background(), token, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithToken_WhenEmailChanged_ExpectInvalidCredentials(t *testing.T) {
+	users, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	token, _ := requestTestEmailLogin(t, service, mailer, user)
+	update := communication.UserDtoRequest{
+		Email: "other-" + user.Email,
+	}
+	_, err := users.Update(context.Background(), user.Id, update, communication.SelfView)
+	require.Nil(t, err)
+
+	_, err = service.LoginWithToken(context.Background(), token, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithToken_WhenSecondFactorIsEnabled_ExpectChallenge(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	insertTestConfirmedTotpSecret(t, conn, user)
+	token, _ := requestTestEmailLogin(t, service, mailer, user)
+
+	out, err := service.LoginWithToken(context.Background(), token, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Nil(t, out.Session)
+	require.NotNil(t, out.Challenge)
+	assert.Equal(t, []string{TotpMethod}, out.Challenge.Methods)
+}
+
+func TestIT_EmailLoginService_LoginWithCode(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	token, code := requestTestEmailLogin(t, service, mailer, user)
+
+	out, err := service.LoginWithCode(context.Background(), user.Email, code[:3]+" "+code[3:], ClientInfo{})
+
+	assert.Nil(t, err)
+	require.NotNil(t, out.Session)
+	assert.Equal(t, user.Id, out.Session.User)
+	_, err = service.LoginWithToken(context.Background(), token, ClientInfo{})
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithCode_WhenEmailIsUnknown_ExpectInvalidCredentials(t *testing.T) {
+	_, service, _, _ := newTestEmailLoginService(t, emailLoginTestConfig)
+
+	_, err := service.LoginWithCode(context.Background(), "not-a-user@example.com", "123456", ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithCode_WhenCodeIsWrong_ExpectInvalidCredentials(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	_, code := requestTestEmailLogin(t, service, mailer, user)
+
+	_, err := service.LoginWithCode(context.Background(), user.Email, wrongTestEmailLoginCode(t, code), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	_, err = service.LoginWithCode(context.Background(), user.Email, code, ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_EmailLoginService_LoginWithCode_WhenTooManyWrongCodes_ExpectLoginIsDiscarded(t *testing.T) {
+	throttleConfig := loginThrottleTestConfig
+	throttleConfig.FreeAttempts = 10
+	_, service, mailer, conn := newTestEmailLoginServiceWithThrottle(t, emailLoginTestConfig, throttleConfig)
+	user := insertTestUser(t, conn)
+	token, code := requestTestEmailLogin(t, service, mailer, user)
+	for range emailLoginTestConfig.MaxAttempts {
+		_, err := service.LoginWithCode(context.Background(), user.Email, wrongTestEmailLoginCode(t, code), ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+
+	_, err := service.LoginWithCode(context.Background(), user.Email, code, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	_, err = service.LoginWithToken(context.Background(), token, ClientInfo{})
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_LoginWithCode_WhenAnotherLoginWasRequested_ExpectWrongCodesAreCarriedOver(t *testing.T) {
+	throttleConfig := loginThrottleTestConfig
+	throttleConfig.FreeAttempts = 10
+	_, service, mailer, conn := newTestEmailLoginServiceWithThrottle(t, emailLoginTestConfig, throttleConfig)
+	user := insertTestUser(t, conn)
+	_, code := requestTestEmailLogin(t, service, mailer, user)
+	for range emailLoginTestConfig.MaxAttempts - 1 {
+		_, err := service.LoginWithCode(context.Background(), user.Email, wrongTestEmailLoginCode(t, code), ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+	_, code = requestTestEmailLogin(t, service, mailer, user)
+	_, err := service.LoginWithCode(context.Background(), user.Email, wrongTestEmailLoginCode(t, code), ClientInfo{})
+	require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+
+	_, err = service.LoginWithCode(context.Background(), user.Email, code, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginService_Request_WhenWrongCodesAreExhausted_ExpectNoMessageIsSent(t *testing.T) {
+	throttleConfig := loginThrottleTestConfig
+	throttleConfig.FreeAttempts = 10
+	_, service, mailer, conn := newTestEmailLoginServiceWithThrottle(t, emailLoginTestConfig, throttleConfig)
+	user := insertTestUser(t, conn)
+	_, code := requestTestEmailLogin(t, service, mailer, user)
+	for range emailLoginTestConfig.MaxAttempts {
+		_, err := service.LoginWithCode(context.Background(), user.Email, wrongTestEmailLoginCode(t, code), ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+
+	err := service.Request(context.Background(), user.Email)
+
+	assert.Nil(t, err)
+	assert.Len(t, mailer.messages, 1)
+}
+
+func TestIT_EmailLoginService_LoginWithCode_WhenAnotherLoginWasRequested_ExpectPreviousCodeStopsWorking(t *testing.T) {
+	_, service, mailer, conn := newTestEmailLoginService(t, emailLoginTestConfig)
+	user := insertTestUser(t, conn)
+	_, previous := requestTestEmailLogin(t, service, mailer, user)
+	_, code := requestTestEmailLogin(t, service, mailer, user)
+	if previous == code {
+		t.Skip("The same code was generated twice")
+	}
+
+	_, err := service.LoginWithCode(context.Background(), user.Email, previous, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func newTestEmailLoginService(t *testing.T, config EmailLoginConfig) (UserService, EmailLoginService, *testMailer, db.Connection) {
+	return newTestEmailLoginServiceWithThrottle(t, config, loginThrottleTestConfig)
+}
+
+func newTestEmailLoginServiceWithThrottle(t *testing.T, config EmailLoginConfig, throttleConfig LoginThrottleConfig) (UserService, EmailLoginService, *testMailer, db.Connection) {
+	conn := newTestConnection(t)
+
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailLogin:         repositories.NewEmailLoginRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+	mailer := &testMailer{}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, throttleConfig, VerificationConfig{}, nil, nil, mailer, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := &waitingEmailLoginService{
+		EmailLoginService: NewEmailLoginService(config, repos, users),
+		users:             users,
+	}
+	return users, service, mailer, conn
+}
+
+// waitingEmailLoginService waits for the messages to be sent so that the
+// tests can inspect them.
+type waitingEmailLoginService struct {
+	EmailLoginService
+	users *userServiceImpl
+}
+
+func (s *waitingEmailLoginService) Request(ctx context.Context, rawEmail string) error {
+	err := s.EmailLoginService.Request(ctx, rawEmail)
+	s.users.background.Wait()
+	return err
+}
+
+func requestTestEmailLogin(t *testing.T, service EmailLoginService, mailer *testMailer, user persistence.User) (apikey.EmailLoginToken, string) {
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	return mailer.lastEmailLogin(t, user.Email)
+}
+
+func wrongTestEmailLoginCode(t *testing.T, code string) string {
+	value, err := strconv.Atoi(code)
+	require.Nil(t, err)
+	return fmt.Sprintf("%06d", (value+1)%1000000)
+}
+
+// insertTestConfirmedTotpSecret enables an authenticator app for the user
+// without going through the enrollment.
+func insertTestConfirmedTotpSecret(t *testing.T, conn db.Connection, user persistence.User) {
+	repo := repositories.NewTotpRepository(conn)
+
+	secret := persistence.TotpSecret{
+		ApiUser:   user.Id,
+		Secret:    []byte("my-secret"),
+		CreatedAt: time.Now(),
+	}
+	_, err := repo.Create(context.Background(), secret)
+	require.Nil(t, err)
+
+	err = repo.Confirm(context.Background(), user.Id, 0, time.Now())
+	require.Nil(t, err)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// NormalizeEmails rewrites the stored emails in the form produced by the
+// normalizer, which depends on its configuration: lookups only match this
+// form. Emails which can't be parsed are kept as is, as their users can
+// still log in with them. The users whose normalized email is already
+// used by another one are left untouched and returned: they have to be
+// merged or renamed manually.
+func NormalizeEmails(ctx context.Context, normalizer email.Normalizer, repo repositories.UserRepository) (int, []uuid.UUID, error) {
+	ids, err := repo.List(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var normalized int
+	var conflicts []uuid.UUID
+	for _, id := range ids {
+		user, err := repo.Get(ctx, id)
+		if err != nil {
+			return normalized, conflicts, err
+		}
+
+		address, err := normalizer.Normalize(user.Email)
+		if err != nil || address == user.Email {
+			continue
+		}
+
+		user.Email = address
+		_, err = repo.Update(ctx, user)
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			conflicts = append(conflicts, id)
+			continue
+		}
+		if err != nil {
+			return normalized, conflicts, err
+		}
+
+		normalized++
+	}
+
+	return normalized, conflicts, nil
+}
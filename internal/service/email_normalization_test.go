@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockUserRepository struct {
+	repositories.UserRepository
+
+	users     map[uuid.UUID]persistence.User
+	conflicts map[string]bool
+
+	updated []persistence.User
+}
+
+func TestUnit_NormalizeEmails_ExpectEmailsAreRewritten(t *testing.T) {
+	legacy := persistence.User{Id: uuid.New(), Email: " John.Doe@Bücher.Example "}
+	normalized := persistence.User{Id: uuid.New(), Email: "jane@example.com"}
+	repo := newMockUserRepository(legacy, normalized)
+
+	count, conflicts, err := NormalizeEmails(context.Background(), newTestNormalizer(), repo)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Empty(t, conflicts)
+	assert.Len(t, repo.updated, 1)
+	assert.Equal(t, legacy.Id, repo.updated[0].Id)
+	assert.Equal(t, "John.Doe@xn--bcher-kva.example", repo.updated[0].Email)
+}
+
+func TestUnit_NormalizeEmails_WhenLocalPartIsLowercased_ExpectItIsRewritten(t *testing.T) {
+	user := persistence.User{Id: uuid.New(), Email: "John.Doe@example.com"}
+	repo := newMockUserRepository(user)
+	normalizer := email.NewNormalizer(email.Config{LowercaseLocalPart: true})
+
+	count, _, err := NormalizeEmails(context.Background(), normalizer, repo)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "john.doe@example.com", repo.updated[0].Email)
+}
+
+func TestUnit_NormalizeEmails_WhenEmailIsNotValid_ExpectItIsKept(t *testing.T) {
+	user := persistence.User{Id: uuid.New(), Email: "my-legacy-user"}
+	repo := newMockUserRepository(user)
+
+	count, _, err := NormalizeEmails(context.Background(), newTestNormalizer(), repo)
+
+	assert.Nil(t, err)
+	assert.Zero(t, count)
+	assert.Empty(t, repo.updated)
+}
+
+func TestUnit_NormalizeEmails_WhenNormalizedEmailIsUsed_ExpectConflictIsReported(t *testing.T) {
+	user := persistence.User{Id: uuid.New(), Email: "john@EXAMPLE.com"}
+	repo := newMockUserRepository(user)
+	repo.conflicts["john@example.com"] = true
+
+	count, conflicts, err := NormalizeEmails(context.Background(), newTestNormalizer(), repo)
+
+	assert.Nil(t, err)
+	assert.Zero(t, count)
+	assert.Equal(t, []uuid.UUID{user.Id}, conflicts)
+}
+
+func newMockUserRepository(users ...persistence.User) *mockUserRepository {
+	out := &mockUserRepository{
+		users:     make(map[uuid.UUID]persistence.User),
+		conflicts: make(map[string]bool),
+	}
+	for _, user := range users {
+		out.users[user.Id] = user
+	}
+	return out
+}
+
+func (m *mockUserRepository) List(ctx context.Context) ([]uuid.UUID, error) {
+	var out []uuid.UUID
+	for id := range m.users {
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+func (m *mockUserRepository) Get(ctx context.Context, id uuid.UUID) (persistence.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockUserRepository) Update(ctx context.Context, user persistence.User) (persistence.User, error) {
+	if m.conflicts[user.Email] {
+		return user, errors.NewCode(pgx.UniqueConstraintViolation)
+	}
+
+	m.updated = append(m.updated, user)
+	return user, nil
+}
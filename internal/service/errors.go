@@ -5,10 +5,74 @@ import (
 )
 
 const (
-	UserNotAuthenticated  errors.ErrorCode = 1000
-	AuthenticationExpired errors.ErrorCode = 1001
-	InvalidCredentials    errors.ErrorCode = 1002
+	UserNotAuthenticated   errors.ErrorCode = 1000
+	AuthenticationExpired  errors.ErrorCode = 1001
+	InvalidCredentials     errors.ErrorCode = 1002
+	NotAnAdministrator     errors.ErrorCode = 1003
+	LegacyApiKeyRejected   errors.ErrorCode = 1004
+	PermissionDenied       errors.ErrorCode = 1005
+	PasswordChangeRequired errors.ErrorCode = 1006
 
-	InvalidEmail    errors.ErrorCode = 1050
-	InvalidPassword errors.ErrorCode = 1051
+	TooManyLoginAttempts errors.ErrorCode = 1010
+	AccountLocked        errors.ErrorCode = 1011
+	TooManyRequests      errors.ErrorCode = 1012
+
+	SessionNotFound           errors.ErrorCode = 1020
+	TooManySessions           errors.ErrorCode = 1021
+	UnsupportedEvictionPolicy errors.ErrorCode = 1022
+
+	InvalidRefreshToken errors.ErrorCode = 1030
+	RefreshTokenReused  errors.ErrorCode = 1031
+
+	ClientNotAuthenticated errors.ErrorCode = 1040
+	InvalidOAuthClient     errors.ErrorCode = 1041
+
+	InvalidRedirectUri          errors.ErrorCode = 1060
+	InvalidAuthorizationRequest errors.ErrorCode = 1061
+	UnsupportedResponseType     errors.ErrorCode = 1062
+	InvalidScope                errors.ErrorCode = 1063
+	InvalidGrant                errors.ErrorCode = 1064
+	UnsupportedGrantType        errors.ErrorCode = 1065
+	InsufficientScope           errors.ErrorCode = 1066
+	InvalidOidcConfiguration    errors.ErrorCode = 1067
+
+	UnknownIdentityProvider  errors.ErrorCode = 1070
+	InvalidFederatedLogin    errors.ErrorCode = 1071
+	UnverifiedFederatedEmail errors.ErrorCode = 1072
+	FederatedSignUpDisabled  errors.ErrorCode = 1073
+
+	UnknownSamlTenant     errors.ErrorCode = 1080
+	InvalidSamlLogin      errors.ErrorCode = 1081
+	SamlAssertionReplayed errors.ErrorCode = 1082
+
+	SecondFactorRequired    errors.ErrorCode = 1090
+	InvalidMfaChallenge     errors.ErrorCode = 1091
+	InvalidMfaCode          errors.ErrorCode = 1092
+	TotpAlreadyEnabled      errors.ErrorCode = 1093
+	TotpNotEnrolled         errors.ErrorCode = 1094
+	SecondFactorUnavailable errors.ErrorCode = 1095
+
+	InvalidWebAuthnCeremony             errors.ErrorCode = 1100
+	WebAuthnCredentialAlreadyRegistered errors.ErrorCode = 1101
+	UnknownWebAuthnCredential           errors.ErrorCode = 1102
+	InvalidWebAuthnCredential           errors.ErrorCode = 1103
+
+	InvalidVerificationToken         errors.ErrorCode = 1110
+	EmailNotVerified                 errors.ErrorCode = 1111
+	EmailAlreadyVerified             errors.ErrorCode = 1112
+	InvalidVerificationConfiguration errors.ErrorCode = 1113
+
+	InvalidPasswordResetToken         errors.ErrorCode = 1120
+	InvalidPasswordResetConfiguration errors.ErrorCode = 1121
+
+	InvalidEmailLoginConfiguration errors.ErrorCode = 1130
+
+	InvalidRoleName       errors.ErrorCode = 1140
+	InvalidRoleParent     errors.ErrorCode = 1141
+	InvalidPermissionName errors.ErrorCode = 1142
+	UnknownPermission     errors.ErrorCode = 1143
+
+	InvalidEmail         errors.ErrorCode = 1050
+	InvalidPassword      errors.ErrorCode = 1051
+	PasswordNotUpdatable errors.ErrorCode = 1052
 )
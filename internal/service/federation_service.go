@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+type FederationService interface {
+	// Start returns the URL of the provider where the user should sign in
+	// and the state to keep in their browser until they come back.
+	Start(ctx context.Context, provider string) (string, string, error)
+	// Complete opens a session for the user who signed in at the provider,
+	// or returns a challenge when they enabled a second factor. The browser
+	// state is the one returned by Start.
+	Complete(ctx context.Context, provider string, callback communication.FederatedCallbackDtoRequest, browserState string, client ClientInfo) (communication.LoginDtoResponse, error)
+}
+
+type federationServiceImpl struct {
+	users      *userServiceImpl
+	loginRepo  repositories.FederatedLoginRepository
+	identities identityResolver
+
+	config    federation.Config
+	providers federation.Providers
+}
+
+func NewFederationService(config federation.Config, providers federation.Providers, repos repositories.Repositories, users *userServiceImpl) FederationService {
+	return &federationServiceImpl{
+		users:      users,
+		loginRepo:  repos.FederatedLogin,
+		identities: newIdentityResolver(users, repos.Identity),
+
+		config:    config,
+		providers: providers,
+	}
+}
+
+func (s *federationServiceImpl) Start(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", errors.NewCodeWithDetails(UnknownIdentityProvider, providerName)
+	}
+
+	request := federation.NewLoginRequest()
+	redirect, err := provider.AuthorizationUrl(ctx, request)
+	if err != nil {
+		return "", "", err
+	}
+
+	stateHash, err := s.users.digester.digest(ctx, request.State)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Close(ctx)
+
+	now := time.Now()
+	err = s.loginRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	login := persistence.FederatedLogin{
+		Id:           uuid.New(),
+		StateHash:    stateHash,
+		Provider:     providerName,
+		Nonce:        request.Nonce,
+		CodeVerifier: request.CodeVerifier,
+		CreatedAt:    now,
+		ValidUntil:   now.Add(s.config.LoginValidity),
+	}
+
+	_, err = s.loginRepo.Create(ctx, tx, login)
+	if err != nil {
+		return "", "", err
+	}
+
+	return redirect, request.State, nil
+}
+
+func (s *federationServiceImpl) Complete(ctx context.Context, providerName string, callback communication.FederatedCallbackDtoRequest, browserState string, client ClientInfo) (communication.LoginDtoResponse, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return communication.LoginDtoResponse{}, errors.NewCodeWithDetails(UnknownIdentityProvider, providerName)
+	}
+
+	if callback.Error != "" {
+		return communication.LoginDtoResponse{}, errors.NewCodeWithDetails(InvalidFederatedLogin, callback.Error)
+	}
+	// The state in the browser proves that the user who comes back is the
+	// one who started the login: otherwise an attacker could sign victims
+	// in to the attacker's account.
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-10.12
+	if callback.State == "" || subtle.ConstantTimeCompare([]byte(callback.State), []byte(browserState)) != 1 {
+		return communication.LoginDtoResponse{}, errors.NewCodeWithDetails(InvalidFederatedLogin, "state mismatch")
+	}
+
+	login, err := s.consumeLogin(ctx, callback.State)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+	if login.Provider != providerName || login.ValidUntil.Before(time.Now()) {
+		return communication.LoginDtoResponse{}, errors.NewCode(InvalidFederatedLogin)
+	}
+
+	request := federation.LoginRequest{
+		State:        callback.State,
+		Nonce:        login.Nonce,
+		CodeVerifier: login.CodeVerifier,
+	}
+	identity, err := provider.Identify(ctx, callback.Code, request)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	providerConfig, _ := s.config.Provider(providerName)
+	user, err := s.identities.resolve(ctx, providerName, identity, providerConfig.CreateUsers)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.users.completeDelegatedLogin(ctx, user, client)
+}
+
+func (s *federationServiceImpl) consumeLogin(ctx context.Context, state string) (persistence.FederatedLogin, error) {
+	stateHashes, err := s.users.digester.candidates(ctx, state)
+	if err != nil {
+		return persistence.FederatedLogin{}, err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return persistence.FederatedLogin{}, err
+	}
+	defer tx.Close(ctx)
+
+	out, err := s.loginRepo.Consume(ctx, tx, stateHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.FederatedLogin{}, errors.NewCode(InvalidFederatedLogin)
+		}
+
+		return persistence.FederatedLogin{}, err
+	}
+
+	return out, nil
+}
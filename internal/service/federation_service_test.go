@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var federationTestConfig = federation.Config{
+	LoginValidity: time.Minute,
+	Providers: []federation.ProviderConfig{
+		{Name: "my-provider", CreateUsers: true},
+		{Name: "my-closed-provider"},
+	},
+}
+
+func TestUnit_FederationService_Start_WhenProviderIsUnknown_ExpectFailure(t *testing.T) {
+	service := newTestFederationService(&mockIdentityProvider{}, repositories.Repositories{})
+
+	_, _, err := service.Start(context.Background(), "not-a-provider")
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownIdentityProvider), "Actual err: %v", err)
+}
+
+func TestUnit_FederationService_Complete_WhenProviderIsUnknown_ExpectFailure(t *testing.T) {
+	service := newTestFederationService(&mockIdentityProvider{}, repositories.Repositories{})
+	callback := communication.FederatedCallbackDtoRequest{Code: "my-code", State: "my-state"}
+
+	_, err := service.Complete(context.Background(), "not-a-provider", callback, "my-state", ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownIdentityProvider), "Actual err: %v", err)
+}
+
+func TestUnit_FederationService_Complete_WhenCallbackIsInvalid_ExpectFailure(t *testing.T) {
+	type testCase struct {
+		callback     communication.FederatedCallbackDtoRequest
+		browserState string
+	}
+
+	testCases := map[string]testCase{
+		"providerError": {
+			callback:     communication.FederatedCallbackDtoRequest{Error: "access_denied", State: "my-state"},
+			browserState: "my-state",
+		},
+		"noState": {
+			callback:     communication.FederatedCallbackDtoRequest{Code: "my-code"},
+			browserState: "",
+		},
+		"stateMismatch": {
+			callback:     communication.FederatedCallbackDtoRequest{Code: "my-code", State: "my-state"},
+			browserState: "another-state",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			provider := &mockIdentityProvider{}
+			service := newTestFederationService(provider, repositories.Repositories{})
+
+			_, err := service.Complete(context.Background(), "my-provider", tc.callback, tc.browserState, ClientInfo{})
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidFederatedLogin), "Actual err: %v", err)
+			assert.Zero(t, provider.identifyCalls)
+		})
+	}
+}
+
+func TestIT_FederationService_WhenIdentityIsNew_ExpectUserIsCreated(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+
+	out := signInWithTestProvider(t, service, "my-provider")
+
+	assertUserExists(t, conn, out.User)
+	assertIdentityLinkedTo(t, conn, "my-provider", provider.identity.Subject, out.User)
+	assert.NotEmpty(t, provider.request.Nonce)
+	assert.NotEmpty(t, provider.request.CodeVerifier)
+}
+
+func TestIT_FederationService_WhenIdentityIsKnown_ExpectSameUser(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, _ := newTestFederationServiceWithDatabase(t, provider)
+	first := signInWithTestProvider(t, service, "my-provider")
+
+	// The email at the provider can change: the subject identifies the user.
+	provider.identity.Email = "another-" + provider.identity.Email
+	second := signInWithTestProvider(t, service, "my-provider")
+
+	assert.Equal(t, first.User, second.User)
+	assert.NotEqual(t, first.Key, second.Key)
+}
+
+func TestIT_FederationService_WhenEmailMatchesUser_ExpectIdentityIsLinked(t *testing.T) {
+	provider := &mockIdentityProvider{}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+	user := insertTestUser(t, conn)
+	provider.identity = federation.Identity{
+		Subject:       uuid.NewString(),
+		Email:         user.Email,
+		EmailVerified: true,
+	}
+
+	out := signInWithTestProvider(t, service, "my-closed-provider")
+
+	assert.Equal(t, user.Id, out.User)
+	assertIdentityLinkedTo(t, conn, "my-closed-provider", provider.identity.Subject, user.Id)
+}
+
+func TestIT_FederationService_WhenEmailIsNotVerified_ExpectFailure(t *testing.T) {
+	provider := &mockIdentityProvider{}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+	user := insertTestUser(t, conn)
+	provider.identity = federation.Identity{
+		Subject: uuid.NewString(),
+		Email:   user.Email,
+	}
+
+	_, err := completeTestFederatedLogin(t, service, "my-provider")
+
+	assert.True(t, errors.IsErrorWithCode(err, UnverifiedFederatedEmail), "Actual err: %v", err)
+}
+
+func TestIT_FederationService_WhenSignUpIsDisabled_ExpectFailure(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+
+	_, err := completeTestFederatedLogin(t, service, "my-closed-provider")
+
+	assert.True(t, errors.IsErrorWithCode(err, FederatedSignUpDisabled), "Actual err: %v", err)
+	_, err = repositories.NewUserRepository(conn).GetByEmail(context.Background(), provider.identity.Email)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_FederationService_WhenUserEnabledTotp_ExpectChallenge(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+	first := signInWithTestProvider(t, service, "my-provider")
+	confirmTestTotp(t, conn, first.User)
+
+	out, err := completeTestFederatedLogin(t, service, "my-provider")
+
+	assert.Nil(t, err)
+	assert.Nil(t, out.Session)
+	require.NotNil(t, out.Challenge)
+	assert.Equal(t, []string{TotpMethod}, out.Challenge.Methods)
+}
+
+func TestIT_FederationService_WhenAccountIsLocked_ExpectFailure(t *testing.T) {
+	provider := &mockIdentityProvider{}
+	service, conn := newTestFederationServiceWithDatabase(t, provider)
+	user := insertTestUser(t, conn)
+	lockTestAccount(t, conn, user.Email)
+	provider.identity = federation.Identity{
+		Subject:       uuid.NewString(),
+		Email:         user.Email,
+		EmailVerified: true,
+	}
+
+	_, err := completeTestFederatedLogin(t, service, "my-closed-provider")
+
+	assert.True(t, errors.IsErrorWithCode(err, AccountLocked), "Actual err: %v", err)
+}
+
+func TestIT_FederationService_WhenStateIsReused_ExpectFailure(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, _ := newTestFederationServiceWithDatabase(t, provider)
+	_, state, err := service.Start(context.Background(), "my-provider")
+	require.Nil(t, err)
+	callback := communication.FederatedCallbackDtoRequest{Code: "my-code", State: state}
+	_, err = service.Complete(context.Background(), "my-provider", callback, state, ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.Complete(context.Background(), "my-provider", callback, state, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFederatedLogin), "Actual err: %v", err)
+}
+
+func TestIT_FederationService_WhenLoginWasStartedWithAnotherProvider_ExpectFailure(t *testing.T) {
+	provider := &mockIdentityProvider{
+		identity: newTestIdentity(),
+	}
+	service, _ := newTestFederationServiceWithDatabase(t, provider)
+	_, state, err := service.Start(context.Background(), "my-closed-provider")
+	require.Nil(t, err)
+	callback := communication.FederatedCallbackDtoRequest{Code: "my-code", State: state}
+
+	_, err = service.Complete(context.Background(), "my-provider", callback, state, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidFederatedLogin), "Actual err: %v", err)
+	assert.Zero(t, provider.identifyCalls)
+}
+
+type mockIdentityProvider struct {
+	identity federation.Identity
+	err      error
+
+	identifyCalls int
+	request       federation.LoginRequest
+}
+
+func (m *mockIdentityProvider) Name() string {
+	return "my-provider"
+}
+
+func (m *mockIdentityProvider) AuthorizationUrl(ctx context.Context, request federation.LoginRequest) (string, error) {
+	return "https://provider.example.com/authorize?state=" + request.State, nil
+}
+
+func (m *mockIdentityProvider) Identify(ctx context.Context, code string, request federation.LoginRequest) (federation.Identity, error) {
+	m.identifyCalls++
+	m.request = request
+	return m.identity, m.err
+}
+
+func newTestIdentity() federation.Identity {
+	return federation.Identity{
+		Subject:       uuid.NewString(),
+		Email:         "player-" + uuid.NewString() + "@example.com",
+		EmailVerified: true,
+	}
+}
+
+func newTestFederationService(provider federation.Provider, repos repositories.Repositories) FederationService {
+	providers := federation.Providers{
+		"my-provider":        provider,
+		"my-closed-provider": provider,
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), nil, nil, nil, repos)
+	return NewFederationService(federationTestConfig, providers, repos, users)
+}
+
+func newTestFederationServiceWithDatabase(t *testing.T, provider federation.Provider) (FederationService, db.Connection) {
+	conn := newTestConnection(t)
+
+	providers := federation.Providers{
+		"my-provider":        provider,
+		"my-closed-provider": provider,
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		FederatedLogin:     repositories.NewFederatedLoginRepository(conn),
+		Identity:           repositories.NewIdentityRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewFederationService(federationTestConfig, providers, repos, users)
+	return service, conn
+}
+
+func completeTestFederatedLogin(t *testing.T, service FederationService, provider string) (communication.LoginDtoResponse, error) {
+	_, state, err := service.Start(context.Background(), provider)
+	require.Nil(t, err)
+
+	callback := communication.FederatedCallbackDtoRequest{
+		Code:  "my-code",
+		State: state,
+	}
+	return service.Complete(context.Background(), provider, callback, state, ClientInfo{})
+}
+
+func signInWithTestProvider(t *testing.T, service FederationService, provider string) communication.ApiKeyDtoResponse {
+	out, err := completeTestFederatedLogin(t, service, provider)
+	require.Nil(t, err)
+	require.NotNil(t, out.Session)
+	return *out.Session
+}
+
+func assertIdentityLinkedTo(t *testing.T, conn db.Connection, provider string, subject string, user uuid.UUID) {
+	identity, err := repositories.NewIdentityRepository(conn).GetBySubject(context.Background(), provider, subject)
+	require.Nil(t, err)
+	require.Equal(t, user, identity.ApiUser)
+}
@@ -3,11 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"testing"
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/postgresql"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	"github.com/google/uuid"
@@ -22,13 +30,82 @@ func newTestConnection(t *testing.T) db.Connection {
 	return conn
 }
 
+var passwordTestConfig = password.Config{
+	Algorithm: password.Argon2id,
+	Argon2: password.Argon2Config{
+		Memory:      1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	},
+	Policy: password.PolicyConfig{
+		MinLength:             8,
+		MaxLength:             64,
+		BanEmailLocalPart:     true,
+		RejectCommonPasswords: true,
+		HistorySize:           3,
+	},
+}
+
+var loginThrottleTestConfig = LoginThrottleConfig{
+	FreeAttempts:     2,
+	BaseDelay:        1 * time.Minute,
+	MaxDelay:         1 * time.Hour,
+	LockoutThreshold: 5,
+	LockoutDuration:  1 * time.Hour,
+	ResetAfter:       24 * time.Hour,
+}
+
+func newTestNormalizer() email.Normalizer {
+	return email.NewNormalizer(email.Config{})
+}
+
+func newTestHasher(t *testing.T) password.Hasher {
+	hasher, err := password.NewHasher(passwordTestConfig)
+	require.Nil(t, err)
+	return hasher
+}
+
+func newTestPolicy(t *testing.T) password.Policy {
+	policy, err := password.NewPolicy(passwordTestConfig.Policy)
+	require.Nil(t, err)
+	return policy
+}
+
+func newTestSigner(t *testing.T) jwt.Signer {
+	return newTestSignerWithIssuer(t, "user-service")
+}
+
+func newTestSignerWithIssuer(t *testing.T, issuer string) jwt.Signer {
+	key, err := keyring.GenerateKey(keyring.TokenSigning, keyring.EdDSA, time.Now())
+	require.Nil(t, err)
+
+	config := jwt.Config{
+		Enabled:  true,
+		Issuer:   issuer,
+		Audience: []string{"my-api"},
+	}
+
+	keys := &mockKeyring{
+		active: map[keyring.Purpose]keyring.Key{
+			keyring.TokenSigning: key,
+		},
+		accepted: map[keyring.Purpose][]keyring.Key{
+			keyring.TokenSigning: {key},
+		},
+	}
+
+	return jwt.NewSigner(config, keys)
+}
+
 func insertTestUser(t *testing.T, conn db.Connection) persistence.User {
 	repo := repositories.NewUserRepository(conn)
 
 	id := uuid.New()
 	user := persistence.User{
 		Id:        id,
-		Email:     fmt.Sprintf("my-user-%s", id),
+		Email:     fmt.Sprintf("my-user-%s@example.com", id),
 		Password:  "my-password",
 		CreatedAt: time.Now(),
 	}
@@ -40,26 +117,58 @@ func insertTestUser(t *testing.T, conn db.Connection) persistence.User {
 	return out
 }
 
-func insertApiKeyForUser(t *testing.T, conn db.Connection, userId uuid.UUID) persistence.ApiKey {
+// testApiKey keeps track of the key in clear as only its digest is stored.
+type testApiKey struct {
+	persistence.ApiKey
+	Key apikey.Key
+}
+
+// confirmTestTotp marks the user as having confirmed an authenticator app.
+// The secret can't be used to generate codes.
+func confirmTestTotp(t *testing.T, conn db.Connection, user uuid.UUID) {
+	sqlQuery := `
+INSERT INTO totp_secret (api_user, secret, last_counter, created_at, confirmed_at)
+	VALUES ($1, $2, 0, $3, $3)`
+	_, err := conn.Exec(context.Background(), sqlQuery, user, []byte("not-a-secret"), time.Now())
+	require.Nil(t, err)
+}
+
+func lockTestAccount(t *testing.T, conn db.Connection, email string) {
+	sqlQuery := `
+INSERT INTO login_throttle (kind, key, failures, last_failure_at, blocked_until, locked)
+	VALUES ('email', lower($1), 10, $2, $3, true)`
+	_, err := conn.Exec(context.Background(), sqlQuery, email, time.Now(), time.Now().Add(time.Hour))
+	require.Nil(t, err)
+}
+
+func insertApiKeyForUser(t *testing.T, conn db.Connection, userId uuid.UUID) testApiKey {
 	return insertApiKeyForUserWithValidity(t, conn, userId, time.Now().Add(3*time.Hour))
 }
 
-func insertApiKeyForUserWithValidity(t *testing.T, conn db.Connection, userId uuid.UUID, validity time.Time) persistence.ApiKey {
+func insertApiKeyForUserWithValidity(t *testing.T, conn db.Connection, userId uuid.UUID, validity time.Time) testApiKey {
 	repo := repositories.NewApiKeyRepository(conn)
 
+	key := apikey.Generate()
 	apiKey := persistence.ApiKey{
 		Id:         uuid.New(),
-		Key:        uuid.New(),
+		KeyHash:    apikey.Digest(key.String(), ""),
 		ApiUser:    userId,
+		CreatedAt:  time.Now(),
 		ValidUntil: validity,
 	}
 
-	out, err := repo.Create(context.Background(), apiKey)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	out, err := repo.Create(context.Background(), tx, apiKey)
+	tx.Close(context.Background())
 	require.Nil(t, err)
 
 	assertApiKeyExists(t, conn, out.Id)
 
-	return out
+	return testApiKey{
+		ApiKey: out,
+		Key:    key,
+	}
 }
 
 func assertApiKeyExists(t *testing.T, conn db.Connection, id uuid.UUID) {
@@ -68,10 +177,16 @@ func assertApiKeyExists(t *testing.T, conn db.Connection, id uuid.UUID) {
 	require.Equal(t, id, value)
 }
 
-func assertApiKeyExistsByKey(t *testing.T, conn db.Connection, key uuid.UUID) {
-	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT key FROM api_key WHERE key = $1", key)
+func assertApiKeyExistsByKey(t *testing.T, conn db.Connection, key string) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM api_key WHERE key_hash = $1", apikey.Digest(key, ""))
+	require.Nil(t, err)
+	require.Equal(t, 1, value)
+}
+
+func assertApiKeyDoesNotExistByKey(t *testing.T, conn db.Connection, key string) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM api_key WHERE key_hash = $1", apikey.Digest(key, ""))
 	require.Nil(t, err)
-	require.Equal(t, key, value)
+	require.Zero(t, value)
 }
 
 func assertApiKeyDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
@@ -91,3 +206,97 @@ func assertUserDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
 	require.Nil(t, err)
 	require.Zero(t, value)
 }
+
+func assertPasswordForUser(t *testing.T, conn db.Connection, id uuid.UUID, expectedPassword string) {
+	value, err := db.QueryOne[string](context.Background(), conn, "SELECT password FROM api_user WHERE id = $1", id)
+	require.Nil(t, err)
+
+	hasher := newTestHasher(t)
+	match, err := hasher.Verify(expectedPassword, value)
+	require.Nil(t, err)
+	require.True(t, match)
+	require.False(t, hasher.NeedsRehash(value))
+}
+
+// openTestSession logs the user in, expecting no second factor to be
+// required.
+func openTestSession(t *testing.T, service UserService, user communication.UserDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	out, err := service.Login(context.Background(), user, client)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	require.Nil(t, out.Challenge)
+	require.NotNil(t, out.Session)
+	return *out.Session, nil
+}
+
+// testMailer keeps the messages instead of sending them.
+type testMailer struct {
+	messages []mail.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, message mail.Message) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+var verificationTokenRegex = regexp.MustCompile(`usv_live_\w+`)
+
+// lastVerificationToken returns the token of the last verification link
+// sent to the address.
+func (m *testMailer) lastVerificationToken(t *testing.T, address string) apikey.VerificationToken {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != address {
+			continue
+		}
+
+		token, err := apikey.ParseVerificationToken(verificationTokenRegex.FindString(m.messages[i].Body))
+		require.Nil(t, err)
+		return token
+	}
+
+	require.FailNow(t, "No verification link sent to "+address)
+	return apikey.VerificationToken{}
+}
+
+var passwordResetTokenRegex = regexp.MustCompile(`usp_live_\w+`)
+
+// lastPasswordResetToken returns the token of the last password reset link
+// sent to the address.
+func (m *testMailer) lastPasswordResetToken(t *testing.T, address string) apikey.PasswordResetToken {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != address || m.messages[i].Subject != passwordResetSubject {
+			continue
+		}
+
+		token, err := apikey.ParsePasswordResetToken(passwordResetTokenRegex.FindString(m.messages[i].Body))
+		require.Nil(t, err)
+		return token
+	}
+
+	require.FailNow(t, "No password reset link sent to "+address)
+	return apikey.PasswordResetToken{}
+}
+
+var emailLoginTokenRegex = regexp.MustCompile(`usl_live_\w+`)
+var emailLoginCodeRegex = regexp.MustCompile(`\b\d{6}\b`)
+
+// lastEmailLogin returns the token and the code of the last login message
+// sent to the address.
+func (m *testMailer) lastEmailLogin(t *testing.T, address string) (apikey.EmailLoginToken, string) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To != address || m.messages[i].Subject != emailLoginSubject {
+			continue
+		}
+
+		token, err := apikey.ParseEmailLoginToken(emailLoginTokenRegex.FindString(m.messages[i].Body))
+		require.Nil(t, err)
+		code := emailLoginCodeRegex.FindString(m.messages[i].Body)
+		require.NotEmpty(t, code)
+		return token, code
+	}
+
+	require.FailNow(t, "No login message sent to "+address)
+	return apikey.EmailLoginToken{}, ""
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// identityResolver links the accounts of users at external identity
+// providers to their account in the service.
+type identityResolver struct {
+	users        *userServiceImpl
+	identityRepo repositories.IdentityRepository
+}
+
+func newIdentityResolver(users *userServiceImpl, identityRepo repositories.IdentityRepository) identityResolver {
+	return identityResolver{
+		users:        users,
+		identityRepo: identityRepo,
+	}
+}
+
+// resolve returns the user linked to the identity. Identities seen for the
+// first time are linked to the user with the same email, which must be
+// verified by the provider. When there's none, an account is created if
+// the provider allows it.
+func (s identityResolver) resolve(ctx context.Context, providerName string, identity federation.Identity, createUsers bool) (uuid.UUID, error) {
+	now := time.Now()
+
+	existing, err := s.identityRepo.GetBySubject(ctx, providerName, identity.Subject)
+	if err == nil {
+		err = s.identityRepo.UpdateLastLogin(ctx, existing.Id, identity.Email, now)
+		return existing.ApiUser, err
+	}
+	if !errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return uuid.Nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return uuid.Nil, errors.NewCode(UnverifiedFederatedEmail)
+	}
+	address, err := s.users.normalizeEmail(identity.Email)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	created := false
+	user, err := s.users.userRepo.GetByEmail(ctx, address)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		if !createUsers {
+			return uuid.Nil, errors.NewCode(FederatedSignUpDisabled)
+		}
+
+		user, err = s.createUser(ctx, address)
+		created = true
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	link := persistence.Identity{
+		Id:          uuid.New(),
+		ApiUser:     user.Id,
+		Provider:    providerName,
+		Subject:     identity.Subject,
+		Email:       identity.Email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+	err = s.createIdentity(ctx, link)
+	if err == nil {
+		return user.Id, nil
+	}
+
+	// The user can't be created in the same transaction as the identity:
+	// remove it so that a later attempt starts from scratch.
+	if created {
+		if deleteErr := s.users.Delete(ctx, user.Id); deleteErr != nil {
+			return uuid.Nil, deleteErr
+		}
+	}
+
+	// The same identity was linked by a concurrent login.
+	if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+		existing, err = s.identityRepo.GetBySubject(ctx, providerName, identity.Subject)
+		return existing.ApiUser, err
+	}
+
+	return uuid.Nil, err
+}
+
+// createUser creates an account without password: users created this way
+// sign in with their provider.
+func (s identityResolver) createUser(ctx context.Context, address string) (persistence.User, error) {
+	unusable := make([]byte, 32)
+	rand.Read(unusable)
+
+	hash, err := s.users.hasher.Hash(base64.RawURLEncoding.EncodeToString(unusable))
+	if err != nil {
+		return persistence.User{}, err
+	}
+
+	user := communication.FromUserDtoRequest(communication.UserDtoRequest{
+		Email:    address,
+		Password: hash,
+	})
+	// Only emails verified by the provider are accepted.
+	verifiedAt := user.CreatedAt
+	user.EmailVerifiedAt = &verifiedAt
+
+	return s.users.userRepo.Create(ctx, user)
+}
+
+func (s identityResolver) createIdentity(ctx context.Context, identity persistence.Identity) error {
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	_, err = s.identityRepo.Create(ctx, tx, identity)
+	return err
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+)
+
+// Prevents the delay from overflowing when computing the back-off.
+const maxBackOffExponent = 30
+
+// loginThrottle keeps track of the failed login attempts per email and per
+// client IP. Both are delayed with an exponential back-off while only the
+// accounts (identified by their email) can be locked.
+type loginThrottle struct {
+	repo   repositories.LoginThrottleRepository
+	config LoginThrottleConfig
+}
+
+type retryAfterError struct {
+	delay time.Duration
+}
+
+func NewRetryAfterError(delay time.Duration) error {
+	return retryAfterError{
+		delay: delay,
+	}
+}
+
+func (e retryAfterError) Error() string {
+	return fmt.Sprintf("retry after %v", e.delay)
+}
+
+// RetryAfter returns the delay held by the throttling error found in the
+// chain of causes of the input error, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	for err != nil {
+		if retry, ok := err.(retryAfterError); ok {
+			return retry.delay, true
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return 0, false
+}
+
+// loginAttempt holds the throttles the attempt was counted in.
+type loginAttempt []persistence.LoginThrottle
+
+// attempt counts the attempt as a failure before the credentials are
+// verified, unless it is throttled: concurrent guesses can't all be made
+// before the first failure is recorded. It should be forgotten once it
+// succeeds. The client IP is counted first so that the attempts rejected
+// because of it don't lock the account.
+func (t *loginThrottle) attempt(ctx context.Context, email string, clientIp string) (loginAttempt, error) {
+	now := time.Now()
+
+	var out loginAttempt
+	for _, throttle := range throttleKeys(email, clientIp) {
+		current, err := t.repo.RecordAttempt(ctx, throttle.Kind, throttle.Key, now, now.Add(-t.config.ResetAfter), t.backOffFor(throttle.Kind))
+		if err != nil {
+			if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+				return nil, t.blocked(ctx, throttle, now)
+			}
+
+			return nil, err
+		}
+
+		out = append(out, current)
+	}
+
+	return out, nil
+}
+
+// forget discards the attempt which succeeded. It is kept when other ones
+// were made since: they were counted on top of it.
+func (t *loginThrottle) forget(ctx context.Context, attempt loginAttempt) error {
+	for _, throttle := range attempt {
+		err := t.repo.ForgetAttempt(ctx, throttle.Kind, throttle.Key, throttle.LastFailureAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// blocked builds the error telling how long to wait before trying again.
+func (t *loginThrottle) blocked(ctx context.Context, throttle persistence.LoginThrottle, now time.Time) error {
+	current, err := t.repo.Get(ctx, throttle.Kind, throttle.Key)
+	if err != nil && !errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return err
+	}
+
+	code := TooManyLoginAttempts
+	if current.Locked {
+		code = AccountLocked
+	}
+	return errors.WrapCode(NewRetryAfterError(max(0, current.BlockedUntil.Sub(now))), code)
+}
+
+// ensureNotLocked rejects the logins which are not counted as attempts,
+// such as the ones delegated to an identity provider, while the account
+// using the email is locked.
+func (t *loginThrottle) ensureNotLocked(ctx context.Context, email string) error {
+	current, err := t.repo.Get(ctx, persistence.EmailLoginThrottle, throttleKey(email))
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if current.Locked && current.BlockedUntil.After(now) {
+		return errors.WrapCode(NewRetryAfterError(current.BlockedUntil.Sub(now)), AccountLocked)
+	}
+	return nil
+}
+
+// isLocked returns whether the account using the email is locked.
+func (t *loginThrottle) isLocked(ctx context.Context, email string) (bool, error) {
+	err := t.ensureNotLocked(ctx, email)
+	if errors.IsErrorWithCode(err, AccountLocked) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// reset forgets the failures and unlocks the account. The failures of the
+// client IPs are voluntarily kept: otherwise an attacker owning an account
+// could reset them at will.
+func (t *loginThrottle) reset(ctx context.Context, email string) error {
+	return t.repo.Delete(ctx, persistence.EmailLoginThrottle, throttleKey(email))
+}
+
+// backOffFor returns how long the keys of the kind are blocked after each
+// failure. Only the accounts (identified by their email) can be locked.
+func (t *loginThrottle) backOffFor(kind persistence.LoginThrottleKind) persistence.LoginBackOff {
+	var out persistence.LoginBackOff
+	for failures := 1; ; failures++ {
+		delay := t.backOff(failures)
+		out.Delays = append(out.Delays, delay)
+
+		// The delay stops growing at some point: the last one applies to
+		// the next failures.
+		exponent := failures - t.config.FreeAttempts - 1
+		if exponent >= 0 && (delay == 0 || delay == t.config.MaxDelay || exponent >= maxBackOffExponent) {
+			break
+		}
+	}
+
+	if kind == persistence.EmailLoginThrottle && t.config.LockoutThreshold > 0 {
+		out.LockAfter = t.config.LockoutThreshold
+		out.LockFor = t.config.LockoutDuration
+	}
+
+	return out
+}
+
+func (t *loginThrottle) backOff(failures int) time.Duration {
+	exponent := failures - t.config.FreeAttempts - 1
+	if exponent < 0 || t.config.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := t.config.BaseDelay << min(exponent, maxBackOffExponent)
+	if delay <= 0 || delay > t.config.MaxDelay {
+		return t.config.MaxDelay
+	}
+
+	return delay
+}
+
+func throttleKeys(email string, clientIp string) []persistence.LoginThrottle {
+	var out []persistence.LoginThrottle
+	if clientIp != "" {
+		out = append(out, persistence.LoginThrottle{
+			Kind: persistence.IpLoginThrottle,
+			Key:  clientIp,
+		})
+	}
+
+	return append(out, persistence.LoginThrottle{
+		Kind: persistence.EmailLoginThrottle,
+		Key:  throttleKey(email),
+	})
+}
+
+func throttleKey(email string) string {
+	return strings.ToLower(email)
+}
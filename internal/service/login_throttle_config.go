@@ -0,0 +1,23 @@
+package service
+
+import (
+	"time"
+)
+
+type LoginThrottleConfig struct {
+	// FreeAttempts is the number of failures tolerated before attempts
+	// start being delayed.
+	FreeAttempts int
+	// BaseDelay is doubled with each additional failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// LockoutThreshold is the number of failures after which the account
+	// is locked. Accounts are never locked when it is 0.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+
+	// ResetAfter is the period without failure after which the failures
+	// are forgotten.
+	ResetAfter time.Duration
+}
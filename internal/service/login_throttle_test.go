@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockLoginThrottleRepository struct {
+	repositories.LoginThrottleRepository
+
+	throttles map[persistence.LoginThrottleKind]persistence.LoginThrottle
+}
+
+func TestUnit_LoginThrottle_BackOff(t *testing.T) {
+	throttle := loginThrottle{
+		config: LoginThrottleConfig{
+			FreeAttempts: 2,
+			BaseDelay:    1 * time.Second,
+			MaxDelay:     10 * time.Second,
+		},
+	}
+
+	assert.Equal(t, time.Duration(0), throttle.backOff(1))
+	assert.Equal(t, time.Duration(0), throttle.backOff(2))
+	assert.Equal(t, 1*time.Second, throttle.backOff(3))
+	assert.Equal(t, 2*time.Second, throttle.backOff(4))
+	assert.Equal(t, 8*time.Second, throttle.backOff(6))
+	assert.Equal(t, 10*time.Second, throttle.backOff(7))
+	assert.Equal(t, 10*time.Second, throttle.backOff(1000))
+}
+
+func TestUnit_LoginThrottle_BackOff_WhenNoBaseDelay_ExpectNoDelay(t *testing.T) {
+	throttle := loginThrottle{
+		config: LoginThrottleConfig{
+			MaxDelay: 10 * time.Second,
+		},
+	}
+
+	assert.Equal(t, time.Duration(0), throttle.backOff(12))
+}
+
+func TestUnit_LoginThrottle_BackOffFor(t *testing.T) {
+	throttle := loginThrottle{
+		config: LoginThrottleConfig{
+			FreeAttempts:     2,
+			BaseDelay:        1 * time.Second,
+			MaxDelay:         10 * time.Second,
+			LockoutThreshold: 3,
+			LockoutDuration:  1 * time.Hour,
+		},
+	}
+
+	actual := throttle.backOffFor(persistence.EmailLoginThrottle)
+
+	expected := persistence.LoginBackOff{
+		Delays:    []time.Duration{0, 0, 1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second},
+		LockAfter: 3,
+		LockFor:   1 * time.Hour,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnit_LoginThrottle_BackOffFor_WhenClientIp_ExpectNeverLocked(t *testing.T) {
+	throttle := loginThrottle{
+		config: LoginThrottleConfig{
+			LockoutThreshold: 3,
+			LockoutDuration:  1 * time.Hour,
+		},
+	}
+
+	actual := throttle.backOffFor(persistence.IpLoginThrottle)
+
+	assert.Zero(t, actual.LockAfter)
+}
+
+func TestUnit_LoginThrottle_BackOffFor_WhenNoBaseDelay_ExpectNoDelay(t *testing.T) {
+	throttle := loginThrottle{
+		config: LoginThrottleConfig{
+			FreeAttempts: 2,
+			MaxDelay:     10 * time.Second,
+		},
+	}
+
+	actual := throttle.backOffFor(persistence.EmailLoginThrottle)
+
+	assert.Equal(t, []time.Duration{0, 0, 0}, actual.Delays)
+	assert.Zero(t, actual.LockAfter)
+}
+
+func TestUnit_RetryAfter_WhenWrapped_ExpectDelayReturned(t *testing.T) {
+	err := errors.WrapCode(NewRetryAfterError(3*time.Second), TooManyLoginAttempts)
+
+	actual, ok := RetryAfter(err)
+
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, actual)
+}
+
+func TestUnit_RetryAfter_WhenNotThrottled_ExpectFalse(t *testing.T) {
+	_, ok := RetryAfter(errors.NewCode(InvalidCredentials))
+
+	assert.False(t, ok)
+}
+
+func TestUnit_UserService_Login_WhenAccountIsLocked_ExpectFailure(t *testing.T) {
+	repo := &mockLoginThrottleRepository{
+		throttles: map[persistence.LoginThrottleKind]persistence.LoginThrottle{
+			persistence.EmailLoginThrottle: {
+				Kind:         persistence.EmailLoginThrottle,
+				BlockedUntil: time.Now().Add(time.Hour),
+				Locked:       true,
+			},
+		},
+	}
+
+	service := newTestUserServiceWithLoginThrottleRepository(repo)
+	_, err := service.Login(context.Background(), communication.UserDtoRequest{Email: "user@example.com"}, ClientInfo{Ip: "203.0.113.7"})
+
+	assert.True(t, errors.IsErrorWithCode(err, AccountLocked), "Actual err: %v", err)
+	delay, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour.Seconds(), delay.Seconds(), 1)
+}
+
+func TestUnit_UserService_Login_WhenClientIpIsThrottled_ExpectFailure(t *testing.T) {
+	repo := &mockLoginThrottleRepository{
+		throttles: map[persistence.LoginThrottleKind]persistence.LoginThrottle{
+			persistence.IpLoginThrottle: {
+				Kind:         persistence.IpLoginThrottle,
+				BlockedUntil: time.Now().Add(time.Minute),
+			},
+		},
+	}
+
+	service := newTestUserServiceWithLoginThrottleRepository(repo)
+	_, err := service.Login(context.Background(), communication.UserDtoRequest{Email: "user@example.com"}, ClientInfo{Ip: "203.0.113.7"})
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyLoginAttempts), "Actual err: %v", err)
+}
+
+func TestUnit_UserService_ListLockouts_WhenNotAnAdministrator_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    uuid.New(),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	_, err := service.ListLockouts(context.Background(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, NotAnAdministrator), "Actual err: %v", err)
+}
+
+func TestUnit_UserService_Unlock_WhenNotAnAdministrator_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	err := service.Unlock(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, NotAnAdministrator), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WhenFailuresExceedFreeAttempts_ExpectThrottled(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "not-the-right-password",
+	}
+
+	for range loginThrottleTestConfig.FreeAttempts {
+		_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+	require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+
+	userDtoRequest.Password = user.Password
+	_, err = service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyLoginAttempts), "Actual err: %v", err)
+	delay, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.InDelta(t, loginThrottleTestConfig.BaseDelay.Seconds(), delay.Seconds(), 1)
+}
+
+func TestIT_UserService_Login_WhenFailuresExceedThreshold_ExpectAccountLocked(t *testing.T) {
+	config := loginThrottleTestConfig
+	config.BaseDelay = 0
+	service, conn := newTestUserServiceWithConfigs(t, AdminConfig{}, config)
+	user := insertTestUser(t, conn)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "not-the-right-password",
+	}
+
+	for range config.LockoutThreshold {
+		_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+
+	userDtoRequest.Password = user.Password
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, AccountLocked), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WhenEmailIsUnknown_ExpectClientIpThrottled(t *testing.T) {
+	config := loginThrottleTestConfig
+	config.FreeAttempts = 0
+	service, conn := newTestUserServiceWithConfigs(t, AdminConfig{}, config)
+	user := insertTestUser(t, conn)
+	clientIp := fmt.Sprintf("test-ip-%s", uuid.NewString())
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    fmt.Sprintf("not-an-existing-email-%s@example.com", uuid.New()),
+		Password: "my-password",
+	}
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{Ip: clientIp})
+	require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+
+	userDtoRequest = communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+	_, err = service.Login(context.Background(), userDtoRequest, ClientInfo{Ip: clientIp})
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyLoginAttempts), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WhenSuccessful_ExpectFailuresReset(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "not-the-right-password",
+	}
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+	require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+
+	userDtoRequest.Password = user.Password
+	_, err = service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertNoLoginFailuresForUser(t, conn, user)
+}
+
+func TestIT_UserService_ListLockouts(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, admin.Id)
+	config := loginThrottleTestConfig
+	config.BaseDelay = 0
+	service, _ := newTestUserServiceWithConfigs(t, AdminConfig{Users: []uuid.UUID{admin.Id}}, config)
+
+	user := insertTestUser(t, conn)
+	lockTestUser(t, service, user, config.LockoutThreshold)
+
+	out, err := service.ListLockouts(context.Background(), apiKey.Key)
+
+	assert.Nil(t, err)
+	var actual *communication.AccountLockoutDtoResponse
+	for _, lockout := range out {
+		if lockout.User == user.Id {
+			actual = &lockout
+		}
+	}
+	require.NotNil(t, actual)
+	assert.Equal(t, user.Email, actual.Email)
+	assert.Equal(t, config.LockoutThreshold, actual.Failures)
+	assert.WithinDuration(t, time.Now().Add(config.LockoutDuration), actual.LockedUntil, 5*time.Second)
+}
+
+func TestIT_UserService_Unlock(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, admin.Id)
+	config := loginThrottleTestConfig
+	config.BaseDelay = 0
+	service, _ := newTestUserServiceWithConfigs(t, AdminConfig{Users: []uuid.UUID{admin.Id}}, config)
+
+	user := insertTestUser(t, conn)
+	lockTestUser(t, service, user, config.LockoutThreshold)
+
+	err := service.Unlock(context.Background(), apiKey.Key, user.Id)
+
+	assert.Nil(t, err)
+	assertNoLoginFailuresForUser(t, conn, user)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+	_, err = service.Login(context.Background(), userDtoRequest, ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_UserService_Unlock_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, admin.Id)
+	service, _ := newTestUserServiceWithConfigs(t, AdminConfig{Users: []uuid.UUID{admin.Id}}, loginThrottleTestConfig)
+
+	err := service.Unlock(context.Background(), apiKey.Key, uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func newTestUserServiceWithLoginThrottleRepository(repo repositories.LoginThrottleRepository) UserService {
+	repos := repositories.Repositories{
+		LoginThrottle: repo,
+	}
+
+	return NewUserService(ApiKeyConfig{}, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), nil, nil, nil, repos)
+}
+
+func lockTestUser(t *testing.T, service UserService, user persistence.User, failures int) {
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "not-the-right-password",
+	}
+
+	for range failures {
+		_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+}
+
+func assertNoLoginFailuresForUser(t *testing.T, conn db.Connection, user persistence.User) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(*) FROM login_throttle WHERE kind = 'email' AND key = $1", strings.ToLower(user.Email))
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+// RecordAttempt rejects the attempts on the keys blocked in the mock.
+func (m *mockLoginThrottleRepository) RecordAttempt(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time, resetBefore time.Time, backOff persistence.LoginBackOff) (persistence.LoginThrottle, error) {
+	throttle, ok := m.throttles[kind]
+	if ok && throttle.BlockedUntil.After(at) {
+		return persistence.LoginThrottle{}, errors.NewCode(db.NoMatchingRows)
+	}
+
+	return persistence.LoginThrottle{Kind: kind, Key: key, Failures: 1, LastFailureAt: at, BlockedUntil: at}, nil
+}
+
+func (m *mockLoginThrottleRepository) Get(ctx context.Context, kind persistence.LoginThrottleKind, key string) (persistence.LoginThrottle, error) {
+	throttle, ok := m.throttles[kind]
+	if !ok {
+		return persistence.LoginThrottle{}, errors.NewCode(db.NoMatchingRows)
+	}
+
+	return throttle, nil
+}
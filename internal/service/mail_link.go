@@ -0,0 +1,44 @@
+package service
+
+import (
+	"net/url"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// validateLinkUrl verifies that the page of the frontend which receives the
+// tokens sent by email can be used to build links. It is optional.
+func validateLinkUrl(raw string, code errors.ErrorCode) error {
+	if raw == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" && parsed.Scheme != "http" || parsed.Host == "" {
+		return errors.NewCodeWithDetails(code, "url must be an absolute URL")
+	}
+	if parsed.Fragment != "" {
+		return errors.NewCodeWithDetails(code, "url can't have a fragment")
+	}
+
+	return nil
+}
+
+// linkWithToken appends the token to the page as the token query parameter.
+// Only the token is returned when there's no page.
+func linkWithToken(raw string, token string) string {
+	if raw == "" {
+		return token
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return token
+	}
+
+	query := parsed.Query()
+	query.Set("token", token)
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
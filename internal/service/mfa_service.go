@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+type MfaService interface {
+	// CompleteLogin opens the session of a login waiting for the second
+	// factor of the user.
+	CompleteLogin(ctx context.Context, request communication.MfaDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error)
+	// EnrollTotp generates the secret to add to the authenticator app of
+	// the user. It is only asked for at login once confirmed.
+	EnrollTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.TotpEnrollmentDtoResponse, error)
+	// ConfirmTotp enables the secret generated by EnrollTotp with a first
+	// code computed from it.
+	ConfirmTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string) error
+	// DisableTotp removes the secret of the user. A code which was not used
+	// before is required: a stolen session is not enough.
+	DisableTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string, client ClientInfo) error
+}
+
+type mfaServiceImpl struct {
+	users         *userServiceImpl
+	secondFactor  secondFactor
+	authenticator *totp.Authenticator
+}
+
+// NewMfaService creates a service managing the second factor of the users.
+// It requires an authenticator.
+func NewMfaService(authenticator *totp.Authenticator, users *userServiceImpl) MfaService {
+	return &mfaServiceImpl{
+		users:         users,
+		secondFactor:  newSecondFactor(users, authenticator),
+		authenticator: authenticator,
+	}
+}
+
+func (s *mfaServiceImpl) CompleteLogin(ctx context.Context, request communication.MfaDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	challenge, err := s.users.getMfaChallenge(ctx, request.Challenge)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	user, err := s.users.userRepo.Get(ctx, challenge.ApiUser)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.secondFactor.verify(ctx, user, request.Code, client)
+	if errors.IsErrorWithCode(err, InvalidMfaCode) {
+		if failureErr := s.users.recordMfaFailure(ctx, challenge.Id); failureErr != nil {
+			return communication.ApiKeyDtoResponse{}, failureErr
+		}
+	}
+	// Users whose only second factor is a passkey have no code to provide.
+	if errors.IsErrorWithCode(err, TotpNotEnrolled) {
+		return communication.ApiKeyDtoResponse{}, errors.WrapCode(err, InvalidMfaCode)
+	}
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	return s.users.completeMfaLogin(ctx, challenge, user, client)
+}
+
+func (s *mfaServiceImpl) EnrollTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.TotpEnrollmentDtoResponse, error) {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return communication.TotpEnrollmentDtoResponse{}, err
+	}
+
+	user, err := s.users.userRepo.Get(ctx, id)
+	if err != nil {
+		return communication.TotpEnrollmentDtoResponse{}, err
+	}
+
+	enrollment := s.authenticator.Enroll(user.Id, user.Email)
+	secret := persistence.TotpSecret{
+		ApiUser:   user.Id,
+		Secret:    enrollment.Encrypted,
+		CreatedAt: time.Now(),
+	}
+	_, err = s.users.totpRepo.Create(ctx, secret)
+	if err != nil {
+		// Confirmed secrets are not replaced.
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return communication.TotpEnrollmentDtoResponse{}, errors.NewCode(TotpAlreadyEnabled)
+		}
+		return communication.TotpEnrollmentDtoResponse{}, err
+	}
+
+	out := communication.TotpEnrollmentDtoResponse{
+		Secret: enrollment.Secret,
+		Uri:    enrollment.Uri,
+	}
+	return out, nil
+}
+
+func (s *mfaServiceImpl) ConfirmTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string) error {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	secret, err := s.users.totpRepo.Get(ctx, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(TotpNotEnrolled)
+		}
+		return err
+	}
+	if secret.ConfirmedAt != nil {
+		return errors.NewCode(TotpAlreadyEnabled)
+	}
+
+	counter, err := s.secondFactor.check(id, secret, code)
+	if err != nil {
+		return err
+	}
+
+	err = s.users.totpRepo.Confirm(ctx, id, counter, time.Now())
+	if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+		return errors.NewCode(TotpAlreadyEnabled)
+	}
+	return err
+}
+
+func (s *mfaServiceImpl) DisableTotp(ctx context.Context, apiKey apikey.Key, id uuid.UUID, code string, client ClientInfo) error {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.userRepo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.secondFactor.verify(ctx, user, code, client)
+	if err != nil {
+		return err
+	}
+
+	return s.users.totpRepo.Delete(ctx, id)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_MfaService_CompleteLogin_WhenChallengeIsMalformed_ExpectInvalidChallenge(t *testing.T) {
+	users := NewUserService(ApiKeyConfig{}, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, nil, nil, nil, nil, repositories.Repositories{})
+	service := NewMfaService(newTestAuthenticator(t), users)
+
+	request := communication.MfaDtoRequest{
+		Challenge: "not-a-challenge",
+		Code:      "123456",
+	}
+	_, err := service.CompleteLogin(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaChallenge), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_EnrollTotp_ExpectSecretIsNotRequiredUntilConfirmed(t *testing.T) {
+	users, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	enrollment, err := service.EnrollTotp(context.Background(), key.Key, user.Id)
+
+	require.Nil(t, err)
+	assert.NotEmpty(t, enrollment.Secret)
+	assert.True(t, strings.HasPrefix(enrollment.Uri, "otpauth://totp/"), "Actual uri: %s", enrollment.Uri)
+	_, err = openTestSession(t, users, newTestLoginRequest(user), ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_MfaService_EnrollTotp_WhenKeyBelongsToAnotherUser_ExpectPermissionDenied(t *testing.T) {
+	_, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, other.Id)
+
+	_, err := service.EnrollTotp(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_EnrollTotp_WhenAlreadyEnabled_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	enableTestTotp(t, service, key, user.Id)
+
+	_, err := service.EnrollTotp(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, TotpAlreadyEnabled), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_ConfirmTotp_WhenCodeIsWrong_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	_, err := service.EnrollTotp(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+
+	err = service.ConfirmTotp(context.Background(), key.Key, user.Id, "not-a-code")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaCode), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_ConfirmTotp_WhenNotEnrolled_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.ConfirmTotp(context.Background(), key.Key, user.Id, "123456")
+
+	assert.True(t, errors.IsErrorWithCode(err, TotpNotEnrolled), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_CompleteLogin(t *testing.T) {
+	users, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	secret := enableTestTotp(t, service, key, user.Id)
+
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	require.Nil(t, login.Session)
+	require.NotNil(t, login.Challenge)
+	assert.Equal(t, []string{TotpMethod}, login.Challenge.Methods)
+
+	request := communication.MfaDtoRequest{
+		Challenge: login.Challenge.Challenge,
+		Code:      generateTestTotpCode(t, secret, time.Now().Add(30*time.Second)),
+	}
+	apiKey, err := service.CompleteLogin(context.Background(), request, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, apiKey.User)
+	assertApiKeyExistsByKey(t, conn, apiKey.Key)
+}
+
+func TestIT_MfaService_CompleteLogin_WhenChallengeWasUsed_ExpectFailure(t *testing.T) {
+	users, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	secret := enableTestTotp(t, service, key, user.Id)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	request := communication.MfaDtoRequest{
+		Challenge: login.Challenge.Challenge,
+		Code:      generateTestTotpCode(t, secret, time.Now().Add(30*time.Second)),
+	}
+	_, err = service.CompleteLogin(context.Background(), request, ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.CompleteLogin(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaChallenge), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_CompleteLogin_WhenCodeWasUsed_ExpectFailure(t *testing.T) {
+	users, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	secret := enableTestTotp(t, service, key, user.Id)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+
+	// The code used to confirm the secret can't be replayed.
+	request := communication.MfaDtoRequest{
+		Challenge: login.Challenge.Challenge,
+		Code:      generateTestTotpCode(t, secret, time.Now()),
+	}
+	_, err = service.CompleteLogin(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaCode), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_CompleteLogin_WhenTooManyWrongCodes_ExpectChallengeIsDropped(t *testing.T) {
+	users, service, conn := newTestMfaServiceWithThrottle(t, LoginThrottleConfig{})
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	secret := enableTestTotp(t, service, key, user.Id)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+
+	request := communication.MfaDtoRequest{
+		Challenge: login.Challenge.Challenge,
+		Code:      "000000",
+	}
+	for range maxMfaAttempts {
+		_, err = service.CompleteLogin(context.Background(), request, ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidMfaCode), "Actual err: %v", err)
+	}
+
+	request.Code = generateTestTotpCode(t, secret, time.Now().Add(30*time.Second))
+	_, err = service.CompleteLogin(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaChallenge), "Actual err: %v", err)
+}
+
+func TestIT_MfaService_DisableTotp(t *testing.T) {
+	users, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	secret := enableTestTotp(t, service, key, user.Id)
+
+	code := generateTestTotpCode(t, secret, time.Now().Add(30*time.Second))
+	err := service.DisableTotp(context.Background(), key.Key, user.Id, code, ClientInfo{})
+
+	assert.Nil(t, err)
+	_, err = openTestSession(t, users, newTestLoginRequest(user), ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_MfaService_DisableTotp_WhenCodeIsWrong_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestMfaService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	enableTestTotp(t, service, key, user.Id)
+
+	err := service.DisableTotp(context.Background(), key.Key, user.Id, "000000", ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaCode), "Actual err: %v", err)
+}
+
+func newTestAuthenticator(t *testing.T) *totp.Authenticator {
+	config := totp.Config{
+		Issuer:        "user-service",
+		EncryptionKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))),
+	}
+	authenticator, err := totp.New(config)
+	require.Nil(t, err)
+	return authenticator
+}
+
+func newTestMfaService(t *testing.T) (UserService, MfaService, db.Connection) {
+	return newTestMfaServiceWithThrottle(t, loginThrottleTestConfig)
+}
+
+func newTestMfaServiceWithThrottle(t *testing.T, throttleConfig LoginThrottleConfig) (UserService, MfaService, db.Connection) {
+	conn := newTestConnection(t)
+
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, throttleConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewMfaService(newTestAuthenticator(t), users)
+	return users, service, conn
+}
+
+// enableTestTotp enrolls and confirms an authenticator app for the user and
+// returns its secret. The code of the current time step is used.
+func enableTestTotp(t *testing.T, service MfaService, key testApiKey, user uuid.UUID) string {
+	enrollment, err := service.EnrollTotp(context.Background(), key.Key, user)
+	require.Nil(t, err)
+
+	code := generateTestTotpCode(t, enrollment.Secret, time.Now())
+	err = service.ConfirmTotp(context.Background(), key.Key, user, code)
+	require.Nil(t, err)
+
+	return enrollment.Secret
+}
+
+func generateTestTotpCode(t *testing.T, secret string, at time.Time) string {
+	code, err := totp.Code(secret, at)
+	require.Nil(t, err)
+	return code
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"net/url"
+	"slices"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+)
+
+type OAuthClient struct {
+	Id string
+	// SecretDigest is the SHA-256 digest of the secret of the client, encoded
+	// in hex: the configuration does not reveal the secret. It is empty for
+	// public clients, such as browser apps, which can't keep a secret: they
+	// can only sign users in with PKCE.
+	SecretDigest string
+	// RedirectUris are the addresses users can be sent back to once they
+	// signed in. They are compared exactly: clients which leave it empty
+	// can't sign users in.
+	RedirectUris []string
+}
+
+type OAuthConfig struct {
+	// Clients are the services allowed to introspect the keys and to sign
+	// users in. No client can call the OAuth endpoints when it is empty.
+	Clients []OAuthClient
+}
+
+func (c OAuthConfig) Validate() error {
+	ids := make(map[string]bool)
+	for _, client := range c.Clients {
+		if client.Id == "" || ids[client.Id] {
+			return errors.NewCodeWithDetails(InvalidOAuthClient, client.Id)
+		}
+		ids[client.Id] = true
+
+		if client.public() && len(client.RedirectUris) == 0 {
+			return errors.NewCodeWithDetails(InvalidOAuthClient, client.Id)
+		}
+
+		if !client.public() {
+			digest, err := hex.DecodeString(client.SecretDigest)
+			if err != nil || len(digest) != 32 {
+				return errors.NewCodeWithDetails(InvalidOAuthClient, client.Id)
+			}
+		}
+
+		for _, uri := range client.RedirectUris {
+			if !validRedirectUri(uri) {
+				return errors.NewCodeWithDetails(InvalidOAuthClient, client.Id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ClientCredentials are provided by the clients calling the OAuth endpoints
+// to authenticate themselves.
+type ClientCredentials struct {
+	Id     string
+	Secret string
+}
+
+// authenticate only succeeds for confidential clients: public clients don't
+// have a secret to prove who they are.
+func (c OAuthConfig) authenticate(credentials ClientCredentials) bool {
+	client, ok := c.client(credentials.Id)
+	if !ok || client.public() {
+		return false
+	}
+
+	digest := apikey.Digest(credentials.Secret, "")
+	return subtle.ConstantTimeCompare([]byte(client.SecretDigest), []byte(digest)) == 1
+}
+
+func (c OAuthConfig) client(id string) (OAuthClient, bool) {
+	for _, client := range c.Clients {
+		if client.Id == id {
+			return client, true
+		}
+	}
+
+	return OAuthClient{}, false
+}
+
+func (c OAuthClient) public() bool {
+	return c.SecretDigest == ""
+}
+
+func (c OAuthClient) allowsRedirectUri(uri string) bool {
+	return slices.Contains(c.RedirectUris, uri)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6749#section-3.1.2
+func validRedirectUri(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+
+	return parsed.IsAbs() && parsed.Host != "" && parsed.Fragment == ""
+}
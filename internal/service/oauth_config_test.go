@@ -0,0 +1,107 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var oauthTestConfig = OAuthConfig{
+	Clients: []OAuthClient{
+		{
+			Id: "my-client",
+			// Digest of "my-secret".
+			SecretDigest: "186ef76e9d6a723ecb570d4d9c287487d001e5d35f7ed4a313350a407950318e",
+			RedirectUris: []string{"https://my-client.example.com/callback"},
+		},
+		{
+			Id:           "my-public-client",
+			RedirectUris: []string{"http://localhost:3000/callback"},
+		},
+	},
+}
+
+var oauthTestClient = ClientCredentials{
+	Id:     "my-client",
+	Secret: "my-secret",
+}
+
+func TestUnit_OAuthConfig_Validate(t *testing.T) {
+	assert.Nil(t, oauthTestConfig.Validate())
+	assert.Nil(t, OAuthConfig{}.Validate())
+}
+
+func TestUnit_OAuthConfig_Validate_WhenClientIsInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		clients []OAuthClient
+	}
+
+	testCases := map[string]testCase{
+		"noId": {
+			clients: []OAuthClient{{SecretDigest: oauthTestConfig.Clients[0].SecretDigest}},
+		},
+		"duplicatedId": {
+			clients: []OAuthClient{oauthTestConfig.Clients[0], oauthTestConfig.Clients[0]},
+		},
+		"digestIsNotHex": {
+			clients: []OAuthClient{{Id: "my-client", SecretDigest: "my-secret"}},
+		},
+		"digestIsTooShort": {
+			clients: []OAuthClient{{Id: "my-client", SecretDigest: "186ef76e9d6a723e"}},
+		},
+		"publicWithoutRedirectUri": {
+			clients: []OAuthClient{{Id: "my-public-client"}},
+		},
+		"relativeRedirectUri": {
+			clients: []OAuthClient{{Id: "my-public-client", RedirectUris: []string{"/callback"}}},
+		},
+		"redirectUriWithFragment": {
+			clients: []OAuthClient{{Id: "my-public-client", RedirectUris: []string{"https://example.com/callback#token"}}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := OAuthConfig{Clients: tc.clients}.Validate()
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidOAuthClient), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_OAuthConfig_Authenticate(t *testing.T) {
+	type testCase struct {
+		credentials ClientCredentials
+		expected    bool
+	}
+
+	testCases := map[string]testCase{
+		"valid": {
+			credentials: oauthTestClient,
+			expected:    true,
+		},
+		"wrongSecret": {
+			credentials: ClientCredentials{Id: "my-client", Secret: "not-my-secret"},
+			expected:    false,
+		},
+		"unknownClient": {
+			credentials: ClientCredentials{Id: "other-client", Secret: "my-secret"},
+			expected:    false,
+		},
+		"publicClient": {
+			credentials: ClientCredentials{Id: "my-public-client"},
+			expected:    false,
+		},
+		"noCredentials": {
+			credentials: ClientCredentials{},
+			expected:    false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, oauthTestConfig.authenticate(tc.credentials))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type OidcConfig struct {
+	// Enabled exposes the OpenID Connect endpoints. It requires signed
+	// tokens: ID tokens are signed with the same keys.
+	Enabled bool
+	// CodeValidity is how long clients have to exchange the authorization
+	// code they received for a session.
+	CodeValidity time.Duration
+}
+
+// Validate verifies the configuration against the issuer of the signed
+// tokens: the endpoints are published relative to it, so it has to be the
+// public URL of the service.
+func (c OidcConfig) Validate(issuer string) error {
+	if !c.Enabled {
+		return nil
+	}
+
+	if c.CodeValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidOidcConfiguration, "code validity must be positive")
+	}
+
+	parsed, err := url.Parse(issuer)
+	if err != nil || parsed.Scheme != "https" && parsed.Scheme != "http" || parsed.Host == "" {
+		return errors.NewCodeWithDetails(InvalidOidcConfiguration, "issuer must be an absolute URL")
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return errors.NewCodeWithDetails(InvalidOidcConfiguration, "issuer can't have a query or a fragment")
+	}
+
+	return nil
+}
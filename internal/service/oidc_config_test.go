@@ -0,0 +1,61 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_OidcConfig_Validate(t *testing.T) {
+	config := OidcConfig{
+		Enabled:      true,
+		CodeValidity: time.Minute,
+	}
+
+	assert.Nil(t, config.Validate("https://example.com/v1/users"))
+	assert.Nil(t, config.Validate("http://localhost:60001/v1/users"))
+}
+
+func TestUnit_OidcConfig_Validate_WhenDisabled_ExpectNothingIsVerified(t *testing.T) {
+	assert.Nil(t, OidcConfig{}.Validate("user-service"))
+}
+
+func TestUnit_OidcConfig_Validate_WhenInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		codeValidity time.Duration
+		issuer       string
+	}
+
+	testCases := map[string]testCase{
+		"noCodeValidity": {
+			issuer: "https://example.com/v1/users",
+		},
+		"issuerIsNotUrl": {
+			codeValidity: time.Minute,
+			issuer:       "user-service",
+		},
+		"issuerIsRelative": {
+			codeValidity: time.Minute,
+			issuer:       "/v1/users",
+		},
+		"issuerHasQuery": {
+			codeValidity: time.Minute,
+			issuer:       "https://example.com/v1/users?tenant=1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := OidcConfig{
+				Enabled:      true,
+				CodeValidity: tc.codeValidity,
+			}
+
+			err := config.Validate(tc.issuer)
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidOidcConfiguration), "Actual err: %v", err)
+		})
+	}
+}
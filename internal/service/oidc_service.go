@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// https://openid.net/specs/openid-connect-core-1_0.html#ScopeClaims
+const (
+	OpenIdScope  = "openid"
+	EmailScope   = "email"
+	ProfileScope = "profile"
+)
+
+var supportedScopes = []string{OpenIdScope, EmailScope, ProfileScope}
+
+const (
+	codeResponseType       = "code"
+	authorizationCodeGrant = "authorization_code"
+)
+
+// State and nonce are echoed back to the client: they don't need to be
+// long and should not fill the database.
+const maxOpaqueValueLength = 512
+
+type OidcService interface {
+	Configuration(ctx context.Context) (communication.OidcConfigurationDtoResponse, error)
+	// ValidateAuthorization verifies the request before asking the user to
+	// log in. Errors with the InvalidRedirectUri code can't be reported to
+	// the client: the user should not be redirected.
+	ValidateAuthorization(ctx context.Context, request communication.AuthorizationDtoRequest) error
+	// Authorize logs the user in and returns the code to send to the client.
+	// The one-time code is only checked for the users who enabled a second
+	// factor: SecondFactorRequired is returned when it is missing.
+	Authorize(ctx context.Context, request communication.AuthorizationDtoRequest, user communication.UserDtoRequest, oneTimeCode string, client ClientInfo) (apikey.AuthorizationCode, error)
+	// Exchange opens a session for the client which presented the code.
+	Exchange(ctx context.Context, credentials ClientCredentials, request communication.TokenDtoRequest, client ClientInfo) (communication.TokenDtoResponse, error)
+	UserInfo(ctx context.Context, apiKey apikey.Key) (communication.UserInfoDtoResponse, error)
+}
+
+type oidcServiceImpl struct {
+	users        *userServiceImpl
+	secondFactor secondFactor
+	codeRepo     repositories.AuthorizationCodeRepository
+	signer       jwt.Signer
+	clients      OAuthConfig
+
+	codeValidity time.Duration
+}
+
+// NewOidcService creates a service signing users in on behalf of the OAuth
+// clients. It requires the user service to have a signer: ID tokens are
+// always signed. The authenticator verifies the second factor of the users
+// and may be nil.
+func NewOidcService(config OidcConfig, oauthConfig OAuthConfig, authenticator *totp.Authenticator, repos repositories.Repositories, users *userServiceImpl) OidcService {
+	return &oidcServiceImpl{
+		users:        users,
+		secondFactor: newSecondFactor(users, authenticator),
+		codeRepo:     repos.AuthorizationCode,
+		signer:       users.signer,
+		clients:      oauthConfig,
+
+		codeValidity: config.CodeValidity,
+	}
+}
+
+func (s *oidcServiceImpl) Configuration(ctx context.Context) (communication.OidcConfigurationDtoResponse, error) {
+	keys, err := s.signer.Keys(ctx)
+	if err != nil {
+		return communication.OidcConfigurationDtoResponse{}, err
+	}
+
+	var algorithms []string
+	for _, key := range keys.Keys {
+		if !slices.Contains(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	issuer := strings.TrimSuffix(s.signer.Issuer(), "/")
+	out := communication.OidcConfigurationDtoResponse{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserInfoEndpoint:                  issuer + "/oauth/userinfo",
+		JwksUri:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/oauth/introspect",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{codeResponseType},
+		GrantTypesSupported:               []string{authorizationCodeGrant},
+		SubjectTypesSupported:             []string{"public"},
+		IdTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported:                   []string{"sub", "email", "updated_at"},
+	}
+
+	return out, nil
+}
+
+func (s *oidcServiceImpl) ValidateAuthorization(ctx context.Context, request communication.AuthorizationDtoRequest) error {
+	_, err := s.validateAuthorization(request)
+	return err
+}
+
+func (s *oidcServiceImpl) Authorize(ctx context.Context, request communication.AuthorizationDtoRequest, user communication.UserDtoRequest, oneTimeCode string, client ClientInfo) (apikey.AuthorizationCode, error) {
+	scope, err := s.validateAuthorization(request)
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+
+	dbUser, secondFactor, err := s.users.authenticateUser(ctx, user.Email, user.Password, client)
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+	if secondFactor {
+		err = s.verifySecondFactor(ctx, dbUser, oneTimeCode, client)
+		if err != nil {
+			return apikey.AuthorizationCode{}, err
+		}
+	}
+
+	code := apikey.GenerateAuthorizationCode()
+	codeHash, err := s.users.digester.digest(ctx, code.String())
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+	defer tx.Close(ctx)
+
+	now := time.Now()
+	err = s.codeRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+
+	authorizationCode := persistence.AuthorizationCode{
+		Id:            uuid.New(),
+		CodeHash:      codeHash,
+		ClientId:      request.ClientId,
+		ApiUser:       dbUser.Id,
+		RedirectUri:   request.RedirectUri,
+		Scope:         scope,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		CreatedAt:     now,
+		ValidUntil:    now.Add(s.codeValidity),
+	}
+
+	_, err = s.codeRepo.Create(ctx, tx, authorizationCode)
+	if err != nil {
+		return apikey.AuthorizationCode{}, err
+	}
+
+	return code, nil
+}
+
+func (s *oidcServiceImpl) Exchange(ctx context.Context, credentials ClientCredentials, request communication.TokenDtoRequest, client ClientInfo) (communication.TokenDtoResponse, error) {
+	var out communication.TokenDtoResponse
+
+	if !s.authenticateClient(credentials) {
+		return out, errors.NewCode(ClientNotAuthenticated)
+	}
+	if request.GrantType != authorizationCodeGrant {
+		return out, errors.NewCodeWithDetails(UnsupportedGrantType, request.GrantType)
+	}
+
+	rawCode, err := apikey.ParseAuthorizationCode(request.Code)
+	if err != nil {
+		return out, errors.WrapCode(err, InvalidGrant)
+	}
+
+	code, err := s.consumeCode(ctx, rawCode)
+	if err != nil {
+		return out, err
+	}
+
+	// The code is gone even when it turns out to be invalid: it can only be
+	// presented once.
+	if code.ClientId != credentials.Id || code.RedirectUri != request.RedirectUri {
+		return out, errors.NewCode(InvalidGrant)
+	}
+	if code.ValidUntil.Before(time.Now()) {
+		return out, errors.NewCode(InvalidGrant)
+	}
+	if !verifyCodeChallenge(request.CodeVerifier, code.CodeChallenge) {
+		return out, errors.NewCode(InvalidGrant)
+	}
+
+	user, err := s.users.userRepo.Get(ctx, code.ApiUser)
+	if err != nil {
+		return out, err
+	}
+
+	grant := oauthGrant{
+		clientId: code.ClientId,
+		scope:    code.Scope,
+	}
+	session, err := s.users.openSession(ctx, user.Id, client, grant)
+	if err != nil {
+		return out, err
+	}
+
+	now := time.Now()
+	claims := jwt.IdClaims{
+		Subject:   user.Id.String(),
+		Audience:  code.ClientId,
+		Nonce:     code.Nonce,
+		AuthTime:  code.CreatedAt.Unix(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: session.ValidUntil.Unix(),
+	}
+	fillUserClaims(user, code.Scope, &claims.Email, &claims.UpdatedAt)
+
+	idToken, err := s.signer.SignIdToken(ctx, claims)
+	if err != nil {
+		return out, err
+	}
+
+	out = communication.TokenDtoResponse{
+		AccessToken: session.Key,
+		TokenType:   bearerTokenType,
+		ExpiresIn:   int64(session.ValidUntil.Sub(now).Seconds()),
+		IdToken:     idToken,
+		Scope:       code.Scope,
+	}
+
+	return out, nil
+}
+
+func (s *oidcServiceImpl) UserInfo(ctx context.Context, apiKey apikey.Key) (communication.UserInfoDtoResponse, error) {
+	var out communication.UserInfoDtoResponse
+
+	session, valid, err := s.users.resolveApiKey(ctx, apiKey)
+	if err != nil {
+		return out, err
+	}
+	if !valid {
+		return out, errors.NewCode(UserNotAuthenticated)
+	}
+	if !slices.Contains(strings.Fields(session.Scope), OpenIdScope) {
+		return out, errors.NewCode(InsufficientScope)
+	}
+
+	user, err := s.users.userRepo.Get(ctx, session.ApiUser)
+	if err != nil {
+		return out, err
+	}
+
+	out.Subject = user.Id.String()
+	fillUserClaims(user, session.Scope, &out.Email, &out.UpdatedAt)
+
+	return out, nil
+}
+
+// validateAuthorization returns the scopes granted to the client when the
+// request is valid.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+func (s *oidcServiceImpl) validateAuthorization(request communication.AuthorizationDtoRequest) (string, error) {
+	client, ok := s.clients.client(request.ClientId)
+	if !ok || !client.allowsRedirectUri(request.RedirectUri) {
+		return "", errors.NewCode(InvalidRedirectUri)
+	}
+
+	if request.ResponseType != codeResponseType {
+		return "", errors.NewCodeWithDetails(UnsupportedResponseType, request.ResponseType)
+	}
+
+	scope, err := grantedScope(request.Scope)
+	if err != nil {
+		return "", err
+	}
+
+	if !validCodeChallenge(request.CodeChallenge, request.CodeChallengeMethod) {
+		return "", errors.NewCodeWithDetails(InvalidAuthorizationRequest, "a S256 code challenge is required")
+	}
+	if len(request.State) > maxOpaqueValueLength || len(request.Nonce) > maxOpaqueValueLength {
+		return "", errors.NewCodeWithDetails(InvalidAuthorizationRequest, "state or nonce is too long")
+	}
+
+	return scope, nil
+}
+
+// authenticateClient verifies the secret of confidential clients. Public
+// clients are identified by their id alone: PKCE proves that they are the
+// ones who requested the code.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-3.2.1
+func (s *oidcServiceImpl) authenticateClient(credentials ClientCredentials) bool {
+	client, ok := s.clients.client(credentials.Id)
+	if !ok {
+		return false
+	}
+	if client.public() {
+		return credentials.Secret == ""
+	}
+
+	return s.clients.authenticate(credentials)
+}
+
+func (s *oidcServiceImpl) consumeCode(ctx context.Context, code apikey.AuthorizationCode) (persistence.AuthorizationCode, error) {
+	codeHashes, err := s.users.digester.candidates(ctx, code.String())
+	if err != nil {
+		return persistence.AuthorizationCode{}, err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return persistence.AuthorizationCode{}, err
+	}
+	defer tx.Close(ctx)
+
+	out, err := s.codeRepo.Consume(ctx, tx, codeHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.AuthorizationCode{}, errors.NewCode(InvalidGrant)
+		}
+
+		return persistence.AuthorizationCode{}, err
+	}
+
+	return out, nil
+}
+
+// grantedScope keeps the supported scopes of the request. Unknown ones are
+// ignored as allowed by the specification, but openid is required.
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func grantedScope(requested string) (string, error) {
+	var granted []string
+	for _, scope := range strings.Fields(requested) {
+		if slices.Contains(supportedScopes, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	if !slices.Contains(granted, OpenIdScope) {
+		return "", errors.NewCodeWithDetails(InvalidScope, "the openid scope is required")
+	}
+
+	return strings.Join(granted, " "), nil
+}
+
+// fillUserClaims sets the claims describing the user which the scope gives
+// access to.
+func fillUserClaims(user persistence.User, scope string, email *string, updatedAt *int64) {
+	scopes := strings.Fields(scope)
+
+	if slices.Contains(scopes, EmailScope) {
+		*email = user.Email
+	}
+	if slices.Contains(scopes, ProfileScope) {
+		*updatedAt = user.UpdatedAt.Unix()
+	}
+}
+
+// verifySecondFactor checks the one-time code typed in the login form. The
+// form is submitted again with it, so the password is verified again as
+// well: there's no challenge to keep in between.
+func (s *oidcServiceImpl) verifySecondFactor(ctx context.Context, user persistence.User, oneTimeCode string, client ClientInfo) error {
+	if oneTimeCode == "" {
+		return errors.NewCode(SecondFactorRequired)
+	}
+
+	err := s.secondFactor.verify(ctx, user, oneTimeCode, client)
+	if err != nil {
+		return err
+	}
+
+	return s.users.throttle.reset(ctx, user.Email)
+}
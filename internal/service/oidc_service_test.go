@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var authorizationTestRequest = communication.AuthorizationDtoRequest{
+	ResponseType:        "code",
+	ClientId:            "my-client",
+	RedirectUri:         "https://my-client.example.com/callback",
+	Scope:               "openid email",
+	State:               "my-state",
+	Nonce:               "my-nonce",
+	CodeChallenge:       pkceTestChallenge,
+	CodeChallengeMethod: "S256",
+}
+
+func TestUnit_OidcService_Configuration(t *testing.T) {
+	signer := newTestSignerWithIssuer(t, "https://example.com/v1/users/")
+	service := newTestOidcService(signer, repositories.Repositories{})
+
+	out, err := service.Configuration(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com/v1/users", out.Issuer)
+	assert.Equal(t, "https://example.com/v1/users/oauth/authorize", out.AuthorizationEndpoint)
+	assert.Equal(t, "https://example.com/v1/users/oauth/token", out.TokenEndpoint)
+	assert.Equal(t, "https://example.com/v1/users/oauth/userinfo", out.UserInfoEndpoint)
+	assert.Equal(t, "https://example.com/v1/users/.well-known/jwks.json", out.JwksUri)
+	assert.Equal(t, []string{"EdDSA"}, out.IdTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, out.CodeChallengeMethodsSupported)
+}
+
+func TestUnit_OidcService_ValidateAuthorization(t *testing.T) {
+	service := newTestOidcService(newTestSigner(t), repositories.Repositories{})
+
+	err := service.ValidateAuthorization(context.Background(), authorizationTestRequest)
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_OidcService_ValidateAuthorization_WhenRequestIsInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		update       func(*communication.AuthorizationDtoRequest)
+		expectedCode errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"unknownClient": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.ClientId = "other-client" },
+			expectedCode: InvalidRedirectUri,
+		},
+		"unregisteredRedirectUri": {
+			update: func(r *communication.AuthorizationDtoRequest) {
+				r.RedirectUri = "https://attacker.example.com/callback"
+			},
+			expectedCode: InvalidRedirectUri,
+		},
+		"redirectUriOfAnotherClient": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.RedirectUri = "http://localhost:3000/callback" },
+			expectedCode: InvalidRedirectUri,
+		},
+		"implicitFlow": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.ResponseType = "token" },
+			expectedCode: UnsupportedResponseType,
+		},
+		"noOpenIdScope": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.Scope = "email" },
+			expectedCode: InvalidScope,
+		},
+		"noCodeChallenge": {
+			update: func(r *communication.AuthorizationDtoRequest) {
+				r.CodeChallenge = ""
+				r.CodeChallengeMethod = ""
+			},
+			expectedCode: InvalidAuthorizationRequest,
+		},
+		"plainCodeChallenge": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.CodeChallengeMethod = "plain" },
+			expectedCode: InvalidAuthorizationRequest,
+		},
+		"stateTooLong": {
+			update:       func(r *communication.AuthorizationDtoRequest) { r.State = strings.Repeat("a", 513) },
+			expectedCode: InvalidAuthorizationRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			service := newTestOidcService(newTestSigner(t), repositories.Repositories{})
+			request := authorizationTestRequest
+			tc.update(&request)
+
+			err := service.ValidateAuthorization(context.Background(), request)
+
+			assert.True(t, errors.IsErrorWithCode(err, tc.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_OidcService_Exchange_WhenClientIsNotAuthenticated_ExpectFailure(t *testing.T) {
+	type testCase struct {
+		credentials ClientCredentials
+	}
+
+	testCases := map[string]testCase{
+		"noCredentials": {
+			credentials: ClientCredentials{},
+		},
+		"unknownClient": {
+			credentials: ClientCredentials{Id: "other-client"},
+		},
+		"wrongSecret": {
+			credentials: ClientCredentials{Id: "my-client", Secret: "not-my-secret"},
+		},
+		"confidentialClientWithoutSecret": {
+			credentials: ClientCredentials{Id: "my-client"},
+		},
+		"publicClientWithSecret": {
+			credentials: ClientCredentials{Id: "my-public-client", Secret: "my-secret"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			service := newTestOidcService(newTestSigner(t), repositories.Repositories{})
+			request := communication.TokenDtoRequest{
+				GrantType: "authorization_code",
+			}
+
+			_, err := service.Exchange(context.Background(), tc.credentials, request, ClientInfo{})
+
+			assert.True(t, errors.IsErrorWithCode(err, ClientNotAuthenticated), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_OidcService_Exchange_WhenGrantTypeIsNotSupported_ExpectFailure(t *testing.T) {
+	service := newTestOidcService(newTestSigner(t), repositories.Repositories{})
+	request := communication.TokenDtoRequest{
+		GrantType: "password",
+	}
+
+	_, err := service.Exchange(context.Background(), oauthTestClient, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, UnsupportedGrantType), "Actual err: %v", err)
+}
+
+func TestUnit_OidcService_Exchange_WhenCodeIsMalformed_ExpectInvalidGrant(t *testing.T) {
+	service := newTestOidcService(newTestSigner(t), repositories.Repositories{})
+	request := communication.TokenDtoRequest{
+		GrantType: "authorization_code",
+		Code:      "not-a-code",
+	}
+
+	_, err := service.Exchange(context.Background(), oauthTestClient, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidGrant), "Actual err: %v", err)
+}
+
+func TestUnit_OidcService_UserInfo_WhenKeyDoesNotExist_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+	service := newTestOidcService(newTestSigner(t), repositories.Repositories{ApiKey: repo})
+
+	_, err := service.UserInfo(context.Background(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, UserNotAuthenticated), "Actual err: %v", err)
+}
+
+func TestUnit_OidcService_UserInfo_WhenSessionWasNotOpenedByClient_ExpectInsufficientScope(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			Id:         uuid.New(),
+			ApiUser:    uuid.New(),
+			ValidUntil: time.Now().Add(time.Hour),
+		},
+	}
+	service := newTestOidcService(newTestSigner(t), repositories.Repositories{ApiKey: repo})
+
+	_, err := service.UserInfo(context.Background(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, InsufficientScope), "Actual err: %v", err)
+}
+
+func TestUnit_GrantedScope(t *testing.T) {
+	type testCase struct {
+		requested string
+		expected  string
+	}
+
+	testCases := map[string]testCase{
+		"openid": {
+			requested: "openid",
+			expected:  "openid",
+		},
+		"allScopes": {
+			requested: "openid email profile",
+			expected:  "openid email profile",
+		},
+		"unknownScopes": {
+			requested: "email openid offline_access",
+			expected:  "email openid",
+		},
+		"duplicatedScopes": {
+			requested: "openid  openid email",
+			expected:  "openid email",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := grantedScope(tc.requested)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIT_OidcService_AuthorizationCodeFlow(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+
+	code, err := service.Authorize(context.Background(), authorizationTestRequest, newTestLoginRequest(user), "", ClientInfo{})
+	require.Nil(t, err)
+
+	out, err := service.Exchange(context.Background(), oauthTestClient, newTestTokenRequest(code), ClientInfo{})
+	require.Nil(t, err)
+
+	assert.Equal(t, "Bearer", out.TokenType)
+	assert.Equal(t, "openid email", out.Scope)
+	assert.InDelta(t, time.Hour.Seconds(), out.ExpiresIn, 5)
+	claims := decodeTestIdToken(t, out.IdToken)
+	assert.Equal(t, "user-service", claims.Issuer)
+	assert.Equal(t, user.Id.String(), claims.Subject)
+	assert.Equal(t, "my-client", claims.Audience)
+	assert.Equal(t, "my-nonce", claims.Nonce)
+	assert.Equal(t, user.Email, claims.Email)
+	assert.Zero(t, claims.UpdatedAt)
+
+	accessToken, err := apikey.Parse(out.AccessToken)
+	require.Nil(t, err)
+	info, err := service.UserInfo(context.Background(), accessToken)
+	assert.Nil(t, err)
+	expected := communication.UserInfoDtoResponse{
+		Subject: user.Id.String(),
+		Email:   user.Email,
+	}
+	assert.Equal(t, expected, info)
+}
+
+func TestIT_OidcService_Exchange_WhenClientIsPublic_ExpectSuccess(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+	request := authorizationTestRequest
+	request.ClientId = "my-public-client"
+	request.RedirectUri = "http://localhost:3000/callback"
+
+	code, err := service.Authorize(context.Background(), request, newTestLoginRequest(user), "", ClientInfo{})
+	require.Nil(t, err)
+
+	tokenRequest := newTestTokenRequest(code)
+	tokenRequest.RedirectUri = request.RedirectUri
+	_, err = service.Exchange(context.Background(), ClientCredentials{Id: "my-public-client"}, tokenRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+}
+
+func TestIT_OidcService_Authorize_WhenCredentialsAreInvalid_ExpectFailure(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+	login := newTestLoginRequest(user)
+	login.Password = "not-my-password"
+
+	_, err := service.Authorize(context.Background(), authorizationTestRequest, login, "", ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_OidcService_Exchange_WhenCodeIsReused_ExpectInvalidGrant(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+	code, err := service.Authorize(context.Background(), authorizationTestRequest, newTestLoginRequest(user), "", ClientInfo{})
+	require.Nil(t, err)
+	_, err = service.Exchange(context.Background(), oauthTestClient, newTestTokenRequest(code), ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.Exchange(context.Background(), oauthTestClient, newTestTokenRequest(code), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidGrant), "Actual err: %v", err)
+}
+
+func TestIT_OidcService_Exchange_WhenCodeDoesNotMatch_ExpectInvalidGrantAndCodeConsumed(t *testing.T) {
+	type testCase struct {
+		update func(*communication.TokenDtoRequest)
+	}
+
+	testCases := map[string]testCase{
+		"wrongVerifier": {
+			update: func(r *communication.TokenDtoRequest) { r.CodeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXK" },
+		},
+		"noVerifier": {
+			update: func(r *communication.TokenDtoRequest) { r.CodeVerifier = "" },
+		},
+		"wrongRedirectUri": {
+			update: func(r *communication.TokenDtoRequest) { r.RedirectUri = "https://my-client.example.com/other" },
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			service, conn := newTestOidcServiceWithDatabase(t)
+			user := insertTestUser(t, conn)
+			code, err := service.Authorize(context.Background(), authorizationTestRequest, newTestLoginRequest(user), "", ClientInfo{})
+			require.Nil(t, err)
+			request := newTestTokenRequest(code)
+			tc.update(&request)
+
+			_, err = service.Exchange(context.Background(), oauthTestClient, request, ClientInfo{})
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidGrant), "Actual err: %v", err)
+			_, err = service.Exchange(context.Background(), oauthTestClient, newTestTokenRequest(code), ClientInfo{})
+			assert.True(t, errors.IsErrorWithCode(err, InvalidGrant), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestIT_OidcService_Exchange_WhenCodeWasIssuedToAnotherClient_ExpectInvalidGrant(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+	code, err := service.Authorize(context.Background(), authorizationTestRequest, newTestLoginRequest(user), "", ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.Exchange(context.Background(), ClientCredentials{Id: "my-public-client"}, newTestTokenRequest(code), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidGrant), "Actual err: %v", err)
+}
+
+func TestIT_OidcService_UserInfo_WhenProfileScopeIsGranted_ExpectUpdatedAt(t *testing.T) {
+	service, conn := newTestOidcServiceWithDatabase(t)
+	user := insertTestUser(t, conn)
+	request := authorizationTestRequest
+	request.Scope = "openid profile"
+	code, err := service.Authorize(context.Background(), request, newTestLoginRequest(user), "", ClientInfo{})
+	require.Nil(t, err)
+	out, err := service.Exchange(context.Background(), oauthTestClient, newTestTokenRequest(code), ClientInfo{})
+	require.Nil(t, err)
+	accessToken, err := apikey.Parse(out.AccessToken)
+	require.Nil(t, err)
+
+	info, err := service.UserInfo(context.Background(), accessToken)
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id.String(), info.Subject)
+	assert.Empty(t, info.Email)
+	assert.NotZero(t, info.UpdatedAt)
+}
+
+func newTestOidcService(signer jwt.Signer, repos repositories.Repositories) OidcService {
+	config := OidcConfig{
+		Enabled:      true,
+		CodeValidity: time.Minute,
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, signer, nil, nil, nil, nil, nil, nil, repos)
+	return NewOidcService(config, oauthTestConfig, nil, repos, users)
+}
+
+func newTestOidcServiceWithDatabase(t *testing.T) (OidcService, db.Connection) {
+	conn := newTestConnection(t)
+
+	config := OidcConfig{
+		Enabled:      true,
+		CodeValidity: time.Minute,
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		AuthorizationCode:  repositories.NewAuthorizationCodeRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Role:               repositories.NewRoleRepository(conn),
+		RoleAssignment:     repositories.NewRoleAssignmentRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, newTestSigner(t), nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewOidcService(config, oauthTestConfig, nil, repos, users)
+	return service, conn
+}
+
+func newTestTokenRequest(code apikey.AuthorizationCode) communication.TokenDtoRequest {
+	return communication.TokenDtoRequest{
+		GrantType:    "authorization_code",
+		Code:         code.String(),
+		RedirectUri:  authorizationTestRequest.RedirectUri,
+		CodeVerifier: pkceTestVerifier,
+	}
+}
+
+func decodeTestIdToken(t *testing.T, token string) jwt.IdClaims {
+	segments := strings.Split(token, ".")
+	require.Len(t, segments, 3)
+
+	payload, err := base64.RawURLEncoding.DecodeString(segments[1])
+	require.Nil(t, err)
+
+	var out jwt.IdClaims
+	require.Nil(t, json.Unmarshal(payload, &out))
+	return out
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type PasswordResetConfig struct {
+	// TokenValidity is how long users have to choose a new password once
+	// they asked for a reset. It should be kept short.
+	TokenValidity time.Duration
+	// MaxRequests is the number of messages which can be requested for
+	// the same address before the requests are rejected. They are accepted
+	// again once none was made for RequestWindow.
+	MaxRequests   int
+	RequestWindow time.Duration
+	// Url is the page of the frontend where users choose their new
+	// password: the token is appended to it as the token query parameter.
+	// Only the token is sent when it is empty.
+	Url string
+}
+
+func (c PasswordResetConfig) Validate() error {
+	if c.TokenValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidPasswordResetConfiguration, "token validity must be positive")
+	}
+	if c.MaxRequests <= 0 {
+		return errors.NewCodeWithDetails(InvalidPasswordResetConfiguration, "max requests must be positive")
+	}
+	if c.RequestWindow <= 0 {
+		return errors.NewCodeWithDetails(InvalidPasswordResetConfiguration, "request window must be positive")
+	}
+
+	return validateLinkUrl(c.Url, InvalidPasswordResetConfiguration)
+}
+
+// link returns what users should follow to choose a new password.
+func (c PasswordResetConfig) link(token string) string {
+	return linkWithToken(c.Url, token)
+}
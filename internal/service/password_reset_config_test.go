@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_PasswordResetConfig_Validate(t *testing.T) {
+	config := PasswordResetConfig{
+		TokenValidity: 30 * time.Minute,
+		MaxRequests:   5,
+		RequestWindow: 1 * time.Hour,
+		Url:           "https://example.com/reset",
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_PasswordResetConfig_Validate_WhenTokenValidityIsNotPositive_ExpectFailure(t *testing.T) {
+	config := PasswordResetConfig{}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_PasswordResetConfig_Validate_WhenMaxRequestsIsNotPositive_ExpectFailure(t *testing.T) {
+	config := PasswordResetConfig{
+		TokenValidity: 30 * time.Minute,
+		RequestWindow: 1 * time.Hour,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_PasswordResetConfig_Validate_WhenRequestWindowIsNotPositive_ExpectFailure(t *testing.T) {
+	config := PasswordResetConfig{
+		TokenValidity: 30 * time.Minute,
+		MaxRequests:   5,
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_PasswordResetConfig_Validate_WhenUrlIsInvalid_ExpectFailure(t *testing.T) {
+	config := PasswordResetConfig{
+		TokenValidity: 30 * time.Minute,
+		MaxRequests:   5,
+		RequestWindow: 1 * time.Hour,
+		Url:           "/reset",
+	}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_PasswordResetConfig_Link(t *testing.T) {
+	config := PasswordResetConfig{
+		Url: "https://example.com/reset",
+	}
+
+	actual := config.link("usp_live_my-token")
+
+	assert.Equal(t, "https://example.com/reset?token=usp_live_my-token", actual)
+}
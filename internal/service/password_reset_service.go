@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+const passwordResetSubject = "Reset your password"
+
+type PasswordResetService interface {
+	// Request sends a link to choose a new password to the user owning the
+	// email. Nothing tells the caller whether such a user exists: the link
+	// is sent in the background and the requests are limited per address
+	// either way.
+	Request(ctx context.Context, rawEmail string) error
+	// Reset replaces the password of the user the token was sent to and
+	// revokes all their sessions.
+	Reset(ctx context.Context, token apikey.PasswordResetToken, plaintext string) error
+}
+
+type passwordResetServiceImpl struct {
+	config   PasswordResetConfig
+	repo     repositories.PasswordResetRepository
+	users    *userServiceImpl
+	throttle requestThrottle
+	log      *slog.Logger
+}
+
+// NewPasswordResetService creates a service sending the links with the mailer
+// of the users. Failures to send them are only logged: the callers are not
+// told whether the email belongs to a user.
+func NewPasswordResetService(config PasswordResetConfig, repos repositories.Repositories, users *userServiceImpl, log *slog.Logger) PasswordResetService {
+	return &passwordResetServiceImpl{
+		config: config,
+		repo:   repos.PasswordReset,
+		users:  users,
+		throttle: requestThrottle{
+			repo:        repos.LoginThrottle,
+			kind:        persistence.PasswordResetRequestThrottle,
+			maxRequests: config.MaxRequests,
+			window:      config.RequestWindow,
+		},
+		log: log,
+	}
+}
+
+func (s *passwordResetServiceImpl) Request(ctx context.Context, rawEmail string) error {
+	if s.users.mailer == nil {
+		return nil
+	}
+
+	// Invalid emails can't belong to anyone: they are answered like the
+	// unknown ones.
+	address, err := s.users.normalizer.Normalize(rawEmail)
+	if err != nil {
+		return nil
+	}
+
+	err = s.throttle.record(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	// Looking the user up and storing the token take time: doing it in the
+	// background as well answers as fast whether the user exists or not.
+	s.users.background.Go(func() {
+		err := s.sendLink(context.WithoutCancel(ctx), address)
+		if err != nil {
+			s.log.Warn("Failed to send password reset link", slog.Any("error", err))
+		}
+	})
+
+	return nil
+}
+
+// sendLink stores a new token for the user owning the email, if any, and
+// sends them the link holding it. Delivery failures are logged by the
+// mailer.
+func (s *passwordResetServiceImpl) sendLink(ctx context.Context, address string) error {
+	user, err := s.users.userRepo.GetByEmail(ctx, address)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return nil
+		}
+
+		return err
+	}
+
+	token := apikey.GeneratePasswordResetToken()
+	tokenHash, err := s.users.digester.digest(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reset := persistence.PasswordReset{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  tokenHash,
+		CreatedAt:  now,
+		ValidUntil: now.Add(s.config.TokenValidity),
+	}
+
+	err = s.storeReset(ctx, reset)
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: passwordResetSubject,
+		Body: fmt.Sprintf(
+			"You can choose a new password by following the link below:\n\n%s\n\nThe link expires on %s. You can ignore this message if you did not ask to reset your password.\n",
+			s.config.link(token.String()),
+			reset.ValidUntil.UTC().Format(time.RFC1123),
+		),
+	}
+
+	s.users.mailer.Send(ctx, message)
+
+	return nil
+}
+
+func (s *passwordResetServiceImpl) Reset(ctx context.Context, token apikey.PasswordResetToken, plaintext string) error {
+	tokenHashes, err := s.users.digester.candidates(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	reset, err := s.repo.GetForToken(ctx, tokenHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(InvalidPasswordResetToken)
+		}
+
+		return err
+	}
+
+	now := time.Now()
+	if reset.ValidUntil.Before(now) {
+		return errors.NewCode(InvalidPasswordResetToken)
+	}
+
+	user, err := s.users.userRepo.Get(ctx, reset.ApiUser)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(InvalidPasswordResetToken)
+		}
+
+		return err
+	}
+	// The token was sent to an address the user no longer uses.
+	if user.Email != reset.Email {
+		return errors.NewCode(InvalidPasswordResetToken)
+	}
+
+	// The token is only used once the password is accepted: users can try
+	// another one with the same link.
+	if err := s.users.validatePassword(plaintext, reset.Email); err != nil {
+		return err
+	}
+
+	err = s.resetPassword(ctx, reset, user, plaintext, now)
+	if err != nil {
+		return err
+	}
+
+	// Users who got locked out trying to remember their password can log
+	// in right away.
+	return s.users.throttle.reset(ctx, reset.Email)
+}
+
+func (s *passwordResetServiceImpl) storeReset(ctx context.Context, reset persistence.PasswordReset) error {
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	err = s.repo.DeleteExpired(ctx, tx, reset.CreatedAt)
+	if err != nil {
+		return err
+	}
+	err = s.repo.DeleteForUser(ctx, tx, reset.ApiUser)
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Create(ctx, tx, reset)
+	return err
+}
+
+// resetPassword replaces the password and revokes the sessions of the user
+// at once. Whoever knew the previous password is logged out. Everything is
+// verified on locked rows before the first write: the transaction can't be
+// rolled back once it wrote something.
+func (s *passwordResetServiceImpl) resetPassword(ctx context.Context, reset persistence.PasswordReset, user persistence.User, plaintext string, at time.Time) error {
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	err = s.repo.Lock(ctx, tx, reset.Id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(InvalidPasswordResetToken)
+		}
+
+		return err
+	}
+
+	// The user is locked and still in the state in which its email matched
+	// the one the token was sent to. The password is checked before the
+	// token is consumed: it can be used again with another password.
+	hash, err := s.users.replacePassword(ctx, tx, user, plaintext)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Consume(ctx, tx, reset.Id)
+	if err != nil {
+		return err
+	}
+
+	err = s.users.userRepo.ResetPassword(ctx, tx, reset.ApiUser, reset.Email, hash, at)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteForUser(ctx, tx, reset.ApiUser)
+	if err != nil {
+		return err
+	}
+
+	return s.users.apiKeyRepo.DeleteForUser(ctx, tx, reset.ApiUser)
+}
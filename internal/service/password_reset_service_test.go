@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/password"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var passwordResetTestConfig = PasswordResetConfig{
+	TokenValidity: 30 * time.Minute,
+	MaxRequests:   5,
+	RequestWindow: 1 * time.Hour,
+	Url:           "https://example.com/reset-password",
+}
+
+func TestIT_PasswordResetService_Request_ExpectLinkIsSent(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+
+	err := service.Request(context.Background(), user.Email)
+
+	assert.Nil(t, err)
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, user.Email, mailer.messages[0].To)
+	assert.Equal(t, passwordResetSubject, mailer.messages[0].Subject)
+	assert.Contains(t, mailer.messages[0].Body, "https://example.com/reset-password?token=usp_live_")
+}
+
+func TestIT_PasswordResetService_Request_WhenEmailIsUnknown_ExpectNoError(t *testing.T) {
+	_, service, mailer, _ := newTestPasswordResetService(t, passwordResetTestConfig)
+
+	err := service.Request(context.Background(), fmt.Sprintf("not-a-user-%s@example.com", uuid.New()))
+
+	assert.Nil(t, err)
+	assert.Empty(t, mailer.messages)
+}
+
+func TestIT_PasswordResetService_Request_WhenTooManyRequests_ExpectTooManyRequests(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	for range passwordResetTestConfig.MaxRequests {
+		err := service.Request(context.Background(), user.Email)
+		require.Nil(t, err)
+	}
+
+	err := service.Request(context.Background(), user.Email)
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyRequests), "Actual err: %v", err)
+	delay, ok := RetryAfter(err)
+	assert.True(t, ok)
+	assert.Equal(t, passwordResetTestConfig.RequestWindow, delay)
+	assert.Len(t, mailer.messages, passwordResetTestConfig.MaxRequests)
+}
+
+func TestIT_PasswordResetService_Request_WhenTooManyRequestsForUnknownEmail_ExpectTooManyRequests(t *testing.T) {
+	_, service, _, _ := newTestPasswordResetService(t, passwordResetTestConfig)
+	address := fmt.Sprintf("not-a-user-%s@example.com", uuid.New())
+	for range passwordResetTestConfig.MaxRequests {
+		err := service.Request(context.Background(), address)
+		require.Nil(t, err)
+	}
+
+	err := service.Request(context.Background(), address)
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyRequests), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetService_Request_WhenEmailIsInvalid_ExpectNoError(t *testing.T) {
+	_, service, mailer, _ := newTestPasswordResetService(t, passwordResetTestConfig)
+
+	err := service.Request(context.Background(), "not-an-email")
+
+	assert.Nil(t, err)
+	assert.Empty(t, mailer.messages)
+}
+
+func TestIT_PasswordResetService_Reset(t *testing.T) {
+	users, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+
+	err = service.Reset(context.Background(), mailer.lastPasswordResetToken(t, user.Email), "this-is-a-better-password")
+
+	assert.Nil(t, err)
+	assertPasswordForUser(t, conn, user.Id, "this-is-a-better-password")
+	assertApiKeyDoesNotExist(t, conn, key.Id)
+	login := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "this-is-a-better-password",
+	}
+	_, err = openTestSession(t, users, login, ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_PasswordResetService_Reset_WhenTokenIsUnknown_ExpectInvalidToken(t *testing.T) {
+	_, service, _, _ := newTestPasswordResetService(t, passwordResetTestConfig)
+
+	err := service.Reset(context.Background(), apikey.GeneratePasswordResetToken(), "this-is-a-better-password")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetToken), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetService_Reset_WhenTokenWasAlreadyUsed_ExpectInvalidToken(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	token := mailer.lastPasswordResetToken(t, user.Email)
+	err = service.Reset(context.Background(), token, "this-is-a-better-password")
+	require.Nil(t, err)
+
+	err = service.Reset(context.Background(), token, "this-is-another-password")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetToken), "Actual err: %v", err)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_PasswordResetService_Reset_WhenTokenIsExpired_ExpectInvalidToken(t *testing.T) {
+	config := passwordResetTestConfig
+	config.TokenValidity = -1 * time.Minute
+	_, service, mailer, conn := newTestPasswordResetService(t, config)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+
+	err = service.Reset(context.Background(), mailer.lastPasswordResetToken(t, user.Email), "this-is-a-better-password")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetToken), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetService_Reset_WhenAnotherLinkWasRequested_ExpectPreviousLinkStopsWorking(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	previous := mailer.lastPasswordResetToken(t, user.Email)
+	err = service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+
+	err = service.Reset(context.Background(), previous, "this-is-a-better-password")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetToken), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetService_Reset_WhenPasswordIsRejected_ExpectTokenCanBeUsedAgain(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	token := mailer.lastPasswordResetToken(t, user.Email)
+
+	err = service.Reset(context.Background(), token, "short")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, key.Id)
+	err = service.Reset(context.Background(), token, "this-is-a-better-password")
+	assert.Nil(t, err)
+}
+
+func TestIT_PasswordResetService_Reset_WhenEmailChanged_ExpectInvalidToken(t *testing.T) {
+	users, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	token := mailer.lastPasswordResetToken(t, user.Email)
+	update := communication.UserDtoRequest{
+		Email: "other-" + user.Email,
+	}
+	_, err = users.Update(context.Background(), user.Id, update, communication.SelfView)
+	require.Nil(t, err)
+
+	err = service.Reset(context.Background(), token, "this-is-another-password")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPasswordResetToken), "Actual err: %v", err)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_PasswordResetService_Reset_WhenPasswordIsInHistory_ExpectTokenCanBeUsedAgain(t *testing.T) {
+	_, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	err = service.Reset(context.Background(), mailer.lastPasswordResetToken(t, user.Email), "Sup3rSecretPassw0rd-1")
+	require.Nil(t, err)
+	err = service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	token := mailer.lastPasswordResetToken(t, user.Email)
+
+	err = service.Reset(context.Background(), token, "Sup3rSecretPassw0rd-1")
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assert.Equal(t, []password.Rule{password.ReusedPassword}, password.Violations(err))
+	err = service.Reset(context.Background(), token, "Sup3rSecretPassw0rd-2")
+	assert.Nil(t, err)
+}
+
+func TestIT_PasswordResetService_Reset_ExpectPreviousPasswordIsKeptInHistory(t *testing.T) {
+	users, service, mailer, conn := newTestPasswordResetService(t, passwordResetTestConfig)
+	user := insertTestUser(t, conn)
+	err := service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	err = service.Reset(context.Background(), mailer.lastPasswordResetToken(t, user.Email), "Sup3rSecretPassw0rd-1")
+	require.Nil(t, err)
+	err = service.Request(context.Background(), user.Email)
+	require.Nil(t, err)
+	err = service.Reset(context.Background(), mailer.lastPasswordResetToken(t, user.Email), "Sup3rSecretPassw0rd-2")
+	require.Nil(t, err)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	request := newTestPasswordChangeRequest("Sup3rSecretPassw0rd-2", "Sup3rSecretPassw0rd-1")
+	err = users.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assert.Equal(t, []password.Rule{password.ReusedPassword}, password.Violations(err))
+}
+
+func newTestPasswordResetService(t *testing.T, config PasswordResetConfig) (UserService, PasswordResetService, *testMailer, db.Connection) {
+	conn := newTestConnection(t)
+
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		PasswordReset:      repositories.NewPasswordResetRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+	mailer := &testMailer{}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, nil, mailer, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := &waitingPasswordResetService{
+		PasswordResetService: NewPasswordResetService(config, repos, users, slog.New(slog.DiscardHandler)),
+		users:                users,
+	}
+	return users, service, mailer, conn
+}
+
+// waitingPasswordResetService waits for the links to be sent so that the
+// tests can inspect them.
+type waitingPasswordResetService struct {
+	PasswordResetService
+	users *userServiceImpl
+}
+
+func (s *waitingPasswordResetService) Request(ctx context.Context, rawEmail string) error {
+	err := s.PasswordResetService.Request(ctx, rawEmail)
+	s.users.background.Wait()
+	return err
+}
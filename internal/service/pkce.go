@@ -0,0 +1,34 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// Only the S256 method is accepted: plain challenges don't protect the code
+// when the authorization request is intercepted.
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.2
+const codeChallengeMethodS256 = "S256"
+
+// https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+var codeVerifierRegexp = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// A challenge is the base64url encoding of a SHA-256 digest, without padding.
+var codeChallengeRegexp = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+
+func validCodeChallenge(challenge string, method string) bool {
+	return method == codeChallengeMethodS256 && codeChallengeRegexp.MatchString(challenge)
+}
+
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if !codeVerifierRegexp.MatchString(verifier) {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Example from https://datatracker.ietf.org/doc/html/rfc7636#appendix-B
+const (
+	pkceTestVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	pkceTestChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+func TestUnit_ValidCodeChallenge(t *testing.T) {
+	type testCase struct {
+		challenge string
+		method    string
+		expected  bool
+	}
+
+	testCases := map[string]testCase{
+		"s256": {
+			challenge: pkceTestChallenge,
+			method:    "S256",
+			expected:  true,
+		},
+		"plain": {
+			challenge: pkceTestChallenge,
+			method:    "plain",
+			expected:  false,
+		},
+		"noMethod": {
+			challenge: pkceTestChallenge,
+			expected:  false,
+		},
+		"noChallenge": {
+			method:   "S256",
+			expected: false,
+		},
+		"tooShort": {
+			challenge: pkceTestChallenge[:42],
+			method:    "S256",
+			expected:  false,
+		},
+		"notBase64Url": {
+			challenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw+cM",
+			method:    "S256",
+			expected:  false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, validCodeChallenge(tc.challenge, tc.method))
+		})
+	}
+}
+
+func TestUnit_VerifyCodeChallenge(t *testing.T) {
+	type testCase struct {
+		verifier string
+		expected bool
+	}
+
+	testCases := map[string]testCase{
+		"valid": {
+			verifier: pkceTestVerifier,
+			expected: true,
+		},
+		"wrongVerifier": {
+			verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXK",
+			expected: false,
+		},
+		"challengeAsVerifier": {
+			verifier: pkceTestChallenge,
+			expected: false,
+		},
+		"tooShort": {
+			verifier: "short",
+			expected: false,
+		},
+		"noVerifier": {
+			verifier: "",
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, verifyCodeChallenge(tc.verifier, pkceTestChallenge))
+		})
+	}
+}
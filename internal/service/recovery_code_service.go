@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+const (
+	recoveryCodesCount = 10
+	// Each code holds 80 random bits, shown as four groups of four
+	// characters.
+	recoveryCodeBytes     = 10
+	recoveryCodeGroupSize = 4
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type RecoveryCodeService interface {
+	// Generate replaces the recovery codes of the user which were not used
+	// yet. The codes are only returned once.
+	Generate(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.RecoveryCodesDtoResponse, error)
+}
+
+type recoveryCodeServiceImpl struct {
+	users *userServiceImpl
+}
+
+func NewRecoveryCodeService(users *userServiceImpl) RecoveryCodeService {
+	return &recoveryCodeServiceImpl{
+		users: users,
+	}
+}
+
+func (s *recoveryCodeServiceImpl) Generate(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.RecoveryCodesDtoResponse, error) {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return communication.RecoveryCodesDtoResponse{}, err
+	}
+
+	now := time.Now()
+	out := communication.RecoveryCodesDtoResponse{
+		Codes: make([]string, 0, recoveryCodesCount),
+	}
+	codes := make([]persistence.RecoveryCode, 0, recoveryCodesCount)
+	for range recoveryCodesCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return communication.RecoveryCodesDtoResponse{}, err
+		}
+		out.Codes = append(out.Codes, code)
+		codes = append(codes, persistence.RecoveryCode{
+			Id:        uuid.New(),
+			ApiUser:   id,
+			CodeHash:  recoveryCodeDigest(code),
+			CreatedAt: now,
+		})
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return communication.RecoveryCodesDtoResponse{}, err
+	}
+	defer tx.Close(ctx)
+
+	_, err = s.users.recoveryCodeRepo.Replace(ctx, tx, id, codes)
+	if err != nil {
+		return communication.RecoveryCodesDtoResponse{}, err
+	}
+
+	return out, nil
+}
+
+// authenticateWithRecoveryCode logs the user in with one of their recovery
+// codes instead of their password. The code is used even when a second
+// factor is required afterwards, and the user is asked to change their
+// password.
+func (s *userServiceImpl) authenticateWithRecoveryCode(ctx context.Context, rawEmail string, code string, client ClientInfo) (persistence.User, bool, error) {
+	return s.authenticate(ctx, rawEmail, client, func(user *persistence.User) (bool, error) {
+		_, err := s.recoveryCodeRepo.Use(ctx, user.Id, recoveryCodeDigest(code), time.Now(), client.UserAgent, client.Ip)
+		if err != nil {
+			// The code is wrong or was already used.
+			if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		err = s.userRepo.RequirePasswordChange(ctx, user.Id)
+		if err != nil {
+			return false, err
+		}
+		user.PasswordChangeRequired = true
+
+		return true, nil
+	})
+}
+
+// recoveryCodeDigest returns the value persisted in place of the code. The
+// codes are meant to last until used: unlike the digests of the keys, it
+// doesn't depend on a pepper which gets rotated. The codes are random
+// enough for a plain digest to resist guesses, and it lets a login find
+// the code in a single query.
+func recoveryCodeDigest(code string) string {
+	return apikey.Digest(normalizeRecoveryCode(code), "")
+}
+
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+
+	var groups []string
+	for start := 0; start < len(encoded); start += recoveryCodeGroupSize {
+		groups = append(groups, encoded[start:start+recoveryCodeGroupSize])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode makes the codes typed by the users match the ones
+// they were given regardless of the case and of the separators.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
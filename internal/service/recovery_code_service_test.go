@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_GenerateRecoveryCode(t *testing.T) {
+	code, err := generateRecoveryCode()
+
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`), code)
+}
+
+func TestUnit_NormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "k7qm2xvd9pfh3nwa", normalizeRecoveryCode("K7QM-2xvd 9pfh-3NWA"))
+}
+
+func TestUnit_RecoveryCodeDigest_IgnoresCaseAndSeparators(t *testing.T) {
+	assert.Equal(t, recoveryCodeDigest("k7qm-2xvd-9pfh-3nwa"), recoveryCodeDigest("K7QM 2XVD 9PFH 3NWA"))
+	assert.NotEqual(t, recoveryCodeDigest("k7qm-2xvd-9pfh-3nwa"), recoveryCodeDigest("k7qm-2xvd-9pfh-3nwb"))
+}
+
+func TestIT_RecoveryCodeService_Generate(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	out, err := service.Generate(context.Background(), key.Key, user.Id)
+
+	assert.Nil(t, err)
+	assert.Len(t, out.Codes, recoveryCodesCount)
+	assertRecoveryCodesLeft(t, users, user.Id, recoveryCodesCount)
+}
+
+func TestIT_RecoveryCodeService_Generate_WhenKeyBelongsToAnotherUser_ExpectPermissionDenied(t *testing.T) {
+	_, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, other.Id)
+
+	_, err := service.Generate(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_RecoveryCodeService_Generate_ExpectPreviousCodesAreReplaced(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	previous, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+
+	_, err = service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+
+	_, err = users.Login(context.Background(), newTestRecoveryLoginRequest(user, previous.Codes[0]), ClientInfo{})
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	assertRecoveryCodesLeft(t, users, user.Id, recoveryCodesCount)
+}
+
+func TestIT_UserService_Login_WithRecoveryCode(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	client := ClientInfo{
+		Ip:        "192.0.2.1",
+		UserAgent: "my-user-agent",
+	}
+
+	out, err := openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), client)
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, out.User)
+	assert.True(t, out.PasswordChangeRequired)
+	assertRecoveryCodesLeft(t, users, user.Id, recoveryCodesCount-1)
+	assertRecoveryCodeUsedFrom(t, conn, user.Id, client)
+}
+
+func TestIT_UserService_Login_WithRecoveryCode_IgnoresCaseAndSeparators(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	code := strings.ToUpper(strings.ReplaceAll(codes.Codes[0], "-", " "))
+
+	_, err = openTestSession(t, users, newTestRecoveryLoginRequest(user, code), ClientInfo{})
+
+	assert.Nil(t, err)
+}
+
+func TestIT_UserService_Login_WithRecoveryCode_WhenPepperWasRotated_ExpectCodeIsStillValid(t *testing.T) {
+	conn := newTestConnection(t)
+	_, service := newTestRecoveryCodeServiceWithKeyring(t, conn, pepperTestKeys)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	rotated := &mockKeyring{
+		active: map[keyring.Purpose]keyring.Key{
+			keyring.DigestPepper: {Id: "newer-pepper", Material: []byte("newer-pepper")},
+		},
+		accepted: map[keyring.Purpose][]keyring.Key{
+			keyring.DigestPepper: {
+				{Id: "newer-pepper", Material: []byte("newer-pepper")},
+			},
+		},
+	}
+	users, _ := newTestRecoveryCodeServiceWithKeyring(t, conn, rotated)
+
+	_, err = openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+
+	assert.Nil(t, err)
+	assertRecoveryCodesLeft(t, users, user.Id, recoveryCodesCount-1)
+}
+
+func TestIT_UserService_Login_WithRecoveryCode_WhenAlreadyUsed_ExpectInvalidCredentials(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	_, err = openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = users.Login(context.Background(), newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WithRecoveryCodeOfAnotherUser_ExpectInvalidCredentials(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, other.Id)
+	codes, err := service.Generate(context.Background(), key.Key, other.Id)
+	require.Nil(t, err)
+
+	_, err = users.Login(context.Background(), newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	assertRecoveryCodesLeft(t, users, other.Id, recoveryCodesCount)
+}
+
+func TestIT_UserService_Login_WithWrongRecoveryCodes_ExpectThrottled(t *testing.T) {
+	users, _, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+
+	var err error
+	for range loginThrottleTestConfig.FreeAttempts + 1 {
+		_, err = users.Login(context.Background(), newTestRecoveryLoginRequest(user, "not-a-code"), ClientInfo{Ip: "192.0.2.1"})
+	}
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyLoginAttempts), "Actual err: %v", err)
+}
+
+func TestIT_UserService_ChangePassword_ExpectPasswordChangeIsNoLongerRequired(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	session, err := openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+	require.Nil(t, err)
+	apiKey, err := apikey.Parse(session.Key)
+	require.Nil(t, err)
+
+	request := communication.PasswordChangeDtoRequest{
+		NewPassword: "this-is-a-better-password",
+	}
+	err = users.ChangePassword(context.Background(), apiKey, user.Id, request, ClientInfo{})
+
+	assert.Nil(t, err)
+	out, err := users.Get(context.Background(), user.Id, communication.SelfView)
+	require.Nil(t, err)
+	actual, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	assert.False(t, actual.PasswordChangeRequired)
+	view, err := users.ViewOf(context.Background(), apiKey, user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, communication.SelfView, view)
+}
+
+func TestIT_UserService_Login_WithRecoveryCode_ExpectSessionCanOnlyChangePassword(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	session, err := openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+	require.Nil(t, err)
+	apiKey, err := apikey.Parse(session.Key)
+	require.Nil(t, err)
+
+	view, err := users.ViewOf(context.Background(), apiKey, user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, communication.PublicView, view)
+	_, err = service.Generate(context.Background(), apiKey, user.Id)
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+	_, err = users.ListSessions(context.Background(), apiKey, user.Id)
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_UserService_ChangePassword_WhenSessionIsNotRestricted_ExpectCurrentPasswordChecked(t *testing.T) {
+	users, service, conn := newTestRecoveryCodeService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	codes, err := service.Generate(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	_, err = openTestSession(t, users, newTestRecoveryLoginRequest(user, codes.Codes[0]), ClientInfo{})
+	require.Nil(t, err)
+
+	request := communication.PasswordChangeDtoRequest{
+		CurrentPassword: "not-the-password",
+		NewPassword:     "this-is-a-better-password",
+	}
+	err = users.ChangePassword(context.Background(), key.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+}
+
+func newTestRecoveryCodeService(t *testing.T) (UserService, RecoveryCodeService, db.Connection) {
+	conn := newTestConnection(t)
+	users, service := newTestRecoveryCodeServiceWithKeyring(t, conn, nil)
+	return users, service, conn
+}
+
+func newTestRecoveryCodeServiceWithKeyring(t *testing.T, conn db.Connection, ring keyring.Keyring) (UserService, RecoveryCodeService) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, ring, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewRecoveryCodeService(users)
+	return users, service
+}
+
+func newTestRecoveryLoginRequest(user persistence.User, code string) communication.UserDtoRequest {
+	return communication.UserDtoRequest{
+		Email:        user.Email,
+		RecoveryCode: code,
+	}
+}
+
+func assertRecoveryCodesLeft(t *testing.T, users UserService, id uuid.UUID, expected int) {
+	out, err := users.Get(context.Background(), id, communication.SelfView)
+	require.Nil(t, err)
+	self, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	require.Equal(t, expected, self.RecoveryCodesLeft)
+}
+
+func assertRecoveryCodeUsedFrom(t *testing.T, conn db.Connection, user uuid.UUID, client ClientInfo) {
+	sqlQuery := "SELECT ip_address FROM recovery_code WHERE api_user = $1 AND used_at IS NOT NULL AND user_agent = $2"
+	ip, err := db.QueryOne[string](context.Background(), conn, sqlQuery, user, client.UserAgent)
+	require.Nil(t, err)
+	require.Equal(t, client.Ip, ip)
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+)
+
+// requestThrottle limits the number of messages which can be requested for
+// an address, so that the endpoints sending them can't be used to flood an
+// inbox. The addresses are counted whether they belong to a user or not:
+// being throttled does not tell whether the account exists.
+type requestThrottle struct {
+	repo        repositories.LoginThrottleRepository
+	kind        persistence.LoginThrottleKind
+	maxRequests int
+	window      time.Duration
+}
+
+// record counts the request and rejects it when too many were made. The
+// count is incremented and returned in a single statement so that the
+// concurrent requests are all accounted for.
+func (t *requestThrottle) record(ctx context.Context, address string) error {
+	now := time.Now()
+	current, err := t.repo.RecordFailure(ctx, t.kind, throttleKey(address), now, now.Add(-t.window))
+	if err != nil {
+		return err
+	}
+
+	// The rejected requests are counted as well: the window only starts
+	// once they stop.
+	if current.Failures > t.maxRequests {
+		return errors.WrapCode(NewRetryAfterError(t.window), TooManyRequests)
+	}
+
+	return nil
+}
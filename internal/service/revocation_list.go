@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// Revoked tokens are reloaded at most this often: a revoked token can still
+// be accepted for this long after its session was revoked.
+const revocationListRefreshInterval = 10 * time.Second
+
+// revocationList keeps the revoked tokens in memory so that authenticating
+// a signed token does not require a query to the database.
+type revocationList struct {
+	repo            repositories.RevokedTokenRepository
+	refreshInterval time.Duration
+
+	lock     sync.Mutex
+	revoked  map[uuid.UUID]struct{}
+	loadedAt time.Time
+}
+
+func newRevocationList(repo repositories.RevokedTokenRepository) *revocationList {
+	return &revocationList{
+		repo:            repo,
+		refreshInterval: revocationListRefreshInterval,
+	}
+}
+
+func (l *revocationList) contains(ctx context.Context, token uuid.UUID, now time.Time) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.revoked == nil || now.Sub(l.loadedAt) >= l.refreshInterval {
+		ids, err := l.repo.ListActive(ctx, now)
+		if err != nil {
+			return false, err
+		}
+
+		l.revoked = make(map[uuid.UUID]struct{}, len(ids))
+		for _, id := range ids {
+			l.revoked[id] = struct{}{}
+		}
+		l.loadedAt = now
+	}
+
+	_, revoked := l.revoked[token]
+	return revoked, nil
+}
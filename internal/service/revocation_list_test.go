@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockRevokedTokenRepository struct {
+	repositories.RevokedTokenRepository
+
+	revoked []uuid.UUID
+	err     error
+	calls   int
+}
+
+func TestUnit_RevocationList_WhenTokenIsRevoked_ExpectContained(t *testing.T) {
+	token := uuid.New()
+	repo := &mockRevokedTokenRepository{
+		revoked: []uuid.UUID{token},
+	}
+
+	list := newRevocationList(repo)
+	actual, err := list.contains(context.Background(), token, time.Now())
+
+	assert.Nil(t, err)
+	assert.True(t, actual)
+}
+
+func TestUnit_RevocationList_WhenTokenIsNotRevoked_ExpectNotContained(t *testing.T) {
+	repo := &mockRevokedTokenRepository{
+		revoked: []uuid.UUID{uuid.New()},
+	}
+
+	list := newRevocationList(repo)
+	actual, err := list.contains(context.Background(), uuid.New(), time.Now())
+
+	assert.Nil(t, err)
+	assert.False(t, actual)
+}
+
+func TestUnit_RevocationList_WhenCalledWithinInterval_ExpectNotReloaded(t *testing.T) {
+	token := uuid.New()
+	repo := &mockRevokedTokenRepository{}
+	now := time.Now()
+
+	list := newRevocationList(repo)
+	_, err := list.contains(context.Background(), token, now)
+	assert.Nil(t, err)
+	repo.revoked = []uuid.UUID{token}
+	actual, err := list.contains(context.Background(), token, now.Add(revocationListRefreshInterval-time.Second))
+
+	assert.Nil(t, err)
+	assert.False(t, actual)
+	assert.Equal(t, 1, repo.calls)
+}
+
+func TestUnit_RevocationList_WhenIntervalIsElapsed_ExpectReloaded(t *testing.T) {
+	token := uuid.New()
+	repo := &mockRevokedTokenRepository{}
+	now := time.Now()
+
+	list := newRevocationList(repo)
+	_, err := list.contains(context.Background(), token, now)
+	assert.Nil(t, err)
+	repo.revoked = []uuid.UUID{token}
+	actual, err := list.contains(context.Background(), token, now.Add(revocationListRefreshInterval))
+
+	assert.Nil(t, err)
+	assert.True(t, actual)
+	assert.Equal(t, 2, repo.calls)
+}
+
+func TestUnit_RevocationList_WhenLoadingFails_ExpectError(t *testing.T) {
+	repo := &mockRevokedTokenRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	list := newRevocationList(repo)
+	_, err := list.contains(context.Background(), uuid.New(), time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func (m *mockRevokedTokenRepository) ListActive(ctx context.Context, at time.Time) ([]uuid.UUID, error) {
+	m.calls++
+	return m.revoked, m.err
+}
@@ -0,0 +1,423 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+const maxAuthorizationNameLength = 64
+
+// RoleService manages the roles and permissions returned by the
+// authentication endpoint. Only administrators can use it.
+type RoleService interface {
+	CreatePermission(ctx context.Context, apiKey apikey.Key, permissionDto communication.PermissionDtoRequest) (communication.PermissionDtoResponse, error)
+	GetPermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.PermissionDtoResponse, error)
+	ListPermissions(ctx context.Context, apiKey apikey.Key) ([]communication.PermissionDtoResponse, error)
+	UpdatePermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID, permissionDto communication.PermissionDtoRequest) (communication.PermissionDtoResponse, error)
+	DeletePermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error
+
+	CreateRole(ctx context.Context, apiKey apikey.Key, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error)
+	GetRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.RoleDtoResponse, error)
+	ListRoles(ctx context.Context, apiKey apikey.Key) ([]communication.RoleDtoResponse, error)
+	UpdateRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error)
+	DeleteRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error
+
+	// ListAssignedRoles returns the roles assigned to the user, without the
+	// ones they inherit from.
+	ListAssignedRoles(ctx context.Context, apiKey apikey.Key, user uuid.UUID) ([]communication.RoleDtoResponse, error)
+	Assign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error
+	Unassign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error
+}
+
+type roleServiceImpl struct {
+	conn db.Connection
+
+	roleRepo       repositories.RoleRepository
+	permissionRepo repositories.PermissionRepository
+	assignmentRepo repositories.RoleAssignmentRepository
+
+	users *userServiceImpl
+}
+
+func NewRoleService(conn db.Connection, repos repositories.Repositories, users *userServiceImpl) RoleService {
+	return &roleServiceImpl{
+		conn: conn,
+
+		roleRepo:       repos.Role,
+		permissionRepo: repos.Permission,
+		assignmentRepo: repos.RoleAssignment,
+
+		users: users,
+	}
+}
+
+func (s *roleServiceImpl) CreatePermission(ctx context.Context, apiKey apikey.Key, permissionDto communication.PermissionDtoRequest) (communication.PermissionDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	if !isValidAuthorizationName(permissionDto.Name) {
+		return communication.PermissionDtoResponse{}, errors.NewCode(InvalidPermissionName)
+	}
+
+	permission := persistence.Permission{
+		Id:          uuid.New(),
+		Name:        permissionDto.Name,
+		Description: permissionDto.Description,
+		CreatedAt:   time.Now(),
+	}
+	created, err := s.permissionRepo.Create(ctx, permission)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	return communication.ToPermissionDtoResponse(created), nil
+}
+
+func (s *roleServiceImpl) GetPermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.PermissionDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	permission, err := s.permissionRepo.Get(ctx, id)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	return communication.ToPermissionDtoResponse(permission), nil
+}
+
+func (s *roleServiceImpl) ListPermissions(ctx context.Context, apiKey apikey.Key) ([]communication.PermissionDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.permissionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]communication.PermissionDtoResponse, 0, len(permissions))
+	for _, permission := range permissions {
+		out = append(out, communication.ToPermissionDtoResponse(permission))
+	}
+
+	return out, nil
+}
+
+func (s *roleServiceImpl) UpdatePermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID, permissionDto communication.PermissionDtoRequest) (communication.PermissionDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	if !isValidAuthorizationName(permissionDto.Name) {
+		return communication.PermissionDtoResponse{}, errors.NewCode(InvalidPermissionName)
+	}
+
+	permission := persistence.Permission{
+		Id:          id,
+		Name:        permissionDto.Name,
+		Description: permissionDto.Description,
+	}
+	updated, err := s.permissionRepo.Update(ctx, permission)
+	if err != nil {
+		return communication.PermissionDtoResponse{}, err
+	}
+
+	return communication.ToPermissionDtoResponse(updated), nil
+}
+
+func (s *roleServiceImpl) DeletePermission(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	return s.permissionRepo.Delete(ctx, id)
+}
+
+func (s *roleServiceImpl) CreateRole(ctx context.Context, apiKey apikey.Key, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	role := persistence.Role{
+		Id:          uuid.New(),
+		Name:        roleDto.Name,
+		Description: roleDto.Description,
+		Parent:      roleDto.Parent,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.validateRole(ctx, role); err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	permissions, err := s.resolvePermissions(ctx, roleDto.Permissions)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	defer tx.Close(ctx)
+
+	created, err := s.roleRepo.Create(ctx, tx, role)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	if err := s.setPermissions(ctx, tx, created.Id, permissions); err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	return communication.ToRoleDtoResponse(created, permissionNames(permissions)), nil
+}
+
+func (s *roleServiceImpl) GetRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.RoleDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	role, err := s.roleRepo.Get(ctx, id)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	out, err := s.toRoleDtoResponses(ctx, []persistence.Role{role})
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	return out[0], nil
+}
+
+func (s *roleServiceImpl) ListRoles(ctx context.Context, apiKey apikey.Key) ([]communication.RoleDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roleRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toRoleDtoResponses(ctx, roles)
+}
+
+func (s *roleServiceImpl) UpdateRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID, roleDto communication.RoleDtoRequest) (communication.RoleDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	role, err := s.roleRepo.Get(ctx, id)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	role.Name = roleDto.Name
+	role.Description = roleDto.Description
+	role.Parent = roleDto.Parent
+	if err := s.validateRole(ctx, role); err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	permissions, err := s.resolvePermissions(ctx, roleDto.Permissions)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	defer tx.Close(ctx)
+
+	updated, err := s.roleRepo.Update(ctx, tx, role)
+	if err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+	if err := s.setPermissions(ctx, tx, updated.Id, permissions); err != nil {
+		return communication.RoleDtoResponse{}, err
+	}
+
+	return communication.ToRoleDtoResponse(updated, permissionNames(permissions)), nil
+}
+
+func (s *roleServiceImpl) DeleteRole(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	return s.roleRepo.Delete(ctx, id)
+}
+
+func (s *roleServiceImpl) ListAssignedRoles(ctx context.Context, apiKey apikey.Key, user uuid.UUID) ([]communication.RoleDtoResponse, error) {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.users.userRepo.Get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.assignmentRepo.ListRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toRoleDtoResponses(ctx, roles)
+}
+
+func (s *roleServiceImpl) Assign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	assignment := persistence.RoleAssignment{
+		ApiUser:   user,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	_, err = s.assignmentRepo.Create(ctx, assignment)
+	if errors.IsErrorWithCode(err, pgx.ForeignKeyValidation) {
+		// Either the user or the role does not exist.
+		return errors.WrapCode(err, db.NoMatchingRows)
+	}
+
+	return err
+}
+
+func (s *roleServiceImpl) Unassign(ctx context.Context, apiKey apikey.Key, user uuid.UUID, role uuid.UUID) error {
+	err := s.users.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	return s.assignmentRepo.Delete(ctx, user, role)
+}
+
+// validateRole verifies the name of the role and that its parent exists.
+// The parent can't inherit from the role: the hierarchy would hold a cycle.
+func (s *roleServiceImpl) validateRole(ctx context.Context, role persistence.Role) error {
+	if !isValidAuthorizationName(role.Name) || isBuiltInRole(role.Name) {
+		return errors.NewCode(InvalidRoleName)
+	}
+
+	if role.Parent == nil {
+		return nil
+	}
+
+	ancestors, err := s.roleRepo.ListInherited(ctx, []uuid.UUID{*role.Parent})
+	if err != nil {
+		return err
+	}
+	if len(ancestors) == 0 {
+		return errors.NewCode(InvalidRoleParent)
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.Id == role.Id {
+			return errors.NewCode(InvalidRoleParent)
+		}
+	}
+
+	return nil
+}
+
+func (s *roleServiceImpl) resolvePermissions(ctx context.Context, names []string) ([]persistence.Permission, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	permissions, err := s.permissionRepo.ListByName(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		found := slices.ContainsFunc(permissions, func(permission persistence.Permission) bool {
+			return permission.Name == name
+		})
+		if !found {
+			return nil, errors.NewCodeWithDetails(UnknownPermission, name)
+		}
+	}
+
+	return permissions, nil
+}
+
+func (s *roleServiceImpl) setPermissions(ctx context.Context, tx db.Transaction, role uuid.UUID, permissions []persistence.Permission) error {
+	ids := make([]uuid.UUID, 0, len(permissions))
+	for _, permission := range permissions {
+		ids = append(ids, permission.Id)
+	}
+
+	return s.roleRepo.SetPermissions(ctx, tx, role, ids)
+}
+
+func (s *roleServiceImpl) toRoleDtoResponses(ctx context.Context, roles []persistence.Role) ([]communication.RoleDtoResponse, error) {
+	ids := make([]uuid.UUID, 0, len(roles))
+	for _, role := range roles {
+		ids = append(ids, role.Id)
+	}
+
+	granted, err := s.roleRepo.ListPermissions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	permissions := make(map[uuid.UUID][]string)
+	for _, permission := range granted {
+		permissions[permission.Role] = append(permissions[permission.Role], permission.Permission)
+	}
+
+	out := make([]communication.RoleDtoResponse, 0, len(roles))
+	for _, role := range roles {
+		out = append(out, communication.ToRoleDtoResponse(role, permissions[role.Id]))
+	}
+
+	return out, nil
+}
+
+func permissionNames(permissions []persistence.Permission) []string {
+	out := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		out = append(out, permission.Name)
+	}
+
+	return out
+}
+
+// isValidAuthorizationName verifies the name of a role or a permission. The
+// names are returned in lists separated by commas or spaces: they can't
+// contain any.
+func isValidAuthorizationName(name string) bool {
+	if name == "" || len(name) > maxAuthorizationNameLength {
+		return false
+	}
+
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ','
+	})
+}
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRole keeps track of the names of the permissions granted to the role.
+type testRole struct {
+	persistence.Role
+	Permissions []string
+}
+
+func TestUnit_IsValidAuthorizationName(t *testing.T) {
+	type testCase struct {
+		name     string
+		expected bool
+	}
+
+	testCases := map[string]testCase{
+		"simple":     {name: "editor", expected: true},
+		"scoped":     {name: "articles:write", expected: true},
+		"empty":      {name: "", expected: false},
+		"space":      {name: "chief editor", expected: false},
+		"tab":        {name: "chief\teditor", expected: false},
+		"comma":      {name: "editor,admin", expected: false},
+		"tooLong":    {name: string(make([]byte, maxAuthorizationNameLength+1)), expected: false},
+		"longEnough": {name: "a" + string(make([]byte, maxAuthorizationNameLength-1)), expected: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isValidAuthorizationName(tc.name))
+		})
+	}
+}
+
+func TestIT_RoleService_CreatePermission(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	request := communication.PermissionDtoRequest{
+		Name:        "articles:write-" + uuid.NewString(),
+		Description: "Publish and edit articles",
+	}
+
+	actual, err := service.CreatePermission(context.Background(), apiKey, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, request.Name, actual.Name)
+	assert.Equal(t, request.Description, actual.Description)
+	permission, err := repositories.NewPermissionRepository(conn).Get(context.Background(), actual.Id)
+	require.Nil(t, err)
+	assert.Equal(t, request.Name, permission.Name)
+}
+
+func TestIT_RoleService_CreatePermission_WhenNotAnAdministrator_ExpectFailure(t *testing.T) {
+	service, conn, _ := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	request := communication.PermissionDtoRequest{
+		Name: "articles:write-" + uuid.NewString(),
+	}
+
+	_, err := service.CreatePermission(context.Background(), apiKey.Key, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, NotAnAdministrator), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_CreatePermission_WhenNameIsInvalid_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+	request := communication.PermissionDtoRequest{
+		Name: "articles write",
+	}
+
+	_, err := service.CreatePermission(context.Background(), apiKey, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPermissionName), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_CreatePermission_WhenNameIsTaken_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+	request := communication.PermissionDtoRequest{
+		Name: permission.Name,
+	}
+
+	_, err := service.CreatePermission(context.Background(), apiKey, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_UpdatePermission(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+	request := communication.PermissionDtoRequest{
+		Name:        "articles:publish-" + uuid.NewString(),
+		Description: "Publish articles",
+	}
+
+	actual, err := service.UpdatePermission(context.Background(), apiKey, permission.Id, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, permission.Id, actual.Id)
+	assert.Equal(t, request.Name, actual.Name)
+	assert.Equal(t, request.Description, actual.Description)
+}
+
+func TestIT_RoleService_UpdatePermission_WhenPermissionDoesNotExist_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+	request := communication.PermissionDtoRequest{
+		Name: "articles:publish-" + uuid.NewString(),
+	}
+
+	_, err := service.UpdatePermission(context.Background(), apiKey, uuid.New(), request)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_DeletePermission_ExpectRevokedFromRoles(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+	role := insertTestRole(t, conn, nil, permission)
+
+	err := service.DeletePermission(context.Background(), apiKey, permission.Id)
+
+	assert.Nil(t, err)
+	actual, err := service.GetRole(context.Background(), apiKey, role.Id)
+	require.Nil(t, err)
+	assert.Empty(t, actual.Permissions)
+}
+
+func TestIT_RoleService_ListPermissions(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+
+	actual, err := service.ListPermissions(context.Background(), apiKey)
+
+	assert.Nil(t, err)
+	var ids []uuid.UUID
+	for _, p := range actual {
+		ids = append(ids, p.Id)
+	}
+	assert.Contains(t, ids, permission.Id)
+}
+
+func TestIT_RoleService_CreateRole(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	parent := insertTestRole(t, conn, nil)
+	permission1 := insertTestPermission(t, conn)
+	permission2 := insertTestPermission(t, conn)
+	request := communication.RoleDtoRequest{
+		Name:        "editor-" + uuid.NewString(),
+		Description: "Writes articles",
+		Parent:      &parent.Id,
+		Permissions: []string{permission1.Name, permission2.Name},
+	}
+
+	actual, err := service.CreateRole(context.Background(), apiKey, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, request.Name, actual.Name)
+	assert.Equal(t, request.Description, actual.Description)
+	assert.Equal(t, &parent.Id, actual.Parent)
+	assert.ElementsMatch(t, request.Permissions, actual.Permissions)
+	stored, err := service.GetRole(context.Background(), apiKey, actual.Id)
+	require.Nil(t, err)
+	assert.ElementsMatch(t, request.Permissions, stored.Permissions)
+}
+
+func TestIT_RoleService_CreateRole_WhenNameIsBuiltIn_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+
+	for _, name := range []string{UserRole, AdminRole} {
+		request := communication.RoleDtoRequest{
+			Name: name,
+		}
+
+		_, err := service.CreateRole(context.Background(), apiKey, request)
+
+		assert.True(t, errors.IsErrorWithCode(err, InvalidRoleName), "Actual err: %v", err)
+	}
+}
+
+func TestIT_RoleService_CreateRole_WhenPermissionDoesNotExist_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+	request := communication.RoleDtoRequest{
+		Name:        "editor-" + uuid.NewString(),
+		Permissions: []string{permission.Name, "not-a-permission"},
+	}
+
+	_, err := service.CreateRole(context.Background(), apiKey, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownPermission), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_CreateRole_WhenParentDoesNotExist_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+	parent := uuid.New()
+	request := communication.RoleDtoRequest{
+		Name:   "editor-" + uuid.NewString(),
+		Parent: &parent,
+	}
+
+	_, err := service.CreateRole(context.Background(), apiKey, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRoleParent), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_CreateRole_WhenNotAnAdministrator_ExpectFailure(t *testing.T) {
+	service, conn, _ := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	request := communication.RoleDtoRequest{
+		Name: "editor-" + uuid.NewString(),
+	}
+
+	_, err := service.CreateRole(context.Background(), apiKey.Key, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, NotAnAdministrator), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_UpdateRole_ExpectPermissionsReplaced(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission1 := insertTestPermission(t, conn)
+	permission2 := insertTestPermission(t, conn)
+	role := insertTestRole(t, conn, nil, permission1)
+	request := communication.RoleDtoRequest{
+		Name:        role.Name,
+		Description: "Updated role",
+		Permissions: []string{permission2.Name},
+	}
+
+	actual, err := service.UpdateRole(context.Background(), apiKey, role.Id, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "Updated role", actual.Description)
+	assert.Equal(t, []string{permission2.Name}, actual.Permissions)
+	stored, err := service.GetRole(context.Background(), apiKey, role.Id)
+	require.Nil(t, err)
+	assert.Equal(t, []string{permission2.Name}, stored.Permissions)
+}
+
+func TestIT_RoleService_UpdateRole_WhenRoleInheritsFromItself_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	role := insertTestRole(t, conn, nil)
+	request := communication.RoleDtoRequest{
+		Name:   role.Name,
+		Parent: &role.Id,
+	}
+
+	_, err := service.UpdateRole(context.Background(), apiKey, role.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRoleParent), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_UpdateRole_WhenParentInheritsFromRole_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	grandParent := insertTestRole(t, conn, nil)
+	parent := insertTestRole(t, conn, &grandParent.Id)
+	role := insertTestRole(t, conn, &parent.Id)
+	request := communication.RoleDtoRequest{
+		Name:   grandParent.Name,
+		Parent: &role.Id,
+	}
+
+	_, err := service.UpdateRole(context.Background(), apiKey, grandParent.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRoleParent), "Actual err: %v", err)
+	stored, err := service.GetRole(context.Background(), apiKey, grandParent.Id)
+	require.Nil(t, err)
+	assert.Nil(t, stored.Parent)
+}
+
+func TestIT_RoleService_UpdateRole_WhenRoleDoesNotExist_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+	request := communication.RoleDtoRequest{
+		Name: "editor-" + uuid.NewString(),
+	}
+
+	_, err := service.UpdateRole(context.Background(), apiKey, uuid.New(), request)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_DeleteRole(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	role := insertTestRole(t, conn, nil)
+
+	err := service.DeleteRole(context.Background(), apiKey, role.Id)
+
+	assert.Nil(t, err)
+	_, err = service.GetRole(context.Background(), apiKey, role.Id)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_ListRoles(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	permission := insertTestPermission(t, conn)
+	role := insertTestRole(t, conn, nil, permission)
+
+	actual, err := service.ListRoles(context.Background(), apiKey)
+
+	assert.Nil(t, err)
+	var found *communication.RoleDtoResponse
+	for _, r := range actual {
+		if r.Id == role.Id {
+			found = &r
+		}
+	}
+	require.NotNil(t, found)
+	assert.Equal(t, []string{permission.Name}, found.Permissions)
+}
+
+func TestIT_RoleService_Assign(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+
+	err := service.Assign(context.Background(), apiKey, user.Id, role.Id)
+
+	assert.Nil(t, err)
+	actual, err := service.ListAssignedRoles(context.Background(), apiKey, user.Id)
+	require.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, role.Id, actual[0].Id)
+}
+
+func TestIT_RoleService_Assign_WhenRoleDoesNotExist_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+
+	err := service.Assign(context.Background(), apiKey, user.Id, uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_Unassign(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+	assignTestRole(t, conn, user, role)
+
+	err := service.Unassign(context.Background(), apiKey, user.Id, role.Id)
+
+	assert.Nil(t, err)
+	actual, err := service.ListAssignedRoles(context.Background(), apiKey, user.Id)
+	require.Nil(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestIT_RoleService_Unassign_WhenRoleIsNotAssigned_ExpectFailure(t *testing.T) {
+	service, conn, apiKey := newTestRoleServiceForAdministrator(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+
+	err := service.Unassign(context.Background(), apiKey, user.Id, role.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleService_ListAssignedRoles_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	service, _, apiKey := newTestRoleServiceForAdministrator(t)
+
+	_, err := service.ListAssignedRoles(context.Background(), apiKey, uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func newTestRoleServiceForAdministrator(t *testing.T) (RoleService, db.Connection, apikey.Key) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, admin.Id)
+
+	repos := repositories.Repositories{
+		ApiKey:         repositories.NewApiKeyRepository(conn),
+		Permission:     repositories.NewPermissionRepository(conn),
+		Role:           repositories.NewRoleRepository(conn),
+		RoleAssignment: repositories.NewRoleAssignmentRepository(conn),
+		User:           repositories.NewUserRepository(conn),
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	adminConfig := AdminConfig{
+		Users: []uuid.UUID{admin.Id},
+	}
+
+	users := NewUserService(apiKeyConfig, adminConfig, loginThrottleTestConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewRoleService(conn, repos, users)
+	return service, conn, apiKey.Key
+}
+
+func insertTestPermission(t *testing.T, conn db.Connection) persistence.Permission {
+	permission := persistence.Permission{
+		Id:        uuid.New(),
+		Name:      "my-permission-" + uuid.NewString(),
+		CreatedAt: time.Now(),
+	}
+	out, err := repositories.NewPermissionRepository(conn).Create(context.Background(), permission)
+	require.Nil(t, err)
+	return out
+}
+
+func insertTestRole(t *testing.T, conn db.Connection, parent *uuid.UUID, permissions ...persistence.Permission) testRole {
+	repo := repositories.NewRoleRepository(conn)
+	role := persistence.Role{
+		Id:        uuid.New(),
+		Name:      "my-role-" + uuid.NewString(),
+		Parent:    parent,
+		CreatedAt: time.Now(),
+	}
+
+	out := testRole{}
+	var ids []uuid.UUID
+	for _, permission := range permissions {
+		ids = append(ids, permission.Id)
+		out.Permissions = append(out.Permissions, permission.Name)
+	}
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	created, err := repo.Create(context.Background(), tx, role)
+	if err == nil {
+		err = repo.SetPermissions(context.Background(), tx, role.Id, ids)
+	}
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	out.Role = created
+	return out
+}
+
+func assignTestRole(t *testing.T, conn db.Connection, user persistence.User, role testRole) {
+	assignment := persistence.RoleAssignment{
+		ApiUser:   user.Id,
+		Role:      role.Id,
+		CreatedAt: time.Now(),
+	}
+	_, err := repositories.NewRoleAssignmentRepository(conn).Create(context.Background(), assignment)
+	require.Nil(t, err)
+}
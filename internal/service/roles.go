@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"slices"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// Roles are included in the signed tokens so that other services can make
+// authorization decisions without calling this one.
+const (
+	UserRole  = "user"
+	AdminRole = "admin"
+)
+
+func rolesOf(admins []uuid.UUID, session persistence.ApiKey) []string {
+	roles := []string{UserRole}
+	if isAdministrator(admins, session) {
+		roles = append(roles, AdminRole)
+	}
+
+	return roles
+}
+
+// isBuiltInRole holds for the roles which are not stored: they can't be
+// created so that granting them stays a matter of configuration.
+func isBuiltInRole(name string) bool {
+	return name == UserRole || name == AdminRole
+}
+
+// isAdministrator never holds for the sessions opened by OAuth clients:
+// users only delegate their identity to them, not their privileges.
+func isAdministrator(admins []uuid.UUID, session persistence.ApiKey) bool {
+	return session.ClientId == "" && slices.Contains(admins, session.ApiUser)
+}
+
+// roleResolver finds the roles assigned to the users along with all the
+// roles they inherit from, and the permissions they grant.
+type roleResolver struct {
+	roleRepo       repositories.RoleRepository
+	assignmentRepo repositories.RoleAssignmentRepository
+}
+
+// resolve returns the built-in roles followed by the ones of the user, and
+// the sorted permissions they grant.
+func (r roleResolver) resolve(ctx context.Context, user uuid.UUID, builtIn []string) ([]string, []string, error) {
+	roles := slices.Clone(builtIn)
+	permissions := []string{}
+
+	assigned, err := r.assignmentRepo.ListRoles(ctx, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(assigned) == 0 {
+		return roles, permissions, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(assigned))
+	for _, role := range assigned {
+		ids = append(ids, role.Id)
+	}
+	inherited, err := r.roleRepo.ListInherited(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids = ids[:0]
+	for _, role := range inherited {
+		ids = append(ids, role.Id)
+		roles = append(roles, role.Name)
+	}
+	granted, err := r.roleRepo.ListPermissions(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, permission := range granted {
+		permissions = append(permissions, permission.Permission)
+	}
+
+	// Roles can share permissions.
+	slices.Sort(permissions)
+	permissions = slices.Compact(permissions)
+
+	return roles, permissions, nil
+}
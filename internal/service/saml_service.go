@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"slices"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/federation"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// Identities of the users of a tenant are stored with this prefix so that
+// they can't collide with the ones of an OAuth provider with the same name.
+const samlProviderPrefix = "saml:"
+
+type SamlService interface {
+	// Metadata describes the service provider to the identity provider of
+	// the tenant.
+	Metadata(ctx context.Context, tenant string) ([]byte, error)
+	// Start returns the URL of the identity provider of the tenant where
+	// the user should sign in and the relay state to keep in their browser
+	// until the provider answers.
+	Start(ctx context.Context, tenant string) (string, string, error)
+	// Complete opens a session for the user who signed in at the identity
+	// provider of the tenant, or returns a challenge when they enabled a
+	// second factor. The browser state is the relay state returned by Start.
+	Complete(ctx context.Context, tenant string, response communication.SamlResponseDtoRequest, browserState string, client ClientInfo) (communication.LoginDtoResponse, error)
+}
+
+type samlServiceImpl struct {
+	users         *userServiceImpl
+	requestRepo   repositories.SamlRequestRepository
+	assertionRepo repositories.SamlAssertionRepository
+	identities    identityResolver
+
+	config saml.Config
+	sp     *saml.ServiceProvider
+}
+
+func NewSamlService(config saml.Config, sp *saml.ServiceProvider, repos repositories.Repositories, users *userServiceImpl) SamlService {
+	return &samlServiceImpl{
+		users:         users,
+		requestRepo:   repos.SamlRequest,
+		assertionRepo: repos.SamlAssertion,
+		identities:    newIdentityResolver(users, repos.Identity),
+
+		config: config,
+		sp:     sp,
+	}
+}
+
+func (s *samlServiceImpl) Metadata(ctx context.Context, tenantName string) ([]byte, error) {
+	tenant, ok := s.sp.Tenant(tenantName)
+	if !ok {
+		return nil, errors.NewCodeWithDetails(UnknownSamlTenant, tenantName)
+	}
+
+	return tenant.Metadata()
+}
+
+func (s *samlServiceImpl) Start(ctx context.Context, tenantName string) (string, string, error) {
+	tenant, ok := s.sp.Tenant(tenantName)
+	if !ok {
+		return "", "", errors.NewCodeWithDetails(UnknownSamlTenant, tenantName)
+	}
+
+	now := time.Now()
+	relayState := saml.NewRelayState()
+	requestId, redirect, err := tenant.AuthnRequest(relayState, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	relayStateHash, err := s.users.digester.digest(ctx, relayState)
+	if err != nil {
+		return "", "", err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Close(ctx)
+
+	err = s.requestRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	request := persistence.SamlRequest{
+		Id:             uuid.New(),
+		RequestId:      requestId,
+		RelayStateHash: relayStateHash,
+		Tenant:         tenantName,
+		CreatedAt:      now,
+		ValidUntil:     now.Add(s.config.RequestValidity),
+	}
+
+	_, err = s.requestRepo.Create(ctx, tx, request)
+	if err != nil {
+		return "", "", err
+	}
+
+	return redirect, relayState, nil
+}
+
+func (s *samlServiceImpl) Complete(ctx context.Context, tenantName string, response communication.SamlResponseDtoRequest, browserState string, client ClientInfo) (communication.LoginDtoResponse, error) {
+	tenant, ok := s.sp.Tenant(tenantName)
+	if !ok {
+		return communication.LoginDtoResponse{}, errors.NewCodeWithDetails(UnknownSamlTenant, tenantName)
+	}
+
+	// As for the state of OAuth logins, the relay state proves that the
+	// browser posting the response is the one which started the login.
+	if response.RelayState == "" || subtle.ConstantTimeCompare([]byte(response.RelayState), []byte(browserState)) != 1 {
+		return communication.LoginDtoResponse{}, errors.NewCodeWithDetails(InvalidSamlLogin, "relay state mismatch")
+	}
+
+	now := time.Now()
+	assertion, err := tenant.ParseResponse(response.SAMLResponse, now)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	err = s.consumeRequest(ctx, tenant, assertion, response.RelayState, now)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	identity := federation.Identity{
+		Subject:       assertion.Subject,
+		Email:         assertion.Email,
+		EmailVerified: assertion.EmailVerified,
+	}
+	user, err := s.identities.resolve(ctx, samlProviderPrefix+tenantName, identity, tenant.CreateUsers())
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.users.completeDelegatedLogin(ctx, user, client)
+}
+
+// consumeRequest verifies that the assertion answers a pending request of
+// the tenant started by the same browser, and records it so that it can't
+// be used again.
+func (s *samlServiceImpl) consumeRequest(ctx context.Context, tenant *saml.Tenant, assertion saml.Assertion, relayState string, now time.Time) error {
+	relayStateHashes, err := s.users.digester.candidates(ctx, relayState)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	err = s.assertionRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return err
+	}
+
+	record := persistence.SamlAssertion{
+		Id:          uuid.New(),
+		Issuer:      tenant.IdentityProvider(),
+		AssertionId: assertion.Id,
+		ValidUntil:  assertion.ValidUntil,
+	}
+	_, err = s.assertionRepo.Create(ctx, tx, record)
+	if err != nil {
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return errors.NewCode(SamlAssertionReplayed)
+		}
+
+		return err
+	}
+
+	request, err := s.requestRepo.Consume(ctx, tx, assertion.InResponseTo)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCodeWithDetails(InvalidSamlLogin, "unknown request")
+		}
+
+		return err
+	}
+
+	if request.Tenant != tenant.Name() || request.ValidUntil.Before(now) || !slices.Contains(relayStateHashes, request.RelayStateHash) {
+		return errors.NewCode(InvalidSamlLogin)
+	}
+
+	return nil
+}
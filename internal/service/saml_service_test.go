@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/saml"
+	"github.com/Knoblauchpilze/user-service/internal/saml/samltest"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_SamlService_WhenTenantIsUnknown_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service := newTestSamlService(t, idp, repositories.Repositories{})
+
+	_, err := service.Metadata(context.Background(), "not-a-tenant")
+	assert.True(t, errors.IsErrorWithCode(err, UnknownSamlTenant), "Actual err: %v", err)
+
+	_, _, err = service.Start(context.Background(), "not-a-tenant")
+	assert.True(t, errors.IsErrorWithCode(err, UnknownSamlTenant), "Actual err: %v", err)
+
+	response := communication.SamlResponseDtoRequest{RelayState: "my-relay-state"}
+	_, err = service.Complete(context.Background(), "not-a-tenant", response, "my-relay-state", ClientInfo{})
+	assert.True(t, errors.IsErrorWithCode(err, UnknownSamlTenant), "Actual err: %v", err)
+}
+
+func TestUnit_SamlService_Metadata(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service := newTestSamlService(t, idp, repositories.Repositories{})
+
+	actual, err := service.Metadata(context.Background(), "acme")
+
+	assert.Nil(t, err)
+	assert.Contains(t, string(actual), "https://users.example.com/v1/users/saml/acme/acs")
+}
+
+func TestUnit_SamlService_Complete_WhenRelayStateDoesNotMatch_ExpectFailure(t *testing.T) {
+	testCases := map[string]communication.SamlResponseDtoRequest{
+		"noRelayState":       {SAMLResponse: "my-response"},
+		"relayStateMismatch": {SAMLResponse: "my-response", RelayState: "another-relay-state"},
+	}
+
+	for name, response := range testCases {
+		t.Run(name, func(t *testing.T) {
+			idp := samltest.NewIdentityProvider(t)
+			service := newTestSamlService(t, idp, repositories.Repositories{})
+
+			_, err := service.Complete(context.Background(), "acme", response, "my-relay-state", ClientInfo{})
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidSamlLogin), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_SamlService_Complete_WhenResponseIsInvalid_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service := newTestSamlService(t, idp, repositories.Repositories{})
+	response := communication.SamlResponseDtoRequest{
+		SAMLResponse: "not-a-response",
+		RelayState:   "my-relay-state",
+	}
+
+	_, err := service.Complete(context.Background(), "acme", response, "my-relay-state", ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, saml.MalformedResponse), "Actual err: %v", err)
+}
+
+func TestIT_SamlService_WhenUserIsNew_ExpectUserIsCreated(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, conn := newTestSamlServiceWithDatabase(t, idp)
+	email := newTestSamlEmail()
+
+	out := signInWithTestTenant(t, service, idp, "acme", email)
+
+	assertUserExists(t, conn, out.User)
+	assertIdentityLinkedTo(t, conn, "saml:acme", email, out.User)
+}
+
+func TestIT_SamlService_WhenUserIsKnown_ExpectSameUser(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, _ := newTestSamlServiceWithDatabase(t, idp)
+	email := newTestSamlEmail()
+
+	first := signInWithTestTenant(t, service, idp, "acme", email)
+	second := signInWithTestTenant(t, service, idp, "acme", email)
+
+	assert.Equal(t, first.User, second.User)
+	assert.NotEqual(t, first.Key, second.Key)
+}
+
+func TestIT_SamlService_WhenUserEnabledTotp_ExpectChallenge(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, conn := newTestSamlServiceWithDatabase(t, idp)
+	email := newTestSamlEmail()
+	first := signInWithTestTenant(t, service, idp, "acme", email)
+	confirmTestTotp(t, conn, first.User)
+
+	out, err := completeTestSamlLogin(t, service, idp, "acme", email, nil)
+
+	assert.Nil(t, err)
+	assert.Nil(t, out.Session)
+	require.NotNil(t, out.Challenge)
+	assert.Equal(t, []string{TotpMethod}, out.Challenge.Methods)
+}
+
+func TestIT_SamlService_WhenAccountIsLocked_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, conn := newTestSamlServiceWithDatabase(t, idp)
+	email := newTestSamlEmail()
+	signInWithTestTenant(t, service, idp, "acme", email)
+	lockTestAccount(t, conn, email)
+
+	_, err := completeTestSamlLogin(t, service, idp, "acme", email, nil)
+
+	assert.True(t, errors.IsErrorWithCode(err, AccountLocked), "Actual err: %v", err)
+}
+
+func TestIT_SamlService_WhenEmailIsOutsideOfTenantDomains_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, conn := newTestSamlServiceWithDatabase(t, idp)
+	user := insertTestUser(t, conn)
+
+	_, err := completeTestSamlLogin(t, service, idp, "acme", user.Email, nil)
+
+	assert.True(t, errors.IsErrorWithCode(err, UnverifiedFederatedEmail), "Actual err: %v", err)
+}
+
+func TestIT_SamlService_WhenSignUpIsDisabled_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, conn := newTestSamlServiceWithDatabase(t, idp)
+	email := newTestSamlEmail()
+
+	_, err := completeTestSamlLogin(t, service, idp, "acme-closed", email, nil)
+
+	assert.True(t, errors.IsErrorWithCode(err, FederatedSignUpDisabled), "Actual err: %v", err)
+	_, err = repositories.NewUserRepository(conn).GetByEmail(context.Background(), email)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_SamlService_WhenResponseIsReplayed_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, _ := newTestSamlServiceWithDatabase(t, idp)
+	var replayed communication.SamlResponseDtoRequest
+	_, err := completeTestSamlLogin(t, service, idp, "acme", newTestSamlEmail(), &replayed)
+	require.Nil(t, err)
+
+	_, err = service.Complete(context.Background(), "acme", replayed, replayed.RelayState, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, SamlAssertionReplayed), "Actual err: %v", err)
+}
+
+func TestIT_SamlService_WhenRelayStateBelongsToAnotherRequest_ExpectFailure(t *testing.T) {
+	idp := samltest.NewIdentityProvider(t)
+	service, _ := newTestSamlServiceWithDatabase(t, idp)
+	_, otherRelayState, err := service.Start(context.Background(), "acme")
+	require.Nil(t, err)
+	redirect, _, err := service.Start(context.Background(), "acme")
+	require.Nil(t, err)
+	request, _ := samltest.ParseAuthnRequest(t, redirect)
+	response := communication.SamlResponseDtoRequest{
+		SAMLResponse: idp.Encode(t, idp.NewResponse(request, newTestSamlEmail())),
+		RelayState:   otherRelayState,
+	}
+
+	_, err = service.Complete(context.Background(), "acme", response, otherRelayState, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSamlLogin), "Actual err: %v", err)
+}
+
+func newTestSamlEmail() string {
+	return "player-" + uuid.NewString() + "@acme.com"
+}
+
+func newTestSamlServiceProvider(t *testing.T, idp *samltest.IdentityProvider) (saml.Config, *saml.ServiceProvider) {
+	dir := t.TempDir()
+	key, certificate := samltest.NewKeyPair(t)
+	certificateFile, keyFile := samltest.WriteKeyPair(t, dir, key, certificate)
+	metadataFile := idp.WriteMetadata(t, dir)
+
+	config := saml.Config{
+		BaseUrl:         "https://users.example.com/v1/users",
+		CertificateFile: certificateFile,
+		PrivateKeyFile:  keyFile,
+		RequestValidity: time.Minute,
+		Tenants: []saml.TenantConfig{
+			{Name: "acme", MetadataFile: metadataFile, EmailAttribute: "mail", EmailDomains: []string{"acme.com"}, CreateUsers: true},
+			{Name: "acme-closed", MetadataFile: metadataFile, EmailAttribute: "mail", EmailDomains: []string{"acme.com"}},
+		},
+	}
+
+	sp, err := saml.New(config)
+	require.Nil(t, err)
+	return config, sp
+}
+
+func newTestSamlService(t *testing.T, idp *samltest.IdentityProvider, repos repositories.Repositories) SamlService {
+	config, sp := newTestSamlServiceProvider(t, idp)
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), nil, nil, nil, repos)
+	return NewSamlService(config, sp, repos, users)
+}
+
+func newTestSamlServiceWithDatabase(t *testing.T, idp *samltest.IdentityProvider) (SamlService, db.Connection) {
+	conn := newTestConnection(t)
+
+	config, sp := newTestSamlServiceProvider(t, idp)
+	apiKeyConfig := ApiKeyConfig{
+		Validity: time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		Identity:           repositories.NewIdentityRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		SamlAssertion:      repositories.NewSamlAssertionRepository(conn),
+		SamlRequest:        repositories.NewSamlRequestRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewSamlService(config, sp, repos, users)
+	return service, conn
+}
+
+// completeTestSamlLogin signs the user in at the identity provider of the
+// tenant. The response posted by the browser is copied in sent when set.
+func completeTestSamlLogin(t *testing.T, service SamlService, idp *samltest.IdentityProvider, tenant string, email string, sent *communication.SamlResponseDtoRequest) (communication.LoginDtoResponse, error) {
+	redirect, relayState, err := service.Start(context.Background(), tenant)
+	require.Nil(t, err)
+
+	request, query := samltest.ParseAuthnRequest(t, redirect)
+	response := communication.SamlResponseDtoRequest{
+		SAMLResponse: idp.Encode(t, idp.NewResponse(request, email)),
+		RelayState:   query.Get("RelayState"),
+	}
+	if sent != nil {
+		*sent = response
+	}
+
+	return service.Complete(context.Background(), tenant, response, relayState, ClientInfo{})
+}
+
+func signInWithTestTenant(t *testing.T, service SamlService, idp *samltest.IdentityProvider, tenant string, email string) communication.ApiKeyDtoResponse {
+	out, err := completeTestSamlLogin(t, service, idp, tenant, email, nil)
+	require.Nil(t, err)
+	require.NotNil(t, out.Session)
+	return *out.Session
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/totp"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+// Challenges are answered right after the password: they don't need to
+// live long, and a few typos are enough before starting over.
+const (
+	mfaChallengeValidity = 5 * time.Minute
+	maxMfaAttempts       = 5
+)
+
+// The second factors a challenge can be answered with.
+const (
+	// TotpMethod is the code computed by an authenticator app.
+	TotpMethod = "totp"
+	// WebAuthnMethod is a passkey or a security key.
+	WebAuthnMethod = "webauthn"
+)
+
+// hasSecondFactor returns whether the user enabled a second factor.
+func (s *userServiceImpl) hasSecondFactor(ctx context.Context, user uuid.UUID) (bool, error) {
+	methods, err := s.secondFactorMethods(ctx, user)
+	return len(methods) > 0, err
+}
+
+// secondFactorMethods lists the second factors of the user. Users who
+// started enrolling an authenticator app without confirming it can't use it
+// yet.
+func (s *userServiceImpl) secondFactorMethods(ctx context.Context, user uuid.UUID) ([]string, error) {
+	var out []string
+
+	secret, err := s.totpRepo.Get(ctx, user)
+	if err != nil && !errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return nil, err
+	}
+	if err == nil && secret.ConfirmedAt != nil {
+		out = append(out, TotpMethod)
+	}
+
+	credentials, err := s.webAuthnRepo.List(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) > 0 {
+		out = append(out, WebAuthnMethod)
+	}
+
+	return out, nil
+}
+
+func (s *userServiceImpl) issueMfaChallenge(ctx context.Context, user uuid.UUID) (communication.MfaChallengeDtoResponse, error) {
+	methods, err := s.secondFactorMethods(ctx, user)
+	if err != nil {
+		return communication.MfaChallengeDtoResponse{}, err
+	}
+
+	token := apikey.GenerateMfaChallenge()
+	tokenHash, err := s.digester.digest(ctx, token.String())
+	if err != nil {
+		return communication.MfaChallengeDtoResponse{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return communication.MfaChallengeDtoResponse{}, err
+	}
+	defer tx.Close(ctx)
+
+	now := time.Now()
+	err = s.mfaChallengeRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return communication.MfaChallengeDtoResponse{}, err
+	}
+
+	challenge := persistence.MfaChallenge{
+		Id:         uuid.New(),
+		TokenHash:  tokenHash,
+		ApiUser:    user,
+		CreatedAt:  now,
+		ValidUntil: now.Add(mfaChallengeValidity),
+	}
+	_, err = s.mfaChallengeRepo.Create(ctx, tx, challenge)
+	if err != nil {
+		return communication.MfaChallengeDtoResponse{}, err
+	}
+
+	out := communication.MfaChallengeDtoResponse{
+		Challenge:  token.String(),
+		ValidUntil: challenge.ValidUntil,
+		Methods:    methods,
+	}
+	return out, nil
+}
+
+// getMfaChallenge returns the challenge matching the token. Unknown, expired
+// and malformed tokens are all reported the same way.
+func (s *userServiceImpl) getMfaChallenge(ctx context.Context, token string) (persistence.MfaChallenge, error) {
+	parsed, err := apikey.ParseMfaChallenge(token)
+	if err != nil {
+		return persistence.MfaChallenge{}, errors.WrapCode(err, InvalidMfaChallenge)
+	}
+
+	tokenHashes, err := s.digester.candidates(ctx, parsed.String())
+	if err != nil {
+		return persistence.MfaChallenge{}, err
+	}
+
+	challenge, err := s.mfaChallengeRepo.Get(ctx, tokenHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.MfaChallenge{}, errors.NewCode(InvalidMfaChallenge)
+		}
+		return persistence.MfaChallenge{}, err
+	}
+	if !challenge.ValidUntil.After(time.Now()) {
+		return persistence.MfaChallenge{}, errors.NewCode(InvalidMfaChallenge)
+	}
+
+	return challenge, nil
+}
+
+// recordMfaFailure counts the wrong answer and drops the challenge after too
+// many of them: the user has to provide their password again.
+func (s *userServiceImpl) recordMfaFailure(ctx context.Context, id uuid.UUID) error {
+	challenge, err := s.mfaChallengeRepo.RecordFailure(ctx, id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return nil
+		}
+		return err
+	}
+	if challenge.Attempts < maxMfaAttempts {
+		return nil
+	}
+
+	_, err = s.mfaChallengeRepo.Consume(ctx, id)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return nil
+	}
+	return err
+}
+
+// completeMfaLogin opens the session of a challenge answered with a valid
+// second factor.
+func (s *userServiceImpl) completeMfaLogin(ctx context.Context, challenge persistence.MfaChallenge, user persistence.User, client ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	// Consuming the challenge prevents concurrent requests from opening more
+	// than one session with it.
+	_, err := s.mfaChallengeRepo.Consume(ctx, challenge.Id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidMfaChallenge)
+		}
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.throttle.reset(ctx, user.Email)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	session, err := s.openSession(ctx, user.Id, client, oauthGrant{})
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	return session, nil
+}
+
+// ensureOwnAccount verifies that the key belongs to the user: the second
+// factor of a user can't be managed by an administrator nor by the sessions
+// opened for OAuth clients.
+func (s *userServiceImpl) ensureOwnAccount(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	key, valid, err := s.resolveApiKey(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	if !valid || key.ApiUser != id || key.ClientId != "" {
+		return errors.NewCode(PermissionDenied)
+	}
+
+	return nil
+}
+
+// secondFactor verifies the codes of the users who enabled two-factor
+// authentication. The authenticator is nil when the service is not
+// configured for it: the users who enabled it can't log in until it is.
+type secondFactor struct {
+	users         *userServiceImpl
+	authenticator *totp.Authenticator
+}
+
+func newSecondFactor(users *userServiceImpl, authenticator *totp.Authenticator) secondFactor {
+	return secondFactor{
+		users:         users,
+		authenticator: authenticator,
+	}
+}
+
+// verify checks the code of the user and marks it as used. Wrong codes count
+// as failed logins: guessing them is throttled like guessing passwords.
+func (f secondFactor) verify(ctx context.Context, user persistence.User, code string, client ClientInfo) error {
+	secret, err := f.users.totpRepo.Get(ctx, user.Id)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(TotpNotEnrolled)
+		}
+		return err
+	}
+	if secret.ConfirmedAt == nil {
+		return errors.NewCode(TotpNotEnrolled)
+	}
+
+	attempt, err := f.users.throttle.attempt(ctx, user.Email, client.Ip)
+	if err != nil {
+		return err
+	}
+
+	counter, err := f.check(user.Id, secret, code)
+	if err == nil {
+		// Two concurrent requests may accept the same code: only one of
+		// them can move the counter forward.
+		err = f.users.totpRepo.UseCounter(ctx, user.Id, counter)
+		if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+			err = errors.NewCode(InvalidMfaCode)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.users.throttle.forget(ctx, attempt)
+}
+
+// check returns the time step the code was generated for, without marking
+// it as used.
+func (f secondFactor) check(user uuid.UUID, secret persistence.TotpSecret, code string) (int64, error) {
+	if f.authenticator == nil {
+		return 0, errors.NewCode(SecondFactorUnavailable)
+	}
+
+	counter, err := f.authenticator.Verify(user, secret.Secret, code, secret.LastCounter, time.Now())
+	if err != nil {
+		if errors.IsErrorWithCode(err, totp.InvalidCode) {
+			return 0, errors.WrapCode(err, InvalidMfaCode)
+		}
+		return 0, err
+	}
+
+	return counter, nil
+}
@@ -2,10 +2,19 @@ package service
 
 import (
 	"context"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/keyring"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/Knoblauchpilze/user-service/pkg/communication"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
@@ -13,83 +22,214 @@ import (
 )
 
 type UserService interface {
-	Create(ctx context.Context, userDto communication.UserDtoRequest) (communication.UserDtoResponse, error)
-	Get(ctx context.Context, id uuid.UUID) (communication.UserDtoResponse, error)
+	ViewOf(ctx context.Context, apiKey apikey.Key, user uuid.UUID) (communication.UserView, error)
+	Create(ctx context.Context, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error)
+	Get(ctx context.Context, id uuid.UUID, view communication.UserView) (communication.UserDtoResponse, error)
 	List(ctx context.Context) ([]uuid.UUID, error)
-	Update(ctx context.Context, id uuid.UUID, userDto communication.UserDtoRequest) (communication.UserDtoResponse, error)
+	Update(ctx context.Context, id uuid.UUID, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error)
 	Delete(ctx context.Context, id uuid.UUID) error
-	Login(ctx context.Context, userDto communication.UserDtoRequest) (communication.ApiKeyDtoResponse, error)
+	// Login opens a session for the user, or returns a challenge to answer
+	// with a second factor when the user enabled one.
+	Login(ctx context.Context, userDto communication.UserDtoRequest, client ClientInfo) (communication.LoginDtoResponse, error)
+	Refresh(ctx context.Context, token apikey.RefreshToken) (communication.ApiKeyDtoResponse, error)
 	Logout(ctx context.Context, id uuid.UUID) error
+	LogoutSession(ctx context.Context, id uuid.UUID, apiKey apikey.Key) error
+	ListSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.SessionDtoResponse, error)
+	RevokeSession(ctx context.Context, apiKey apikey.Key, id uuid.UUID, session uuid.UUID) error
+	RevokeSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID, keepCurrent bool) error
+	ListLockouts(ctx context.Context, apiKey apikey.Key) ([]communication.AccountLockoutDtoResponse, error)
+	Unlock(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error
+	// ChangePassword replaces the password of the user after verifying the
+	// current one. Every other session of the user is revoked.
+	ChangePassword(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.PasswordChangeDtoRequest, client ClientInfo) error
 }
 
 type userServiceImpl struct {
 	conn db.Connection
 
-	userRepo   repositories.UserRepository
-	apiKeyRepo repositories.ApiKeyRepository
+	userRepo         repositories.UserRepository
+	apiKeyRepo       repositories.ApiKeyRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	totpRepo         repositories.TotpRepository
+	mfaChallengeRepo repositories.MfaChallengeRepository
+	webAuthnRepo     repositories.WebAuthnCredentialRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
 
-	apiKeyValidity time.Duration
+	emailVerificationRepo repositories.EmailVerificationRepository
+	passwordHistoryRepo   repositories.PasswordHistoryRepository
+
+	roles roleResolver
+
+	signer     jwt.Signer
+	mailer     mail.Mailer
+	normalizer email.Normalizer
+	hasher     password.Hasher
+	policy     password.Policy
+	throttle   loginThrottle
+
+	verification VerificationConfig
+
+	apiKeyValidity       time.Duration
+	apiKeyMaxLifetime    time.Duration
+	refreshValidity      time.Duration
+	digester             digester
+	legacyFormatDeadline time.Time
+	maxSessions          int
+	eviction             EvictionPolicy
+	admins               []uuid.UUID
+
+	// background tracks the messages being sent in the background.
+	background sync.WaitGroup
 }
 
-func NewUserService(config ApiKeyConfig, conn db.Connection, repos repositories.Repositories) UserService {
+// NewUserService creates a service which issues signed tokens with the signer
+// when it is not nil and opaque keys otherwise. Digests are computed with the
+// keys of the keyring when there's one. Users are asked to verify their email
+// when a mailer is provided. The other services which authenticate users or
+// open sessions are built on top of the returned service.
+func NewUserService(config ApiKeyConfig, adminConfig AdminConfig, throttleConfig LoginThrottleConfig, verificationConfig VerificationConfig, signer jwt.Signer, ring keyring.Keyring, mailer mail.Mailer, normalizer email.Normalizer, hasher password.Hasher, policy password.Policy, conn db.Connection, repos repositories.Repositories) *userServiceImpl {
 	return &userServiceImpl{
-		conn:       conn,
-		userRepo:   repos.User,
-		apiKeyRepo: repos.ApiKey,
+		conn:             conn,
+		userRepo:         repos.User,
+		apiKeyRepo:       repos.ApiKey,
+		refreshTokenRepo: repos.RefreshToken,
+		totpRepo:         repos.Totp,
+		mfaChallengeRepo: repos.MfaChallenge,
+		webAuthnRepo:     repos.WebAuthnCredential,
+		recoveryCodeRepo: repos.RecoveryCode,
+
+		emailVerificationRepo: repos.EmailVerification,
+		passwordHistoryRepo:   repos.PasswordHistory,
+
+		roles: roleResolver{
+			roleRepo:       repos.Role,
+			assignmentRepo: repos.RoleAssignment,
+		},
 
-		apiKeyValidity: config.Validity,
+		signer:     signer,
+		mailer:     mailer,
+		normalizer: normalizer,
+		hasher:     hasher,
+		policy:     policy,
+		throttle: loginThrottle{
+			repo:   repos.LoginThrottle,
+			config: throttleConfig,
+		},
+
+		verification: verificationConfig,
+
+		apiKeyValidity:    config.Validity,
+		apiKeyMaxLifetime: config.MaxLifetime,
+		refreshValidity:   config.RefreshValidity,
+		digester: digester{
+			keyring:      ring,
+			legacyPepper: config.Pepper,
+		},
+		legacyFormatDeadline: config.legacyFormatDeadline(),
+		maxSessions:          config.MaxPerUser,
+		eviction:             config.Eviction,
+		admins:               adminConfig.Users,
 	}
 }
 
-func (s *userServiceImpl) Create(ctx context.Context, userDto communication.UserDtoRequest) (communication.UserDtoResponse, error) {
+func (s *userServiceImpl) ViewOf(ctx context.Context, apiKey apikey.Key, user uuid.UUID) (communication.UserView, error) {
+	key, valid, err := s.resolveApiKey(ctx, apiKey)
+	if err != nil || !valid {
+		return communication.PublicView, err
+	}
+
+	if isAdministrator(s.admins, key) {
+		return communication.AdminView, nil
+	}
+	if key.ApiUser == user {
+		return communication.SelfView, nil
+	}
+
+	return communication.PublicView, nil
+}
+
+func (s *userServiceImpl) Create(ctx context.Context, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error) {
 	user := communication.FromUserDtoRequest(userDto)
 
-	if user.Email == "" {
-		return communication.UserDtoResponse{}, errors.NewCode(InvalidEmail)
+	normalized, err := s.normalizeEmail(user.Email)
+	if err != nil {
+		return nil, err
 	}
-	if user.Password == "" {
-		return communication.UserDtoResponse{}, errors.NewCode(InvalidPassword)
+	user.Email = normalized
+
+	if err := s.validatePassword(user.Password, user.Email); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.hasher.Hash(user.Password)
+	if err != nil {
+		return nil, err
 	}
+	user.Password = hash
 
 	createdUser, err := s.userRepo.Create(ctx, user)
 	if err != nil {
-		return communication.UserDtoResponse{}, err
+		return nil, err
 	}
 
-	out := communication.ToUserDtoResponse(createdUser)
-	return out, nil
+	// The account exists even if the link couldn't be sent: users can ask
+	// for a new one.
+	s.sendVerification(ctx, createdUser)
+
+	return s.toUserDtoResponse(ctx, createdUser, view)
 }
 
-func (s *userServiceImpl) Get(ctx context.Context, id uuid.UUID) (communication.UserDtoResponse, error) {
+func (s *userServiceImpl) Get(ctx context.Context, id uuid.UUID, view communication.UserView) (communication.UserDtoResponse, error) {
 	user, err := s.userRepo.Get(ctx, id)
 	if err != nil {
-		return communication.UserDtoResponse{}, err
+		return nil, err
 	}
 
-	out := communication.ToUserDtoResponse(user)
-	return out, nil
+	return s.toUserDtoResponse(ctx, user, view)
 }
 
 func (s *userServiceImpl) List(ctx context.Context) ([]uuid.UUID, error) {
 	return s.userRepo.List(ctx)
 }
 
-func (s *userServiceImpl) Update(ctx context.Context, id uuid.UUID, userDto communication.UserDtoRequest) (communication.UserDtoResponse, error) {
+func (s *userServiceImpl) Update(ctx context.Context, id uuid.UUID, userDto communication.UserDtoRequest, view communication.UserView) (communication.UserDtoResponse, error) {
+	// Pointing the account at another email is enough to take it over with
+	// a password reset: only the user and administrators can do it.
+	if view == communication.PublicView {
+		return nil, errors.NewCode(PermissionDenied)
+	}
+	// The password can only be changed with ChangePassword, which verifies
+	// the current one and keeps track of the previous ones.
+	if userDto.Password != "" {
+		return nil, errors.NewCode(PasswordNotUpdatable)
+	}
+
 	user, err := s.userRepo.Get(ctx, id)
 	if err != nil {
-		return communication.UserDtoResponse{}, err
+		return nil, err
 	}
 
-	user.Email = userDto.Email
-	user.Password = userDto.Password
+	normalized, err := s.normalizeEmail(userDto.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	emailChanged := user.Email != normalized
+	if emailChanged {
+		user.EmailVerifiedAt = nil
+	}
+	user.Email = normalized
 
 	updated, err := s.userRepo.Update(ctx, user)
 	if err != nil {
-		return communication.UserDtoResponse{}, err
+		return nil, err
 	}
 
-	out := communication.ToUserDtoResponse(updated)
-	return out, nil
+	if emailChanged {
+		s.sendVerification(ctx, updated)
+	}
+
+	return s.toUserDtoResponse(ctx, updated, view)
 }
 
 func (s *userServiceImpl) Delete(ctx context.Context, id uuid.UUID) error {
@@ -111,29 +251,95 @@ func (s *userServiceImpl) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (s *userServiceImpl) Login(ctx context.Context, user communication.UserDtoRequest) (communication.ApiKeyDtoResponse, error) {
-	dbUser, err := s.userRepo.GetByEmail(ctx, user.Email)
+func (s *userServiceImpl) Login(ctx context.Context, user communication.UserDtoRequest, client ClientInfo) (communication.LoginDtoResponse, error) {
+	var dbUser persistence.User
+	var secondFactor bool
+	var err error
+	if user.RecoveryCode != "" {
+		dbUser, secondFactor, err = s.authenticateWithRecoveryCode(ctx, user.Email, user.RecoveryCode, client)
+	} else {
+		dbUser, secondFactor, err = s.authenticateUser(ctx, user.Email, user.Password, client)
+	}
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.openSessionOrChallenge(ctx, dbUser, secondFactor, client)
+}
+
+func (s *userServiceImpl) Refresh(ctx context.Context, token apikey.RefreshToken) (communication.ApiKeyDtoResponse, error) {
+	tx, err := s.conn.BeginTx(ctx)
 	if err != nil {
 		return communication.ApiKeyDtoResponse{}, err
 	}
+	defer tx.Close(ctx)
 
-	if user.Password != dbUser.Password {
-		return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidCredentials)
+	tokenHashes, err := s.digester.candidates(ctx, token.String())
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
 	}
 
-	apiKey := persistence.ApiKey{
-		Id:         uuid.New(),
-		Key:        uuid.New(),
-		ApiUser:    dbUser.Id,
-		ValidUntil: time.Now().Add(s.apiKeyValidity),
+	refreshToken, err := s.refreshTokenRepo.LockForToken(ctx, tx, tokenHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidRefreshToken)
+		}
+
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	if refreshToken.UsedAt != nil {
+		// Either the client or an attacker holds a stolen copy of the token:
+		// revoking the session locks both of them out.
+		err = s.apiKeyRepo.Delete(ctx, tx, refreshToken.ApiKey)
+		if err != nil {
+			return communication.ApiKeyDtoResponse{}, err
+		}
+
+		return communication.ApiKeyDtoResponse{}, errors.NewCode(RefreshTokenReused)
+	}
+
+	now := time.Now()
+	if refreshToken.ValidUntil.Before(now) {
+		return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidRefreshToken)
 	}
 
-	createdKey, err := s.apiKeyRepo.Create(ctx, apiKey)
+	session, err := s.apiKeyRepo.Get(ctx, refreshToken.ApiKey)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	// Refreshing does not reset the lifetime of the session: once it is
+	// exhausted the user has to log in again.
+	if s.apiKeyMaxLifetime != 0 && !now.Before(session.CreatedAt.Add(s.apiKeyMaxLifetime)) {
+		return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidRefreshToken)
+	}
+
+	err = s.refreshTokenRepo.MarkUsed(ctx, tx, refreshToken.Id, now)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	session.ValidUntil = sessionValidUntil(session.CreatedAt, now, s.apiKeyValidity, s.apiKeyMaxLifetime)
+	key, err := s.issueKey(ctx, &session, now)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.apiKeyRepo.Rotate(ctx, tx, session.Id, session.KeyHash, session.TokenId, session.ValidUntil)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	out := communication.ToApiKeyDtoResponse(session, key)
+
+	// The new token expires with the one it replaces: users still have to
+	// log in again from time to time.
+	err = s.issueRefreshToken(ctx, tx, session.Id, refreshToken.ValidUntil, &out)
 	if err != nil {
 		return communication.ApiKeyDtoResponse{}, err
 	}
 
-	out := communication.ToApiKeyDtoResponse(createdKey)
 	return out, nil
 }
 
@@ -151,3 +357,758 @@ func (s *userServiceImpl) Logout(ctx context.Context, id uuid.UUID) error {
 
 	return s.apiKeyRepo.DeleteForUser(ctx, tx, id)
 }
+
+func (s *userServiceImpl) LogoutSession(ctx context.Context, id uuid.UUID, apiKey apikey.Key) error {
+	keyHashes, err := s.digester.candidates(ctx, apiKey.String())
+	if err != nil {
+		return err
+	}
+
+	key, err := s.apiKeyRepo.GetForKey(ctx, keyHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(SessionNotFound)
+		}
+
+		return err
+	}
+	if key.ApiUser != id {
+		return errors.NewCode(SessionNotFound)
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	return s.apiKeyRepo.Delete(ctx, tx, key.Id)
+}
+
+func (s *userServiceImpl) ListSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.SessionDtoResponse, error) {
+	current, err := s.ensureCanManageSessions(ctx, apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.apiKeyRepo.ListForUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refreshable, err := s.refreshTokenRepo.ListRefreshableSessions(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]communication.SessionDtoResponse, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ValidUntil.Before(now) && !slices.Contains(refreshable, session.Id) {
+			continue
+		}
+
+		out = append(out, communication.ToSessionDtoResponse(session, session.Id == current.Id))
+	}
+
+	return out, nil
+}
+
+func (s *userServiceImpl) RevokeSession(ctx context.Context, apiKey apikey.Key, id uuid.UUID, session uuid.UUID) error {
+	_, err := s.ensureCanManageSessions(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.apiKeyRepo.Get(ctx, session)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(SessionNotFound)
+		}
+
+		return err
+	}
+	if key.ApiUser != id {
+		return errors.NewCode(SessionNotFound)
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	return s.apiKeyRepo.Delete(ctx, tx, key.Id)
+}
+
+func (s *userServiceImpl) RevokeSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID, keepCurrent bool) error {
+	current, err := s.ensureCanManageSessions(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	if keepCurrent {
+		return s.apiKeyRepo.DeleteForUserExcept(ctx, tx, id, current.Id)
+	}
+
+	return s.apiKeyRepo.DeleteForUser(ctx, tx, id)
+}
+
+func (s *userServiceImpl) ListLockouts(ctx context.Context, apiKey apikey.Key) ([]communication.AccountLockoutDtoResponse, error) {
+	err := s.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	lockouts, err := s.throttle.repo.ListLockedAccounts(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]communication.AccountLockoutDtoResponse, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		out = append(out, communication.ToAccountLockoutDtoResponse(lockout))
+	}
+
+	return out, nil
+}
+
+func (s *userServiceImpl) Unlock(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	err := s.ensureAdministrator(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.throttle.reset(ctx, user.Email)
+}
+
+func (s *userServiceImpl) ChangePassword(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.PasswordChangeDtoRequest, client ClientInfo) error {
+	// This is the only thing the sessions opened with a recovery code can
+	// be used for.
+	key, valid, err := s.lookupApiKey(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	if !valid || key.ApiUser != id || key.ClientId != "" {
+		return errors.NewCode(PermissionDenied)
+	}
+
+	user, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Users who logged in with a recovery code lost their password: the
+	// code replaces it.
+	recovering := key.PasswordChangeRequired && user.PasswordChangeRequired
+	if !recovering {
+		err = s.verifyCurrentPassword(ctx, user, request.CurrentPassword, client)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.validatePassword(request.NewPassword, user.Email); err != nil {
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	hash, err := s.replacePassword(ctx, tx, user, request.NewPassword)
+	if err != nil {
+		return err
+	}
+	err = s.userRepo.ChangePassword(ctx, tx, id, user.Password, hash)
+	if err != nil {
+		return err
+	}
+	err = s.apiKeyRepo.DeleteForUserExcept(ctx, tx, id, key.Id)
+	if err != nil {
+		return err
+	}
+	err = s.apiKeyRepo.ClearPasswordChangeRequired(ctx, tx, key.Id)
+	if err != nil {
+		return err
+	}
+
+	return s.throttle.reset(ctx, user.Email)
+}
+
+// verifyCurrentPassword makes sure that the password is known before it is
+// changed. A stolen session should not be enough to find it out: the guesses
+// are throttled as for a login.
+func (s *userServiceImpl) verifyCurrentPassword(ctx context.Context, user persistence.User, current string, client ClientInfo) error {
+	attempt, err := s.throttle.attempt(ctx, user.Email, client.Ip)
+	if err != nil {
+		return err
+	}
+	match, err := s.hasher.Verify(current, user.Password)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return errors.NewCode(InvalidCredentials)
+	}
+
+	return s.throttle.forget(ctx, attempt)
+}
+
+// authenticateUser verifies the credentials of the user and returns whether
+// a second factor is required as well.
+func (s *userServiceImpl) authenticateUser(ctx context.Context, rawEmail string, rawPassword string, client ClientInfo) (persistence.User, bool, error) {
+	return s.authenticate(ctx, rawEmail, client, func(user *persistence.User) (bool, error) {
+		match, err := s.hasher.Verify(rawPassword, user.Password)
+		if err != nil || !match {
+			return false, err
+		}
+
+		if s.hasher.NeedsRehash(user.Password) {
+			err = s.rehashPassword(ctx, *user, rawPassword)
+		}
+		return true, err
+	})
+}
+
+// authenticate looks up the user and verifies their credentials with the
+// check. Attempts are counted as failures until they succeed so that
+// guessing credentials is throttled; the previous failures are only
+// forgotten once the second factor is verified, if any.
+func (s *userServiceImpl) authenticate(ctx context.Context, rawEmail string, client ClientInfo, check func(user *persistence.User) (bool, error)) (persistence.User, bool, error) {
+	address, err := s.normalizer.Normalize(rawEmail)
+	if err != nil {
+		// Accounts created before emails were validated may not hold a
+		// valid address: they can still log in with it.
+		address = strings.TrimSpace(rawEmail)
+	}
+
+	attempt, err := s.throttle.attempt(ctx, address, client.Ip)
+	if err != nil {
+		return persistence.User{}, false, err
+	}
+
+	// Guesses on unknown accounts count as well: this prevents an attacker
+	// from spraying emails from the same address. They are answered like
+	// wrong credentials so that the caller can't tell who is registered.
+	dbUser, err := s.userRepo.GetByEmail(ctx, address)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.User{}, false, errors.NewCode(InvalidCredentials)
+		}
+		return persistence.User{}, false, err
+	}
+
+	match, err := check(&dbUser)
+	if err != nil {
+		return persistence.User{}, false, err
+	}
+	if !match {
+		return persistence.User{}, false, errors.NewCode(InvalidCredentials)
+	}
+
+	err = s.throttle.forget(ctx, attempt)
+	if err != nil {
+		return persistence.User{}, false, err
+	}
+
+	if s.verification.Required && dbUser.EmailVerifiedAt == nil {
+		// The previous link may have expired: a new one is sent so that
+		// the user has a way forward.
+		s.sendVerification(ctx, dbUser)
+		return persistence.User{}, false, errors.NewCode(EmailNotVerified)
+	}
+
+	secondFactor, err := s.hasSecondFactor(ctx, dbUser.Id)
+	if err != nil {
+		return persistence.User{}, false, err
+	}
+	if !secondFactor {
+		err = s.throttle.reset(ctx, address)
+		if err != nil {
+			return persistence.User{}, false, err
+		}
+	}
+
+	return dbUser, secondFactor, nil
+}
+
+// openSessionOrChallenge opens a session for the authenticated user, or
+// returns a challenge to answer with their second factor first.
+func (s *userServiceImpl) openSessionOrChallenge(ctx context.Context, user persistence.User, secondFactor bool, client ClientInfo) (communication.LoginDtoResponse, error) {
+	if secondFactor {
+		challenge, err := s.issueMfaChallenge(ctx, user.Id)
+		if err != nil {
+			return communication.LoginDtoResponse{}, err
+		}
+		return communication.LoginDtoResponse{Challenge: &challenge}, nil
+	}
+
+	session, err := s.openSession(ctx, user.Id, client, oauthGrant{})
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+	return communication.LoginDtoResponse{Session: &session}, nil
+}
+
+// completeDelegatedLogin opens a session for a user authenticated by an
+// identity provider, or returns a challenge: the provider only vouches for
+// the identity, the second factor is still required. Locked accounts are
+// rejected as for the other logins.
+func (s *userServiceImpl) completeDelegatedLogin(ctx context.Context, id uuid.UUID, client ClientInfo) (communication.LoginDtoResponse, error) {
+	user, err := s.userRepo.Get(ctx, id)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	err = s.throttle.ensureNotLocked(ctx, user.Email)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	secondFactor, err := s.hasSecondFactor(ctx, user.Id)
+	if err != nil {
+		return communication.LoginDtoResponse{}, err
+	}
+
+	return s.openSessionOrChallenge(ctx, user, secondFactor, client)
+}
+
+// oauthGrant describes what an OAuth client was allowed to access. It is
+// empty for the sessions opened by the login endpoint.
+type oauthGrant struct {
+	clientId string
+	scope    string
+}
+
+// openSession creates a new session for the user. Refresh tokens are only
+// issued to the sessions opened by the login endpoint. While the user has to
+// change their password, the session can't be used for anything else.
+func (s *userServiceImpl) openSession(ctx context.Context, user uuid.UUID, client ClientInfo, grant oauthGrant) (communication.ApiKeyDtoResponse, error) {
+	account, err := s.userRepo.Get(ctx, user)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	defer tx.Close(ctx)
+
+	err = s.makeRoomForSession(ctx, tx, user)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	now := time.Now()
+	apiKey := persistence.ApiKey{
+		Id:         uuid.New(),
+		ApiUser:    user,
+		UserAgent:  client.truncatedUserAgent(),
+		IpAddress:  client.Ip,
+		ClientId:   grant.clientId,
+		Scope:      grant.scope,
+		CreatedAt:  now,
+		ValidUntil: sessionValidUntil(now, now, s.apiKeyValidity, s.apiKeyMaxLifetime),
+
+		PasswordChangeRequired: account.PasswordChangeRequired,
+	}
+
+	key, err := s.issueKey(ctx, &apiKey, now)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	createdKey, err := s.apiKeyRepo.Create(ctx, tx, apiKey)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	// This is the only time the key is available in clear.
+	out := communication.ToApiKeyDtoResponse(createdKey, key)
+
+	if s.refreshValidity > 0 && grant.clientId == "" {
+		err = s.issueRefreshToken(ctx, tx, createdKey.Id, now.Add(s.refreshValidity), &out)
+		if err != nil {
+			return communication.ApiKeyDtoResponse{}, err
+		}
+	}
+
+	return out, nil
+}
+
+// makeRoomForSession drops the expired sessions of the user and, when the
+// maximum number of sessions is reached, either evicts the oldest ones or
+// refuses the new session depending on the configuration. Sessions which can
+// still be refreshed are not considered expired.
+func (s *userServiceImpl) makeRoomForSession(ctx context.Context, tx db.Transaction, user uuid.UUID) error {
+	sessions, err := s.apiKeyRepo.LockForUser(ctx, tx, user)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	refreshable, err := s.refreshTokenRepo.ListRefreshableSessions(ctx, user, now)
+	if err != nil {
+		return err
+	}
+
+	var active []persistence.ApiKey
+	for _, session := range sessions {
+		if session.ValidUntil.After(now) || slices.Contains(refreshable, session.Id) {
+			active = append(active, session)
+			continue
+		}
+
+		err = s.apiKeyRepo.Delete(ctx, tx, session.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.maxSessions <= 0 || len(active) < s.maxSessions {
+		return nil
+	}
+	if s.eviction == RejectNew {
+		return errors.NewCode(TooManySessions)
+	}
+
+	// Sessions are sorted from the oldest to the most recent one.
+	for _, session := range active[:len(active)-s.maxSessions+1] {
+		err = s.apiKeyRepo.Delete(ctx, tx, session.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// issueKey generates a new key for the session and returns it in clear: the
+// session is updated with its digest. When tokens are enabled the key is a
+// signed token expiring with the session.
+func (s *userServiceImpl) issueKey(ctx context.Context, session *persistence.ApiKey, now time.Time) (string, error) {
+	// Signed tokens are accepted without looking the session up: the ones
+	// which are restricted to changing the password get an opaque key.
+	if s.signer == nil || session.PasswordChangeRequired {
+		key := apikey.Generate()
+		keyHash, err := s.digester.digest(ctx, key.String())
+		if err != nil {
+			return "", err
+		}
+
+		session.KeyHash = keyHash
+		session.TokenId = nil
+		return key.String(), nil
+	}
+
+	// The token carries the roles of the user so that verifying it does not
+	// query the database: changes to them apply once it is refreshed. The
+	// OAuth clients don't get the assigned roles.
+	roles := rolesOf(s.admins, *session)
+	var permissions []string
+	if session.ClientId == "" {
+		var err error
+		roles, permissions, err = s.roles.resolve(ctx, session.ApiUser, roles)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tokenId := uuid.New()
+	claims := jwt.Claims{
+		Id:          tokenId.String(),
+		Subject:     session.ApiUser.String(),
+		Session:     session.Id.String(),
+		Roles:       roles,
+		Permissions: permissions,
+		ClientId:    session.ClientId,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   session.ValidUntil.Unix(),
+	}
+
+	token, err := s.signer.Sign(ctx, claims)
+	if err != nil {
+		return "", err
+	}
+
+	// The token is also accepted by the endpoints which look keys up in the
+	// database: its digest is stored as any other key.
+	keyHash, err := s.digester.digest(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	session.KeyHash = keyHash
+	session.TokenId = &tokenId
+	return token, nil
+}
+
+// issueRefreshToken creates a new refresh token for the session and adds it
+// to the response, which is the only time it is available in clear.
+func (s *userServiceImpl) issueRefreshToken(ctx context.Context, tx db.Transaction, session uuid.UUID, validUntil time.Time, out *communication.ApiKeyDtoResponse) error {
+	token := apikey.GenerateRefreshToken()
+	tokenHash, err := s.digester.digest(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	refreshToken := persistence.RefreshToken{
+		Id:         uuid.New(),
+		TokenHash:  tokenHash,
+		ApiKey:     session,
+		CreatedAt:  time.Now(),
+		ValidUntil: validUntil,
+	}
+
+	_, err = s.refreshTokenRepo.Create(ctx, tx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	out.RefreshToken = token.String()
+	out.RefreshTokenValidUntil = &refreshToken.ValidUntil
+	return nil
+}
+
+// resolveApiKey returns the session attached to the key. The boolean is
+// false when the key is unknown, expired, in a format which is no longer
+// accepted or restricted to changing the password.
+func (s *userServiceImpl) resolveApiKey(ctx context.Context, apiKey apikey.Key) (persistence.ApiKey, bool, error) {
+	key, valid, err := s.lookupApiKey(ctx, apiKey)
+	if err != nil || !valid {
+		return key, valid, err
+	}
+	// The session can only be used to change the password.
+	if key.PasswordChangeRequired {
+		return persistence.ApiKey{}, false, nil
+	}
+
+	return key, true, nil
+}
+
+// lookupApiKey returns the session attached to the key, including the ones
+// which can only be used to change the password.
+func (s *userServiceImpl) lookupApiKey(ctx context.Context, apiKey apikey.Key) (persistence.ApiKey, bool, error) {
+	now := time.Now()
+	if apiKey.Legacy() && !now.Before(s.legacyFormatDeadline) {
+		return persistence.ApiKey{}, false, nil
+	}
+
+	keyHashes, err := s.digester.candidates(ctx, apiKey.String())
+	if err != nil {
+		return persistence.ApiKey{}, false, err
+	}
+
+	key, err := s.apiKeyRepo.GetForKey(ctx, keyHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.ApiKey{}, false, nil
+		}
+
+		return persistence.ApiKey{}, false, err
+	}
+
+	if key.ValidUntil.Before(now) {
+		return persistence.ApiKey{}, false, nil
+	}
+
+	validUntil := extendedValidUntil(key, now, s.apiKeyValidity, s.apiKeyMaxLifetime)
+	err = s.apiKeyRepo.Touch(ctx, key.Id, now, validUntil)
+	if err != nil {
+		return persistence.ApiKey{}, false, err
+	}
+
+	return key, true, nil
+}
+
+// ensureCanManageSessions verifies that the key belongs to the user or to an
+// administrator and returns the session attached to it.
+func (s *userServiceImpl) ensureCanManageSessions(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (persistence.ApiKey, error) {
+	key, valid, err := s.resolveApiKey(ctx, apiKey)
+	if err != nil {
+		return persistence.ApiKey{}, err
+	}
+	if !valid || (key.ApiUser != id && !isAdministrator(s.admins, key)) {
+		return persistence.ApiKey{}, errors.NewCode(PermissionDenied)
+	}
+
+	return key, nil
+}
+
+func (s *userServiceImpl) ensureAdministrator(ctx context.Context, apiKey apikey.Key) error {
+	view, err := s.ViewOf(ctx, apiKey, uuid.Nil)
+	if err != nil {
+		return err
+	}
+	if view != communication.AdminView {
+		return errors.NewCode(NotAnAdministrator)
+	}
+
+	return nil
+}
+
+// toUserDtoResponse projects the user for the view. Users looking at their
+// own account also see how many recovery codes they have left, and the
+// administrators whether it is locked.
+func (s *userServiceImpl) toUserDtoResponse(ctx context.Context, user persistence.User, view communication.UserView) (communication.UserDtoResponse, error) {
+	if view == communication.AdminView {
+		locked, err := s.throttle.isLocked(ctx, user.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		return communication.ToUserAdminDtoResponse(user, locked), nil
+	}
+	if view != communication.SelfView {
+		return communication.ToUserDtoResponse(user, view), nil
+	}
+
+	out := communication.ToUserSelfDtoResponse(user)
+	count, err := s.recoveryCodeRepo.CountUnused(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	out.RecoveryCodesLeft = count
+
+	return out, nil
+}
+
+func (s *userServiceImpl) normalizeEmail(address string) (string, error) {
+	normalized, err := s.normalizer.Normalize(address)
+	if err != nil {
+		return "", errors.WrapCode(err, InvalidEmail)
+	}
+
+	return normalized, nil
+}
+
+func (s *userServiceImpl) validatePassword(plaintext string, email string) error {
+	violations := s.policy.Validate(plaintext, email)
+	if len(violations) > 0 {
+		return errors.WrapCode(password.NewPolicyViolationError(violations), InvalidPassword)
+	}
+
+	return nil
+}
+
+// replacePassword returns the hash of the new password of the user, which
+// the caller is expected to store in the same transaction. Every path which
+// changes the password goes through it: the password can't be one of the
+// recent ones and the password it replaces is added to the history. The user
+// is locked until the end of the transaction so that concurrent changes are
+// checked against each other; nothing is written when the password is
+// rejected.
+func (s *userServiceImpl) replacePassword(ctx context.Context, tx db.Transaction, user persistence.User, plaintext string) (string, error) {
+	current, err := s.userRepo.Lock(ctx, tx, user.Id)
+	if err != nil {
+		return "", err
+	}
+	// The caller verified the request against a previous state of the user.
+	if current.Version != user.Version || current.Password != user.Password {
+		return "", errors.NewCode(repositories.OptimisticLockException)
+	}
+
+	if err := s.ensurePasswordNotReused(ctx, current, plaintext); err != nil {
+		return "", err
+	}
+
+	hash, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.recordPasswordHistory(ctx, tx, current); err != nil {
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// ensurePasswordNotReused verifies that the password is neither the current
+// password of the user nor one of the previous ones kept in the history.
+func (s *userServiceImpl) ensurePasswordNotReused(ctx context.Context, user persistence.User, plaintext string) error {
+	size := s.policy.HistorySize()
+	if size == 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+	if size > 1 {
+		history, err := s.passwordHistoryRepo.List(ctx, user.Id, size-1)
+		if err != nil {
+			return err
+		}
+		for _, entry := range history {
+			hashes = append(hashes, entry.Password)
+		}
+	}
+
+	for _, hash := range hashes {
+		match, err := s.hasher.Verify(plaintext, hash)
+		if err != nil {
+			return err
+		}
+		if match {
+			violations := []password.Rule{password.ReusedPassword}
+			return errors.WrapCode(password.NewPolicyViolationError(violations), InvalidPassword)
+		}
+	}
+
+	return nil
+}
+
+// recordPasswordHistory keeps the password the user is replacing so that it
+// can't be chosen again. Only as many passwords as the policy checks are
+// kept: the current password is not part of the history.
+func (s *userServiceImpl) recordPasswordHistory(ctx context.Context, tx db.Transaction, user persistence.User) error {
+	size := s.policy.HistorySize()
+	if size <= 1 {
+		return s.passwordHistoryRepo.Prune(ctx, tx, user.Id, 0)
+	}
+
+	entry := persistence.PasswordHistory{
+		Id:        uuid.New(),
+		ApiUser:   user.Id,
+		Password:  user.Password,
+		CreatedAt: time.Now(),
+	}
+	_, err := s.passwordHistoryRepo.Create(ctx, tx, entry)
+	if err != nil {
+		return err
+	}
+
+	return s.passwordHistoryRepo.Prune(ctx, tx, user.Id, size-1)
+}
+
+func (s *userServiceImpl) rehashPassword(ctx context.Context, user persistence.User, plaintext string) error {
+	hash, err := s.hasher.Hash(plaintext)
+	if err != nil {
+		return err
+	}
+
+	return s.userRepo.UpdatePasswordHash(ctx, user.Id, user.Password, hash)
+}
+
+// sendInBackground sends the message without waiting for it to be delivered,
+// so that answering takes the same time whether a message is sent or not.
+// Failures are logged by the mailer.
+func (s *userServiceImpl) sendInBackground(ctx context.Context, message mail.Message) {
+	s.background.Go(func() {
+		s.mailer.Send(context.WithoutCancel(ctx), message)
+	})
+}
@@ -3,33 +3,210 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/email"
+	"github.com/Knoblauchpilze/user-service/internal/jwt"
+	"github.com/Knoblauchpilze/user-service/internal/password"
 	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/Knoblauchpilze/user-service/pkg/repositories"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+func TestUnit_UserService_ViewOf_WhenKeyDoesNotExist_ExpectPublicView(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	view, err := service.ViewOf(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.PublicView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenKeyExpired_ExpectPublicView(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(-1 * time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	view, err := service.ViewOf(context.Background(), apikey.Generate(), user)
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.PublicView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenCallerIsTheUser_ExpectSelfView(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(1 * time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	view, err := service.ViewOf(context.Background(), apikey.Generate(), user)
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.SelfView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenLegacyKeyBeforeDeadline_ExpectSelfView(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(1 * time.Hour),
+		},
+	}
+	apiKeyConfig := ApiKeyConfig{
+		Validity:             1 * time.Hour,
+		LegacyFormatDeadline: time.Now().Add(1 * time.Hour).Format(time.RFC3339),
+	}
+
+	service := NewUserService(apiKeyConfig, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, nil, nil, nil, nil, repositories.Repositories{ApiKey: repo})
+	view, err := service.ViewOf(context.Background(), newTestLegacyApiKey(t), user)
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.SelfView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenLegacyKeyAfterDeadline_ExpectPublicView(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(1 * time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	view, err := service.ViewOf(context.Background(), newTestLegacyApiKey(t), user)
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.PublicView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenCallerIsAnotherUser_ExpectPublicView(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    uuid.New(),
+			ValidUntil: time.Now().Add(1 * time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	view, err := service.ViewOf(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.PublicView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenCallerIsAdmin_ExpectAdminView(t *testing.T) {
+	admin := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    admin,
+			ValidUntil: time.Now().Add(1 * time.Hour),
+		},
+	}
+	adminConfig := AdminConfig{
+		Users: []uuid.UUID{admin},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, adminConfig)
+	view, err := service.ViewOf(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.Nil(t, err)
+	assert.Equal(t, communication.AdminView, view)
+}
+
+func TestUnit_UserService_ViewOf_WhenLookupFails_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NotConnected),
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	_, err := service.ViewOf(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NotConnected), "Actual err: %v", err)
+}
+
+func TestUnit_UserService_Create_WhenPasswordBreaksPolicy_ExpectViolations(t *testing.T) {
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    "johndoe@example.com",
+		Password: "johndoe",
+	}
+
+	service := NewUserService(ApiKeyConfig{}, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), nil, repositories.Repositories{})
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	expected := []password.Rule{password.TooShort, password.ContainsEmail}
+	assert.Equal(t, expected, password.Violations(err))
+}
+
+func TestUnit_UserService_Create_WhenEmailIsInvalid_ExpectFailure(t *testing.T) {
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    "John Doe <johndoe@example.com>",
+		Password: "this-is-a-better-password",
+	}
+
+	service := NewUserService(ApiKeyConfig{}, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), nil, repositories.Repositories{})
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmail), "Actual err: %v", err)
+	assert.True(t, errors.IsErrorWithCode(errors.Unwrap(err), email.InvalidSyntax), "Actual err: %v", err)
+}
+
 func TestIT_UserService_Create(t *testing.T) {
 	id := uuid.New()
 	userDtoRequest := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("my-user-%s", id),
+		Email:    fmt.Sprintf("my-user-%s@example.com", id),
 		Password: "my-password",
 	}
 
 	service, conn := newTestUserRepository(t)
-	out, err := service.Create(context.Background(), userDtoRequest)
+	out, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
 
 	assert.Nil(t, err)
 
-	assert.Equal(t, userDtoRequest.Email, out.Email)
-	assert.Equal(t, userDtoRequest.Password, out.Password)
-	assertUserExists(t, conn, out.Id)
+	actual, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	assert.Equal(t, userDtoRequest.Email, actual.Email)
+	assertUserExists(t, conn, actual.Id)
+	assertPasswordForUser(t, conn, actual.Id, userDtoRequest.Password)
+}
+
+func TestIT_UserService_Create_NormalizesEmail(t *testing.T) {
+	id := uuid.New()
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    fmt.Sprintf("  My-User-%s@Example.COM ", id),
+		Password: "my-password",
+	}
+
+	service, _ := newTestUserRepository(t)
+	out, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
+
+	assert.Nil(t, err)
+	actual, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	assert.Equal(t, fmt.Sprintf("My-User-%s@example.com", id), actual.Email)
 }
 
 func TestIT_UserService_Create_InvalidEmail(t *testing.T) {
@@ -39,19 +216,19 @@ func TestIT_UserService_Create_InvalidEmail(t *testing.T) {
 	}
 
 	service, _ := newTestUserRepository(t)
-	_, err := service.Create(context.Background(), userDtoRequest)
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
 
 	assert.True(t, errors.IsErrorWithCode(err, InvalidEmail), "Actual err: %v", err)
 }
 
 func TestIT_UserService_Create_InvalidPassword(t *testing.T) {
 	userDtoRequest := communication.UserDtoRequest{
-		Email:    "my-username",
+		Email:    "my-username@example.com",
 		Password: "",
 	}
 
 	service, _ := newTestUserRepository(t)
-	_, err := service.Create(context.Background(), userDtoRequest)
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
 
 	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
 }
@@ -64,7 +241,20 @@ func TestIT_UserService_Create_WhenUserAlreadyExists_ExpectFailure(t *testing.T)
 		Password: "some-strong-password",
 	}
 
-	_, err := service.Create(context.Background(), userDtoRequest)
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Create_WhenUserAlreadyExistsWithDifferentDomainCase_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    uppercaseTestDomain(user.Email),
+		Password: "some-strong-password",
+	}
+
+	_, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
 
 	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
 }
@@ -73,19 +263,46 @@ func TestIT_UserService_Get(t *testing.T) {
 	service, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
 
-	actual, err := service.Get(context.Background(), user.Id)
+	out, err := service.Get(context.Background(), user.Id, communication.SelfView)
 
 	assert.Nil(t, err)
+	actual, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
 	assert.Equal(t, user.Id, actual.Id)
 	assert.Equal(t, user.Email, actual.Email)
-	assert.Equal(t, user.Password, actual.Password)
+}
+
+func TestIT_UserService_Get_ReturnsRequestedView(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	public, err := service.Get(context.Background(), user.Id, communication.PublicView)
+	assert.Nil(t, err)
+	assert.IsType(t, communication.UserPublicDtoResponse{}, public)
+
+	admin, err := service.Get(context.Background(), user.Id, communication.AdminView)
+	assert.Nil(t, err)
+	assert.IsType(t, communication.UserAdminDtoResponse{}, admin)
+}
+
+func TestIT_UserService_Get_WhenAccountIsLocked_ExpectLockedStatus(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	lockTestAccount(t, conn, user.Email)
+
+	out, err := service.Get(context.Background(), user.Id, communication.AdminView)
+
+	assert.Nil(t, err)
+	actual, ok := out.(communication.UserAdminDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	assert.Equal(t, communication.LockedStatus, actual.Status)
 }
 
 func TestIT_UserService_Get_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
 	nonExistingId := uuid.MustParse("00000000-0000-1221-0000-000000000000")
 
 	service, _ := newTestUserRepository(t)
-	_, err := service.Get(context.Background(), nonExistingId)
+	_, err := service.Get(context.Background(), nonExistingId, communication.SelfView)
 
 	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
 }
@@ -106,31 +323,77 @@ func TestIT_UserService_Update(t *testing.T) {
 
 	id := uuid.New()
 	updatedUser := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("updated-email-%s", id),
-		Password: "this-is-a-better-password",
+		Email: fmt.Sprintf("updated-email-%s@example.com", id),
 	}
 
-	updated, err := service.Update(context.Background(), user.Id, updatedUser)
+	out, err := service.Update(context.Background(), user.Id, updatedUser, communication.SelfView)
 
 	assert.Nil(t, err)
+	updated, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
 	assert.Equal(t, updatedUser.Email, updated.Email)
-	assert.Equal(t, updatedUser.Password, updated.Password)
+	assert.Equal(t, user.Version+1, updated.Version)
 
-	actual, err := service.Get(context.Background(), user.Id)
+	out, err = service.Get(context.Background(), user.Id, communication.SelfView)
 	assert.Nil(t, err)
+	actual, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
 	assert.Equal(t, updatedUser.Email, actual.Email)
-	assert.Equal(t, updatedUser.Password, actual.Password)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_Update_WhenPasswordIsProvided_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	updatedUser := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "this-is-a-better-password",
+	}
+
+	_, err := service.Update(context.Background(), user.Id, updatedUser, communication.SelfView)
+
+	assert.True(t, errors.IsErrorWithCode(err, PasswordNotUpdatable), "Actual err: %v", err)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_Update_WhenCallerIsNotTheUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	updatedUser := communication.UserDtoRequest{
+		Email: fmt.Sprintf("attacker-%s@example.com", uuid.NewString()),
+	}
+
+	_, err := service.Update(context.Background(), user.Id, updatedUser, communication.PublicView)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+	actual, err := service.Get(context.Background(), user.Id, communication.SelfView)
+	require.Nil(t, err)
+	assert.Equal(t, user.Email, actual.(communication.UserSelfDtoResponse).Email)
+}
+
+func TestIT_UserService_Update_WhenEmailIsInvalid_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	updatedUser := communication.UserDtoRequest{
+		Email: "not-an-email",
+	}
+
+	_, err := service.Update(context.Background(), user.Id, updatedUser, communication.SelfView)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEmail), "Actual err: %v", err)
 }
 
 func TestIT_UserService_Update_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
 	nonExistentId := uuid.New()
 	updatedUser := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("updated-email-%s", nonExistentId),
-		Password: "this-is-a-better-password",
+		Email: fmt.Sprintf("updated-email-%s@example.com", nonExistentId),
 	}
 
 	service, _ := newTestUserRepository(t)
-	_, err := service.Update(context.Background(), nonExistentId, updatedUser)
+	_, err := service.Update(context.Background(), nonExistentId, updatedUser, communication.AdminView)
 
 	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
 }
@@ -141,11 +404,10 @@ func TestIT_UserService_Update_WhenUpdateFails_ExpectFailure(t *testing.T) {
 	otherUser := insertTestUser(t, conn)
 
 	updatedUser := communication.UserDtoRequest{
-		Email:    otherUser.Email,
-		Password: "this-is-a-better-password",
+		Email: otherUser.Email,
 	}
 
-	_, err := service.Update(context.Background(), user.Id, updatedUser)
+	_, err := service.Update(context.Background(), user.Id, updatedUser, communication.SelfView)
 
 	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
 }
@@ -190,7 +452,7 @@ func TestIT_UserService_Login_ExpectCorrectUserAndValidity(t *testing.T) {
 		Password: user.Password,
 	}
 
-	apiKey, err := service.Login(context.Background(), userDtoRequest)
+	apiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
 
 	assert.Nil(t, err)
 	assert.Equal(t, user.Id, apiKey.User)
@@ -199,16 +461,107 @@ func TestIT_UserService_Login_ExpectCorrectUserAndValidity(t *testing.T) {
 	assertApiKeyExistsByKey(t, conn, apiKey.Key)
 }
 
+func TestIT_UserService_Login_WhenPasswordIsHashed_ExpectSuccess(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	id := uuid.New()
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    fmt.Sprintf("my-user-%s@example.com", id),
+		Password: "my-password",
+	}
+	out, err := service.Create(context.Background(), userDtoRequest, communication.SelfView)
+	require.Nil(t, err)
+	user, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+
+	apiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, apiKey.User)
+	assertApiKeyExistsByKey(t, conn, apiKey.Key)
+}
+
+func TestIT_UserService_Login_WhenPasswordIsInPlaintext_ExpectPasswordIsHashed(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_Login_WhenHashIsOutdated_ExpectPasswordIsRehashed(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	outdatedConfig := passwordTestConfig
+	outdatedConfig.Argon2.Iterations = 2
+	outdatedHasher, err := password.NewHasher(outdatedConfig)
+	require.Nil(t, err)
+	outdatedHash, err := outdatedHasher.Hash(user.Password)
+	require.Nil(t, err)
+	_, err = conn.Exec(context.Background(), "UPDATE api_user SET password = $1 WHERE id = $2", outdatedHash, user.Id)
+	require.Nil(t, err)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	_, err = service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertPasswordForUser(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_Login_IgnoresEmailDomainCase(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    " " + uppercaseTestDomain(user.Email),
+		Password: user.Password,
+	}
+
+	out, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, out.User)
+}
+
+func TestIT_UserService_Login_WhenEmailIsNotValid_ExpectLegacyUserToLogIn(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	legacyEmail := fmt.Sprintf("my-user-%s", user.Id)
+	_, err := conn.Exec(context.Background(), "UPDATE api_user SET email = $1 WHERE id = $2", legacyEmail, user.Id)
+	require.Nil(t, err)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    legacyEmail,
+		Password: user.Password,
+	}
+
+	out, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, out.User)
+}
+
 func TestIT_UserService_Login_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
 	userDtoRequest := communication.UserDtoRequest{
-		Email:    fmt.Sprintf("not-an-existing-email-%s", uuid.New()),
+		Email:    fmt.Sprintf("not-an-existing-email-%s@example.com", uuid.New()),
 		Password: "my-password",
 	}
 
 	service, _ := newTestUserRepository(t)
-	_, err := service.Login(context.Background(), userDtoRequest)
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
 
-	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
 }
 
 func TestIT_UserService_Login_WhenCredentialsAreWrong_ExpectFailure(t *testing.T) {
@@ -220,74 +573,999 @@ func TestIT_UserService_Login_WhenCredentialsAreWrong_ExpectFailure(t *testing.T
 		Password: "not-the-right-password",
 	}
 
-	_, err := service.Login(context.Background(), userDtoRequest)
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
 
 	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
 }
 
-func TestIT_UserService_Login_WhenUserAlreadyLoggedIn_ExpectApiKeyValidityIsExtended(t *testing.T) {
+func TestIT_UserService_Login_WhenUserAlreadyLoggedIn_ExpectNewSession(t *testing.T) {
 	service, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
-	timeInThePast := time.Now().Add(-1 * time.Hour)
-	apiKey := insertApiKeyForUserWithValidity(t, conn, user.Id, timeInThePast)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
 
 	userDtoRequest := communication.UserDtoRequest{
 		Email:    user.Email,
 		Password: user.Password,
 	}
 
-	updatedApiKey, err := service.Login(context.Background(), userDtoRequest)
+	newApiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
 
 	assert.Nil(t, err)
-	assert.Equal(t, apiKey.Key, updatedApiKey.Key)
-	assert.Equal(t, user.Id, updatedApiKey.User)
-	assert.True(t, timeInThePast.Before(updatedApiKey.ValidUntil))
+	assert.NotEqual(t, apiKey.Key.String(), newApiKey.Key)
+	assert.Equal(t, user.Id, newApiKey.User)
+	assertApiKeyExists(t, conn, apiKey.Id)
+	assertApiKeyExistsByKey(t, conn, newApiKey.Key)
 	validityDateWithSafetyMargin := time.Now().Add(55 * time.Minute)
-	assert.True(t, updatedApiKey.ValidUntil.After(validityDateWithSafetyMargin))
+	assert.True(t, newApiKey.ValidUntil.After(validityDateWithSafetyMargin))
 }
 
-func TestIT_UserService_Logout(t *testing.T) {
+func TestIT_UserService_Login_WhenPreviousSessionExpired_ExpectItIsDeleted(t *testing.T) {
 	service, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
-	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	timeInThePast := time.Now().Add(-1 * time.Hour)
+	apiKey := insertApiKeyForUserWithValidity(t, conn, user.Id, timeInThePast)
 
-	err := service.Logout(context.Background(), user.Id)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	newApiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
 
 	assert.Nil(t, err)
 	assertApiKeyDoesNotExist(t, conn, apiKey.Id)
-	assertUserExists(t, conn, user.Id)
+	assertApiKeyExistsByKey(t, conn, newApiKey.Key)
 }
 
-func TestIT_UserService_Logout_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
-	nonExistingId := uuid.MustParse("00000000-0000-1221-0000-000000000000")
+func TestIT_UserService_Login_WhenTooManySessionsAndEvictOldest_ExpectOldestSessionDeleted(t *testing.T) {
+	service, conn := newTestUserServiceWithSessionLimit(t, 2, EvictOldest)
+	user := insertTestUser(t, conn)
+	oldest := insertApiKeyForUser(t, conn, user.Id)
+	newest := insertApiKeyForUser(t, conn, user.Id)
 
-	service, _ := newTestUserRepository(t)
-	err := service.Logout(context.Background(), nonExistingId)
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
 
-	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	apiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, oldest.Id)
+	assertApiKeyExists(t, conn, newest.Id)
+	assertApiKeyExistsByKey(t, conn, apiKey.Key)
 }
 
-func TestIT_UserService_Logout_WhenNotLoggedIn_ExpectSuccess(t *testing.T) {
+func TestIT_UserService_Login_WhenTooManySessionsAndRejectNew_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserServiceWithSessionLimit(t, 2, RejectNew)
+	user := insertTestUser(t, conn)
+	first := insertApiKeyForUser(t, conn, user.Id)
+	second := insertApiKeyForUser(t, conn, user.Id)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManySessions), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, first.Id)
+	assertApiKeyExists(t, conn, second.Id)
+}
+
+func TestIT_UserService_Login_WhenExpiredSessionsAndRejectNew_ExpectSuccess(t *testing.T) {
+	service, conn := newTestUserServiceWithSessionLimit(t, 1, RejectNew)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(-1*time.Hour))
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	_, err := service.Login(context.Background(), userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey.Id)
+}
+
+func TestIT_UserService_Login_WhenRefreshTokensAreDisabled_ExpectNoRefreshToken(t *testing.T) {
 	service, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
 
-	err := service.Logout(context.Background(), user.Id)
+	apiKey, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
 
 	assert.Nil(t, err)
-	assertUserExists(t, conn, user.Id)
+	assert.Empty(t, apiKey.RefreshToken)
+	assert.Nil(t, apiKey.RefreshTokenValidUntil)
 }
 
-func newTestUserRepository(t *testing.T) (UserService, db.Connection) {
-	conn := newTestConnection(t)
+func TestIT_UserService_Login_WhenRefreshTokensAreEnabled_ExpectRefreshToken(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+
+	apiKey, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	_, err = apikey.ParseRefreshToken(apiKey.RefreshToken)
+	assert.Nil(t, err)
+	require.NotNil(t, apiKey.RefreshTokenValidUntil)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *apiKey.RefreshTokenValidUntil, time.Minute)
+}
+
+func TestIT_UserService_Login_WhenPreviousSessionCanBeRefreshed_ExpectItIsKept(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	first, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	_, err = conn.Exec(context.Background(), "UPDATE api_key SET valid_until = $1 WHERE api_user = $2", time.Now().Add(-1*time.Hour), user.Id)
+	require.Nil(t, err)
+
+	_, err = service.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, first.RefreshToken))
+	assert.Nil(t, err)
+}
+
+func TestIT_UserService_Refresh_ExpectKeyAndTokenAreRotated(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+
+	actual, err := service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, actual.User)
+	assert.NotEqual(t, login.Key, actual.Key)
+	assert.NotEqual(t, login.RefreshToken, actual.RefreshToken)
+	assertApiKeyExistsByKey(t, conn, actual.Key)
+	assertApiKeyDoesNotExistByKey(t, conn, login.Key)
+	// The new token does not extend the validity of the session.
+	require.NotNil(t, actual.RefreshTokenValidUntil)
+	assert.True(t, login.RefreshTokenValidUntil.Equal(*actual.RefreshTokenValidUntil))
+}
+
+func TestIT_UserService_Refresh_ExpectSessionIsKept(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	before, err := service.ListSessions(context.Background(), newTestApiKey(t, login.Key), user.Id)
+	require.Nil(t, err)
+
+	actual, err := service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+	require.Nil(t, err)
+
+	after, err := service.ListSessions(context.Background(), newTestApiKey(t, actual.Key), user.Id)
+	assert.Nil(t, err)
+	require.Len(t, before, 1)
+	require.Len(t, after, 1)
+	assert.Equal(t, before[0].Id, after[0].Id)
+}
+
+func TestIT_UserService_Refresh_WhenTokenIsReused_ExpectSessionIsRevoked(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	refreshed, err := service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+	require.Nil(t, err)
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.True(t, errors.IsErrorWithCode(err, RefreshTokenReused), "Actual err: %v", err)
+	assertApiKeyDoesNotExistByKey(t, conn, refreshed.Key)
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, refreshed.RefreshToken))
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRefreshToken), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Refresh_WhenTokenIsReused_ExpectOtherSessionsAreKept(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	other := insertApiKeyForUser(t, conn, user.Id)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+	require.Nil(t, err)
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.True(t, errors.IsErrorWithCode(err, RefreshTokenReused), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, other.Id)
+}
+
+func TestIT_UserService_Refresh_WhenTokenDoesNotExist_ExpectFailure(t *testing.T) {
+	service, _ := newTestUserServiceWithRefreshTokens(t)
+
+	_, err := service.Refresh(context.Background(), apikey.GenerateRefreshToken())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRefreshToken), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Refresh_WhenTokenExpired_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	_, err = conn.Exec(context.Background(), "UPDATE refresh_token SET valid_until = $1 WHERE api_key IN (SELECT id FROM api_key WHERE api_user = $2)", time.Now().Add(-1*time.Minute), user.Id)
+	require.Nil(t, err)
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRefreshToken), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Refresh_WhenSessionIsRevoked_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserServiceWithRefreshTokens(t)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	err = service.Logout(context.Background(), user.Id)
+	require.Nil(t, err)
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRefreshToken), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Refresh_ExpectSessionIsCappedToMaxLifetime(t *testing.T) {
+	service, conn := newTestUserServiceWithMaxLifetime(t, 2*time.Hour)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	createdAt := time.Now().Add(-90 * time.Minute)
+	ageTestSessions(t, conn, user.Id, createdAt)
+
+	actual, err := service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.Nil(t, err)
+	assert.WithinDuration(t, createdAt.Add(2*time.Hour), actual.ValidUntil, time.Second)
+}
+
+func TestIT_UserService_Refresh_WhenMaxLifetimeIsExhausted_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserServiceWithMaxLifetime(t, 2*time.Hour)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	ageTestSessions(t, conn, user.Id, time.Now().Add(-3*time.Hour))
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidRefreshToken), "Actual err: %v", err)
+}
+
+func TestUnit_UserService_IssueKey_WhenTokensAreDisabled_ExpectOpaqueKey(t *testing.T) {
+	service := &userServiceImpl{}
+	session := persistence.ApiKey{
+		Id:      uuid.New(),
+		ApiUser: uuid.New(),
+	}
+
+	actual, err := service.issueKey(context.Background(), &session, time.Now())
+
+	assert.Nil(t, err)
+	key := newTestApiKey(t, actual)
+	assert.False(t, key.Token())
+	assert.Equal(t, apikey.Digest(actual, ""), session.KeyHash)
+	assert.Nil(t, session.TokenId)
+}
+
+func TestUnit_UserService_IssueKey_WhenTokensAreEnabled_ExpectSignedToken(t *testing.T) {
+	signer := newTestSigner(t)
+	service := &userServiceImpl{
+		signer: signer,
+		roles:  newTestRoleResolver(nil),
+	}
+	now := time.Now()
+	session := persistence.ApiKey{
+		Id:         uuid.New(),
+		ApiUser:    uuid.New(),
+		ValidUntil: now.Add(time.Hour),
+	}
+
+	actual, err := service.issueKey(context.Background(), &session, now)
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual, now)
+	require.Nil(t, err)
+	assert.Equal(t, session.ApiUser.String(), claims.Subject)
+	assert.Equal(t, session.Id.String(), claims.Session)
+	assert.Equal(t, []string{"my-api"}, claims.Audience)
+	assert.Equal(t, []string{UserRole}, claims.Roles)
+	assert.Equal(t, session.ValidUntil.Unix(), claims.ExpiresAt)
+	assert.Equal(t, apikey.Digest(actual, ""), session.KeyHash)
+	require.NotNil(t, session.TokenId)
+	assert.Equal(t, session.TokenId.String(), claims.Id)
+}
+
+func TestUnit_UserService_IssueKey_WhenRolesAreAssigned_ExpectRolesAndPermissionsInToken(t *testing.T) {
+	signer := newTestSigner(t)
+	editor := persistence.Role{Id: uuid.New(), Name: "editor"}
+	service := &userServiceImpl{
+		signer: signer,
+		roles: roleResolver{
+			roleRepo: &mockRoleRepository{
+				inherited:   []persistence.Role{editor},
+				permissions: []persistence.RolePermission{{Role: editor.Id, Permission: "articles:write"}},
+			},
+			assignmentRepo: &mockRoleAssignmentRepository{roles: []persistence.Role{editor}},
+		},
+	}
+	now := time.Now()
+	session := persistence.ApiKey{
+		Id:         uuid.New(),
+		ApiUser:    uuid.New(),
+		ValidUntil: now.Add(time.Hour),
+	}
+
+	actual, err := service.issueKey(context.Background(), &session, now)
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual, now)
+	require.Nil(t, err)
+	assert.Equal(t, []string{UserRole, "editor"}, claims.Roles)
+	assert.Equal(t, []string{"articles:write"}, claims.Permissions)
+}
+
+func TestUnit_UserService_IssueKey_WhenSessionBelongsToClient_ExpectAssignedRolesIgnored(t *testing.T) {
+	signer := newTestSigner(t)
+	assignmentRepo := &mockRoleAssignmentRepository{
+		roles: []persistence.Role{{Id: uuid.New(), Name: "editor"}},
+	}
+	service := &userServiceImpl{
+		signer: signer,
+		roles:  roleResolver{roleRepo: &mockRoleRepository{}, assignmentRepo: assignmentRepo},
+	}
+	now := time.Now()
+	session := persistence.ApiKey{
+		Id:         uuid.New(),
+		ApiUser:    uuid.New(),
+		ClientId:   "my-client",
+		ValidUntil: now.Add(time.Hour),
+	}
+
+	actual, err := service.issueKey(context.Background(), &session, now)
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual, now)
+	require.Nil(t, err)
+	assert.Equal(t, []string{UserRole}, claims.Roles)
+	assert.Empty(t, claims.Permissions)
+	assert.Zero(t, assignmentRepo.calls)
+}
+
+func TestUnit_UserService_IssueKey_WhenUserIsAdministrator_ExpectAdminRole(t *testing.T) {
+	signer := newTestSigner(t)
+	admin := uuid.New()
+	service := &userServiceImpl{
+		signer: signer,
+		roles:  newTestRoleResolver(nil),
+		admins: []uuid.UUID{admin},
+	}
+	now := time.Now()
+	session := persistence.ApiKey{
+		Id:         uuid.New(),
+		ApiUser:    admin,
+		ValidUntil: now.Add(time.Hour),
+	}
+
+	actual, err := service.issueKey(context.Background(), &session, now)
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual, now)
+	require.Nil(t, err)
+	assert.Equal(t, []string{UserRole, AdminRole}, claims.Roles)
+}
+
+func TestIT_UserService_Login_WhenTokensAreEnabled_ExpectSignedToken(t *testing.T) {
+	signer := newTestSigner(t)
+	service, conn := newTestUserServiceWithTokens(t, signer)
+	user := insertTestUser(t, conn)
+
+	actual, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual.Key, time.Now())
+	require.Nil(t, err)
+	assert.Equal(t, user.Id.String(), claims.Subject)
+	assertApiKeyExistsByKey(t, conn, actual.Key)
+	// The token is accepted by the endpoints looking keys up in the database.
+	sessions, err := service.ListSessions(context.Background(), newTestApiKey(t, actual.Key), user.Id)
+	assert.Nil(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, claims.Session, sessions[0].Id.String())
+}
+
+func TestIT_UserService_Login_WhenTokensAreEnabled_ExpectAssignedRolesInToken(t *testing.T) {
+	signer := newTestSigner(t)
+	service, conn := newTestUserServiceWithTokens(t, signer)
+	user := insertTestUser(t, conn)
+	reader := insertTestRole(t, conn, nil, insertTestPermission(t, conn))
+	editor := insertTestRole(t, conn, &reader.Id, insertTestPermission(t, conn))
+	assignTestRole(t, conn, user, editor)
+
+	actual, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), actual.Key, time.Now())
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{UserRole, reader.Name, editor.Name}, claims.Roles)
+	assert.ElementsMatch(t, append(reader.Permissions, editor.Permissions...), claims.Permissions)
+}
+
+func TestIT_UserService_Refresh_WhenTokensAreEnabled_ExpectPreviousTokenRevoked(t *testing.T) {
+	signer := newTestSigner(t)
+	service, conn := newTestUserServiceWithTokens(t, signer)
+	user := insertTestUser(t, conn)
+	login, err := openTestSession(t, service, newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	claims, err := signer.Verify(context.Background(), login.Key, time.Now())
+	require.Nil(t, err)
+
+	_, err = service.Refresh(context.Background(), newTestRefreshToken(t, login.RefreshToken))
+
+	assert.Nil(t, err)
+	revoked, err := repositories.NewRevokedTokenRepository(conn).ListActive(context.Background(), time.Now())
+	require.Nil(t, err)
+	assert.Contains(t, revoked, uuid.MustParse(claims.Id))
+}
+
+func TestIT_UserService_Logout(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.Logout(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey.Id)
+	assertUserExists(t, conn, user.Id)
+}
+
+func TestIT_UserService_Logout_WhenUserHasMultipleSessions_ExpectAllDeleted(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.Logout(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestIT_UserService_Logout_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	nonExistingId := uuid.MustParse("00000000-0000-1221-0000-000000000000")
+
+	service, _ := newTestUserRepository(t)
+	err := service.Logout(context.Background(), nonExistingId)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Logout_WhenNotLoggedIn_ExpectSuccess(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	err := service.Logout(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assertUserExists(t, conn, user.Id)
+}
+
+func TestIT_UserService_LogoutSession_ExpectOtherSessionsAreKept(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.LogoutSession(context.Background(), user.Id, apiKey1.Key)
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+}
+
+func TestIT_UserService_LogoutSession_WhenKeyBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	otherUser := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	err := service.LogoutSession(context.Background(), user.Id, apiKey.Key)
+
+	assert.True(t, errors.IsErrorWithCode(err, SessionNotFound), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, apiKey.Id)
+}
+
+func TestUnit_UserService_LogoutSession_WhenKeyDoesNotExist_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	err := service.LogoutSession(context.Background(), uuid.New(), apikey.Generate())
+
+	assert.True(t, errors.IsErrorWithCode(err, SessionNotFound), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WhenMaxLifetimeIsShorterThanValidity_ExpectSessionIsCapped(t *testing.T) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity:    3 * time.Hour,
+		MaxLifetime: 1 * time.Hour,
+	}
+	service, conn := newTestUserServiceWithAllConfigs(t, apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, nil)
+	user := insertTestUser(t, conn)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+
+	apiKey, err := openTestSession(t, service, userDtoRequest, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(1*time.Hour), apiKey.ValidUntil, time.Minute)
+}
+
+func TestIT_UserService_Login_ExpectClientIsAttachedToSession(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	userDtoRequest := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+	client := ClientInfo{
+		Ip:        "203.0.113.42",
+		UserAgent: "my-user-agent",
+	}
+
+	apiKey, err := openTestSession(t, service, userDtoRequest, client)
+	require.Nil(t, err)
+
+	key, err := apikey.Parse(apiKey.Key)
+	require.Nil(t, err)
+	sessions, err := service.ListSessions(context.Background(), key, user.Id)
+	assert.Nil(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "203.0.113.42", sessions[0].IpAddress)
+	assert.Equal(t, "my-user-agent", sessions[0].UserAgent)
+}
+
+func TestIT_UserService_ListSessions(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+	insertApiKeyForUserWithValidity(t, conn, user.Id, time.Now().Add(-1*time.Hour))
+
+	actual, err := service.ListSessions(context.Background(), apiKey2.Key, user.Id)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 2)
+	assert.Equal(t, apiKey1.Id, actual[0].Id)
+	assert.False(t, actual[0].Current)
+	assert.Equal(t, apiKey2.Id, actual[1].Id)
+	assert.True(t, actual[1].Current)
+	// The key used for the request is marked as used.
+	assert.NotNil(t, actual[1].LastUsedAt)
+}
+
+func TestIT_UserService_ListSessions_WhenCallerIsAnotherUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	otherUser := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	_, err := service.ListSessions(context.Background(), apiKey.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_UserService_ListSessions_WhenCallerIsAdmin_ExpectSuccess(t *testing.T) {
+	conn := newTestConnection(t)
+	admin := insertTestUser(t, conn)
+	adminApiKey := insertApiKeyForUser(t, conn, admin.Id)
+	service, _ := newTestUserServiceWithConfigs(t, AdminConfig{Users: []uuid.UUID{admin.Id}}, loginThrottleTestConfig)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	actual, err := service.ListSessions(context.Background(), adminApiKey.Key, user.Id)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, apiKey.Id, actual[0].Id)
+	assert.False(t, actual[0].Current)
+}
+
+func TestUnit_UserService_ListSessions_WhenKeyDoesNotExist_ExpectFailure(t *testing.T) {
+	repo := &mockApiKeyRepository{
+		err: errors.NewCode(db.NoMatchingRows),
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	_, err := service.ListSessions(context.Background(), apikey.Generate(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestUnit_UserService_ListSessions_WhenKeyExpired_ExpectFailure(t *testing.T) {
+	user := uuid.New()
+	repo := &mockApiKeyRepository{
+		apiKey: persistence.ApiKey{
+			ApiUser:    user,
+			ValidUntil: time.Now().Add(-1 * time.Hour),
+		},
+	}
+
+	service := newTestUserServiceWithApiKeyRepository(repo, AdminConfig{})
+	_, err := service.ListSessions(context.Background(), apikey.Generate(), user)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_UserService_RevokeSession(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.RevokeSession(context.Background(), apiKey1.Key, user.Id, apiKey2.Id)
+
+	assert.Nil(t, err)
+	assertApiKeyExists(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestIT_UserService_RevokeSession_WhenSessionBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	otherUser := insertTestUser(t, conn)
+	otherApiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	err := service.RevokeSession(context.Background(), apiKey.Key, user.Id, otherApiKey.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, SessionNotFound), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, otherApiKey.Id)
+}
+
+func TestIT_UserService_RevokeSession_WhenSessionDoesNotExist_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.RevokeSession(context.Background(), apiKey.Key, user.Id, uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, SessionNotFound), "Actual err: %v", err)
+}
+
+func TestIT_UserService_RevokeSessions(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.RevokeSessions(context.Background(), apiKey1.Key, user.Id, false)
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestIT_UserService_RevokeSessions_WhenCurrentIsKept_ExpectOtherSessionsDeleted(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey3 := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.RevokeSessions(context.Background(), apiKey2.Key, user.Id, true)
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey3.Id)
+}
+
+func TestIT_UserService_RevokeSessions_WhenCallerIsAnotherUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	otherUser := insertTestUser(t, conn)
+	otherApiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	err := service.RevokeSessions(context.Background(), otherApiKey.Key, user.Id, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+	assertApiKeyExists(t, conn, apiKey.Id)
+}
+
+func TestIT_UserService_ChangePassword(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	request := newTestPasswordChangeRequest(user.Password, "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.Nil(t, err)
+	login := communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "Sup3rSecretPassw0rd-1",
+	}
+	_, err = service.Login(context.Background(), login, ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_UserService_ChangePassword_ExpectOtherSessionsRevoked(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+	otherUser := insertTestUser(t, conn)
+	otherApiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	request := newTestPasswordChangeRequest(user.Password, "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey2.Key, user.Id, request, ClientInfo{})
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+	assertApiKeyExists(t, conn, otherApiKey.Id)
+}
+
+func TestIT_UserService_ChangePassword_WhenCallerIsAnotherUser_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	otherUser := insertTestUser(t, conn)
+	otherApiKey := insertApiKeyForUser(t, conn, otherUser.Id)
+
+	request := newTestPasswordChangeRequest(user.Password, "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), otherApiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+	assertUserPassword(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_ChangePassword_WhenCurrentPasswordIsWrong_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey1 := insertApiKeyForUser(t, conn, user.Id)
+	apiKey2 := insertApiKeyForUser(t, conn, user.Id)
+
+	request := newTestPasswordChangeRequest("not-my-password", "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey1.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	assertUserPassword(t, conn, user.Id, user.Password)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+}
+
+func TestIT_UserService_ChangePassword_WhenCurrentPasswordIsGuessed_ExpectThrottled(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	request := newTestPasswordChangeRequest("not-my-password", "Sup3rSecretPassw0rd-1")
+	for range loginThrottleTestConfig.FreeAttempts {
+		err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+		require.True(t, errors.IsErrorWithCode(err, InvalidCredentials), "Actual err: %v", err)
+	}
+
+	request = newTestPasswordChangeRequest(user.Password, "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, TooManyLoginAttempts), "Actual err: %v", err)
+	assertUserPassword(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_ChangePassword_WhenPolicyIsNotRespected_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+
+	request := newTestPasswordChangeRequest(user.Password, "short")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assert.Contains(t, password.Violations(err), password.TooShort)
+	assertUserPassword(t, conn, user.Id, user.Password)
+}
+
+func TestIT_UserService_ChangePassword_WhenPasswordIsTheCurrentOne_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	changeTestPassword(t, service, apiKey.Key, user.Id, user.Password, "Sup3rSecretPassw0rd-1")
+
+	request := newTestPasswordChangeRequest("Sup3rSecretPassw0rd-1", "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assert.Equal(t, []password.Rule{password.ReusedPassword}, password.Violations(err))
+}
+
+func TestIT_UserService_ChangePassword_WhenPasswordIsInHistory_ExpectFailure(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	changeTestPassword(t, service, apiKey.Key, user.Id, user.Password, "Sup3rSecretPassw0rd-1")
+	changeTestPassword(t, service, apiKey.Key, user.Id, "Sup3rSecretPassw0rd-1", "Sup3rSecretPassw0rd-2")
+
+	request := newTestPasswordChangeRequest("Sup3rSecretPassw0rd-2", "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidPassword), "Actual err: %v", err)
+	assert.Equal(t, []password.Rule{password.ReusedPassword}, password.Violations(err))
+}
+
+func TestIT_UserService_ChangePassword_WhenPasswordIsOlderThanHistory_ExpectSuccess(t *testing.T) {
+	service, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	apiKey := insertApiKeyForUser(t, conn, user.Id)
+	changeTestPassword(t, service, apiKey.Key, user.Id, user.Password, "Sup3rSecretPassw0rd-1")
+	changeTestPassword(t, service, apiKey.Key, user.Id, "Sup3rSecretPassw0rd-1", "Sup3rSecretPassw0rd-2")
+	changeTestPassword(t, service, apiKey.Key, user.Id, "Sup3rSecretPassw0rd-2", "Sup3rSecretPassw0rd-3")
+
+	request := newTestPasswordChangeRequest("Sup3rSecretPassw0rd-3", "Sup3rSecretPassw0rd-1")
+	err := service.ChangePassword(context.Background(), apiKey.Key, user.Id, request, ClientInfo{})
+
+	assert.Nil(t, err)
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(*) FROM password_history WHERE api_user = $1", user.Id)
+	require.Nil(t, err)
+	assert.Equal(t, passwordTestConfig.Policy.HistorySize-1, value)
+}
+
+func TestUnit_ClientInfo_WhenUserAgentIsTooLong_ExpectTruncated(t *testing.T) {
+	client := ClientInfo{
+		UserAgent: strings.Repeat("a", maxUserAgentLength-1) + "é",
+	}
+
+	actual := client.truncatedUserAgent()
+
+	assert.Equal(t, strings.Repeat("a", maxUserAgentLength-1), actual)
+}
+
+func newTestUserRepository(t *testing.T) (UserService, db.Connection) {
+	return newTestUserServiceWithConfigs(t, AdminConfig{}, loginThrottleTestConfig)
+}
+
+func newTestUserServiceWithConfigs(t *testing.T, adminConfig AdminConfig, throttleConfig LoginThrottleConfig) (UserService, db.Connection) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+
+	return newTestUserServiceWithAllConfigs(t, apiKeyConfig, adminConfig, throttleConfig, nil)
+}
+
+func newTestUserServiceWithRefreshTokens(t *testing.T) (UserService, db.Connection) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity:        1 * time.Hour,
+		RefreshValidity: 24 * time.Hour,
+	}
+
+	return newTestUserServiceWithAllConfigs(t, apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, nil)
+}
+
+func newTestUserServiceWithMaxLifetime(t *testing.T, maxLifetime time.Duration) (UserService, db.Connection) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity:        1 * time.Hour,
+		RefreshValidity: 24 * time.Hour,
+		MaxLifetime:     maxLifetime,
+	}
+
+	return newTestUserServiceWithAllConfigs(t, apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, nil)
+}
+
+func ageTestSessions(t *testing.T, conn db.Connection, user uuid.UUID, createdAt time.Time) {
+	_, err := conn.Exec(context.Background(), "UPDATE api_key SET created_at = $1 WHERE api_user = $2", createdAt, user)
+	require.Nil(t, err)
+}
+
+func newTestUserServiceWithTokens(t *testing.T, signer jwt.Signer) (UserService, db.Connection) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity:        1 * time.Hour,
+		RefreshValidity: 24 * time.Hour,
+	}
+
+	return newTestUserServiceWithAllConfigs(t, apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, signer)
+}
+
+func uppercaseTestDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	return email[:at] + strings.ToUpper(email[at:])
+}
+
+func newTestRoleResolver(roles []persistence.Role) roleResolver {
+	return roleResolver{
+		roleRepo:       &mockRoleRepository{inherited: roles},
+		assignmentRepo: &mockRoleAssignmentRepository{roles: roles},
+	}
+}
+
+func newTestLoginRequest(user persistence.User) communication.UserDtoRequest {
+	return communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: user.Password,
+	}
+}
+
+func newTestPasswordChangeRequest(current string, next string) communication.PasswordChangeDtoRequest {
+	return communication.PasswordChangeDtoRequest{
+		CurrentPassword: current,
+		NewPassword:     next,
+	}
+}
+
+func changeTestPassword(t *testing.T, service UserService, apiKey apikey.Key, user uuid.UUID, current string, next string) {
+	request := newTestPasswordChangeRequest(current, next)
+	err := service.ChangePassword(context.Background(), apiKey, user, request, ClientInfo{})
+	require.Nil(t, err)
+}
+
+func assertUserPassword(t *testing.T, conn db.Connection, user uuid.UUID, expected string) {
+	value, err := db.QueryOne[string](context.Background(), conn, "SELECT password FROM api_user WHERE id = $1", user)
+	require.Nil(t, err)
+	require.Equal(t, expected, value)
+}
+
+func newTestApiKey(t *testing.T, raw string) apikey.Key {
+	key, err := apikey.Parse(raw)
+	require.Nil(t, err)
+	return key
+}
+
+func newTestRefreshToken(t *testing.T, raw string) apikey.RefreshToken {
+	token, err := apikey.ParseRefreshToken(raw)
+	require.Nil(t, err)
+	return token
+}
+
+func newTestUserServiceWithSessionLimit(t *testing.T, maxPerUser int, eviction EvictionPolicy) (UserService, db.Connection) {
+	apiKeyConfig := ApiKeyConfig{
+		Validity:   1 * time.Hour,
+		MaxPerUser: maxPerUser,
+		Eviction:   eviction,
+	}
+
+	return newTestUserServiceWithAllConfigs(t, apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, nil)
+}
+
+func newTestUserServiceWithAllConfigs(t *testing.T, apiKeyConfig ApiKeyConfig, adminConfig AdminConfig, throttleConfig LoginThrottleConfig, signer jwt.Signer) (UserService, db.Connection) {
+	conn := newTestConnection(t)
+
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Role:               repositories.NewRoleRepository(conn),
+		RoleAssignment:     repositories.NewRoleAssignmentRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	return NewUserService(apiKeyConfig, adminConfig, throttleConfig, VerificationConfig{}, signer, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos), conn
+}
 
+func newTestUserServiceWithApiKeyRepository(apiKeyRepo repositories.ApiKeyRepository, adminConfig AdminConfig) UserService {
 	repos := repositories.Repositories{
-		ApiKey: repositories.NewApiKeyRepository(conn),
-		User:   repositories.NewUserRepository(conn),
+		ApiKey: apiKeyRepo,
 	}
 
 	apiKeyConfig := ApiKeyConfig{
 		Validity: 1 * time.Hour,
 	}
 
-	return NewUserService(apiKeyConfig, conn, repos), conn
+	return NewUserService(apiKeyConfig, adminConfig, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, nil, nil, nil, nil, repos)
 }
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type VerificationConfig struct {
+	// TokenValidity is how long users have to follow the link they were
+	// sent before having to request a new one.
+	TokenValidity time.Duration
+	// Url is the page of the frontend confirming the address: the token is
+	// appended to it as the token query parameter. Only the token is sent
+	// when it is empty.
+	Url string
+	// Required prevents users from logging in until they verified their
+	// email.
+	Required bool
+}
+
+func (c VerificationConfig) Validate() error {
+	if c.TokenValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidVerificationConfiguration, "token validity must be positive")
+	}
+
+	return validateLinkUrl(c.Url, InvalidVerificationConfiguration)
+}
+
+// link returns what users should follow to verify their email.
+func (c VerificationConfig) link(token string) string {
+	return linkWithToken(c.Url, token)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_VerificationConfig_Validate(t *testing.T) {
+	config := VerificationConfig{
+		TokenValidity: 24 * time.Hour,
+		Url:           "https://example.com/verify",
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_VerificationConfig_Validate_WhenUrlIsEmpty_ExpectSuccess(t *testing.T) {
+	config := VerificationConfig{
+		TokenValidity: 24 * time.Hour,
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_VerificationConfig_Validate_WhenTokenValidityIsNotPositive_ExpectFailure(t *testing.T) {
+	config := VerificationConfig{}
+
+	err := config.Validate()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationConfiguration), "Actual err: %v", err)
+}
+
+func TestUnit_VerificationConfig_Validate_WhenUrlIsInvalid_ExpectFailure(t *testing.T) {
+	urls := []string{
+		"example.com/verify",
+		"/verify",
+		"ftp://example.com/verify",
+		"https://example.com/verify#token",
+	}
+
+	for _, url := range urls {
+		t.Run(url, func(t *testing.T) {
+			config := VerificationConfig{
+				TokenValidity: 24 * time.Hour,
+				Url:           url,
+			}
+
+			err := config.Validate()
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationConfiguration), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_VerificationConfig_Link(t *testing.T) {
+	config := VerificationConfig{
+		Url: "https://example.com/verify?lang=en",
+	}
+
+	actual := config.link("usv_live_my-token")
+
+	assert.Equal(t, "https://example.com/verify?lang=en&token=usv_live_my-token", actual)
+}
+
+func TestUnit_VerificationConfig_Link_WhenUrlIsEmpty_ExpectToken(t *testing.T) {
+	config := VerificationConfig{}
+
+	actual := config.link("usv_live_my-token")
+
+	assert.Equal(t, "usv_live_my-token", actual)
+}
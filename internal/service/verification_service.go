@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/mail"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+const verificationSubject = "Verify your email address"
+
+type VerificationService interface {
+	// Resend sends a new verification link to the user. The links sent
+	// previously stop working.
+	Resend(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error
+	// Confirm marks the email the token was sent to as verified, as long as
+	// it is still the email of the user.
+	Confirm(ctx context.Context, token apikey.VerificationToken) error
+}
+
+type verificationServiceImpl struct {
+	users *userServiceImpl
+}
+
+func NewVerificationService(users *userServiceImpl) VerificationService {
+	return &verificationServiceImpl{
+		users: users,
+	}
+}
+
+func (s *verificationServiceImpl) Resend(ctx context.Context, apiKey apikey.Key, id uuid.UUID) error {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.users.userRepo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return errors.NewCode(EmailAlreadyVerified)
+	}
+
+	return s.users.sendVerification(ctx, user)
+}
+
+func (s *verificationServiceImpl) Confirm(ctx context.Context, token apikey.VerificationToken) error {
+	tokenHashes, err := s.users.digester.candidates(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	verification, err := s.users.emailVerificationRepo.Consume(ctx, tokenHashes)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(InvalidVerificationToken)
+		}
+
+		return err
+	}
+
+	now := time.Now()
+	if verification.ValidUntil.Before(now) {
+		return errors.NewCode(InvalidVerificationToken)
+	}
+
+	// The link doesn't prove anything about an address the user switched
+	// to after it was sent.
+	err = s.users.userRepo.VerifyEmail(ctx, verification.ApiUser, verification.Email, now)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(InvalidVerificationToken)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// sendVerification mails a new verification link to the user, replacing the
+// ones sent previously. Nothing is sent when no mailer is configured.
+func (s *userServiceImpl) sendVerification(ctx context.Context, user persistence.User) error {
+	if s.mailer == nil {
+		return nil
+	}
+
+	token := apikey.GenerateVerificationToken()
+	tokenHash, err := s.digester.digest(ctx, token.String())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	verification := persistence.EmailVerification{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  tokenHash,
+		CreatedAt:  now,
+		ValidUntil: now.Add(s.verification.TokenValidity),
+	}
+
+	err = s.storeVerification(ctx, verification)
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: verificationSubject,
+		Body: fmt.Sprintf(
+			"Please confirm that this is your email address by following the link below:\n\n%s\n\nThe link expires on %s. You can ignore this message if you did not create an account.\n",
+			s.verification.link(token.String()),
+			verification.ValidUntil.UTC().Format(time.RFC1123),
+		),
+	}
+	return s.mailer.Send(ctx, message)
+}
+
+func (s *userServiceImpl) storeVerification(ctx context.Context, verification persistence.EmailVerification) error {
+	tx, err := s.conn.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Close(ctx)
+
+	err = s.emailVerificationRepo.DeleteExpired(ctx, tx, verification.CreatedAt)
+	if err != nil {
+		return err
+	}
+	err = s.emailVerificationRepo.DeleteForUser(ctx, tx, verification.ApiUser)
+	if err != nil {
+		return err
+	}
+	_, err = s.emailVerificationRepo.Create(ctx, tx, verification)
+	return err
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verificationTestConfig = VerificationConfig{
+	TokenValidity: 1 * time.Hour,
+	Url:           "https://example.com/verify",
+}
+
+func TestIT_UserService_Create_ExpectVerificationLinkIsSent(t *testing.T) {
+	users, _, mailer, _ := newTestVerificationService(t, verificationTestConfig)
+	request := newTestVerificationUserRequest()
+
+	out, err := users.Create(context.Background(), request, communication.SelfView)
+
+	assert.Nil(t, err)
+	self, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	assert.Nil(t, self.EmailVerifiedAt)
+	require.Len(t, mailer.messages, 1)
+	assert.Equal(t, request.Email, mailer.messages[0].To)
+	assert.Equal(t, verificationSubject, mailer.messages[0].Subject)
+	assert.Contains(t, mailer.messages[0].Body, "https://example.com/verify?token=usv_live_")
+}
+
+func TestIT_VerificationService_Confirm(t *testing.T) {
+	users, service, mailer, _ := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	token := mailer.lastVerificationToken(t, user.Email)
+
+	err := service.Confirm(context.Background(), token)
+
+	assert.Nil(t, err)
+	assertEmailVerified(t, users, user.Id, true)
+}
+
+func TestIT_VerificationService_Confirm_WhenTokenIsUnknown_ExpectInvalidToken(t *testing.T) {
+	_, service, _, _ := newTestVerificationService(t, verificationTestConfig)
+
+	err := service.Confirm(context.Background(), apikey.GenerateVerificationToken())
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationToken), "Actual err: %v", err)
+}
+
+func TestIT_VerificationService_Confirm_WhenTokenWasAlreadyUsed_ExpectInvalidToken(t *testing.T) {
+	users, service, mailer, _ := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	token := mailer.lastVerificationToken(t, user.Email)
+	err := service.Confirm(context.Background(), token)
+	require.Nil(t, err)
+
+	err = service.Confirm(context.Background(), token)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationToken), "Actual err: %v", err)
+}
+
+func TestIT_VerificationService_Confirm_WhenTokenIsExpired_ExpectInvalidToken(t *testing.T) {
+	config := verificationTestConfig
+	config.TokenValidity = -1 * time.Minute
+	users, service, mailer, _ := newTestVerificationService(t, config)
+	user := createTestVerificationUser(t, users)
+	token := mailer.lastVerificationToken(t, user.Email)
+
+	err := service.Confirm(context.Background(), token)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationToken), "Actual err: %v", err)
+	assertEmailVerified(t, users, user.Id, false)
+}
+
+func TestIT_VerificationService_Confirm_WhenEmailChanged_ExpectInvalidToken(t *testing.T) {
+	users, service, mailer, _ := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	token := mailer.lastVerificationToken(t, user.Email)
+	update := communication.UserDtoRequest{
+		Email: "other-" + user.Email,
+	}
+	_, err := users.Update(context.Background(), user.Id, update, communication.SelfView)
+	require.Nil(t, err)
+
+	err = service.Confirm(context.Background(), token)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationToken), "Actual err: %v", err)
+	assertEmailVerified(t, users, user.Id, false)
+}
+
+func TestIT_UserService_Update_WhenEmailChanges_ExpectVerificationIsReset(t *testing.T) {
+	users, service, mailer, _ := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	err := service.Confirm(context.Background(), mailer.lastVerificationToken(t, user.Email))
+	require.Nil(t, err)
+	update := communication.UserDtoRequest{
+		Email: "other-" + user.Email,
+	}
+
+	_, err = users.Update(context.Background(), user.Id, update, communication.SelfView)
+
+	assert.Nil(t, err)
+	assertEmailVerified(t, users, user.Id, false)
+	err = service.Confirm(context.Background(), mailer.lastVerificationToken(t, update.Email))
+	assert.Nil(t, err)
+	assertEmailVerified(t, users, user.Id, true)
+}
+
+func TestIT_VerificationService_Resend_ExpectPreviousLinkStopsWorking(t *testing.T) {
+	users, service, mailer, conn := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	previous := mailer.lastVerificationToken(t, user.Email)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.Resend(context.Background(), key.Key, user.Id)
+
+	assert.Nil(t, err)
+	assert.Len(t, mailer.messages, 2)
+	err = service.Confirm(context.Background(), previous)
+	assert.True(t, errors.IsErrorWithCode(err, InvalidVerificationToken), "Actual err: %v", err)
+	err = service.Confirm(context.Background(), mailer.lastVerificationToken(t, user.Email))
+	assert.Nil(t, err)
+}
+
+func TestIT_VerificationService_Resend_WhenKeyBelongsToAnotherUser_ExpectPermissionDenied(t *testing.T) {
+	users, service, _, conn := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	other := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, other.Id)
+
+	err := service.Resend(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_VerificationService_Resend_WhenEmailIsVerified_ExpectAlreadyVerified(t *testing.T) {
+	users, service, mailer, conn := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+	err := service.Confirm(context.Background(), mailer.lastVerificationToken(t, user.Email))
+	require.Nil(t, err)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	err = service.Resend(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, EmailAlreadyVerified), "Actual err: %v", err)
+}
+
+func TestIT_UserService_Login_WhenVerificationIsRequiredAndEmailIsNotVerified_ExpectFailure(t *testing.T) {
+	config := verificationTestConfig
+	config.Required = true
+	users, _, mailer, _ := newTestVerificationService(t, config)
+	user := createTestVerificationUser(t, users)
+
+	_, err := users.Login(context.Background(), newTestVerificationLoginRequest(user), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, EmailNotVerified), "Actual err: %v", err)
+	assert.Len(t, mailer.messages, 2)
+}
+
+func TestIT_UserService_Login_WhenVerificationIsRequiredAndEmailIsVerified_ExpectSuccess(t *testing.T) {
+	config := verificationTestConfig
+	config.Required = true
+	users, service, mailer, _ := newTestVerificationService(t, config)
+	user := createTestVerificationUser(t, users)
+	err := service.Confirm(context.Background(), mailer.lastVerificationToken(t, user.Email))
+	require.Nil(t, err)
+
+	out, err := openTestSession(t, users, newTestVerificationLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, out.User)
+}
+
+func TestIT_UserService_Login_WhenVerificationIsNotRequired_ExpectUnverifiedUsersCanLogIn(t *testing.T) {
+	users, _, _, _ := newTestVerificationService(t, verificationTestConfig)
+	user := createTestVerificationUser(t, users)
+
+	out, err := openTestSession(t, users, newTestVerificationLoginRequest(user), ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, out.User)
+}
+
+func newTestVerificationService(t *testing.T, config VerificationConfig) (UserService, VerificationService, *testMailer, db.Connection) {
+	conn := newTestConnection(t)
+
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+	mailer := &testMailer{}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, config, nil, nil, mailer, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewVerificationService(users)
+	return users, service, mailer, conn
+}
+
+func newTestVerificationUserRequest() communication.UserDtoRequest {
+	return communication.UserDtoRequest{
+		Email:    fmt.Sprintf("my-user-%s@example.com", uuid.New()),
+		Password: "my-password",
+	}
+}
+
+func createTestVerificationUser(t *testing.T, users UserService) communication.UserSelfDtoResponse {
+	out, err := users.Create(context.Background(), newTestVerificationUserRequest(), communication.SelfView)
+	require.Nil(t, err)
+	self, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	return self
+}
+
+func newTestVerificationLoginRequest(user communication.UserSelfDtoResponse) communication.UserDtoRequest {
+	return communication.UserDtoRequest{
+		Email:    user.Email,
+		Password: "my-password",
+	}
+}
+
+func assertEmailVerified(t *testing.T, users UserService, id uuid.UUID, expected bool) {
+	out, err := users.Get(context.Background(), id, communication.SelfView)
+	require.Nil(t, err)
+	self, ok := out.(communication.UserSelfDtoResponse)
+	require.True(t, ok, "Actual: %T", out)
+	require.Equal(t, expected, self.EmailVerifiedAt != nil)
+}
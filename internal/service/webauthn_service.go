@@ -0,0 +1,475 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/apikey"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+)
+
+const publicKeyCredentialType = "public-key"
+
+type WebAuthnService interface {
+	// StartRegistration returns the options to create a passkey with. Only
+	// available to the user themselves.
+	StartRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.WebAuthnCreationOptionsDtoResponse, error)
+	// FinishRegistration stores the passkey created by the browser. It is
+	// asked for at login from then on.
+	FinishRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.WebAuthnRegistrationDtoRequest) (communication.WebAuthnCredentialDtoResponse, error)
+	ListCredentials(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.WebAuthnCredentialDtoResponse, error)
+	DeleteCredential(ctx context.Context, apiKey apikey.Key, id uuid.UUID, credential uuid.UUID) error
+	// StartLogin returns the options to log in with a passkey, without a
+	// password: the browser picks the passkey.
+	StartLogin(ctx context.Context) (communication.WebAuthnRequestOptionsDtoResponse, error)
+	FinishLogin(ctx context.Context, assertion communication.WebAuthnAssertionDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error)
+	// StartSecondFactor returns the options to answer the challenge of a
+	// login with one of the passkeys of the user.
+	StartSecondFactor(ctx context.Context, request communication.MfaChallengeDtoRequest) (communication.WebAuthnRequestOptionsDtoResponse, error)
+	CompleteSecondFactor(ctx context.Context, request communication.WebAuthnMfaDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error)
+}
+
+type webAuthnServiceImpl struct {
+	users *userServiceImpl
+	rp    *webauthn.RelyingParty
+
+	ceremonyRepo   repositories.WebAuthnCeremonyRepository
+	credentialRepo repositories.WebAuthnCredentialRepository
+
+	ceremonyValidity time.Duration
+}
+
+// NewWebAuthnService creates a service registering and verifying the passkeys
+// of the users. It requires a relying party.
+func NewWebAuthnService(config webauthn.Config, rp *webauthn.RelyingParty, repos repositories.Repositories, users *userServiceImpl) WebAuthnService {
+	return &webAuthnServiceImpl{
+		users: users,
+		rp:    rp,
+
+		ceremonyRepo:   repos.WebAuthnCeremony,
+		credentialRepo: repos.WebAuthnCredential,
+
+		ceremonyValidity: config.CeremonyValidity,
+	}
+}
+
+func (s *webAuthnServiceImpl) StartRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID) (communication.WebAuthnCreationOptionsDtoResponse, error) {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return communication.WebAuthnCreationOptionsDtoResponse{}, err
+	}
+
+	user, err := s.users.userRepo.Get(ctx, id)
+	if err != nil {
+		return communication.WebAuthnCreationOptionsDtoResponse{}, err
+	}
+
+	existing, err := s.credentialRepo.List(ctx, user.Id)
+	if err != nil {
+		return communication.WebAuthnCreationOptionsDtoResponse{}, err
+	}
+
+	challenge, err := s.startCeremony(ctx, persistence.WebAuthnRegistration, &user.Id)
+	if err != nil {
+		return communication.WebAuthnCreationOptionsDtoResponse{}, err
+	}
+
+	out := communication.WebAuthnCreationOptionsDtoResponse{
+		Challenge: challenge,
+		Rp: communication.WebAuthnRelyingPartyDto{
+			Id:   s.rp.Id(),
+			Name: s.rp.Name(),
+		},
+		User: communication.WebAuthnUserDto{
+			Id:          webauthn.EncodeBytes(user.Id[:]),
+			Name:        user.Email,
+			DisplayName: user.Email,
+		},
+		Timeout:            s.ceremonyValidity.Milliseconds(),
+		ExcludeCredentials: toCredentialDescriptors(existing),
+		AuthenticatorSelection: communication.WebAuthnAuthenticatorSelectionDto{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		// The authenticators are not checked against a list of trusted
+		// models: their attestation is not needed.
+		Attestation: "none",
+	}
+	for _, algorithm := range webauthn.SupportedAlgorithms {
+		out.PubKeyCredParams = append(out.PubKeyCredParams, communication.WebAuthnCredentialParametersDto{
+			Type: publicKeyCredentialType,
+			Alg:  algorithm,
+		})
+	}
+
+	return out, nil
+}
+
+func (s *webAuthnServiceImpl) FinishRegistration(ctx context.Context, apiKey apikey.Key, id uuid.UUID, request communication.WebAuthnRegistrationDtoRequest) (communication.WebAuthnCredentialDtoResponse, error) {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return communication.WebAuthnCredentialDtoResponse{}, err
+	}
+
+	registration := webauthn.Registration{
+		Id:                request.Credential.Id,
+		ClientDataJson:    request.Credential.Response.ClientDataJson,
+		AttestationObject: request.Credential.Response.AttestationObject,
+		Transports:        request.Credential.Response.Transports,
+	}
+	challenge, err := registration.Challenge()
+	if err != nil {
+		return communication.WebAuthnCredentialDtoResponse{}, errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	ceremony, err := s.consumeCeremony(ctx, challenge, persistence.WebAuthnRegistration)
+	if err != nil {
+		return communication.WebAuthnCredentialDtoResponse{}, err
+	}
+	if ceremony.ApiUser == nil || *ceremony.ApiUser != id {
+		return communication.WebAuthnCredentialDtoResponse{}, errors.NewCode(InvalidWebAuthnCeremony)
+	}
+
+	verified, err := s.rp.VerifyRegistration(registration, challenge, false)
+	if err != nil {
+		return communication.WebAuthnCredentialDtoResponse{}, errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	credential := persistence.WebAuthnCredential{
+		Id:             uuid.New(),
+		ApiUser:        id,
+		CredentialId:   verified.Id,
+		PublicKey:      verified.PublicKey,
+		SignCount:      int64(verified.SignCount),
+		Transports:     verified.Transports,
+		Aaguid:         verified.Aaguid,
+		Name:           name,
+		BackupEligible: verified.BackupEligible,
+		CreatedAt:      time.Now(),
+	}
+	credential, err = s.credentialRepo.Create(ctx, credential)
+	if err != nil {
+		if errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation) {
+			return communication.WebAuthnCredentialDtoResponse{}, errors.NewCode(WebAuthnCredentialAlreadyRegistered)
+		}
+		return communication.WebAuthnCredentialDtoResponse{}, err
+	}
+
+	return communication.ToWebAuthnCredentialDtoResponse(credential), nil
+}
+
+func (s *webAuthnServiceImpl) ListCredentials(ctx context.Context, apiKey apikey.Key, id uuid.UUID) ([]communication.WebAuthnCredentialDtoResponse, error) {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentialRepo.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]communication.WebAuthnCredentialDtoResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		out = append(out, communication.ToWebAuthnCredentialDtoResponse(credential))
+	}
+
+	return out, nil
+}
+
+func (s *webAuthnServiceImpl) DeleteCredential(ctx context.Context, apiKey apikey.Key, id uuid.UUID, credential uuid.UUID) error {
+	err := s.users.ensureOwnAccount(ctx, apiKey, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.credentialRepo.Delete(ctx, id, credential)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return errors.NewCode(UnknownWebAuthnCredential)
+	}
+	return err
+}
+
+func (s *webAuthnServiceImpl) StartLogin(ctx context.Context) (communication.WebAuthnRequestOptionsDtoResponse, error) {
+	challenge, err := s.startCeremony(ctx, persistence.WebAuthnLogin, nil)
+	if err != nil {
+		return communication.WebAuthnRequestOptionsDtoResponse{}, err
+	}
+
+	out := communication.WebAuthnRequestOptionsDtoResponse{
+		Challenge:        challenge,
+		Timeout:          s.ceremonyValidity.Milliseconds(),
+		RpId:             s.rp.Id(),
+		AllowCredentials: []communication.WebAuthnCredentialDescriptorDto{},
+		// The passkey replaces the password: it must verify the user and
+		// not only their presence.
+		UserVerification: "required",
+	}
+	return out, nil
+}
+
+func (s *webAuthnServiceImpl) FinishLogin(ctx context.Context, request communication.WebAuthnAssertionDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	assertion := toAssertion(request)
+	challenge, err := assertion.Challenge()
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	_, err = s.consumeCeremony(ctx, challenge, persistence.WebAuthnLogin)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	credential, err := s.getCredential(ctx, assertion.Id)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	// The browser tells which user it picked the passkey for: it must be
+	// the one the passkey was registered for.
+	if assertion.UserHandle != "" {
+		handle, err := webauthn.DecodeBytes(assertion.UserHandle)
+		if err != nil || !bytes.Equal(handle, credential.ApiUser[:]) {
+			return communication.ApiKeyDtoResponse{}, errors.NewCode(UnknownWebAuthnCredential)
+		}
+	}
+
+	user, err := s.users.userRepo.Get(ctx, credential.ApiUser)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	attempt, err := s.users.throttle.attempt(ctx, user.Email, client.Ip)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.useCredential(ctx, credential, assertion, challenge, true)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.users.throttle.forget(ctx, attempt)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	err = s.users.throttle.reset(ctx, user.Email)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	return s.users.openSession(ctx, user.Id, client, oauthGrant{})
+}
+
+func (s *webAuthnServiceImpl) StartSecondFactor(ctx context.Context, request communication.MfaChallengeDtoRequest) (communication.WebAuthnRequestOptionsDtoResponse, error) {
+	mfaChallenge, err := s.users.getMfaChallenge(ctx, request.Challenge)
+	if err != nil {
+		return communication.WebAuthnRequestOptionsDtoResponse{}, err
+	}
+
+	credentials, err := s.credentialRepo.List(ctx, mfaChallenge.ApiUser)
+	if err != nil {
+		return communication.WebAuthnRequestOptionsDtoResponse{}, err
+	}
+	if len(credentials) == 0 {
+		return communication.WebAuthnRequestOptionsDtoResponse{}, errors.NewCode(UnknownWebAuthnCredential)
+	}
+
+	challenge, err := s.startCeremony(ctx, persistence.WebAuthnSecondFactor, &mfaChallenge.ApiUser)
+	if err != nil {
+		return communication.WebAuthnRequestOptionsDtoResponse{}, err
+	}
+
+	out := communication.WebAuthnRequestOptionsDtoResponse{
+		Challenge:        challenge,
+		Timeout:          s.ceremonyValidity.Milliseconds(),
+		RpId:             s.rp.Id(),
+		AllowCredentials: toCredentialDescriptors(credentials),
+		UserVerification: "preferred",
+	}
+	return out, nil
+}
+
+func (s *webAuthnServiceImpl) CompleteSecondFactor(ctx context.Context, request communication.WebAuthnMfaDtoRequest, client ClientInfo) (communication.ApiKeyDtoResponse, error) {
+	mfaChallenge, err := s.users.getMfaChallenge(ctx, request.Challenge)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	assertion := toAssertion(request.Credential)
+	challenge, err := assertion.Challenge()
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	ceremony, err := s.consumeCeremony(ctx, challenge, persistence.WebAuthnSecondFactor)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	if ceremony.ApiUser == nil || *ceremony.ApiUser != mfaChallenge.ApiUser {
+		return communication.ApiKeyDtoResponse{}, errors.NewCode(InvalidWebAuthnCeremony)
+	}
+
+	credential, err := s.getCredential(ctx, assertion.Id)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+	if credential.ApiUser != mfaChallenge.ApiUser {
+		return communication.ApiKeyDtoResponse{}, errors.NewCode(UnknownWebAuthnCredential)
+	}
+
+	user, err := s.users.userRepo.Get(ctx, mfaChallenge.ApiUser)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	attempt, err := s.users.throttle.attempt(ctx, user.Email, client.Ip)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.useCredential(ctx, credential, assertion, challenge, false)
+	if errors.IsErrorWithCode(err, InvalidWebAuthnCredential) {
+		if failureErr := s.users.recordMfaFailure(ctx, mfaChallenge.Id); failureErr != nil {
+			return communication.ApiKeyDtoResponse{}, failureErr
+		}
+	}
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	err = s.users.throttle.forget(ctx, attempt)
+	if err != nil {
+		return communication.ApiKeyDtoResponse{}, err
+	}
+
+	return s.users.completeMfaLogin(ctx, mfaChallenge, user, client)
+}
+
+// startCeremony generates the challenge sent to the browser. Only its digest
+// is persisted, like the other tokens.
+func (s *webAuthnServiceImpl) startCeremony(ctx context.Context, kind persistence.WebAuthnCeremonyKind, user *uuid.UUID) (string, error) {
+	challenge := webauthn.NewChallenge()
+	challengeHash, err := s.users.digester.digest(ctx, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.users.conn.BeginTx(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Close(ctx)
+
+	now := time.Now()
+	err = s.ceremonyRepo.DeleteExpired(ctx, tx, now)
+	if err != nil {
+		return "", err
+	}
+
+	ceremony := persistence.WebAuthnCeremony{
+		Id:            uuid.New(),
+		ChallengeHash: challengeHash,
+		Kind:          kind,
+		ApiUser:       user,
+		CreatedAt:     now,
+		ValidUntil:    now.Add(s.ceremonyValidity),
+	}
+	_, err = s.ceremonyRepo.Create(ctx, tx, ceremony)
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// consumeCeremony returns the ceremony the browser answered. It can't be
+// answered again, even when the answer turns out to be invalid.
+func (s *webAuthnServiceImpl) consumeCeremony(ctx context.Context, challenge string, kind persistence.WebAuthnCeremonyKind) (persistence.WebAuthnCeremony, error) {
+	challengeHashes, err := s.users.digester.candidates(ctx, challenge)
+	if err != nil {
+		return persistence.WebAuthnCeremony{}, err
+	}
+
+	ceremony, err := s.ceremonyRepo.Consume(ctx, challengeHashes, kind)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.WebAuthnCeremony{}, errors.NewCode(InvalidWebAuthnCeremony)
+		}
+		return persistence.WebAuthnCeremony{}, err
+	}
+	if !ceremony.ValidUntil.After(time.Now()) {
+		return persistence.WebAuthnCeremony{}, errors.NewCode(InvalidWebAuthnCeremony)
+	}
+
+	return ceremony, nil
+}
+
+func (s *webAuthnServiceImpl) getCredential(ctx context.Context, encodedId string) (persistence.WebAuthnCredential, error) {
+	credentialId, err := webauthn.DecodeBytes(encodedId)
+	if err != nil {
+		return persistence.WebAuthnCredential{}, errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	credential, err := s.credentialRepo.Get(ctx, credentialId)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return persistence.WebAuthnCredential{}, errors.NewCode(UnknownWebAuthnCredential)
+		}
+		return persistence.WebAuthnCredential{}, err
+	}
+
+	return credential, nil
+}
+
+// useCredential verifies the signature of the challenge and records the new
+// sign count of the credential.
+func (s *webAuthnServiceImpl) useCredential(ctx context.Context, credential persistence.WebAuthnCredential, assertion webauthn.Assertion, challenge string, requireUserVerification bool) error {
+	signCount, err := s.rp.VerifyAssertion(assertion, challenge, credential.PublicKey, uint32(credential.SignCount), requireUserVerification)
+	if err != nil {
+		return errors.WrapCode(err, InvalidWebAuthnCredential)
+	}
+
+	// Two concurrent uses of a cloned authenticator may present the same
+	// sign count: only one of them is accepted.
+	err = s.credentialRepo.Use(ctx, credential.Id, credential.SignCount, int64(signCount), time.Now())
+	if errors.IsErrorWithCode(err, repositories.OptimisticLockException) {
+		return errors.NewCode(InvalidWebAuthnCredential)
+	}
+	return err
+}
+
+func toAssertion(request communication.WebAuthnAssertionDtoRequest) webauthn.Assertion {
+	return webauthn.Assertion{
+		Id:                request.Id,
+		ClientDataJson:    request.Response.ClientDataJson,
+		AuthenticatorData: request.Response.AuthenticatorData,
+		Signature:         request.Response.Signature,
+		UserHandle:        request.Response.UserHandle,
+	}
+}
+
+func toCredentialDescriptors(credentials []persistence.WebAuthnCredential) []communication.WebAuthnCredentialDescriptorDto {
+	out := make([]communication.WebAuthnCredentialDescriptorDto, 0, len(credentials))
+	for _, credential := range credentials {
+		out = append(out, communication.WebAuthnCredentialDescriptorDto{
+			Type:       publicKeyCredentialType,
+			Id:         webauthn.EncodeBytes(credential.CredentialId),
+			Transports: credential.Transports,
+		})
+	}
+	return out
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn/webauthntest"
+	"github.com/Knoblauchpilze/user-service/pkg/communication"
+	"github.com/Knoblauchpilze/user-service/pkg/repositories"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_WebAuthnService_FinishLogin_WhenClientDataIsMalformed_ExpectInvalidCredential(t *testing.T) {
+	users := NewUserService(ApiKeyConfig{}, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, nil, nil, nil, nil, repositories.Repositories{})
+	service := NewWebAuthnService(newTestWebAuthnConfig(), newTestRelyingParty(t), repositories.Repositories{}, users)
+
+	request := communication.WebAuthnAssertionDtoRequest{
+		Id: "AQID",
+		Response: communication.WebAuthnAssertionResponseDto{
+			ClientDataJson: "not-client-data",
+		},
+	}
+	_, err := service.FinishLogin(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestUnit_WebAuthnService_StartSecondFactor_WhenChallengeIsMalformed_ExpectInvalidChallenge(t *testing.T) {
+	users := NewUserService(ApiKeyConfig{}, AdminConfig{}, LoginThrottleConfig{}, VerificationConfig{}, nil, nil, nil, nil, nil, nil, nil, repositories.Repositories{})
+	service := NewWebAuthnService(newTestWebAuthnConfig(), newTestRelyingParty(t), repositories.Repositories{}, users)
+
+	request := communication.MfaChallengeDtoRequest{
+		Challenge: "not-a-challenge",
+	}
+	_, err := service.StartSecondFactor(context.Background(), request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidMfaChallenge), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_StartRegistration(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	options, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+
+	assert.Nil(t, err)
+	assert.NotEmpty(t, options.Challenge)
+	assert.Equal(t, "example.com", options.Rp.Id)
+	assert.Equal(t, webauthn.EncodeBytes(user.Id[:]), options.User.Id)
+	assert.Equal(t, user.Email, options.User.Name)
+	assert.Len(t, options.PubKeyCredParams, len(webauthn.SupportedAlgorithms))
+	assert.Empty(t, options.ExcludeCredentials)
+}
+
+func TestIT_WebAuthnService_StartRegistration_WhenKeyBelongsToAnotherUser_ExpectPermissionDenied(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, other.Id)
+
+	_, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, PermissionDenied), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishRegistration_ExpectSecondFactorIsRequired(t *testing.T) {
+	users, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user.Id[:])
+
+	options, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	registration := authenticator.Create(t, credential, authenticator.Ceremony(options.Challenge))
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Name:       "my-passkey",
+		Credential: toTestAttestationDto(registration),
+	}
+	actual, err := service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "my-passkey", actual.Name)
+	assert.Equal(t, []string{"internal", "hybrid"}, actual.Transports)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	require.NotNil(t, login.Challenge)
+	assert.Equal(t, []string{WebAuthnMethod}, login.Challenge.Methods)
+}
+
+func TestIT_WebAuthnService_FinishRegistration_WhenCeremonyWasUsed_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user.Id[:])
+	options, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Credential: toTestAttestationDto(authenticator.Create(t, credential, authenticator.Ceremony(options.Challenge))),
+	}
+	_, err = service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+	require.Nil(t, err)
+
+	_, err = service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCeremony), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishRegistration_WhenCeremonyBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	other := insertTestUser(t, conn)
+	otherKey := insertApiKeyForUser(t, conn, other.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user.Id[:])
+	options, err := service.StartRegistration(context.Background(), otherKey.Key, other.Id)
+	require.Nil(t, err)
+
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Credential: toTestAttestationDto(authenticator.Create(t, credential, authenticator.Ceremony(options.Challenge))),
+	}
+	_, err = service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCeremony), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishRegistration_WhenCredentialIsAlreadyRegistered_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+	options, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+	require.Len(t, options.ExcludeCredentials, 1)
+
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Credential: toTestAttestationDto(authenticator.Create(t, credential, authenticator.Ceremony(options.Challenge))),
+	}
+	_, err = service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, WebAuthnCredentialAlreadyRegistered), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishRegistration_WhenOriginIsNotAllowed_ExpectInvalidCredential(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user.Id[:])
+	options, err := service.StartRegistration(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+
+	ceremony := authenticator.Ceremony(options.Challenge)
+	ceremony.Origin = "https://phishing.example.net"
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Credential: toTestAttestationDto(authenticator.Create(t, credential, ceremony)),
+	}
+	_, err = service.FinishRegistration(context.Background(), key.Key, user.Id, request)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_ListCredentials(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	registerTestPasskey(t, service, newTestWebAuthnAuthenticator(), key, user.Id)
+
+	actual, err := service.ListCredentials(context.Background(), key.Key, user.Id)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.True(t, actual[0].Synced)
+	assert.Nil(t, actual[0].LastUsedAt)
+}
+
+func TestIT_WebAuthnService_DeleteCredential_ExpectSecondFactorIsNoLongerRequired(t *testing.T) {
+	users, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	registerTestPasskey(t, service, newTestWebAuthnAuthenticator(), key, user.Id)
+	credentials, err := service.ListCredentials(context.Background(), key.Key, user.Id)
+	require.Nil(t, err)
+
+	err = service.DeleteCredential(context.Background(), key.Key, user.Id, credentials[0].Id)
+
+	assert.Nil(t, err)
+	_, err = openTestSession(t, users, newTestLoginRequest(user), ClientInfo{})
+	assert.Nil(t, err)
+}
+
+func TestIT_WebAuthnService_DeleteCredential_WhenCredentialDoesNotExist_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+
+	err := service.DeleteCredential(context.Background(), key.Key, user.Id, uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishLogin(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, "required", options.UserVerification)
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))
+	apiKey, err := service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, apiKey.User)
+	assertApiKeyExistsByKey(t, conn, apiKey.Key)
+}
+
+func TestIT_WebAuthnService_FinishLogin_WhenUserIsNotVerified_ExpectInvalidCredential(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+
+	ceremony := authenticator.Ceremony(options.Challenge)
+	ceremony.Flags &^= webauthntest.FlagUserVerified
+	assertion := authenticator.Get(t, credential, ceremony)
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishLogin_WhenSignCountRegresses_ExpectInvalidCredential(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+	credential.Synced = false
+	loginWithTestPasskey(t, service, authenticator, credential)
+
+	// A clone of the authenticator still has the previous count.
+	credential.SignCount = 0
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishLogin_WhenCeremonyWasUsed_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+	require.Nil(t, err)
+
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidWebAuthnCeremony), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_FinishLogin_WhenCredentialIsUnknown_ExpectFailure(t *testing.T) {
+	_, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user.Id[:])
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownWebAuthnCredential), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnService_CompleteSecondFactor(t *testing.T) {
+	users, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	credential := registerTestPasskey(t, service, authenticator, key, user.Id)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	require.NotNil(t, login.Challenge)
+
+	options, err := service.StartSecondFactor(context.Background(), communication.MfaChallengeDtoRequest{Challenge: login.Challenge.Challenge})
+	require.Nil(t, err)
+	require.Len(t, options.AllowCredentials, 1)
+	assert.Equal(t, webauthn.EncodeBytes(credential.Id), options.AllowCredentials[0].Id)
+	request := communication.WebAuthnMfaDtoRequest{
+		Challenge:  login.Challenge.Challenge,
+		Credential: toTestAssertionDto(authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))),
+	}
+	apiKey, err := service.CompleteSecondFactor(context.Background(), request, ClientInfo{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, user.Id, apiKey.User)
+	assertApiKeyExistsByKey(t, conn, apiKey.Key)
+}
+
+func TestIT_WebAuthnService_CompleteSecondFactor_WhenCredentialBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	users, service, conn := newTestWebAuthnService(t)
+	user := insertTestUser(t, conn)
+	key := insertApiKeyForUser(t, conn, user.Id)
+	other := insertTestUser(t, conn)
+	otherKey := insertApiKeyForUser(t, conn, other.Id)
+	authenticator := newTestWebAuthnAuthenticator()
+	registerTestPasskey(t, service, authenticator, key, user.Id)
+	otherCredential := registerTestPasskey(t, service, authenticator, otherKey, other.Id)
+	login, err := users.Login(context.Background(), newTestLoginRequest(user), ClientInfo{})
+	require.Nil(t, err)
+	options, err := service.StartSecondFactor(context.Background(), communication.MfaChallengeDtoRequest{Challenge: login.Challenge.Challenge})
+	require.Nil(t, err)
+
+	request := communication.WebAuthnMfaDtoRequest{
+		Challenge:  login.Challenge.Challenge,
+		Credential: toTestAssertionDto(authenticator.Get(t, otherCredential, authenticator.Ceremony(options.Challenge))),
+	}
+	_, err = service.CompleteSecondFactor(context.Background(), request, ClientInfo{})
+
+	assert.True(t, errors.IsErrorWithCode(err, UnknownWebAuthnCredential), "Actual err: %v", err)
+}
+
+func newTestWebAuthnConfig() webauthn.Config {
+	return webauthn.Config{
+		RpId:             "example.com",
+		RpName:           "user-service",
+		Origins:          []string{"https://app.example.com"},
+		CeremonyValidity: 5 * time.Minute,
+	}
+}
+
+func newTestRelyingParty(t *testing.T) *webauthn.RelyingParty {
+	rp, err := webauthn.New(newTestWebAuthnConfig())
+	require.Nil(t, err)
+	return rp
+}
+
+func newTestWebAuthnAuthenticator() *webauthntest.Authenticator {
+	return webauthntest.NewAuthenticator("example.com", "https://app.example.com")
+}
+
+func newTestWebAuthnService(t *testing.T) (UserService, WebAuthnService, db.Connection) {
+	conn := newTestConnection(t)
+
+	apiKeyConfig := ApiKeyConfig{
+		Validity: 1 * time.Hour,
+	}
+	repos := repositories.Repositories{
+		ApiKey:             repositories.NewApiKeyRepository(conn),
+		EmailVerification:  repositories.NewEmailVerificationRepository(conn),
+		LoginThrottle:      repositories.NewLoginThrottleRepository(conn),
+		MfaChallenge:       repositories.NewMfaChallengeRepository(conn),
+		PasswordHistory:    repositories.NewPasswordHistoryRepository(conn),
+		RecoveryCode:       repositories.NewRecoveryCodeRepository(conn),
+		RefreshToken:       repositories.NewRefreshTokenRepository(conn),
+		Totp:               repositories.NewTotpRepository(conn),
+		User:               repositories.NewUserRepository(conn),
+		WebAuthnCeremony:   repositories.NewWebAuthnCeremonyRepository(conn),
+		WebAuthnCredential: repositories.NewWebAuthnCredentialRepository(conn),
+	}
+
+	users := NewUserService(apiKeyConfig, AdminConfig{}, loginThrottleTestConfig, VerificationConfig{}, nil, nil, nil, newTestNormalizer(), newTestHasher(t), newTestPolicy(t), conn, repos)
+	service := NewWebAuthnService(newTestWebAuthnConfig(), newTestRelyingParty(t), repos, users)
+	return users, service, conn
+}
+
+// registerTestPasskey registers a synced passkey for the user: its sign count
+// stays zero.
+func registerTestPasskey(t *testing.T, service WebAuthnService, authenticator *webauthntest.Authenticator, key testApiKey, user uuid.UUID) *webauthntest.Credential {
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, user[:])
+	credential.Synced = true
+
+	options, err := service.StartRegistration(context.Background(), key.Key, user)
+	require.Nil(t, err)
+	request := communication.WebAuthnRegistrationDtoRequest{
+		Name:       "my-passkey",
+		Credential: toTestAttestationDto(authenticator.Create(t, credential, authenticator.Ceremony(options.Challenge))),
+	}
+	_, err = service.FinishRegistration(context.Background(), key.Key, user, request)
+	require.Nil(t, err)
+
+	return credential
+}
+
+func loginWithTestPasskey(t *testing.T, service WebAuthnService, authenticator *webauthntest.Authenticator, credential *webauthntest.Credential) {
+	options, err := service.StartLogin(context.Background())
+	require.Nil(t, err)
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(options.Challenge))
+	_, err = service.FinishLogin(context.Background(), toTestAssertionDto(assertion), ClientInfo{})
+	require.Nil(t, err)
+}
+
+func toTestAttestationDto(registration webauthntest.Registration) communication.WebAuthnAttestationDto {
+	return communication.WebAuthnAttestationDto{
+		Id:   registration.Id,
+		Type: "public-key",
+		Response: communication.WebAuthnAttestationResponseDto{
+			ClientDataJson:    registration.ClientDataJson,
+			AttestationObject: registration.AttestationObject,
+			Transports:        registration.Transports,
+		},
+	}
+}
+
+func toTestAssertionDto(assertion webauthntest.Assertion) communication.WebAuthnAssertionDtoRequest {
+	return communication.WebAuthnAssertionDtoRequest{
+		Id:   assertion.Id,
+		Type: "public-key",
+		Response: communication.WebAuthnAssertionResponseDto{
+			ClientDataJson:    assertion.ClientDataJson,
+			AuthenticatorData: assertion.AuthenticatorData,
+			Signature:         assertion.Signature,
+			UserHandle:        assertion.UserHandle,
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Authenticator generates the secrets shared with the authenticator apps of
+// the users and verifies the codes they compute. The secrets are encrypted
+// with AES-GCM before being stored; the user they belong to is authenticated
+// as well so that they can't be moved to another row.
+type Authenticator struct {
+	issuer string
+	aead   cipher.AEAD
+}
+
+// Enrollment is a new secret for a user.
+type Enrollment struct {
+	// Secret is the base32 encoded secret, for users typing it.
+	Secret string
+	// Uri is the otpauth URI of the secret, for users scanning it.
+	Uri string
+	// Encrypted is the secret to store.
+	Encrypted []byte
+}
+
+func New(config Config) (*Authenticator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := config.loadEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidEncryptionKey)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidEncryptionKey)
+	}
+
+	return &Authenticator{
+		issuer: config.Issuer,
+		aead:   aead,
+	}, nil
+}
+
+// Enroll generates a secret for the user. The account is shown in the
+// authenticator apps, usually the email of the user.
+func (a *Authenticator) Enroll(user uuid.UUID, account string) Enrollment {
+	secret := generateSecret()
+
+	return Enrollment{
+		Secret:    encodeSecret(secret),
+		Uri:       uri(a.issuer, account, secret),
+		Encrypted: a.encrypt(user, secret),
+	}
+}
+
+// Verify checks the code against the encrypted secret of the user and
+// returns the counter it was generated for. Codes generated for lastCounter
+// or earlier are rejected: the returned counter should be stored to prevent
+// replaying the code.
+func (a *Authenticator) Verify(user uuid.UUID, encrypted []byte, code string, lastCounter int64, now time.Time) (int64, error) {
+	secret, err := a.decrypt(user, encrypted)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, ok := match(secret, code, lastCounter, now)
+	if !ok {
+		return 0, errors.NewCode(InvalidCode)
+	}
+
+	return counter, nil
+}
+
+// encrypt returns the nonce followed by the encrypted secret.
+func (a *Authenticator) encrypt(user uuid.UUID, secret []byte) []byte {
+	nonce := make([]byte, a.aead.NonceSize())
+	rand.Read(nonce)
+
+	return a.aead.Seal(nonce, nonce, secret, user[:])
+}
+
+func (a *Authenticator) decrypt(user uuid.UUID, encrypted []byte) ([]byte, error) {
+	if len(encrypted) < a.aead.NonceSize() {
+		return nil, errors.NewCode(DecryptionFailed)
+	}
+
+	nonce, ciphertext := encrypted[:a.aead.NonceSize()], encrypted[a.aead.NonceSize():]
+	secret, err := a.aead.Open(nil, nonce, ciphertext, user[:])
+	if err != nil {
+		return nil, errors.WrapCode(err, DecryptionFailed)
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_Authenticator_Enroll(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+
+	actual := authenticator.Enroll(uuid.New(), "user@example.com")
+
+	assert.Len(t, actual.Secret, 32)
+	assert.True(t, strings.HasPrefix(actual.Uri, "otpauth://totp/user-service:user@example.com?"), "Actual uri: %s", actual.Uri)
+	assert.Contains(t, actual.Uri, "secret="+actual.Secret)
+	assert.NotContains(t, string(actual.Encrypted), actual.Secret)
+}
+
+func TestUnit_Authenticator_Enroll_GeneratesDifferentSecrets(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+	user := uuid.New()
+
+	first := authenticator.Enroll(user, "user@example.com")
+	second := authenticator.Enroll(user, "user@example.com")
+
+	assert.NotEqual(t, first.Secret, second.Secret)
+}
+
+func TestUnit_Authenticator_Verify(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+	user := uuid.New()
+	enrollment := authenticator.Enroll(user, "user@example.com")
+	now := time.Now()
+
+	actual, err := authenticator.Verify(user, enrollment.Encrypted, codeFor(t, enrollment, now), 0, now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, counterAt(now), actual)
+}
+
+func TestUnit_Authenticator_Verify_WhenCodeIsWrong_ExpectError(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+	user := uuid.New()
+	enrollment := authenticator.Enroll(user, "user@example.com")
+	now := time.Now()
+	wrong := codeFor(t, enrollment, now.Add(-time.Hour))
+
+	_, err := authenticator.Verify(user, enrollment.Encrypted, wrong, 0, now)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCode), "Actual err: %v", err)
+}
+
+func TestUnit_Authenticator_Verify_WhenCodeWasUsed_ExpectError(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+	user := uuid.New()
+	enrollment := authenticator.Enroll(user, "user@example.com")
+	now := time.Now()
+
+	_, err := authenticator.Verify(user, enrollment.Encrypted, codeFor(t, enrollment, now), counterAt(now), now)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidCode), "Actual err: %v", err)
+}
+
+func TestUnit_Authenticator_Verify_WhenSecretBelongsToAnotherUser_ExpectError(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+	enrollment := authenticator.Enroll(uuid.New(), "user@example.com")
+	now := time.Now()
+
+	_, err := authenticator.Verify(uuid.New(), enrollment.Encrypted, codeFor(t, enrollment, now), 0, now)
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestUnit_Authenticator_Verify_WhenEncryptionKeyIsDifferent_ExpectError(t *testing.T) {
+	user := uuid.New()
+	enrollment := newTestAuthenticator(t, "k").Enroll(user, "user@example.com")
+	now := time.Now()
+
+	_, err := newTestAuthenticator(t, "o").Verify(user, enrollment.Encrypted, codeFor(t, enrollment, now), 0, now)
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestUnit_Authenticator_Verify_WhenSecretIsTruncated_ExpectError(t *testing.T) {
+	authenticator := newTestAuthenticator(t, "k")
+
+	_, err := authenticator.Verify(uuid.New(), []byte("short"), "123456", 0, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, DecryptionFailed), "Actual err: %v", err)
+}
+
+func TestUnit_New_WhenConfigIsInvalid_ExpectError(t *testing.T) {
+	_, err := New(Config{Issuer: "user-service"})
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEncryptionKey), "Actual err: %v", err)
+}
+
+func newTestAuthenticator(t *testing.T, fill string) *Authenticator {
+	authenticator, err := New(Config{
+		Issuer:        "user-service",
+		EncryptionKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat(fill, encryptionKeyLength))),
+	})
+	require.Nil(t, err)
+	return authenticator
+}
+
+func codeFor(t *testing.T, enrollment Enrollment, at time.Time) string {
+	out, err := Code(enrollment.Secret, at)
+	require.Nil(t, err)
+	return out
+}
@@ -0,0 +1,60 @@
+package totp
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const encryptionKeyLength = 32
+
+type Config struct {
+	// Issuer is shown next to the account in the authenticator apps.
+	Issuer string
+	// EncryptionKey is the base64 encoded 32 bytes key encrypting the
+	// secrets stored in the database. It is meant to come from the
+	// ENV_TOTP_ENCRYPTIONKEY environment variable rather than from the
+	// configuration file. It is not rotated: the secrets can't be recovered
+	// without it.
+	EncryptionKey string
+	// EncryptionKeyFile is the path to a file holding the encryption key, in
+	// the same format. It takes precedence over EncryptionKey. Two-factor
+	// authentication is disabled when neither is set.
+	EncryptionKeyFile string
+}
+
+func (c Config) Enabled() bool {
+	return c.EncryptionKey != "" || c.EncryptionKeyFile != ""
+}
+
+func (c Config) Validate() error {
+	if c.Issuer == "" || strings.Contains(c.Issuer, ":") {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "issuer must be set and can't contain a colon")
+	}
+
+	_, err := c.loadEncryptionKey()
+	return err
+}
+
+func (c Config) loadEncryptionKey() ([]byte, error) {
+	encoded := c.EncryptionKey
+	if c.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(c.EncryptionKeyFile)
+		if err != nil {
+			return nil, errors.WrapCode(err, InvalidEncryptionKey)
+		}
+		encoded = string(data)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidEncryptionKey)
+	}
+	if len(key) != encryptionKeyLength {
+		return nil, errors.NewCode(InvalidEncryptionKey)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var sampleEncryptionKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", encryptionKeyLength)))
+
+func TestUnit_Config_Enabled(t *testing.T) {
+	assert.False(t, Config{}.Enabled())
+	assert.True(t, Config{EncryptionKey: sampleEncryptionKey}.Enabled())
+	assert.True(t, Config{EncryptionKeyFile: "totp.key"}.Enabled())
+}
+
+func TestUnit_Config_Validate(t *testing.T) {
+	err := newTestConfig().Validate()
+
+	assert.Nil(t, err)
+}
+
+func TestUnit_Config_Validate_WhenIssuerIsInvalid_ExpectError(t *testing.T) {
+	for _, issuer := range []string{"", "acme:corp"} {
+		config := newTestConfig()
+		config.Issuer = issuer
+
+		err := config.Validate()
+
+		assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Issuer: %q, actual err: %v", issuer, err)
+	}
+}
+
+func TestUnit_Config_Validate_WhenEncryptionKeyIsInvalid_ExpectError(t *testing.T) {
+	for _, key := range []string{"not-base-64", base64.StdEncoding.EncodeToString([]byte("too-short"))} {
+		config := newTestConfig()
+		config.EncryptionKey = key
+
+		err := config.Validate()
+
+		assert.True(t, errors.IsErrorWithCode(err, InvalidEncryptionKey), "Key: %q, actual err: %v", key, err)
+	}
+}
+
+func TestUnit_Config_LoadEncryptionKey_PrefersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "totp.key")
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("f", encryptionKeyLength)))
+	require.Nil(t, os.WriteFile(path, []byte(encoded+"\n"), 0600))
+	config := newTestConfig()
+	config.EncryptionKeyFile = path
+
+	actual, err := config.loadEncryptionKey()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte(strings.Repeat("f", encryptionKeyLength)), actual)
+}
+
+func TestUnit_Config_LoadEncryptionKey_WhenFileDoesNotExist_ExpectError(t *testing.T) {
+	config := newTestConfig()
+	config.EncryptionKeyFile = filepath.Join(t.TempDir(), "missing.key")
+
+	_, err := config.loadEncryptionKey()
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidEncryptionKey), "Actual err: %v", err)
+}
+
+func newTestConfig() Config {
+	return Config{
+		Issuer:        "user-service",
+		EncryptionKey: sampleEncryptionKey,
+	}
+}
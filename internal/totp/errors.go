@@ -0,0 +1,13 @@
+package totp
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration errors.ErrorCode = 1800
+	InvalidEncryptionKey errors.ErrorCode = 1801
+
+	DecryptionFailed errors.ErrorCode = 1810
+	InvalidCode      errors.ErrorCode = 1811
+)
@@ -0,0 +1,108 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// The parameters are the defaults of RFC 6238: they are the only ones all
+// the authenticator apps support.
+// https://datatracker.ietf.org/doc/html/rfc6238#section-4
+const (
+	secretLength = 20
+	digits       = 6
+	period       = 30 * time.Second
+	// skew is how many periods before and after the current one are
+	// accepted, to tolerate clock drift and slow typing.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func generateSecret() []byte {
+	secret := make([]byte, secretLength)
+	rand.Read(secret)
+	return secret
+}
+
+// encodeSecret returns the secret as typed in the authenticator apps.
+func encodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// uri returns the key URI to share the secret with a QR code.
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func uri(issuer string, account string, secret []byte) string {
+	query := url.Values{}
+	query.Set("secret", encodeSecret(secret))
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(int(period.Seconds())))
+
+	out := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return out.String()
+}
+
+func counterAt(at time.Time) int64 {
+	return at.Unix() / int64(period.Seconds())
+}
+
+// code computes the HOTP value of the counter.
+// https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
+func code(secret []byte, counter int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
+
+// match returns the counter the code was generated for. Counters up to
+// lastCounter are rejected: each code can only be used once.
+func match(secret []byte, candidate string, lastCounter int64, now time.Time) (int64, bool) {
+	if len(candidate) != digits {
+		return 0, false
+	}
+
+	current := counterAt(now)
+	for counter := current - skew; counter <= current+skew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(code(secret, counter)), []byte(candidate)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// Code returns what an authenticator app shows at the time for the base32
+// encoded secret.
+func Code(secret string, at time.Time) (string, error) {
+	decoded, err := encoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return code(decoded, counterAt(at)), nil
+}
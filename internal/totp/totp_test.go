@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc6238#appendix-B
+var rfcSecret = []byte("12345678901234567890")
+
+func TestUnit_Code_MatchesRfcTestVectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for at, expected := range vectors {
+		actual := code(rfcSecret, counterAt(time.Unix(at, 0)))
+
+		assert.Equal(t, expected, actual, "At: %d", at)
+	}
+}
+
+func TestUnit_Match_AcceptsAdjacentPeriods(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := counterAt(now)
+
+	for _, counter := range []int64{current - 1, current, current + 1} {
+		actual, ok := match(rfcSecret, code(rfcSecret, counter), 0, now)
+
+		assert.True(t, ok)
+		assert.Equal(t, counter, actual)
+	}
+}
+
+func TestUnit_Match_RejectsDistantPeriods(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := counterAt(now)
+
+	for _, counter := range []int64{current - 2, current + 2} {
+		_, ok := match(rfcSecret, code(rfcSecret, counter), 0, now)
+
+		assert.False(t, ok)
+	}
+}
+
+func TestUnit_Match_RejectsUsedCounters(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := counterAt(now)
+
+	_, ok := match(rfcSecret, code(rfcSecret, current), current, now)
+
+	assert.False(t, ok)
+}
+
+func TestUnit_Match_RejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	valid := code(rfcSecret, counterAt(now))
+
+	for _, candidate := range []string{"", valid[:5], valid + "0", " " + valid} {
+		_, ok := match(rfcSecret, candidate, 0, now)
+
+		assert.False(t, ok, "Candidate: %q", candidate)
+	}
+}
+
+func TestUnit_Uri_DescribesSecret(t *testing.T) {
+	actual, err := url.Parse(uri("Acme Corp", "user@example.com", rfcSecret))
+	require.Nil(t, err)
+
+	assert.Equal(t, "otpauth", actual.Scheme)
+	assert.Equal(t, "totp", actual.Host)
+	assert.Equal(t, "/Acme Corp:user@example.com", actual.Path)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", actual.Query().Get("secret"))
+	assert.Equal(t, "Acme Corp", actual.Query().Get("issuer"))
+	assert.Equal(t, "6", actual.Query().Get("digits"))
+	assert.Equal(t, "30", actual.Query().Get("period"))
+}
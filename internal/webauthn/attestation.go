@@ -0,0 +1,204 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.w3.org/TR/webauthn-3/#sctn-defined-attestation-formats
+const (
+	noneAttestation    = "none"
+	packedAttestation  = "packed"
+	fidoU2fAttestation = "fido-u2f"
+)
+
+// https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation-cert-requirements
+var aaguidExtension = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+type attestationObject struct {
+	format    string
+	statement map[any]any
+	authData  authenticatorData
+}
+
+func parseAttestationObject(data []byte) (attestationObject, error) {
+	fields, err := cborMapOf(data)
+	if err != nil {
+		return attestationObject{}, err
+	}
+
+	format, _ := fields["fmt"].(string)
+	statement, ok := fields["attStmt"].(map[any]any)
+	if !ok {
+		return attestationObject{}, errors.NewCodeWithDetails(MalformedResponse, "missing attestation statement")
+	}
+	rawAuthData, ok := fields["authData"].([]byte)
+	if !ok {
+		return attestationObject{}, errors.NewCodeWithDetails(MalformedResponse, "missing authenticator data")
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return attestationObject{}, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return attestationObject{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "missing attested credential data")
+	}
+
+	out := attestationObject{
+		format:    format,
+		statement: statement,
+		authData:  authData,
+	}
+	return out, nil
+}
+
+// verify checks the statement of the authenticator about the credential.
+// The service asks browsers not to convey attestations and has no trust
+// anchors: the certificates of the statements are not chained to a root,
+// but the statements must still be consistent with the credential.
+func (a attestationObject) verify(key publicKey, clientDataHash []byte) error {
+	switch a.format {
+	case noneAttestation:
+		if len(a.statement) != 0 {
+			return errors.NewCodeWithDetails(InvalidAttestation, "unexpected statement for none attestation")
+		}
+		return nil
+	case packedAttestation:
+		return a.verifyPacked(key, clientDataHash)
+	case fidoU2fAttestation:
+		return a.verifyFidoU2f(key, clientDataHash)
+	default:
+		return errors.NewCodeWithDetails(UnsupportedAttestation, a.format)
+	}
+}
+
+// https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation
+func (a attestationObject) verifyPacked(key publicKey, clientDataHash []byte) error {
+	algorithm, ok := a.statement["alg"].(int64)
+	if !ok {
+		return errors.NewCodeWithDetails(InvalidAttestation, "missing algorithm")
+	}
+	signature, ok := a.statement["sig"].([]byte)
+	if !ok {
+		return errors.NewCodeWithDetails(InvalidAttestation, "missing signature")
+	}
+	signed := append(append([]byte(nil), a.authData.raw...), clientDataHash...)
+
+	chain, hasChain := a.statement["x5c"]
+	if !hasChain {
+		// Self attestation: the credential signs its own creation.
+		if algorithm != key.algorithm {
+			return errors.NewCodeWithDetails(InvalidAttestation, "algorithm mismatch")
+		}
+		return wrapAttestationError(key.verify(signed, signature))
+	}
+
+	certificate, err := parseAttestationCertificate(chain)
+	if err != nil {
+		return err
+	}
+	if certificate.IsCA {
+		return errors.NewCodeWithDetails(InvalidAttestation, "attestation certificate is a CA")
+	}
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(aaguidExtension) {
+			continue
+		}
+		var aaguid []byte
+		_, err := asn1.Unmarshal(extension.Value, &aaguid)
+		if err != nil || !bytes.Equal(aaguid, a.authData.aaguid) {
+			return errors.NewCodeWithDetails(InvalidAttestation, "aaguid mismatch")
+		}
+	}
+
+	certificateKey, err := certificatePublicKey(certificate, algorithm)
+	if err != nil {
+		return err
+	}
+	return wrapAttestationError(certificateKey.verify(signed, signature))
+}
+
+// https://www.w3.org/TR/webauthn-3/#sctn-fido-u2f-attestation
+func (a attestationObject) verifyFidoU2f(key publicKey, clientDataHash []byte) error {
+	signature, ok := a.statement["sig"].([]byte)
+	if !ok {
+		return errors.NewCodeWithDetails(InvalidAttestation, "missing signature")
+	}
+	certificate, err := parseAttestationCertificate(a.statement["x5c"])
+	if err != nil {
+		return err
+	}
+	certificateKey, err := certificatePublicKey(certificate, AlgorithmES256)
+	if err != nil {
+		return err
+	}
+
+	credentialKey, ok := key.key.(*ecdsa.PublicKey)
+	if !ok {
+		return errors.NewCodeWithDetails(InvalidAttestation, "U2F credentials use P-256 keys")
+	}
+	point, err := credentialKey.Bytes()
+	if err != nil {
+		return errors.WrapCode(err, InvalidAttestation)
+	}
+
+	signed := []byte{0x00}
+	signed = append(signed, a.authData.rpIdHash...)
+	signed = append(signed, clientDataHash...)
+	signed = append(signed, a.authData.credentialId...)
+	signed = append(signed, point...)
+	return wrapAttestationError(certificateKey.verify(signed, signature))
+}
+
+func parseAttestationCertificate(chain any) (*x509.Certificate, error) {
+	certificates, ok := chain.([]any)
+	if !ok || len(certificates) == 0 {
+		return nil, errors.NewCodeWithDetails(InvalidAttestation, "missing certificate")
+	}
+	der, ok := certificates[0].([]byte)
+	if !ok {
+		return nil, errors.NewCodeWithDetails(InvalidAttestation, "invalid certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.WrapCode(err, InvalidAttestation)
+	}
+	return certificate, nil
+}
+
+// certificatePublicKey returns the key of the certificate, which must match
+// the algorithm of the signature.
+func certificatePublicKey(certificate *x509.Certificate, algorithm int64) (publicKey, error) {
+	switch key := certificate.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if algorithm == AlgorithmES256 && key.Curve == elliptic.P256() {
+			return publicKey{algorithm: algorithm, key: key}, nil
+		}
+	case *rsa.PublicKey:
+		if algorithm == AlgorithmRS256 {
+			return publicKey{algorithm: algorithm, key: key}, nil
+		}
+	case ed25519.PublicKey:
+		if algorithm == AlgorithmEdDSA {
+			return publicKey{algorithm: algorithm, key: key}, nil
+		}
+	}
+
+	return publicKey{}, errors.NewCodeWithDetails(InvalidAttestation, "certificate key does not match the algorithm")
+}
+
+func wrapAttestationError(err error) error {
+	if err != nil {
+		return errors.WrapCode(err, InvalidAttestation)
+	}
+	return nil
+}
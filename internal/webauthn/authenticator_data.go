@@ -0,0 +1,102 @@
+package webauthn
+
+import (
+	"encoding/binary"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.w3.org/TR/webauthn-3/#sctn-authenticator-data
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagBackupState    = 0x10
+	flagAttestedData   = 0x40
+	flagExtensionData  = 0x80
+)
+
+const (
+	rpIdHashLength = 32
+	aaguidLength   = 16
+	// Authenticators are not allowed to create longer identifiers.
+	maxCredentialIdLength = 1023
+)
+
+type authenticatorData struct {
+	raw       []byte
+	rpIdHash  []byte
+	flags     byte
+	signCount uint32
+
+	// The attested credential data is only present during registration.
+	aaguid       []byte
+	credentialId []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < rpIdHashLength+1+4 {
+		return authenticatorData{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "authenticator data too short")
+	}
+
+	out := authenticatorData{
+		raw:       data,
+		rpIdHash:  data[:rpIdHashLength],
+		flags:     data[rpIdHashLength],
+		signCount: binary.BigEndian.Uint32(data[rpIdHashLength+1:]),
+	}
+	rest := data[rpIdHashLength+5:]
+
+	if out.flags&flagAttestedData != 0 {
+		if len(rest) < aaguidLength+2 {
+			return authenticatorData{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "truncated attested credential data")
+		}
+		out.aaguid = rest[:aaguidLength]
+		length := int(binary.BigEndian.Uint16(rest[aaguidLength:]))
+		rest = rest[aaguidLength+2:]
+		if length == 0 || length > maxCredentialIdLength || len(rest) < length {
+			return authenticatorData{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "invalid credential id")
+		}
+		out.credentialId = rest[:length]
+		rest = rest[length:]
+
+		// The key is the only item whose length is not known in advance.
+		_, remaining, err := decodeCbor(rest)
+		if err != nil {
+			return authenticatorData{}, errors.WrapCode(err, InvalidAuthenticatorData)
+		}
+		out.publicKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if out.flags&flagExtensionData != 0 {
+		_, remaining, err := decodeCbor(rest)
+		if err != nil {
+			return authenticatorData{}, errors.WrapCode(err, InvalidAuthenticatorData)
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return authenticatorData{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "trailing bytes in authenticator data")
+	}
+
+	return out, nil
+}
+
+func (d authenticatorData) userPresent() bool {
+	return d.flags&flagUserPresent != 0
+}
+
+func (d authenticatorData) userVerified() bool {
+	return d.flags&flagUserVerified != 0
+}
+
+func (d authenticatorData) backupEligible() bool {
+	return d.flags&flagBackupEligible != 0
+}
+
+func (d authenticatorData) backedUp() bool {
+	return d.flags&flagBackupState != 0
+}
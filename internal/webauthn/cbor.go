@@ -0,0 +1,183 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.rfc-editor.org/rfc/rfc8949#section-3.1
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+	cborSimple   = 7
+)
+
+// Authenticators nest a few levels at most: this only protects against
+// crafted inputs.
+const maxCborDepth = 16
+
+// decodeCbor decodes the first item of the data and returns what follows
+// it. Only the subset of CBOR produced by authenticators is supported:
+// integers are returned as int64, byte and text strings as []byte and
+// string, arrays as []any and maps as map[any]any. Tags are ignored, and
+// floats and indefinite lengths are rejected.
+func decodeCbor(data []byte) (any, []byte, error) {
+	return decodeCborItem(data, 0)
+}
+
+func decodeCborItem(data []byte, depth int) (any, []byte, error) {
+	if depth > maxCborDepth {
+		return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "cbor nested too deeply")
+	}
+	if len(data) == 0 {
+		return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "truncated cbor")
+	}
+
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22:
+			return nil, data, nil
+		default:
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "unsupported cbor simple value")
+		}
+	}
+
+	argument, data, err := decodeCborArgument(info, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if argument > math.MaxInt64 {
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "cbor integer overflow")
+		}
+		return int64(argument), data, nil
+	case cborNegative:
+		if argument > math.MaxInt64 {
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "cbor integer overflow")
+		}
+		return -1 - int64(argument), data, nil
+	case cborBytes, cborText:
+		if argument > uint64(len(data)) {
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "truncated cbor")
+		}
+		value := data[:argument]
+		if major == cborText {
+			return string(value), data[argument:], nil
+		}
+		return append([]byte(nil), value...), data[argument:], nil
+	case cborArray:
+		// Each item takes at least one byte.
+		if argument > uint64(len(data)) {
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "truncated cbor")
+		}
+		out := make([]any, 0, argument)
+		for range argument {
+			var item any
+			item, data, err = decodeCborItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			out = append(out, item)
+		}
+		return out, data, nil
+	case cborMap:
+		if argument > uint64(len(data)) {
+			return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "truncated cbor")
+		}
+		out := make(map[any]any, argument)
+		for range argument {
+			var key, value any
+			key, data, err = decodeCborItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "unsupported cbor map key")
+			}
+			if _, ok := out[key]; ok {
+				return nil, nil, errors.NewCodeWithDetails(MalformedResponse, "duplicated cbor map key")
+			}
+
+			value, data, err = decodeCborItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			out[key] = value
+		}
+		return out, data, nil
+	default:
+		return decodeCborItem(data, depth+1)
+	}
+}
+
+func decodeCborArgument(info byte, data []byte) (uint64, []byte, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, errors.NewCodeWithDetails(MalformedResponse, "unsupported cbor length")
+	}
+
+	if len(data) < size {
+		return 0, nil, errors.NewCodeWithDetails(MalformedResponse, "truncated cbor")
+	}
+
+	var argument uint64
+	switch size {
+	case 1:
+		argument = uint64(data[0])
+	case 2:
+		argument = uint64(binary.BigEndian.Uint16(data))
+	case 4:
+		argument = uint64(binary.BigEndian.Uint32(data))
+	case 8:
+		argument = binary.BigEndian.Uint64(data)
+	}
+
+	return argument, data[size:], nil
+}
+
+// cborMapOf decodes data which must hold a single map.
+func cborMapOf(data []byte) (map[any]any, error) {
+	value, rest, err := decodeCbor(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.NewCodeWithDetails(MalformedResponse, "trailing bytes after cbor")
+	}
+
+	out, ok := value.(map[any]any)
+	if !ok {
+		return nil, errors.NewCodeWithDetails(MalformedResponse, "expected a cbor map")
+	}
+	return out, nil
+}
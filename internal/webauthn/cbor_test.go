@@ -0,0 +1,81 @@
+package webauthn
+
+import (
+	"testing"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnit_DecodeCbor(t *testing.T) {
+	type testCase struct {
+		data     []byte
+		expected any
+	}
+
+	// https://www.rfc-editor.org/rfc/rfc8949#appendix-A
+	testCases := map[string]testCase{
+		"smallInteger":    {data: []byte{0x17}, expected: int64(23)},
+		"oneByteInteger":  {data: []byte{0x18, 0x64}, expected: int64(100)},
+		"twoBytesInteger": {data: []byte{0x19, 0x03, 0xe8}, expected: int64(1000)},
+		"negative":        {data: []byte{0x38, 0x63}, expected: int64(-100)},
+		"bytes":           {data: []byte{0x44, 0x01, 0x02, 0x03, 0x04}, expected: []byte{1, 2, 3, 4}},
+		"text":            {data: []byte{0x64, 0x49, 0x45, 0x54, 0x46}, expected: "IETF"},
+		"array":           {data: []byte{0x83, 0x01, 0x02, 0x03}, expected: []any{int64(1), int64(2), int64(3)}},
+		"map": {
+			data:     []byte{0xa2, 0x01, 0x02, 0x61, 0x61, 0xf5},
+			expected: map[any]any{int64(1): int64(2), "a": true},
+		},
+		"null": {data: []byte{0xf6}, expected: nil},
+		"tag":  {data: []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, expected: int64(1363896240)},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			actual, rest, err := decodeCbor(tc.data)
+
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Empty(t, rest)
+		})
+	}
+}
+
+func TestUnit_DecodeCbor_ReturnsFollowingBytes(t *testing.T) {
+	actual, rest, err := decodeCbor([]byte{0x01, 0x02, 0x03})
+
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), actual)
+	assert.Equal(t, []byte{0x02, 0x03}, rest)
+}
+
+func TestUnit_DecodeCbor_WhenDataIsInvalid_ExpectError(t *testing.T) {
+	testCases := map[string][]byte{
+		"empty":            {},
+		"truncatedInteger": {0x19, 0x03},
+		"truncatedBytes":   {0x44, 0x01, 0x02},
+		"truncatedArray":   {0x83, 0x01, 0x02},
+		"indefiniteLength": {0x5f, 0x41, 0x01, 0xff},
+		"float":            {0xf9, 0x3c, 0x00},
+		"duplicatedKey":    {0xa2, 0x01, 0x02, 0x01, 0x03},
+		"unsupportedKey":   {0xa1, 0x41, 0x01, 0x02},
+		"integerOverflow":  {0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"tooDeep":          {0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x81, 0x01},
+		"hugeLength":       {0x9b, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := decodeCbor(data)
+
+			assert.True(t, errors.IsErrorWithCode(err, MalformedResponse), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_CborMapOf_WhenTrailingBytes_ExpectError(t *testing.T) {
+	_, err := cborMapOf([]byte{0xa0, 0x00})
+
+	assert.True(t, errors.IsErrorWithCode(err, MalformedResponse), "Actual err: %v", err)
+}
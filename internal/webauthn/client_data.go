@@ -0,0 +1,52 @@
+package webauthn
+
+import (
+	"encoding/json"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.w3.org/TR/webauthn-3/#dictionary-client-data
+const (
+	createCeremony = "webauthn.create"
+	getCeremony    = "webauthn.get"
+)
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func parseClientData(data []byte) (clientData, error) {
+	var out clientData
+	err := json.Unmarshal(data, &out)
+	if err != nil {
+		return clientData{}, errors.WrapCode(err, MalformedResponse)
+	}
+
+	return out, nil
+}
+
+// verify checks that the browser answered the expected ceremony for one of
+// the origins of the relying party. Embedding the pages in frames of other
+// origins is not supported.
+func (c clientData) verify(ceremony string, challenge string, origins []string) error {
+	if c.Type != ceremony {
+		return errors.NewCodeWithDetails(InvalidClientData, "unexpected ceremony type "+c.Type)
+	}
+	if c.Challenge == "" || c.Challenge != challenge {
+		return errors.NewCodeWithDetails(InvalidClientData, "challenge mismatch")
+	}
+	if c.CrossOrigin {
+		return errors.NewCodeWithDetails(InvalidClientData, "cross origin ceremony")
+	}
+
+	for _, origin := range origins {
+		if c.Origin == origin {
+			return nil
+		}
+	}
+	return errors.NewCodeWithDetails(InvalidClientData, "unexpected origin "+c.Origin)
+}
@@ -0,0 +1,66 @@
+package webauthn
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+type Config struct {
+	// RpId is the domain the credentials are bound to, such as example.com.
+	// Browsers only let the pages served from this domain or one of its
+	// subdomains use them. Passkeys are disabled when it is not set.
+	RpId string
+	// RpName is shown to the users when they create a credential.
+	RpName string
+	// Origins are the origins of the pages allowed to use the credentials,
+	// such as https://app.example.com. Their host must be the RpId or one of
+	// its subdomains.
+	Origins []string
+	// CeremonyValidity is how long users have to answer the prompt of their
+	// browser.
+	CeremonyValidity time.Duration
+}
+
+func (c Config) Enabled() bool {
+	return c.RpId != ""
+}
+
+func (c Config) Validate() error {
+	if strings.ContainsAny(c.RpId, ":/") {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "relying party id must be a domain")
+	}
+	if c.RpName == "" {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "relying party name must be set")
+	}
+	if len(c.Origins) == 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "at least one origin is required")
+	}
+	if c.CeremonyValidity <= 0 {
+		return errors.NewCodeWithDetails(InvalidConfiguration, "ceremony validity must be positive")
+	}
+
+	for _, origin := range c.Origins {
+		parsed, err := url.Parse(origin)
+		if err != nil || parsed.Host == "" || parsed.Path != "" {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "invalid origin: "+origin)
+		}
+		// Browsers only allow plain http for local development.
+		if parsed.Scheme != "https" && !(parsed.Scheme == "http" && parsed.Hostname() == "localhost") {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "origin must use https: "+origin)
+		}
+		if !isRegistrableSuffix(parsed.Hostname(), c.RpId) {
+			return errors.NewCodeWithDetails(InvalidConfiguration, "origin does not belong to the relying party: "+origin)
+		}
+	}
+
+	return nil
+}
+
+// isRegistrableSuffix returns whether the host is the domain or one of its
+// subdomains.
+func isRegistrableSuffix(host string, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
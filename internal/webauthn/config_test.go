@@ -0,0 +1,54 @@
+package webauthn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_Config_Enabled(t *testing.T) {
+	assert.False(t, Config{}.Enabled())
+	assert.True(t, newTestConfig().Enabled())
+}
+
+func TestUnit_Config_Validate(t *testing.T) {
+	config := newTestConfig()
+	config.Origins = append(config.Origins, "https://example.com")
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_Config_Validate_WhenLocalhost_ExpectPlainHttpAllowed(t *testing.T) {
+	config := newTestConfig()
+	config.RpId = "localhost"
+	config.Origins = []string{"http://localhost:3000"}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestUnit_Config_Validate_WhenInvalid_ExpectError(t *testing.T) {
+	testCases := map[string]func(*Config){
+		"rpIdIsAnUrl":          func(c *Config) { c.RpId = "https://example.com" },
+		"noName":               func(c *Config) { c.RpName = "" },
+		"noOrigin":             func(c *Config) { c.Origins = nil },
+		"originWithPath":       func(c *Config) { c.Origins = []string{"https://example.com/login"} },
+		"plainHttp":            func(c *Config) { c.Origins = []string{"http://example.com"} },
+		"originOfAnotherSite":  func(c *Config) { c.Origins = []string{"https://example.net"} },
+		"originSharingASuffix": func(c *Config) { c.Origins = []string{"https://notexample.com"} },
+		"noValidity":           func(c *Config) { c.CeremonyValidity = 0 },
+		"negativeValidity":     func(c *Config) { c.CeremonyValidity = -time.Minute },
+	}
+
+	for name, alter := range testCases {
+		t.Run(name, func(t *testing.T) {
+			config := newTestConfig()
+			alter(&config)
+
+			err := config.Validate()
+
+			assert.True(t, errors.IsErrorWithCode(err, InvalidConfiguration), "Actual err: %v", err)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+// https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	AlgorithmES256 int64 = -7
+	AlgorithmEdDSA int64 = -8
+	AlgorithmRS256 int64 = -257
+)
+
+// SupportedAlgorithms are offered to the authenticators by order of
+// preference.
+var SupportedAlgorithms = []int64{AlgorithmES256, AlgorithmEdDSA, AlgorithmRS256}
+
+// https://www.rfc-editor.org/rfc/rfc9053#section-7
+const (
+	coseKeyType   = 1
+	coseAlgorithm = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseRsaN      = -1
+	coseRsaE      = -2
+)
+
+const (
+	coseOkp = 1
+	coseEc2 = 2
+	coseRsa = 3
+
+	coseP256    = 1
+	coseEd25519 = 6
+)
+
+const minRsaKeyBits = 2048
+
+type publicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE key. Only the keys matching the supported
+// algorithms are accepted.
+func parsePublicKey(data []byte) (publicKey, error) {
+	fields, err := cborMapOf(data)
+	if err != nil {
+		return publicKey{}, err
+	}
+
+	keyType, _ := fields[int64(coseKeyType)].(int64)
+	algorithm, _ := fields[int64(coseAlgorithm)].(int64)
+
+	switch {
+	case keyType == coseEc2 && algorithm == AlgorithmES256:
+		curve, _ := fields[int64(coseCurve)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		y, _ := fields[int64(coseY)].([]byte)
+		if curve != coseP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, errors.NewCodeWithDetails(UnsupportedKey, "invalid P-256 key")
+		}
+
+		point := append([]byte{0x04}, x...)
+		point = append(point, y...)
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return publicKey{}, errors.WrapCode(err, UnsupportedKey)
+		}
+		return publicKey{algorithm: algorithm, key: key}, nil
+
+	case keyType == coseOkp && algorithm == AlgorithmEdDSA:
+		curve, _ := fields[int64(coseCurve)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		if curve != coseEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.NewCodeWithDetails(UnsupportedKey, "invalid Ed25519 key")
+		}
+		return publicKey{algorithm: algorithm, key: ed25519.PublicKey(x)}, nil
+
+	case keyType == coseRsa && algorithm == AlgorithmRS256:
+		n, _ := fields[int64(coseRsaN)].([]byte)
+		e, _ := fields[int64(coseRsaE)].([]byte)
+		if len(e) == 0 || len(e) > 4 {
+			return publicKey{}, errors.NewCodeWithDetails(UnsupportedKey, "invalid RSA exponent")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRsaKeyBits || key.E < 3 {
+			return publicKey{}, errors.NewCodeWithDetails(UnsupportedKey, "RSA key too weak")
+		}
+		return publicKey{algorithm: algorithm, key: key}, nil
+
+	default:
+		return publicKey{}, errors.NewCodeWithDetails(UnsupportedKey, "unsupported key type or algorithm")
+	}
+}
+
+// verify checks a signature produced by the authenticator. ECDSA signatures
+// are DER encoded as in WebAuthn, not raw as in COSE.
+func (k publicKey) verify(data []byte, signature []byte) error {
+	var valid bool
+
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, data, signature)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+
+	if !valid {
+		return errors.NewCode(InvalidSignature)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package webauthn
+
+import (
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+)
+
+const (
+	InvalidConfiguration errors.ErrorCode = 1900
+
+	MalformedResponse        errors.ErrorCode = 1910
+	InvalidClientData        errors.ErrorCode = 1911
+	InvalidAuthenticatorData errors.ErrorCode = 1912
+	UnsupportedAttestation   errors.ErrorCode = 1913
+	InvalidAttestation       errors.ErrorCode = 1914
+	UnsupportedKey           errors.ErrorCode = 1915
+	InvalidSignature         errors.ErrorCode = 1916
+	SignCountRegression      errors.ErrorCode = 1917
+)
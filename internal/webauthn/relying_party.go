@@ -0,0 +1,279 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"slices"
+	"strings"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/google/uuid"
+)
+
+const challengeLength = 32
+
+// https://www.w3.org/TR/webauthn-3/#enum-transport
+var knownTransports = []string{"ble", "hybrid", "internal", "nfc", "smart-card", "usb"}
+
+// RelyingParty verifies the credentials created and used by the browsers of
+// the users.
+type RelyingParty struct {
+	id       string
+	name     string
+	origins  []string
+	rpIdHash []byte
+}
+
+// Registration is the credential created by the browser, as serialized by
+// PublicKeyCredential.toJSON(): binary values are base64url encoded.
+type Registration struct {
+	Id                string
+	ClientDataJson    string
+	AttestationObject string
+	Transports        []string
+}
+
+// Assertion is the answer of the browser to an authentication ceremony, in
+// the same format as Registration.
+type Assertion struct {
+	Id                string
+	ClientDataJson    string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+}
+
+// Credential is a public key credential whose creation was verified.
+type Credential struct {
+	Id []byte
+	// PublicKey is COSE encoded, as received from the authenticator.
+	PublicKey  []byte
+	SignCount  uint32
+	Aaguid     uuid.UUID
+	Transports []string
+	// BackupEligible credentials are synced passkeys rather than keys bound
+	// to a single device.
+	BackupEligible bool
+	BackedUp       bool
+}
+
+func New(config Config) (*RelyingParty, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	rpIdHash := sha256.Sum256([]byte(config.RpId))
+	out := &RelyingParty{
+		id:       config.RpId,
+		name:     config.RpName,
+		origins:  config.Origins,
+		rpIdHash: rpIdHash[:],
+	}
+	return out, nil
+}
+
+func (rp *RelyingParty) Id() string {
+	return rp.id
+}
+
+func (rp *RelyingParty) Name() string {
+	return rp.name
+}
+
+// NewChallenge generates the value signed by the authenticator. It is sent
+// back in the client data, which is how ceremonies are matched.
+func NewChallenge() string {
+	challenge := make([]byte, challengeLength)
+	rand.Read(challenge)
+	return base64.RawURLEncoding.EncodeToString(challenge)
+}
+
+// EncodeBytes uses the encoding of the binary values exchanged with the
+// browsers.
+func EncodeBytes(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeBytes(data string) ([]byte, error) {
+	out, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+	if err != nil {
+		return nil, errors.WrapCode(err, MalformedResponse)
+	}
+	return out, nil
+}
+
+// Challenge returns the challenge the browser answered, without verifying
+// anything.
+func (r Registration) Challenge() (string, error) {
+	return challengeOf(r.ClientDataJson)
+}
+
+func (a Assertion) Challenge() (string, error) {
+	return challengeOf(a.ClientDataJson)
+}
+
+// VerifyRegistration checks that the browser created the credential for this
+// relying party in answer to the challenge.
+func (rp *RelyingParty) VerifyRegistration(registration Registration, challenge string, requireUserVerification bool) (Credential, error) {
+	rawClientData, err := DecodeBytes(registration.ClientDataJson)
+	if err != nil {
+		return Credential{}, err
+	}
+	client, err := parseClientData(rawClientData)
+	if err != nil {
+		return Credential{}, err
+	}
+	err = client.verify(createCeremony, challenge, rp.origins)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	rawAttestation, err := DecodeBytes(registration.AttestationObject)
+	if err != nil {
+		return Credential{}, err
+	}
+	attestation, err := parseAttestationObject(rawAttestation)
+	if err != nil {
+		return Credential{}, err
+	}
+	authData := attestation.authData
+	err = rp.verifyAuthenticatorData(authData, requireUserVerification)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	id, err := DecodeBytes(registration.Id)
+	if err != nil {
+		return Credential{}, err
+	}
+	if !bytes.Equal(id, authData.credentialId) {
+		return Credential{}, errors.NewCodeWithDetails(InvalidAuthenticatorData, "credential id mismatch")
+	}
+
+	key, err := parsePublicKey(authData.publicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	err = attestation.verify(key, clientDataHash[:])
+	if err != nil {
+		return Credential{}, err
+	}
+
+	aaguid, err := uuid.FromBytes(authData.aaguid)
+	if err != nil {
+		return Credential{}, errors.WrapCode(err, InvalidAuthenticatorData)
+	}
+
+	out := Credential{
+		Id:             authData.credentialId,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		Aaguid:         aaguid,
+		Transports:     filterTransports(registration.Transports),
+		BackupEligible: authData.backupEligible(),
+		BackedUp:       authData.backedUp(),
+	}
+	return out, nil
+}
+
+// VerifyAssertion checks that the credential signed the challenge for this
+// relying party and returns the new sign count of the credential.
+// Authenticators which count signatures must always increase it: a lower
+// value reveals a cloned authenticator.
+func (rp *RelyingParty) VerifyAssertion(assertion Assertion, challenge string, publicKey []byte, signCount uint32, requireUserVerification bool) (uint32, error) {
+	rawClientData, err := DecodeBytes(assertion.ClientDataJson)
+	if err != nil {
+		return 0, err
+	}
+	client, err := parseClientData(rawClientData)
+	if err != nil {
+		return 0, err
+	}
+	err = client.verify(getCeremony, challenge, rp.origins)
+	if err != nil {
+		return 0, err
+	}
+
+	rawAuthData, err := DecodeBytes(assertion.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.verifyAuthenticatorData(authData, requireUserVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	signature, err := DecodeBytes(assertion.Signature)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	err = key.verify(signed, signature)
+	if err != nil {
+		return 0, err
+	}
+
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return 0, errors.NewCode(SignCountRegression)
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData authenticatorData, requireUserVerification bool) error {
+	if !bytes.Equal(authData.rpIdHash, rp.rpIdHash) {
+		return errors.NewCodeWithDetails(InvalidAuthenticatorData, "relying party mismatch")
+	}
+	if !authData.userPresent() {
+		return errors.NewCodeWithDetails(InvalidAuthenticatorData, "user not present")
+	}
+	if requireUserVerification && !authData.userVerified() {
+		return errors.NewCodeWithDetails(InvalidAuthenticatorData, "user not verified")
+	}
+	// https://www.w3.org/TR/webauthn-3/#sctn-credential-backup
+	if authData.backedUp() && !authData.backupEligible() {
+		return errors.NewCodeWithDetails(InvalidAuthenticatorData, "inconsistent backup flags")
+	}
+
+	return nil
+}
+
+func challengeOf(encodedClientData string) (string, error) {
+	rawClientData, err := DecodeBytes(encodedClientData)
+	if err != nil {
+		return "", err
+	}
+	client, err := parseClientData(rawClientData)
+	if err != nil {
+		return "", err
+	}
+	if client.Challenge == "" {
+		return "", errors.NewCodeWithDetails(MalformedResponse, "missing challenge")
+	}
+
+	return client.Challenge, nil
+}
+
+// filterTransports keeps the transports defined by the specification. They
+// are only hints given back to the browsers.
+func filterTransports(transports []string) []string {
+	out := []string{}
+	for _, transport := range transports {
+		if slices.Contains(knownTransports, transport) && !slices.Contains(out, transport) {
+			out = append(out, transport)
+		}
+	}
+	return out
+}
@@ -0,0 +1,371 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/internal/webauthn/webauthntest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRpId   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// attestationFixture is a registration followed by an assertion with the
+// same credential, as serialized by PublicKeyCredential.toJSON(). They were
+// recorded from the authenticator of webauthntest.
+type attestationFixture struct {
+	RpId                  string `json:"rpId"`
+	Origin                string `json:"origin"`
+	RegistrationChallenge string `json:"registrationChallenge"`
+	Registration          struct {
+		Id       string `json:"id"`
+		Response struct {
+			ClientDataJson    string   `json:"clientDataJSON"`
+			AttestationObject string   `json:"attestationObject"`
+			Transports        []string `json:"transports"`
+		} `json:"response"`
+	} `json:"registration"`
+	AssertionChallenge string `json:"assertionChallenge"`
+	Assertion          struct {
+		Id       string `json:"id"`
+		Response struct {
+			ClientDataJson    string `json:"clientDataJSON"`
+			AuthenticatorData string `json:"authenticatorData"`
+			Signature         string `json:"signature"`
+			UserHandle        string `json:"userHandle"`
+		} `json:"response"`
+	} `json:"assertion"`
+}
+
+func TestUnit_RelyingParty_VerifyRecordedFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	require.Nil(t, err)
+	require.NotEmpty(t, fixtures)
+
+	for _, path := range fixtures {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			fixture := loadAttestationFixture(t, path)
+			rp := newTestRelyingParty(t)
+
+			credential, err := rp.VerifyRegistration(fixture.registration(), fixture.RegistrationChallenge, false)
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Registration.Id, EncodeBytes(credential.Id))
+			assert.Equal(t, []string{"internal", "hybrid"}, credential.Transports)
+
+			signCount, err := rp.VerifyAssertion(fixture.assertion(), fixture.AssertionChallenge, credential.PublicKey, credential.SignCount, false)
+			require.Nil(t, err)
+			assert.Equal(t, uint32(1), signCount)
+		})
+	}
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_WhenChallengeIsDifferent_ExpectError(t *testing.T) {
+	fixture := loadAttestationFixture(t, filepath.Join("testdata", "none-es256.json"))
+	rp := newTestRelyingParty(t)
+
+	_, err := rp.VerifyRegistration(fixture.registration(), NewChallenge(), false)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidClientData), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_WhenRecordedForAnotherRelyingParty_ExpectError(t *testing.T) {
+	fixture := loadAttestationFixture(t, filepath.Join("testdata", "none-es256.json"))
+	config := newTestConfig()
+	config.RpId = "app.example.com"
+	rp, err := New(config)
+	require.Nil(t, err)
+
+	_, err = rp.VerifyRegistration(fixture.registration(), fixture.RegistrationChallenge, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidAuthenticatorData), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_WhenAttestationIsTampered_ExpectError(t *testing.T) {
+	fixture := loadAttestationFixture(t, filepath.Join("testdata", "packed-x5c-es256.json"))
+	rp := newTestRelyingParty(t)
+
+	registration := fixture.registration()
+	// The client data is covered by the signature of the attestation.
+	clientData, err := DecodeBytes(registration.ClientDataJson)
+	require.Nil(t, err)
+	registration.ClientDataJson = EncodeBytes(append(clientData, ' '))
+
+	_, err = rp.VerifyRegistration(registration, fixture.RegistrationChallenge, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidAttestation), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_WhenCeremonyIsInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		alter                   func(*webauthntest.Ceremony)
+		requireUserVerification bool
+		expectedCode            errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"wrongType": {
+			alter:        func(c *webauthntest.Ceremony) { c.Type = "webauthn.get" },
+			expectedCode: InvalidClientData,
+		},
+		"wrongOrigin": {
+			alter:        func(c *webauthntest.Ceremony) { c.Origin = "https://evil.example.net" },
+			expectedCode: InvalidClientData,
+		},
+		"crossOrigin": {
+			alter:        func(c *webauthntest.Ceremony) { c.CrossOrigin = true },
+			expectedCode: InvalidClientData,
+		},
+		"wrongRelyingParty": {
+			alter:        func(c *webauthntest.Ceremony) { c.RpId = "evil.example.net" },
+			expectedCode: InvalidAuthenticatorData,
+		},
+		"userNotPresent": {
+			alter:        func(c *webauthntest.Ceremony) { c.Flags = webauthntest.FlagUserVerified },
+			expectedCode: InvalidAuthenticatorData,
+		},
+		"userNotVerified": {
+			alter:                   func(c *webauthntest.Ceremony) { c.Flags = webauthntest.FlagUserPresent },
+			requireUserVerification: true,
+			expectedCode:            InvalidAuthenticatorData,
+		},
+		"inconsistentBackupFlags": {
+			alter:        func(c *webauthntest.Ceremony) { c.Flags = webauthntest.FlagUserPresent | webauthntest.FlagBackupState },
+			expectedCode: InvalidAuthenticatorData,
+		},
+		"unsupportedFormat": {
+			alter:        func(c *webauthntest.Ceremony) { c.Format = "tpm" },
+			expectedCode: UnsupportedAttestation,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rp := newTestRelyingParty(t)
+			authenticator := webauthntest.NewAuthenticator(testRpId, testOrigin)
+			credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, []byte("my-user"))
+			challenge := NewChallenge()
+			ceremony := authenticator.Ceremony(challenge)
+			tc.alter(&ceremony)
+
+			registration := authenticator.Create(t, credential, ceremony)
+			_, err := rp.VerifyRegistration(Registration(registration), challenge, tc.requireUserVerification)
+
+			assert.True(t, errors.IsErrorWithCode(err, tc.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_WhenCredentialIdDoesNotMatch_ExpectError(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator(testRpId, testOrigin)
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, []byte("my-user"))
+	challenge := NewChallenge()
+
+	registration := authenticator.Create(t, credential, authenticator.Ceremony(challenge))
+	registration.Id = EncodeBytes([]byte("another-credential"))
+	_, err := rp.VerifyRegistration(Registration(registration), challenge, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidAuthenticatorData), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyRegistration_KeepsKnownTransports(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator(testRpId, testOrigin)
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, []byte("my-user"))
+	challenge := NewChallenge()
+
+	registration := authenticator.Create(t, credential, authenticator.Ceremony(challenge))
+	registration.Transports = []string{"usb", "carrier-pigeon", "usb", "nfc"}
+	actual, err := rp.VerifyRegistration(Registration(registration), challenge, false)
+
+	require.Nil(t, err)
+	assert.Equal(t, []string{"usb", "nfc"}, actual.Transports)
+	assert.True(t, actual.BackupEligible)
+	assert.True(t, actual.BackedUp)
+}
+
+func TestUnit_RelyingParty_VerifyAssertion(t *testing.T) {
+	rp, authenticator, credential, stored := newTestRegisteredCredential(t)
+	challenge := NewChallenge()
+
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(challenge))
+	signCount, err := rp.VerifyAssertion(Assertion(assertion), challenge, stored.PublicKey, stored.SignCount, true)
+
+	require.Nil(t, err)
+	assert.Equal(t, uint32(1), signCount)
+}
+
+func TestUnit_RelyingParty_VerifyAssertion_WhenCeremonyIsInvalid_ExpectError(t *testing.T) {
+	type testCase struct {
+		alter        func(*webauthntest.Ceremony)
+		expectedCode errors.ErrorCode
+	}
+
+	testCases := map[string]testCase{
+		"wrongType": {
+			alter:        func(c *webauthntest.Ceremony) { c.Type = "webauthn.create" },
+			expectedCode: InvalidClientData,
+		},
+		"wrongOrigin": {
+			alter:        func(c *webauthntest.Ceremony) { c.Origin = "https://example.com.evil.net" },
+			expectedCode: InvalidClientData,
+		},
+		"wrongChallenge": {
+			alter:        func(c *webauthntest.Ceremony) { c.Challenge = NewChallenge() },
+			expectedCode: InvalidClientData,
+		},
+		"wrongRelyingParty": {
+			alter:        func(c *webauthntest.Ceremony) { c.RpId = "evil.example.net" },
+			expectedCode: InvalidAuthenticatorData,
+		},
+		"userNotVerified": {
+			alter:        func(c *webauthntest.Ceremony) { c.Flags = webauthntest.FlagUserPresent },
+			expectedCode: InvalidAuthenticatorData,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rp, authenticator, credential, stored := newTestRegisteredCredential(t)
+			challenge := NewChallenge()
+			ceremony := authenticator.Ceremony(challenge)
+			tc.alter(&ceremony)
+
+			assertion := authenticator.Get(t, credential, ceremony)
+			_, err := rp.VerifyAssertion(Assertion(assertion), challenge, stored.PublicKey, stored.SignCount, true)
+
+			assert.True(t, errors.IsErrorWithCode(err, tc.expectedCode), "Actual err: %v", err)
+		})
+	}
+}
+
+func TestUnit_RelyingParty_VerifyAssertion_WhenSignedByAnotherKey_ExpectError(t *testing.T) {
+	rp, authenticator, _, stored := newTestRegisteredCredential(t)
+	other := authenticator.NewCredential(t, webauthntest.AlgorithmES256, []byte("my-user"))
+	challenge := NewChallenge()
+
+	assertion := authenticator.Get(t, other, authenticator.Ceremony(challenge))
+	_, err := rp.VerifyAssertion(Assertion(assertion), challenge, stored.PublicKey, stored.SignCount, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, InvalidSignature), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyAssertion_WhenSignCountDoesNotIncrease_ExpectError(t *testing.T) {
+	rp, authenticator, credential, stored := newTestRegisteredCredential(t)
+	challenge := NewChallenge()
+
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(challenge))
+	_, err := rp.VerifyAssertion(Assertion(assertion), challenge, stored.PublicKey, 1, false)
+
+	assert.True(t, errors.IsErrorWithCode(err, SignCountRegression), "Actual err: %v", err)
+}
+
+func TestUnit_RelyingParty_VerifyAssertion_WhenAuthenticatorDoesNotCount_ExpectSuccess(t *testing.T) {
+	rp, authenticator, credential, stored := newTestRegisteredCredential(t)
+	challenge := NewChallenge()
+	credential.Synced = true
+
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(challenge))
+	signCount, err := rp.VerifyAssertion(Assertion(assertion), challenge, stored.PublicKey, 0, false)
+
+	require.Nil(t, err)
+	assert.Zero(t, signCount)
+}
+
+func TestUnit_Assertion_Challenge(t *testing.T) {
+	_, authenticator, credential, _ := newTestRegisteredCredential(t)
+	challenge := NewChallenge()
+
+	assertion := authenticator.Get(t, credential, authenticator.Ceremony(challenge))
+	actual, err := Assertion(assertion).Challenge()
+
+	require.Nil(t, err)
+	assert.Equal(t, challenge, actual)
+}
+
+func TestUnit_Assertion_Challenge_WhenClientDataIsMalformed_ExpectError(t *testing.T) {
+	assertion := Assertion{
+		ClientDataJson: EncodeBytes([]byte("not-json")),
+	}
+
+	_, err := assertion.Challenge()
+
+	assert.True(t, errors.IsErrorWithCode(err, MalformedResponse), "Actual err: %v", err)
+}
+
+func TestUnit_NewChallenge_GeneratesDifferentValues(t *testing.T) {
+	first := NewChallenge()
+	second := NewChallenge()
+
+	assert.NotEqual(t, first, second)
+	decoded, err := DecodeBytes(first)
+	require.Nil(t, err)
+	assert.Len(t, decoded, challengeLength)
+}
+
+func newTestConfig() Config {
+	return Config{
+		RpId:             testRpId,
+		RpName:           "User service",
+		Origins:          []string{testOrigin},
+		CeremonyValidity: 5 * time.Minute,
+	}
+}
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	rp, err := New(newTestConfig())
+	require.Nil(t, err)
+	return rp
+}
+
+func newTestRegisteredCredential(t *testing.T) (*RelyingParty, *webauthntest.Authenticator, *webauthntest.Credential, Credential) {
+	rp := newTestRelyingParty(t)
+	authenticator := webauthntest.NewAuthenticator(testRpId, testOrigin)
+	credential := authenticator.NewCredential(t, webauthntest.AlgorithmES256, []byte("my-user"))
+	challenge := NewChallenge()
+
+	registration := authenticator.Create(t, credential, authenticator.Ceremony(challenge))
+	stored, err := rp.VerifyRegistration(Registration(registration), challenge, true)
+	require.Nil(t, err)
+
+	return rp, authenticator, credential, stored
+}
+
+func loadAttestationFixture(t *testing.T, path string) attestationFixture {
+	data, err := os.ReadFile(path)
+	require.Nil(t, err)
+
+	var out attestationFixture
+	err = json.Unmarshal(data, &out)
+	require.Nil(t, err)
+	require.Equal(t, testRpId, out.RpId)
+	require.Equal(t, testOrigin, out.Origin)
+	return out
+}
+
+func (f attestationFixture) registration() Registration {
+	return Registration{
+		Id:                f.Registration.Id,
+		ClientDataJson:    f.Registration.Response.ClientDataJson,
+		AttestationObject: f.Registration.Response.AttestationObject,
+		Transports:        f.Registration.Response.Transports,
+	}
+}
+
+func (f attestationFixture) assertion() Assertion {
+	return Assertion{
+		Id:                f.Assertion.Id,
+		ClientDataJson:    f.Assertion.Response.ClientDataJson,
+		AuthenticatorData: f.Assertion.Response.AuthenticatorData,
+		Signature:         f.Assertion.Response.Signature,
+		UserHandle:        f.Assertion.Response.UserHandle,
+	}
+}
@@ -0,0 +1,334 @@
+// Package webauthntest provides an authenticator creating credentials with
+// locally generated keys, to test the relying party without a browser.
+package webauthntest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	AlgorithmES256 int64 = -7
+	AlgorithmEdDSA int64 = -8
+	AlgorithmRS256 int64 = -257
+)
+
+const (
+	FlagUserPresent    byte = 0x01
+	FlagUserVerified   byte = 0x04
+	FlagBackupEligible byte = 0x08
+	FlagBackupState    byte = 0x10
+
+	flagAttestedData byte = 0x40
+)
+
+// PackedX5cAttestation is the packed format with a certificate rather than
+// a self attestation.
+const (
+	NoneAttestation      = "none"
+	PackedAttestation    = "packed"
+	PackedX5cAttestation = "packed-x5c"
+	FidoU2fAttestation   = "fido-u2f"
+)
+
+const (
+	createCeremony = "webauthn.create"
+	getCeremony    = "webauthn.get"
+	packedFormat   = "packed"
+	aaguidLength   = 16
+)
+
+var aaguidExtension = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+type Authenticator struct {
+	RpId   string
+	Origin string
+	Aaguid []byte
+}
+
+type Credential struct {
+	Id         []byte
+	Algorithm  int64
+	Key        crypto.Signer
+	UserHandle []byte
+	SignCount  uint32
+	// Synced passkeys don't count their signatures: the count stays zero.
+	Synced bool
+}
+
+// Ceremony describes what the browser answers. Tests alter it to simulate
+// invalid answers.
+type Ceremony struct {
+	Type        string
+	Challenge   string
+	Origin      string
+	CrossOrigin bool
+	RpId        string
+	Flags       byte
+	Format      string
+}
+
+// Registration and Assertion have the layout of the types of the relying
+// party so that they can be converted to them.
+type Registration struct {
+	Id                string
+	ClientDataJson    string
+	AttestationObject string
+	Transports        []string
+}
+
+type Assertion struct {
+	Id                string
+	ClientDataJson    string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+}
+
+func NewAuthenticator(rpId string, origin string) *Authenticator {
+	aaguid := make([]byte, aaguidLength)
+	rand.Read(aaguid)
+
+	return &Authenticator{
+		RpId:   rpId,
+		Origin: origin,
+		Aaguid: aaguid,
+	}
+}
+
+func (a *Authenticator) NewCredential(t *testing.T, algorithm int64, userHandle []byte) *Credential {
+	id := make([]byte, 32)
+	rand.Read(id)
+
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		t.Fatalf("unsupported algorithm %d", algorithm)
+	}
+	require.Nil(t, err)
+
+	return &Credential{
+		Id:         id,
+		Algorithm:  algorithm,
+		Key:        key,
+		UserHandle: userHandle,
+	}
+}
+
+// Ceremony returns a valid answer to the challenge: tests alter it before
+// passing it to Create or Get.
+func (a *Authenticator) Ceremony(challenge string) Ceremony {
+	return Ceremony{
+		Challenge: challenge,
+		Origin:    a.Origin,
+		RpId:      a.RpId,
+		Flags:     FlagUserPresent | FlagUserVerified | FlagBackupEligible | FlagBackupState,
+		Format:    NoneAttestation,
+	}
+}
+
+func (a *Authenticator) Create(t *testing.T, credential *Credential, ceremony Ceremony) Registration {
+	if ceremony.Type == "" {
+		ceremony.Type = createCeremony
+	}
+	clientData := encodeClientData(t, ceremony)
+	clientDataHash := sha256.Sum256(clientData)
+
+	aaguid := a.Aaguid
+	if ceremony.Format == FidoU2fAttestation {
+		aaguid = make([]byte, aaguidLength)
+	}
+
+	rpIdHash := sha256.Sum256([]byte(ceremony.RpId))
+	authData := append([]byte(nil), rpIdHash[:]...)
+	authData = append(authData, ceremony.Flags|flagAttestedData)
+	authData = binary.BigEndian.AppendUint32(authData, credential.SignCount)
+	authData = append(authData, aaguid...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(credential.Id)))
+	authData = append(authData, credential.Id...)
+	authData = append(authData, encodeCbor(credential.coseKey(t))...)
+
+	format := ceremony.Format
+	statement := map[any]any{}
+	switch ceremony.Format {
+	case PackedAttestation:
+		signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+		statement["alg"] = credential.Algorithm
+		statement["sig"] = sign(t, credential.Key, signed)
+	case PackedX5cAttestation:
+		key, certificate := newAttestationCertificate(t, aaguid)
+		signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+		format = packedFormat
+		statement["alg"] = AlgorithmES256
+		statement["sig"] = sign(t, key, signed)
+		statement["x5c"] = []any{certificate}
+	case FidoU2fAttestation:
+		public, ok := credential.Key.Public().(*ecdsa.PublicKey)
+		require.True(t, ok, "U2F credentials use P-256 keys")
+		point, err := public.Bytes()
+		require.Nil(t, err)
+
+		key, certificate := newAttestationCertificate(t, nil)
+		signed := []byte{0x00}
+		signed = append(signed, rpIdHash[:]...)
+		signed = append(signed, clientDataHash[:]...)
+		signed = append(signed, credential.Id...)
+		signed = append(signed, point...)
+		statement["sig"] = sign(t, key, signed)
+		statement["x5c"] = []any{certificate}
+	}
+
+	attestation := map[any]any{
+		"fmt":      format,
+		"attStmt":  statement,
+		"authData": authData,
+	}
+
+	return Registration{
+		Id:                encode(credential.Id),
+		ClientDataJson:    encode(clientData),
+		AttestationObject: encode(encodeCbor(attestation)),
+		Transports:        []string{"internal", "hybrid"},
+	}
+}
+
+// Get signs the challenge with the credential. The sign count is increased
+// beforehand as real authenticators do, unless the credential is synced.
+func (a *Authenticator) Get(t *testing.T, credential *Credential, ceremony Ceremony) Assertion {
+	if ceremony.Type == "" {
+		ceremony.Type = getCeremony
+	}
+	if !credential.Synced {
+		credential.SignCount++
+	}
+
+	clientData := encodeClientData(t, ceremony)
+	clientDataHash := sha256.Sum256(clientData)
+
+	rpIdHash := sha256.Sum256([]byte(ceremony.RpId))
+	authData := append([]byte(nil), rpIdHash[:]...)
+	authData = append(authData, ceremony.Flags)
+	authData = binary.BigEndian.AppendUint32(authData, credential.SignCount)
+
+	signed := append(append([]byte(nil), authData...), clientDataHash[:]...)
+
+	return Assertion{
+		Id:                encode(credential.Id),
+		ClientDataJson:    encode(clientData),
+		AuthenticatorData: encode(authData),
+		Signature:         encode(sign(t, credential.Key, signed)),
+		UserHandle:        encode(credential.UserHandle),
+	}
+}
+
+func (c *Credential) coseKey(t *testing.T) map[any]any {
+	switch key := c.Key.Public().(type) {
+	case *ecdsa.PublicKey:
+		point, err := key.Bytes()
+		require.Nil(t, err)
+		return map[any]any{
+			int64(1):  int64(2),
+			int64(3):  AlgorithmES256,
+			int64(-1): int64(1),
+			int64(-2): point[1:33],
+			int64(-3): point[33:],
+		}
+	case ed25519.PublicKey:
+		return map[any]any{
+			int64(1):  int64(1),
+			int64(3):  AlgorithmEdDSA,
+			int64(-1): int64(6),
+			int64(-2): []byte(key),
+		}
+	case *rsa.PublicKey:
+		return map[any]any{
+			int64(1):  int64(3),
+			int64(3):  AlgorithmRS256,
+			int64(-1): key.N.Bytes(),
+			int64(-2): big.NewInt(int64(key.E)).Bytes(),
+		}
+	default:
+		t.Fatalf("unsupported key %T", key)
+		return nil
+	}
+}
+
+func encodeClientData(t *testing.T, ceremony Ceremony) []byte {
+	clientData := map[string]any{
+		"type":        ceremony.Type,
+		"challenge":   ceremony.Challenge,
+		"origin":      ceremony.Origin,
+		"crossOrigin": ceremony.CrossOrigin,
+	}
+	out, err := json.Marshal(clientData)
+	require.Nil(t, err)
+	return out
+}
+
+func sign(t *testing.T, key crypto.Signer, data []byte) []byte {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		out, err := key.Sign(rand.Reader, data, crypto.Hash(0))
+		require.Nil(t, err)
+		return out
+	}
+
+	digest := sha256.Sum256(data)
+	out, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.Nil(t, err)
+	return out
+}
+
+// newAttestationCertificate returns a self signed certificate standing for
+// the one installed by the vendor of the authenticator.
+func newAttestationCertificate(t *testing.T, aaguid []byte) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Organization:       []string{"webauthntest"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "webauthntest",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		BasicConstraintsValid: true,
+	}
+	if len(aaguid) == aaguidLength {
+		value, err := asn1.Marshal(aaguid)
+		require.Nil(t, err)
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: aaguidExtension, Value: value})
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.Nil(t, err)
+	return key, der
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,71 @@
+package webauthntest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// encodeCbor supports the values found in the answers of authenticators.
+// Maps are encoded in the canonical order required by CTAP2.
+func encodeCbor(value any) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v >= 0 {
+			return encodeCborHeader(0, uint64(v))
+		}
+		return encodeCborHeader(1, uint64(-1-v))
+	case []byte:
+		return append(encodeCborHeader(2, uint64(len(v))), v...)
+	case string:
+		return append(encodeCborHeader(3, uint64(len(v))), v...)
+	case []any:
+		out := encodeCborHeader(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCbor(item)...)
+		}
+		return out
+	case map[any]any:
+		entries := make([][2][]byte, 0, len(v))
+		for key, item := range v {
+			entries = append(entries, [2][]byte{encodeCbor(key), encodeCbor(item)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			a, b := entries[i][0], entries[j][0]
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return string(a) < string(b)
+		})
+
+		out := encodeCborHeader(5, uint64(len(v)))
+		for _, entry := range entries {
+			out = append(out, entry[0]...)
+			out = append(out, entry[1]...)
+		}
+		return out
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	default:
+		panic(fmt.Sprintf("unsupported cbor value %T", value))
+	}
+}
+
+func encodeCborHeader(major byte, argument uint64) []byte {
+	header := major << 5
+	switch {
+	case argument < 24:
+		return []byte{header | byte(argument)}
+	case argument <= 0xff:
+		return []byte{header | 24, byte(argument)}
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{header | 25}, uint16(argument))
+	case argument <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{header | 26}, uint32(argument))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{header | 27}, argument)
+	}
+}
@@ -0,0 +1,24 @@
+package communication
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type AccountLockoutDtoResponse struct {
+	User        uuid.UUID `json:"user" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email       string    `json:"email" binding:"required" example:"user@example.com"`
+	Failures    int       `json:"failures" binding:"required" example:"10"`
+	LockedUntil time.Time `json:"lockedUntil" binding:"required" format:"date-time" example:"2026-04-28T20:56:59Z"`
+}
+
+func ToAccountLockoutDtoResponse(lockout persistence.AccountLockout) AccountLockoutDtoResponse {
+	return AccountLockoutDtoResponse{
+		User:        lockout.ApiUser,
+		Email:       lockout.Email,
+		Failures:    lockout.Failures,
+		LockedUntil: lockout.LockedUntil,
+	}
+}
@@ -0,0 +1,47 @@
+package communication
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_AccountLockoutDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := AccountLockoutDtoResponse{
+		User:        uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Email:       "some@e.mail",
+		Failures:    12,
+		LockedUntil: someTime,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"user": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"email": "some@e.mail",
+		"failures": 12,
+		"lockedUntil": "2024-11-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToAccountLockoutDtoResponse(t *testing.T) {
+	entity := persistence.AccountLockout{
+		ApiUser:     uuid.New(),
+		Email:       "some@e.mail",
+		Failures:    12,
+		LockedUntil: someTime,
+	}
+
+	actual := ToAccountLockoutDtoResponse(entity)
+
+	assert.Equal(t, entity.ApiUser, actual.User)
+	assert.Equal(t, entity.Email, actual.Email)
+	assert.Equal(t, entity.Failures, actual.Failures)
+	assert.Equal(t, entity.LockedUntil, actual.LockedUntil)
+}
@@ -9,14 +9,24 @@ import (
 
 type ApiKeyDtoResponse struct {
 	User       uuid.UUID `json:"user" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Key        uuid.UUID `json:"key" binding:"required" format:"uuid" example:"f47ac10b-58cc-4372-a567-0e02b2c3d479"`
+	Key        string    `json:"key" binding:"required" example:"usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO"`
 	ValidUntil time.Time `json:"validUntil" binding:"required" format:"date-time" example:"2026-04-28T20:56:59Z"`
+
+	// The refresh token is only returned when they are enabled.
+	RefreshToken           string     `json:"refreshToken,omitempty" example:"usr_live_bt6tn9DpaasX82fhqfLNfbO4DfyGxhSYnQyjUvnhuZi2nYBu1"`
+	RefreshTokenValidUntil *time.Time `json:"refreshTokenValidUntil,omitempty" format:"date-time" example:"2026-05-28T17:56:59Z"`
+
+	// PasswordChangeRequired is set when the user logged in with a recovery
+	// code: the key can only be used to choose a new password.
+	PasswordChangeRequired bool `json:"passwordChangeRequired,omitempty" example:"true"`
 }
 
-func ToApiKeyDtoResponse(apiKey persistence.ApiKey) ApiKeyDtoResponse {
+func ToApiKeyDtoResponse(apiKey persistence.ApiKey, key string) ApiKeyDtoResponse {
 	return ApiKeyDtoResponse{
 		User:       apiKey.ApiUser,
-		Key:        apiKey.Key,
+		Key:        key,
 		ValidUntil: apiKey.ValidUntil,
+
+		PasswordChangeRequired: apiKey.PasswordChangeRequired,
 	}
 }
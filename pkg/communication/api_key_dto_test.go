@@ -13,7 +13,7 @@ import (
 func TestUnit_ApiKeyDtoResponse_MarshalsToCamelCase(t *testing.T) {
 	dto := ApiKeyDtoResponse{
 		User:       uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
-		Key:        uuid.MustParse("872e9e40-ce61-497e-b606-c7a08a4faa14"),
+		Key:        "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
 		ValidUntil: someTime,
 	}
 
@@ -23,23 +23,72 @@ func TestUnit_ApiKeyDtoResponse_MarshalsToCamelCase(t *testing.T) {
 	expectedJson := `
 	{
 		"user": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
-		"key": "872e9e40-ce61-497e-b606-c7a08a4faa14",
+		"key": "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
 		"validUntil": "2024-11-12T19:09:36Z"
 	}`
 	assert.JSONEq(t, expectedJson, string(out))
 }
 
+func TestUnit_ApiKeyDtoResponse_WhenRefreshTokenIsSet_ExpectItIsMarshalled(t *testing.T) {
+	refreshValidUntil := someTime.Add(30 * 24 * time.Hour)
+	dto := ApiKeyDtoResponse{
+		User:                   uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Key:                    "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
+		ValidUntil:             someTime,
+		RefreshToken:           "usr_live_bt6tn9DpaasX82fhqfLNfbO4DfyGxhSYnQyjUvnhuZi2nYBu1",
+		RefreshTokenValidUntil: &refreshValidUntil,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"user": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"key": "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
+		"validUntil": "2024-11-12T19:09:36Z",
+		"refreshToken": "usr_live_bt6tn9DpaasX82fhqfLNfbO4DfyGxhSYnQyjUvnhuZi2nYBu1",
+		"refreshTokenValidUntil": "2024-12-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ApiKeyDtoResponse_WhenPasswordChangeIsRequired_ExpectItIsMarshalled(t *testing.T) {
+	dto := ApiKeyDtoResponse{
+		User:                   uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Key:                    "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
+		ValidUntil:             someTime,
+		PasswordChangeRequired: true,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"user": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"key": "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO",
+		"validUntil": "2024-11-12T19:09:36Z",
+		"passwordChangeRequired": true
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
 func TestUnit_ToApiKeyDtoResponse(t *testing.T) {
 	entity := persistence.ApiKey{
 		Id:         uuid.New(),
-		Key:        uuid.New(),
+		KeyHash:    "5f2a0d1c0c6e0e8b0c3b1f0d6d2c2f3e9a7b4c1d0e8f7a6b5c4d3e2f1a0b9c8d",
 		ApiUser:    uuid.New(),
 		ValidUntil: someTime.Add(2 * time.Hour),
+
+		PasswordChangeRequired: true,
 	}
+	key := "usk_live_36P44uWzf7pHfVRKnDzDtLZ686a6QzJlcMtBXPArorU1xQmpO"
 
-	actual := ToApiKeyDtoResponse(entity)
+	actual := ToApiKeyDtoResponse(entity, key)
 
 	assert.Equal(t, entity.ApiUser, actual.User)
-	assert.Equal(t, entity.Key, actual.Key)
+	assert.Equal(t, key, actual.Key)
 	assert.Equal(t, entity.ValidUntil, actual.ValidUntil)
+	assert.True(t, actual.PasswordChangeRequired)
 }
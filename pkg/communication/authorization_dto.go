@@ -1,3 +1,14 @@
 package communication
 
-type AuthorizationDtoResponse struct{}
+import (
+	"github.com/google/uuid"
+)
+
+// AuthorizationDtoResponse describes what the user behind a key is allowed
+// to do. The roles include the ones inherited through the role hierarchy
+// and the permissions are the ones granted to any of them.
+type AuthorizationDtoResponse struct {
+	User        uuid.UUID `json:"user" binding:"required" format:"uuid" example:"0463ed3d-bfc9-4c10-b6ee-c223bbca0fab"`
+	Roles       []string  `json:"roles" binding:"required" example:"user,editor"`
+	Permissions []string  `json:"permissions" binding:"required" example:"articles:read,articles:write"`
+}
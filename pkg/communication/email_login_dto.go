@@ -0,0 +1,13 @@
+package communication
+
+type EmailLoginDtoRequest struct {
+	Email string `json:"email" form:"email" binding:"required" example:"user@example.com"`
+}
+
+// EmailLoginCredentialsDtoRequest holds either the token of the link or the
+// email and the code which were sent to the user.
+type EmailLoginCredentialsDtoRequest struct {
+	Token string `json:"token,omitempty" form:"token" example:"usl_live_WiUO38LBGghl94RVzkarly1HCAvcvJtVa4B5LoH0NAf1iyV6n"`
+	Email string `json:"email,omitempty" form:"email" example:"user@example.com"`
+	Code  string `json:"code,omitempty" form:"code" example:"492817"`
+}
@@ -0,0 +1,12 @@
+package communication
+
+// FederatedCallbackDtoRequest is sent by the identity provider when the user
+// comes back. The names are imposed by the OAuth specification.
+// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2
+type FederatedCallbackDtoRequest struct {
+	Code  string `query:"code" example:"4/0AeanS0ZJ2f6"`
+	State string `query:"state" example:"Xl2aN3Vd6w9fYb2rJqz0c8mLkH1tPsEu4GoIyRvBnW5"`
+	// Error is set instead of the code when the user did not sign in.
+	Error            string `query:"error" example:"access_denied"`
+	ErrorDescription string `query:"error_description" example:"The user denied the request"`
+}
@@ -0,0 +1,13 @@
+package communication
+
+// IntrospectionDtoResponse follows the format defined by RFC 7662. All the
+// fields but Active are omitted when the key is not active.
+// https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type IntrospectionDtoResponse struct {
+	Active    bool   `json:"active" binding:"required" example:"true"`
+	Subject   string `json:"sub,omitempty" format:"uuid" example:"0463ed3d-bfc9-4c10-b6ee-c223bbca0fab"`
+	ExpiresAt int64  `json:"exp,omitempty" example:"1777409819"`
+	Scope     string `json:"scope,omitempty" example:"user admin"`
+	ClientId  string `json:"client_id,omitempty" example:"user-service"`
+	TokenType string `json:"token_type,omitempty" example:"Bearer"`
+}
@@ -0,0 +1,36 @@
+package communication
+
+import (
+	"time"
+)
+
+// LoginDtoResponse is the outcome of a login: a session, or a challenge when
+// the user enabled a second factor. Exactly one of them is set.
+type LoginDtoResponse struct {
+	Session   *ApiKeyDtoResponse
+	Challenge *MfaChallengeDtoResponse
+}
+
+type MfaChallengeDtoResponse struct {
+	Challenge  string    `json:"challenge" binding:"required" example:"usm_live_4mX8cQ2rTn5vWk9pLz3dHf7jYb6sGa1uEo0iNhRqVwC2xBt9a"`
+	ValidUntil time.Time `json:"validUntil" binding:"required" format:"date-time" example:"2026-04-28T20:56:59Z"`
+	// Methods lists the second factors the challenge can be answered with.
+	Methods []string `json:"methods" binding:"required" example:"totp"`
+}
+
+type MfaDtoRequest struct {
+	Challenge string `json:"challenge" form:"challenge" binding:"required" example:"usm_live_4mX8cQ2rTn5vWk9pLz3dHf7jYb6sGa1uEo0iNhRqVwC2xBt9a"`
+	Code      string `json:"code" form:"code" binding:"required" example:"492039"`
+}
+
+type TotpCodeDtoRequest struct {
+	Code string `json:"code" form:"code" binding:"required" example:"492039"`
+}
+
+// TotpEnrollmentDtoResponse holds the secret to add to an authenticator
+// app. It is only returned once: the secret can't be read afterwards.
+type TotpEnrollmentDtoResponse struct {
+	Secret string `json:"secret" binding:"required" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	// Uri is meant to be shown as a QR code.
+	Uri string `json:"uri" binding:"required" example:"otpauth://totp/user-service:user@example.com?algorithm=SHA1&digits=6&issuer=user-service&period=30&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+}
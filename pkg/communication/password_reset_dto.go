@@ -0,0 +1,16 @@
+package communication
+
+type PasswordResetDtoRequest struct {
+	Email string `json:"email" form:"email" binding:"required" example:"user@example.com"`
+}
+
+type NewPasswordDtoRequest struct {
+	Password string `json:"password" form:"password" binding:"required" example:"SecurePassword123"`
+}
+
+type PasswordChangeDtoRequest struct {
+	// CurrentPassword is not needed by the users who logged in with a
+	// recovery code.
+	CurrentPassword string `json:"currentPassword,omitempty" form:"currentPassword" example:"SecurePassword123"`
+	NewPassword     string `json:"newPassword" form:"newPassword" binding:"required" example:"EvenMoreSecurePassword456"`
+}
@@ -0,0 +1,14 @@
+package communication
+
+type PasswordViolationsDtoResponse struct {
+	Violations []string `json:"violations" binding:"required" example:"too_short,common_password"`
+}
+
+func ToPasswordViolationsDtoResponse(rules []string) PasswordViolationsDtoResponse {
+	out := PasswordViolationsDtoResponse{
+		Violations: make([]string, 0, len(rules)),
+	}
+	out.Violations = append(out.Violations, rules...)
+
+	return out
+}
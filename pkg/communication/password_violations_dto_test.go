@@ -0,0 +1,32 @@
+package communication
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_PasswordViolationsDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := PasswordViolationsDtoResponse{
+		Violations: []string{"too_short", "common_password"},
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"violations": ["too_short", "common_password"]
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToPasswordViolationsDtoResponse_WhenNoRules_ExpectEmptySlice(t *testing.T) {
+	actual := ToPasswordViolationsDtoResponse(nil)
+
+	out, err := json.Marshal(actual)
+
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"violations": []}`, string(out))
+}
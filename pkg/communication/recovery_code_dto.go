@@ -0,0 +1,7 @@
+package communication
+
+// RecoveryCodesDtoResponse holds the recovery codes of a user. They are only
+// returned once: the codes can't be read afterwards.
+type RecoveryCodesDtoResponse struct {
+	Codes []string `json:"codes" binding:"required" example:"k7qm-2xvd-9pfh-3nwa,3nwa-k7qm-2xvd-9pfh"`
+}
@@ -0,0 +1,5 @@
+package communication
+
+type RefreshTokenDtoRequest struct {
+	RefreshToken string `json:"refreshToken" form:"refreshToken" binding:"required" example:"usr_live_bt6tn9DpaasX82fhqfLNfbO4DfyGxhSYnQyjUvnhuZi2nYBu1"`
+}
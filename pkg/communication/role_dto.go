@@ -0,0 +1,66 @@
+package communication
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type PermissionDtoRequest struct {
+	Name        string `json:"name" form:"name" binding:"required" example:"articles:write"`
+	Description string `json:"description" form:"description" example:"Publish and edit articles"`
+}
+
+type PermissionDtoResponse struct {
+	Id          uuid.UUID `json:"id" binding:"required" format:"uuid" example:"7c1e9a52-3f4b-4d8e-9a61-2b5f0c8d4e17"`
+	Name        string    `json:"name" binding:"required" example:"articles:write"`
+	Description string    `json:"description" binding:"required" example:"Publish and edit articles"`
+	CreatedAt   time.Time `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-28T17:56:59Z"`
+}
+
+func ToPermissionDtoResponse(permission persistence.Permission) PermissionDtoResponse {
+	return PermissionDtoResponse{
+		Id:          permission.Id,
+		Name:        permission.Name,
+		Description: permission.Description,
+		CreatedAt:   permission.CreatedAt,
+	}
+}
+
+type RoleDtoRequest struct {
+	Name        string `json:"name" form:"name" binding:"required" example:"editor"`
+	Description string `json:"description" form:"description" example:"Writes articles"`
+	// Parent is the role this one inherits the permissions of.
+	Parent *uuid.UUID `json:"parent,omitempty" form:"parent" format:"uuid" example:"3b8d2f61-0c7a-4e59-b1d4-6f2a9e8c5d30"`
+	// Permissions are given by name and replace the ones of the role.
+	Permissions []string `json:"permissions" form:"permissions" example:"articles:write"`
+}
+
+type RoleDtoResponse struct {
+	Id          uuid.UUID  `json:"id" binding:"required" format:"uuid" example:"5e4a1c7d-9b2f-4a83-8d6e-1f0b3c9a7e24"`
+	Name        string     `json:"name" binding:"required" example:"editor"`
+	Description string     `json:"description" binding:"required" example:"Writes articles"`
+	Parent      *uuid.UUID `json:"parent,omitempty" format:"uuid" example:"3b8d2f61-0c7a-4e59-b1d4-6f2a9e8c5d30"`
+	// Permissions only lists the permissions granted to the role itself,
+	// not the ones it inherits.
+	Permissions []string  `json:"permissions" binding:"required" example:"articles:write"`
+	CreatedAt   time.Time `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-28T17:56:59Z"`
+	UpdatedAt   time.Time `json:"updatedAt" binding:"required" format:"date-time" example:"2026-04-28T18:12:03Z"`
+}
+
+func ToRoleDtoResponse(role persistence.Role, permissions []string) RoleDtoResponse {
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return RoleDtoResponse{
+		Id:          role.Id,
+		Name:        role.Name,
+		Description: role.Description,
+		Parent:      role.Parent,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
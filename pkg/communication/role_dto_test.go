@@ -0,0 +1,76 @@
+package communication
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_RoleDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	parent := uuid.MustParse("3b8d2f61-0c7a-4e59-b1d4-6f2a9e8c5d30")
+	dto := RoleDtoResponse{
+		Id:          uuid.MustParse("5e4a1c7d-9b2f-4a83-8d6e-1f0b3c9a7e24"),
+		Name:        "editor",
+		Description: "Writes articles",
+		Parent:      &parent,
+		Permissions: []string{"articles:write"},
+		CreatedAt:   someTime,
+		UpdatedAt:   someTime,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "5e4a1c7d-9b2f-4a83-8d6e-1f0b3c9a7e24",
+		"name": "editor",
+		"description": "Writes articles",
+		"parent": "3b8d2f61-0c7a-4e59-b1d4-6f2a9e8c5d30",
+		"permissions": ["articles:write"],
+		"createdAt": "2024-11-12T19:09:36Z",
+		"updatedAt": "2024-11-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToRoleDtoResponse_WhenNoPermission_ExpectEmptyList(t *testing.T) {
+	entity := persistence.Role{
+		Id:        uuid.New(),
+		Name:      "editor",
+		CreatedAt: someTime,
+		UpdatedAt: someTime,
+		Version:   2,
+	}
+
+	actual := ToRoleDtoResponse(entity, nil)
+
+	assert.Equal(t, entity.Id, actual.Id)
+	assert.Equal(t, entity.Name, actual.Name)
+	assert.Nil(t, actual.Parent)
+	assert.Equal(t, []string{}, actual.Permissions)
+	assert.Equal(t, someTime, actual.CreatedAt)
+	assert.Equal(t, someTime, actual.UpdatedAt)
+}
+
+func TestUnit_AuthorizationDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := AuthorizationDtoResponse{
+		User:        uuid.MustParse("0463ed3d-bfc9-4c10-b6ee-c223bbca0fab"),
+		Roles:       []string{"user"},
+		Permissions: []string{},
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"user": "0463ed3d-bfc9-4c10-b6ee-c223bbca0fab",
+		"roles": ["user"],
+		"permissions": []
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
@@ -0,0 +1,10 @@
+package communication
+
+// SamlResponseDtoRequest is posted by the identity provider of a tenant
+// with the HTTP-POST binding. The names are imposed by the binding.
+// https://docs.oasis-open.org/security/saml/v2.0/saml-bindings-2.0-os.pdf#page=21
+type SamlResponseDtoRequest struct {
+	// SAMLResponse is the base64 encoded response.
+	SAMLResponse string `form:"SAMLResponse" example:"PHNhbWxwOlJlc3BvbnNlIC4uLg=="`
+	RelayState   string `form:"RelayState" example:"Xl2aN3Vd6w9fYb2rJqz0c8mLkH1tPsEu4GoIyRvBnW5"`
+}
@@ -0,0 +1,30 @@
+package communication
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type SessionDtoResponse struct {
+	Id         uuid.UUID  `json:"id" binding:"required" format:"uuid" example:"a5eff7a9-9bd6-4f51-9b42-a7ca5ffd3f5e"`
+	UserAgent  string     `json:"userAgent" binding:"required" example:"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"`
+	IpAddress  string     `json:"ipAddress" binding:"required" example:"203.0.113.42"`
+	Current    bool       `json:"current" binding:"required" example:"true"`
+	CreatedAt  time.Time  `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-28T17:56:59Z"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" format:"date-time" example:"2026-04-28T18:12:03Z"`
+	ValidUntil time.Time  `json:"validUntil" binding:"required" format:"date-time" example:"2026-04-28T20:56:59Z"`
+}
+
+func ToSessionDtoResponse(apiKey persistence.ApiKey, current bool) SessionDtoResponse {
+	return SessionDtoResponse{
+		Id:         apiKey.Id,
+		UserAgent:  apiKey.UserAgent,
+		IpAddress:  apiKey.IpAddress,
+		Current:    current,
+		CreatedAt:  apiKey.CreatedAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		ValidUntil: apiKey.ValidUntil,
+	}
+}
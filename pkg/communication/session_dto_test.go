@@ -0,0 +1,85 @@
+package communication
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_SessionDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	lastUsedAt := someTime.Add(1 * time.Hour)
+	dto := SessionDtoResponse{
+		Id:         uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		UserAgent:  "my-user-agent",
+		IpAddress:  "203.0.113.42",
+		Current:    true,
+		CreatedAt:  someTime,
+		LastUsedAt: &lastUsedAt,
+		ValidUntil: someTime.Add(3 * time.Hour),
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"userAgent": "my-user-agent",
+		"ipAddress": "203.0.113.42",
+		"current": true,
+		"createdAt": "2024-11-12T19:09:36Z",
+		"lastUsedAt": "2024-11-12T20:09:36Z",
+		"validUntil": "2024-11-12T22:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_SessionDtoResponse_WhenNeverUsed_ExpectNoLastUsedAt(t *testing.T) {
+	dto := SessionDtoResponse{
+		Id:         uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		CreatedAt:  someTime,
+		ValidUntil: someTime,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"userAgent": "",
+		"ipAddress": "",
+		"current": false,
+		"createdAt": "2024-11-12T19:09:36Z",
+		"validUntil": "2024-11-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToSessionDtoResponse(t *testing.T) {
+	lastUsedAt := someTime.Add(1 * time.Hour)
+	entity := persistence.ApiKey{
+		Id:         uuid.New(),
+		KeyHash:    "5f2a0d1c0c6e0e8b0c3b1f0d6d2c2f3e9a7b4c1d0e8f7a6b5c4d3e2f1a0b9c8d",
+		ApiUser:    uuid.New(),
+		UserAgent:  "my-user-agent",
+		IpAddress:  "203.0.113.42",
+		CreatedAt:  someTime,
+		LastUsedAt: &lastUsedAt,
+		ValidUntil: someTime.Add(2 * time.Hour),
+	}
+
+	actual := ToSessionDtoResponse(entity, true)
+
+	assert.Equal(t, entity.Id, actual.Id)
+	assert.Equal(t, entity.UserAgent, actual.UserAgent)
+	assert.Equal(t, entity.IpAddress, actual.IpAddress)
+	assert.True(t, actual.Current)
+	assert.Equal(t, entity.CreatedAt, actual.CreatedAt)
+	assert.Equal(t, entity.LastUsedAt, actual.LastUsedAt)
+	assert.Equal(t, entity.ValidUntil, actual.ValidUntil)
+}
@@ -11,16 +11,78 @@ import (
 type UserDtoRequest struct {
 	Email    string `json:"email" form:"email" binding:"required" example:"user@example.com"`
 	Password string `json:"password" form:"password" binding:"required" example:"SecurePassword123"`
+	// RecoveryCode replaces the password at login for users who lost it.
+	// It is ignored otherwise.
+	RecoveryCode string `json:"recoveryCode,omitempty" form:"recoveryCode" example:"k7qm-2xvd-9pfh-3nwa"`
 }
 
-type UserDtoResponse struct {
-	Id       uuid.UUID `json:"id" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Email    string    `json:"email" binding:"required" example:"user@example.com"`
-	Password string    `json:"password" binding:"required" example:"SecurePassword123"`
+type UserView int
+
+const (
+	PublicView UserView = iota
+	SelfView
+	AdminView
+)
+
+type UserStatus string
+
+const (
+	ActiveStatus UserStatus = "active"
+	// UnverifiedStatus is used until the user followed the link sent to
+	// their current email.
+	UnverifiedStatus UserStatus = "unverified"
+	// LockedStatus is used while the account is locked after too many
+	// failed login attempts.
+	LockedStatus UserStatus = "locked"
+)
+
+// UserDtoResponse is implemented by the projections of a user which can
+// be returned to a caller. None of them carries credential material.
+type UserDtoResponse interface {
+	isUserDtoResponse()
+}
+
+type UserPublicDtoResponse struct {
+	Id uuid.UUID `json:"id" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
 
 	CreatedAt time.Time `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-27T20:56:59Z"`
 }
 
+type UserSelfDtoResponse struct {
+	Id    uuid.UUID `json:"id" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email string    `json:"email" binding:"required" example:"user@example.com"`
+	// EmailVerifiedAt is only set once the user followed the link sent to
+	// their current email.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" format:"date-time" example:"2026-04-27T21:03:12Z"`
+
+	CreatedAt time.Time `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-27T20:56:59Z"`
+	UpdatedAt time.Time `json:"updatedAt" binding:"required" format:"date-time" example:"2026-04-28T08:12:43Z"`
+
+	Version int `json:"version" binding:"required" example:"2"`
+
+	// PasswordChangeRequired is set once the user logged in with a recovery
+	// code, until they choose a new password.
+	PasswordChangeRequired bool `json:"passwordChangeRequired,omitempty" example:"true"`
+	RecoveryCodesLeft      int  `json:"recoveryCodesLeft" binding:"required" example:"10"`
+}
+
+type UserAdminDtoResponse struct {
+	Id     uuid.UUID  `json:"id" binding:"required" format:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Email  string     `json:"email" binding:"required" example:"user@example.com"`
+	Status UserStatus `json:"status" binding:"required" enums:"active,unverified,locked" example:"active"`
+
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty" format:"date-time" example:"2026-04-27T21:03:12Z"`
+
+	CreatedAt time.Time `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-27T20:56:59Z"`
+	UpdatedAt time.Time `json:"updatedAt" binding:"required" format:"date-time" example:"2026-04-28T08:12:43Z"`
+
+	Version int `json:"version" binding:"required" example:"2"`
+}
+
+func (UserPublicDtoResponse) isUserDtoResponse() {}
+func (UserSelfDtoResponse) isUserDtoResponse()   {}
+func (UserAdminDtoResponse) isUserDtoResponse()  {}
+
 func FromUserDtoRequest(user UserDtoRequest) persistence.User {
 	t := time.Now()
 	return persistence.User{
@@ -33,12 +95,66 @@ func FromUserDtoRequest(user UserDtoRequest) persistence.User {
 	}
 }
 
-func ToUserDtoResponse(user persistence.User) UserDtoResponse {
-	return UserDtoResponse{
-		Id:       user.Id,
-		Email:    user.Email,
-		Password: user.Password,
+// ToUserDtoResponse projects the user for the view. The lockouts are not
+// stored with the users: administrators see the account as not locked, use
+// ToUserAdminDtoResponse when it is known.
+func ToUserDtoResponse(user persistence.User, view UserView) UserDtoResponse {
+	switch view {
+	case AdminView:
+		return ToUserAdminDtoResponse(user, false)
+	case SelfView:
+		return ToUserSelfDtoResponse(user)
+	default:
+		return ToUserPublicDtoResponse(user)
+	}
+}
+
+func ToUserPublicDtoResponse(user persistence.User) UserPublicDtoResponse {
+	return UserPublicDtoResponse{
+		Id: user.Id,
 
 		CreatedAt: user.CreatedAt,
 	}
 }
+
+func ToUserSelfDtoResponse(user persistence.User) UserSelfDtoResponse {
+	return UserSelfDtoResponse{
+		Id:              user.Id,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+
+		Version: user.Version,
+
+		PasswordChangeRequired: user.PasswordChangeRequired,
+	}
+}
+
+// ToUserAdminDtoResponse derives the status from the lockout of the account,
+// which the caller determines from the failed login attempts, and from the
+// verification of the email. A locked account is reported as such whether
+// its email is verified or not.
+func ToUserAdminDtoResponse(user persistence.User, locked bool) UserAdminDtoResponse {
+	status := ActiveStatus
+	switch {
+	case locked:
+		status = LockedStatus
+	case user.EmailVerifiedAt == nil:
+		status = UnverifiedStatus
+	}
+
+	return UserAdminDtoResponse{
+		Id:     user.Id,
+		Email:  user.Email,
+		Status: status,
+
+		EmailVerifiedAt: user.EmailVerifiedAt,
+
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+
+		Version: user.Version,
+	}
+}
@@ -45,12 +45,34 @@ func TestUnit_FromUserDtoRequest(t *testing.T) {
 	assert.Equal(t, actual.CreatedAt, actual.UpdatedAt)
 }
 
-func TestUnit_UserDtoResponse_MarshalsToCamelCase(t *testing.T) {
-	dto := UserDtoResponse{
+func TestUnit_UserPublicDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := UserPublicDtoResponse{
+		Id:        uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814"),
+		CreatedAt: someTime,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "a590b448-d3cd-4dbc-a9e3-8d642b1a5814",
+		"createdAt": "2024-11-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_UserSelfDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := UserSelfDtoResponse{
 		Id:        uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814"),
 		Email:     "some@e.mail",
-		Password:  "secret",
 		CreatedAt: someTime,
+		UpdatedAt: someTime.Add(2 * time.Hour),
+		Version:   3,
+
+		EmailVerifiedAt:        &someTime,
+		PasswordChangeRequired: true,
+		RecoveryCodesLeft:      7,
 	}
 
 	out, err := json.Marshal(dto)
@@ -60,25 +82,160 @@ func TestUnit_UserDtoResponse_MarshalsToCamelCase(t *testing.T) {
 	{
 		"id": "a590b448-d3cd-4dbc-a9e3-8d642b1a5814",
 		"email": "some@e.mail",
-		"password": "secret",
-		"createdAt": "2024-11-12T19:09:36Z"
+		"emailVerifiedAt": "2024-11-12T19:09:36Z",
+		"createdAt": "2024-11-12T19:09:36Z",
+		"updatedAt": "2024-11-12T21:09:36Z",
+		"version": 3,
+		"passwordChangeRequired": true,
+		"recoveryCodesLeft": 7
 	}`
 	assert.JSONEq(t, expectedJson, string(out))
 }
 
-func TestUnit_ToUserDtoResponse(t *testing.T) {
+func TestUnit_UserAdminDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := UserAdminDtoResponse{
+		Id:        uuid.MustParse("a590b448-d3cd-4dbc-a9e3-8d642b1a5814"),
+		Email:     "some@e.mail",
+		Status:    ActiveStatus,
+		CreatedAt: someTime,
+		UpdatedAt: someTime.Add(2 * time.Hour),
+		Version:   3,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "a590b448-d3cd-4dbc-a9e3-8d642b1a5814",
+		"email": "some@e.mail",
+		"status": "active",
+		"createdAt": "2024-11-12T19:09:36Z",
+		"updatedAt": "2024-11-12T21:09:36Z",
+		"version": 3
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToUserDtoResponse_DoesNotLeakPassword(t *testing.T) {
+	entity := persistence.User{
+		Id:       uuid.New(),
+		Email:    "email",
+		Password: "password",
+	}
+
+	for _, view := range []UserView{PublicView, SelfView, AdminView} {
+		out, err := json.Marshal(ToUserDtoResponse(entity, view))
+
+		assert.Nil(t, err)
+		assert.NotContains(t, string(out), "password")
+	}
+}
+
+func TestUnit_ToUserDtoResponse_PicksProjectionFromView(t *testing.T) {
+	entity := persistence.User{
+		Id: uuid.New(),
+	}
+
+	assert.IsType(t, UserPublicDtoResponse{}, ToUserDtoResponse(entity, PublicView))
+	assert.IsType(t, UserSelfDtoResponse{}, ToUserDtoResponse(entity, SelfView))
+	assert.IsType(t, UserAdminDtoResponse{}, ToUserDtoResponse(entity, AdminView))
+}
+
+func TestUnit_ToUserPublicDtoResponse(t *testing.T) {
+	entity := persistence.User{
+		Id:       uuid.New(),
+		Email:    "email",
+		Password: "password",
+
+		CreatedAt: someTime,
+	}
+
+	actual := ToUserPublicDtoResponse(entity)
+
+	assert.Equal(t, entity.Id, actual.Id)
+	assert.Equal(t, someTime, actual.CreatedAt)
+}
+
+func TestUnit_ToUserSelfDtoResponse(t *testing.T) {
 	entity := persistence.User{
 		Id:       uuid.New(),
 		Email:    "email",
 		Password: "password",
 
 		CreatedAt: someTime,
+		UpdatedAt: someTime.Add(1 * time.Hour),
+
+		Version: 4,
+
+		EmailVerifiedAt: &someTime,
 	}
 
-	actual := ToUserDtoResponse(entity)
+	actual := ToUserSelfDtoResponse(entity)
 
 	assert.Equal(t, entity.Id, actual.Id)
 	assert.Equal(t, "email", actual.Email)
-	assert.Equal(t, "password", actual.Password)
+	assert.Equal(t, &someTime, actual.EmailVerifiedAt)
 	assert.Equal(t, someTime, actual.CreatedAt)
+	assert.Equal(t, entity.UpdatedAt, actual.UpdatedAt)
+	assert.Equal(t, 4, actual.Version)
+}
+
+func TestUnit_ToUserAdminDtoResponse(t *testing.T) {
+	entity := persistence.User{
+		Id:       uuid.New(),
+		Email:    "email",
+		Password: "password",
+
+		CreatedAt: someTime,
+		UpdatedAt: someTime.Add(1 * time.Hour),
+
+		Version: 4,
+
+		EmailVerifiedAt: &someTime,
+	}
+
+	actual := ToUserAdminDtoResponse(entity, false)
+
+	assert.Equal(t, entity.Id, actual.Id)
+	assert.Equal(t, "email", actual.Email)
+	assert.Equal(t, &someTime, actual.EmailVerifiedAt)
+	assert.Equal(t, ActiveStatus, actual.Status)
+	assert.Equal(t, someTime, actual.CreatedAt)
+	assert.Equal(t, entity.UpdatedAt, actual.UpdatedAt)
+	assert.Equal(t, 4, actual.Version)
+}
+
+func TestUnit_ToUserAdminDtoResponse_WhenEmailIsNotVerified_ExpectUnverified(t *testing.T) {
+	entity := persistence.User{
+		Id:    uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Email: "email",
+	}
+
+	actual := ToUserAdminDtoResponse(entity, false)
+
+	assert.Equal(t, UnverifiedStatus, actual.Status)
+}
+
+func TestUnit_ToUserAdminDtoResponse_WhenAccountIsLocked_ExpectLocked(t *testing.T) {
+	entity := persistence.User{
+		Id:              uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Email:           "email",
+		EmailVerifiedAt: &someTime,
+	}
+
+	actual := ToUserAdminDtoResponse(entity, true)
+
+	assert.Equal(t, LockedStatus, actual.Status)
+}
+
+func TestUnit_ToUserAdminDtoResponse_WhenLockedAndNotVerified_ExpectLocked(t *testing.T) {
+	entity := persistence.User{
+		Id:    uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Email: "email",
+	}
+
+	actual := ToUserAdminDtoResponse(entity, true)
+
+	assert.Equal(t, LockedStatus, actual.Status)
 }
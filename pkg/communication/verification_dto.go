@@ -0,0 +1,5 @@
+package communication
+
+type VerificationDtoRequest struct {
+	Token string `json:"token" form:"token" binding:"required" example:"usv_live_37U2pWCvPij9APPECyvHgmxAwr3z3TphmsxUMGPvrjJ3GoSOC"`
+}
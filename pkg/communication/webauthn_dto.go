@@ -0,0 +1,133 @@
+package communication
+
+import (
+	"time"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+// The options and credentials below follow the JSON serialization of the
+// WebAuthn API: the options can be given to
+// PublicKeyCredential.parseCreationOptionsFromJSON() and
+// parseRequestOptionsFromJSON(), and the credentials are the output of
+// PublicKeyCredential.toJSON(). Binary values are base64url encoded.
+
+type WebAuthnRelyingPartyDto struct {
+	Id   string `json:"id" binding:"required" example:"example.com"`
+	Name string `json:"name" binding:"required" example:"user-service"`
+}
+
+type WebAuthnUserDto struct {
+	Id          string `json:"id" binding:"required" example:"pe_3qZvdT1mEYsbxBtRzXg"`
+	Name        string `json:"name" binding:"required" example:"user@example.com"`
+	DisplayName string `json:"displayName" binding:"required" example:"user@example.com"`
+}
+
+type WebAuthnCredentialParametersDto struct {
+	Type string `json:"type" binding:"required" example:"public-key"`
+	Alg  int64  `json:"alg" binding:"required" example:"-7"`
+}
+
+type WebAuthnCredentialDescriptorDto struct {
+	Type       string   `json:"type" binding:"required" example:"public-key"`
+	Id         string   `json:"id" binding:"required" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Transports []string `json:"transports,omitempty" example:"internal,hybrid"`
+}
+
+type WebAuthnAuthenticatorSelectionDto struct {
+	ResidentKey      string `json:"residentKey" binding:"required" example:"preferred"`
+	UserVerification string `json:"userVerification" binding:"required" example:"preferred"`
+}
+
+type WebAuthnCreationOptionsDtoResponse struct {
+	Challenge        string                            `json:"challenge" binding:"required" example:"yB0mXbbGbKE6pW1HuJgVq8S1oXUPEs2kHSzqW5BKxFo"`
+	Rp               WebAuthnRelyingPartyDto           `json:"rp" binding:"required"`
+	User             WebAuthnUserDto                   `json:"user" binding:"required"`
+	PubKeyCredParams []WebAuthnCredentialParametersDto `json:"pubKeyCredParams" binding:"required"`
+	// Timeout is in milliseconds.
+	Timeout int64 `json:"timeout" binding:"required" example:"300000"`
+	// ExcludeCredentials lists the credentials the user already registered.
+	ExcludeCredentials     []WebAuthnCredentialDescriptorDto `json:"excludeCredentials" binding:"required"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelectionDto `json:"authenticatorSelection" binding:"required"`
+	Attestation            string                            `json:"attestation" binding:"required" example:"none"`
+}
+
+type WebAuthnRequestOptionsDtoResponse struct {
+	Challenge string `json:"challenge" binding:"required" example:"yB0mXbbGbKE6pW1HuJgVq8S1oXUPEs2kHSzqW5BKxFo"`
+	// Timeout is in milliseconds.
+	Timeout int64  `json:"timeout" binding:"required" example:"300000"`
+	RpId    string `json:"rpId" binding:"required" example:"example.com"`
+	// AllowCredentials is empty when the user is not known yet: the browser
+	// offers the passkeys it holds for the relying party.
+	AllowCredentials []WebAuthnCredentialDescriptorDto `json:"allowCredentials" binding:"required"`
+	UserVerification string                            `json:"userVerification" binding:"required" example:"required"`
+}
+
+type WebAuthnAttestationResponseDto struct {
+	ClientDataJson    string   `json:"clientDataJSON" binding:"required" example:"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIn0"`
+	AttestationObject string   `json:"attestationObject" binding:"required" example:"o2NmbXRkbm9uZQ"`
+	Transports        []string `json:"transports" example:"internal,hybrid"`
+}
+
+type WebAuthnAttestationDto struct {
+	Id       string                         `json:"id" binding:"required" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string                         `json:"type" binding:"required" example:"public-key"`
+	Response WebAuthnAttestationResponseDto `json:"response" binding:"required"`
+}
+
+type WebAuthnRegistrationDtoRequest struct {
+	// Name helps the user recognize the credential in the list.
+	Name       string                 `json:"name" example:"My phone"`
+	Credential WebAuthnAttestationDto `json:"credential" binding:"required"`
+}
+
+type WebAuthnAssertionResponseDto struct {
+	ClientDataJson    string `json:"clientDataJSON" binding:"required" example:"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0In0"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required" example:"o3mm9u6vuaVeN4wRgDTidR5oL6ufLTCrE9ISVYbOGUcdAAAAAQ"`
+	Signature         string `json:"signature" binding:"required" example:"MEUCIQDs"`
+	UserHandle        string `json:"userHandle" example:"pe_3qZvdT1mEYsbxBtRzXg"`
+}
+
+type WebAuthnAssertionDtoRequest struct {
+	Id       string                       `json:"id" binding:"required" example:"AQIDBAUGBwgJCgsMDQ4PEA"`
+	Type     string                       `json:"type" binding:"required" example:"public-key"`
+	Response WebAuthnAssertionResponseDto `json:"response" binding:"required"`
+}
+
+// MfaChallengeDtoRequest starts answering a challenge with a passkey.
+type MfaChallengeDtoRequest struct {
+	Challenge string `json:"challenge" binding:"required" example:"usm_live_4mX8cQ2rTn5vWk9pLz3dHf7jYb6sGa1uEo0iNhRqVwC2xBt9a"`
+}
+
+type WebAuthnMfaDtoRequest struct {
+	Challenge  string                      `json:"challenge" binding:"required" example:"usm_live_4mX8cQ2rTn5vWk9pLz3dHf7jYb6sGa1uEo0iNhRqVwC2xBt9a"`
+	Credential WebAuthnAssertionDtoRequest `json:"credential" binding:"required"`
+}
+
+type WebAuthnCredentialDtoResponse struct {
+	Id         uuid.UUID `json:"id" binding:"required" format:"uuid" example:"1d9c3f8e-5a3b-4c52-9f0e-0b7e4f6f2c11"`
+	Name       string    `json:"name" binding:"required" example:"My phone"`
+	Transports []string  `json:"transports" binding:"required" example:"internal,hybrid"`
+	// Synced passkeys are backed up by their provider rather than bound to
+	// a single device.
+	Synced     bool       `json:"synced" binding:"required" example:"true"`
+	CreatedAt  time.Time  `json:"createdAt" binding:"required" format:"date-time" example:"2026-04-28T17:56:59Z"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" format:"date-time" example:"2026-04-28T18:12:03Z"`
+}
+
+func ToWebAuthnCredentialDtoResponse(credential persistence.WebAuthnCredential) WebAuthnCredentialDtoResponse {
+	transports := credential.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+
+	return WebAuthnCredentialDtoResponse{
+		Id:         credential.Id,
+		Name:       credential.Name,
+		Transports: transports,
+		Synced:     credential.BackupEligible,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
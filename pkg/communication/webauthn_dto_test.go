@@ -0,0 +1,57 @@
+package communication
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnit_WebAuthnCredentialDtoResponse_MarshalsToCamelCase(t *testing.T) {
+	dto := WebAuthnCredentialDtoResponse{
+		Id:         uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Name:       "my-passkey",
+		Transports: []string{"internal", "hybrid"},
+		Synced:     true,
+		CreatedAt:  someTime,
+	}
+
+	out, err := json.Marshal(dto)
+
+	assert.Nil(t, err)
+	expectedJson := `
+	{
+		"id": "c74a22da-8a05-43a9-a8b9-717e422b0af4",
+		"name": "my-passkey",
+		"transports": ["internal", "hybrid"],
+		"synced": true,
+		"createdAt": "2024-11-12T19:09:36Z"
+	}`
+	assert.JSONEq(t, expectedJson, string(out))
+}
+
+func TestUnit_ToWebAuthnCredentialDtoResponse(t *testing.T) {
+	credential := persistence.WebAuthnCredential{
+		Id:             uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		ApiUser:        uuid.MustParse("4f26321f-d0ea-46a3-83dd-6aa1c6053aaf"),
+		CredentialId:   []byte("my-credential-id"),
+		PublicKey:      []byte("my-public-key"),
+		SignCount:      3,
+		Name:           "my-passkey",
+		BackupEligible: true,
+		CreatedAt:      someTime,
+	}
+
+	actual := ToWebAuthnCredentialDtoResponse(credential)
+
+	expected := WebAuthnCredentialDtoResponse{
+		Id:         uuid.MustParse("c74a22da-8a05-43a9-a8b9-717e422b0af4"),
+		Name:       "my-passkey",
+		Transports: []string{},
+		Synced:     true,
+		CreatedAt:  someTime,
+	}
+	assert.Equal(t, expected, actual)
+}
@@ -7,9 +7,30 @@ import (
 )
 
 type ApiKey struct {
-	Id      uuid.UUID
-	Key     uuid.UUID
+	Id uuid.UUID
+	// KeyHash is the digest of the key: the key itself is never stored.
+	KeyHash string
 	ApiUser uuid.UUID
 
+	// UserAgent and IpAddress describe the client which logged in so that
+	// users can tell their sessions apart.
+	UserAgent string
+	IpAddress string
+
+	// TokenId is the identifier of the signed token issued for the session.
+	// It is nil when the session uses an opaque key.
+	TokenId *uuid.UUID
+
+	// ClientId and Scope are set when the session was opened by an OAuth
+	// client. They are empty for the sessions opened by the login endpoint.
+	ClientId string
+	Scope    string
+
+	// PasswordChangeRequired is set for the sessions opened while the user
+	// has to change their password: they can only be used to do so.
+	PasswordChangeRequired bool
+
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
 	ValidUntil time.Time
 }
@@ -0,0 +1,29 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuthorizationCode is issued to an OAuth client once the user logged in:
+// the client exchanges it for a session.
+type AuthorizationCode struct {
+	Id uuid.UUID
+	// CodeHash is the digest of the code: the code itself is never stored.
+	CodeHash string
+	ClientId string
+	ApiUser  uuid.UUID
+
+	// RedirectUri, Scope and Nonce are the values provided by the client
+	// when requesting the code.
+	RedirectUri string
+	Scope       string
+	Nonce       string
+	// CodeChallenge is the PKCE challenge the client has to answer when
+	// exchanging the code.
+	CodeChallenge string
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
@@ -0,0 +1,27 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailLogin is sent to a user who wants to log in without their password.
+// It holds both a link and a short code to type in: either of them can be
+// used, once.
+type EmailLogin struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// Email is the address the message was sent to: it can't be used once
+	// the user changed their email.
+	Email string
+	// TokenHash and CodeHash are the digests of the token of the link and
+	// of the code: neither of them is stored.
+	TokenHash string
+	CodeHash  string
+	// Attempts counts the wrong codes provided for the login.
+	Attempts int
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
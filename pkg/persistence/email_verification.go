@@ -0,0 +1,22 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerification is sent to a user to prove that they own their email.
+type EmailVerification struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// Email is the address the token was sent to: it does not verify the
+	// email of the user once they changed it.
+	Email string
+	// TokenHash is the digest of the token: the token itself is never
+	// stored.
+	TokenHash string
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
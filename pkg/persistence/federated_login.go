@@ -0,0 +1,23 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FederatedLogin is started when the user is redirected to an external
+// identity provider and completed when they come back.
+type FederatedLogin struct {
+	Id uuid.UUID
+	// StateHash is the digest of the state sent to the provider: the state
+	// itself is never stored.
+	StateHash string
+	Provider  string
+	// Nonce and CodeVerifier are needed to verify the answer of the provider.
+	Nonce        string
+	CodeVerifier string
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
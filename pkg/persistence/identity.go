@@ -0,0 +1,23 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Identity links a user to their account at an external identity provider.
+type Identity struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// Provider is the name of the provider in the configuration and Subject
+	// the identifier of the user there.
+	Provider string
+	Subject  string
+	// Email is the address known by the provider at the last login. It can
+	// differ from the one of the user.
+	Email string
+
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
@@ -0,0 +1,18 @@
+package persistence
+
+import (
+	"time"
+)
+
+type KeyringKey struct {
+	Id        string
+	Purpose   string
+	Algorithm string
+	// Material is encrypted with the master key of the keyring.
+	Material []byte
+	State    string
+
+	CreatedAt   time.Time
+	ActivatedAt *time.Time
+	RetiredAt   *time.Time
+}
@@ -0,0 +1,48 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoginThrottleKind string
+
+const (
+	EmailLoginThrottle LoginThrottleKind = "email"
+	IpLoginThrottle    LoginThrottleKind = "ip"
+	// The messages requested for an address are counted like the failed
+	// login attempts, the failures being the requests.
+	PasswordResetRequestThrottle LoginThrottleKind = "password_reset"
+	EmailLoginRequestThrottle    LoginThrottleKind = "email_login"
+)
+
+type LoginThrottle struct {
+	Kind LoginThrottleKind
+	Key  string
+
+	Failures      int
+	LastFailureAt time.Time
+
+	BlockedUntil time.Time
+	Locked       bool
+}
+
+// LoginBackOff tells how long a key is blocked after each failure.
+type LoginBackOff struct {
+	// Delays holds the delay after each failure: the last one applies to
+	// the next failures as well.
+	Delays []time.Duration
+	// LockAfter is the number of failures after which the key is locked
+	// for LockFor instead. Keys are never locked when it is 0.
+	LockAfter int
+	LockFor   time.Duration
+}
+
+type AccountLockout struct {
+	ApiUser uuid.UUID
+	Email   string
+
+	Failures    int
+	LockedUntil time.Time
+}
@@ -0,0 +1,22 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MfaChallenge is issued when the password of a user with a second factor
+// matches: the session is only opened once the second factor is provided.
+type MfaChallenge struct {
+	Id uuid.UUID
+	// TokenHash is the digest of the challenge token: the token itself is
+	// never stored.
+	TokenHash string
+	ApiUser   uuid.UUID
+	// Attempts counts the wrong codes provided for the challenge.
+	Attempts int
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
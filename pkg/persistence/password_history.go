@@ -0,0 +1,17 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistory is a password the user had before changing it. Only its
+// hash is kept, to prevent the user from choosing it again.
+type PasswordHistory struct {
+	Id       uuid.UUID
+	ApiUser  uuid.UUID
+	Password string
+
+	CreatedAt time.Time
+}
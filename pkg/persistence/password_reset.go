@@ -0,0 +1,23 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasswordReset is sent to a user who forgot their password to let them
+// choose a new one.
+type PasswordReset struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// Email is the address the token was sent to: it can't be used once the
+	// user changed their email.
+	Email string
+	// TokenHash is the digest of the token: the token itself is never
+	// stored.
+	TokenHash string
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
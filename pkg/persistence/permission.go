@@ -0,0 +1,15 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Permission struct {
+	Id          uuid.UUID
+	Name        string
+	Description string
+
+	CreatedAt time.Time
+}
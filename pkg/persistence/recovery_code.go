@@ -0,0 +1,24 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecoveryCode lets a user back into their account when the password is
+// lost. Each code can only be used once.
+type RecoveryCode struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// CodeHash is the digest of the code: the code itself is only shown
+	// to the user when it is generated.
+	CodeHash string
+
+	CreatedAt time.Time
+	// UsedAt, UserAgent and IpAddress record where the code was used from.
+	// They are empty as long as the code is available.
+	UsedAt    *time.Time
+	UserAgent *string
+	IpAddress *string
+}
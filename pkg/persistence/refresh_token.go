@@ -0,0 +1,20 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshToken struct {
+	Id uuid.UUID
+	// TokenHash is the digest of the token: the token itself is never stored.
+	TokenHash string
+	// ApiKey is the session the token allows to extend. All the tokens of a
+	// session are kept until it is revoked so that reuses can be detected.
+	ApiKey uuid.UUID
+
+	CreatedAt  time.Time
+	UsedAt     *time.Time
+	ValidUntil time.Time
+}
@@ -0,0 +1,36 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	Id          uuid.UUID
+	Name        string
+	Description string
+	// Parent is the role this one inherits the permissions of, if any.
+	Parent *uuid.UUID
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Version int
+}
+
+// RoleAssignment grants a role to a user, along with the roles it inherits
+// from.
+type RoleAssignment struct {
+	ApiUser uuid.UUID
+	Role    uuid.UUID
+
+	CreatedAt time.Time
+}
+
+// RolePermission is a permission granted directly to a role, identified by
+// its name.
+type RolePermission struct {
+	Role       uuid.UUID
+	Permission string
+}
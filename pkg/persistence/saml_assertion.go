@@ -0,0 +1,16 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SamlAssertion records an assertion used to sign in so that it can't be
+// used a second time.
+type SamlAssertion struct {
+	Id          uuid.UUID
+	Issuer      string
+	AssertionId string
+	ValidUntil  time.Time
+}
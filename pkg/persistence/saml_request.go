@@ -0,0 +1,23 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SamlRequest is created when the user is redirected to the identity
+// provider of a tenant and completed when the provider posts its answer.
+type SamlRequest struct {
+	Id uuid.UUID
+	// RequestId is the identifier of the authentication request: answers
+	// refer to it.
+	RequestId string
+	// RelayStateHash is the digest of the relay state kept in the browser
+	// of the user: the relay state itself is never stored.
+	RelayStateHash string
+	Tenant         string
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
@@ -0,0 +1,22 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TotpSecret is shared with the authenticator app of the user. It is only
+// asked for at login once confirmed.
+type TotpSecret struct {
+	ApiUser uuid.UUID
+	// Secret is encrypted: it can't be read without the encryption key of
+	// the service.
+	Secret []byte
+	// LastCounter is the time step of the last accepted code: codes of this
+	// step and earlier ones are rejected so that they can't be replayed.
+	LastCounter int64
+
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+}
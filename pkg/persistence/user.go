@@ -10,6 +10,12 @@ type User struct {
 	Id       uuid.UUID
 	Email    string
 	Password string
+	// PasswordChangeRequired is set when the user logs in with a recovery
+	// code: the password they lost should be replaced.
+	PasswordChangeRequired bool
+	// EmailVerifiedAt is empty until the user proves that they own their
+	// email.
+	EmailVerifiedAt *time.Time
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -0,0 +1,29 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebAuthnCeremonyKind string
+
+const (
+	WebAuthnRegistration WebAuthnCeremonyKind = "registration"
+	WebAuthnLogin        WebAuthnCeremonyKind = "login"
+	WebAuthnSecondFactor WebAuthnCeremonyKind = "second_factor"
+)
+
+// WebAuthnCeremony is started when options are sent to the browser and
+// completed with its answer, which holds the challenge.
+type WebAuthnCeremony struct {
+	Id uuid.UUID
+	// ChallengeHash is the digest of the challenge sent to the browser.
+	ChallengeHash string
+	Kind          WebAuthnCeremonyKind
+	// ApiUser is not known for logins without a password.
+	ApiUser *uuid.UUID
+
+	CreatedAt  time.Time
+	ValidUntil time.Time
+}
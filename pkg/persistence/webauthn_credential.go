@@ -0,0 +1,30 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey or a security key of the user. It can be
+// used to log in without a password or as a second factor.
+type WebAuthnCredential struct {
+	Id      uuid.UUID
+	ApiUser uuid.UUID
+	// CredentialId is chosen by the authenticator and PublicKey is COSE
+	// encoded, as received from it.
+	CredentialId []byte
+	PublicKey    []byte
+	// SignCount is the number of signatures reported by the authenticator:
+	// it must increase with each use unless it stays zero.
+	SignCount int64
+	// Transports are hints given back to the browser to find the credential.
+	Transports []string
+	Aaguid     uuid.UUID
+	// Name is chosen by the user to recognize the credential.
+	Name           string
+	BackupEligible bool
+
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
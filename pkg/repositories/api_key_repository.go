@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
@@ -9,11 +10,17 @@ import (
 )
 
 type ApiKeyRepository interface {
-	Create(ctx context.Context, apiKey persistence.ApiKey) (persistence.ApiKey, error)
+	Create(ctx context.Context, tx db.Transaction, apiKey persistence.ApiKey) (persistence.ApiKey, error)
 	Get(ctx context.Context, id uuid.UUID) (persistence.ApiKey, error)
-	GetForKey(ctx context.Context, apiKey uuid.UUID) (persistence.ApiKey, error)
-	GetForUser(ctx context.Context, user uuid.UUID) (persistence.ApiKey, error)
+	GetForKey(ctx context.Context, keyHashes []string) (persistence.ApiKey, error)
+	ListForUser(ctx context.Context, user uuid.UUID) ([]persistence.ApiKey, error)
+	LockForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) ([]persistence.ApiKey, error)
+	Touch(ctx context.Context, id uuid.UUID, usedAt time.Time, validUntil time.Time) error
+	Rotate(ctx context.Context, tx db.Transaction, id uuid.UUID, keyHash string, tokenId *uuid.UUID, validUntil time.Time) error
+	ClearPasswordChangeRequired(ctx context.Context, tx db.Transaction, id uuid.UUID) error
+	Delete(ctx context.Context, tx db.Transaction, id uuid.UUID) error
 	DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error
+	DeleteForUserExcept(ctx context.Context, tx db.Transaction, user uuid.UUID, except uuid.UUID) error
 }
 
 type apiKeyRepositoryImpl struct {
@@ -27,36 +34,17 @@ func NewApiKeyRepository(conn db.Connection) ApiKeyRepository {
 }
 
 const createApiKeySqlTemplate = `
-INSERT INTO api_key (id, key, api_user, valid_until)
-	VALUES($1, $2, $3, $4)
-	ON CONFLICT (api_user) DO UPDATE
-	SET
-		valid_until = excluded.valid_until
-	WHERE
-		api_key.api_user = excluded.api_user
-	RETURNING
-		api_key.id,
-		api_key.key
-`
-
-func (r *apiKeyRepositoryImpl) Create(ctx context.Context, apiKey persistence.ApiKey) (persistence.ApiKey, error) {
-	type apiKeyDetails struct {
-		Id  uuid.UUID
-		Key uuid.UUID
-	}
-	keyDetails, err := db.QueryOne[apiKeyDetails](ctx, r.conn, createApiKeySqlTemplate, apiKey.Id, apiKey.Key, apiKey.ApiUser, apiKey.ValidUntil)
-	if err != nil {
-		return persistence.ApiKey{}, err
-	}
+INSERT INTO api_key (id, key_hash, api_user, user_agent, ip_address, token_id, client_id, scope, password_change_required, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
-	apiKey.Id = keyDetails.Id
-	apiKey.Key = keyDetails.Key
-	return apiKey, nil
+func (r *apiKeyRepositoryImpl) Create(ctx context.Context, tx db.Transaction, apiKey persistence.ApiKey) (persistence.ApiKey, error) {
+	_, err := tx.Exec(ctx, createApiKeySqlTemplate, apiKey.Id, apiKey.KeyHash, apiKey.ApiUser, apiKey.UserAgent, apiKey.IpAddress, apiKey.TokenId, apiKey.ClientId, apiKey.Scope, apiKey.PasswordChangeRequired, apiKey.CreatedAt, apiKey.ValidUntil)
+	return apiKey, err
 }
 
 const getApiKeySqlTemplate = `
 SELECT
-	id, key, api_user, valid_until
+	id, key_hash, api_user, user_agent, ip_address, token_id, client_id, scope, password_change_required, created_at, last_used_at, valid_until
 FROM
 	api_key
 WHERE
@@ -68,26 +56,107 @@ func (r *apiKeyRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (persisten
 
 const getApiKeyForKeySqlTemplate = `
 SELECT
-	id, key, api_user, valid_until
+	id, key_hash, api_user, user_agent, ip_address, token_id, client_id, scope, password_change_required, created_at, last_used_at, valid_until
 FROM
 	api_key
 WHERE
-	key = $1`
+	key_hash = ANY($1)`
 
-func (r *apiKeyRepositoryImpl) GetForKey(ctx context.Context, apiKey uuid.UUID) (persistence.ApiKey, error) {
-	return db.QueryOne[persistence.ApiKey](ctx, r.conn, getApiKeyForKeySqlTemplate, apiKey)
+func (r *apiKeyRepositoryImpl) GetForKey(ctx context.Context, keyHashes []string) (persistence.ApiKey, error) {
+	return db.QueryOne[persistence.ApiKey](ctx, r.conn, getApiKeyForKeySqlTemplate, keyHashes)
 }
 
-const getApiKeyForUserSqlTemplate = `
+const listApiKeyForUserSqlTemplate = `
 SELECT
-	id, key, api_user, valid_until
+	id, key_hash, api_user, user_agent, ip_address, token_id, client_id, scope, password_change_required, created_at, last_used_at, valid_until
 FROM
 	api_key
 WHERE
-	api_user = $1`
+	api_user = $1
+ORDER BY
+	created_at`
+
+func (r *apiKeyRepositoryImpl) ListForUser(ctx context.Context, user uuid.UUID) ([]persistence.ApiKey, error) {
+	return db.QueryAll[persistence.ApiKey](ctx, r.conn, listApiKeyForUserSqlTemplate, user)
+}
+
+const lockUserSqlTemplate = `
+SELECT
+	id
+FROM
+	api_user
+WHERE
+	id = $1
+FOR UPDATE`
+
+// LockForUser returns the keys of the user and prevents concurrent
+// transactions from creating new ones until the input transaction is over.
+// Locking the keys themselves would not be enough as it does not prevent
+// inserting new ones.
+func (r *apiKeyRepositoryImpl) LockForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) ([]persistence.ApiKey, error) {
+	_, err := db.QueryOneTx[uuid.UUID](ctx, tx, lockUserSqlTemplate, user)
+	if err != nil {
+		return nil, err
+	}
 
-func (r *apiKeyRepositoryImpl) GetForUser(ctx context.Context, user uuid.UUID) (persistence.ApiKey, error) {
-	return db.QueryOne[persistence.ApiKey](ctx, r.conn, getApiKeyForUserSqlTemplate, user)
+	return db.QueryAllTx[persistence.ApiKey](ctx, tx, listApiKeyForUserSqlTemplate, user)
+}
+
+// The key is refreshed at most once per minute: this avoids writing to the
+// database for each request while still being accurate enough for users.
+// The expiration date can only be pushed back.
+const touchApiKeySqlTemplate = `
+UPDATE
+	api_key
+SET
+	last_used_at = $2,
+	valid_until = GREATEST(valid_until, $3)
+WHERE
+	id = $1
+	AND (last_used_at IS NULL OR last_used_at < $2 - interval '1 minute')`
+
+func (r *apiKeyRepositoryImpl) Touch(ctx context.Context, id uuid.UUID, usedAt time.Time, validUntil time.Time) error {
+	_, err := r.conn.Exec(ctx, touchApiKeySqlTemplate, id, usedAt, validUntil)
+	return err
+}
+
+const rotateApiKeySqlTemplate = `
+UPDATE
+	api_key
+SET
+	key_hash = $2,
+	token_id = $3,
+	valid_until = $4
+WHERE
+	id = $1`
+
+func (r *apiKeyRepositoryImpl) Rotate(ctx context.Context, tx db.Transaction, id uuid.UUID, keyHash string, tokenId *uuid.UUID, validUntil time.Time) error {
+	_, err := tx.Exec(ctx, rotateApiKeySqlTemplate, id, keyHash, tokenId, validUntil)
+	return err
+}
+
+const clearApiKeyPasswordChangeRequiredSqlTemplate = `
+UPDATE
+	api_key
+SET
+	password_change_required = false
+WHERE
+	id = $1`
+
+func (r *apiKeyRepositoryImpl) ClearPasswordChangeRequired(ctx context.Context, tx db.Transaction, id uuid.UUID) error {
+	_, err := tx.Exec(ctx, clearApiKeyPasswordChangeRequiredSqlTemplate, id)
+	return err
+}
+
+const deleteApiKeySqlTemplate = `
+DELETE FROM
+	api_key
+WHERE
+	id = $1`
+
+func (r *apiKeyRepositoryImpl) Delete(ctx context.Context, tx db.Transaction, id uuid.UUID) error {
+	_, err := tx.Exec(ctx, deleteApiKeySqlTemplate, id)
+	return err
 }
 
 const deleteApiKeyForUserSqlTemplate = `
@@ -100,3 +169,15 @@ func (r *apiKeyRepositoryImpl) DeleteForUser(ctx context.Context, tx db.Transact
 	_, err := tx.Exec(ctx, deleteApiKeyForUserSqlTemplate, user)
 	return err
 }
+
+const deleteApiKeyForUserExceptSqlTemplate = `
+DELETE FROM
+	api_key
+WHERE
+	api_user = $1
+	AND id != $2`
+
+func (r *apiKeyRepositoryImpl) DeleteForUserExcept(ctx context.Context, tx db.Transaction, user uuid.UUID, except uuid.UUID) error {
+	_, err := tx.Exec(ctx, deleteApiKeyForUserExceptSqlTemplate, user, except)
+	return err
+}
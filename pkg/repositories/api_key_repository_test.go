@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
 	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
 	"github.com/Knoblauchpilze/user-service/pkg/persistence"
 	"github.com/google/uuid"
@@ -14,70 +15,123 @@ import (
 )
 
 func TestIT_ApiKeyRepository_Create(t *testing.T) {
-	repo, conn := newTestApiKeyRepository(t)
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
 
 	user := insertTestUser(t, conn)
 
 	apiKey := persistence.ApiKey{
 		Id:      uuid.New(),
-		Key:     uuid.New(),
+		KeyHash: "my-key-hash-" + uuid.NewString(),
 		ApiUser: user.Id,
 
+		UserAgent: "my-user-agent",
+		IpAddress: "203.0.113.42",
+
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
 		ValidUntil: time.Date(2024, 11, 12, 18, 32, 20, 0, time.UTC),
 	}
 
-	actual, err := repo.Create(context.Background(), apiKey)
+	actual, err := repo.Create(context.Background(), tx, apiKey)
+	tx.Close(context.Background())
 	assert.Nil(t, err)
 
 	assert.Equal(t, apiKey, actual)
 	assertApiKeyExists(t, conn, apiKey.Id)
+
+	stored, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	assert.Equal(t, apiKey, toUtcApiKey(stored))
 }
 
-func TestIT_ApiKeyRepository_Create_WhenDuplicateForUser_ExpectKeyIsReturned(t *testing.T) {
-	repo, conn := newTestApiKeyRepository(t)
+func TestIT_ApiKeyRepository_Create_WhenOpenedByClient_ExpectClientIsStored(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
+	user := insertTestUser(t, conn)
+
+	apiKey := persistence.ApiKey{
+		Id:      uuid.New(),
+		KeyHash: "my-key-hash-" + uuid.NewString(),
+		ApiUser: user.Id,
+
+		ClientId: "my-client",
+		Scope:    "openid email",
+
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: time.Date(2024, 11, 12, 18, 32, 20, 0, time.UTC),
+	}
+
+	_, err := repo.Create(context.Background(), tx, apiKey)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	stored, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	assert.Equal(t, apiKey, toUtcApiKey(stored))
+}
+
+func TestIT_ApiKeyRepository_Create_WhenPasswordChangeIsRequired_ExpectRestrictionIsStored(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
+	user := insertTestUser(t, conn)
+
+	apiKey := persistence.ApiKey{
+		Id:         uuid.New(),
+		KeyHash:    "my-key-hash-" + uuid.NewString(),
+		ApiUser:    user.Id,
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: time.Date(2024, 11, 12, 18, 32, 20, 0, time.UTC),
+
+		PasswordChangeRequired: true,
+	}
+
+	_, err := repo.Create(context.Background(), tx, apiKey)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	stored, err := repo.GetForKey(context.Background(), []string{apiKey.KeyHash})
+	require.Nil(t, err)
+	assert.Equal(t, apiKey, toUtcApiKey(stored))
+}
+
+func TestIT_ApiKeyRepository_Create_WhenUserAlreadyHasAKey_ExpectBothKeysExist(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
 	_, apiKey := insertTestApiKey(t, conn)
 
 	newKey := persistence.ApiKey{
 		Id:      uuid.New(),
-		Key:     uuid.New(),
+		KeyHash: "my-key-hash-" + uuid.NewString(),
 		ApiUser: apiKey.ApiUser,
 
+		CreatedAt:  time.Date(2024, 11, 12, 16, 34, 40, 0, time.UTC),
 		ValidUntil: time.Date(2024, 11, 12, 18, 34, 40, 0, time.UTC),
 	}
 
-	require.NotEqual(t, apiKey.Id, newKey.Id)
-	require.NotEqual(t, apiKey.Key, newKey.Key)
-
-	actual, err := repo.Create(context.Background(), newKey)
+	_, err := repo.Create(context.Background(), tx, newKey)
+	tx.Close(context.Background())
 
 	assert.Nil(t, err)
-	assert.Equal(t, apiKey.Id, actual.Id)
-	assert.Equal(t, apiKey.ApiUser, actual.ApiUser)
-	assert.Equal(t, apiKey.Key, actual.Key)
-	assertApiKeyDoesNotExist(t, conn, newKey.Id)
+	assertApiKeyExists(t, conn, apiKey.Id)
+	assertApiKeyExists(t, conn, newKey.Id)
 }
 
-func TestIT_ApiKeyRepository_Create_WhenDuplicateForUser_ExpectValidityExtended(t *testing.T) {
-	repo, conn := newTestApiKeyRepository(t)
+func TestIT_ApiKeyRepository_Create_WhenDuplicateKeyHash_ExpectFailure(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
 	_, apiKey := insertTestApiKey(t, conn)
 
 	newKey := persistence.ApiKey{
 		Id:      uuid.New(),
-		Key:     uuid.New(),
+		KeyHash: apiKey.KeyHash,
 		ApiUser: apiKey.ApiUser,
 
+		CreatedAt:  time.Date(2024, 11, 12, 16, 34, 40, 0, time.UTC),
 		ValidUntil: time.Date(2024, 11, 12, 18, 34, 40, 0, time.UTC),
 	}
 
-	actual, err := repo.Create(context.Background(), newKey)
-	require.Nil(t, err)
-
-	updated, err := repo.Get(context.Background(), apiKey.Id)
-	require.Nil(t, err)
+	_, err := repo.Create(context.Background(), tx, newKey)
+	tx.Close(context.Background())
 
-	assert.Nil(t, err)
-	assert.Equal(t, newKey.ValidUntil, actual.ValidUntil)
-	assert.Equal(t, newKey.ValidUntil, updated.ValidUntil.UTC())
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+	assertApiKeyDoesNotExist(t, conn, newKey.Id)
 }
 
 func TestIT_ApiKeyRepository_Get(t *testing.T) {
@@ -88,9 +142,7 @@ func TestIT_ApiKeyRepository_Get(t *testing.T) {
 	actual, err := repo.Get(context.Background(), apiKey.Id)
 	assert.Nil(t, err)
 
-	actualUtc := actual
-	actualUtc.ValidUntil = actual.ValidUntil.UTC()
-	assert.Equal(t, apiKey, actualUtc)
+	assert.Equal(t, apiKey, toUtcApiKey(actual))
 }
 
 func TestIT_ApiKeyRepository_Get_WhenNotFound_ExpectFailure(t *testing.T) {
@@ -107,60 +159,208 @@ func TestIT_ApiKeyRepository_GetForKey(t *testing.T) {
 
 	_, apiKey := insertTestApiKey(t, conn)
 
-	actual, err := repo.GetForKey(context.Background(), apiKey.Key)
+	actual, err := repo.GetForKey(context.Background(), []string{"not-a-key-hash", apiKey.KeyHash})
 	assert.Nil(t, err)
 
-	actualUtc := actual
-	actualUtc.ValidUntil = actual.ValidUntil.UTC()
-	assert.Equal(t, apiKey, actualUtc)
+	assert.Equal(t, apiKey, toUtcApiKey(actual))
 }
 
 func TestIT_ApiKeyRepository_GetForKey_WhenNotFound_ExpectFailure(t *testing.T) {
 	repo, _ := newTestApiKeyRepository(t)
 
-	// Non-existent id
-	id := uuid.MustParse("00000000-1111-2222-1111-000000000000")
-	_, err := repo.GetForKey(context.Background(), id)
+	_, err := repo.GetForKey(context.Background(), []string{"not-a-key-hash"})
 	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
 }
 
-func TestIT_ApiKeyRepository_GetForUser(t *testing.T) {
+func TestIT_ApiKeyRepository_ListForUser(t *testing.T) {
 	repo, conn := newTestApiKeyRepository(t)
 
-	_, apiKey := insertTestApiKey(t, conn)
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt.Add(-1*time.Hour))
 
-	actual, err := repo.GetForUser(context.Background(), apiKey.ApiUser)
+	actual, err := repo.ListForUser(context.Background(), user.Id)
 	assert.Nil(t, err)
 
-	actualUtc := actual
-	actualUtc.ValidUntil = actual.ValidUntil.UTC()
-	assert.Equal(t, apiKey, actualUtc)
+	assert.Equal(t, []persistence.ApiKey{apiKey2, apiKey1}, toUtcApiKeys(actual))
 }
 
-func TestIT_ApiKeyRepository_GetForUser_WhenNotFound_ExpectFailure(t *testing.T) {
-	repo, _ := newTestApiKeyRepository(t)
+func TestIT_ApiKeyRepository_ListForUser_WhenNoKeys_ExpectEmptyList(t *testing.T) {
+	repo, conn := newTestApiKeyRepository(t)
+
+	user := insertTestUser(t, conn)
+
+	actual, err := repo.ListForUser(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestIT_ApiKeyRepository_LockForUser(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt.Add(1*time.Hour))
+
+	actual, err := repo.LockForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, []persistence.ApiKey{apiKey1, apiKey2}, toUtcApiKeys(actual))
+}
+
+func TestIT_ApiKeyRepository_LockForUser_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _, tx := newTestApiKeyRepositoryAndTransaction(t)
 
 	// Non-existent id
 	id := uuid.MustParse("00000000-1111-2222-1111-000000000000")
-	_, err := repo.GetForUser(context.Background(), id)
+	_, err := repo.LockForUser(context.Background(), tx, id)
+	tx.Close(context.Background())
+
 	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
 }
 
+func TestIT_ApiKeyRepository_Touch(t *testing.T) {
+	repo, conn := newTestApiKeyRepository(t)
+
+	_, apiKey := insertTestApiKey(t, conn)
+	usedAt := time.Date(2024, 11, 12, 17, 10, 0, 0, time.UTC)
+
+	err := repo.Touch(context.Background(), apiKey.Id, usedAt, apiKey.ValidUntil)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	require.NotNil(t, actual.LastUsedAt)
+	assert.Equal(t, usedAt, actual.LastUsedAt.UTC())
+}
+
+func TestIT_ApiKeyRepository_Touch_WhenRecentlyUsed_ExpectDateIsKept(t *testing.T) {
+	repo, conn := newTestApiKeyRepository(t)
+
+	_, apiKey := insertTestApiKey(t, conn)
+	usedAt := time.Date(2024, 11, 12, 17, 10, 0, 0, time.UTC)
+	err := repo.Touch(context.Background(), apiKey.Id, usedAt, apiKey.ValidUntil)
+	require.Nil(t, err)
+
+	err = repo.Touch(context.Background(), apiKey.Id, usedAt.Add(30*time.Second), apiKey.ValidUntil.Add(time.Hour))
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	require.NotNil(t, actual.LastUsedAt)
+	assert.Equal(t, usedAt, actual.LastUsedAt.UTC())
+	assert.Equal(t, apiKey.ValidUntil, actual.ValidUntil.UTC())
+}
+
+func TestIT_ApiKeyRepository_Touch_WhenUsedLongAgo_ExpectDateIsRefreshed(t *testing.T) {
+	repo, conn := newTestApiKeyRepository(t)
+
+	_, apiKey := insertTestApiKey(t, conn)
+	usedAt := time.Date(2024, 11, 12, 17, 10, 0, 0, time.UTC)
+	err := repo.Touch(context.Background(), apiKey.Id, usedAt, apiKey.ValidUntil)
+	require.Nil(t, err)
+
+	err = repo.Touch(context.Background(), apiKey.Id, usedAt.Add(2*time.Minute), apiKey.ValidUntil.Add(time.Hour))
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	require.NotNil(t, actual.LastUsedAt)
+	assert.Equal(t, usedAt.Add(2*time.Minute), actual.LastUsedAt.UTC())
+	assert.Equal(t, apiKey.ValidUntil.Add(time.Hour), actual.ValidUntil.UTC())
+}
+
+func TestIT_ApiKeyRepository_Touch_WhenValidityIsShorter_ExpectValidityIsKept(t *testing.T) {
+	repo, conn := newTestApiKeyRepository(t)
+
+	_, apiKey := insertTestApiKey(t, conn)
+	usedAt := time.Date(2024, 11, 12, 17, 10, 0, 0, time.UTC)
+
+	err := repo.Touch(context.Background(), apiKey.Id, usedAt, apiKey.ValidUntil.Add(-time.Hour))
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	assert.Equal(t, apiKey.ValidUntil, actual.ValidUntil.UTC())
+}
+
+func TestIT_ApiKeyRepository_Rotate(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+	_, apiKey := insertTestApiKey(t, conn)
+	keyHash := "my-key-hash-" + uuid.NewString()
+	tokenId := uuid.New()
+	validUntil := apiKey.ValidUntil.Add(time.Hour)
+
+	err := repo.Rotate(context.Background(), tx, apiKey.Id, keyHash, &tokenId, validUntil)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	expected := apiKey
+	expected.KeyHash = keyHash
+	expected.TokenId = &tokenId
+	expected.ValidUntil = validUntil
+	assert.Equal(t, expected, toUtcApiKey(actual))
+}
+
+func TestIT_ApiKeyRepository_ClearPasswordChangeRequired(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+	_, apiKey := insertTestApiKey(t, conn)
+	_, err := conn.Exec(context.Background(), "UPDATE api_key SET password_change_required = true WHERE id = $1", apiKey.Id)
+	require.Nil(t, err)
+
+	err = repo.ClearPasswordChangeRequired(context.Background(), tx, apiKey.Id)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), apiKey.Id)
+	require.Nil(t, err)
+	assert.Equal(t, apiKey, toUtcApiKey(actual))
+}
+
 func TestIT_ApiKeyRepository_Delete(t *testing.T) {
 	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
 
-	user, apiKey := insertTestApiKey(t, conn)
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
 
-	err := repo.DeleteForUser(context.Background(), tx, user.Id)
+	err := repo.Delete(context.Background(), tx, apiKey1.Id)
 	tx.Close(context.Background())
 
 	assert.Nil(t, err)
-	assertApiKeyDoesNotExist(t, conn, apiKey.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
 }
 
 func TestIT_ApiKeyRepository_Delete_WhenNotFound_ExpectSuccess(t *testing.T) {
 	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
 
+	_, apiKey := insertTestApiKey(t, conn)
+
+	err := repo.Delete(context.Background(), tx, uuid.New())
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertApiKeyExists(t, conn, apiKey.Id)
+}
+
+func TestIT_ApiKeyRepository_DeleteForUser(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
+
+	err := repo.DeleteForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey2.Id)
+}
+
+func TestIT_ApiKeyRepository_DeleteForUser_WhenNotFound_ExpectSuccess(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
 	user, apiKey := insertTestApiKey(t, conn)
 	id := uuid.New()
 	require.NotEqual(t, user.Id, id)
@@ -172,6 +372,24 @@ func TestIT_ApiKeyRepository_Delete_WhenNotFound_ExpectSuccess(t *testing.T) {
 	assertApiKeyExists(t, conn, apiKey.Id)
 }
 
+func TestIT_ApiKeyRepository_DeleteForUserExcept(t *testing.T) {
+	repo, conn, tx := newTestApiKeyRepositoryAndTransaction(t)
+
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
+	apiKey3 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
+	_, otherApiKey := insertTestApiKey(t, conn)
+
+	err := repo.DeleteForUserExcept(context.Background(), tx, user.Id, apiKey2.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertApiKeyDoesNotExist(t, conn, apiKey1.Id)
+	assertApiKeyExists(t, conn, apiKey2.Id)
+	assertApiKeyDoesNotExist(t, conn, apiKey3.Id)
+	assertApiKeyExists(t, conn, otherApiKey.Id)
+}
+
 func newTestApiKeyRepository(t *testing.T) (ApiKeyRepository, db.Connection) {
 	conn := newTestConnection(t)
 	return NewApiKeyRepository(conn), conn
@@ -199,16 +417,40 @@ func assertApiKeyDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
 func insertTestApiKey(t *testing.T, conn db.Connection) (persistence.User, persistence.ApiKey) {
 	user := insertTestUser(t, conn)
 
-	someTime := time.Date(2024, 11, 12, 18, 49, 35, 0, time.UTC)
+	someTime := time.Date(2024, 11, 12, 16, 49, 35, 0, time.UTC)
+	apiKey := insertTestApiKeyForUser(t, conn, user.Id, someTime)
 
+	return user, apiKey
+}
+
+func insertTestApiKeyForUser(t *testing.T, conn db.Connection, user uuid.UUID, createdAt time.Time) persistence.ApiKey {
 	apiKey := persistence.ApiKey{
 		Id:         uuid.New(),
-		Key:        uuid.New(),
-		ApiUser:    user.Id,
-		ValidUntil: someTime,
+		KeyHash:    "my-key-hash-" + uuid.NewString(),
+		ApiUser:    user,
+		CreatedAt:  createdAt,
+		ValidUntil: createdAt.Add(2 * time.Hour),
 	}
-	_, err := conn.Exec(context.Background(), "INSERT INTO api_key (id, key, api_user, valid_until) VALUES ($1, $2, $3, $4)", apiKey.Id, apiKey.Key, apiKey.ApiUser, apiKey.ValidUntil)
+	_, err := conn.Exec(context.Background(), "INSERT INTO api_key (id, key_hash, api_user, created_at, valid_until) VALUES ($1, $2, $3, $4, $5)", apiKey.Id, apiKey.KeyHash, apiKey.ApiUser, apiKey.CreatedAt, apiKey.ValidUntil)
 	require.Nil(t, err)
 
-	return user, apiKey
+	return apiKey
+}
+
+func toUtcApiKey(apiKey persistence.ApiKey) persistence.ApiKey {
+	apiKey.CreatedAt = apiKey.CreatedAt.UTC()
+	if apiKey.LastUsedAt != nil {
+		lastUsedAt := apiKey.LastUsedAt.UTC()
+		apiKey.LastUsedAt = &lastUsedAt
+	}
+	apiKey.ValidUntil = apiKey.ValidUntil.UTC()
+	return apiKey
+}
+
+func toUtcApiKeys(apiKeys []persistence.ApiKey) []persistence.ApiKey {
+	var out []persistence.ApiKey
+	for _, apiKey := range apiKeys {
+		out = append(out, toUtcApiKey(apiKey))
+	}
+	return out
 }
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, tx db.Transaction, code persistence.AuthorizationCode) (persistence.AuthorizationCode, error)
+	Consume(ctx context.Context, tx db.Transaction, codeHashes []string) (persistence.AuthorizationCode, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type authorizationCodeRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewAuthorizationCodeRepository(conn db.Connection) AuthorizationCodeRepository {
+	return &authorizationCodeRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createAuthorizationCodeSqlTemplate = `
+INSERT INTO authorization_code (id, code_hash, client_id, api_user, redirect_uri, scope, nonce, code_challenge, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+func (r *authorizationCodeRepositoryImpl) Create(ctx context.Context, tx db.Transaction, code persistence.AuthorizationCode) (persistence.AuthorizationCode, error) {
+	_, err := tx.Exec(ctx, createAuthorizationCodeSqlTemplate, code.Id, code.CodeHash, code.ClientId, code.ApiUser, code.RedirectUri, code.Scope, code.Nonce, code.CodeChallenge, code.CreatedAt, code.ValidUntil)
+	return code, err
+}
+
+// Codes can only be used once: they are deleted as they are read, so that
+// concurrent attempts to use the same code can't both succeed.
+const consumeAuthorizationCodeSqlTemplate = `
+DELETE FROM
+	authorization_code
+WHERE
+	code_hash = ANY($1)
+RETURNING
+	id, code_hash, client_id, api_user, redirect_uri, scope, nonce, code_challenge, created_at, valid_until`
+
+func (r *authorizationCodeRepositoryImpl) Consume(ctx context.Context, tx db.Transaction, codeHashes []string) (persistence.AuthorizationCode, error) {
+	return db.QueryOneTx[persistence.AuthorizationCode](ctx, tx, consumeAuthorizationCodeSqlTemplate, codeHashes)
+}
+
+const deleteExpiredAuthorizationCodesSqlTemplate = `
+DELETE FROM
+	authorization_code
+WHERE
+	valid_until < $1`
+
+func (r *authorizationCodeRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredAuthorizationCodesSqlTemplate, at)
+	return err
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_AuthorizationCodeRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestAuthorizationCodeRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+	code := newTestAuthorizationCode(user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Create(context.Background(), tx, code)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, code, actual)
+	assertAuthorizationCodeExists(t, conn, code.Id)
+}
+
+func TestIT_AuthorizationCodeRepository_Create_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _, tx := newTestAuthorizationCodeRepositoryAndTransaction(t)
+	code := newTestAuthorizationCode(uuid.New(), time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := repo.Create(context.Background(), tx, code)
+	tx.Close(context.Background())
+
+	assert.NotNil(t, err)
+}
+
+func TestIT_AuthorizationCodeRepository_Consume_ExpectCodeIsDeleted(t *testing.T) {
+	repo, conn := newTestAuthorizationCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := insertTestAuthorizationCode(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Consume(context.Background(), tx, []string{"not-a-code-hash", code.CodeHash})
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, code, toUtcAuthorizationCode(actual))
+	assertAuthorizationCodeDoesNotExist(t, conn, code.Id)
+}
+
+func TestIT_AuthorizationCodeRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestAuthorizationCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := insertTestAuthorizationCode(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, []string{code.CodeHash})
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	tx, err = conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, []string{code.CodeHash})
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_AuthorizationCodeRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestAuthorizationCodeRepository(t)
+	user := insertTestUser(t, conn)
+	expired := insertTestAuthorizationCode(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestAuthorizationCode(t, conn, user.Id, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertAuthorizationCodeDoesNotExist(t, conn, expired.Id)
+	assertAuthorizationCodeExists(t, conn, valid.Id)
+}
+
+func TestIT_AuthorizationCodeRepository_WhenUserIsDeleted_ExpectCodesAreDeleted(t *testing.T) {
+	_, conn := newTestAuthorizationCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := insertTestAuthorizationCode(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertAuthorizationCodeDoesNotExist(t, conn, code.Id)
+}
+
+func newTestAuthorizationCodeRepository(t *testing.T) (AuthorizationCodeRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewAuthorizationCodeRepository(conn), conn
+}
+
+func newTestAuthorizationCodeRepositoryAndTransaction(t *testing.T) (AuthorizationCodeRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewAuthorizationCodeRepository(conn), conn, tx
+}
+
+func assertAuthorizationCodeExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM authorization_code WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertAuthorizationCodeDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM authorization_code WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestAuthorizationCode(user uuid.UUID, validUntil time.Time) persistence.AuthorizationCode {
+	return persistence.AuthorizationCode{
+		Id:            uuid.New(),
+		CodeHash:      "my-code-hash-" + uuid.NewString(),
+		ClientId:      "my-client",
+		ApiUser:       user,
+		RedirectUri:   "https://client.example.com/callback",
+		Scope:         "openid email",
+		Nonce:         "my-nonce",
+		CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CreatedAt:     time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil:    validUntil,
+	}
+}
+
+func insertTestAuthorizationCode(t *testing.T, conn db.Connection, user uuid.UUID, validUntil time.Time) persistence.AuthorizationCode {
+	code := newTestAuthorizationCode(user, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewAuthorizationCodeRepository(conn).Create(context.Background(), tx, code)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return code
+}
+
+func toUtcAuthorizationCode(code persistence.AuthorizationCode) persistence.AuthorizationCode {
+	code.CreatedAt = code.CreatedAt.UTC()
+	code.ValidUntil = code.ValidUntil.UTC()
+	return code
+}
@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type EmailLoginRepository interface {
+	Create(ctx context.Context, tx db.Transaction, login persistence.EmailLogin) (persistence.EmailLogin, error)
+	GetForToken(ctx context.Context, tokenHashes []string) (persistence.EmailLogin, error)
+	GetForUser(ctx context.Context, user uuid.UUID) (persistence.EmailLogin, error)
+	LockForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) (persistence.EmailLogin, error)
+	RecordFailure(ctx context.Context, id uuid.UUID) (persistence.EmailLogin, error)
+	Consume(ctx context.Context, id uuid.UUID) error
+	DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type emailLoginRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewEmailLoginRepository(conn db.Connection) EmailLoginRepository {
+	return &emailLoginRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createEmailLoginSqlTemplate = `
+INSERT INTO email_login (id, api_user, email, token_hash, code_hash, attempts, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+func (r *emailLoginRepositoryImpl) Create(ctx context.Context, tx db.Transaction, login persistence.EmailLogin) (persistence.EmailLogin, error) {
+	_, err := tx.Exec(
+		ctx,
+		createEmailLoginSqlTemplate,
+		login.Id,
+		login.ApiUser,
+		login.Email,
+		login.TokenHash,
+		login.CodeHash,
+		login.Attempts,
+		login.CreatedAt,
+		login.ValidUntil,
+	)
+	return login, err
+}
+
+const getEmailLoginForTokenSqlTemplate = `
+SELECT
+	id,
+	api_user,
+	email,
+	token_hash,
+	code_hash,
+	attempts,
+	created_at,
+	valid_until
+FROM
+	email_login
+WHERE
+	token_hash = ANY($1)`
+
+func (r *emailLoginRepositoryImpl) GetForToken(ctx context.Context, tokenHashes []string) (persistence.EmailLogin, error) {
+	return db.QueryOne[persistence.EmailLogin](ctx, r.conn, getEmailLoginForTokenSqlTemplate, tokenHashes)
+}
+
+// Only the most recent login of the user is returned: the previous ones are
+// deleted when a new one is created anyway.
+const getEmailLoginForUserSqlTemplate = `
+SELECT
+	id,
+	api_user,
+	email,
+	token_hash,
+	code_hash,
+	attempts,
+	created_at,
+	valid_until
+FROM
+	email_login
+WHERE
+	api_user = $1
+ORDER BY
+	created_at DESC
+LIMIT 1`
+
+func (r *emailLoginRepositoryImpl) GetForUser(ctx context.Context, user uuid.UUID) (persistence.EmailLogin, error) {
+	return db.QueryOne[persistence.EmailLogin](ctx, r.conn, getEmailLoginForUserSqlTemplate, user)
+}
+
+// The login is locked so that the wrong codes provided while a new one is
+// requested are not lost.
+const lockEmailLoginForUserSqlTemplate = `
+SELECT
+	id,
+	api_user,
+	email,
+	token_hash,
+	code_hash,
+	attempts,
+	created_at,
+	valid_until
+FROM
+	email_login
+WHERE
+	api_user = $1
+ORDER BY
+	created_at DESC
+LIMIT 1
+FOR UPDATE`
+
+func (r *emailLoginRepositoryImpl) LockForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) (persistence.EmailLogin, error) {
+	return db.QueryOneTx[persistence.EmailLogin](ctx, tx, lockEmailLoginForUserSqlTemplate, user)
+}
+
+// The counter is incremented in a single statement so that concurrent
+// attempts are all accounted for.
+const recordEmailLoginFailureSqlTemplate = `
+UPDATE
+	email_login
+SET
+	attempts = attempts + 1
+WHERE
+	id = $1
+RETURNING
+	id,
+	api_user,
+	email,
+	token_hash,
+	code_hash,
+	attempts,
+	created_at,
+	valid_until`
+
+func (r *emailLoginRepositoryImpl) RecordFailure(ctx context.Context, id uuid.UUID) (persistence.EmailLogin, error) {
+	return db.QueryOne[persistence.EmailLogin](ctx, r.conn, recordEmailLoginFailureSqlTemplate, id)
+}
+
+// Only one of the concurrent attempts to use the same login finds it.
+const consumeEmailLoginSqlTemplate = `
+DELETE FROM
+	email_login
+WHERE
+	id = $1
+RETURNING
+	id`
+
+func (r *emailLoginRepositoryImpl) Consume(ctx context.Context, id uuid.UUID) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, consumeEmailLoginSqlTemplate, id)
+	return err
+}
+
+const deleteEmailLoginsForUserSqlTemplate = `
+DELETE FROM
+	email_login
+WHERE
+	api_user = $1`
+
+func (r *emailLoginRepositoryImpl) DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error {
+	_, err := tx.Exec(ctx, deleteEmailLoginsForUserSqlTemplate, user)
+	return err
+}
+
+const deleteExpiredEmailLoginsSqlTemplate = `
+DELETE FROM
+	email_login
+WHERE
+	valid_until < $1`
+
+func (r *emailLoginRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredEmailLoginsSqlTemplate, at)
+	return err
+}
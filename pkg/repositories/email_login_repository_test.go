@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_EmailLoginRepository_Create(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := newTestEmailLogin(user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, login)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, login, actual)
+	assertEmailLoginExists(t, conn, login.Id)
+}
+
+func TestIT_EmailLoginRepository_GetForToken(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.GetForToken(context.Background(), []string{"not-a-token-hash", login.TokenHash})
+
+	assert.Nil(t, err)
+	assert.Equal(t, login, toUtcEmailLogin(actual))
+}
+
+func TestIT_EmailLoginRepository_GetForToken_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, _ := newTestEmailLoginRepository(t)
+
+	_, err := repo.GetForToken(context.Background(), []string{"not-a-token-hash"})
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginRepository_GetForUser(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	insertTestEmailLogin(t, conn, other, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.GetForUser(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, login, toUtcEmailLogin(actual))
+}
+
+func TestIT_EmailLoginRepository_GetForUser_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+
+	_, err := repo.GetForUser(context.Background(), user.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginRepository_LockForUser(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.LockForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, login, toUtcEmailLogin(actual))
+}
+
+func TestIT_EmailLoginRepository_LockForUser_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.LockForUser(context.Background(), tx, uuid.New())
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginRepository_RecordFailure(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := repo.RecordFailure(context.Background(), login.Id)
+	require.Nil(t, err)
+	actual, err := repo.RecordFailure(context.Background(), login.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Attempts)
+}
+
+func TestIT_EmailLoginRepository_Consume(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	err := repo.Consume(context.Background(), login.Id)
+
+	assert.Nil(t, err)
+	assertEmailLoginDoesNotExist(t, conn, login.Id)
+}
+
+func TestIT_EmailLoginRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	err := repo.Consume(context.Background(), login.Id)
+	require.Nil(t, err)
+
+	err = repo.Consume(context.Background(), login.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_EmailLoginRepository_DeleteForUser(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	login := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	otherLogin := insertTestEmailLogin(t, conn, other, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertEmailLoginDoesNotExist(t, conn, login.Id)
+	assertEmailLoginExists(t, conn, otherLogin.Id)
+}
+
+func TestIT_EmailLoginRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestEmailLoginRepository(t)
+	user := insertTestUser(t, conn)
+	expired := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestEmailLogin(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertEmailLoginDoesNotExist(t, conn, expired.Id)
+	assertEmailLoginExists(t, conn, valid.Id)
+}
+
+func newTestEmailLoginRepository(t *testing.T) (EmailLoginRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewEmailLoginRepository(conn), conn
+}
+
+func assertEmailLoginExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM email_login WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertEmailLoginDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM email_login WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestEmailLogin(user persistence.User, validUntil time.Time) persistence.EmailLogin {
+	return persistence.EmailLogin{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		CodeHash:   "my-code-hash",
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: validUntil,
+	}
+}
+
+func insertTestEmailLogin(t *testing.T, conn db.Connection, user persistence.User, validUntil time.Time) persistence.EmailLogin {
+	login := newTestEmailLogin(user, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewEmailLoginRepository(conn).Create(context.Background(), tx, login)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return login
+}
+
+func toUtcEmailLogin(login persistence.EmailLogin) persistence.EmailLogin {
+	login.CreatedAt = login.CreatedAt.UTC()
+	login.ValidUntil = login.ValidUntil.UTC()
+	return login
+}
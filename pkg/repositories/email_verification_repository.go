@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, tx db.Transaction, verification persistence.EmailVerification) (persistence.EmailVerification, error)
+	Consume(ctx context.Context, tokenHashes []string) (persistence.EmailVerification, error)
+	DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type emailVerificationRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewEmailVerificationRepository(conn db.Connection) EmailVerificationRepository {
+	return &emailVerificationRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createEmailVerificationSqlTemplate = `
+INSERT INTO email_verification (id, api_user, email, token_hash, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6)`
+
+func (r *emailVerificationRepositoryImpl) Create(ctx context.Context, tx db.Transaction, verification persistence.EmailVerification) (persistence.EmailVerification, error) {
+	_, err := tx.Exec(
+		ctx,
+		createEmailVerificationSqlTemplate,
+		verification.Id,
+		verification.ApiUser,
+		verification.Email,
+		verification.TokenHash,
+		verification.CreatedAt,
+		verification.ValidUntil,
+	)
+	return verification, err
+}
+
+// Each token is only used once, whatever the outcome: a new one has to be
+// requested after a failure.
+const consumeEmailVerificationSqlTemplate = `
+DELETE FROM
+	email_verification
+WHERE
+	token_hash = ANY($1)
+RETURNING
+	id, api_user, email, token_hash, created_at, valid_until`
+
+func (r *emailVerificationRepositoryImpl) Consume(ctx context.Context, tokenHashes []string) (persistence.EmailVerification, error) {
+	return db.QueryOne[persistence.EmailVerification](ctx, r.conn, consumeEmailVerificationSqlTemplate, tokenHashes)
+}
+
+const deleteEmailVerificationsForUserSqlTemplate = `
+DELETE FROM
+	email_verification
+WHERE
+	api_user = $1`
+
+func (r *emailVerificationRepositoryImpl) DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error {
+	_, err := tx.Exec(ctx, deleteEmailVerificationsForUserSqlTemplate, user)
+	return err
+}
+
+const deleteExpiredEmailVerificationsSqlTemplate = `
+DELETE FROM
+	email_verification
+WHERE
+	valid_until < $1`
+
+func (r *emailVerificationRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredEmailVerificationsSqlTemplate, at)
+	return err
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_EmailVerificationRepository_Create(t *testing.T) {
+	repo, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	verification := newTestEmailVerification(user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, verification)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, verification, actual)
+	assertEmailVerificationExists(t, conn, verification.Id)
+}
+
+func TestIT_EmailVerificationRepository_Consume_ExpectVerificationIsDeleted(t *testing.T) {
+	repo, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	verification := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Consume(context.Background(), []string{"not-a-token-hash", verification.TokenHash})
+
+	assert.Nil(t, err)
+	assert.Equal(t, verification, toUtcEmailVerification(actual))
+	assertEmailVerificationDoesNotExist(t, conn, verification.Id)
+}
+
+func TestIT_EmailVerificationRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	verification := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	_, err := repo.Consume(context.Background(), []string{verification.TokenHash})
+	require.Nil(t, err)
+
+	_, err = repo.Consume(context.Background(), []string{verification.TokenHash})
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_EmailVerificationRepository_DeleteForUser(t *testing.T) {
+	repo, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	verification := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	otherVerification := insertTestEmailVerification(t, conn, other, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertEmailVerificationDoesNotExist(t, conn, verification.Id)
+	assertEmailVerificationExists(t, conn, otherVerification.Id)
+}
+
+func TestIT_EmailVerificationRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	expired := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertEmailVerificationDoesNotExist(t, conn, expired.Id)
+	assertEmailVerificationExists(t, conn, valid.Id)
+}
+
+func TestIT_EmailVerificationRepository_WhenUserIsDeleted_ExpectVerificationsAreDeleted(t *testing.T) {
+	_, conn := newTestEmailVerificationRepository(t)
+	user := insertTestUser(t, conn)
+	verification := insertTestEmailVerification(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertEmailVerificationDoesNotExist(t, conn, verification.Id)
+}
+
+func newTestEmailVerificationRepository(t *testing.T) (EmailVerificationRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewEmailVerificationRepository(conn), conn
+}
+
+func assertEmailVerificationExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM email_verification WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertEmailVerificationDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM email_verification WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestEmailVerification(user persistence.User, validUntil time.Time) persistence.EmailVerification {
+	return persistence.EmailVerification{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: validUntil,
+	}
+}
+
+func insertTestEmailVerification(t *testing.T, conn db.Connection, user persistence.User, validUntil time.Time) persistence.EmailVerification {
+	verification := newTestEmailVerification(user, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewEmailVerificationRepository(conn).Create(context.Background(), tx, verification)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return verification
+}
+
+func toUtcEmailVerification(verification persistence.EmailVerification) persistence.EmailVerification {
+	verification.CreatedAt = verification.CreatedAt.UTC()
+	verification.ValidUntil = verification.ValidUntil.UTC()
+	return verification
+}
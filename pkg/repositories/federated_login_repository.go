@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type FederatedLoginRepository interface {
+	Create(ctx context.Context, tx db.Transaction, login persistence.FederatedLogin) (persistence.FederatedLogin, error)
+	Consume(ctx context.Context, tx db.Transaction, stateHashes []string) (persistence.FederatedLogin, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type federatedLoginRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewFederatedLoginRepository(conn db.Connection) FederatedLoginRepository {
+	return &federatedLoginRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createFederatedLoginSqlTemplate = `
+INSERT INTO federated_login (id, state_hash, provider, nonce, code_verifier, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6, $7)`
+
+func (r *federatedLoginRepositoryImpl) Create(ctx context.Context, tx db.Transaction, login persistence.FederatedLogin) (persistence.FederatedLogin, error) {
+	_, err := tx.Exec(ctx, createFederatedLoginSqlTemplate, login.Id, login.StateHash, login.Provider, login.Nonce, login.CodeVerifier, login.CreatedAt, login.ValidUntil)
+	return login, err
+}
+
+// As authorization codes, logins can only be completed once.
+const consumeFederatedLoginSqlTemplate = `
+DELETE FROM
+	federated_login
+WHERE
+	state_hash = ANY($1)
+RETURNING
+	id, state_hash, provider, nonce, code_verifier, created_at, valid_until`
+
+func (r *federatedLoginRepositoryImpl) Consume(ctx context.Context, tx db.Transaction, stateHashes []string) (persistence.FederatedLogin, error) {
+	return db.QueryOneTx[persistence.FederatedLogin](ctx, tx, consumeFederatedLoginSqlTemplate, stateHashes)
+}
+
+const deleteExpiredFederatedLoginsSqlTemplate = `
+DELETE FROM
+	federated_login
+WHERE
+	valid_until < $1`
+
+func (r *federatedLoginRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredFederatedLoginsSqlTemplate, at)
+	return err
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_FederatedLoginRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestFederatedLoginRepositoryAndTransaction(t)
+	login := newTestFederatedLogin(time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Create(context.Background(), tx, login)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, login, actual)
+	assertFederatedLoginExists(t, conn, login.Id)
+}
+
+func TestIT_FederatedLoginRepository_Consume_ExpectLoginIsDeleted(t *testing.T) {
+	repo, conn := newTestFederatedLoginRepository(t)
+	login := insertTestFederatedLogin(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Consume(context.Background(), tx, []string{"not-a-state-hash", login.StateHash})
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, login, toUtcFederatedLogin(actual))
+	assertFederatedLoginDoesNotExist(t, conn, login.Id)
+}
+
+func TestIT_FederatedLoginRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestFederatedLoginRepository(t)
+	login := insertTestFederatedLogin(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, []string{login.StateHash})
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	tx, err = conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, []string{login.StateHash})
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_FederatedLoginRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestFederatedLoginRepository(t)
+	expired := insertTestFederatedLogin(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestFederatedLogin(t, conn, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertFederatedLoginDoesNotExist(t, conn, expired.Id)
+	assertFederatedLoginExists(t, conn, valid.Id)
+}
+
+func newTestFederatedLoginRepository(t *testing.T) (FederatedLoginRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewFederatedLoginRepository(conn), conn
+}
+
+func newTestFederatedLoginRepositoryAndTransaction(t *testing.T) (FederatedLoginRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewFederatedLoginRepository(conn), conn, tx
+}
+
+func assertFederatedLoginExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM federated_login WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertFederatedLoginDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM federated_login WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestFederatedLogin(validUntil time.Time) persistence.FederatedLogin {
+	return persistence.FederatedLogin{
+		Id:           uuid.New(),
+		StateHash:    "my-state-hash-" + uuid.NewString(),
+		Provider:     "my-provider",
+		Nonce:        "my-nonce",
+		CodeVerifier: "my-code-verifier",
+		CreatedAt:    time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil:   validUntil,
+	}
+}
+
+func insertTestFederatedLogin(t *testing.T, conn db.Connection, validUntil time.Time) persistence.FederatedLogin {
+	login := newTestFederatedLogin(validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewFederatedLoginRepository(conn).Create(context.Background(), tx, login)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return login
+}
+
+func toUtcFederatedLogin(login persistence.FederatedLogin) persistence.FederatedLogin {
+	login.CreatedAt = login.CreatedAt.UTC()
+	login.ValidUntil = login.ValidUntil.UTC()
+	return login
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type IdentityRepository interface {
+	Create(ctx context.Context, tx db.Transaction, identity persistence.Identity) (persistence.Identity, error)
+	GetBySubject(ctx context.Context, provider string, subject string) (persistence.Identity, error)
+	UpdateLastLogin(ctx context.Context, id uuid.UUID, email string, at time.Time) error
+}
+
+type identityRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewIdentityRepository(conn db.Connection) IdentityRepository {
+	return &identityRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createIdentitySqlTemplate = `
+INSERT INTO identity (id, api_user, provider, subject, email, created_at, last_login_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7)`
+
+func (r *identityRepositoryImpl) Create(ctx context.Context, tx db.Transaction, identity persistence.Identity) (persistence.Identity, error) {
+	_, err := tx.Exec(ctx, createIdentitySqlTemplate, identity.Id, identity.ApiUser, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt, identity.LastLoginAt)
+	return identity, err
+}
+
+const getIdentityBySubjectSqlTemplate = `
+SELECT
+	id, api_user, provider, subject, email, created_at, last_login_at
+FROM
+	identity
+WHERE
+	provider = $1
+	AND subject = $2`
+
+func (r *identityRepositoryImpl) GetBySubject(ctx context.Context, provider string, subject string) (persistence.Identity, error) {
+	return db.QueryOne[persistence.Identity](ctx, r.conn, getIdentityBySubjectSqlTemplate, provider, subject)
+}
+
+const updateIdentityLastLoginSqlTemplate = `
+UPDATE
+	identity
+SET
+	email = $1,
+	last_login_at = $2
+WHERE
+	id = $3`
+
+func (r *identityRepositoryImpl) UpdateLastLogin(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	_, err := r.conn.Exec(ctx, updateIdentityLastLoginSqlTemplate, email, at, id)
+	return err
+}
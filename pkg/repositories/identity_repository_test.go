@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_IdentityRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestIdentityRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+	identity := newTestIdentity(user.Id)
+
+	actual, err := repo.Create(context.Background(), tx, identity)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, identity, actual)
+	assertIdentityExists(t, conn, identity.Id)
+}
+
+func TestIT_IdentityRepository_Create_WhenSubjectIsAlreadyLinked_ExpectFailure(t *testing.T) {
+	repo, conn := newTestIdentityRepository(t)
+	user := insertTestUser(t, conn)
+	identity := insertTestIdentity(t, conn, user.Id)
+	otherUser := insertTestUser(t, conn)
+	duplicate := newTestIdentity(otherUser.Id)
+	duplicate.Subject = identity.Subject
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, duplicate)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_IdentityRepository_GetBySubject(t *testing.T) {
+	repo, conn := newTestIdentityRepository(t)
+	user := insertTestUser(t, conn)
+	identity := insertTestIdentity(t, conn, user.Id)
+
+	actual, err := repo.GetBySubject(context.Background(), identity.Provider, identity.Subject)
+
+	assert.Nil(t, err)
+	assert.Equal(t, identity, toUtcIdentity(actual))
+}
+
+func TestIT_IdentityRepository_GetBySubject_WhenProviderDiffers_ExpectNotFound(t *testing.T) {
+	repo, conn := newTestIdentityRepository(t)
+	user := insertTestUser(t, conn)
+	identity := insertTestIdentity(t, conn, user.Id)
+
+	_, err := repo.GetBySubject(context.Background(), "another-provider", identity.Subject)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_IdentityRepository_UpdateLastLogin(t *testing.T) {
+	repo, conn := newTestIdentityRepository(t)
+	user := insertTestUser(t, conn)
+	identity := insertTestIdentity(t, conn, user.Id)
+	at := time.Date(2024, 11, 13, 9, 10, 11, 0, time.UTC)
+
+	err := repo.UpdateLastLogin(context.Background(), identity.Id, "new-email@example.com", at)
+	assert.Nil(t, err)
+
+	actual, err := repo.GetBySubject(context.Background(), identity.Provider, identity.Subject)
+	require.Nil(t, err)
+	assert.Equal(t, "new-email@example.com", actual.Email)
+	assert.Equal(t, at, actual.LastLoginAt.UTC())
+}
+
+func TestIT_IdentityRepository_WhenUserIsDeleted_ExpectIdentitiesAreDeleted(t *testing.T) {
+	_, conn := newTestIdentityRepository(t)
+	user := insertTestUser(t, conn)
+	identity := insertTestIdentity(t, conn, user.Id)
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertIdentityDoesNotExist(t, conn, identity.Id)
+}
+
+func newTestIdentityRepository(t *testing.T) (IdentityRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewIdentityRepository(conn), conn
+}
+
+func newTestIdentityRepositoryAndTransaction(t *testing.T) (IdentityRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewIdentityRepository(conn), conn, tx
+}
+
+func assertIdentityExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM identity WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertIdentityDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM identity WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestIdentity(user uuid.UUID) persistence.Identity {
+	return persistence.Identity{
+		Id:          uuid.New(),
+		ApiUser:     user,
+		Provider:    "my-provider",
+		Subject:     "my-subject-" + uuid.NewString(),
+		Email:       "my-email@example.com",
+		CreatedAt:   time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		LastLoginAt: time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+	}
+}
+
+func insertTestIdentity(t *testing.T, conn db.Connection, user uuid.UUID) persistence.Identity {
+	identity := newTestIdentity(user)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewIdentityRepository(conn).Create(context.Background(), tx, identity)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return identity
+}
+
+func toUtcIdentity(identity persistence.Identity) persistence.Identity {
+	identity.CreatedAt = identity.CreatedAt.UTC()
+	identity.LastLoginAt = identity.LastLoginAt.UTC()
+	return identity
+}
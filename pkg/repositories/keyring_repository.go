@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type KeyringRepository interface {
+	Create(ctx context.Context, tx db.Transaction, key persistence.KeyringKey) (persistence.KeyringKey, error)
+	ListForPurpose(ctx context.Context, purpose string) ([]persistence.KeyringKey, error)
+	LockForPurpose(ctx context.Context, tx db.Transaction, purpose string) ([]persistence.KeyringKey, error)
+	Activate(ctx context.Context, tx db.Transaction, id string, at time.Time) error
+	Retire(ctx context.Context, tx db.Transaction, id string, at time.Time) error
+	Delete(ctx context.Context, tx db.Transaction, id string) error
+}
+
+type keyringRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewKeyringRepository(conn db.Connection) KeyringRepository {
+	return &keyringRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createKeyringKeySqlTemplate = `
+INSERT INTO keyring_key (id, purpose, algorithm, material, state, created_at, activated_at, retired_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8)`
+
+func (r *keyringRepositoryImpl) Create(ctx context.Context, tx db.Transaction, key persistence.KeyringKey) (persistence.KeyringKey, error) {
+	_, err := tx.Exec(ctx, createKeyringKeySqlTemplate, key.Id, key.Purpose, key.Algorithm, key.Material, key.State, key.CreatedAt, key.ActivatedAt, key.RetiredAt)
+	return key, err
+}
+
+const listKeyringKeyForPurposeSqlTemplate = `
+SELECT
+	id, purpose, algorithm, material, state, created_at, activated_at, retired_at
+FROM
+	keyring_key
+WHERE
+	purpose = $1
+ORDER BY
+	created_at`
+
+func (r *keyringRepositoryImpl) ListForPurpose(ctx context.Context, purpose string) ([]persistence.KeyringKey, error) {
+	return db.QueryAll[persistence.KeyringKey](ctx, r.conn, listKeyringKeyForPurposeSqlTemplate, purpose)
+}
+
+// The lock is held until the end of the transaction. Unlike locking rows, it
+// also works when there are no keys yet.
+const lockKeyringPurposeSqlTemplate = `SELECT pg_advisory_xact_lock(hashtext('keyring_key/' || $1))`
+
+// LockForPurpose returns the keys of the purpose and prevents concurrent
+// transactions from modifying them until the input transaction is over.
+func (r *keyringRepositoryImpl) LockForPurpose(ctx context.Context, tx db.Transaction, purpose string) ([]persistence.KeyringKey, error) {
+	_, err := tx.Exec(ctx, lockKeyringPurposeSqlTemplate, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.QueryAllTx[persistence.KeyringKey](ctx, tx, listKeyringKeyForPurposeSqlTemplate, purpose)
+}
+
+const activateKeyringKeySqlTemplate = `
+UPDATE
+	keyring_key
+SET
+	state = 'active',
+	activated_at = $2
+WHERE
+	id = $1`
+
+func (r *keyringRepositoryImpl) Activate(ctx context.Context, tx db.Transaction, id string, at time.Time) error {
+	_, err := tx.Exec(ctx, activateKeyringKeySqlTemplate, id, at)
+	return err
+}
+
+const retireKeyringKeySqlTemplate = `
+UPDATE
+	keyring_key
+SET
+	state = 'retired',
+	retired_at = $2
+WHERE
+	id = $1`
+
+func (r *keyringRepositoryImpl) Retire(ctx context.Context, tx db.Transaction, id string, at time.Time) error {
+	_, err := tx.Exec(ctx, retireKeyringKeySqlTemplate, id, at)
+	return err
+}
+
+const deleteKeyringKeySqlTemplate = `
+DELETE FROM
+	keyring_key
+WHERE
+	id = $1`
+
+func (r *keyringRepositoryImpl) Delete(ctx context.Context, tx db.Transaction, id string) error {
+	_, err := tx.Exec(ctx, deleteKeyringKeySqlTemplate, id)
+	return err
+}
@@ -0,0 +1,146 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_KeyringRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestKeyringRepositoryAndTransaction(t)
+	key := newTestKeyringKey("purpose-"+uuid.NewString(), "pending")
+
+	actual, err := repo.Create(context.Background(), tx, key)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, key, actual)
+	assertKeyringKeyExists(t, conn, key.Id)
+}
+
+func TestIT_KeyringRepository_Create_WhenPurposeAlreadyHasActiveKey_ExpectFailure(t *testing.T) {
+	repo, conn, tx := newTestKeyringRepositoryAndTransaction(t)
+	purpose := "purpose-" + uuid.NewString()
+	insertTestKeyringKey(t, conn, newTestKeyringKey(purpose, "active"))
+
+	_, err := repo.Create(context.Background(), tx, newTestKeyringKey(purpose, "active"))
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_KeyringRepository_ListForPurpose(t *testing.T) {
+	repo, conn := newTestKeyringRepository(t)
+	purpose := "purpose-" + uuid.NewString()
+	key1 := insertTestKeyringKey(t, conn, newTestKeyringKey(purpose, "active"))
+	key2 := newTestKeyringKey(purpose, "pending")
+	key2.CreatedAt = key1.CreatedAt.Add(time.Minute)
+	insertTestKeyringKey(t, conn, key2)
+	insertTestKeyringKey(t, conn, newTestKeyringKey("purpose-"+uuid.NewString(), "active"))
+
+	actual, err := repo.ListForPurpose(context.Background(), purpose)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 2)
+	assert.Equal(t, key1.Id, actual[0].Id)
+	assert.Equal(t, key1.Material, actual[0].Material)
+	assert.Equal(t, key2.Id, actual[1].Id)
+}
+
+func TestIT_KeyringRepository_LockForPurpose(t *testing.T) {
+	repo, conn, tx := newTestKeyringRepositoryAndTransaction(t)
+	purpose := "purpose-" + uuid.NewString()
+	key := insertTestKeyringKey(t, conn, newTestKeyringKey(purpose, "active"))
+
+	actual, err := repo.LockForPurpose(context.Background(), tx, purpose)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, key.Id, actual[0].Id)
+}
+
+func TestIT_KeyringRepository_ActivateAndRetire(t *testing.T) {
+	repo, conn, tx := newTestKeyringRepositoryAndTransaction(t)
+	purpose := "purpose-" + uuid.NewString()
+	active := insertTestKeyringKey(t, conn, newTestKeyringKey(purpose, "active"))
+	pending := insertTestKeyringKey(t, conn, newTestKeyringKey(purpose, "pending"))
+	now := time.Date(2024, 11, 12, 17, 10, 0, 0, time.UTC)
+
+	err := repo.Retire(context.Background(), tx, active.Id, now)
+	require.Nil(t, err)
+	err = repo.Activate(context.Background(), tx, pending.Id, now)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.ListForPurpose(context.Background(), purpose)
+	require.Nil(t, err)
+	require.Len(t, actual, 2)
+	byId := map[string]persistence.KeyringKey{actual[0].Id: actual[0], actual[1].Id: actual[1]}
+	assert.Equal(t, "retired", byId[active.Id].State)
+	require.NotNil(t, byId[active.Id].RetiredAt)
+	assert.Equal(t, now, byId[active.Id].RetiredAt.UTC())
+	assert.Equal(t, "active", byId[pending.Id].State)
+	require.NotNil(t, byId[pending.Id].ActivatedAt)
+	assert.Equal(t, now, byId[pending.Id].ActivatedAt.UTC())
+}
+
+func TestIT_KeyringRepository_Delete(t *testing.T) {
+	repo, conn, tx := newTestKeyringRepositoryAndTransaction(t)
+	key := insertTestKeyringKey(t, conn, newTestKeyringKey("purpose-"+uuid.NewString(), "retired"))
+
+	err := repo.Delete(context.Background(), tx, key.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertKeyringKeyDoesNotExist(t, conn, key.Id)
+}
+
+func newTestKeyringRepository(t *testing.T) (KeyringRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewKeyringRepository(conn), conn
+}
+
+func newTestKeyringRepositoryAndTransaction(t *testing.T) (KeyringRepository, db.Connection, db.Transaction) {
+	repo, conn := newTestKeyringRepository(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return repo, conn, tx
+}
+
+func newTestKeyringKey(purpose string, state string) persistence.KeyringKey {
+	return persistence.KeyringKey{
+		Id:        uuid.NewString(),
+		Purpose:   purpose,
+		Algorithm: "HS256",
+		Material:  []byte("my-encrypted-material"),
+		State:     state,
+		CreatedAt: time.Date(2024, 11, 12, 16, 49, 35, 0, time.UTC),
+	}
+}
+
+func insertTestKeyringKey(t *testing.T, conn db.Connection, key persistence.KeyringKey) persistence.KeyringKey {
+	_, err := conn.Exec(context.Background(), "INSERT INTO keyring_key (id, purpose, algorithm, material, state, created_at) VALUES ($1, $2, $3, $4, $5, $6)", key.Id, key.Purpose, key.Algorithm, key.Material, key.State, key.CreatedAt)
+	require.Nil(t, err)
+	return key
+}
+
+func assertKeyringKeyExists(t *testing.T, conn db.Connection, id string) {
+	value, err := db.QueryOne[string](context.Background(), conn, "SELECT id FROM keyring_key WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertKeyringKeyDoesNotExist(t *testing.T, conn db.Connection, id string) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM keyring_key WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
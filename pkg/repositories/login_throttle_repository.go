@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type LoginThrottleRepository interface {
+	Get(ctx context.Context, kind persistence.LoginThrottleKind, key string) (persistence.LoginThrottle, error)
+	RecordFailure(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time, resetBefore time.Time) (persistence.LoginThrottle, error)
+	RecordAttempt(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time, resetBefore time.Time, backOff persistence.LoginBackOff) (persistence.LoginThrottle, error)
+	ForgetAttempt(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time) error
+	Delete(ctx context.Context, kind persistence.LoginThrottleKind, key string) error
+	ListLockedAccounts(ctx context.Context, at time.Time) ([]persistence.AccountLockout, error)
+}
+
+type loginThrottleRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewLoginThrottleRepository(conn db.Connection) LoginThrottleRepository {
+	return &loginThrottleRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const getLoginThrottleSqlTemplate = `
+SELECT
+	kind, key, failures, last_failure_at, blocked_until, locked
+FROM
+	login_throttle
+WHERE
+	kind = $1
+	AND key = $2`
+
+func (r *loginThrottleRepositoryImpl) Get(ctx context.Context, kind persistence.LoginThrottleKind, key string) (persistence.LoginThrottle, error) {
+	return db.QueryOne[persistence.LoginThrottle](ctx, r.conn, getLoginThrottleSqlTemplate, kind, key)
+}
+
+// The counter is incremented in a single statement so that concurrent
+// attempts handled by different replicas are all accounted for. Failures
+// older than the reset threshold are forgotten.
+const recordLoginFailureSqlTemplate = `
+INSERT INTO login_throttle (kind, key, failures, last_failure_at, blocked_until)
+	VALUES($1, $2, 1, $3, $3)
+	ON CONFLICT (kind, key) DO UPDATE
+	SET
+		failures = CASE
+			WHEN login_throttle.last_failure_at < $4 THEN 1
+			ELSE login_throttle.failures + 1
+		END,
+		locked = login_throttle.locked AND login_throttle.last_failure_at >= $4,
+		last_failure_at = excluded.last_failure_at
+	RETURNING
+		kind, key, failures, last_failure_at, blocked_until, locked`
+
+func (r *loginThrottleRepositoryImpl) RecordFailure(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time, resetBefore time.Time) (persistence.LoginThrottle, error) {
+	return db.QueryOne[persistence.LoginThrottle](ctx, r.conn, recordLoginFailureSqlTemplate, kind, key, at, resetBefore)
+}
+
+// The failures after the attempt: the previous ones are forgotten when they
+// are older than the reset threshold.
+const loginAttemptFailures = `CASE
+			WHEN login_throttle.last_failure_at < $4 THEN 1
+			ELSE login_throttle.failures + 1
+		END`
+
+// The attempt is counted as a failure and the key blocked for the matching
+// delay in the same statement as the check that it is not blocked already:
+// concurrent attempts handled by different replicas can't get through before
+// the block is recorded. Nothing is returned when the key is blocked.
+const recordLoginAttemptSqlTemplate = `
+INSERT INTO login_throttle (kind, key, failures, last_failure_at, blocked_until, locked)
+	VALUES(
+		$1,
+		$2,
+		1,
+		$3,
+		CASE
+			WHEN $6 = 1 THEN $3 + $7::bigint * interval '1 microsecond'
+			ELSE $3 + ($5::bigint[])[1] * interval '1 microsecond'
+		END,
+		$6 = 1
+	)
+	ON CONFLICT (kind, key) DO UPDATE
+	SET
+		failures = ` + loginAttemptFailures + `,
+		blocked_until = CASE
+			WHEN $6 > 0 AND ` + loginAttemptFailures + ` >= $6 THEN $3 + $7::bigint * interval '1 microsecond'
+			ELSE $3 + ($5::bigint[])[LEAST(` + loginAttemptFailures + `, cardinality($5::bigint[]))] * interval '1 microsecond'
+		END,
+		locked = ($6 > 0 AND ` + loginAttemptFailures + ` >= $6) OR (login_throttle.locked AND login_throttle.last_failure_at >= $4),
+		last_failure_at = excluded.last_failure_at
+	WHERE
+		login_throttle.blocked_until <= $3
+	RETURNING
+		kind, key, failures, last_failure_at, blocked_until, locked`
+
+func (r *loginThrottleRepositoryImpl) RecordAttempt(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time, resetBefore time.Time, backOff persistence.LoginBackOff) (persistence.LoginThrottle, error) {
+	delays := make([]int64, 0, len(backOff.Delays))
+	for _, delay := range backOff.Delays {
+		delays = append(delays, delay.Microseconds())
+	}
+	if len(delays) == 0 {
+		delays = append(delays, 0)
+	}
+
+	return db.QueryOne[persistence.LoginThrottle](
+		ctx,
+		r.conn,
+		recordLoginAttemptSqlTemplate,
+		kind,
+		key,
+		at,
+		resetBefore,
+		delays,
+		backOff.LockAfter,
+		backOff.LockFor.Microseconds(),
+	)
+}
+
+// The attempt is only forgotten when no other one was made since: the block
+// it set is lifted, the previous one had expired for it to be allowed.
+const forgetLoginAttemptSqlTemplate = `
+UPDATE
+	login_throttle
+SET
+	failures = failures - 1,
+	blocked_until = last_failure_at
+WHERE
+	kind = $1
+	AND key = $2
+	AND last_failure_at = $3`
+
+func (r *loginThrottleRepositoryImpl) ForgetAttempt(ctx context.Context, kind persistence.LoginThrottleKind, key string, at time.Time) error {
+	_, err := r.conn.Exec(ctx, forgetLoginAttemptSqlTemplate, kind, key, at)
+	return err
+}
+
+const deleteLoginThrottleSqlTemplate = `
+DELETE FROM
+	login_throttle
+WHERE
+	kind = $1
+	AND key = $2`
+
+func (r *loginThrottleRepositoryImpl) Delete(ctx context.Context, kind persistence.LoginThrottleKind, key string) error {
+	_, err := r.conn.Exec(ctx, deleteLoginThrottleSqlTemplate, kind, key)
+	return err
+}
+
+const listLockedAccountsSqlTemplate = `
+SELECT
+	api_user.id AS api_user,
+	api_user.email,
+	login_throttle.failures,
+	login_throttle.blocked_until AS locked_until
+FROM
+	login_throttle
+	JOIN api_user ON lower(api_user.email) = login_throttle.key
+WHERE
+	login_throttle.kind = $1
+	AND login_throttle.locked
+	AND login_throttle.blocked_until > $2
+ORDER BY
+	login_throttle.blocked_until`
+
+func (r *loginThrottleRepositoryImpl) ListLockedAccounts(ctx context.Context, at time.Time) ([]persistence.AccountLockout, error) {
+	return db.QueryAll[persistence.AccountLockout](ctx, r.conn, listLockedAccountsSqlTemplate, persistence.EmailLoginThrottle, at)
+}
@@ -0,0 +1,236 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_LoginThrottleRepository_RecordFailure_WhenFirstFailure_ExpectCreated(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+
+	actual, err := repo.RecordFailure(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour))
+
+	assert.Nil(t, err)
+	assert.Equal(t, persistence.IpLoginThrottle, actual.Kind)
+	assert.Equal(t, key, actual.Key)
+	assert.Equal(t, 1, actual.Failures)
+	assert.WithinDuration(t, now, actual.LastFailureAt, time.Millisecond)
+	assert.WithinDuration(t, now, actual.BlockedUntil, time.Millisecond)
+	assert.False(t, actual.Locked)
+}
+
+func TestIT_LoginThrottleRepository_RecordFailure_WhenRecentFailure_ExpectIncremented(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	_, err := repo.RecordFailure(context.Background(), persistence.IpLoginThrottle, key, now.Add(-time.Minute), now.Add(-time.Hour))
+	require.Nil(t, err)
+
+	actual, err := repo.RecordFailure(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Failures)
+	assert.WithinDuration(t, now, actual.LastFailureAt, time.Millisecond)
+}
+
+func TestIT_LoginThrottleRepository_RecordFailure_WhenOldFailure_ExpectReset(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	lock := persistence.LoginBackOff{Delays: []time.Duration{0}, LockAfter: 1, LockFor: time.Hour}
+	_, err := repo.RecordAttempt(context.Background(), persistence.EmailLoginThrottle, key, now.Add(-3*time.Hour), now.Add(-4*time.Hour), lock)
+	require.Nil(t, err)
+
+	actual, err := repo.RecordFailure(context.Background(), persistence.EmailLoginThrottle, key, now, now.Add(-time.Hour))
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, actual.Failures)
+	assert.False(t, actual.Locked)
+}
+
+func TestIT_LoginThrottleRepository_RecordAttempt_WhenFirstAttempt_ExpectCreated(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+
+	actual, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+
+	assert.Nil(t, err)
+	assert.Equal(t, persistence.IpLoginThrottle, actual.Kind)
+	assert.Equal(t, key, actual.Key)
+	assert.Equal(t, 1, actual.Failures)
+	assert.WithinDuration(t, now, actual.LastFailureAt, time.Millisecond)
+	assert.WithinDuration(t, now, actual.BlockedUntil, time.Millisecond)
+	assert.False(t, actual.Locked)
+}
+
+func TestIT_LoginThrottleRepository_RecordAttempt_WhenFreeAttemptsAreExhausted_ExpectBlocked(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	_, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(-time.Minute), now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+
+	actual, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Failures)
+	assert.WithinDuration(t, now.Add(time.Second), actual.BlockedUntil, time.Millisecond)
+	assert.False(t, actual.Locked)
+}
+
+func TestIT_LoginThrottleRepository_RecordAttempt_WhenDelaysAreExhausted_ExpectLastDelay(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	for i := range 3 {
+		_, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(time.Duration(i-3)*time.Minute), now.Add(-time.Hour), testLoginBackOff)
+		require.Nil(t, err)
+	}
+
+	actual, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, actual.Failures)
+	assert.WithinDuration(t, now.Add(2*time.Second), actual.BlockedUntil, time.Millisecond)
+}
+
+func TestIT_LoginThrottleRepository_RecordAttempt_WhenThresholdIsReached_ExpectLocked(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	backOff := testLoginBackOff
+	backOff.LockAfter = 2
+	_, err := repo.RecordAttempt(context.Background(), persistence.EmailLoginThrottle, key, now.Add(-time.Minute), now.Add(-time.Hour), backOff)
+	require.Nil(t, err)
+
+	actual, err := repo.RecordAttempt(context.Background(), persistence.EmailLoginThrottle, key, now, now.Add(-time.Hour), backOff)
+
+	assert.Nil(t, err)
+	assert.WithinDuration(t, now.Add(backOff.LockFor), actual.BlockedUntil, time.Millisecond)
+	assert.True(t, actual.Locked)
+}
+
+func TestIT_LoginThrottleRepository_RecordAttempt_WhenBlocked_ExpectFailure(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	_, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(-2*time.Second), now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+	_, err = repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(-500*time.Millisecond), now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+
+	_, err = repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	actual, err := repo.Get(context.Background(), persistence.IpLoginThrottle, key)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Failures)
+}
+
+func TestIT_LoginThrottleRepository_ForgetAttempt(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	_, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(-time.Minute), now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+	attempt, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+
+	err = repo.ForgetAttempt(context.Background(), persistence.IpLoginThrottle, key, attempt.LastFailureAt)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), persistence.IpLoginThrottle, key)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, actual.Failures)
+	assert.WithinDuration(t, attempt.LastFailureAt, actual.BlockedUntil, 0)
+}
+
+func TestIT_LoginThrottleRepository_ForgetAttempt_WhenAnotherAttemptWasMade_ExpectKept(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	attempt, err := repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now.Add(-time.Minute), now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+	_, err = repo.RecordAttempt(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour), testLoginBackOff)
+	require.Nil(t, err)
+
+	err = repo.ForgetAttempt(context.Background(), persistence.IpLoginThrottle, key, attempt.LastFailureAt)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), persistence.IpLoginThrottle, key)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Failures)
+}
+
+func TestIT_LoginThrottleRepository_Get_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+
+	_, err := repo.Get(context.Background(), persistence.IpLoginThrottle, "not-a-key-"+uuid.NewString())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_LoginThrottleRepository_Delete(t *testing.T) {
+	repo, _ := newTestLoginThrottleRepository(t)
+	key := "my-key-" + uuid.NewString()
+	now := time.Now()
+	_, err := repo.RecordFailure(context.Background(), persistence.IpLoginThrottle, key, now, now.Add(-time.Hour))
+	require.Nil(t, err)
+
+	err = repo.Delete(context.Background(), persistence.IpLoginThrottle, key)
+	assert.Nil(t, err)
+
+	_, err = repo.Get(context.Background(), persistence.IpLoginThrottle, key)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_LoginThrottleRepository_ListLockedAccounts(t *testing.T) {
+	repo, conn := newTestLoginThrottleRepository(t)
+	locked := insertTestUser(t, conn)
+	expired := insertTestUser(t, conn)
+	now := time.Now()
+	lock := persistence.LoginBackOff{Delays: []time.Duration{0}, LockAfter: 1, LockFor: time.Hour}
+	_, err := repo.RecordAttempt(context.Background(), persistence.EmailLoginThrottle, strings.ToLower(locked.Email), now, now.Add(-time.Hour), lock)
+	require.Nil(t, err)
+	_, err = repo.RecordAttempt(context.Background(), persistence.EmailLoginThrottle, strings.ToLower(expired.Email), now.Add(-time.Hour-time.Minute), now.Add(-2*time.Hour), lock)
+	require.Nil(t, err)
+
+	actual, err := repo.ListLockedAccounts(context.Background(), now)
+
+	assert.Nil(t, err)
+	var users []uuid.UUID
+	for _, lockout := range actual {
+		users = append(users, lockout.ApiUser)
+		if lockout.ApiUser == locked.Id {
+			assert.Equal(t, locked.Email, lockout.Email)
+			assert.Equal(t, 1, lockout.Failures)
+			assert.WithinDuration(t, now.Add(time.Hour), lockout.LockedUntil, time.Millisecond)
+		}
+	}
+	assert.Contains(t, users, locked.Id)
+	assert.NotContains(t, users, expired.Id)
+}
+
+// testLoginBackOff lets one free attempt through, then blocks the key for a
+// second and two seconds for the next failures.
+var testLoginBackOff = persistence.LoginBackOff{
+	Delays:  []time.Duration{0, 1 * time.Second, 2 * time.Second},
+	LockFor: 1 * time.Hour,
+}
+
+func newTestLoginThrottleRepository(t *testing.T) (LoginThrottleRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewLoginThrottleRepository(conn), conn
+}
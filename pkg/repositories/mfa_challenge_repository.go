@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type MfaChallengeRepository interface {
+	Create(ctx context.Context, tx db.Transaction, challenge persistence.MfaChallenge) (persistence.MfaChallenge, error)
+	Get(ctx context.Context, tokenHashes []string) (persistence.MfaChallenge, error)
+	RecordFailure(ctx context.Context, id uuid.UUID) (persistence.MfaChallenge, error)
+	Consume(ctx context.Context, id uuid.UUID) (persistence.MfaChallenge, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type mfaChallengeRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewMfaChallengeRepository(conn db.Connection) MfaChallengeRepository {
+	return &mfaChallengeRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createMfaChallengeSqlTemplate = `
+INSERT INTO mfa_challenge (id, token_hash, api_user, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5)`
+
+func (r *mfaChallengeRepositoryImpl) Create(ctx context.Context, tx db.Transaction, challenge persistence.MfaChallenge) (persistence.MfaChallenge, error) {
+	_, err := tx.Exec(ctx, createMfaChallengeSqlTemplate, challenge.Id, challenge.TokenHash, challenge.ApiUser, challenge.CreatedAt, challenge.ValidUntil)
+	return challenge, err
+}
+
+const getMfaChallengeSqlTemplate = `
+SELECT
+	id, token_hash, api_user, attempts, created_at, valid_until
+FROM
+	mfa_challenge
+WHERE
+	token_hash = ANY($1)`
+
+func (r *mfaChallengeRepositoryImpl) Get(ctx context.Context, tokenHashes []string) (persistence.MfaChallenge, error) {
+	return db.QueryOne[persistence.MfaChallenge](ctx, r.conn, getMfaChallengeSqlTemplate, tokenHashes)
+}
+
+// The counter is incremented in a single statement so that concurrent
+// attempts are all accounted for.
+const recordMfaFailureSqlTemplate = `
+UPDATE
+	mfa_challenge
+SET
+	attempts = attempts + 1
+WHERE
+	id = $1
+RETURNING
+	id, token_hash, api_user, attempts, created_at, valid_until`
+
+func (r *mfaChallengeRepositoryImpl) RecordFailure(ctx context.Context, id uuid.UUID) (persistence.MfaChallenge, error) {
+	return db.QueryOne[persistence.MfaChallenge](ctx, r.conn, recordMfaFailureSqlTemplate, id)
+}
+
+// Challenges can only be completed once: they are deleted as they are read,
+// so that concurrent attempts can't both open a session.
+const consumeMfaChallengeSqlTemplate = `
+DELETE FROM
+	mfa_challenge
+WHERE
+	id = $1
+RETURNING
+	id, token_hash, api_user, attempts, created_at, valid_until`
+
+func (r *mfaChallengeRepositoryImpl) Consume(ctx context.Context, id uuid.UUID) (persistence.MfaChallenge, error) {
+	return db.QueryOne[persistence.MfaChallenge](ctx, r.conn, consumeMfaChallengeSqlTemplate, id)
+}
+
+const deleteExpiredMfaChallengesSqlTemplate = `
+DELETE FROM
+	mfa_challenge
+WHERE
+	valid_until < $1`
+
+func (r *mfaChallengeRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredMfaChallengesSqlTemplate, at)
+	return err
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_MfaChallengeRepository_Create(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := newTestMfaChallenge(user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, challenge)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, challenge, actual)
+	assertMfaChallengeExists(t, conn, challenge.Id)
+}
+
+func TestIT_MfaChallengeRepository_Create_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	challenge := newTestMfaChallenge(uuid.New(), time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, challenge)
+	tx.Close(context.Background())
+
+	assert.NotNil(t, err)
+}
+
+func TestIT_MfaChallengeRepository_Get(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Get(context.Background(), []string{"not-a-token-hash", challenge.TokenHash})
+
+	assert.Nil(t, err)
+	assert.Equal(t, challenge, toUtcMfaChallenge(actual))
+}
+
+func TestIT_MfaChallengeRepository_Get_WhenChallengeDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _ := newTestMfaChallengeRepository(t)
+
+	_, err := repo.Get(context.Background(), []string{"not-a-token-hash"})
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_MfaChallengeRepository_RecordFailure(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := repo.RecordFailure(context.Background(), challenge.Id)
+	require.Nil(t, err)
+	actual, err := repo.RecordFailure(context.Background(), challenge.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual.Attempts)
+}
+
+func TestIT_MfaChallengeRepository_Consume_ExpectChallengeIsDeleted(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Consume(context.Background(), challenge.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, challenge, toUtcMfaChallenge(actual))
+	assertMfaChallengeDoesNotExist(t, conn, challenge.Id)
+}
+
+func TestIT_MfaChallengeRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	_, err := repo.Consume(context.Background(), challenge.Id)
+	require.Nil(t, err)
+
+	_, err = repo.Consume(context.Background(), challenge.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_MfaChallengeRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	expired := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertMfaChallengeDoesNotExist(t, conn, expired.Id)
+	assertMfaChallengeExists(t, conn, valid.Id)
+}
+
+func TestIT_MfaChallengeRepository_WhenUserIsDeleted_ExpectChallengesAreDeleted(t *testing.T) {
+	_, conn := newTestMfaChallengeRepository(t)
+	user := insertTestUser(t, conn)
+	challenge := insertTestMfaChallenge(t, conn, user.Id, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertMfaChallengeDoesNotExist(t, conn, challenge.Id)
+}
+
+func newTestMfaChallengeRepository(t *testing.T) (MfaChallengeRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewMfaChallengeRepository(conn), conn
+}
+
+func assertMfaChallengeExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM mfa_challenge WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertMfaChallengeDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM mfa_challenge WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestMfaChallenge(user uuid.UUID, validUntil time.Time) persistence.MfaChallenge {
+	return persistence.MfaChallenge{
+		Id:         uuid.New(),
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		ApiUser:    user,
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: validUntil,
+	}
+}
+
+func insertTestMfaChallenge(t *testing.T, conn db.Connection, user uuid.UUID, validUntil time.Time) persistence.MfaChallenge {
+	challenge := newTestMfaChallenge(user, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewMfaChallengeRepository(conn).Create(context.Background(), tx, challenge)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return challenge
+}
+
+func toUtcMfaChallenge(challenge persistence.MfaChallenge) persistence.MfaChallenge {
+	challenge.CreatedAt = challenge.CreatedAt.UTC()
+	challenge.ValidUntil = challenge.ValidUntil.UTC()
+	return challenge
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type PasswordHistoryRepository interface {
+	Create(ctx context.Context, tx db.Transaction, entry persistence.PasswordHistory) (persistence.PasswordHistory, error)
+	List(ctx context.Context, user uuid.UUID, limit int) ([]persistence.PasswordHistory, error)
+	Prune(ctx context.Context, tx db.Transaction, user uuid.UUID, keep int) error
+}
+
+type passwordHistoryRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewPasswordHistoryRepository(conn db.Connection) PasswordHistoryRepository {
+	return &passwordHistoryRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createPasswordHistorySqlTemplate = `
+INSERT INTO password_history (id, api_user, password, created_at)
+	VALUES($1, $2, $3, $4)`
+
+func (r *passwordHistoryRepositoryImpl) Create(ctx context.Context, tx db.Transaction, entry persistence.PasswordHistory) (persistence.PasswordHistory, error) {
+	_, err := tx.Exec(ctx, createPasswordHistorySqlTemplate, entry.Id, entry.ApiUser, entry.Password, entry.CreatedAt)
+	return entry, err
+}
+
+// The most recent passwords come first.
+const listPasswordHistorySqlTemplate = `
+SELECT
+	id,
+	api_user,
+	password,
+	created_at
+FROM
+	password_history
+WHERE
+	api_user = $1
+ORDER BY
+	created_at DESC
+LIMIT $2`
+
+func (r *passwordHistoryRepositoryImpl) List(ctx context.Context, user uuid.UUID, limit int) ([]persistence.PasswordHistory, error) {
+	return db.QueryAll[persistence.PasswordHistory](ctx, r.conn, listPasswordHistorySqlTemplate, user, limit)
+}
+
+const prunePasswordHistorySqlTemplate = `
+DELETE FROM
+	password_history
+WHERE
+	api_user = $1
+	AND id NOT IN (
+		SELECT
+			id
+		FROM
+			password_history
+		WHERE
+			api_user = $1
+		ORDER BY
+			created_at DESC
+		LIMIT $2
+	)`
+
+func (r *passwordHistoryRepositoryImpl) Prune(ctx context.Context, tx db.Transaction, user uuid.UUID, keep int) error {
+	_, err := tx.Exec(ctx, prunePasswordHistorySqlTemplate, user, keep)
+	return err
+}
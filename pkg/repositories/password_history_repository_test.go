@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_PasswordHistoryRepository_Create(t *testing.T) {
+	repo, conn := newTestPasswordHistoryRepository(t)
+	user := insertTestUser(t, conn)
+	entry := newTestPasswordHistory(user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, entry)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, entry, actual)
+	assertPasswordHistoryExists(t, conn, entry.Id)
+}
+
+func TestIT_PasswordHistoryRepository_List_ExpectMostRecentFirst(t *testing.T) {
+	repo, conn := newTestPasswordHistoryRepository(t)
+	user := insertTestUser(t, conn)
+	oldest := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	newest := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	insertTestPasswordHistory(t, conn, other, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+
+	actual, err := repo.List(context.Background(), user.Id, 10)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 2)
+	assert.Equal(t, newest.Id, actual[0].Id)
+	assert.Equal(t, oldest.Id, actual[1].Id)
+}
+
+func TestIT_PasswordHistoryRepository_List_ExpectLimitIsApplied(t *testing.T) {
+	repo, conn := newTestPasswordHistoryRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	newest := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	actual, err := repo.List(context.Background(), user.Id, 1)
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, newest.Id, actual[0].Id)
+}
+
+func TestIT_PasswordHistoryRepository_Prune(t *testing.T) {
+	repo, conn := newTestPasswordHistoryRepository(t)
+	user := insertTestUser(t, conn)
+	oldest := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	middle := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	newest := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	otherEntry := insertTestPasswordHistory(t, conn, other, time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Prune(context.Background(), tx, user.Id, 2)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordHistoryDoesNotExist(t, conn, oldest.Id)
+	assertPasswordHistoryExists(t, conn, middle.Id)
+	assertPasswordHistoryExists(t, conn, newest.Id)
+	assertPasswordHistoryExists(t, conn, otherEntry.Id)
+}
+
+func TestIT_PasswordHistoryRepository_Prune_WhenNothingIsKept_ExpectAllDeleted(t *testing.T) {
+	repo, conn := newTestPasswordHistoryRepository(t)
+	user := insertTestUser(t, conn)
+	entry := insertTestPasswordHistory(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Prune(context.Background(), tx, user.Id, 0)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordHistoryDoesNotExist(t, conn, entry.Id)
+}
+
+func newTestPasswordHistoryRepository(t *testing.T) (PasswordHistoryRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewPasswordHistoryRepository(conn), conn
+}
+
+func assertPasswordHistoryExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM password_history WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertPasswordHistoryDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM password_history WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestPasswordHistory(user persistence.User, createdAt time.Time) persistence.PasswordHistory {
+	return persistence.PasswordHistory{
+		Id:        uuid.New(),
+		ApiUser:   user.Id,
+		Password:  "my-hash-" + uuid.NewString(),
+		CreatedAt: createdAt,
+	}
+}
+
+func insertTestPasswordHistory(t *testing.T, conn db.Connection, user persistence.User, createdAt time.Time) persistence.PasswordHistory {
+	entry := newTestPasswordHistory(user, createdAt)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewPasswordHistoryRepository(conn).Create(context.Background(), tx, entry)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return entry
+}
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, tx db.Transaction, reset persistence.PasswordReset) (persistence.PasswordReset, error)
+	GetForToken(ctx context.Context, tokenHashes []string) (persistence.PasswordReset, error)
+	// Lock prevents the reset from being consumed by another transaction
+	// until the end of this one.
+	Lock(ctx context.Context, tx db.Transaction, id uuid.UUID) error
+	Consume(ctx context.Context, tx db.Transaction, id uuid.UUID) error
+	DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type passwordResetRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewPasswordResetRepository(conn db.Connection) PasswordResetRepository {
+	return &passwordResetRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createPasswordResetSqlTemplate = `
+INSERT INTO password_reset (id, api_user, email, token_hash, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6)`
+
+func (r *passwordResetRepositoryImpl) Create(ctx context.Context, tx db.Transaction, reset persistence.PasswordReset) (persistence.PasswordReset, error) {
+	_, err := tx.Exec(
+		ctx,
+		createPasswordResetSqlTemplate,
+		reset.Id,
+		reset.ApiUser,
+		reset.Email,
+		reset.TokenHash,
+		reset.CreatedAt,
+		reset.ValidUntil,
+	)
+	return reset, err
+}
+
+const getPasswordResetForTokenSqlTemplate = `
+SELECT
+	id,
+	api_user,
+	email,
+	token_hash,
+	created_at,
+	valid_until
+FROM
+	password_reset
+WHERE
+	token_hash = ANY($1)`
+
+func (r *passwordResetRepositoryImpl) GetForToken(ctx context.Context, tokenHashes []string) (persistence.PasswordReset, error) {
+	return db.QueryOne[persistence.PasswordReset](ctx, r.conn, getPasswordResetForTokenSqlTemplate, tokenHashes)
+}
+
+// The concurrent attempts to use the same token wait for the first one and
+// don't find it once it is consumed.
+const lockPasswordResetSqlTemplate = `
+SELECT
+	id
+FROM
+	password_reset
+WHERE
+	id = $1
+FOR UPDATE`
+
+func (r *passwordResetRepositoryImpl) Lock(ctx context.Context, tx db.Transaction, id uuid.UUID) error {
+	_, err := db.QueryOneTx[uuid.UUID](ctx, tx, lockPasswordResetSqlTemplate, id)
+	return err
+}
+
+// Only one of the concurrent attempts to use the same token finds it.
+const consumePasswordResetSqlTemplate = `
+DELETE FROM
+	password_reset
+WHERE
+	id = $1
+RETURNING
+	id`
+
+func (r *passwordResetRepositoryImpl) Consume(ctx context.Context, tx db.Transaction, id uuid.UUID) error {
+	_, err := db.QueryOneTx[uuid.UUID](ctx, tx, consumePasswordResetSqlTemplate, id)
+	return err
+}
+
+const deletePasswordResetsForUserSqlTemplate = `
+DELETE FROM
+	password_reset
+WHERE
+	api_user = $1`
+
+func (r *passwordResetRepositoryImpl) DeleteForUser(ctx context.Context, tx db.Transaction, user uuid.UUID) error {
+	_, err := tx.Exec(ctx, deletePasswordResetsForUserSqlTemplate, user)
+	return err
+}
+
+const deleteExpiredPasswordResetsSqlTemplate = `
+DELETE FROM
+	password_reset
+WHERE
+	valid_until < $1`
+
+func (r *passwordResetRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredPasswordResetsSqlTemplate, at)
+	return err
+}
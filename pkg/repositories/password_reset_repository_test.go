@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_PasswordResetRepository_Create(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := newTestPasswordReset(user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, reset)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, reset, actual)
+	assertPasswordResetExists(t, conn, reset.Id)
+}
+
+func TestIT_PasswordResetRepository_GetForToken(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.GetForToken(context.Background(), []string{"not-a-token-hash", reset.TokenHash})
+
+	assert.Nil(t, err)
+	assert.Equal(t, reset, toUtcPasswordReset(actual))
+	assertPasswordResetExists(t, conn, reset.Id)
+}
+
+func TestIT_PasswordResetRepository_GetForToken_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, _ := newTestPasswordResetRepository(t)
+
+	_, err := repo.GetForToken(context.Background(), []string{"not-a-token-hash"})
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetRepository_Consume(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Consume(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordResetDoesNotExist(t, conn, reset.Id)
+}
+
+func TestIT_PasswordResetRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Consume(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	tx, err = conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Consume(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetRepository_Lock(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Lock(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordResetExists(t, conn, reset.Id)
+}
+
+func TestIT_PasswordResetRepository_Lock_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Consume(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	tx, err = conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.Lock(context.Background(), tx, reset.Id)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_PasswordResetRepository_DeleteForUser(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	reset := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	other := insertTestUser(t, conn)
+	otherReset := insertTestPasswordReset(t, conn, other, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteForUser(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordResetDoesNotExist(t, conn, reset.Id)
+	assertPasswordResetExists(t, conn, otherReset.Id)
+}
+
+func TestIT_PasswordResetRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestPasswordResetRepository(t)
+	user := insertTestUser(t, conn)
+	expired := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestPasswordReset(t, conn, user, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertPasswordResetDoesNotExist(t, conn, expired.Id)
+	assertPasswordResetExists(t, conn, valid.Id)
+}
+
+func newTestPasswordResetRepository(t *testing.T) (PasswordResetRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewPasswordResetRepository(conn), conn
+}
+
+func assertPasswordResetExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM password_reset WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertPasswordResetDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM password_reset WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestPasswordReset(user persistence.User, validUntil time.Time) persistence.PasswordReset {
+	return persistence.PasswordReset{
+		Id:         uuid.New(),
+		ApiUser:    user.Id,
+		Email:      user.Email,
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: validUntil,
+	}
+}
+
+func insertTestPasswordReset(t *testing.T, conn db.Connection, user persistence.User, validUntil time.Time) persistence.PasswordReset {
+	reset := newTestPasswordReset(user, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewPasswordResetRepository(conn).Create(context.Background(), tx, reset)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return reset
+}
+
+func toUtcPasswordReset(reset persistence.PasswordReset) persistence.PasswordReset {
+	reset.CreatedAt = reset.CreatedAt.UTC()
+	reset.ValidUntil = reset.ValidUntil.UTC()
+	return reset
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type PermissionRepository interface {
+	Create(ctx context.Context, permission persistence.Permission) (persistence.Permission, error)
+	Get(ctx context.Context, id uuid.UUID) (persistence.Permission, error)
+	List(ctx context.Context) ([]persistence.Permission, error)
+	ListByName(ctx context.Context, names []string) ([]persistence.Permission, error)
+	Update(ctx context.Context, permission persistence.Permission) (persistence.Permission, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type permissionRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewPermissionRepository(conn db.Connection) PermissionRepository {
+	return &permissionRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createPermissionSqlTemplate = `
+INSERT INTO permission (id, name, description, created_at)
+	VALUES($1, $2, $3, $4)`
+
+func (r *permissionRepositoryImpl) Create(ctx context.Context, permission persistence.Permission) (persistence.Permission, error) {
+	_, err := r.conn.Exec(ctx, createPermissionSqlTemplate, permission.Id, permission.Name, permission.Description, permission.CreatedAt)
+	return permission, err
+}
+
+const getPermissionSqlTemplate = `
+SELECT
+	id, name, description, created_at
+FROM
+	permission
+WHERE
+	id = $1`
+
+func (r *permissionRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (persistence.Permission, error) {
+	return db.QueryOne[persistence.Permission](ctx, r.conn, getPermissionSqlTemplate, id)
+}
+
+const listPermissionsSqlTemplate = `
+SELECT
+	id, name, description, created_at
+FROM
+	permission
+ORDER BY
+	name`
+
+func (r *permissionRepositoryImpl) List(ctx context.Context) ([]persistence.Permission, error) {
+	return db.QueryAll[persistence.Permission](ctx, r.conn, listPermissionsSqlTemplate)
+}
+
+// Names which do not match a permission are ignored.
+const listPermissionsByNameSqlTemplate = `
+SELECT
+	id, name, description, created_at
+FROM
+	permission
+WHERE
+	name = ANY($1)
+ORDER BY
+	name`
+
+func (r *permissionRepositoryImpl) ListByName(ctx context.Context, names []string) ([]persistence.Permission, error) {
+	return db.QueryAll[persistence.Permission](ctx, r.conn, listPermissionsByNameSqlTemplate, names)
+}
+
+const updatePermissionSqlTemplate = `
+UPDATE
+	permission
+SET
+	name = $1,
+	description = $2
+WHERE
+	id = $3
+RETURNING
+	created_at`
+
+func (r *permissionRepositoryImpl) Update(ctx context.Context, permission persistence.Permission) (persistence.Permission, error) {
+	createdAt, err := db.QueryOne[time.Time](ctx, r.conn, updatePermissionSqlTemplate, permission.Name, permission.Description, permission.Id)
+	permission.CreatedAt = createdAt
+	return permission, err
+}
+
+const deletePermissionSqlTemplate = `
+DELETE FROM
+	permission
+WHERE
+	id = $1
+RETURNING
+	id`
+
+func (r *permissionRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, deletePermissionSqlTemplate, id)
+	return err
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_PermissionRepository_Create(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission := newTestPermission()
+
+	actual, err := repo.Create(context.Background(), permission)
+
+	assert.Nil(t, err)
+	assert.Equal(t, permission, actual)
+	assertPermissionExists(t, conn, permission.Id)
+}
+
+func TestIT_PermissionRepository_Create_WhenNameIsTaken_ExpectFailure(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	existing := insertTestPermission(t, conn)
+	permission := newTestPermission()
+	permission.Name = existing.Name
+
+	_, err := repo.Create(context.Background(), permission)
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_PermissionRepository_Get(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission := insertTestPermission(t, conn)
+
+	actual, err := repo.Get(context.Background(), permission.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, permission.Id, actual.Id)
+	assert.Equal(t, permission.Name, actual.Name)
+	assert.Equal(t, permission.Description, actual.Description)
+	assert.True(t, permission.CreatedAt.Equal(actual.CreatedAt))
+}
+
+func TestIT_PermissionRepository_Get_WhenNotFound_ExpectNoMatchingRows(t *testing.T) {
+	repo, _ := newTestPermissionRepository(t)
+
+	_, err := repo.Get(context.Background(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_PermissionRepository_List(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission := insertTestPermission(t, conn)
+
+	actual, err := repo.List(context.Background())
+
+	assert.Nil(t, err)
+	var ids []uuid.UUID
+	for _, p := range actual {
+		ids = append(ids, p.Id)
+	}
+	assert.Contains(t, ids, permission.Id)
+}
+
+func TestIT_PermissionRepository_ListByName_ExpectUnknownNamesIgnored(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission1 := insertTestPermission(t, conn)
+	insertTestPermission(t, conn)
+
+	actual, err := repo.ListByName(context.Background(), []string{permission1.Name, "not-a-permission"})
+
+	assert.Nil(t, err)
+	require.Len(t, actual, 1)
+	assert.Equal(t, permission1.Id, actual[0].Id)
+}
+
+func TestIT_PermissionRepository_Update(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission := insertTestPermission(t, conn)
+	permission.Name = "my-renamed-permission-" + uuid.NewString()
+	permission.Description = "Renamed permission"
+
+	actual, err := repo.Update(context.Background(), permission)
+
+	assert.Nil(t, err)
+	assert.Equal(t, permission.Name, actual.Name)
+	updated, err := repo.Get(context.Background(), permission.Id)
+	require.Nil(t, err)
+	assert.Equal(t, permission.Name, updated.Name)
+	assert.Equal(t, "Renamed permission", updated.Description)
+}
+
+func TestIT_PermissionRepository_Update_WhenNotFound_ExpectNoMatchingRows(t *testing.T) {
+	repo, _ := newTestPermissionRepository(t)
+
+	_, err := repo.Update(context.Background(), newTestPermission())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_PermissionRepository_Delete(t *testing.T) {
+	repo, conn := newTestPermissionRepository(t)
+	permission := insertTestPermission(t, conn)
+
+	err := repo.Delete(context.Background(), permission.Id)
+
+	assert.Nil(t, err)
+	assertPermissionDoesNotExist(t, conn, permission.Id)
+}
+
+func TestIT_PermissionRepository_Delete_WhenNotFound_ExpectNoMatchingRows(t *testing.T) {
+	repo, _ := newTestPermissionRepository(t)
+
+	err := repo.Delete(context.Background(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func newTestPermissionRepository(t *testing.T) (PermissionRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewPermissionRepository(conn), conn
+}
+
+func assertPermissionExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM permission WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertPermissionDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM permission WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestPermission() persistence.Permission {
+	return persistence.Permission{
+		Id:          uuid.New(),
+		Name:        "my-permission-" + uuid.NewString(),
+		Description: "My permission",
+		CreatedAt:   time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC),
+	}
+}
+
+func insertTestPermission(t *testing.T, conn db.Connection) persistence.Permission {
+	permission, err := NewPermissionRepository(conn).Create(context.Background(), newTestPermission())
+	require.Nil(t, err)
+	return permission
+}
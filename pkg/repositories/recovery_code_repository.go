@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type RecoveryCodeRepository interface {
+	Replace(ctx context.Context, tx db.Transaction, user uuid.UUID, codes []persistence.RecoveryCode) ([]persistence.RecoveryCode, error)
+	Use(ctx context.Context, user uuid.UUID, codeHash string, at time.Time, userAgent string, ipAddress string) (persistence.RecoveryCode, error)
+	CountUnused(ctx context.Context, user uuid.UUID) (int, error)
+}
+
+type recoveryCodeRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewRecoveryCodeRepository(conn db.Connection) RecoveryCodeRepository {
+	return &recoveryCodeRepositoryImpl{
+		conn: conn,
+	}
+}
+
+// Codes already used are kept: they record when the account was recovered.
+const deleteUnusedRecoveryCodesSqlTemplate = `
+DELETE FROM
+	recovery_code
+WHERE
+	api_user = $1
+	AND used_at IS NULL`
+
+const createRecoveryCodeSqlTemplate = `
+INSERT INTO recovery_code (id, api_user, code_hash, created_at)
+	VALUES($1, $2, $3, $4)`
+
+func (r *recoveryCodeRepositoryImpl) Replace(ctx context.Context, tx db.Transaction, user uuid.UUID, codes []persistence.RecoveryCode) ([]persistence.RecoveryCode, error) {
+	_, err := tx.Exec(ctx, deleteUnusedRecoveryCodesSqlTemplate, user)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, code := range codes {
+		_, err = tx.Exec(ctx, createRecoveryCodeSqlTemplate, code.Id, user, code.CodeHash, code.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, nil
+}
+
+// Checking that the code is not used yet in the same statement guarantees
+// that concurrent logins can't both use it.
+const useRecoveryCodeSqlTemplate = `
+UPDATE
+	recovery_code
+SET
+	used_at = $1,
+	user_agent = $2,
+	ip_address = $3
+WHERE
+	api_user = $4
+	AND code_hash = $5
+	AND used_at IS NULL
+RETURNING
+	id, api_user, code_hash, created_at, used_at, user_agent, ip_address`
+
+func (r *recoveryCodeRepositoryImpl) Use(ctx context.Context, user uuid.UUID, codeHash string, at time.Time, userAgent string, ipAddress string) (persistence.RecoveryCode, error) {
+	return db.QueryOne[persistence.RecoveryCode](ctx, r.conn, useRecoveryCodeSqlTemplate, at, userAgent, ipAddress, user, codeHash)
+}
+
+const countUnusedRecoveryCodesSqlTemplate = `
+SELECT
+	COUNT(id)
+FROM
+	recovery_code
+WHERE
+	api_user = $1
+	AND used_at IS NULL`
+
+func (r *recoveryCodeRepositoryImpl) CountUnused(ctx context.Context, user uuid.UUID) (int, error) {
+	return db.QueryOne[int](ctx, r.conn, countUnusedRecoveryCodesSqlTemplate, user)
+}
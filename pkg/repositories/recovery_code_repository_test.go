@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_RecoveryCodeRepository_Replace(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	codes := []persistence.RecoveryCode{
+		newTestRecoveryCode(user.Id),
+		newTestRecoveryCode(user.Id),
+	}
+
+	actual, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, codes)
+
+	assert.Nil(t, err)
+	assert.Equal(t, codes, actual)
+	assertRecoveryCodeExists(t, conn, codes[0].Id)
+	assertRecoveryCodeExists(t, conn, codes[1].Id)
+}
+
+func TestIT_RecoveryCodeRepository_Replace_DeletesUnusedCodes(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	previous := newTestRecoveryCode(user.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{previous})
+	require.Nil(t, err)
+
+	_, err = replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{newTestRecoveryCode(user.Id)})
+
+	assert.Nil(t, err)
+	assertRecoveryCodeDoesNotExist(t, conn, previous.Id)
+}
+
+func TestIT_RecoveryCodeRepository_Replace_KeepsUsedCodes(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	used := newTestRecoveryCode(user.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{used})
+	require.Nil(t, err)
+	_, err = repo.Use(context.Background(), user.Id, used.CodeHash, time.Now(), "my-user-agent", "192.0.2.1")
+	require.Nil(t, err)
+
+	_, err = replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{newTestRecoveryCode(user.Id)})
+
+	assert.Nil(t, err)
+	assertRecoveryCodeExists(t, conn, used.Id)
+}
+
+func TestIT_RecoveryCodeRepository_Replace_WhenCodesBelongToAnotherUser_ExpectKept(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	code := newTestRecoveryCode(other.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, other.Id, []persistence.RecoveryCode{code})
+	require.Nil(t, err)
+
+	_, err = replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{newTestRecoveryCode(user.Id)})
+
+	assert.Nil(t, err)
+	assertRecoveryCodeExists(t, conn, code.Id)
+}
+
+func TestIT_RecoveryCodeRepository_Use(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := newTestRecoveryCode(user.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{code})
+	require.Nil(t, err)
+	usedAt := time.Date(2024, 11, 12, 16, 40, 20, 0, time.UTC)
+
+	actual, err := repo.Use(context.Background(), user.Id, code.CodeHash, usedAt, "my-user-agent", "192.0.2.1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, code.Id, actual.Id)
+	require.NotNil(t, actual.UsedAt)
+	assert.Equal(t, usedAt, actual.UsedAt.UTC())
+	require.NotNil(t, actual.UserAgent)
+	assert.Equal(t, "my-user-agent", *actual.UserAgent)
+	require.NotNil(t, actual.IpAddress)
+	assert.Equal(t, "192.0.2.1", *actual.IpAddress)
+}
+
+func TestIT_RecoveryCodeRepository_Use_WhenAlreadyUsed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := newTestRecoveryCode(user.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{code})
+	require.Nil(t, err)
+	_, err = repo.Use(context.Background(), user.Id, code.CodeHash, time.Now(), "my-user-agent", "192.0.2.1")
+	require.Nil(t, err)
+
+	_, err = repo.Use(context.Background(), user.Id, code.CodeHash, time.Now(), "my-user-agent", "192.0.2.1")
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RecoveryCodeRepository_Use_WhenCodeDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _ := newTestRecoveryCodeRepository(t)
+
+	_, err := repo.Use(context.Background(), uuid.New(), "not-a-code-hash", time.Now(), "my-user-agent", "192.0.2.1")
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RecoveryCodeRepository_Use_WhenCodeBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	other := insertTestUser(t, conn)
+	code := newTestRecoveryCode(other.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, other.Id, []persistence.RecoveryCode{code})
+	require.Nil(t, err)
+
+	_, err = repo.Use(context.Background(), user.Id, code.CodeHash, time.Now(), "my-user-agent", "192.0.2.1")
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	assertRecoveryCodeExists(t, conn, code.Id)
+}
+
+func TestIT_RecoveryCodeRepository_CountUnused(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	codes := []persistence.RecoveryCode{
+		newTestRecoveryCode(user.Id),
+		newTestRecoveryCode(user.Id),
+		newTestRecoveryCode(user.Id),
+	}
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, codes)
+	require.Nil(t, err)
+	_, err = repo.Use(context.Background(), user.Id, codes[0].CodeHash, time.Now(), "my-user-agent", "192.0.2.1")
+	require.Nil(t, err)
+
+	actual, err := repo.CountUnused(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, actual)
+}
+
+func TestIT_RecoveryCodeRepository_WhenUserIsDeleted_ExpectCodesAreDeleted(t *testing.T) {
+	repo, conn := newTestRecoveryCodeRepository(t)
+	user := insertTestUser(t, conn)
+	code := newTestRecoveryCode(user.Id)
+	_, err := replaceTestRecoveryCodes(t, repo, conn, user.Id, []persistence.RecoveryCode{code})
+	require.Nil(t, err)
+
+	_, err = conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertRecoveryCodeDoesNotExist(t, conn, code.Id)
+}
+
+func newTestRecoveryCodeRepository(t *testing.T) (RecoveryCodeRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewRecoveryCodeRepository(conn), conn
+}
+
+func replaceTestRecoveryCodes(t *testing.T, repo RecoveryCodeRepository, conn db.Connection, user uuid.UUID, codes []persistence.RecoveryCode) ([]persistence.RecoveryCode, error) {
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	defer tx.Close(context.Background())
+
+	return repo.Replace(context.Background(), tx, user, codes)
+}
+
+func assertRecoveryCodeExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM recovery_code WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertRecoveryCodeDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM recovery_code WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestRecoveryCode(user uuid.UUID) persistence.RecoveryCode {
+	return persistence.RecoveryCode{
+		Id:        uuid.New(),
+		ApiUser:   user,
+		CodeHash:  "my-hash-" + uuid.New().String(),
+		CreatedAt: time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+	}
+}
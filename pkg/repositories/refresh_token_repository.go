@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, tx db.Transaction, token persistence.RefreshToken) (persistence.RefreshToken, error)
+	LockForToken(ctx context.Context, tx db.Transaction, tokenHashes []string) (persistence.RefreshToken, error)
+	ListRefreshableSessions(ctx context.Context, user uuid.UUID, at time.Time) ([]uuid.UUID, error)
+	MarkUsed(ctx context.Context, tx db.Transaction, id uuid.UUID, usedAt time.Time) error
+}
+
+type refreshTokenRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewRefreshTokenRepository(conn db.Connection) RefreshTokenRepository {
+	return &refreshTokenRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createRefreshTokenSqlTemplate = `
+INSERT INTO refresh_token (id, token_hash, api_key, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5)`
+
+func (r *refreshTokenRepositoryImpl) Create(ctx context.Context, tx db.Transaction, token persistence.RefreshToken) (persistence.RefreshToken, error) {
+	_, err := tx.Exec(ctx, createRefreshTokenSqlTemplate, token.Id, token.TokenHash, token.ApiKey, token.CreatedAt, token.ValidUntil)
+	return token, err
+}
+
+// The token is locked so that concurrent attempts to use it are serialized:
+// only the first one succeeds, the others are treated as reuses.
+const lockRefreshTokenForTokenSqlTemplate = `
+SELECT
+	id, token_hash, api_key, created_at, used_at, valid_until
+FROM
+	refresh_token
+WHERE
+	token_hash = ANY($1)
+FOR UPDATE`
+
+func (r *refreshTokenRepositoryImpl) LockForToken(ctx context.Context, tx db.Transaction, tokenHashes []string) (persistence.RefreshToken, error) {
+	return db.QueryOneTx[persistence.RefreshToken](ctx, tx, lockRefreshTokenForTokenSqlTemplate, tokenHashes)
+}
+
+const listRefreshableSessionsSqlTemplate = `
+SELECT DISTINCT
+	refresh_token.api_key
+FROM
+	refresh_token
+	JOIN api_key ON api_key.id = refresh_token.api_key
+WHERE
+	api_key.api_user = $1
+	AND refresh_token.used_at IS NULL
+	AND refresh_token.valid_until > $2`
+
+func (r *refreshTokenRepositoryImpl) ListRefreshableSessions(ctx context.Context, user uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+	return db.QueryAll[uuid.UUID](ctx, r.conn, listRefreshableSessionsSqlTemplate, user, at)
+}
+
+const markRefreshTokenUsedSqlTemplate = `
+UPDATE
+	refresh_token
+SET
+	used_at = $2
+WHERE
+	id = $1`
+
+func (r *refreshTokenRepositoryImpl) MarkUsed(ctx context.Context, tx db.Transaction, id uuid.UUID, usedAt time.Time) error {
+	_, err := tx.Exec(ctx, markRefreshTokenUsedSqlTemplate, id, usedAt)
+	return err
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_RefreshTokenRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestRefreshTokenRepositoryAndTransaction(t)
+	_, apiKey := insertTestApiKey(t, conn)
+
+	token := persistence.RefreshToken{
+		Id:         uuid.New(),
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		ApiKey:     apiKey.Id,
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: time.Date(2024, 12, 12, 16, 32, 20, 0, time.UTC),
+	}
+
+	actual, err := repo.Create(context.Background(), tx, token)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, token, actual)
+	assertRefreshTokenExists(t, conn, token.Id)
+}
+
+func TestIT_RefreshTokenRepository_Create_WhenSessionDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _, tx := newTestRefreshTokenRepositoryAndTransaction(t)
+
+	token := persistence.RefreshToken{
+		Id:         uuid.New(),
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		ApiKey:     uuid.New(),
+		CreatedAt:  time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil: time.Date(2024, 12, 12, 16, 32, 20, 0, time.UTC),
+	}
+
+	_, err := repo.Create(context.Background(), tx, token)
+	tx.Close(context.Background())
+
+	assert.NotNil(t, err)
+}
+
+func TestIT_RefreshTokenRepository_LockForToken(t *testing.T) {
+	repo, conn, tx := newTestRefreshTokenRepositoryAndTransaction(t)
+	_, apiKey := insertTestApiKey(t, conn)
+	token := insertTestRefreshToken(t, conn, apiKey.Id, time.Date(2024, 12, 12, 16, 32, 20, 0, time.UTC))
+
+	actual, err := repo.LockForToken(context.Background(), tx, []string{"not-a-token-hash", token.TokenHash})
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, token, toUtcRefreshToken(actual))
+}
+
+func TestIT_RefreshTokenRepository_LockForToken_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, _, tx := newTestRefreshTokenRepositoryAndTransaction(t)
+
+	_, err := repo.LockForToken(context.Background(), tx, []string{"not-a-token-hash"})
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RefreshTokenRepository_MarkUsed(t *testing.T) {
+	repo, conn, tx := newTestRefreshTokenRepositoryAndTransaction(t)
+	_, apiKey := insertTestApiKey(t, conn)
+	token := insertTestRefreshToken(t, conn, apiKey.Id, time.Date(2024, 12, 12, 16, 32, 20, 0, time.UTC))
+	usedAt := time.Date(2024, 11, 13, 8, 0, 0, 0, time.UTC)
+
+	err := repo.MarkUsed(context.Background(), tx, token.Id, usedAt)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	value, err := db.QueryOne[time.Time](context.Background(), conn, "SELECT used_at FROM refresh_token WHERE id = $1", token.Id)
+	require.Nil(t, err)
+	assert.Equal(t, usedAt, value.UTC())
+}
+
+func TestIT_RefreshTokenRepository_ListRefreshableSessions(t *testing.T) {
+	repo, conn := newTestRefreshTokenRepository(t)
+	user, apiKey1 := insertTestApiKey(t, conn)
+	apiKey2 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
+	apiKey3 := insertTestApiKeyForUser(t, conn, user.Id, apiKey1.CreatedAt)
+	_, otherApiKey := insertTestApiKey(t, conn)
+	at := time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC)
+
+	insertTestRefreshToken(t, conn, apiKey1.Id, at.Add(time.Hour))
+	insertTestRefreshToken(t, conn, apiKey2.Id, at.Add(-time.Hour))
+	used := insertTestRefreshToken(t, conn, apiKey3.Id, at.Add(time.Hour))
+	_, err := conn.Exec(context.Background(), "UPDATE refresh_token SET used_at = $1 WHERE id = $2", at, used.Id)
+	require.Nil(t, err)
+	insertTestRefreshToken(t, conn, otherApiKey.Id, at.Add(time.Hour))
+
+	actual, err := repo.ListRefreshableSessions(context.Background(), user.Id, at)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{apiKey1.Id}, actual)
+}
+
+func TestIT_RefreshTokenRepository_WhenSessionIsDeleted_ExpectTokensDeleted(t *testing.T) {
+	conn := newTestConnection(t)
+	_, apiKey := insertTestApiKey(t, conn)
+	token := insertTestRefreshToken(t, conn, apiKey.Id, time.Date(2024, 12, 12, 16, 32, 20, 0, time.UTC))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_key WHERE id = $1", apiKey.Id)
+	require.Nil(t, err)
+
+	assertRefreshTokenDoesNotExist(t, conn, token.Id)
+}
+
+func newTestRefreshTokenRepository(t *testing.T) (RefreshTokenRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewRefreshTokenRepository(conn), conn
+}
+
+func newTestRefreshTokenRepositoryAndTransaction(t *testing.T) (RefreshTokenRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewRefreshTokenRepository(conn), conn, tx
+}
+
+func assertRefreshTokenExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM refresh_token WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertRefreshTokenDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM refresh_token WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func insertTestRefreshToken(t *testing.T, conn db.Connection, apiKey uuid.UUID, validUntil time.Time) persistence.RefreshToken {
+	token := persistence.RefreshToken{
+		Id:         uuid.New(),
+		TokenHash:  "my-token-hash-" + uuid.NewString(),
+		ApiKey:     apiKey,
+		CreatedAt:  time.Date(2024, 11, 12, 16, 49, 35, 0, time.UTC),
+		ValidUntil: validUntil,
+	}
+	_, err := conn.Exec(context.Background(), "INSERT INTO refresh_token (id, token_hash, api_key, created_at, valid_until) VALUES ($1, $2, $3, $4, $5)", token.Id, token.TokenHash, token.ApiKey, token.CreatedAt, token.ValidUntil)
+	require.Nil(t, err)
+
+	return token
+}
+
+func toUtcRefreshToken(token persistence.RefreshToken) persistence.RefreshToken {
+	token.CreatedAt = token.CreatedAt.UTC()
+	if token.UsedAt != nil {
+		usedAt := token.UsedAt.UTC()
+		token.UsedAt = &usedAt
+	}
+	token.ValidUntil = token.ValidUntil.UTC()
+	return token
+}
@@ -1,6 +1,27 @@
 package repositories
 
 type Repositories struct {
-	ApiKey ApiKeyRepository
-	User   UserRepository
+	ApiKey             ApiKeyRepository
+	AuthorizationCode  AuthorizationCodeRepository
+	EmailLogin         EmailLoginRepository
+	EmailVerification  EmailVerificationRepository
+	FederatedLogin     FederatedLoginRepository
+	Identity           IdentityRepository
+	Keyring            KeyringRepository
+	LoginThrottle      LoginThrottleRepository
+	MfaChallenge       MfaChallengeRepository
+	PasswordHistory    PasswordHistoryRepository
+	Permission         PermissionRepository
+	PasswordReset      PasswordResetRepository
+	RecoveryCode       RecoveryCodeRepository
+	RefreshToken       RefreshTokenRepository
+	RevokedToken       RevokedTokenRepository
+	Role               RoleRepository
+	RoleAssignment     RoleAssignmentRepository
+	SamlAssertion      SamlAssertionRepository
+	SamlRequest        SamlRequestRepository
+	Totp               TotpRepository
+	User               UserRepository
+	WebAuthnCeremony   WebAuthnCeremonyRepository
+	WebAuthnCredential WebAuthnCredentialRepository
 }
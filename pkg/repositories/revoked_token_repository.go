@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/google/uuid"
+)
+
+// RevokedTokenRepository only allows reading: tokens are added by the
+// database whenever the session they were issued for is rotated or deleted.
+type RevokedTokenRepository interface {
+	ListActive(ctx context.Context, at time.Time) ([]uuid.UUID, error)
+}
+
+type revokedTokenRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewRevokedTokenRepository(conn db.Connection) RevokedTokenRepository {
+	return &revokedTokenRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const listActiveRevokedTokensSqlTemplate = `
+SELECT
+	id
+FROM
+	revoked_token
+WHERE
+	expires_at > $1`
+
+func (r *revokedTokenRepositoryImpl) ListActive(ctx context.Context, at time.Time) ([]uuid.UUID, error) {
+	return db.QueryAll[uuid.UUID](ctx, r.conn, listActiveRevokedTokensSqlTemplate, at)
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_RevokedTokenRepository_WhenSessionIsDeleted_ExpectTokenRevoked(t *testing.T) {
+	repo, conn := newTestRevokedTokenRepository(t)
+	apiKey := insertTestApiKeyWithToken(t, conn, time.Now().Add(time.Hour))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_key WHERE id = $1", apiKey.Id)
+	require.Nil(t, err)
+
+	actual, err := repo.ListActive(context.Background(), time.Now())
+	assert.Nil(t, err)
+	assert.Contains(t, actual, *apiKey.TokenId)
+}
+
+func TestIT_RevokedTokenRepository_WhenSessionIsRotated_ExpectPreviousTokenRevoked(t *testing.T) {
+	repo, conn := newTestRevokedTokenRepository(t)
+	apiKey := insertTestApiKeyWithToken(t, conn, time.Now().Add(time.Hour))
+	apiKeyRepo := NewApiKeyRepository(conn)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	tokenId := uuid.New()
+	err = apiKeyRepo.Rotate(context.Background(), tx, apiKey.Id, "my-key-hash-"+uuid.NewString(), &tokenId, apiKey.ValidUntil)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	actual, err := repo.ListActive(context.Background(), time.Now())
+	assert.Nil(t, err)
+	assert.Contains(t, actual, *apiKey.TokenId)
+	assert.NotContains(t, actual, tokenId)
+}
+
+func TestIT_RevokedTokenRepository_WhenSessionIsTouched_ExpectTokenNotRevoked(t *testing.T) {
+	repo, conn := newTestRevokedTokenRepository(t)
+	apiKey := insertTestApiKeyWithToken(t, conn, time.Now().Add(time.Hour))
+	apiKeyRepo := NewApiKeyRepository(conn)
+
+	err := apiKeyRepo.Touch(context.Background(), apiKey.Id, time.Now(), apiKey.ValidUntil)
+	require.Nil(t, err)
+
+	actual, err := repo.ListActive(context.Background(), time.Now())
+	assert.Nil(t, err)
+	assert.NotContains(t, actual, *apiKey.TokenId)
+}
+
+func TestIT_RevokedTokenRepository_WhenSessionIsExpired_ExpectTokenNotRevoked(t *testing.T) {
+	repo, conn := newTestRevokedTokenRepository(t)
+	apiKey := insertTestApiKeyWithToken(t, conn, time.Now().Add(-time.Hour))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_key WHERE id = $1", apiKey.Id)
+	require.Nil(t, err)
+
+	actual, err := repo.ListActive(context.Background(), time.Now())
+	assert.Nil(t, err)
+	assert.NotContains(t, actual, *apiKey.TokenId)
+}
+
+func TestIT_RevokedTokenRepository_ListActive_WhenTokenIsExpired_ExpectNotReturned(t *testing.T) {
+	repo, conn := newTestRevokedTokenRepository(t)
+	apiKey := insertTestApiKeyWithToken(t, conn, time.Now().Add(time.Hour))
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_key WHERE id = $1", apiKey.Id)
+	require.Nil(t, err)
+
+	actual, err := repo.ListActive(context.Background(), time.Now().Add(2*time.Hour))
+	assert.Nil(t, err)
+	assert.NotContains(t, actual, *apiKey.TokenId)
+}
+
+func newTestRevokedTokenRepository(t *testing.T) (RevokedTokenRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewRevokedTokenRepository(conn), conn
+}
+
+func insertTestApiKeyWithToken(t *testing.T, conn db.Connection, validUntil time.Time) persistence.ApiKey {
+	user := insertTestUser(t, conn)
+
+	tokenId := uuid.New()
+	apiKey := persistence.ApiKey{
+		Id:         uuid.New(),
+		KeyHash:    "my-key-hash-" + uuid.NewString(),
+		ApiUser:    user.Id,
+		TokenId:    &tokenId,
+		CreatedAt:  validUntil.Add(-2 * time.Hour),
+		ValidUntil: validUntil,
+	}
+	_, err := conn.Exec(context.Background(), "INSERT INTO api_key (id, key_hash, api_user, token_id, created_at, valid_until) VALUES ($1, $2, $3, $4, $5, $6)", apiKey.Id, apiKey.KeyHash, apiKey.ApiUser, apiKey.TokenId, apiKey.CreatedAt, apiKey.ValidUntil)
+	require.Nil(t, err)
+
+	return apiKey
+}
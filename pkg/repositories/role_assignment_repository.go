@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type RoleAssignmentRepository interface {
+	Create(ctx context.Context, assignment persistence.RoleAssignment) (persistence.RoleAssignment, error)
+	// ListRoles returns the roles assigned to the user, without the ones
+	// they inherit from.
+	ListRoles(ctx context.Context, user uuid.UUID) ([]persistence.Role, error)
+	Delete(ctx context.Context, user uuid.UUID, role uuid.UUID) error
+}
+
+type roleAssignmentRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewRoleAssignmentRepository(conn db.Connection) RoleAssignmentRepository {
+	return &roleAssignmentRepositoryImpl{
+		conn: conn,
+	}
+}
+
+// Assigning a role twice keeps the first assignment.
+const createRoleAssignmentSqlTemplate = `
+INSERT INTO role_assignment (api_user, role, created_at)
+	VALUES($1, $2, $3)
+	ON CONFLICT (api_user, role) DO NOTHING`
+
+func (r *roleAssignmentRepositoryImpl) Create(ctx context.Context, assignment persistence.RoleAssignment) (persistence.RoleAssignment, error) {
+	_, err := r.conn.Exec(ctx, createRoleAssignmentSqlTemplate, assignment.ApiUser, assignment.Role, assignment.CreatedAt)
+	return assignment, err
+}
+
+const listRolesForUserSqlTemplate = `
+SELECT
+	r.id, r.name, r.description, r.parent, r.created_at, r.updated_at, r.version
+FROM
+	role_assignment AS ra
+	JOIN role AS r ON r.id = ra.role
+WHERE
+	ra.api_user = $1
+ORDER BY
+	r.name`
+
+func (r *roleAssignmentRepositoryImpl) ListRoles(ctx context.Context, user uuid.UUID) ([]persistence.Role, error) {
+	return db.QueryAll[persistence.Role](ctx, r.conn, listRolesForUserSqlTemplate, user)
+}
+
+const deleteRoleAssignmentSqlTemplate = `
+DELETE FROM
+	role_assignment
+WHERE
+	api_user = $1
+	AND role = $2
+RETURNING
+	role`
+
+func (r *roleAssignmentRepositoryImpl) Delete(ctx context.Context, user uuid.UUID, role uuid.UUID) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, deleteRoleAssignmentSqlTemplate, user, role)
+	return err
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_RoleAssignmentRepository_Create(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+	assignment := newTestRoleAssignment(user, role)
+
+	actual, err := repo.Create(context.Background(), assignment)
+
+	assert.Nil(t, err)
+	assert.Equal(t, assignment, actual)
+	roles, err := repo.ListRoles(context.Background(), user.Id)
+	require.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{role.Id}, roleIds(roles))
+}
+
+func TestIT_RoleAssignmentRepository_Create_WhenAlreadyAssigned_ExpectSuccess(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+	insertTestRoleAssignment(t, conn, user, role)
+
+	_, err := repo.Create(context.Background(), newTestRoleAssignment(user, role))
+
+	assert.Nil(t, err)
+	roles, err := repo.ListRoles(context.Background(), user.Id)
+	require.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{role.Id}, roleIds(roles))
+}
+
+func TestIT_RoleAssignmentRepository_Create_WhenRoleDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	assignment := persistence.RoleAssignment{
+		ApiUser:   user.Id,
+		Role:      uuid.New(),
+		CreatedAt: time.Now(),
+	}
+
+	_, err := repo.Create(context.Background(), assignment)
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.ForeignKeyValidation), "Actual err: %v", err)
+}
+
+func TestIT_RoleAssignmentRepository_ListRoles_ExpectInheritedRolesNotReturned(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+	insertTestRoleAssignment(t, conn, user, role)
+	otherUser := insertTestUser(t, conn)
+	otherRole := insertTestRole(t, conn, nil)
+	insertTestRoleAssignment(t, conn, otherUser, otherRole)
+
+	actual, err := repo.ListRoles(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []uuid.UUID{role.Id}, roleIds(actual))
+}
+
+func TestIT_RoleAssignmentRepository_ListRoles_WhenRoleIsDeleted_ExpectNotReturned(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+	insertTestRoleAssignment(t, conn, user, role)
+	err := NewRoleRepository(conn).Delete(context.Background(), role.Id)
+	require.Nil(t, err)
+
+	actual, err := repo.ListRoles(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestIT_RoleAssignmentRepository_Delete(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+	insertTestRoleAssignment(t, conn, user, role)
+
+	err := repo.Delete(context.Background(), user.Id, role.Id)
+
+	assert.Nil(t, err)
+	roles, err := repo.ListRoles(context.Background(), user.Id)
+	require.Nil(t, err)
+	assert.Empty(t, roles)
+}
+
+func TestIT_RoleAssignmentRepository_Delete_WhenNotAssigned_ExpectNoMatchingRows(t *testing.T) {
+	repo, conn := newTestRoleAssignmentRepository(t)
+	user := insertTestUser(t, conn)
+	role := insertTestRole(t, conn, nil)
+
+	err := repo.Delete(context.Background(), user.Id, role.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func newTestRoleAssignmentRepository(t *testing.T) (RoleAssignmentRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewRoleAssignmentRepository(conn), conn
+}
+
+func newTestRoleAssignment(user persistence.User, role persistence.Role) persistence.RoleAssignment {
+	return persistence.RoleAssignment{
+		ApiUser:   user.Id,
+		Role:      role.Id,
+		CreatedAt: time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC),
+	}
+}
+
+func insertTestRoleAssignment(t *testing.T, conn db.Connection, user persistence.User, role persistence.Role) {
+	_, err := NewRoleAssignmentRepository(conn).Create(context.Background(), newTestRoleAssignment(user, role))
+	require.Nil(t, err)
+}
@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type RoleRepository interface {
+	Create(ctx context.Context, tx db.Transaction, role persistence.Role) (persistence.Role, error)
+	Get(ctx context.Context, id uuid.UUID) (persistence.Role, error)
+	List(ctx context.Context) ([]persistence.Role, error)
+	// ListInherited returns the roles with the given ids along with all the
+	// roles they inherit from.
+	ListInherited(ctx context.Context, ids []uuid.UUID) ([]persistence.Role, error)
+	Update(ctx context.Context, tx db.Transaction, role persistence.Role) (persistence.Role, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// ListPermissions returns the permissions granted directly to the
+	// roles, without the inherited ones.
+	ListPermissions(ctx context.Context, roles []uuid.UUID) ([]persistence.RolePermission, error)
+	SetPermissions(ctx context.Context, tx db.Transaction, role uuid.UUID, permissions []uuid.UUID) error
+}
+
+type roleRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewRoleRepository(conn db.Connection) RoleRepository {
+	return &roleRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createRoleSqlTemplate = `
+INSERT INTO role (id, name, description, parent, created_at)
+	VALUES($1, $2, $3, $4, $5)
+	RETURNING updated_at`
+
+func (r *roleRepositoryImpl) Create(ctx context.Context, tx db.Transaction, role persistence.Role) (persistence.Role, error) {
+	updatedAt, err := db.QueryOneTx[time.Time](ctx, tx, createRoleSqlTemplate, role.Id, role.Name, role.Description, role.Parent, role.CreatedAt)
+	role.UpdatedAt = updatedAt
+	return role, err
+}
+
+const getRoleSqlTemplate = `
+SELECT
+	id, name, description, parent, created_at, updated_at, version
+FROM
+	role
+WHERE
+	id = $1`
+
+func (r *roleRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (persistence.Role, error) {
+	return db.QueryOne[persistence.Role](ctx, r.conn, getRoleSqlTemplate, id)
+}
+
+const listRolesSqlTemplate = `
+SELECT
+	id, name, description, parent, created_at, updated_at, version
+FROM
+	role
+ORDER BY
+	name`
+
+func (r *roleRepositoryImpl) List(ctx context.Context) ([]persistence.Role, error) {
+	return db.QueryAll[persistence.Role](ctx, r.conn, listRolesSqlTemplate)
+}
+
+// https://www.postgresql.org/docs/current/queries-with.html#QUERIES-WITH-RECURSIVE
+// UNION discards the roles which were already visited: this terminates
+// even if the hierarchy holds a cycle.
+const listInheritedRolesSqlTemplate = `
+WITH RECURSIVE inherited AS (
+	SELECT
+		id, name, description, parent, created_at, updated_at, version
+	FROM
+		role
+	WHERE
+		id = ANY($1)
+	UNION
+	SELECT
+		r.id, r.name, r.description, r.parent, r.created_at, r.updated_at, r.version
+	FROM
+		role AS r
+		JOIN inherited AS i ON r.id = i.parent
+)
+SELECT
+	id, name, description, parent, created_at, updated_at, version
+FROM
+	inherited
+ORDER BY
+	name`
+
+func (r *roleRepositoryImpl) ListInherited(ctx context.Context, ids []uuid.UUID) ([]persistence.Role, error) {
+	return db.QueryAll[persistence.Role](ctx, r.conn, listInheritedRolesSqlTemplate, ids)
+}
+
+const updateRoleSqlTemplate = `
+UPDATE
+	role
+SET
+	name = $1,
+	description = $2,
+	parent = $3,
+	version = $4
+WHERE
+	id = $5
+	AND version = $6
+RETURNING
+	updated_at`
+
+func (r *roleRepositoryImpl) Update(ctx context.Context, tx db.Transaction, role persistence.Role) (persistence.Role, error) {
+	version := role.Version + 1
+
+	updatedAt, err := db.QueryOneTx[time.Time](ctx, tx, updateRoleSqlTemplate, role.Name, role.Description, role.Parent, version, role.Id, role.Version)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return role, errors.NewCode(OptimisticLockException)
+		}
+		return role, err
+	}
+
+	role.Version = version
+	role.UpdatedAt = updatedAt
+
+	return role, nil
+}
+
+const deleteRoleSqlTemplate = `
+DELETE FROM
+	role
+WHERE
+	id = $1
+RETURNING
+	id`
+
+func (r *roleRepositoryImpl) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, deleteRoleSqlTemplate, id)
+	return err
+}
+
+const listRolePermissionsSqlTemplate = `
+SELECT
+	rp.role,
+	p.name AS permission
+FROM
+	role_permission AS rp
+	JOIN permission AS p ON p.id = rp.permission
+WHERE
+	rp.role = ANY($1)
+ORDER BY
+	p.name`
+
+func (r *roleRepositoryImpl) ListPermissions(ctx context.Context, roles []uuid.UUID) ([]persistence.RolePermission, error) {
+	return db.QueryAll[persistence.RolePermission](ctx, r.conn, listRolePermissionsSqlTemplate, roles)
+}
+
+const deleteRolePermissionsSqlTemplate = `
+DELETE FROM
+	role_permission
+WHERE
+	role = $1`
+
+const createRolePermissionsSqlTemplate = `
+INSERT INTO role_permission (role, permission)
+	SELECT $1, permission FROM unnest($2::uuid[]) AS permission`
+
+// SetPermissions replaces the permissions granted directly to the role.
+func (r *roleRepositoryImpl) SetPermissions(ctx context.Context, tx db.Transaction, role uuid.UUID, permissions []uuid.UUID) error {
+	_, err := tx.Exec(ctx, deleteRolePermissionsSqlTemplate, role)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, createRolePermissionsSqlTemplate, role, permissions)
+	return err
+}
@@ -0,0 +1,332 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_RoleRepository_Create(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := newTestRole(nil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, role)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, role.Id, actual.Id)
+	assert.Equal(t, role.Name, actual.Name)
+	assertRoleExists(t, conn, role.Id)
+}
+
+func TestIT_RoleRepository_Create_WhenNameIsTaken_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	existing := insertTestRole(t, conn, nil)
+	role := newTestRole(nil)
+	role.Name = existing.Name
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, role)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_Create_WhenParentDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := uuid.New()
+	role := newTestRole(&parent)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, role)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.ForeignKeyValidation), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_Get(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+
+	actual, err := repo.Get(context.Background(), role.Id)
+
+	assert.Nil(t, err)
+	assert.Equal(t, role.Id, actual.Id)
+	assert.Equal(t, role.Name, actual.Name)
+	assert.Equal(t, role.Description, actual.Description)
+	assert.Equal(t, &parent.Id, actual.Parent)
+	assert.Equal(t, 0, actual.Version)
+}
+
+func TestIT_RoleRepository_Get_WhenNotFound_ExpectNoMatchingRows(t *testing.T) {
+	repo, _ := newTestRoleRepository(t)
+
+	_, err := repo.Get(context.Background(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_List(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+
+	actual, err := repo.List(context.Background())
+
+	assert.Nil(t, err)
+	assert.Contains(t, roleIds(actual), role.Id)
+}
+
+func TestIT_RoleRepository_ListInherited_ExpectAncestorsReturned(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	grandParent := insertTestRole(t, conn, nil)
+	parent := insertTestRole(t, conn, &grandParent.Id)
+	role := insertTestRole(t, conn, &parent.Id)
+	child := insertTestRole(t, conn, &role.Id)
+
+	actual, err := repo.ListInherited(context.Background(), []uuid.UUID{role.Id})
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{grandParent.Id, parent.Id, role.Id}, roleIds(actual))
+	assert.NotContains(t, roleIds(actual), child.Id)
+}
+
+func TestIT_RoleRepository_ListInherited_WhenAncestorIsShared_ExpectReturnedOnce(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role1 := insertTestRole(t, conn, &parent.Id)
+	role2 := insertTestRole(t, conn, &parent.Id)
+
+	actual, err := repo.ListInherited(context.Background(), []uuid.UUID{role1.Id, role2.Id})
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{parent.Id, role1.Id, role2.Id}, roleIds(actual))
+}
+
+func TestIT_RoleRepository_ListInherited_WhenHierarchyHasACycle_ExpectTerminates(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+	parent.Parent = &role.Id
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Update(context.Background(), tx, parent)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	actual, err := repo.ListInherited(context.Background(), []uuid.UUID{role.Id})
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{parent.Id, role.Id}, roleIds(actual))
+}
+
+func TestIT_RoleRepository_ListInherited_WhenNothingIsGiven_ExpectEmpty(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	insertTestRole(t, conn, nil)
+
+	actual, err := repo.ListInherited(context.Background(), nil)
+
+	assert.Nil(t, err)
+	assert.Empty(t, actual)
+}
+
+func TestIT_RoleRepository_Update(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, nil)
+	role.Description = "Updated role"
+	role.Parent = &parent.Id
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Update(context.Background(), tx, role)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, role.Version+1, actual.Version)
+	updated, err := repo.Get(context.Background(), role.Id)
+	require.Nil(t, err)
+	assert.Equal(t, "Updated role", updated.Description)
+	assert.Equal(t, &parent.Id, updated.Parent)
+	assert.Equal(t, role.Version+1, updated.Version)
+}
+
+func TestIT_RoleRepository_Update_WhenVersionIsWrong_ExpectOptimisticLockException(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+	role.Version = 3
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Update(context.Background(), tx, role)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, OptimisticLockException), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_Delete(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+
+	err := repo.Delete(context.Background(), role.Id)
+
+	assert.Nil(t, err)
+	assertRoleDoesNotExist(t, conn, role.Id)
+}
+
+func TestIT_RoleRepository_Delete_WhenRoleIsAParent_ExpectChildrenKept(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+
+	err := repo.Delete(context.Background(), parent.Id)
+
+	assert.Nil(t, err)
+	actual, err := repo.Get(context.Background(), role.Id)
+	require.Nil(t, err)
+	assert.Nil(t, actual.Parent)
+}
+
+func TestIT_RoleRepository_Delete_WhenNotFound_ExpectNoMatchingRows(t *testing.T) {
+	repo, _ := newTestRoleRepository(t)
+
+	err := repo.Delete(context.Background(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_SetPermissions(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+	permission1 := insertTestPermission(t, conn)
+	permission2 := insertTestPermission(t, conn)
+	permission3 := insertTestPermission(t, conn)
+	setTestRolePermissions(t, conn, role, permission1, permission2)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.SetPermissions(context.Background(), tx, role.Id, []uuid.UUID{permission2.Id, permission3.Id})
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	actual, err := repo.ListPermissions(context.Background(), []uuid.UUID{role.Id})
+	require.Nil(t, err)
+	expected := []persistence.RolePermission{
+		{Role: role.Id, Permission: permission2.Name},
+		{Role: role.Id, Permission: permission3.Name},
+	}
+	assert.ElementsMatch(t, expected, actual)
+}
+
+func TestIT_RoleRepository_SetPermissions_WhenPermissionDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.SetPermissions(context.Background(), tx, role.Id, []uuid.UUID{uuid.New()})
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.ForeignKeyValidation), "Actual err: %v", err)
+}
+
+func TestIT_RoleRepository_ListPermissions_ExpectInheritedPermissionsNotReturned(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	parent := insertTestRole(t, conn, nil)
+	role := insertTestRole(t, conn, &parent.Id)
+	permission1 := insertTestPermission(t, conn)
+	permission2 := insertTestPermission(t, conn)
+	setTestRolePermissions(t, conn, parent, permission1)
+	setTestRolePermissions(t, conn, role, permission2)
+
+	actual, err := repo.ListPermissions(context.Background(), []uuid.UUID{role.Id})
+
+	assert.Nil(t, err)
+	expected := []persistence.RolePermission{
+		{Role: role.Id, Permission: permission2.Name},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestIT_RoleRepository_ListPermissions_WhenPermissionIsDeleted_ExpectNotReturned(t *testing.T) {
+	repo, conn := newTestRoleRepository(t)
+	role := insertTestRole(t, conn, nil)
+	permission := insertTestPermission(t, conn)
+	setTestRolePermissions(t, conn, role, permission)
+	err := NewPermissionRepository(conn).Delete(context.Background(), permission.Id)
+	require.Nil(t, err)
+
+	actual, err := repo.ListPermissions(context.Background(), []uuid.UUID{role.Id})
+
+	assert.Nil(t, err)
+	assert.Empty(t, actual)
+}
+
+func newTestRoleRepository(t *testing.T) (RoleRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewRoleRepository(conn), conn
+}
+
+func assertRoleExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM role WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertRoleDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM role WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func roleIds(roles []persistence.Role) []uuid.UUID {
+	var out []uuid.UUID
+	for _, role := range roles {
+		out = append(out, role.Id)
+	}
+	return out
+}
+
+func newTestRole(parent *uuid.UUID) persistence.Role {
+	return persistence.Role{
+		Id:          uuid.New(),
+		Name:        "my-role-" + uuid.NewString(),
+		Description: "My role",
+		Parent:      parent,
+		CreatedAt:   time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC),
+	}
+}
+
+func insertTestRole(t *testing.T, conn db.Connection, parent *uuid.UUID) persistence.Role {
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	role, err := NewRoleRepository(conn).Create(context.Background(), tx, newTestRole(parent))
+	tx.Close(context.Background())
+	require.Nil(t, err)
+	return role
+}
+
+func setTestRolePermissions(t *testing.T, conn db.Connection, role persistence.Role, permissions ...persistence.Permission) {
+	var ids []uuid.UUID
+	for _, permission := range permissions {
+		ids = append(ids, permission.Id)
+	}
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = NewRoleRepository(conn).SetPermissions(context.Background(), tx, role.Id, ids)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+}
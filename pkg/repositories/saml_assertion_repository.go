@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type SamlAssertionRepository interface {
+	// Create fails with a unique constraint violation when the assertion
+	// was already recorded.
+	Create(ctx context.Context, tx db.Transaction, assertion persistence.SamlAssertion) (persistence.SamlAssertion, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type samlAssertionRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewSamlAssertionRepository(conn db.Connection) SamlAssertionRepository {
+	return &samlAssertionRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createSamlAssertionSqlTemplate = `
+INSERT INTO saml_assertion (id, issuer, assertion_id, valid_until)
+	VALUES($1, $2, $3, $4)`
+
+func (r *samlAssertionRepositoryImpl) Create(ctx context.Context, tx db.Transaction, assertion persistence.SamlAssertion) (persistence.SamlAssertion, error) {
+	_, err := tx.Exec(ctx, createSamlAssertionSqlTemplate, assertion.Id, assertion.Issuer, assertion.AssertionId, assertion.ValidUntil)
+	return assertion, err
+}
+
+const deleteExpiredSamlAssertionsSqlTemplate = `
+DELETE FROM
+	saml_assertion
+WHERE
+	valid_until < $1`
+
+func (r *samlAssertionRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredSamlAssertionsSqlTemplate, at)
+	return err
+}
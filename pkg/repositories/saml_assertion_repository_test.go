@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db/pgx"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_SamlAssertionRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestSamlAssertionRepositoryAndTransaction(t)
+	assertion := newTestSamlAssertion(time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Create(context.Background(), tx, assertion)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, assertion, actual)
+	assertSamlAssertionExists(t, conn, assertion.Id)
+}
+
+func TestIT_SamlAssertionRepository_Create_WhenAlreadyRecorded_ExpectFailure(t *testing.T) {
+	repo, conn := newTestSamlAssertionRepository(t)
+	assertion := insertTestSamlAssertion(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	replayed := newTestSamlAssertion(assertion.ValidUntil)
+	replayed.AssertionId = assertion.AssertionId
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, replayed)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, pgx.UniqueConstraintViolation), "Actual err: %v", err)
+}
+
+func TestIT_SamlAssertionRepository_Create_WhenIssuerDiffers_ExpectSuccess(t *testing.T) {
+	repo, conn := newTestSamlAssertionRepository(t)
+	assertion := insertTestSamlAssertion(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	other := newTestSamlAssertion(assertion.ValidUntil)
+	other.Issuer = "another-issuer"
+	other.AssertionId = assertion.AssertionId
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, other)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertSamlAssertionExists(t, conn, other.Id)
+}
+
+func TestIT_SamlAssertionRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestSamlAssertionRepository(t)
+	expired := insertTestSamlAssertion(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestSamlAssertion(t, conn, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertSamlAssertionDoesNotExist(t, conn, expired.Id)
+	assertSamlAssertionExists(t, conn, valid.Id)
+}
+
+func newTestSamlAssertionRepository(t *testing.T) (SamlAssertionRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewSamlAssertionRepository(conn), conn
+}
+
+func newTestSamlAssertionRepositoryAndTransaction(t *testing.T) (SamlAssertionRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewSamlAssertionRepository(conn), conn, tx
+}
+
+func assertSamlAssertionExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM saml_assertion WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertSamlAssertionDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM saml_assertion WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestSamlAssertion(validUntil time.Time) persistence.SamlAssertion {
+	return persistence.SamlAssertion{
+		Id:          uuid.New(),
+		Issuer:      "my-issuer",
+		AssertionId: "_my-assertion-" + uuid.NewString(),
+		ValidUntil:  validUntil,
+	}
+}
+
+func insertTestSamlAssertion(t *testing.T, conn db.Connection, validUntil time.Time) persistence.SamlAssertion {
+	assertion := newTestSamlAssertion(validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewSamlAssertionRepository(conn).Create(context.Background(), tx, assertion)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return assertion
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type SamlRequestRepository interface {
+	Create(ctx context.Context, tx db.Transaction, request persistence.SamlRequest) (persistence.SamlRequest, error)
+	Consume(ctx context.Context, tx db.Transaction, requestId string) (persistence.SamlRequest, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type samlRequestRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewSamlRequestRepository(conn db.Connection) SamlRequestRepository {
+	return &samlRequestRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createSamlRequestSqlTemplate = `
+INSERT INTO saml_request (id, request_id, relay_state_hash, tenant, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6)`
+
+func (r *samlRequestRepositoryImpl) Create(ctx context.Context, tx db.Transaction, request persistence.SamlRequest) (persistence.SamlRequest, error) {
+	_, err := tx.Exec(ctx, createSamlRequestSqlTemplate, request.Id, request.RequestId, request.RelayStateHash, request.Tenant, request.CreatedAt, request.ValidUntil)
+	return request, err
+}
+
+// Each request can only be answered once.
+const consumeSamlRequestSqlTemplate = `
+DELETE FROM
+	saml_request
+WHERE
+	request_id = $1
+RETURNING
+	id, request_id, relay_state_hash, tenant, created_at, valid_until`
+
+func (r *samlRequestRepositoryImpl) Consume(ctx context.Context, tx db.Transaction, requestId string) (persistence.SamlRequest, error) {
+	return db.QueryOneTx[persistence.SamlRequest](ctx, tx, consumeSamlRequestSqlTemplate, requestId)
+}
+
+const deleteExpiredSamlRequestsSqlTemplate = `
+DELETE FROM
+	saml_request
+WHERE
+	valid_until < $1`
+
+func (r *samlRequestRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredSamlRequestsSqlTemplate, at)
+	return err
+}
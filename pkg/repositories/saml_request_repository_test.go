@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_SamlRequestRepository_Create(t *testing.T) {
+	repo, conn, tx := newTestSamlRequestRepositoryAndTransaction(t)
+	request := newTestSamlRequest(time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Create(context.Background(), tx, request)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, request, actual)
+	assertSamlRequestExists(t, conn, request.Id)
+}
+
+func TestIT_SamlRequestRepository_Consume_ExpectRequestIsDeleted(t *testing.T) {
+	repo, conn := newTestSamlRequestRepository(t)
+	request := insertTestSamlRequest(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Consume(context.Background(), tx, request.RequestId)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, request, toUtcSamlRequest(actual))
+	assertSamlRequestDoesNotExist(t, conn, request.Id)
+}
+
+func TestIT_SamlRequestRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestSamlRequestRepository(t)
+	request := insertTestSamlRequest(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, request.RequestId)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	tx, err = conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Consume(context.Background(), tx, request.RequestId)
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_SamlRequestRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestSamlRequestRepository(t)
+	expired := insertTestSamlRequest(t, conn, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestSamlRequest(t, conn, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertSamlRequestDoesNotExist(t, conn, expired.Id)
+	assertSamlRequestExists(t, conn, valid.Id)
+}
+
+func newTestSamlRequestRepository(t *testing.T) (SamlRequestRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewSamlRequestRepository(conn), conn
+}
+
+func newTestSamlRequestRepositoryAndTransaction(t *testing.T) (SamlRequestRepository, db.Connection, db.Transaction) {
+	conn := newTestConnection(t)
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	return NewSamlRequestRepository(conn), conn, tx
+}
+
+func assertSamlRequestExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM saml_request WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertSamlRequestDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM saml_request WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestSamlRequest(validUntil time.Time) persistence.SamlRequest {
+	return persistence.SamlRequest{
+		Id:             uuid.New(),
+		RequestId:      "_my-request-" + uuid.NewString(),
+		RelayStateHash: "my-relay-state-hash",
+		Tenant:         "my-tenant",
+		CreatedAt:      time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil:     validUntil,
+	}
+}
+
+func insertTestSamlRequest(t *testing.T, conn db.Connection, validUntil time.Time) persistence.SamlRequest {
+	request := newTestSamlRequest(validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewSamlRequestRepository(conn).Create(context.Background(), tx, request)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return request
+}
+
+func toUtcSamlRequest(request persistence.SamlRequest) persistence.SamlRequest {
+	request.CreatedAt = request.CreatedAt.UTC()
+	request.ValidUntil = request.ValidUntil.UTC()
+	return request
+}
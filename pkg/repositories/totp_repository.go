@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type TotpRepository interface {
+	Create(ctx context.Context, secret persistence.TotpSecret) (persistence.TotpSecret, error)
+	Get(ctx context.Context, user uuid.UUID) (persistence.TotpSecret, error)
+	Confirm(ctx context.Context, user uuid.UUID, counter int64, at time.Time) error
+	UseCounter(ctx context.Context, user uuid.UUID, counter int64) error
+	Delete(ctx context.Context, user uuid.UUID) error
+}
+
+type totpRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewTotpRepository(conn db.Connection) TotpRepository {
+	return &totpRepositoryImpl{
+		conn: conn,
+	}
+}
+
+// A secret which was not confirmed yet is replaced: the user may have lost
+// it before scanning it. A confirmed one is kept.
+const createTotpSecretSqlTemplate = `
+INSERT INTO totp_secret (api_user, secret, last_counter, created_at)
+	VALUES($1, $2, $3, $4)
+	ON CONFLICT (api_user) DO UPDATE
+	SET
+		secret = excluded.secret,
+		last_counter = excluded.last_counter,
+		created_at = excluded.created_at
+	WHERE
+		totp_secret.confirmed_at IS NULL
+	RETURNING
+		api_user, secret, last_counter, created_at, confirmed_at`
+
+func (r *totpRepositoryImpl) Create(ctx context.Context, secret persistence.TotpSecret) (persistence.TotpSecret, error) {
+	return db.QueryOne[persistence.TotpSecret](ctx, r.conn, createTotpSecretSqlTemplate, secret.ApiUser, secret.Secret, secret.LastCounter, secret.CreatedAt)
+}
+
+const getTotpSecretSqlTemplate = `
+SELECT
+	api_user, secret, last_counter, created_at, confirmed_at
+FROM
+	totp_secret
+WHERE
+	api_user = $1`
+
+func (r *totpRepositoryImpl) Get(ctx context.Context, user uuid.UUID) (persistence.TotpSecret, error) {
+	return db.QueryOne[persistence.TotpSecret](ctx, r.conn, getTotpSecretSqlTemplate, user)
+}
+
+const confirmTotpSecretSqlTemplate = `
+UPDATE
+	totp_secret
+SET
+	last_counter = $1,
+	confirmed_at = $2
+WHERE
+	api_user = $3
+	AND confirmed_at IS NULL
+	AND last_counter < $1
+RETURNING
+	api_user`
+
+func (r *totpRepositoryImpl) Confirm(ctx context.Context, user uuid.UUID, counter int64, at time.Time) error {
+	return r.updateCounter(ctx, confirmTotpSecretSqlTemplate, counter, at, user)
+}
+
+// The counter only moves forward: when the same code is used concurrently,
+// only one of the attempts succeeds.
+const useTotpCounterSqlTemplate = `
+UPDATE
+	totp_secret
+SET
+	last_counter = $1
+WHERE
+	api_user = $2
+	AND last_counter < $1
+RETURNING
+	api_user`
+
+func (r *totpRepositoryImpl) UseCounter(ctx context.Context, user uuid.UUID, counter int64) error {
+	return r.updateCounter(ctx, useTotpCounterSqlTemplate, counter, user)
+}
+
+const deleteTotpSecretSqlTemplate = `
+DELETE FROM
+	totp_secret
+WHERE
+	api_user = $1`
+
+func (r *totpRepositoryImpl) Delete(ctx context.Context, user uuid.UUID) error {
+	_, err := r.conn.Exec(ctx, deleteTotpSecretSqlTemplate, user)
+	return err
+}
+
+func (r *totpRepositoryImpl) updateCounter(ctx context.Context, sql string, arguments ...any) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, sql, arguments...)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return errors.NewCode(OptimisticLockException)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_TotpRepository_Create(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	secret := newTestTotpSecret(user.Id)
+
+	actual, err := repo.Create(context.Background(), secret)
+
+	assert.Nil(t, err)
+	assert.Equal(t, secret, toUtcTotpSecret(actual))
+}
+
+func TestIT_TotpRepository_Create_WhenUserDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _ := newTestTotpRepository(t)
+
+	_, err := repo.Create(context.Background(), newTestTotpSecret(uuid.New()))
+
+	assert.NotNil(t, err)
+}
+
+func TestIT_TotpRepository_Create_WhenNotConfirmed_ExpectSecretIsReplaced(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+	secret := newTestTotpSecret(user.Id)
+	secret.Secret = []byte("another-secret")
+
+	_, err := repo.Create(context.Background(), secret)
+	require.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("another-secret"), actual.Secret)
+}
+
+func TestIT_TotpRepository_Create_WhenConfirmed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+	require.Nil(t, repo.Confirm(context.Background(), user.Id, 12, time.Now()))
+
+	_, err := repo.Create(context.Background(), newTestTotpSecret(user.Id))
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_TotpRepository_Get_WhenSecretDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _ := newTestTotpRepository(t)
+
+	_, err := repo.Get(context.Background(), uuid.New())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_TotpRepository_Confirm(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+	at := time.Date(2024, 11, 12, 16, 40, 20, 0, time.UTC)
+
+	err := repo.Confirm(context.Background(), user.Id, 12, at)
+	require.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), actual.LastCounter)
+	require.NotNil(t, actual.ConfirmedAt)
+	assert.Equal(t, at, actual.ConfirmedAt.UTC())
+}
+
+func TestIT_TotpRepository_Confirm_WhenAlreadyConfirmed_ExpectOptimisticLockException(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+	require.Nil(t, repo.Confirm(context.Background(), user.Id, 12, time.Now()))
+
+	err := repo.Confirm(context.Background(), user.Id, 13, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, OptimisticLockException), "Actual err: %v", err)
+}
+
+func TestIT_TotpRepository_UseCounter(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+
+	err := repo.UseCounter(context.Background(), user.Id, 12)
+	require.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), actual.LastCounter)
+}
+
+func TestIT_TotpRepository_UseCounter_WhenCounterWasUsed_ExpectOptimisticLockException(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+	require.Nil(t, repo.UseCounter(context.Background(), user.Id, 12))
+
+	err := repo.UseCounter(context.Background(), user.Id, 12)
+
+	assert.True(t, errors.IsErrorWithCode(err, OptimisticLockException), "Actual err: %v", err)
+}
+
+func TestIT_TotpRepository_Delete(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+
+	err := repo.Delete(context.Background(), user.Id)
+	require.Nil(t, err)
+
+	_, err = repo.Get(context.Background(), user.Id)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_TotpRepository_WhenUserIsDeleted_ExpectSecretIsDeleted(t *testing.T) {
+	repo, conn := newTestTotpRepository(t)
+	user := insertTestUser(t, conn)
+	insertTestTotpSecret(t, conn, user.Id)
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	_, err = repo.Get(context.Background(), user.Id)
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func newTestTotpRepository(t *testing.T) (TotpRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewTotpRepository(conn), conn
+}
+
+func newTestTotpSecret(user uuid.UUID) persistence.TotpSecret {
+	return persistence.TotpSecret{
+		ApiUser:     user,
+		Secret:      []byte("my-encrypted-secret"),
+		LastCounter: 0,
+		CreatedAt:   time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+	}
+}
+
+func insertTestTotpSecret(t *testing.T, conn db.Connection, user uuid.UUID) persistence.TotpSecret {
+	secret, err := NewTotpRepository(conn).Create(context.Background(), newTestTotpSecret(user))
+	require.Nil(t, err)
+	return secret
+}
+
+func toUtcTotpSecret(secret persistence.TotpSecret) persistence.TotpSecret {
+	secret.CreatedAt = secret.CreatedAt.UTC()
+	return secret
+}
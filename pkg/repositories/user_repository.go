@@ -13,9 +13,17 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user persistence.User) (persistence.User, error)
 	Get(ctx context.Context, id uuid.UUID) (persistence.User, error)
+	// Lock returns the user and prevents any change to it until the end of
+	// the transaction.
+	Lock(ctx context.Context, tx db.Transaction, id uuid.UUID) (persistence.User, error)
 	GetByEmail(ctx context.Context, email string) (persistence.User, error)
 	List(ctx context.Context) ([]uuid.UUID, error)
 	Update(ctx context.Context, user persistence.User) (persistence.User, error)
+	UpdatePasswordHash(ctx context.Context, id uuid.UUID, previous string, hash string) error
+	RequirePasswordChange(ctx context.Context, id uuid.UUID) error
+	VerifyEmail(ctx context.Context, id uuid.UUID, email string, at time.Time) error
+	ResetPassword(ctx context.Context, tx db.Transaction, id uuid.UUID, email string, hash string, at time.Time) error
+	ChangePassword(ctx context.Context, tx db.Transaction, id uuid.UUID, previous string, hash string) error
 	Delete(ctx context.Context, tx db.Transaction, id uuid.UUID) error
 }
 
@@ -30,19 +38,19 @@ func NewUserRepository(conn db.Connection) UserRepository {
 }
 
 const createUserSqlTemplate = `
-INSERT INTO api_user (id, email, password, created_at)
-	VALUES($1, $2, $3, $4)
+INSERT INTO api_user (id, email, password, email_verified_at, created_at)
+	VALUES($1, $2, $3, $4, $5)
 	RETURNING updated_at`
 
 func (r *userRepositoryImpl) Create(ctx context.Context, user persistence.User) (persistence.User, error) {
-	updatedAt, err := db.QueryOne[time.Time](ctx, r.conn, createUserSqlTemplate, user.Id, user.Email, user.Password, user.CreatedAt)
+	updatedAt, err := db.QueryOne[time.Time](ctx, r.conn, createUserSqlTemplate, user.Id, user.Email, user.Password, user.EmailVerifiedAt, user.CreatedAt)
 	user.UpdatedAt = updatedAt
 	return user, err
 }
 
 const getUserSqlTemplate = `
 SELECT
-	id, email, password, created_at, updated_at, version
+	id, email, password, password_change_required, email_verified_at, created_at, updated_at, version
 FROM
 	api_user
 WHERE
@@ -52,9 +60,22 @@ func (r *userRepositoryImpl) Get(ctx context.Context, id uuid.UUID) (persistence
 	return db.QueryOne[persistence.User](ctx, r.conn, getUserSqlTemplate, id)
 }
 
+const lockUserForUpdateSqlTemplate = `
+SELECT
+	id, email, password, password_change_required, email_verified_at, created_at, updated_at, version
+FROM
+	api_user
+WHERE
+	id = $1
+FOR UPDATE`
+
+func (r *userRepositoryImpl) Lock(ctx context.Context, tx db.Transaction, id uuid.UUID) (persistence.User, error) {
+	return db.QueryOneTx[persistence.User](ctx, tx, lockUserForUpdateSqlTemplate, id)
+}
+
 const getUserByEmailSqlTemplate = `
 SELECT
-	id, email, password, created_at, updated_at, version
+	id, email, password, password_change_required, email_verified_at, created_at, updated_at, version
 FROM
 	api_user
 WHERE
@@ -74,12 +95,14 @@ func (r *userRepositoryImpl) List(ctx context.Context) ([]uuid.UUID, error) {
 	return db.QueryAll[uuid.UUID](ctx, r.conn, listUserSqlTemplate)
 }
 
+// The password is not part of the update: it can only be changed with the
+// dedicated queries below.
 const updateUserSqlTemplate = `
 UPDATE
 	api_user
 SET
 	email = $1,
-	password = $2,
+	email_verified_at = $2,
 	version = $3
 WHERE
 	id = $4
@@ -90,7 +113,7 @@ RETURNING
 func (r *userRepositoryImpl) Update(ctx context.Context, user persistence.User) (persistence.User, error) {
 	version := user.Version + 1
 
-	updatedAt, err := db.QueryOne[time.Time](ctx, r.conn, updateUserSqlTemplate, user.Email, user.Password, version, user.Id, user.Version)
+	updatedAt, err := db.QueryOne[time.Time](ctx, r.conn, updateUserSqlTemplate, user.Email, user.EmailVerifiedAt, version, user.Id, user.Version)
 	if err != nil {
 		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
 			return user, errors.NewCode(OptimisticLockException)
@@ -104,6 +127,104 @@ func (r *userRepositoryImpl) Update(ctx context.Context, user persistence.User)
 	return user, nil
 }
 
+// The version is voluntarily not bumped: replacing the hash of a password
+// does not change it from the point of view of the user.
+const updateUserPasswordHashSqlTemplate = `
+UPDATE
+	api_user
+SET
+	password = $1
+WHERE
+	id = $2
+	AND password = $3`
+
+func (r *userRepositoryImpl) UpdatePasswordHash(ctx context.Context, id uuid.UUID, previous string, hash string) error {
+	_, err := r.conn.Exec(ctx, updateUserPasswordHashSqlTemplate, hash, id, previous)
+	return err
+}
+
+// Like the hash of the password, the flag is not part of what the user
+// changes: the version is not bumped.
+const requirePasswordChangeSqlTemplate = `
+UPDATE
+	api_user
+SET
+	password_change_required = true
+WHERE
+	id = $1`
+
+func (r *userRepositoryImpl) RequirePasswordChange(ctx context.Context, id uuid.UUID) error {
+	_, err := r.conn.Exec(ctx, requirePasswordChangeSqlTemplate, id)
+	return err
+}
+
+// The email is checked so that a verification sent to a previous address of
+// the user does not apply to the current one.
+const verifyUserEmailSqlTemplate = `
+UPDATE
+	api_user
+SET
+	email_verified_at = $1
+WHERE
+	id = $2
+	AND email = $3
+RETURNING
+	id`
+
+func (r *userRepositoryImpl) VerifyEmail(ctx context.Context, id uuid.UUID, email string, at time.Time) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, verifyUserEmailSqlTemplate, at, id, email)
+	return err
+}
+
+// The reset link was sent to the email of the user: following it also
+// verifies the email when it was not already.
+const resetUserPasswordSqlTemplate = `
+UPDATE
+	api_user
+SET
+	password = $1,
+	password_change_required = false,
+	email_verified_at = COALESCE(email_verified_at, $2),
+	version = version + 1
+WHERE
+	id = $3
+	AND email = $4
+RETURNING
+	id`
+
+func (r *userRepositoryImpl) ResetPassword(ctx context.Context, tx db.Transaction, id uuid.UUID, email string, hash string, at time.Time) error {
+	_, err := db.QueryOneTx[uuid.UUID](ctx, tx, resetUserPasswordSqlTemplate, hash, at, id, email)
+	return err
+}
+
+// The previous hash is checked so that the password is not changed twice
+// concurrently: the second change would not have been verified against the
+// current password.
+const changeUserPasswordSqlTemplate = `
+UPDATE
+	api_user
+SET
+	password = $1,
+	password_change_required = false,
+	version = version + 1
+WHERE
+	id = $2
+	AND password = $3
+RETURNING
+	id`
+
+func (r *userRepositoryImpl) ChangePassword(ctx context.Context, tx db.Transaction, id uuid.UUID, previous string, hash string) error {
+	_, err := db.QueryOneTx[uuid.UUID](ctx, tx, changeUserPasswordSqlTemplate, hash, id, previous)
+	if err != nil {
+		if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+			return errors.NewCode(OptimisticLockException)
+		}
+		return err
+	}
+
+	return nil
+}
+
 const deleteUserSqlTemplate = `
 DELETE FROM
 	api_user
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,6 +56,24 @@ func TestIT_UserRepository_Create_WhenDuplicateName_ExpectFailure(t *testing.T)
 	assertUserDoesNotExist(t, conn, newUser.Id)
 }
 
+func TestIT_UserRepository_Create_WhenDuplicateNameWithDifferentCase_ExpectSuccess(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	newUser := persistence.User{
+		Id:        uuid.New(),
+		Email:     strings.ToUpper(user.Email),
+		Password:  "my-password",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	_, err := repo.Create(context.Background(), newUser)
+
+	assert.Nil(t, err)
+	assertUserExists(t, conn, newUser.Id)
+}
+
 func TestIT_UserRepository_Get(t *testing.T) {
 	repo, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
@@ -73,6 +93,26 @@ func TestIT_UserRepository_Get_WhenNotFound_ExpectFailure(t *testing.T) {
 	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
 }
 
+func TestIT_UserRepository_Lock(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+
+	actual, err := repo.Lock(context.Background(), tx, user.Id)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.True(t, eassert.EqualsIgnoringFields(actual, user))
+}
+
+func TestIT_UserRepository_Lock_WhenNotFound_ExpectFailure(t *testing.T) {
+	repo, _, tx := newTestUserRepositoryAndTransaction(t)
+
+	_, err := repo.Lock(context.Background(), tx, uuid.New())
+	tx.Close(context.Background())
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
 func TestIT_UserRepository_GetByEmail(t *testing.T) {
 	repo, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
@@ -83,6 +123,14 @@ func TestIT_UserRepository_GetByEmail(t *testing.T) {
 	assert.True(t, eassert.EqualsIgnoringFields(actual, user))
 }
 
+func TestIT_UserRepository_GetByEmail_WhenCaseDiffers_ExpectFailure(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	_, err := repo.GetByEmail(context.Background(), strings.ToUpper(user.Email))
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
 func TestIT_UserRepository_GetByEmail_WhenNotFound_ExpectFailure(t *testing.T) {
 	repo, _ := newTestUserRepository(t)
 
@@ -109,7 +157,7 @@ func TestIT_UserRepository_Update(t *testing.T) {
 	user := insertTestUser(t, conn)
 
 	updatedUser := user
-	updatedUser.Password = "my-new-password"
+	updatedUser.Email = fmt.Sprintf("my-new-email-%s@example.com", uuid.NewString())
 
 	actual, err := repo.Update(context.Background(), updatedUser)
 
@@ -118,7 +166,7 @@ func TestIT_UserRepository_Update(t *testing.T) {
 	expected := persistence.User{
 		Id:        user.Id,
 		Email:     updatedUser.Email,
-		Password:  "my-new-password",
+		Password:  user.Password,
 		CreatedAt: user.CreatedAt,
 		Version:   user.Version + 1,
 	}
@@ -126,6 +174,24 @@ func TestIT_UserRepository_Update(t *testing.T) {
 	assert.True(t, actual.UpdatedAt.After(user.UpdatedAt))
 }
 
+func TestIT_UserRepository_Update_ExpectPasswordUnchanged(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	err := repo.RequirePasswordChange(context.Background(), user.Id)
+	require.Nil(t, err)
+
+	updatedUser := user
+	updatedUser.Password = "my-new-password"
+
+	_, err = repo.Update(context.Background(), updatedUser)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, user.Password, actual.Password)
+	assert.True(t, actual.PasswordChangeRequired)
+}
+
 func TestIT_UserRepository_Update_WhenNameAlreadyExists_ExpectFailure(t *testing.T) {
 	repo, conn := newTestUserRepository(t)
 	user := insertTestUser(t, conn)
@@ -145,7 +211,6 @@ func TestIT_UserRepository_Update_WhenVersionIsWrong_ExpectOptimisticLockExcepti
 	user := insertTestUser(t, conn)
 
 	updatedUser := user
-	updatedUser.Password = "my-new-password"
 	updatedUser.Version = user.Version + 2
 
 	_, err := repo.Update(context.Background(), updatedUser)
@@ -159,7 +224,6 @@ func TestIT_UserRepository_Update_BumpsUpdatedAt(t *testing.T) {
 	user := insertTestUser(t, conn)
 
 	updatedUser := user
-	updatedUser.Password = "my-new-password"
 
 	_, err := repo.Update(context.Background(), updatedUser)
 	assert.Nil(t, err)
@@ -175,7 +239,6 @@ func TestIT_UserRepository_Update_BumpsVersion(t *testing.T) {
 	user := insertTestUser(t, conn)
 
 	updatedUser := user
-	updatedUser.Password = "my-new-password"
 
 	_, err := repo.Update(context.Background(), updatedUser)
 	assert.Nil(t, err)
@@ -185,6 +248,170 @@ func TestIT_UserRepository_Update_BumpsVersion(t *testing.T) {
 	assert.Equal(t, user.Version+1, updatedUserFromDb.Version)
 }
 
+func TestIT_UserRepository_UpdatePasswordHash(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.UpdatePasswordHash(context.Background(), user.Id, user.Password, "my-hash")
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-hash", actual.Password)
+	assert.Equal(t, user.Version, actual.Version)
+}
+
+func TestIT_UserRepository_UpdatePasswordHash_WhenPasswordChangedConcurrently_ExpectNotUpdated(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.UpdatePasswordHash(context.Background(), user.Id, "not-the-current-password", "my-hash")
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, user.Password, actual.Password)
+}
+
+func TestIT_UserRepository_RequirePasswordChange(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.RequirePasswordChange(context.Background(), user.Id)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.True(t, actual.PasswordChangeRequired)
+	assert.Equal(t, user.Version, actual.Version)
+}
+
+func TestIT_UserRepository_Update_ClearsPasswordChangeRequired(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	err := repo.RequirePasswordChange(context.Background(), user.Id)
+	require.Nil(t, err)
+
+	updatedUser := user
+	updatedUser.Password = "my-new-password"
+	updatedUser.PasswordChangeRequired = true
+
+	actual, err := repo.Update(context.Background(), updatedUser)
+	assert.Nil(t, err)
+	assert.False(t, actual.PasswordChangeRequired)
+
+	userFromDb, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.False(t, userFromDb.PasswordChangeRequired)
+}
+
+func TestIT_UserRepository_VerifyEmail(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+	verifiedAt := time.Date(2024, 11, 12, 16, 40, 20, 0, time.UTC)
+
+	err := repo.VerifyEmail(context.Background(), user.Id, user.Email, verifiedAt)
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	require.NotNil(t, actual.EmailVerifiedAt)
+	assert.Equal(t, verifiedAt, actual.EmailVerifiedAt.UTC())
+	assert.Equal(t, user.Version, actual.Version)
+}
+
+func TestIT_UserRepository_VerifyEmail_WhenEmailChanged_ExpectFailure(t *testing.T) {
+	repo, conn := newTestUserRepository(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.VerifyEmail(context.Background(), user.Id, "previous-"+user.Email, time.Now())
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Nil(t, actual.EmailVerifiedAt)
+}
+
+func TestIT_UserRepository_ResetPassword(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+	err := repo.RequirePasswordChange(context.Background(), user.Id)
+	require.Nil(t, err)
+	resetAt := time.Date(2024, 11, 12, 16, 40, 20, 0, time.UTC)
+
+	err = repo.ResetPassword(context.Background(), tx, user.Id, user.Email, "my-hash", resetAt)
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-hash", actual.Password)
+	assert.False(t, actual.PasswordChangeRequired)
+	require.NotNil(t, actual.EmailVerifiedAt)
+	assert.Equal(t, resetAt, actual.EmailVerifiedAt.UTC())
+	assert.Equal(t, user.Version+1, actual.Version)
+}
+
+func TestIT_UserRepository_ResetPassword_WhenEmailIsVerified_ExpectVerificationIsKept(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+	verifiedAt := time.Date(2024, 11, 12, 16, 30, 20, 0, time.UTC)
+	err := repo.VerifyEmail(context.Background(), user.Id, user.Email, verifiedAt)
+	require.Nil(t, err)
+
+	err = repo.ResetPassword(context.Background(), tx, user.Id, user.Email, "my-hash", time.Now())
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	require.NotNil(t, actual.EmailVerifiedAt)
+	assert.Equal(t, verifiedAt, actual.EmailVerifiedAt.UTC())
+}
+
+func TestIT_UserRepository_ResetPassword_WhenEmailChanged_ExpectFailure(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.ResetPassword(context.Background(), tx, user.Id, "previous-"+user.Email, "my-hash", time.Now())
+	tx.Close(context.Background())
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, user.Password, actual.Password)
+}
+
+func TestIT_UserRepository_ChangePassword(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+	err := repo.RequirePasswordChange(context.Background(), user.Id)
+	require.Nil(t, err)
+
+	err = repo.ChangePassword(context.Background(), tx, user.Id, user.Password, "my-hash")
+	tx.Close(context.Background())
+	assert.Nil(t, err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "my-hash", actual.Password)
+	assert.False(t, actual.PasswordChangeRequired)
+	assert.Equal(t, user.Version+1, actual.Version)
+}
+
+func TestIT_UserRepository_ChangePassword_WhenPasswordChangedConcurrently_ExpectOptimisticLockException(t *testing.T) {
+	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
+	user := insertTestUser(t, conn)
+
+	err := repo.ChangePassword(context.Background(), tx, user.Id, "not-the-current-hash", "my-hash")
+	tx.Close(context.Background())
+	assert.True(t, errors.IsErrorWithCode(err, OptimisticLockException), "Actual err: %v", err)
+
+	actual, err := repo.Get(context.Background(), user.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, user.Password, actual.Password)
+}
+
 func TestIT_UserRepository_Delete(t *testing.T) {
 	repo, conn, tx := newTestUserRepositoryAndTransaction(t)
 
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+)
+
+type WebAuthnCeremonyRepository interface {
+	Create(ctx context.Context, tx db.Transaction, ceremony persistence.WebAuthnCeremony) (persistence.WebAuthnCeremony, error)
+	Consume(ctx context.Context, challengeHashes []string, kind persistence.WebAuthnCeremonyKind) (persistence.WebAuthnCeremony, error)
+	DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error
+}
+
+type webAuthnCeremonyRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewWebAuthnCeremonyRepository(conn db.Connection) WebAuthnCeremonyRepository {
+	return &webAuthnCeremonyRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createWebAuthnCeremonySqlTemplate = `
+INSERT INTO webauthn_ceremony (id, challenge_hash, kind, api_user, created_at, valid_until)
+	VALUES($1, $2, $3, $4, $5, $6)`
+
+func (r *webAuthnCeremonyRepositoryImpl) Create(ctx context.Context, tx db.Transaction, ceremony persistence.WebAuthnCeremony) (persistence.WebAuthnCeremony, error) {
+	_, err := tx.Exec(ctx, createWebAuthnCeremonySqlTemplate, ceremony.Id, ceremony.ChallengeHash, ceremony.Kind, ceremony.ApiUser, ceremony.CreatedAt, ceremony.ValidUntil)
+	return ceremony, err
+}
+
+// Each challenge is only answered once, whatever the outcome: the browser
+// has to start over after a failure.
+const consumeWebAuthnCeremonySqlTemplate = `
+DELETE FROM
+	webauthn_ceremony
+WHERE
+	challenge_hash = ANY($1)
+	AND kind = $2
+RETURNING
+	id, challenge_hash, kind, api_user, created_at, valid_until`
+
+func (r *webAuthnCeremonyRepositoryImpl) Consume(ctx context.Context, challengeHashes []string, kind persistence.WebAuthnCeremonyKind) (persistence.WebAuthnCeremony, error) {
+	return db.QueryOne[persistence.WebAuthnCeremony](ctx, r.conn, consumeWebAuthnCeremonySqlTemplate, challengeHashes, kind)
+}
+
+const deleteExpiredWebAuthnCeremoniesSqlTemplate = `
+DELETE FROM
+	webauthn_ceremony
+WHERE
+	valid_until < $1`
+
+func (r *webAuthnCeremonyRepositoryImpl) DeleteExpired(ctx context.Context, tx db.Transaction, at time.Time) error {
+	_, err := tx.Exec(ctx, deleteExpiredWebAuthnCeremoniesSqlTemplate, at)
+	return err
+}
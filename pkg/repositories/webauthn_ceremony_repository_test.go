@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_WebAuthnCeremonyRepository_Create(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	user := insertTestUser(t, conn)
+	ceremony := newTestWebAuthnCeremony(&user.Id, persistence.WebAuthnRegistration, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	actual, err := repo.Create(context.Background(), tx, ceremony)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, ceremony, actual)
+	assertWebAuthnCeremonyExists(t, conn, ceremony.Id)
+}
+
+func TestIT_WebAuthnCeremonyRepository_Create_WithoutUser(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	ceremony := newTestWebAuthnCeremony(nil, persistence.WebAuthnLogin, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = repo.Create(context.Background(), tx, ceremony)
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertWebAuthnCeremonyExists(t, conn, ceremony.Id)
+}
+
+func TestIT_WebAuthnCeremonyRepository_Consume_ExpectCeremonyIsDeleted(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	user := insertTestUser(t, conn)
+	ceremony := insertTestWebAuthnCeremony(t, conn, &user.Id, persistence.WebAuthnRegistration, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	actual, err := repo.Consume(context.Background(), []string{"not-a-challenge-hash", ceremony.ChallengeHash}, persistence.WebAuthnRegistration)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ceremony, toUtcWebAuthnCeremony(actual))
+	assertWebAuthnCeremonyDoesNotExist(t, conn, ceremony.Id)
+}
+
+func TestIT_WebAuthnCeremonyRepository_Consume_WhenKindDoesNotMatch_ExpectFailure(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	user := insertTestUser(t, conn)
+	ceremony := insertTestWebAuthnCeremony(t, conn, &user.Id, persistence.WebAuthnRegistration, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+
+	_, err := repo.Consume(context.Background(), []string{ceremony.ChallengeHash}, persistence.WebAuthnLogin)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	assertWebAuthnCeremonyExists(t, conn, ceremony.Id)
+}
+
+func TestIT_WebAuthnCeremonyRepository_Consume_WhenAlreadyConsumed_ExpectFailure(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	ceremony := insertTestWebAuthnCeremony(t, conn, nil, persistence.WebAuthnLogin, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	_, err := repo.Consume(context.Background(), []string{ceremony.ChallengeHash}, persistence.WebAuthnLogin)
+	require.Nil(t, err)
+
+	_, err = repo.Consume(context.Background(), []string{ceremony.ChallengeHash}, persistence.WebAuthnLogin)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnCeremonyRepository_DeleteExpired(t *testing.T) {
+	repo, conn := newTestWebAuthnCeremonyRepository(t)
+	expired := insertTestWebAuthnCeremony(t, conn, nil, persistence.WebAuthnLogin, time.Date(2024, 11, 12, 16, 33, 20, 0, time.UTC))
+	valid := insertTestWebAuthnCeremony(t, conn, nil, persistence.WebAuthnLogin, time.Date(2024, 11, 12, 16, 35, 20, 0, time.UTC))
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	err = repo.DeleteExpired(context.Background(), tx, time.Date(2024, 11, 12, 16, 34, 20, 0, time.UTC))
+	tx.Close(context.Background())
+
+	assert.Nil(t, err)
+	assertWebAuthnCeremonyDoesNotExist(t, conn, expired.Id)
+	assertWebAuthnCeremonyExists(t, conn, valid.Id)
+}
+
+func newTestWebAuthnCeremonyRepository(t *testing.T) (WebAuthnCeremonyRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewWebAuthnCeremonyRepository(conn), conn
+}
+
+func assertWebAuthnCeremonyExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM webauthn_ceremony WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertWebAuthnCeremonyDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM webauthn_ceremony WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestWebAuthnCeremony(user *uuid.UUID, kind persistence.WebAuthnCeremonyKind, validUntil time.Time) persistence.WebAuthnCeremony {
+	return persistence.WebAuthnCeremony{
+		Id:            uuid.New(),
+		ChallengeHash: "my-challenge-hash-" + uuid.NewString(),
+		Kind:          kind,
+		ApiUser:       user,
+		CreatedAt:     time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+		ValidUntil:    validUntil,
+	}
+}
+
+func insertTestWebAuthnCeremony(t *testing.T, conn db.Connection, user *uuid.UUID, kind persistence.WebAuthnCeremonyKind, validUntil time.Time) persistence.WebAuthnCeremony {
+	ceremony := newTestWebAuthnCeremony(user, kind, validUntil)
+
+	tx, err := conn.BeginTx(context.Background())
+	require.Nil(t, err)
+	_, err = NewWebAuthnCeremonyRepository(conn).Create(context.Background(), tx, ceremony)
+	tx.Close(context.Background())
+	require.Nil(t, err)
+
+	return ceremony
+}
+
+func toUtcWebAuthnCeremony(ceremony persistence.WebAuthnCeremony) persistence.WebAuthnCeremony {
+	ceremony.CreatedAt = ceremony.CreatedAt.UTC()
+	ceremony.ValidUntil = ceremony.ValidUntil.UTC()
+	return ceremony
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+)
+
+type WebAuthnCredentialRepository interface {
+	Create(ctx context.Context, credential persistence.WebAuthnCredential) (persistence.WebAuthnCredential, error)
+	Get(ctx context.Context, credentialId []byte) (persistence.WebAuthnCredential, error)
+	List(ctx context.Context, user uuid.UUID) ([]persistence.WebAuthnCredential, error)
+	Use(ctx context.Context, id uuid.UUID, previousSignCount int64, signCount int64, at time.Time) error
+	Delete(ctx context.Context, user uuid.UUID, id uuid.UUID) error
+}
+
+type webAuthnCredentialRepositoryImpl struct {
+	conn db.Connection
+}
+
+func NewWebAuthnCredentialRepository(conn db.Connection) WebAuthnCredentialRepository {
+	return &webAuthnCredentialRepositoryImpl{
+		conn: conn,
+	}
+}
+
+const createWebAuthnCredentialSqlTemplate = `
+INSERT INTO webauthn_credential (id, api_user, credential_id, public_key, sign_count, transports, aaguid, name, backup_eligible, created_at)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+func (r *webAuthnCredentialRepositoryImpl) Create(ctx context.Context, credential persistence.WebAuthnCredential) (persistence.WebAuthnCredential, error) {
+	_, err := r.conn.Exec(
+		ctx,
+		createWebAuthnCredentialSqlTemplate,
+		credential.Id,
+		credential.ApiUser,
+		credential.CredentialId,
+		credential.PublicKey,
+		credential.SignCount,
+		credential.Transports,
+		credential.Aaguid,
+		credential.Name,
+		credential.BackupEligible,
+		credential.CreatedAt,
+	)
+	return credential, err
+}
+
+const getWebAuthnCredentialSqlTemplate = `
+SELECT
+	id, api_user, credential_id, public_key, sign_count, transports, aaguid, name, backup_eligible, created_at, last_used_at
+FROM
+	webauthn_credential
+WHERE
+	credential_id = $1`
+
+func (r *webAuthnCredentialRepositoryImpl) Get(ctx context.Context, credentialId []byte) (persistence.WebAuthnCredential, error) {
+	return db.QueryOne[persistence.WebAuthnCredential](ctx, r.conn, getWebAuthnCredentialSqlTemplate, credentialId)
+}
+
+const listWebAuthnCredentialsSqlTemplate = `
+SELECT
+	id, api_user, credential_id, public_key, sign_count, transports, aaguid, name, backup_eligible, created_at, last_used_at
+FROM
+	webauthn_credential
+WHERE
+	api_user = $1
+ORDER BY
+	created_at`
+
+func (r *webAuthnCredentialRepositoryImpl) List(ctx context.Context, user uuid.UUID) ([]persistence.WebAuthnCredential, error) {
+	return db.QueryAll[persistence.WebAuthnCredential](ctx, r.conn, listWebAuthnCredentialsSqlTemplate, user)
+}
+
+// The sign count is compared with the one the assertion was verified
+// against: a concurrent use of the same credential makes this one fail.
+const useWebAuthnCredentialSqlTemplate = `
+UPDATE
+	webauthn_credential
+SET
+	sign_count = $1,
+	last_used_at = $2
+WHERE
+	id = $3
+	AND sign_count = $4
+RETURNING
+	id`
+
+func (r *webAuthnCredentialRepositoryImpl) Use(ctx context.Context, id uuid.UUID, previousSignCount int64, signCount int64, at time.Time) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, useWebAuthnCredentialSqlTemplate, signCount, at, id, previousSignCount)
+	if errors.IsErrorWithCode(err, db.NoMatchingRows) {
+		return errors.NewCode(OptimisticLockException)
+	}
+	return err
+}
+
+const deleteWebAuthnCredentialSqlTemplate = `
+DELETE FROM
+	webauthn_credential
+WHERE
+	id = $1
+	AND api_user = $2
+RETURNING
+	id`
+
+func (r *webAuthnCredentialRepositoryImpl) Delete(ctx context.Context, user uuid.UUID, id uuid.UUID) error {
+	_, err := db.QueryOne[uuid.UUID](ctx, r.conn, deleteWebAuthnCredentialSqlTemplate, id, user)
+	return err
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/db"
+	"github.com/Knoblauchpilze/backend-toolkit/pkg/errors"
+	"github.com/Knoblauchpilze/user-service/pkg/persistence"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIT_WebAuthnCredentialRepository_Create(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := newTestWebAuthnCredential(user.Id)
+
+	actual, err := repo.Create(context.Background(), credential)
+
+	assert.Nil(t, err)
+	assert.Equal(t, credential, actual)
+	assertWebAuthnCredentialExists(t, conn, credential.Id)
+}
+
+func TestIT_WebAuthnCredentialRepository_Create_WhenCredentialIdIsAlreadyRegistered_ExpectFailure(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	existing := insertTestWebAuthnCredential(t, conn, user.Id)
+	credential := newTestWebAuthnCredential(user.Id)
+	credential.CredentialId = existing.CredentialId
+
+	_, err := repo.Create(context.Background(), credential)
+
+	assert.NotNil(t, err)
+}
+
+func TestIT_WebAuthnCredentialRepository_Get(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+
+	actual, err := repo.Get(context.Background(), credential.CredentialId)
+
+	assert.Nil(t, err)
+	assert.Equal(t, credential, toUtcWebAuthnCredential(actual))
+}
+
+func TestIT_WebAuthnCredentialRepository_Get_WhenCredentialDoesNotExist_ExpectFailure(t *testing.T) {
+	repo, _ := newTestWebAuthnCredentialRepository(t)
+
+	_, err := repo.Get(context.Background(), []byte("not-a-credential-id"))
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnCredentialRepository_List(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential1 := insertTestWebAuthnCredential(t, conn, user.Id)
+	credential2 := insertTestWebAuthnCredential(t, conn, user.Id)
+	other := insertTestUser(t, conn)
+	insertTestWebAuthnCredential(t, conn, other.Id)
+
+	actual, err := repo.List(context.Background(), user.Id)
+
+	assert.Nil(t, err)
+	ids := []uuid.UUID{}
+	for _, credential := range actual {
+		ids = append(ids, credential.Id)
+	}
+	assert.ElementsMatch(t, []uuid.UUID{credential1.Id, credential2.Id}, ids)
+}
+
+func TestIT_WebAuthnCredentialRepository_Use(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+	usedAt := time.Date(2024, 11, 12, 16, 40, 20, 0, time.UTC)
+
+	err := repo.Use(context.Background(), credential.Id, credential.SignCount, 5, usedAt)
+
+	assert.Nil(t, err)
+	actual, err := repo.Get(context.Background(), credential.CredentialId)
+	require.Nil(t, err)
+	assert.Equal(t, int64(5), actual.SignCount)
+	require.NotNil(t, actual.LastUsedAt)
+	assert.Equal(t, usedAt, actual.LastUsedAt.UTC())
+}
+
+func TestIT_WebAuthnCredentialRepository_Use_WhenSignCountChangedConcurrently_ExpectOptimisticLockException(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+	err := repo.Use(context.Background(), credential.Id, credential.SignCount, 5, time.Now())
+	require.Nil(t, err)
+
+	err = repo.Use(context.Background(), credential.Id, credential.SignCount, 5, time.Now())
+
+	assert.True(t, errors.IsErrorWithCode(err, OptimisticLockException), "Actual err: %v", err)
+}
+
+func TestIT_WebAuthnCredentialRepository_Delete(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+
+	err := repo.Delete(context.Background(), user.Id, credential.Id)
+
+	assert.Nil(t, err)
+	assertWebAuthnCredentialDoesNotExist(t, conn, credential.Id)
+}
+
+func TestIT_WebAuthnCredentialRepository_Delete_WhenCredentialBelongsToAnotherUser_ExpectFailure(t *testing.T) {
+	repo, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+	other := insertTestUser(t, conn)
+
+	err := repo.Delete(context.Background(), other.Id, credential.Id)
+
+	assert.True(t, errors.IsErrorWithCode(err, db.NoMatchingRows), "Actual err: %v", err)
+	assertWebAuthnCredentialExists(t, conn, credential.Id)
+}
+
+func TestIT_WebAuthnCredentialRepository_WhenUserIsDeleted_ExpectCredentialsAreDeleted(t *testing.T) {
+	_, conn := newTestWebAuthnCredentialRepository(t)
+	user := insertTestUser(t, conn)
+	credential := insertTestWebAuthnCredential(t, conn, user.Id)
+
+	_, err := conn.Exec(context.Background(), "DELETE FROM api_user WHERE id = $1", user.Id)
+	require.Nil(t, err)
+
+	assertWebAuthnCredentialDoesNotExist(t, conn, credential.Id)
+}
+
+func newTestWebAuthnCredentialRepository(t *testing.T) (WebAuthnCredentialRepository, db.Connection) {
+	conn := newTestConnection(t)
+	return NewWebAuthnCredentialRepository(conn), conn
+}
+
+func assertWebAuthnCredentialExists(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[uuid.UUID](context.Background(), conn, "SELECT id FROM webauthn_credential WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Equal(t, id, value)
+}
+
+func assertWebAuthnCredentialDoesNotExist(t *testing.T, conn db.Connection, id uuid.UUID) {
+	value, err := db.QueryOne[int](context.Background(), conn, "SELECT COUNT(id) FROM webauthn_credential WHERE id = $1", id)
+	require.Nil(t, err)
+	require.Zero(t, value)
+}
+
+func newTestWebAuthnCredential(user uuid.UUID) persistence.WebAuthnCredential {
+	id := uuid.New()
+	return persistence.WebAuthnCredential{
+		Id:             id,
+		ApiUser:        user,
+		CredentialId:   []byte("my-credential-id-" + id.String()),
+		PublicKey:      []byte("my-public-key"),
+		SignCount:      1,
+		Transports:     []string{"internal", "hybrid"},
+		Aaguid:         uuid.New(),
+		Name:           "my-passkey",
+		BackupEligible: true,
+		CreatedAt:      time.Date(2024, 11, 12, 16, 32, 20, 0, time.UTC),
+	}
+}
+
+func insertTestWebAuthnCredential(t *testing.T, conn db.Connection, user uuid.UUID) persistence.WebAuthnCredential {
+	credential := newTestWebAuthnCredential(user)
+	_, err := NewWebAuthnCredentialRepository(conn).Create(context.Background(), credential)
+	require.Nil(t, err)
+	return credential
+}
+
+func toUtcWebAuthnCredential(credential persistence.WebAuthnCredential) persistence.WebAuthnCredential {
+	credential.CreatedAt = credential.CreatedAt.UTC()
+	if credential.LastUsedAt != nil {
+		usedAt := credential.LastUsedAt.UTC()
+		credential.LastUsedAt = &usedAt
+	}
+	return credential
+}